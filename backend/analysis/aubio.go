@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const aubioBinary = "aubio"
+
+// aubio reports no tempo confidence, only whether it saw enough beats to be
+// sure. These stand-ins keep its results ranked below essentia's typical
+// scores so confidence-sorted views still surface the better estimates.
+const (
+	aubioConfidence          = 0.5
+	aubioUncertainConfidence = 0.2
+)
+
+// Aubio wraps `aubio tempo`. It only detects tempo.
+type Aubio struct {
+	runner
+}
+
+func NewAubio(timeout time.Duration) *Aubio {
+	return &Aubio{runner{timeout: timeout}}
+}
+
+func (a *Aubio) Name() string              { return BackendAubio }
+func (a *Aubio) Provides() (bpm, key bool) { return true, false }
+func (a *Aubio) Available() bool           { return onPath(aubioBinary) }
+
+func (a *Aubio) Analyze(ctx context.Context, audioPath string) (Result, error) {
+	if err := checkInput(aubioBinary, audioPath); err != nil {
+		return Result{}, err
+	}
+	out, err := a.run(ctx, aubioBinary, "tempo", audioPath)
+	if err != nil {
+		return Result{}, err
+	}
+	bpm, conf, err := ParseAubioOutput(string(out))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrMalformedOutput, err)
+	}
+	return Result{BPM: bpm, BPMConfidence: conf, BPMBackend: BackendAubio}, nil
+}
+
+// ParseAubioOutput reads the summary line `aubio tempo` prints: "124.01 bpm",
+// "124.01 bpm (uncertain)" when it found fewer than ten beats, or
+// "unknown bpm". Unknown is returned as an error since tempo is all aubio
+// provides.
+func ParseAubioOutput(out string) (bpm, confidence float64, err error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	fields := strings.Fields(last)
+	if len(fields) < 2 || fields[1] != "bpm" {
+		return 0, 0, fmt.Errorf("unexpected aubio output %q", last)
+	}
+	if fields[0] == "unknown" {
+		return 0, 0, fmt.Errorf("aubio could not find a tempo")
+	}
+	bpm, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil || bpm <= 0 {
+		return 0, 0, fmt.Errorf("unexpected aubio tempo %q", fields[0])
+	}
+	confidence = aubioConfidence
+	if strings.Contains(last, "uncertain") {
+		confidence = aubioUncertainConfidence
+	}
+	return bpm, confidence, nil
+}
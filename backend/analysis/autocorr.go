@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Autocorrelation tempo estimator parameters. The signal is decoded to mono
+// at 11.025kHz (plenty for kick and snare energy) and cut into 128-sample
+// hops, giving an onset envelope at ~86Hz: about 2.5 BPM per lag step at
+// 128 BPM, refined further by interpolating between lags.
+const (
+	autocorrSampleRate = 11025
+	autocorrHop        = 128
+	autocorrMinBPM     = 60.0
+	autocorrMaxBPM     = 200.0
+	autocorrStepBPM    = 0.05
+	// autocorrDecodeSeconds bounds how much audio is decoded; tempo is
+	// stable enough that three minutes says as much as a whole extended mix.
+	autocorrDecodeSeconds = 180
+	// autocorrSkipSeconds skips beatless intros on tracks long enough to
+	// spare them.
+	autocorrSkipSeconds = 15
+	// autocorrPriorBPM and autocorrPriorOctaves shape the tempo prior that
+	// breaks ties between a tempo and its double or half, favouring the range
+	// dance music actually lives in.
+	autocorrPriorBPM     = 120.0
+	autocorrPriorOctaves = 1.0
+)
+
+// Autocorr is the pure-Go tempo estimator. It needs ffmpeg to decode audio
+// but nothing else, so it works on any box that can play the library.
+type Autocorr struct {
+	runner
+}
+
+func NewAutocorr(timeout time.Duration) *Autocorr {
+	return &Autocorr{runner{timeout: timeout}}
+}
+
+func (a *Autocorr) Name() string              { return BackendAutocorr }
+func (a *Autocorr) Provides() (bpm, key bool) { return true, false }
+func (a *Autocorr) Available() bool           { return onPath("ffmpeg") }
+
+func (a *Autocorr) Analyze(ctx context.Context, audioPath string) (Result, error) {
+	if err := checkInput("ffmpeg", audioPath); err != nil {
+		return Result{}, err
+	}
+	pcm, err := a.run(ctx, "ffmpeg", "-v", "error", "-i", audioPath,
+		"-t", fmt.Sprint(autocorrDecodeSeconds), "-vn", "-ac", "1", "-ar", fmt.Sprint(autocorrSampleRate),
+		"-f", "f32le", "pipe:1")
+	if err != nil {
+		return Result{}, err
+	}
+	samples := decodeF32LE(pcm)
+	if skip := autocorrSkipSeconds * autocorrSampleRate; len(samples) > 4*skip {
+		samples = samples[skip:]
+	}
+	bpm, conf := EstimateBPM(samples, autocorrSampleRate)
+	if bpm <= 0 {
+		return Result{}, fmt.Errorf("%w: no periodic onsets found", ErrMalformedOutput)
+	}
+	return Result{BPM: bpm, BPMConfidence: conf, BPMBackend: BackendAutocorr}, nil
+}
+
+func decodeF32LE(b []byte) []float32 {
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return out
+}
+
+// EstimateBPM estimates the tempo of mono samples at the given rate. It
+// builds an onset envelope from rises in log energy, autocorrelates it, and
+// scores each candidate tempo by the correlation at its first four beat
+// multiples and the half beat (a comb), weighted by a prior centred on 120
+// BPM. Confidence is the winner's mean normalised correlation across the
+// comb, so a track whose every beat lines up scores near 1.
+// Returns 0, 0 when there is too little audio or no periodic energy.
+func EstimateBPM(samples []float32, sampleRate int) (bpm, confidence float64) {
+	hop := autocorrHop * sampleRate / autocorrSampleRate
+	if hop < 1 {
+		hop = 1
+	}
+	frameRate := float64(sampleRate) / float64(hop)
+	frames := len(samples) / hop
+	maxLag := 60 * frameRate / autocorrMinBPM * 4 // comb reaches the 4th beat
+	if frames < int(maxLag)*2 {
+		return 0, 0
+	}
+
+	// Onset envelope: half-wave rectified rise in log energy per hop.
+	env := make([]float64, frames)
+	prev := 0.0
+	for i := 0; i < frames; i++ {
+		var e float64
+		for _, s := range samples[i*hop : (i+1)*hop] {
+			e += float64(s) * float64(s)
+		}
+		l := math.Log1p(1000 * e / float64(hop))
+		if d := l - prev; d > 0 && i > 0 {
+			env[i] = d
+		}
+		prev = l
+	}
+	mean := 0.0
+	for _, v := range env {
+		mean += v
+	}
+	mean /= float64(frames)
+	for i := range env {
+		env[i] -= mean
+	}
+
+	lags := int(maxLag) + 2
+	ac := make([]float64, lags)
+	for lag := 0; lag < lags; lag++ {
+		var sum float64
+		for i := lag; i < frames; i++ {
+			sum += env[i] * env[i-lag]
+		}
+		ac[lag] = sum / float64(frames-lag)
+	}
+	if ac[0] <= 0 {
+		return 0, 0
+	}
+
+	at := func(lag float64) float64 {
+		i := int(lag)
+		if i+1 >= len(ac) {
+			return 0
+		}
+		f := lag - float64(i)
+		return (ac[i]*(1-f) + ac[i+1]*f) / ac[0]
+	}
+
+	const combTeeth = 5
+	best, bestScore, bestRaw := 0.0, math.Inf(-1), 0.0
+	for cand := autocorrMinBPM; cand <= autocorrMaxBPM; cand += autocorrStepBPM {
+		lag := 60 * frameRate / cand
+		score := 0.0
+		for k := 1.0; k <= 4; k++ {
+			score += at(lag * k)
+		}
+		// Reward a pulse at the half beat. Without it a tempo and the one 2/3
+		// of it (174 and 116) share every other comb tooth and only the prior
+		// separates them; music is overwhelmingly in duple time, so a strong
+		// half-beat pulse is what marks the real tempo.
+		score += at(lag / 2)
+		raw := score
+		octaves := math.Log2(cand / autocorrPriorBPM)
+		score *= math.Exp(-0.5 * (octaves / autocorrPriorOctaves) * (octaves / autocorrPriorOctaves))
+		if score > bestScore {
+			best, bestScore, bestRaw = cand, score, raw
+		}
+	}
+	if bestScore <= 0 {
+		return 0, 0
+	}
+	return math.Round(best*100) / 100, clamp01(bestRaw / combTeeth)
+}
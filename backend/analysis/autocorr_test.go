@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// clickTrack synthesises a kick-like burst on every beat plus a quieter
+// off-beat hat, over low background noise.
+func clickTrack(bpm float64, seconds, sampleRate int, seed int64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, seconds*sampleRate)
+	for i := range out {
+		out[i] = float32(rng.NormFloat64() * 0.01)
+	}
+	beat := 60 / bpm * float64(sampleRate)
+	burst := sampleRate / 50 // 20ms
+	for b := 0.0; int(b) < len(out); b += beat {
+		for _, hit := range []struct {
+			offset float64
+			amp    float64
+			freq   float64
+		}{{0, 0.9, 60}, {beat / 2, 0.3, 3000}} {
+			start := int(b + hit.offset)
+			for j := 0; j < burst && start+j < len(out); j++ {
+				decay := math.Exp(-float64(j) / float64(burst/4))
+				out[start+j] += float32(hit.amp * decay * math.Sin(2*math.Pi*hit.freq*float64(j)/float64(sampleRate)))
+			}
+		}
+	}
+	return out
+}
+
+func TestEstimateBPM_ClickTracks(t *testing.T) {
+	for _, want := range []float64{87, 100, 124, 128, 140, 174} {
+		samples := clickTrack(want, 40, autocorrSampleRate, int64(want))
+		got, conf := EstimateBPM(samples, autocorrSampleRate)
+		if math.Abs(got-want) > 1 {
+			t.Errorf("EstimateBPM(%v BPM clicks) = %v (conf %.2f)", want, got, conf)
+		}
+		if conf <= 0 {
+			t.Errorf("EstimateBPM(%v BPM clicks) confidence = %v, want > 0", want, conf)
+		}
+	}
+}
+
+func TestEstimateBPM_TooShortOrSilent(t *testing.T) {
+	if got, _ := EstimateBPM(make([]float32, autocorrSampleRate), autocorrSampleRate); got != 0 {
+		t.Errorf("1s of audio: got %v, want 0", got)
+	}
+	if got, _ := EstimateBPM(make([]float32, 30*autocorrSampleRate), autocorrSampleRate); got != 0 {
+		t.Errorf("silence: got %v, want 0", got)
+	}
+}
+
+func TestEstimateBPM_NoiseHasLowConfidence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float32, 40*autocorrSampleRate)
+	for i := range samples {
+		samples[i] = float32(rng.NormFloat64() * 0.3)
+	}
+	clicks := clickTrack(128, 40, autocorrSampleRate, 1)
+	_, noiseConf := EstimateBPM(samples, autocorrSampleRate)
+	_, clickConf := EstimateBPM(clicks, autocorrSampleRate)
+	if noiseConf >= clickConf/2 {
+		t.Errorf("noise confidence %.2f should be well below click confidence %.2f", noiseConf, clickConf)
+	}
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Backend names, as used in ANALYSIS_BACKENDS and recorded per track in
+// bpm_backend / key_backend.
+const (
+	BackendEssentia  = "essentia"
+	BackendKeyFinder = "keyfinder"
+	BackendAubio     = "aubio"
+	BackendAutocorr  = "autocorr"
+)
+
+// DefaultBackends is the chain used when ANALYSIS_BACKENDS is unset: essentia
+// first since it produces both fields with real confidences, then the
+// single-purpose tools, then the pure-Go estimator, which only needs ffmpeg.
+var DefaultBackends = []string{BackendEssentia, BackendKeyFinder, BackendAubio, BackendAutocorr}
+
+// Backend is one analysis tool. Backends may fill only some fields of the
+// Result: Provides says which, so the chain can skip backends that have
+// nothing left to contribute.
+type Backend interface {
+	analyzer
+	Name() string
+	// Available reports whether the backend's external tools are on PATH.
+	// It is a hint only; Analyze re-checks.
+	Available() bool
+	Provides() (bpm, key bool)
+}
+
+// NewBackend constructs a backend by name.
+func NewBackend(name string, timeout time.Duration) (Backend, error) {
+	switch name {
+	case BackendEssentia:
+		return NewEssentia(timeout), nil
+	case BackendKeyFinder:
+		return NewKeyFinder(timeout), nil
+	case BackendAubio:
+		return NewAubio(timeout), nil
+	case BackendAutocorr:
+		return NewAutocorr(timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown analysis backend %q (want one of %s)", name, strings.Join(DefaultBackends, ", "))
+	}
+}
+
+// ParseBackendNames splits a comma-separated backend list, normalising case
+// and dropping blanks. An empty string yields DefaultBackends.
+func ParseBackendNames(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return DefaultBackends
+	}
+	return names
+}
+
+// Chain runs backends in order and fills each field from the first backend
+// that produces it. A track gets essentia's key and aubio's tempo if essentia
+// is missing but keyfinder isn't, and so on down the list.
+type Chain struct {
+	backends []Backend
+}
+
+func NewChain(names []string, timeout time.Duration) (*Chain, error) {
+	c := &Chain{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		b, err := NewBackend(name, timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.backends = append(c.backends, b)
+	}
+	if len(c.backends) == 0 {
+		return nil, errors.New("no analysis backends configured")
+	}
+	return c, nil
+}
+
+// Backends returns the configured backends in chain order.
+func (c *Chain) Backends() []Backend {
+	return c.backends
+}
+
+// SetLimits applies process limits to every backend that shells out.
+func (c *Chain) SetLimits(l Limits) {
+	for _, b := range c.backends {
+		if lb, ok := b.(limiter); ok {
+			lb.setLimits(l)
+		}
+	}
+}
+
+// Available reports whether at least one backend can run.
+func (c *Chain) Available() bool {
+	for _, b := range c.backends {
+		if b.Available() {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze runs the chain. It only fails when no backend produced either
+// field; a tempo with no key (or the reverse) is a usable result. When every
+// backend failed because its binary is missing the error wraps
+// ErrBinaryMissing, so the manager backs off instead of burning retries.
+func (c *Chain) Analyze(ctx context.Context, audioPath string) (Result, error) {
+	var res Result
+	var failures []error
+	allMissing := true
+
+	for _, b := range c.backends {
+		wantBPM, wantKey := b.Provides()
+		wantBPM = wantBPM && res.BPM <= 0
+		wantKey = wantKey && res.Key == ""
+		if !wantBPM && !wantKey {
+			continue
+		}
+
+		r, err := b.Analyze(ctx, audioPath)
+		if err != nil {
+			// Neither of these gets better by trying the next tool.
+			if errors.Is(err, ErrFileMissing) || ctx.Err() != nil {
+				return Result{}, err
+			}
+			if !errors.Is(err, ErrBinaryMissing) {
+				allMissing = false
+				failures = append(failures, fmt.Errorf("%s: %w", b.Name(), err))
+			}
+			continue
+		}
+		allMissing = false
+
+		if wantBPM && r.BPM > 0 {
+			res.BPM, res.BPMConfidence, res.BPMBackend = r.BPM, r.BPMConfidence, b.Name()
+			res.Beats = r.Beats
+		}
+		if wantKey && r.Key != "" {
+			res.Key, res.KeyConfidence, res.KeyBackend = r.Key, r.KeyConfidence, b.Name()
+		}
+		if res.Descriptors == nil && r.Descriptors != nil {
+			res.Descriptors, res.Raw = r.Descriptors, r.Raw
+		}
+		if res.BPM > 0 && res.Key != "" {
+			break
+		}
+	}
+
+	if res.BPM > 0 || res.Key != "" {
+		return res, nil
+	}
+	if allMissing {
+		return Result{}, fmt.Errorf("%w: none of the configured backends are installed", ErrBinaryMissing)
+	}
+	if len(failures) == 0 {
+		return Result{}, fmt.Errorf("%w: no backend detected a tempo or key", ErrMalformedOutput)
+	}
+	return Result{}, errors.Join(failures...)
+}
@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeBackend is a Backend with canned output for chain tests.
+type fakeBackend struct {
+	name     string
+	bpm, key bool
+	result   Result
+	err      error
+	calls    int
+}
+
+func (f *fakeBackend) Name() string              { return f.name }
+func (f *fakeBackend) Provides() (bpm, key bool) { return f.bpm, f.key }
+func (f *fakeBackend) Available() bool           { return !errors.Is(f.err, ErrBinaryMissing) }
+func (f *fakeBackend) Analyze(ctx context.Context, path string) (Result, error) {
+	f.calls++
+	return f.result, f.err
+}
+
+func TestChain_FillsEachFieldFromFirstProvider(t *testing.T) {
+	essentia := &fakeBackend{name: BackendEssentia, bpm: true, key: true, err: ErrBinaryMissing}
+	keyfinder := &fakeBackend{name: BackendKeyFinder, key: true, result: Result{Key: "8A"}}
+	aubio := &fakeBackend{name: BackendAubio, bpm: true, err: ErrMalformedOutput}
+	autocorr := &fakeBackend{name: BackendAutocorr, bpm: true, result: Result{BPM: 124, BPMConfidence: 0.6}}
+	chain := &Chain{backends: []Backend{essentia, keyfinder, aubio, autocorr}}
+
+	res, err := chain.Analyze(context.Background(), "/a.wav")
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	want := Result{BPM: 124, BPMConfidence: 0.6, BPMBackend: BackendAutocorr, Key: "8A", KeyBackend: BackendKeyFinder}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("result = %+v, want %+v", res, want)
+	}
+}
+
+func TestChain_SkipsBackendsWithNothingLeftToAdd(t *testing.T) {
+	essentia := &fakeBackend{name: BackendEssentia, bpm: true, key: true, result: Result{BPM: 128, Key: "9B"}}
+	aubio := &fakeBackend{name: BackendAubio, bpm: true, result: Result{BPM: 64}}
+	chain := &Chain{backends: []Backend{essentia, aubio}}
+
+	res, err := chain.Analyze(context.Background(), "/a.wav")
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if res.BPM != 128 || res.BPMBackend != BackendEssentia {
+		t.Errorf("result = %+v, want essentia's tempo", res)
+	}
+	if aubio.calls != 0 {
+		t.Errorf("aubio called %d times, want 0", aubio.calls)
+	}
+}
+
+func TestChain_AllMissingIsBinaryMissing(t *testing.T) {
+	chain := &Chain{backends: []Backend{
+		&fakeBackend{name: BackendEssentia, bpm: true, key: true, err: ErrBinaryMissing},
+		&fakeBackend{name: BackendAutocorr, bpm: true, err: ErrBinaryMissing},
+	}}
+	_, err := chain.Analyze(context.Background(), "/a.wav")
+	if !errors.Is(err, ErrBinaryMissing) {
+		t.Fatalf("want ErrBinaryMissing, got %v", err)
+	}
+	if chain.Available() {
+		t.Error("Available() = true with every backend missing")
+	}
+}
+
+func TestChain_FailuresAreRetryable(t *testing.T) {
+	chain := &Chain{backends: []Backend{
+		&fakeBackend{name: BackendEssentia, bpm: true, key: true, err: ErrBinaryMissing},
+		&fakeBackend{name: BackendAubio, bpm: true, err: ErrTimeout},
+	}}
+	_, err := chain.Analyze(context.Background(), "/a.wav")
+	if err == nil || errors.Is(err, ErrBinaryMissing) {
+		t.Fatalf("want a retryable error, got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("want wrapped ErrTimeout, got %v", err)
+	}
+}
+
+func TestChain_FileMissingShortCircuits(t *testing.T) {
+	essentia := &fakeBackend{name: BackendEssentia, bpm: true, key: true, err: ErrFileMissing}
+	autocorr := &fakeBackend{name: BackendAutocorr, bpm: true, result: Result{BPM: 120}}
+	chain := &Chain{backends: []Backend{essentia, autocorr}}
+
+	_, err := chain.Analyze(context.Background(), "/gone.wav")
+	if !errors.Is(err, ErrFileMissing) {
+		t.Fatalf("want ErrFileMissing, got %v", err)
+	}
+	if autocorr.calls != 0 {
+		t.Errorf("autocorr called %d times after file missing", autocorr.calls)
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	chain, err := NewChain([]string{"autocorr", "essentia", "autocorr"}, 0)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	var names []string
+	for _, b := range chain.Backends() {
+		names = append(names, b.Name())
+	}
+	if !reflect.DeepEqual(names, []string{BackendAutocorr, BackendEssentia}) {
+		t.Errorf("backends = %v", names)
+	}
+	if _, err := NewChain([]string{"madmom"}, 0); err == nil {
+		t.Error("want error for unknown backend")
+	}
+}
+
+func TestParseBackendNames(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", DefaultBackends},
+		{" , ", DefaultBackends},
+		{"Aubio, keyfinder", []string{"aubio", "keyfinder"}},
+		{"autocorr", []string{"autocorr"}},
+	}
+	for _, tc := range cases {
+		if got := ParseBackendNames(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseBackendNames(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseKeyFinderOutput(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"Am\n", "8A", false},
+		{"F#\n", "2B", false},
+		{"Ebm", "2A", false},
+		{"silence\n", "", false},
+		{"", "", false},
+		{"H#m", "", true},
+	}
+	for _, tc := range cases {
+		got, err := ParseKeyFinderOutput(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParseKeyFinderOutput(%q) = %q, %v; want %q, err=%v", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestParseAubioOutput(t *testing.T) {
+	cases := []struct {
+		in       string
+		wantBPM  float64
+		wantConf float64
+		wantErr  bool
+	}{
+		{"124.01 bpm\n", 124.01, aubioConfidence, false},
+		{"warming up\n87.5 bpm\n", 87.5, aubioConfidence, false},
+		{"140.00 bpm (uncertain)\n", 140, aubioUncertainConfidence, false},
+		{"unknown bpm\n", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, tc := range cases {
+		bpm, conf, err := ParseAubioOutput(tc.in)
+		if (err != nil) != tc.wantErr || bpm != tc.wantBPM || conf != tc.wantConf {
+			t.Errorf("ParseAubioOutput(%q) = %v, %v, %v; want %v, %v, err=%v", tc.in, bpm, conf, err, tc.wantBPM, tc.wantConf, tc.wantErr)
+		}
+	}
+}
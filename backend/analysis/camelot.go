@@ -37,3 +37,49 @@ func ToCamelot(key, scale string) string {
 		return ""
 	}
 }
+
+// ParseCamelot splits Camelot notation ("8A") into its wheel number (1-12)
+// and side letter ('A' = minor, 'B' = major). ok is false for anything that
+// is not strictly valid Camelot; callers are expected to have normalized case.
+func ParseCamelot(k string) (num int, letter byte, ok bool) {
+	if len(k) < 2 || len(k) > 3 {
+		return 0, 0, false
+	}
+	letter = k[len(k)-1]
+	if letter != 'A' && letter != 'B' {
+		return 0, 0, false
+	}
+	for _, c := range k[:len(k)-1] {
+		if c < '0' || c > '9' {
+			return 0, 0, false
+		}
+		num = num*10 + int(c-'0')
+	}
+	if num < 1 || num > 12 || (len(k) == 3 && k[0] == '0') {
+		return 0, 0, false
+	}
+	return num, letter, true
+}
+
+// CamelotDistance counts the harmonic moves between two Camelot keys: steps
+// around the wheel (wrapping 12 -> 1) plus one if the A/B side differs. Same
+// key is 0; adjacent numbers and relative major/minor are 1. Returns -1 if
+// either key is not valid Camelot.
+func CamelotDistance(a, b string) int {
+	na, la, okA := ParseCamelot(a)
+	nb, lb, okB := ParseCamelot(b)
+	if !okA || !okB {
+		return -1
+	}
+	steps := na - nb
+	if steps < 0 {
+		steps = -steps
+	}
+	if steps > 6 {
+		steps = 12 - steps
+	}
+	if la != lb {
+		steps++
+	}
+	return steps
+}
@@ -58,3 +58,51 @@ func TestToCamelot_Invalid(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCamelot(t *testing.T) {
+	cases := []struct {
+		in     string
+		num    int
+		letter byte
+		ok     bool
+	}{
+		{"8A", 8, 'A', true},
+		{"12B", 12, 'B', true},
+		{"1B", 1, 'B', true},
+		{"0A", 0, 0, false},
+		{"13A", 0, 0, false},
+		{"08A", 0, 0, false},
+		{"8a", 0, 0, false},
+		{"8C", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tc := range cases {
+		num, letter, ok := ParseCamelot(tc.in)
+		if num != tc.num || letter != tc.letter || ok != tc.ok {
+			t.Errorf("ParseCamelot(%q) = (%d, %q, %v), want (%d, %q, %v)", tc.in, num, letter, ok, tc.num, tc.letter, tc.ok)
+		}
+	}
+}
+
+func TestCamelotDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"8A", "8A", 0},
+		{"8A", "9A", 1},
+		{"8A", "7A", 1},
+		{"8A", "8B", 1},
+		{"12A", "1A", 1}, // wheel wraps
+		{"1B", "12A", 2},
+		{"8A", "2A", 6},
+		{"8A", "3B", 6},
+		{"8A", "", -1},
+		{"X", "8A", -1},
+	}
+	for _, tc := range cases {
+		if got := CamelotDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("CamelotDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package analysis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Moods reported from essentia's high-level classifiers, in tie-break order.
+var Moods = []string{"happy", "sad", "aggressive", "relaxed", "party"}
+
+// Descriptors is the curated set of audio descriptors kept per track.
+// essentia's default extractor computes everything but Mood; the mood
+// classifiers only run when it is configured with the SVM models.
+type Descriptors struct {
+	Energy            int      // 1-10, see EnergyRating
+	Danceability      float64  // 0-1
+	DynamicComplexity float64  // dB; higher means more loudness variation
+	LoudnessLUFS      *float64 // integrated loudness; nil on essentia builds without EBU R128
+	SpectralCentroid  float64  // Hz; brightness
+	OnsetRate         float64  // onsets per second; rhythmic density
+	Mood              string   // dominant mood, "" if unknown
+
+	// Timbre for similarity search, kept in track_features rather than
+	// columns. MFCC is nil when the extractor didn't report it.
+	MFCC            []float64 // MFCC means, MFCCBands of them
+	SpectralRolloff float64   // Hz
+	SpectralFlux    float64
+}
+
+// MFCCBands is how many MFCC means essentia reports.
+const MFCCBands = 13
+
+// Descriptor scaling. essentia's danceability (detrended fluctuation
+// analysis) runs from 0 to about 3. The energy inputs are mapped to [0, 1]
+// over the ranges club tracks actually span.
+const (
+	essentiaDanceabilityScale = 3.0
+	energyQuietLUFS           = -30.0
+	energyLoudLUFS            = -5.0
+	energyMaxOnsetRate        = 6.0
+	energyDarkCentroid        = 500.0
+	energyBrightCentroid      = 3500.0
+	energyMaxDynamicDB        = 10.0
+	moodMinProbability        = 0.5
+)
+
+type rawHighlevel struct {
+	All map[string]float64 `json:"all"`
+}
+
+type rawDescriptors struct {
+	Lowlevel *struct {
+		AverageLoudness   float64 `json:"average_loudness"`
+		DynamicComplexity float64 `json:"dynamic_complexity"`
+		LoudnessEBU128    struct {
+			Integrated *float64 `json:"integrated"`
+		} `json:"loudness_ebu128"`
+		SpectralCentroid struct {
+			Mean float64 `json:"mean"`
+		} `json:"spectral_centroid"`
+		SpectralRolloff struct {
+			Mean float64 `json:"mean"`
+		} `json:"spectral_rolloff"`
+		SpectralFlux struct {
+			Mean float64 `json:"mean"`
+		} `json:"spectral_flux"`
+		MFCC struct {
+			Mean []float64 `json:"mean"`
+		} `json:"mfcc"`
+	} `json:"lowlevel"`
+	Rhythm struct {
+		Danceability *float64 `json:"danceability"`
+		OnsetRate    float64  `json:"onset_rate"`
+	} `json:"rhythm"`
+	Highlevel map[string]rawHighlevel `json:"highlevel"`
+}
+
+// ParseEssentiaDescriptors pulls the curated descriptors out of
+// streaming_extractor_music JSON. It returns nil when the output has no
+// low-level section or danceability (e.g. a trimmed profile).
+func ParseEssentiaDescriptors(raw []byte) (*Descriptors, error) {
+	var e rawDescriptors
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, fmt.Errorf("parse essentia json: %w", err)
+	}
+	if e.Lowlevel == nil || e.Rhythm.Danceability == nil {
+		return nil, nil
+	}
+	d := &Descriptors{
+		Danceability:      clamp01(*e.Rhythm.Danceability / essentiaDanceabilityScale),
+		DynamicComplexity: finite(e.Lowlevel.DynamicComplexity),
+		SpectralCentroid:  finite(e.Lowlevel.SpectralCentroid.Mean),
+		OnsetRate:         finite(e.Rhythm.OnsetRate),
+		SpectralRolloff:   finite(e.Lowlevel.SpectralRolloff.Mean),
+		SpectralFlux:      finite(e.Lowlevel.SpectralFlux.Mean),
+	}
+	if len(e.Lowlevel.MFCC.Mean) == MFCCBands {
+		d.MFCC = make([]float64, MFCCBands)
+		for i, v := range e.Lowlevel.MFCC.Mean {
+			d.MFCC[i] = finite(v)
+		}
+	}
+	if l := e.Lowlevel.LoudnessEBU128.Integrated; l != nil && !math.IsNaN(*l) && !math.IsInf(*l, 0) {
+		v := *l
+		d.LoudnessLUFS = &v
+	}
+
+	best := 0.0
+	for _, mood := range Moods {
+		if p := e.Highlevel["mood_"+mood].All[mood]; p >= moodMinProbability && p > best {
+			d.Mood, best = mood, p
+		}
+	}
+
+	d.Energy = EnergyRating(d, e.Lowlevel.AverageLoudness)
+	return d, nil
+}
+
+// EnergyRating condenses descriptors into a 1-10 rating in the spirit of
+// Mixed In Key's: loud, dense, bright, danceable tracks with little
+// dynamic range score high; quiet, sparse, dark or very dynamic ones low.
+// averageLoudness (essentia's 0-1 measure) stands in for LUFS when the
+// extractor didn't report integrated loudness.
+func EnergyRating(d *Descriptors, averageLoudness float64) int {
+	loud := clamp01(averageLoudness)
+	if d.LoudnessLUFS != nil {
+		loud = clamp01((*d.LoudnessLUFS - energyQuietLUFS) / (energyLoudLUFS - energyQuietLUFS))
+	}
+	density := clamp01(d.OnsetRate / energyMaxOnsetRate)
+	bright := clamp01((d.SpectralCentroid - energyDarkCentroid) / (energyBrightCentroid - energyDarkCentroid))
+	steady := 1 - clamp01(d.DynamicComplexity/energyMaxDynamicDB)
+
+	score := 0.35*loud + 0.2*density + 0.15*bright + 0.2*d.Danceability + 0.1*steady
+	return 1 + int(math.Round(clamp01(score)*9))
+}
+
+func finite(x float64) float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0
+	}
+	return x
+}
+
+// CompressRaw gzips analyzer output for storage. Essentia's JSON shrinks
+// about five-fold.
+func CompressRaw(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressRaw reverses CompressRaw.
+func DecompressRaw(blob []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// EncodeMFCC packs MFCC means as little-endian float32s.
+func EncodeMFCC(mfcc []float64) []byte {
+	out := make([]byte, 4*len(mfcc))
+	for i, v := range mfcc {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(float32(v)))
+	}
+	return out
+}
+
+// DecodeMFCC reverses EncodeMFCC. It returns nil for a blob of the wrong
+// size.
+func DecodeMFCC(blob []byte) []float64 {
+	if len(blob) != 4*MFCCBands {
+		return nil
+	}
+	out := make([]float64, MFCCBands)
+	for i := range out {
+		out[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:])))
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestParseEssentiaDescriptors_Fixture(t *testing.T) {
+	raw, err := os.ReadFile("testdata/essentia_descriptors.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	d, err := ParseEssentiaDescriptors(raw)
+	if err != nil {
+		t.Fatalf("ParseEssentiaDescriptors: %v", err)
+	}
+	if d == nil {
+		t.Fatal("descriptors = nil, want parsed values")
+	}
+	// danceability 1.62 on essentia's 0-3 scale.
+	if d.Danceability < 0.539 || d.Danceability > 0.541 {
+		t.Errorf("Danceability = %v, want 0.54", d.Danceability)
+	}
+	if d.LoudnessLUFS == nil || *d.LoudnessLUFS != -7.8 {
+		t.Errorf("LoudnessLUFS = %v, want -7.8", d.LoudnessLUFS)
+	}
+	if d.DynamicComplexity != 2.4 || d.SpectralCentroid != 2150.5 || d.OnsetRate != 4.3 {
+		t.Errorf("descriptors = %+v", d)
+	}
+	// party beats aggressive; happy is under the 0.5 threshold.
+	if d.Mood != "party" {
+		t.Errorf("Mood = %q, want party", d.Mood)
+	}
+	if d.Energy != 7 {
+		t.Errorf("Energy = %d, want 7", d.Energy)
+	}
+	if len(d.MFCC) != MFCCBands || d.MFCC[1] != 118.9 || d.SpectralRolloff != 4380.2 || d.SpectralFlux != 0.094 {
+		t.Errorf("timbre: mfcc = %v, rolloff = %v, flux = %v", d.MFCC, d.SpectralRolloff, d.SpectralFlux)
+	}
+}
+
+func TestMFCCRoundTrip(t *testing.T) {
+	in := []float64{-612.5, 118.75, -21.5, 30.25, -4.75, 9, -6.25, 4.5, -2.75, 3.5, -1.25, 2.5, -0.75}
+	got := DecodeMFCC(EncodeMFCC(in))
+	for i := range in {
+		if got[i] != in[i] {
+			t.Fatalf("round trip = %v, want %v", got, in)
+		}
+	}
+	if DecodeMFCC([]byte{1, 2, 3}) != nil {
+		t.Error("short blob should decode to nil")
+	}
+}
+
+func TestParseEssentiaOutput_KeepsDescriptors(t *testing.T) {
+	raw, err := os.ReadFile("testdata/essentia_descriptors.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	got, err := ParseEssentiaOutput(raw)
+	if err != nil {
+		t.Fatalf("ParseEssentiaOutput: %v", err)
+	}
+	if got.Descriptors == nil || got.Descriptors.Mood != "party" {
+		t.Errorf("Descriptors = %+v, want the fixture's", got.Descriptors)
+	}
+}
+
+func TestParseEssentiaDescriptors_MissingSections(t *testing.T) {
+	raw, err := os.ReadFile("testdata/essentia_success.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	d, err := ParseEssentiaDescriptors(raw)
+	if err != nil {
+		t.Fatalf("ParseEssentiaDescriptors: %v", err)
+	}
+	if d != nil {
+		t.Errorf("descriptors = %+v, want nil without lowlevel", d)
+	}
+}
+
+func TestParseEssentiaDescriptors_NoMoodOrLUFS(t *testing.T) {
+	raw := []byte(`{"lowlevel":{"average_loudness":0.5},"rhythm":{"danceability":0.9}}`)
+	d, err := ParseEssentiaDescriptors(raw)
+	if err != nil || d == nil {
+		t.Fatalf("ParseEssentiaDescriptors = %v, %v", d, err)
+	}
+	if d.Mood != "" || d.LoudnessLUFS != nil {
+		t.Errorf("Mood = %q, LoudnessLUFS = %v, want both unset", d.Mood, d.LoudnessLUFS)
+	}
+}
+
+func TestEnergyRating_Bounds(t *testing.T) {
+	quiet, loud := -40.0, 0.0
+	low := EnergyRating(&Descriptors{LoudnessLUFS: &quiet, SpectralCentroid: 100, DynamicComplexity: 20}, 0)
+	if low != 1 {
+		t.Errorf("quiet sparse track: energy = %d, want 1", low)
+	}
+	high := EnergyRating(&Descriptors{
+		LoudnessLUFS: &loud, Danceability: 1, OnsetRate: 10, SpectralCentroid: 5000,
+	}, 1)
+	if high != 10 {
+		t.Errorf("loud dense track: energy = %d, want 10", high)
+	}
+}
+
+func TestEnergyRating_FallsBackToAverageLoudness(t *testing.T) {
+	d := &Descriptors{Danceability: 0.5, OnsetRate: 3, SpectralCentroid: 2000, DynamicComplexity: 5}
+	if q, l := EnergyRating(d, 0.1), EnergyRating(d, 0.9); q >= l {
+		t.Errorf("energy quiet = %d, loud = %d; want louder to rate higher", q, l)
+	}
+}
+
+func TestCompressRaw_RoundTrip(t *testing.T) {
+	raw, err := os.ReadFile("testdata/essentia_descriptors.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	blob, err := CompressRaw(raw)
+	if err != nil {
+		t.Fatalf("CompressRaw: %v", err)
+	}
+	got, err := DecompressRaw(blob)
+	if err != nil {
+		t.Fatalf("DecompressRaw: %v", err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Error("round trip changed the output")
+	}
+	if _, err := DecompressRaw([]byte("not gzip")); err == nil {
+		t.Error("DecompressRaw accepted garbage")
+	}
+}
@@ -12,41 +12,52 @@ import (
 
 // Sentinel errors so callers (the manager) can decide retry policy.
 var (
-	ErrBinaryMissing   = errors.New("essentia binary not found on PATH")
+	ErrBinaryMissing   = errors.New("analyzer binary not found on PATH")
 	ErrFileMissing     = errors.New("audio file not found")
-	ErrTimeout         = errors.New("essentia analysis timed out")
-	ErrMalformedOutput = errors.New("essentia produced malformed output")
+	ErrTimeout         = errors.New("analysis timed out")
+	ErrMalformedOutput = errors.New("analyzer produced malformed output")
 )
 
-const binaryName = "streaming_extractor_music"
+const essentiaBinary = "streaming_extractor_music"
 
-// Analyzer wraps the streaming_extractor_music binary.
-type Analyzer struct {
-	timeout time.Duration
+// Essentia wraps the streaming_extractor_music binary.
+type Essentia struct {
+	runner
 }
 
-func NewAnalyzer(timeout time.Duration) *Analyzer {
-	return &Analyzer{timeout: timeout}
+func NewEssentia(timeout time.Duration) *Essentia {
+	return &Essentia{runner{timeout: timeout}}
 }
 
-// BinaryAvailable reports whether the essentia binary is on PATH. Call once
-// at startup to decide whether to start the ticker. Analyze() re-checks, so
-// this is an optimization signal only — not a guarantee.
-func BinaryAvailable() bool {
-	_, err := exec.LookPath(binaryName)
+func (a *Essentia) Name() string              { return BackendEssentia }
+func (a *Essentia) Provides() (bpm, key bool) { return true, true }
+
+func (a *Essentia) Available() bool { return onPath(essentiaBinary) }
+
+func onPath(binary string) bool {
+	_, err := exec.LookPath(binary)
 	return err == nil
 }
 
-// Analyze runs the essentia extractor on the given audio file path.
-func (a *Analyzer) Analyze(ctx context.Context, audioPath string) (Result, error) {
-	if _, err := exec.LookPath(binaryName); err != nil {
-		return Result{}, ErrBinaryMissing
+// checkInput is the common preflight for backends that shell out: the tool
+// must be on PATH and the audio file must exist.
+func checkInput(binary, audioPath string) error {
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("%w: %s", ErrBinaryMissing, binary)
 	}
 	if _, err := os.Stat(audioPath); err != nil {
 		if os.IsNotExist(err) {
-			return Result{}, ErrFileMissing
+			return ErrFileMissing
 		}
-		return Result{}, fmt.Errorf("stat audio: %w", err)
+		return fmt.Errorf("stat audio: %w", err)
+	}
+	return nil
+}
+
+// Analyze runs the essentia extractor on the given audio file path.
+func (a *Essentia) Analyze(ctx context.Context, audioPath string) (Result, error) {
+	if err := checkInput(essentiaBinary, audioPath); err != nil {
+		return Result{}, err
 	}
 
 	outDir, err := os.MkdirTemp("", "essentia-*")
@@ -58,27 +69,14 @@ func (a *Analyzer) Analyze(ctx context.Context, audioPath string) (Result, error
 	// output format from the extension.
 	outPath := filepath.Join(outDir, "out.json")
 
-	runCtx, cancel := context.WithTimeout(ctx, a.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(runCtx, binaryName, audioPath, outPath)
-	output, err := cmd.CombinedOutput()
-	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
-		return Result{}, ErrTimeout
-	}
-	// Preserve parent-ctx cancellation so the manager can distinguish shutdown
-	// from an actual analysis failure.
-	if ctx.Err() != nil {
-		return Result{}, ctx.Err()
-	}
-	if err != nil {
-		return Result{}, fmt.Errorf("essentia exec failed: %w (output: %s)", err, string(output))
+	if _, err := a.run(ctx, essentiaBinary, audioPath, outPath); err != nil {
+		return Result{}, err
 	}
 
 	raw, err := os.ReadFile(outPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return Result{}, fmt.Errorf("%w: output file not created (stderr: %s)", ErrMalformedOutput, string(output))
+			return Result{}, fmt.Errorf("%w: output file not created", ErrMalformedOutput)
 		}
 		return Result{}, fmt.Errorf("read essentia output: %w", err)
 	}
@@ -86,5 +84,12 @@ func (a *Analyzer) Analyze(ctx context.Context, audioPath string) (Result, error
 	if err != nil {
 		return Result{}, fmt.Errorf("%w: %v", ErrMalformedOutput, err)
 	}
+	result.BPMBackend = BackendEssentia
+	if result.Descriptors != nil {
+		result.Raw = raw
+	}
+	if result.Key != "" {
+		result.KeyBackend = BackendEssentia
+	}
 	return result, nil
 }
@@ -13,18 +13,36 @@ import (
 // Ref: https://essentia.upf.edu/reference/streaming_RhythmExtractor2013.html
 const essentiaBPMConfidenceScale = 5.0
 
-// Result is the structured output of an essentia analysis run.
+// Result is the structured output of an analysis run. A backend may fill
+// only the tempo or only the key; the Backend fields name which backend
+// produced each value.
 type Result struct {
-	BPM           float64
+	BPM           float64 // 0 if unknown
 	BPMConfidence float64 // in [0, 1]
 	Key           string  // Camelot notation, e.g. "8A"; "" if unknown
-	KeyConfidence float64 // in [0, 1]; 0 if key unknown
+	KeyConfidence float64 // in [0, 1]; 0 if key unknown or not reported
+	BPMBackend    string
+	KeyBackend    string
+	// Beats are detected beat positions in seconds, from backends that
+	// track beats (essentia); nil otherwise. They travel with BPM.
+	Beats []float64
+	// Descriptors are essentia's energy, danceability and mood descriptors;
+	// nil from other backends. Raw is the output they were parsed from,
+	// kept so they can be re-derived without rerunning the analyzer.
+	Descriptors *Descriptors
+	Raw         []byte
+	// RawBPM is the analyzer's tempo when NormalizeTempo corrected BPM, and
+	// SuggestedBPM a probable octave correction it left to the user; 0
+	// otherwise.
+	RawBPM       float64
+	SuggestedBPM float64
 }
 
 type rawEssentia struct {
 	Rhythm struct {
-		BPM           float64 `json:"bpm"`
-		BPMConfidence float64 `json:"bpm_confidence"`
+		BPM           float64   `json:"bpm"`
+		BPMConfidence float64   `json:"bpm_confidence"`
+		BeatsPosition []float64 `json:"beats_position"`
 	} `json:"rhythm"`
 	Tonal struct {
 		KeyKey      string  `json:"key_key"`
@@ -34,8 +52,9 @@ type rawEssentia struct {
 }
 
 // ParseEssentiaOutput reads the JSON written by streaming_extractor_music and
-// returns a normalized Result. Returns an error for malformed JSON or a
-// missing / non-positive / NaN BPM (the only truly required field).
+// returns a normalized Result, descriptors included. Returns an error for
+// malformed JSON or a missing / non-positive / NaN BPM (the only truly
+// required field).
 func ParseEssentiaOutput(raw []byte) (Result, error) {
 	var e rawEssentia
 	if err := json.Unmarshal(raw, &e); err != nil {
@@ -44,6 +63,9 @@ func ParseEssentiaOutput(raw []byte) (Result, error) {
 	if math.IsNaN(e.Rhythm.BPM) || e.Rhythm.BPM <= 0 {
 		return Result{}, errors.New("essentia output missing or non-positive bpm")
 	}
+	// Descriptors are extras: a malformed section shouldn't cost the tempo
+	// and key.
+	desc, _ := ParseEssentiaDescriptors(raw)
 	camelot := ToCamelot(e.Tonal.KeyKey, e.Tonal.KeyScale)
 	keyConf := clamp01(e.Tonal.KeyStrength)
 	if camelot == "" {
@@ -54,6 +76,8 @@ func ParseEssentiaOutput(raw []byte) (Result, error) {
 		BPMConfidence: clamp01(e.Rhythm.BPMConfidence / essentiaBPMConfidenceScale),
 		Key:           camelot,
 		KeyConfidence: keyConf,
+		Beats:         e.Rhythm.BeatsPosition,
+		Descriptors:   desc,
 	}, nil
 }
 
@@ -27,6 +27,9 @@ func TestParseEssentiaOutput_Success(t *testing.T) {
 	if got.KeyConfidence != 0.78 {
 		t.Errorf("KeyConfidence = %v, want 0.78", got.KeyConfidence)
 	}
+	if len(got.Beats) != 4 || got.Beats[0] != 0.482 {
+		t.Errorf("Beats = %v, want the 4 beats_position entries", got.Beats)
+	}
 }
 
 func TestParseEssentiaOutput_MalformedJSON(t *testing.T) {
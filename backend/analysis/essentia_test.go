@@ -39,7 +39,7 @@ func TestAnalyzer_Analyze_Success(t *testing.T) {
 	audio := filepath.Join(t.TempDir(), "fake.wav")
 	os.WriteFile(audio, []byte("fake"), 0644)
 
-	a := NewAnalyzer(30 * time.Second)
+	a := NewEssentia(30 * time.Second)
 	got, err := a.Analyze(context.Background(), audio)
 	if err != nil {
 		t.Fatalf("Analyze: %v", err)
@@ -55,7 +55,7 @@ func TestAnalyzer_Analyze_Success(t *testing.T) {
 func TestAnalyzer_Analyze_BinaryMissing(t *testing.T) {
 	t.Setenv("PATH", "/nonexistent")
 
-	a := NewAnalyzer(30 * time.Second)
+	a := NewEssentia(30 * time.Second)
 	_, err := a.Analyze(context.Background(), "/tmp/anything.wav")
 	if !errors.Is(err, ErrBinaryMissing) {
 		t.Fatalf("want ErrBinaryMissing, got %v", err)
@@ -68,7 +68,7 @@ func TestAnalyzer_Analyze_FileMissing(t *testing.T) {
 	stubDir := writeStubBinary(t, `{"rhythm":{"bpm":120,"bpm_confidence":2.0},"tonal":{"key_key":"A","key_scale":"minor","key_strength":0.5}}`)
 	withPathPrepended(t, stubDir)
 
-	a := NewAnalyzer(30 * time.Second)
+	a := NewEssentia(30 * time.Second)
 	_, err := a.Analyze(context.Background(), "/tmp/definitely-does-not-exist-xyz.wav")
 	if !errors.Is(err, ErrFileMissing) {
 		t.Fatalf("want ErrFileMissing, got %v", err)
@@ -82,7 +82,7 @@ func TestAnalyzer_Analyze_MalformedOutput(t *testing.T) {
 	audio := filepath.Join(t.TempDir(), "fake.wav")
 	os.WriteFile(audio, []byte("fake"), 0644)
 
-	a := NewAnalyzer(30 * time.Second)
+	a := NewEssentia(30 * time.Second)
 	_, err := a.Analyze(context.Background(), audio)
 	if !errors.Is(err, ErrMalformedOutput) {
 		t.Fatalf("want ErrMalformedOutput, got %v", err)
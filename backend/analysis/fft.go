@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fft is an iterative radix-2 FFT of a fixed power-of-two size, with the
+// bit-reversal permutation and twiddle factors computed once so one plan
+// can transform every frame of a track.
+type fft struct {
+	n       int
+	rev     []int
+	twiddle []complex128 // e^(-2πik/n) for k < n/2
+}
+
+func newFFT(n int) *fft {
+	if n < 2 || n&(n-1) != 0 {
+		panic("fft size must be a power of two")
+	}
+	shift := bits.UintSize - bits.TrailingZeros(uint(n))
+	f := &fft{n: n, rev: make([]int, n), twiddle: make([]complex128, n/2)}
+	for i := range f.rev {
+		f.rev[i] = int(bits.Reverse(uint(i)) >> shift)
+	}
+	for k := range f.twiddle {
+		s, c := math.Sincos(-2 * math.Pi * float64(k) / float64(n))
+		f.twiddle[k] = complex(c, s)
+	}
+	return f
+}
+
+// transform replaces x (of length n) with its discrete Fourier transform.
+func (f *fft) transform(x []complex128) {
+	for i, j := range f.rev {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= f.n; size <<= 1 {
+		half, step := size/2, f.n/size
+		for start := 0; start < f.n; start += size {
+			for k := 0; k < half; k++ {
+				t := f.twiddle[k*step] * x[start+k+half]
+				x[start+k+half] = x[start+k] - t
+				x[start+k] += t
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// Cue types generated from the beatgrid and loudness.
+const (
+	CueIntro = "intro" // first downbeat: where to start mixing in
+	CueDrop  = "drop"  // biggest phrase-aligned jump in loudness
+	CueOutro = "outro" // phrase where the track winds down: where to mix out
+)
+
+// Beatgrid and cue detection parameters.
+const (
+	beatsPerBar = 4
+	phraseBars  = 8
+	// minFitBeats is how many detected beats it takes to trust a regression
+	// over re-tracking the beats from the onset envelope.
+	minFitBeats = 16
+	// dropMinJumpDB is the smallest phrase-to-phrase loudness rise that counts
+	// as a drop.
+	dropMinJumpDB = 3.0
+	// outroLoudDB is how far below the track's loud level a bar must sit to
+	// count as winding down.
+	outroLoudDB = 3.0
+	// outroFallbackBars is the mix-out point used when the track never gets
+	// quieter: the usual 32-bar DJ outro.
+	outroFallbackBars = 32
+	// gridSearchBPM is how far either side of the analyzer's tempo the beat
+	// tracker searches; aubio and the autocorrelation estimator can be a
+	// fraction of a BPM out, which drifts by whole beats over a long track.
+	gridSearchBPM  = 1.0
+	gridSearchStep = 0.01
+)
+
+// Cue is an automatically placed cue point.
+type Cue struct {
+	Type    string  `json:"type"`
+	Seconds float64 `json:"seconds"`
+	// Bar is the 1-based bar number counted from the first downbeat.
+	Bar int `json:"bar"`
+}
+
+// Grid is a constant-tempo beatgrid with the detected beats it was fitted to
+// and the cue points derived from it.
+type Grid struct {
+	BPM float64 `json:"bpm"`
+	// Interval is the beat length in seconds, kept unrounded: BPM is rounded
+	// for display and would drift over a long track.
+	Interval      float64 `json:"beat_interval"`
+	FirstBeat     float64 `json:"first_beat"`
+	FirstDownbeat float64 `json:"first_downbeat"`
+	BeatCount     int     `json:"beat_count"`
+	// Beats are the detected beat positions in seconds. When the analyzer
+	// gave none they are the grid itself and BeatsDetected is false.
+	Beats         []float64 `json:"beats"`
+	BeatsDetected bool      `json:"beats_detected"`
+	Cues          []Cue     `json:"cues"`
+}
+
+// GridBeats expands a grid into count beat times.
+func GridBeats(first, interval float64, count int) []float64 {
+	beats := make([]float64, count)
+	for i := range beats {
+		beats[i] = first + float64(i)*interval
+	}
+	return beats
+}
+
+// fitGrid fits a constant tempo to detected beat positions. Beats are indexed
+// against the median spacing rather than by position in the slice, so a
+// breakdown where the tracker lost the beat doesn't shift the rest of the
+// grid. Returns the time of beat 0 and the interval.
+func fitGrid(beats []float64) (first, interval float64) {
+	if len(beats) < 2 {
+		return 0, 0
+	}
+	gaps := make([]float64, 0, len(beats)-1)
+	for i := 1; i < len(beats); i++ {
+		gaps = append(gaps, beats[i]-beats[i-1])
+	}
+	sort.Float64s(gaps)
+	period := gaps[len(gaps)/2]
+	if period <= 0 {
+		return 0, 0
+	}
+
+	// Least squares of beat time against beat number.
+	var n, sx, sy, sxx, sxy float64
+	for _, b := range beats {
+		x := math.Round((b - beats[0]) / period)
+		n++
+		sx += x
+		sy += b
+		sxx += x * x
+		sxy += x * b
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return beats[0], period
+	}
+	interval = (n*sxy - sx*sy) / den
+	first = (sy - interval*sx) / n
+	// Pull the grid back to the earliest beat at or after 0.
+	for first-interval >= 0 {
+		first -= interval
+	}
+	for first < 0 {
+		first += interval
+	}
+	return first, interval
+}
+
+// trackGrid places a constant-tempo grid on an onset envelope, searching
+// tempos near bpm and every phase for the grid that lands on the most onset
+// energy. Returns the time of the first beat and the interval.
+func trackGrid(onsets []float64, frameRate, bpm float64) (first, interval float64) {
+	if bpm <= 0 || len(onsets) == 0 {
+		return 0, 0
+	}
+	at := func(pos float64) float64 {
+		i := int(pos)
+		if i+1 >= len(onsets) {
+			return 0
+		}
+		f := pos - float64(i)
+		return onsets[i]*(1-f) + onsets[i+1]*f
+	}
+
+	bestScore, bestBPM, bestPhase := -1.0, bpm, 0.0
+	for cand := bpm - gridSearchBPM; cand <= bpm+gridSearchBPM+1e-9; cand += gridSearchStep {
+		period := 60 * frameRate / cand
+		for phase := 0.0; phase < period; phase += 0.5 {
+			score := 0.0
+			for pos := phase; pos < float64(len(onsets)-1); pos += period {
+				score += at(pos)
+			}
+			if score > bestScore {
+				bestScore, bestBPM, bestPhase = score, cand, phase
+			}
+		}
+	}
+	return bestPhase / frameRate, 60 / bestBPM
+}
+
+// findDownbeat picks which of the first four beats starts a bar. Kicks tend
+// to hit hardest on the one, and arrangements change on bar lines, so each
+// phase is scored by its mean onset strength plus its mean loudness change.
+// onsets and loudness are per beat; loudness is in dB.
+func findDownbeat(onsets, loudness []float64) int {
+	if len(onsets) < beatsPerBar*2 {
+		return 0
+	}
+	var onsetMean, changeMean float64
+	for i := range onsets {
+		onsetMean += onsets[i]
+		if i > 0 {
+			changeMean += math.Abs(loudness[i] - loudness[i-1])
+		}
+	}
+	onsetMean /= float64(len(onsets))
+	changeMean /= float64(len(onsets) - 1)
+
+	best, bestScore := 0, math.Inf(-1)
+	for p := 0; p < beatsPerBar; p++ {
+		var score float64
+		var n int
+		for i := p; i < len(onsets); i += beatsPerBar {
+			if onsetMean > 0 {
+				score += onsets[i] / onsetMean
+			}
+			if i > 0 && changeMean > 0 {
+				score += math.Abs(loudness[i]-loudness[i-1]) / changeMean
+			}
+			n++
+		}
+		if score /= float64(n); score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+// detectCues places intro, drop and outro cues on phrase boundaries from
+// per-bar loudness (dB, bar 0 starting at the first downbeat). barTime maps
+// a bar index to seconds.
+func detectCues(bars []float64, barTime func(bar int) float64) []Cue {
+	if len(bars) == 0 {
+		return nil
+	}
+	cues := []Cue{{Type: CueIntro, Seconds: barTime(0), Bar: 1}}
+
+	mean := func(from, to int) float64 {
+		var s float64
+		for _, b := range bars[from:to] {
+			s += b
+		}
+		return s / float64(to-from)
+	}
+
+	// Drop: the phrase boundary with the biggest rise over the phrase before.
+	drop, dropJump := -1, dropMinJumpDB
+	for j := phraseBars; j+phraseBars <= len(bars); j += phraseBars {
+		if jump := mean(j, j+phraseBars) - mean(j-phraseBars, j); jump >= dropJump {
+			drop, dropJump = j, jump
+		}
+	}
+	if drop >= 0 {
+		cues = append(cues, Cue{Type: CueDrop, Seconds: barTime(drop), Bar: drop + 1})
+	}
+
+	// Outro: the phrase after the last loud bar, where the track winds down.
+	sorted := append([]float64(nil), bars...)
+	sort.Float64s(sorted)
+	loud := sorted[len(sorted)*3/4]
+	last := len(bars) - 1
+	for last > 0 && bars[last] < loud-outroLoudDB {
+		last--
+	}
+	outro := (last/phraseBars + 1) * phraseBars
+	if outro >= len(bars)-beatsPerBar {
+		// Loud to the end: fall back to a standard-length outro.
+		outro = (len(bars) - outroFallbackBars) / phraseBars * phraseBars
+	}
+	if outro > len(bars)/2 && outro > drop {
+		cues = append(cues, Cue{Type: CueOutro, Seconds: barTime(outro), Bar: outro + 1})
+	}
+	return cues
+}
+
+// buildGrid assembles a Grid from a detected beat list (or none), the onset
+// and energy envelopes at frameRate, and the analyzer's tempo.
+func buildGrid(detected []float64, bpm float64, onsets, energy []float64, frameRate float64) *Grid {
+	duration := float64(len(energy)) / frameRate
+	var first, interval float64
+	if len(detected) >= minFitBeats {
+		first, interval = fitGrid(detected)
+	} else {
+		first, interval = trackGrid(onsets, frameRate, bpm)
+	}
+	if interval <= 0 || duration <= first {
+		return nil
+	}
+	count := int((duration-first)/interval) + 1
+	grid := GridBeats(first, interval, count)
+	beats, detectedOK := detected, len(detected) >= minFitBeats
+	if !detectedOK {
+		beats = grid
+	}
+
+	// Per-beat onset strength and loudness over the grid.
+	frame := func(t float64) int {
+		i := int(t * frameRate)
+		if i < 0 {
+			return 0
+		}
+		if i > len(energy) {
+			return len(energy)
+		}
+		return i
+	}
+	beatOnset := make([]float64, count)
+	beatEnergy := make([]float64, count)
+	for i, t := range grid {
+		from, to := frame(t), frame(t+interval)
+		if to <= from {
+			continue
+		}
+		// Onsets are smeared a frame or two around the beat.
+		for k := from; k < from+3 && k < to; k++ {
+			beatOnset[i] = math.Max(beatOnset[i], onsets[k])
+		}
+		for _, e := range energy[from:to] {
+			beatEnergy[i] += e
+		}
+		beatEnergy[i] /= float64(to - from)
+	}
+	toDB := func(e float64) float64 { return 10 * math.Log10(e+1e-10) }
+	beatLoud := make([]float64, count)
+	for i, e := range beatEnergy {
+		beatLoud[i] = toDB(e)
+	}
+
+	down := findDownbeat(beatOnset, beatLoud)
+	var bars []float64
+	for b := down; b+beatsPerBar <= count; b += beatsPerBar {
+		var e float64
+		for _, v := range beatEnergy[b : b+beatsPerBar] {
+			e += v
+		}
+		bars = append(bars, toDB(e/beatsPerBar))
+	}
+	downbeat := grid[down]
+	barTime := func(bar int) float64 { return round3(downbeat + float64(bar*beatsPerBar)*interval) }
+
+	return &Grid{
+		BPM:           math.Round(6000/interval) / 100,
+		Interval:      interval,
+		FirstBeat:     round3(first),
+		FirstDownbeat: round3(downbeat),
+		BeatCount:     len(beats),
+		Beats:         beats,
+		BeatsDetected: detectedOK,
+		Cues:          detectCues(bars, barTime),
+	}
+}
+
+func round3(x float64) float64 { return math.Round(x*1000) / 1000 }
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// beatCodecVersion prefixes every encoded beat list so the format can change
+// without a migration.
+const beatCodecVersion = 1
+
+// EncodeBeats packs detected beat positions against their grid. Each beat is
+// stored as its step in grid beats from the previous one (almost always 1)
+// and its offset from the grid in whole milliseconds (usually within a few),
+// both as varints, then deflated. An hour-long mix's ~8000 beats come to a
+// few kilobytes instead of the ~150KB a JSON array takes.
+func EncodeBeats(beats []float64, first, interval float64) ([]byte, error) {
+	if interval <= 0 {
+		return nil, errors.New("encode beats: non-positive interval")
+	}
+	var raw bytes.Buffer
+	buf := make([]byte, binary.MaxVarintLen64)
+	prev := int64(-1)
+	for _, b := range beats {
+		n := int64(math.Round((b - first) / interval))
+		if n <= prev {
+			// Two detections snapped to one grid beat; keep them ordered.
+			n = prev + 1
+		}
+		residual := int64(math.Round((b - (first + float64(n)*interval)) * 1000))
+		raw.Write(buf[:binary.PutUvarint(buf, uint64(n-prev))])
+		raw.Write(buf[:binary.PutVarint(buf, residual)])
+		prev = n
+	}
+
+	var out bytes.Buffer
+	out.WriteByte(beatCodecVersion)
+	w, err := flate.NewWriter(&out, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecodeBeats reverses EncodeBeats. Positions come back to the millisecond.
+func DecodeBeats(blob []byte, first, interval float64) ([]float64, error) {
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	if blob[0] != beatCodecVersion {
+		return nil, fmt.Errorf("decode beats: unknown version %d", blob[0])
+	}
+	r := bufio.NewReader(flate.NewReader(bytes.NewReader(blob[1:])))
+	var beats []float64
+	n := int64(-1)
+	for {
+		step, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return beats, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode beats: %w", err)
+		}
+		residual, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("decode beats: %w", err)
+		}
+		n += int64(step)
+		t := first + float64(n)*interval + float64(residual)/1000
+		beats = append(beats, math.Round(t*1000)/1000)
+	}
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The grid detector decodes at 8kHz with 80-sample hops: a 10ms envelope,
+// fine enough to place beats and cheap enough to hold for a two-hour mix.
+const (
+	gridSampleRate = 8000
+	gridHop        = 80
+	gridFrameRate  = float64(gridSampleRate) / gridHop
+)
+
+// GridDetector derives the beatgrid, downbeat and cue points for an analyzed
+// track. It decodes the whole file with ffmpeg into energy and onset
+// envelopes; beat positions come from the analyzer when it reported them
+// (essentia), otherwise they are tracked from the onsets at the analyzed BPM.
+type GridDetector struct {
+	runner
+}
+
+func NewGridDetector(timeout time.Duration, limits Limits) *GridDetector {
+	return &GridDetector{runner{timeout: timeout, limits: limits}}
+}
+
+// Detect returns the grid for audioPath given its analysis result, or nil if
+// the track is too short or too quiet to grid.
+func (d *GridDetector) Detect(ctx context.Context, audioPath string, res Result) (*Grid, error) {
+	if res.BPM <= 0 {
+		return nil, nil
+	}
+	if err := checkInput("ffmpeg", audioPath); err != nil {
+		return nil, err
+	}
+	var energy, onsets []float64
+	err := d.stream(ctx, func(r io.Reader) error {
+		var err error
+		energy, onsets, err = readEnvelopes(r, gridHop)
+		return err
+	}, "ffmpeg", "-v", "error", "-i", audioPath, "-vn", "-ac", "1",
+		"-ar", fmt.Sprint(gridSampleRate), "-f", "f32le", "pipe:1")
+	if err != nil {
+		return nil, err
+	}
+	return buildGrid(res.Beats, res.BPM, onsets, energy, gridFrameRate), nil
+}
+
+// readEnvelopes reads little-endian float32 samples and returns per-hop mean
+// energy and onset strength (the rise in log energy, half-wave rectified).
+func readEnvelopes(r io.Reader, hop int) (energy, onsets []float64, err error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var sample [4]byte
+	var sum, prev float64
+	n := 0
+	for {
+		if _, err := io.ReadFull(br, sample[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return energy, onsets, nil
+			}
+			return nil, nil, err
+		}
+		s := float64(math.Float32frombits(binary.LittleEndian.Uint32(sample[:])))
+		sum += s * s
+		if n++; n < hop {
+			continue
+		}
+		e := sum / float64(hop)
+		l := math.Log1p(1000 * e)
+		onset := 0.0
+		if d := l - prev; d > 0 && len(energy) > 0 {
+			onset = d
+		}
+		energy = append(energy, e)
+		onsets = append(onsets, onset)
+		prev, sum, n = l, 0, 0
+	}
+}
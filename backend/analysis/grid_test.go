@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func near(a, b, tol float64) bool { return math.Abs(a-b) <= tol }
+
+func TestFitGrid_IgnoresGapsAndJitter(t *testing.T) {
+	const interval, first = 60.0 / 128, 0.31
+	rng := rand.New(rand.NewSource(1))
+	var beats []float64
+	for i := 0; i < 400; i++ {
+		if i >= 120 && i < 150 {
+			continue // breakdown: the tracker lost the beat
+		}
+		beats = append(beats, first+float64(i)*interval+(rng.Float64()-0.5)*0.01)
+	}
+	gotFirst, gotInterval := fitGrid(beats)
+	if !near(gotInterval, interval, 1e-4) {
+		t.Errorf("interval = %v, want %v", gotInterval, interval)
+	}
+	if !near(gotFirst, first, 0.005) {
+		t.Errorf("first = %v, want %v", gotFirst, first)
+	}
+}
+
+func TestFitGrid_PullsFirstBeatToStart(t *testing.T) {
+	// The tracker only locked on after a few seconds.
+	beats := GridBeats(5.2, 0.5, 40)
+	first, interval := fitGrid(beats)
+	if !near(first, 0.2, 1e-9) || !near(interval, 0.5, 1e-9) {
+		t.Errorf("fitGrid = %v, %v; want 0.2, 0.5", first, interval)
+	}
+}
+
+// pulses builds an onset envelope with a unit pulse on every beat.
+func pulses(frames int, frameRate, first, interval float64) []float64 {
+	env := make([]float64, frames)
+	for t := first; int(t*frameRate) < frames; t += interval {
+		env[int(math.Round(t*frameRate))%frames] = 1
+	}
+	return env
+}
+
+func TestTrackGrid_CorrectsSlightlyWrongTempo(t *testing.T) {
+	const frameRate, bpm, first = 100.0, 126.0, 0.25
+	onsets := pulses(int(300*frameRate), frameRate, first, 60/bpm)
+	// aubio said 125.6; over five minutes that drifts by more than a beat.
+	gotFirst, gotInterval := trackGrid(onsets, frameRate, 125.6)
+	if !near(60/gotInterval, bpm, 0.02) {
+		t.Errorf("tempo = %v, want %v", 60/gotInterval, bpm)
+	}
+	if !near(gotFirst, first, 0.011) {
+		t.Errorf("first = %v, want %v", gotFirst, first)
+	}
+}
+
+func TestFindDownbeat_PrefersAccentedPhase(t *testing.T) {
+	onsets := make([]float64, 64)
+	loudness := make([]float64, 64)
+	for i := range onsets {
+		onsets[i] = 1
+		if i%4 == 2 {
+			onsets[i] = 2
+		}
+		loudness[i] = -20
+	}
+	if got := findDownbeat(onsets, loudness); got != 2 {
+		t.Errorf("downbeat = %d, want 2", got)
+	}
+}
+
+func TestDetectCues(t *testing.T) {
+	// Intro, drop at bar 32, breakdown, smaller second drop, quiet outro.
+	var bars []float64
+	for _, sec := range []struct {
+		n  int
+		db float64
+	}{{32, -20}, {32, -10}, {8, -20}, {24, -12}, {16, -25}} {
+		for i := 0; i < sec.n; i++ {
+			bars = append(bars, sec.db)
+		}
+	}
+	cues := detectCues(bars, func(bar int) float64 { return float64(bar) * 2 })
+	want := []Cue{
+		{Type: CueIntro, Seconds: 0, Bar: 1},
+		{Type: CueDrop, Seconds: 64, Bar: 33},
+		{Type: CueOutro, Seconds: 192, Bar: 97},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("cues = %+v, want %+v", cues, want)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestDetectCues_FlatTrackFallsBackTo32BarOutro(t *testing.T) {
+	bars := make([]float64, 100)
+	for i := range bars {
+		bars[i] = -10
+	}
+	cues := detectCues(bars, func(bar int) float64 { return float64(bar) })
+	if len(cues) != 2 || cues[1].Type != CueOutro || cues[1].Bar != 65 {
+		t.Errorf("cues = %+v, want intro and an outro at bar 65", cues)
+	}
+}
+
+func TestBuildGrid_FromEnvelopes(t *testing.T) {
+	const frameRate, bpm = 100.0, 120.0
+	const interval, first = 0.5, 0.12
+	frames := int(200 * frameRate) // 100 bars
+	onsets := make([]float64, frames)
+	energy := make([]float64, frames)
+	for i := range energy {
+		energy[i] = 0.001
+		// Loud from bar 48 (the downbeat is beat 1, so 0.62s + 96s).
+		if float64(i)/frameRate >= first+interval+48*4*interval {
+			energy[i] = 0.01
+		}
+	}
+	for b := 0; ; b++ {
+		f := int(math.Round((first + float64(b)*interval) * frameRate))
+		if f >= frames {
+			break
+		}
+		onsets[f] = 1
+		if b%4 == 1 {
+			onsets[f] = 3
+		}
+	}
+
+	g := buildGrid(nil, bpm, onsets, energy, frameRate)
+	if g == nil {
+		t.Fatal("buildGrid returned nil")
+	}
+	if g.BeatsDetected || g.BPM != bpm || !near(g.FirstBeat, first, 0.006) {
+		t.Errorf("grid = bpm %v first %v detected %v; want %v, %v, false", g.BPM, g.FirstBeat, g.BeatsDetected, bpm, first)
+	}
+	if !near(g.FirstDownbeat, first+interval, 0.006) {
+		t.Errorf("first downbeat = %v, want %v", g.FirstDownbeat, first+interval)
+	}
+	var drop *Cue
+	for i := range g.Cues {
+		if g.Cues[i].Type == CueDrop {
+			drop = &g.Cues[i]
+		}
+	}
+	if drop == nil || drop.Bar != 49 {
+		t.Errorf("cues = %+v, want a drop at bar 49", g.Cues)
+	}
+}
+
+func TestBeatCodec_RoundTrip(t *testing.T) {
+	const first, interval = 0.104, 60.0 / 124
+	rng := rand.New(rand.NewSource(2))
+	var beats []float64
+	for i := 0; i < 8000; i++ {
+		if i%500 == 7 {
+			continue
+		}
+		beats = append(beats, first+float64(i)*interval+float64(rng.Intn(11)-5)/1000)
+	}
+	blob, err := EncodeBeats(beats, first, interval)
+	if err != nil {
+		t.Fatalf("EncodeBeats: %v", err)
+	}
+	if len(blob) > len(beats)*2 {
+		t.Errorf("encoded %d beats into %d bytes; want under 2 per beat", len(beats), len(blob))
+	}
+	got, err := DecodeBeats(blob, first, interval)
+	if err != nil {
+		t.Fatalf("DecodeBeats: %v", err)
+	}
+	if len(got) != len(beats) {
+		t.Fatalf("decoded %d beats, want %d", len(got), len(beats))
+	}
+	for i := range beats {
+		if !near(got[i], beats[i], 0.0006) {
+			t.Fatalf("beat %d = %v, want %v", i, got[i], beats[i])
+		}
+	}
+}
+
+func TestDecodeBeats_RejectsUnknownVersion(t *testing.T) {
+	if _, err := DecodeBeats([]byte{9, 0}, 0, 0.5); err == nil {
+		t.Error("expected error for unknown codec version")
+	}
+}
+
+func TestReadEnvelopes(t *testing.T) {
+	var pcm bytes.Buffer
+	for i := 0; i < 400; i++ {
+		v := float32(0)
+		if i >= 200 {
+			v = 0.5
+		}
+		binary.Write(&pcm, binary.LittleEndian, v)
+	}
+	energy, onsets, err := readEnvelopes(&pcm, 100)
+	if err != nil {
+		t.Fatalf("readEnvelopes: %v", err)
+	}
+	if len(energy) != 4 {
+		t.Fatalf("frames = %d, want 4", len(energy))
+	}
+	if energy[1] != 0 || !near(energy[2], 0.25, 1e-9) {
+		t.Errorf("energy = %v", energy)
+	}
+	if onsets[1] != 0 || onsets[2] <= 0 || onsets[3] != 0 {
+		t.Errorf("onsets = %v, want a single rise at frame 2", onsets)
+	}
+}
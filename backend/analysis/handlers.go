@@ -0,0 +1,218 @@
+package analysis
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+	chain   *Chain
+}
+
+func NewHandlers(manager *Manager, chain *Chain) *Handlers {
+	return &Handlers{manager: manager, chain: chain}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrTrackNotFound):
+		return http.StatusNotFound, "track_not_found"
+	case errors.Is(err, ErrCrateNotFound):
+		return http.StatusNotFound, "crate_not_found"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+// BackendInfo describes one configured backend for the admin API.
+type BackendInfo struct {
+	Name        string `json:"name"`
+	Available   bool   `json:"available"`
+	ProvidesBPM bool   `json:"provides_bpm"`
+	ProvidesKey bool   `json:"provides_key"`
+}
+
+// ListBackends reports the chain in order, which tools are installed, and
+// how many tracks each backend has analyzed.
+func (h *Handlers) ListBackends(c *gin.Context) {
+	infos := make([]BackendInfo, 0, len(h.chain.Backends()))
+	for _, b := range h.chain.Backends() {
+		bpm, key := b.Provides()
+		infos = append(infos, BackendInfo{Name: b.Name(), Available: b.Available(), ProvidesBPM: bpm, ProvidesKey: key})
+	}
+	counts, err := h.manager.BackendCounts(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if counts == nil {
+		counts = []BackendCount{}
+	}
+	c.JSON(http.StatusOK, gin.H{"backends": infos, "counts": counts})
+}
+
+type requeueBackendRequest struct {
+	Backend string `json:"backend" binding:"required"`
+	Field   string `json:"field" binding:"required"`
+}
+
+func (h *Handlers) RequeueBackend(c *gin.Context) {
+	var req requeueBackendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	n, err := h.manager.RequeueBackend(c.Request.Context(), req.Field, req.Backend)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": n})
+}
+
+// Workers reports the pool size, how many tracks are being analyzed, and
+// whether the pool is paused or holding off for playback.
+func (h *Handlers) Workers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"workers": h.manager.State()})
+}
+
+func (h *Handlers) Pause(c *gin.Context) {
+	h.manager.Pause()
+	c.JSON(http.StatusOK, gin.H{"workers": h.manager.State()})
+}
+
+func (h *Handlers) Resume(c *gin.Context) {
+	h.manager.Resume()
+	c.JSON(http.StatusOK, gin.H{"workers": h.manager.State()})
+}
+
+// Status reports queue counts by analysis status and the worker pool state.
+func (h *Handlers) Status(c *gin.Context) {
+	st, err := h.manager.Status(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": st})
+}
+
+// ListFailed pages through failed tracks with their last error.
+func (h *Handlers) ListFailed(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	tracks, total, err := h.manager.Failed(c.Request.Context(), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tracks":   tracks,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_next": offset+limit < total,
+	})
+}
+
+// Quality reports audit verdict counts and pages through tracks with the
+// verdicts in ?verdict= (comma-separated; suspicious and transcoded by
+// default), most confidently flagged first.
+func (h *Handlers) Quality(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	var verdicts []string
+	for _, v := range strings.Split(c.Query("verdict"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			verdicts = append(verdicts, v)
+		}
+	}
+	report, err := h.manager.Quality(c.Request.Context(), verdicts, limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"counts":   report.Counts,
+		"tracks":   report.Tracks,
+		"total":    report.Total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_next": offset+limit < report.Total,
+	})
+}
+
+func (h *Handlers) Requeue(c *gin.Context) {
+	var req ScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	n, err := h.manager.Requeue(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": n})
+}
+
+func (h *Handlers) Reanalyze(c *gin.Context) {
+	var req ScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	n, err := h.manager.Reanalyze(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": n})
+}
+
+type backfillRequest struct {
+	All bool `json:"all"`
+}
+
+// BackfillDescriptors re-derives descriptors from stored analyzer output.
+// The body is optional; {"all": true} recomputes tracks that already have
+// descriptors too.
+func (h *Handlers) BackfillDescriptors(c *gin.Context) {
+	var req backfillRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+			return
+		}
+	}
+	n, err := h.manager.BackfillDescriptors(c.Request.Context(), req.All)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": n})
+}
@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// Harmonic moves from one Camelot key to the next, as DJs name them.
+const (
+	MoveSameKey  = "same_key" // e.g. 8A -> 8A
+	MoveUp       = "+1"       // one step clockwise, a fifth up: 8A -> 9A
+	MoveDown     = "-1"       // one step anticlockwise: 8A -> 7A
+	MoveRelative = "relative" // relative major/minor: 8A -> 8B
+	MoveBoost    = "+2"       // energy boost, a whole tone up: 8A -> 10A
+	MoveSemitone = "+7"       // energy boost, a semitone up: 8A -> 3A
+)
+
+const camelotWheel = 12
+
+// moveWeights rank moves by how smoothly they mix, 1 being seamless.
+var moveWeights = map[string]float64{
+	MoveSameKey:  1,
+	MoveUp:       0.9,
+	MoveDown:     0.9,
+	MoveRelative: 0.85,
+	MoveBoost:    0.7,
+	MoveSemitone: 0.65,
+}
+
+// MoveWeight returns how smoothly a harmonic move mixes, from 0 to 1.
+func MoveWeight(move string) float64 {
+	return moveWeights[move]
+}
+
+// wheelKey returns the Camelot key steps clockwise from num on side letter.
+func wheelKey(num, steps int, letter byte) string {
+	n := ((num-1+steps)%camelotWheel+camelotWheel)%camelotWheel + 1
+	return fmt.Sprintf("%d%c", n, letter)
+}
+
+// CompatibleKeys maps each key that mixes harmonically out of key to the
+// move it takes. Energy-boost moves (+2 and +7) are included only when
+// boost is set. Returns nil if key is not valid Camelot.
+func CompatibleKeys(key string, boost bool) map[string]string {
+	num, letter, ok := ParseCamelot(key)
+	if !ok {
+		return nil
+	}
+	other := byte('A')
+	if letter == 'A' {
+		other = 'B'
+	}
+	keys := map[string]string{
+		key:                       MoveSameKey,
+		wheelKey(num, 1, letter):  MoveUp,
+		wheelKey(num, -1, letter): MoveDown,
+		wheelKey(num, 0, other):   MoveRelative,
+	}
+	if boost {
+		keys[wheelKey(num, 2, letter)] = MoveBoost
+		keys[wheelKey(num, 7, letter)] = MoveSemitone
+	}
+	return keys
+}
+
+// TempoMatch compares a candidate's BPM with the current track's, allowing
+// the candidate to be mixed at half or double time. ratio is the multiple
+// applied to the candidate (1, 2 or 0.5) and diff the remaining difference
+// as a fraction of bpm; ok reports whether diff is within tolerance.
+func TempoMatch(bpm, candidate, tolerance float64) (ratio, diff float64, ok bool) {
+	if bpm <= 0 || candidate <= 0 {
+		return 0, 0, false
+	}
+	diff = math.Inf(1)
+	for _, r := range []float64{1, 2, 0.5} {
+		if d := math.Abs(candidate*r-bpm) / bpm; d < diff-1e-9 {
+			ratio, diff = r, d
+		}
+	}
+	return ratio, diff, diff <= tolerance
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompatibleKeys(t *testing.T) {
+	got := CompatibleKeys("8A", false)
+	want := map[string]string{"8A": MoveSameKey, "9A": MoveUp, "7A": MoveDown, "8B": MoveRelative}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompatibleKeys(8A) = %v, want %v", got, want)
+	}
+
+	// The wheel wraps, and boost adds +2 and +7.
+	got = CompatibleKeys("12B", true)
+	want = map[string]string{
+		"12B": MoveSameKey, "1B": MoveUp, "11B": MoveDown, "12A": MoveRelative,
+		"2B": MoveBoost, "7B": MoveSemitone,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompatibleKeys(12B, boost) = %v, want %v", got, want)
+	}
+
+	if CompatibleKeys("H#", false) != nil {
+		t.Error("invalid key should give nil")
+	}
+}
+
+func TestCompatibleKeys_AgreeWithToCamelot(t *testing.T) {
+	// A minor's relative major is C major; a fifth up is E minor.
+	am := ToCamelot("A", "minor")
+	keys := CompatibleKeys(am, true)
+	if keys[ToCamelot("C", "major")] != MoveRelative {
+		t.Errorf("C major from A minor = %q, want relative", keys[ToCamelot("C", "major")])
+	}
+	if keys[ToCamelot("E", "minor")] != MoveUp {
+		t.Errorf("E minor from A minor = %q, want +1", keys[ToCamelot("E", "minor")])
+	}
+	// A semitone up from A minor is Bb minor; a whole tone up is B minor.
+	if keys[ToCamelot("Bb", "minor")] != MoveSemitone || keys[ToCamelot("B", "minor")] != MoveBoost {
+		t.Errorf("boost moves from A minor: %v", keys)
+	}
+}
+
+func TestTempoMatch(t *testing.T) {
+	cases := []struct {
+		bpm, candidate float64
+		ratio          float64
+		ok             bool
+	}{
+		{128, 126, 1, true},
+		{128, 64.5, 2, true},
+		{87, 174, 0.5, true},
+		{128, 140, 1, false},
+		{128, 0, 0, false},
+	}
+	for _, tc := range cases {
+		ratio, _, ok := TempoMatch(tc.bpm, tc.candidate, 0.06)
+		if ok != tc.ok || (ok && ratio != tc.ratio) {
+			t.Errorf("TempoMatch(%v, %v) = %v, %v; want %v, %v", tc.bpm, tc.candidate, ratio, ok, tc.ratio, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const keyfinderBinary = "keyfinder-cli"
+
+// KeyFinder wraps keyfinder-cli (libKeyFinder). It only detects key, and
+// reports no confidence, so KeyConfidence is left at 0.
+type KeyFinder struct {
+	runner
+}
+
+func NewKeyFinder(timeout time.Duration) *KeyFinder {
+	return &KeyFinder{runner{timeout: timeout}}
+}
+
+func (k *KeyFinder) Name() string              { return BackendKeyFinder }
+func (k *KeyFinder) Provides() (bpm, key bool) { return false, true }
+
+func (k *KeyFinder) Available() bool { return onPath(keyfinderBinary) }
+
+func (k *KeyFinder) Analyze(ctx context.Context, audioPath string) (Result, error) {
+	if err := checkInput(keyfinderBinary, audioPath); err != nil {
+		return Result{}, err
+	}
+	out, err := k.run(ctx, keyfinderBinary, "-n", "standard", audioPath)
+	if err != nil {
+		return Result{}, err
+	}
+	camelot, err := ParseKeyFinderOutput(string(out))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrMalformedOutput, err)
+	}
+	return Result{Key: camelot, KeyBackend: BackendKeyFinder}, nil
+}
+
+// ParseKeyFinderOutput converts keyfinder-cli's standard notation ("Am",
+// "F#", "Ebm") to Camelot. Silent input yields "" with no error: the track
+// simply has no key.
+func ParseKeyFinderOutput(out string) (string, error) {
+	s := strings.TrimSpace(out)
+	if s == "" || strings.EqualFold(s, "silence") {
+		return "", nil
+	}
+	scale := "major"
+	if strings.HasSuffix(s, "m") {
+		scale = "minor"
+		s = strings.TrimSuffix(s, "m")
+	}
+	camelot := ToCamelot(s, scale)
+	if camelot == "" {
+		return "", fmt.Errorf("unrecognized keyfinder key %q", strings.TrimSpace(out))
+	}
+	return camelot, nil
+}
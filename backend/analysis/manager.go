@@ -4,24 +4,142 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Errors returned by the admin operations.
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrCrateNotFound  = errors.New("crate not found")
+)
+
 // analyzer is the narrow interface the manager needs — lets tests swap in a fake.
 type analyzer interface {
 	Analyze(ctx context.Context, audioPath string) (Result, error)
 }
 
+// qualityAuditor judges whether a track's audio is as good as its format
+// claims.
+type qualityAuditor interface {
+	Audit(ctx context.Context, audioPath string, src QualitySource) (*Quality, error)
+	Available() bool
+}
+
+// gridDetector derives a beatgrid and cues once a track's tempo is known.
+type gridDetector interface {
+	Detect(ctx context.Context, audioPath string, res Result) (*Grid, error)
+}
+
 type Manager struct {
 	repo     *Repository
 	analyzer analyzer
+	grids    gridDetector
+	quality  qualityAuditor
 	now      func() time.Time
+	resolve  func(filePath string) (string, bool)
+
+	// busy reports whether playback is active; workers hold off claiming new
+	// tracks while it returns true. paused is the admin's manual switch.
+	busy     func() bool
+	paused   atomic.Bool
+	inFlight atomic.Int32
+	workers  atomic.Int32
+
+	// analyzed is called after new analysis or descriptors land.
+	analyzed func()
 }
 
 func NewManager(repo *Repository, a analyzer) *Manager {
 	return &Manager{repo: repo, analyzer: a, now: time.Now}
 }
 
+// SetPathResolver maps the storage-relative file_path stored on tracks to a
+// path on disk. Without one the stored path is handed to the analyzer as-is.
+func (m *Manager) SetPathResolver(fn func(filePath string) (string, bool)) {
+	m.resolve = fn
+}
+
+func (m *Manager) audioPath(filePath string) string {
+	if m.resolve == nil {
+		return filePath
+	}
+	if full, ok := m.resolve(filePath); ok {
+		return full
+	}
+	return filePath
+}
+
+// SetGridDetector enables beatgrid and cue detection after each analysis.
+func (m *Manager) SetGridDetector(d gridDetector) {
+	m.grids = d
+}
+
+// SetQualityAuditor enables the spectral quality audit after each analysis,
+// and for already-analyzed tracks whenever a worker is otherwise idle.
+func (m *Manager) SetQualityAuditor(a qualityAuditor) {
+	m.quality = a
+}
+
+// SetBusyCheck installs the playback-activity check that makes workers back
+// off while streams are active.
+func (m *Manager) SetBusyCheck(fn func() bool) {
+	m.busy = fn
+}
+
+// SetAnalyzedHook installs a callback run whenever a track's analysis or
+// descriptors are written, so indexes built from them can refresh. It must
+// not block.
+func (m *Manager) SetAnalyzedHook(fn func()) {
+	m.analyzed = fn
+}
+
+func (m *Manager) notifyAnalyzed() {
+	if m.analyzed != nil {
+		m.analyzed()
+	}
+}
+
+// Pause stops workers claiming new tracks; analyses already running finish.
+func (m *Manager) Pause() { m.paused.Store(true) }
+
+// Resume undoes Pause.
+func (m *Manager) Resume() { m.paused.Store(false) }
+
+// Hold reports whether workers should wait instead of claiming, and why.
+func (m *Manager) Hold() (bool, string) {
+	if m.paused.Load() {
+		return true, "paused"
+	}
+	if m.busy != nil && m.busy() {
+		return true, "streams active"
+	}
+	return false, ""
+}
+
+// WorkerState is a snapshot of the pool for the admin API.
+type WorkerState struct {
+	Workers    int    `json:"workers"`
+	InFlight   int    `json:"in_flight"`
+	Paused     bool   `json:"paused"`
+	Held       bool   `json:"held"`
+	HeldReason string `json:"held_reason,omitempty"`
+}
+
+func (m *Manager) State() WorkerState {
+	held, reason := m.Hold()
+	return WorkerState{
+		Workers:    int(m.workers.Load()),
+		InFlight:   int(m.inFlight.Load()),
+		Paused:     m.paused.Load(),
+		Held:       held,
+		HeldReason: reason,
+	}
+}
+
 // ProcessOne claims the next pending track (if any) and analyzes it.
 // Returns (processed, err). `processed` is true iff a track was claimed;
 // err is only returned for surface-breaking conditions like ErrBinaryMissing.
@@ -34,12 +152,22 @@ func (m *Manager) ProcessOne(ctx context.Context) (bool, error) {
 	if claim == nil {
 		return false, nil
 	}
+	m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
 
-	result, analyzeErr := m.analyzer.Analyze(ctx, claim.FilePath)
+	result, analyzeErr := m.analyzer.Analyze(ctx, m.audioPath(claim.FilePath))
 	if analyzeErr != nil {
 		// Surface ErrBinaryMissing so the ticker can back off instead of
-		// marching through every track as failed.
-		if errors.Is(analyzeErr, ErrBinaryMissing) {
+		// marching through every track as failed. Shutdown isn't the track's
+		// fault either. Both hand the claim back untouched; ctx may already be
+		// done, so the release can't use it.
+		if errors.Is(analyzeErr, ErrBinaryMissing) || ctx.Err() != nil {
+			if err := m.repo.ReleaseClaim(context.Background(), claim.ID); err != nil {
+				fmt.Printf("[analysis] release claim for %s: %v\n", claim.ID, err)
+			}
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
 			return false, analyzeErr
 		}
 		if errors.Is(analyzeErr, ErrFileMissing) {
@@ -55,9 +183,290 @@ func (m *Manager) ProcessOne(ctx context.Context) (bool, error) {
 		return true, nil
 	}
 
+	result = m.normalizeTempo(ctx, claim, result)
 	if err := m.repo.MarkAnalyzed(ctx, claim.ID, result); err != nil {
 		fmt.Printf("[analysis] mark analyzed for %s: %v\n", claim.ID, err)
 		return true, nil
 	}
+	m.notifyAnalyzed()
+	m.detectGrid(ctx, claim, result)
+	if m.quality != nil {
+		if track, err := m.repo.StartAudit(ctx, claim.ID, m.now()); err != nil {
+			fmt.Printf("[analysis] start quality audit for %s: %v\n", claim.ID, err)
+		} else if track != nil {
+			m.auditQuality(ctx, track)
+		}
+	}
+	return true, nil
+}
+
+// AuditOne audits the quality of the next analyzed track that has never been
+// audited, so libraries analyzed before the audit existed catch up. Workers
+// call it when there is nothing to analyze. Returns whether a track was
+// audited; with no auditor or no ffmpeg it does nothing.
+func (m *Manager) AuditOne(ctx context.Context) (bool, error) {
+	if m.quality == nil || !m.quality.Available() {
+		return false, nil
+	}
+	track, err := m.repo.ClaimNextAudit(ctx, m.now())
+	if err != nil {
+		return false, fmt.Errorf("claim next audit: %w", err)
+	}
+	if track == nil {
+		return false, nil
+	}
+	m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	m.auditQuality(ctx, track)
 	return true, nil
 }
+
+// auditQuality runs a claimed audit and stores the verdict. Like the grid,
+// a failure doesn't touch the analysis; the track is left unchecked, except
+// when ffmpeg is missing or the server is stopping, where the claim is handed
+// back so the audit runs later.
+func (m *Manager) auditQuality(ctx context.Context, track *AuditTrack) {
+	q, err := m.quality.Audit(ctx, m.audioPath(track.FilePath), track.Source)
+	if err != nil {
+		if errors.Is(err, ErrBinaryMissing) || ctx.Err() != nil {
+			if err := m.repo.ReleaseAudit(context.Background(), track.ID); err != nil {
+				fmt.Printf("[analysis] release quality audit for %s: %v\n", track.ID, err)
+			}
+			return
+		}
+		fmt.Printf("[analysis] quality audit for %s: %v\n", track.ID, err)
+		return
+	}
+	if err := m.repo.SaveQuality(ctx, track.ID, q); err != nil {
+		fmt.Printf("[analysis] save quality for %s: %v\n", track.ID, err)
+	}
+}
+
+// normalizeTempo applies the owner's tempo ranges to the detected BPM (see
+// NormalizeTempo), rescaling the beats to match a corrected tempo. If the
+// ranges can't be loaded the built-in genre ranges still apply.
+func (m *Manager) normalizeTempo(ctx context.Context, claim *ClaimedTrack, result Result) Result {
+	if result.BPM <= 0 {
+		return result
+	}
+	ranges, err := m.repo.TempoRanges(ctx, claim.OwnerUserID)
+	if err != nil {
+		fmt.Printf("[analysis] tempo ranges for %s: %v\n", claim.OwnerUserID, err)
+	}
+	factor, suggested := NormalizeTempo(result.BPM, result.BPMConfidence, claim.Genre, ranges)
+	if factor != 1 {
+		result.RawBPM = result.BPM
+		result.BPM = round3(result.BPM * factor)
+		result.Beats = ScaleBeats(result.Beats, factor)
+	}
+	result.SuggestedBPM = suggested
+	return result
+}
+
+// detectGrid stores the track's beatgrid and cues. A failure here doesn't
+// fail the analysis: the tempo and key are already saved, and the grid is
+// redone whenever the track is reanalyzed.
+func (m *Manager) detectGrid(ctx context.Context, claim *ClaimedTrack, result Result) {
+	if m.grids == nil || result.BPM <= 0 {
+		return
+	}
+	grid, err := m.grids.Detect(ctx, m.audioPath(claim.FilePath), result)
+	if err != nil {
+		if !errors.Is(err, ErrBinaryMissing) && ctx.Err() == nil {
+			fmt.Printf("[analysis] beatgrid for %s: %v\n", claim.ID, err)
+		}
+		return
+	}
+	if grid == nil {
+		return
+	}
+	if err := m.repo.SaveGrid(ctx, claim.ID, grid); err != nil {
+		fmt.Printf("[analysis] save beatgrid for %s: %v\n", claim.ID, err)
+	}
+}
+
+// BackendCounts reports how many analyzed tracks each backend contributed to.
+func (m *Manager) BackendCounts(ctx context.Context) ([]BackendCount, error) {
+	return m.repo.CountByBackend(ctx)
+}
+
+// RequeueBackend sends tracks whose field ("bpm" or "key") came from backend
+// back through analysis, so a better tool installed later can redo them.
+func (m *Manager) RequeueBackend(ctx context.Context, field, backend string) (int64, error) {
+	if field != "bpm" && field != "key" {
+		return 0, fmt.Errorf("%w: field must be bpm or key", ErrInvalidRequest)
+	}
+	if _, err := NewBackend(backend, 0); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return m.repo.RequeueByBackend(ctx, field, backend)
+}
+
+// QueueStatus is the admin view of the analysis queue.
+type QueueStatus struct {
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+	// RetryScheduled is the part of the pending count waiting out a back-off.
+	RetryScheduled int         `json:"retry_scheduled"`
+	Workers        WorkerState `json:"workers"`
+}
+
+// Status reports track counts by analysis status alongside the pool state.
+// Every status appears, even at zero, so dashboards don't need defaults.
+func (m *Manager) Status(ctx context.Context) (*QueueStatus, error) {
+	counts, retry, err := m.repo.StatusCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st := &QueueStatus{Counts: map[string]int{}, RetryScheduled: retry, Workers: m.State()}
+	for _, s := range []string{"pending", "analyzing", "analyzed", "failed", "user_edited"} {
+		st.Counts[s] = 0
+	}
+	for s, n := range counts {
+		st.Counts[s] = n
+		st.Total += n
+	}
+	return st, nil
+}
+
+// Failed pages through tracks whose analysis failed for good.
+func (m *Manager) Failed(ctx context.Context, limit, offset int) ([]*FailedTrack, int, error) {
+	return m.repo.ListFailed(ctx, limit, offset)
+}
+
+// QualityReport is the admin view of the quality audit: counts by verdict
+// and a page of tracks with the requested verdicts.
+type QualityReport struct {
+	Counts map[string]int  `json:"counts"`
+	Tracks []*QualityTrack `json:"tracks"`
+	Total  int             `json:"total"`
+}
+
+// Quality reports audit verdict counts, every verdict appearing even at
+// zero, and pages through tracks with the given verdicts (by default the
+// flagged ones: suspicious and transcoded).
+func (m *Manager) Quality(ctx context.Context, verdicts []string, limit, offset int) (*QualityReport, error) {
+	if len(verdicts) == 0 {
+		verdicts = []string{QualitySuspicious, QualityTranscoded}
+	}
+	for _, v := range verdicts {
+		if !slices.Contains(QualityVerdicts, v) {
+			return nil, fmt.Errorf("%w: unknown verdict %q (want one of %s)", ErrInvalidRequest, v, strings.Join(QualityVerdicts, ", "))
+		}
+	}
+	counts, err := m.repo.QualityCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &QualityReport{Counts: map[string]int{"unchecked": 0}}
+	for _, v := range QualityVerdicts {
+		report.Counts[v] = 0
+	}
+	for v, n := range counts {
+		report.Counts[v] = n
+	}
+	report.Tracks, report.Total, err = m.repo.ListQuality(ctx, verdicts, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ScopeRequest picks the tracks to requeue or reanalyze: one track, every
+// track in a crate, or the whole library.
+type ScopeRequest struct {
+	TrackID    string `json:"track_id"`
+	PlaylistID string `json:"playlist_id"`
+	All        bool   `json:"all"`
+	// OverrideUserEdits (reanalyze only) also re-detects tracks whose BPM or
+	// key a user corrected, replacing their values.
+	OverrideUserEdits bool `json:"override_user_edits"`
+}
+
+func (m *Manager) scope(ctx context.Context, req *ScopeRequest) (Scope, error) {
+	set := 0
+	for _, b := range []bool{req.TrackID != "", req.PlaylistID != "", req.All} {
+		if b {
+			set++
+		}
+	}
+	if set != 1 {
+		return Scope{}, fmt.Errorf("%w: set exactly one of track_id, playlist_id or all", ErrInvalidRequest)
+	}
+	switch {
+	case req.TrackID != "":
+		ok, err := m.repo.TrackExists(ctx, req.TrackID)
+		if err != nil {
+			return Scope{}, err
+		}
+		if !ok {
+			return Scope{}, ErrTrackNotFound
+		}
+	case req.PlaylistID != "":
+		ok, err := m.repo.PlaylistExists(ctx, req.PlaylistID)
+		if err != nil {
+			return Scope{}, err
+		}
+		if !ok {
+			return Scope{}, ErrCrateNotFound
+		}
+	}
+	return Scope{TrackID: req.TrackID, PlaylistID: req.PlaylistID, All: req.All}, nil
+}
+
+// Requeue resets failed (and backed-off) tracks in scope so they are retried
+// straight away. Returns how many tracks were requeued.
+func (m *Manager) Requeue(ctx context.Context, req *ScopeRequest) (int64, error) {
+	scope, err := m.scope(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return m.repo.Requeue(ctx, scope)
+}
+
+// Reanalyze queues tracks in scope for a fresh analysis even if they already
+// have one. Returns how many tracks were queued.
+func (m *Manager) Reanalyze(ctx context.Context, req *ScopeRequest) (int64, error) {
+	scope, err := m.scope(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return m.repo.Reanalyze(ctx, scope, req.OverrideUserEdits)
+}
+
+// backfillBatch is how many stored outputs a backfill loads at a time.
+const backfillBatch = 100
+
+// BackfillDescriptors re-derives descriptors from stored analyzer output,
+// without rerunning the analyzer. By default only tracks with no
+// descriptors yet are touched; all recomputes every track with stored
+// output, for when the descriptor set or the energy formula changes.
+// Returns how many tracks were updated.
+func (m *Manager) BackfillDescriptors(ctx context.Context, all bool) (int, error) {
+	updated := 0
+	after := ""
+	for {
+		batch, err := m.repo.ListRawOutputs(ctx, after, !all, backfillBatch)
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			if updated > 0 {
+				m.notifyAnalyzed()
+			}
+			return updated, nil
+		}
+		for _, o := range batch {
+			after = o.TrackID
+			d, err := ParseEssentiaDescriptors(o.Output)
+			if err != nil || d == nil {
+				fmt.Printf("[analysis] backfill descriptors for %s: no descriptors in stored output (%v)\n", o.TrackID, err)
+				continue
+			}
+			if err := m.repo.SaveDescriptors(ctx, o.TrackID, d); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
@@ -9,13 +9,20 @@ import (
 
 // fakeAnalyzer returns canned results or errors for tests.
 type fakeAnalyzer struct {
-	result Result
-	err    error
-	calls  int
+	result   Result
+	err      error
+	calls    int
+	lastPath string
+	// onAnalyze, if set, runs inside Analyze (e.g. to simulate shutdown).
+	onAnalyze func()
 }
 
 func (f *fakeAnalyzer) Analyze(ctx context.Context, path string) (Result, error) {
 	f.calls++
+	f.lastPath = path
+	if f.onAnalyze != nil {
+		f.onAnalyze()
+	}
 	return f.result, f.err
 }
 
@@ -127,3 +134,296 @@ func TestManager_ProcessOne_BinaryMissingReturnsError(t *testing.T) {
 		t.Errorf("status=%q retry=%d, want untouched", status, retryCount)
 	}
 }
+
+func TestManager_ProcessOne_ResolvesStoragePath(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "tracks/u1/a.wav")
+	repo := NewRepository(db)
+	fa := &fakeAnalyzer{result: Result{BPM: 128}}
+	m := NewManager(repo, fa)
+	m.SetPathResolver(func(p string) (string, bool) { return "/data/" + p, true })
+
+	if _, err := m.ProcessOne(context.Background()); err != nil {
+		t.Fatalf("ProcessOne: %v", err)
+	}
+	if fa.lastPath != "/data/tracks/u1/a.wav" {
+		t.Errorf("analyzer got %q, want resolved path", fa.lastPath)
+	}
+}
+
+func TestManager_Hold(t *testing.T) {
+	m := NewManager(NewRepository(newTestDB(t)), &fakeAnalyzer{})
+	if held, _ := m.Hold(); held {
+		t.Fatal("new manager should not be held")
+	}
+
+	streaming := true
+	m.SetBusyCheck(func() bool { return streaming })
+	if held, reason := m.Hold(); !held || reason != "streams active" {
+		t.Errorf("Hold() = %v, %q; want held for streams", held, reason)
+	}
+	streaming = false
+
+	m.Pause()
+	if held, reason := m.Hold(); !held || reason != "paused" {
+		t.Errorf("Hold() = %v, %q; want paused", held, reason)
+	}
+	m.Resume()
+	if held, _ := m.Hold(); held {
+		t.Error("still held after Resume")
+	}
+}
+
+func TestManager_ProcessOne_ShutdownReleasesClaim(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	ctx, cancel := context.WithCancel(context.Background())
+	fa := &fakeAnalyzer{err: context.Canceled, onAnalyze: cancel}
+	m := NewManager(NewRepository(db), fa)
+
+	_, err := m.ProcessOne(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	var status string
+	var retryCount int
+	_ = db.QueryRow(`SELECT analysis_status, analysis_retry_count FROM tracks WHERE id='t1'`).Scan(&status, &retryCount)
+	if status != "pending" || retryCount != 0 {
+		t.Errorf("status=%q retry=%d, want pending with no retry burned", status, retryCount)
+	}
+}
+
+func TestManager_RequeueScopeValidation(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	m := NewManager(NewRepository(db), &fakeAnalyzer{})
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		req  ScopeRequest
+		want error
+	}{
+		{"nothing set", ScopeRequest{}, ErrInvalidRequest},
+		{"two scopes", ScopeRequest{TrackID: "t1", All: true}, ErrInvalidRequest},
+		{"unknown track", ScopeRequest{TrackID: "nope"}, ErrTrackNotFound},
+		{"unknown crate", ScopeRequest{PlaylistID: "nope"}, ErrCrateNotFound},
+		{"known track", ScopeRequest{TrackID: "t1"}, nil},
+	}
+	for _, tc := range cases {
+		_, err := m.Reanalyze(ctx, &tc.req)
+		if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestManager_StatusFillsEveryStatus(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	m := NewManager(NewRepository(db), &fakeAnalyzer{})
+
+	st, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if st.Total != 1 || st.Counts["pending"] != 1 || len(st.Counts) != 5 {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+// fakeGrids returns a canned grid and records what it was given.
+type fakeGrids struct {
+	grid  *Grid
+	err   error
+	calls int
+	got   Result
+}
+
+func (f *fakeGrids) Detect(ctx context.Context, path string, res Result) (*Grid, error) {
+	f.calls++
+	f.got = res
+	return f.grid, f.err
+}
+
+func TestManager_ProcessOne_SavesGrid(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	beats := []float64{0.5, 1.001, 1.5, 2.0}
+	fa := &fakeAnalyzer{result: Result{BPM: 120, Key: "8A", Beats: beats}}
+	fg := &fakeGrids{grid: &Grid{
+		BPM: 120, Interval: 0.5, FirstBeat: 0.5, FirstDownbeat: 1.0, BeatCount: 4,
+		Beats: beats, BeatsDetected: true,
+		Cues: []Cue{{Type: CueIntro, Seconds: 1.0, Bar: 1}},
+	}}
+	m := NewManager(NewRepository(db), fa)
+	m.SetGridDetector(fg)
+
+	if _, err := m.ProcessOne(context.Background()); err != nil {
+		t.Fatalf("ProcessOne: %v", err)
+	}
+	if fg.calls != 1 || len(fg.got.Beats) != 4 {
+		t.Fatalf("detector calls=%d beats=%v, want one call with the analyzer's beats", fg.calls, fg.got.Beats)
+	}
+	var downbeat float64
+	var blob []byte
+	var cues string
+	err := db.QueryRow(`SELECT first_downbeat, beats, cues FROM track_grids WHERE track_id='t1'`).Scan(&downbeat, &blob, &cues)
+	if err != nil {
+		t.Fatalf("read grid: %v", err)
+	}
+	if downbeat != 1.0 || cues != `[{"type":"intro","seconds":1,"bar":1}]` {
+		t.Errorf("downbeat=%v cues=%s", downbeat, cues)
+	}
+	decoded, err := DecodeBeats(blob, 0.5, 0.5)
+	if err != nil || len(decoded) != 4 || decoded[1] != 1.001 {
+		t.Errorf("beats = %v (%v), want the detected beats back", decoded, err)
+	}
+
+	// Reanalysis replaces the grid in place.
+	fg.grid = &Grid{BPM: 121, Interval: 60.0 / 121, BeatCount: 10}
+	if err := m.repo.SaveGrid(context.Background(), "t1", fg.grid); err != nil {
+		t.Fatalf("SaveGrid: %v", err)
+	}
+	var n int
+	var bpm float64
+	_ = db.QueryRow(`SELECT COUNT(*), MAX(bpm) FROM track_grids`).Scan(&n, &bpm)
+	if n != 1 || bpm != 121 {
+		t.Errorf("grids=%d bpm=%v, want the one row updated to 121", n, bpm)
+	}
+}
+
+func TestManager_ProcessOne_GridFailureKeepsAnalysis(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	m := NewManager(NewRepository(db), &fakeAnalyzer{result: Result{BPM: 120}})
+	m.SetGridDetector(&fakeGrids{err: ErrTimeout})
+
+	processed, err := m.ProcessOne(context.Background())
+	if err != nil || !processed {
+		t.Fatalf("ProcessOne = %v, %v", processed, err)
+	}
+	if got := statusOf(t, db, "t1"); got != "analyzed" {
+		t.Errorf("status = %q, want analyzed", got)
+	}
+}
+
+func TestManager_ProcessOne_CorrectsHalfTime(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	seedPending(t, db, "t2", "/b.wav")
+	_, _ = db.Exec(`UPDATE tracks SET genre = 'Drum & Bass', created_at = datetime('now', '-1 minute') WHERE id = 't1'`)
+	_, _ = db.Exec(`INSERT INTO user_tempo_ranges (user_id, genre, min_bpm, max_bpm) VALUES ('u1', '', 140, 180)`)
+	repo := NewRepository(db)
+	fa := &fakeAnalyzer{result: Result{BPM: 87, BPMConfidence: 0.9, Beats: []float64{1, 1.69}}}
+	m := NewManager(repo, fa)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.ProcessOne(ctx); err != nil {
+			t.Fatalf("ProcessOne: %v", err)
+		}
+	}
+
+	read := func(id string) (bpm, raw float64, suggested sql.NullFloat64) {
+		t.Helper()
+		err := db.QueryRow(`SELECT bpm, bpm_raw, bpm_suggested FROM tracks WHERE id = ?`, id).Scan(&bpm, &raw, &suggested)
+		if err != nil {
+			t.Fatalf("read %s: %v", id, err)
+		}
+		return
+	}
+	// The genre range corrects outright.
+	if bpm, raw, suggested := read("t1"); bpm != 174 || raw != 87 || suggested.Valid {
+		t.Errorf("t1: bpm = %v, raw = %v, suggested = %v; want 174, 87, NULL", bpm, raw, suggested)
+	}
+	// A confident reading outside the catch-all range is only flagged.
+	if bpm, raw, suggested := read("t2"); bpm != 87 || raw != 87 || suggested.Float64 != 174 {
+		t.Errorf("t2: bpm = %v, raw = %v, suggested = %v; want 87, 87, 174", bpm, raw, suggested)
+	}
+}
+
+type fakeAuditor struct {
+	quality *Quality
+	err     error
+	missing bool
+	got     []QualitySource
+}
+
+func (f *fakeAuditor) Audit(ctx context.Context, path string, src QualitySource) (*Quality, error) {
+	f.got = append(f.got, src)
+	return f.quality, f.err
+}
+
+func (f *fakeAuditor) Available() bool { return !f.missing }
+
+func TestManager_QualityAudit(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "new", "/a.flac")
+	_, err := db.Exec(`INSERT INTO tracks (id, analysis_status, content_type, original_filename, sample_rate, bitrate, created_at)
+        VALUES ('old', 'analyzed', 'audio/mpeg', 'old.mp3', 44100, 320, '2020-01-01')`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	fq := &fakeAuditor{quality: &Quality{Verdict: QualityTranscoded, Confidence: 0.9, CutoffHz: 16000}}
+	m := NewManager(NewRepository(db), &fakeAnalyzer{result: Result{BPM: 120}})
+	m.SetQualityAuditor(fq)
+	ctx := context.Background()
+
+	// A fresh analysis is audited straight after.
+	if _, err := m.ProcessOne(ctx); err != nil {
+		t.Fatalf("ProcessOne: %v", err)
+	}
+	if len(fq.got) != 1 {
+		t.Fatalf("audits = %d, want 1", len(fq.got))
+	}
+
+	// Idle workers catch up on tracks analyzed before the audit existed,
+	// and stop once every track has been audited.
+	if ok, err := m.AuditOne(ctx); !ok || err != nil {
+		t.Fatalf("AuditOne = %v, %v", ok, err)
+	}
+	if src := fq.got[1]; src.ContentType != "audio/mpeg" || src.Bitrate != 320 || src.SampleRate != 44100 {
+		t.Errorf("source = %+v, want the track's format", src)
+	}
+	if ok, _ := m.AuditOne(ctx); ok {
+		t.Error("AuditOne found work with every track audited")
+	}
+
+	report, err := m.Quality(ctx, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("Quality: %v", err)
+	}
+	if report.Total != 2 || report.Counts[QualityTranscoded] != 2 || report.Counts[QualityLossless] != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if _, err := m.Quality(ctx, []string{"great"}, 10, 0); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unknown verdict err = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestManager_QualityAudit_MissingFfmpegReleasesClaim(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec(`INSERT INTO tracks (id, analysis_status) VALUES ('t1', 'analyzed')`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	fq := &fakeAuditor{missing: true}
+	m := NewManager(NewRepository(db), &fakeAnalyzer{})
+	m.SetQualityAuditor(fq)
+	ctx := context.Background()
+
+	if ok, _ := m.AuditOne(ctx); ok || len(fq.got) != 0 {
+		t.Fatal("AuditOne audited without ffmpeg")
+	}
+	// ffmpeg vanishing mid-audit hands the track back for later.
+	fq.missing, fq.err = false, ErrBinaryMissing
+	if _, err := m.AuditOne(ctx); err != nil {
+		t.Fatalf("AuditOne: %v", err)
+	}
+	var checked sql.NullString
+	_ = db.QueryRow(`SELECT quality_checked_at FROM tracks WHERE id = 't1'`).Scan(&checked)
+	if checked.Valid {
+		t.Errorf("quality_checked_at = %v, want the claim released", checked.String)
+	}
+}
@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyNotation is how keys are written for a user. Keys are always stored
+// and compared as Camelot; the other notations exist only at the edges.
+type KeyNotation string
+
+const (
+	NotationCamelot  KeyNotation = "camelot"  // 8A, 8B
+	NotationOpenKey  KeyNotation = "open_key" // 1m, 1d (Traktor)
+	NotationStandard KeyNotation = "standard" // Am, C
+)
+
+// KeyNotations lists the supported notations, default first.
+var KeyNotations = []KeyNotation{NotationCamelot, NotationOpenKey, NotationStandard}
+
+// ParseKeyNotation validates a notation name.
+func ParseKeyNotation(s string) (KeyNotation, bool) {
+	for _, n := range KeyNotations {
+		if string(n) == s {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// standardMinor and standardMajor spell each Camelot key in standard
+// notation, indexed by wheel number - 1. Spellings follow the usual
+// DJ-software convention, so some keys use sharps and others flats.
+var (
+	standardMinor = [camelotWheel]string{"G#m", "Ebm", "Bbm", "Fm", "Cm", "Gm", "Dm", "Am", "Em", "Bm", "F#m", "C#m"}
+	standardMajor = [camelotWheel]string{"B", "F#", "Db", "Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E"}
+)
+
+// openKeyOffset is how far Open Key's numbering is turned from Camelot's:
+// Open Key 1 is Camelot 8 (C major / A minor).
+const openKeyOffset = 7
+
+// FormatKey writes a Camelot key in notation n. Anything that is not valid
+// Camelot is returned unchanged.
+func FormatKey(camelot string, n KeyNotation) string {
+	num, letter, ok := ParseCamelot(camelot)
+	if !ok {
+		return camelot
+	}
+	switch n {
+	case NotationOpenKey:
+		open := (num-1-openKeyOffset+camelotWheel)%camelotWheel + 1
+		if letter == 'A' {
+			return fmt.Sprintf("%dm", open)
+		}
+		return fmt.Sprintf("%dd", open)
+	case NotationStandard:
+		if letter == 'A' {
+			return standardMinor[num-1]
+		}
+		return standardMajor[num-1]
+	default:
+		return camelot
+	}
+}
+
+// ParseKey reads a key written in any supported notation and returns it as
+// Camelot: "8a", "8A", "1m", "Am", "A minor", "F#", "Gb maj" and "B♭m" are
+// all understood, in any case except a flat's "b". ok is false for
+// anything else.
+func ParseKey(s string) (camelot string, ok bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", false
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return parseNumberedKey(s)
+	}
+	return parseStandardKey(s)
+}
+
+// parseNumberedKey reads Camelot ("8A") or Open Key ("1m", "1d").
+func parseNumberedKey(s string) (string, bool) {
+	upper := strings.ToUpper(s)
+	if num, letter, ok := ParseCamelot(upper); ok {
+		return fmt.Sprintf("%d%c", num, letter), true
+	}
+	side := upper[len(upper)-1]
+	if side != 'M' && side != 'D' {
+		return "", false
+	}
+	// ParseCamelot does the number checks; the side letter is swapped in.
+	open, _, ok := ParseCamelot(upper[:len(upper)-1] + "A")
+	if !ok {
+		return "", false
+	}
+	num := (open-1+openKeyOffset)%camelotWheel + 1
+	if side == 'M' {
+		return fmt.Sprintf("%dA", num), true
+	}
+	return fmt.Sprintf("%dB", num), true
+}
+
+// parseStandardKey reads standard notation: a note, an optional accidental
+// and an optional mode ("m", "min", "minor", "maj", "major").
+func parseStandardKey(s string) (string, bool) {
+	s = strings.NewReplacer("♯", "#", "♭", "b").Replace(s)
+	note := strings.ToUpper(s[:1])
+	if note < "A" || note > "G" {
+		return "", false
+	}
+	rest := s[1:]
+	if strings.HasPrefix(rest, "#") || strings.HasPrefix(rest, "b") {
+		note += rest[:1]
+		rest = rest[1:]
+	}
+	switch strings.ToLower(strings.TrimSpace(rest)) {
+	case "", "maj", "major":
+		return ToCamelot(note, "major"), majorToCamelot[note] != ""
+	case "m", "min", "minor":
+		return ToCamelot(note, "minor"), minorToCamelot[note] != ""
+	default:
+		return "", false
+	}
+}
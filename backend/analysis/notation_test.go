@@ -0,0 +1,65 @@
+package analysis
+
+import "testing"
+
+func TestFormatKey(t *testing.T) {
+	cases := []struct {
+		camelot          string
+		openKey, musical string
+	}{
+		{"8A", "1m", "Am"},
+		{"8B", "1d", "C"},
+		{"1A", "6m", "G#m"},
+		{"7B", "12d", "F"},
+		{"12A", "5m", "C#m"},
+		{"3B", "8d", "Db"},
+	}
+	for _, c := range cases {
+		if got := FormatKey(c.camelot, NotationOpenKey); got != c.openKey {
+			t.Errorf("FormatKey(%q, open_key) = %q, want %q", c.camelot, got, c.openKey)
+		}
+		if got := FormatKey(c.camelot, NotationStandard); got != c.musical {
+			t.Errorf("FormatKey(%q, standard) = %q, want %q", c.camelot, got, c.musical)
+		}
+		if got := FormatKey(c.camelot, NotationCamelot); got != c.camelot {
+			t.Errorf("FormatKey(%q, camelot) = %q", c.camelot, got)
+		}
+	}
+	if got := FormatKey("bogus", NotationStandard); got != "bogus" {
+		t.Errorf("FormatKey(bogus) = %q, want it unchanged", got)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	cases := map[string]string{
+		"8A": "8A", "8a": "8A", " 12b ": "12B",
+		"1m": "8A", "1d": "8B", "12D": "7B", "6m": "1A",
+		"Am": "8A", "am": "8A", "A minor": "8A", "Amin": "8A",
+		"C": "8B", "c major": "8B", "Cmaj": "8B",
+		"F#": "2B", "Gb": "2B", "F♯m": "11A", "B♭m": "3A", "bbm": "3A", "bm": "10A",
+		"Db": "3B", "C#m": "12A",
+	}
+	for in, want := range cases {
+		if got, ok := ParseKey(in); !ok || got != want {
+			t.Errorf("ParseKey(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "0A", "13B", "1C", "13m", "0d", "H", "Cb", "E#m", "Am7", "Ax"} {
+		if got, ok := ParseKey(in); ok {
+			t.Errorf("ParseKey(%q) = %q, want invalid", in, got)
+		}
+	}
+}
+
+func TestParseKey_RoundTrip(t *testing.T) {
+	for num := 1; num <= 12; num++ {
+		for _, letter := range "AB" {
+			k := wheelKey(num, 0, byte(letter))
+			for _, n := range KeyNotations {
+				if got, ok := ParseKey(FormatKey(k, n)); !ok || got != k {
+					t.Errorf("ParseKey(FormatKey(%q, %s)) = %q, %v", k, n, got, ok)
+				}
+			}
+		}
+	}
+}
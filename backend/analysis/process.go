@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Limits keeps analysis subprocesses from competing with playback. Each limit
+// is applied by wrapping the command in the matching util-linux/coreutils tool
+// (nice, ionice, prlimit), and skipped when that tool isn't on PATH, so dev
+// boxes without util-linux still analyze, just unthrottled.
+type Limits struct {
+	// Nice is the scheduling niceness, 0 (normal) to 19 (lowest).
+	Nice int
+	// IdleIO puts the process in the idle I/O class, so it only reads the
+	// disk when nothing else wants it.
+	IdleIO bool
+	// CPUSeconds caps CPU time per process; 0 is unlimited. Unlike the wall
+	// clock timeout this doesn't fire just because the box was busy.
+	CPUSeconds int
+}
+
+// cpuGraceSeconds is the gap between the soft RLIMIT_CPU (SIGXCPU) and the
+// hard one (SIGKILL), for tools that clean up on SIGXCPU.
+const cpuGraceSeconds = 5
+
+// runner runs one external tool with a wall-clock timeout and process limits.
+// Backends embed it so the chain can hand limits to all of them at once.
+type runner struct {
+	timeout time.Duration
+	limits  Limits
+}
+
+func (r *runner) setLimits(l Limits) { r.limits = l }
+
+// limiter is implemented by backends that shell out.
+type limiter interface {
+	setLimits(Limits)
+}
+
+// wrapCommand prefixes binary/args with the limit tools that are available.
+// lookPath is exec.LookPath outside tests.
+func wrapCommand(l Limits, lookPath func(string) (string, error), binary string, args ...string) (string, []string) {
+	var prefix []string
+	have := func(tool string) bool { _, err := lookPath(tool); return err == nil }
+	if l.Nice > 0 && have("nice") {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(l.Nice))
+	}
+	if l.IdleIO && have("ionice") {
+		prefix = append(prefix, "ionice", "-c", "3")
+	}
+	if l.CPUSeconds > 0 && have("prlimit") {
+		prefix = append(prefix, "prlimit", fmt.Sprintf("--cpu=%d:%d", l.CPUSeconds, l.CPUSeconds+cpuGraceSeconds), "--")
+	}
+	if len(prefix) == 0 {
+		return binary, args
+	}
+	return prefix[0], append(append(prefix[1:], binary), args...)
+}
+
+// run runs binary and returns its stdout. Timeouts and CPU-limit kills map to
+// ErrTimeout; parent-ctx cancellation is returned as-is so the manager can
+// tell shutdown from a failed analysis.
+func (r *runner) run(ctx context.Context, binary string, args ...string) ([]byte, error) {
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	name, argv := wrapCommand(r.limits, exec.LookPath, binary, args...)
+	cmd := exec.CommandContext(runCtx, name, argv...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, ErrTimeout
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if r.limits.CPUSeconds > 0 && errors.As(err, &exitErr) {
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() &&
+				(ws.Signal() == syscall.SIGXCPU || ws.Signal() == syscall.SIGKILL) {
+				return nil, fmt.Errorf("%w: %s exceeded %ds of CPU time", ErrTimeout, binary, r.limits.CPUSeconds)
+			}
+		}
+		return nil, fmt.Errorf("%s exec failed: %w (output: %s)", binary, err, stderr.String())
+	}
+	return out, nil
+}
+
+// stream runs binary and hands its stdout to read as it is produced, for
+// output too large to buffer (decoded audio). Errors map like run's.
+func (r *runner) stream(ctx context.Context, read func(io.Reader) error, binary string, args ...string) error {
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	name, argv := wrapCommand(r.limits, exec.LookPath, binary, args...)
+	cmd := exec.CommandContext(runCtx, name, argv...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s start failed: %w", binary, err)
+	}
+	readErr := read(stdout)
+	if readErr != nil {
+		// Stop the tool rather than block on a full pipe.
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return ErrTimeout
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s exec failed: %w (output: %s)", binary, waitErr, stderr.String())
+	}
+	return nil
+}
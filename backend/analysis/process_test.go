@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWrapCommand(t *testing.T) {
+	all := func(string) (string, error) { return "/usr/bin/x", nil }
+	none := func(string) (string, error) { return "", exec.ErrNotFound }
+	onlyNice := func(tool string) (string, error) {
+		if tool == "nice" {
+			return "/usr/bin/nice", nil
+		}
+		return "", exec.ErrNotFound
+	}
+
+	cases := []struct {
+		name     string
+		limits   Limits
+		lookPath func(string) (string, error)
+		wantName string
+		wantArgs []string
+	}{
+		{"all limits", Limits{Nice: 10, IdleIO: true, CPUSeconds: 300}, all,
+			"nice", []string{"-n", "10", "ionice", "-c", "3", "prlimit", "--cpu=300:305", "--", "tool", "a.wav"}},
+		{"no limits", Limits{}, all, "tool", []string{"a.wav"}},
+		{"tools missing", Limits{Nice: 10, IdleIO: true, CPUSeconds: 300}, none, "tool", []string{"a.wav"}},
+		{"partial tools", Limits{Nice: 5, IdleIO: true}, onlyNice, "nice", []string{"-n", "5", "tool", "a.wav"}},
+	}
+	for _, tc := range cases {
+		name, args := wrapCommand(tc.limits, tc.lookPath, "tool", "a.wav")
+		if name != tc.wantName || !reflect.DeepEqual(args, tc.wantArgs) {
+			t.Errorf("%s: got %s %v, want %s %v", tc.name, name, args, tc.wantName, tc.wantArgs)
+		}
+	}
+}
+
+func TestRunner_CPULimitIsTimeout(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("prlimit is Linux-only")
+	}
+	if _, err := exec.LookPath("prlimit"); err != nil {
+		t.Skip("prlimit not installed")
+	}
+	dir := t.TempDir()
+	spin := filepath.Join(dir, "spin")
+	if err := os.WriteFile(spin, []byte("#!/bin/sh\nwhile :; do :; done\n"), 0755); err != nil {
+		t.Fatalf("write stub: %v", err)
+	}
+
+	r := &runner{timeout: 30 * time.Second, limits: Limits{CPUSeconds: 1}}
+	_, err := r.run(context.Background(), spin)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("want ErrTimeout from CPU limit, got %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Quality verdicts. Lossless and lossy mean the audio is as good as its
+// format claims; suspicious and transcoded mean it is worse, e.g. a FLAC
+// made from an MP3, or a 320 kbps MP3 made from a 128 kbps one.
+const (
+	QualityLossless     = "lossless"
+	QualityLossy        = "lossy"
+	QualitySuspicious   = "suspicious"
+	QualityTranscoded   = "transcoded"
+	QualityInconclusive = "inconclusive" // too short or too quiet to judge
+)
+
+// QualityVerdicts lists every verdict, best first.
+var QualityVerdicts = []string{QualityLossless, QualityLossy, QualitySuspicious, QualityTranscoded, QualityInconclusive}
+
+// The audit decodes to mono 44.1kHz and averages 4096-point spectra: 10.8Hz
+// bins, grouped into 86Hz bands to find the cutoff and 689Hz bands to follow
+// the top of the spectrum frame by frame.
+const (
+	qualitySampleRate = 44100
+	qualityFrame      = 4096
+	qualityBandBins   = 8
+	qualityCoarseBins = 64
+	// qualityMinFrames is about two seconds of audible signal.
+	qualityMinFrames = 20
+	// qualitySilenceDB skips frames quieter than this (RMS, dBFS).
+	qualitySilenceDB = -60.0
+	// cutoffMarginDB is how far above the spectrum's floor a band must sit
+	// to count as content.
+	cutoffMarginDB = 15.0
+	// minDynamicDB is the smallest peak-to-floor range worth judging.
+	minDynamicDB = 20.0
+	// cliffBands is the span either side of the cutoff compared to measure
+	// how sharp it is: about 500Hz. An encoder's lowpass drops 30dB or more
+	// across it; a natural rolloff a few dB.
+	cliffBands = 6
+	// fullBandRatio is how close to the source's Nyquist frequency a cutoff
+	// must be to count as full bandwidth.
+	fullBandRatio = 0.95
+	// dropoutMarginDB and dropoutRatio detect the top band switching off
+	// and on from frame to frame, as MP3 encoders do when bits run short.
+	dropoutMarginDB = 10.0
+	dropoutRatio    = 0.1
+	// Confidence needed for each verdict.
+	transcodedConfidence = 0.7
+	suspiciousConfidence = 0.4
+)
+
+// Quality is the result of a quality audit.
+type Quality struct {
+	Verdict string `json:"verdict"`
+	// Confidence (0-1) is how sure the audit is that the audio is worse
+	// than its format claims.
+	Confidence float64 `json:"confidence"`
+	// CutoffHz is the highest frequency with real content.
+	CutoffHz float64 `json:"cutoff_hz"`
+}
+
+// QualitySource is what the library knows about the file being audited.
+type QualitySource struct {
+	ContentType string
+	Filename    string
+	SampleRate  int // Hz, 0 if unknown
+	Bitrate     int // bits per second, 0 if unknown
+}
+
+// Lossless reports whether the file's format is lossless.
+func (s QualitySource) Lossless() bool {
+	ct := strings.ToLower(s.ContentType)
+	for _, f := range []string{"flac", "wav", "wave", "aiff", "alac"} {
+		if strings.Contains(ct, f) {
+			return true
+		}
+	}
+	name := strings.ToLower(s.Filename)
+	for _, ext := range []string{".flac", ".wav", ".aif", ".aiff"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// QualityAuditor looks for the fingerprints lossy encoders leave in a
+// track's spectrum. It decodes the file with ffmpeg and measures it in Go.
+type QualityAuditor struct {
+	runner
+}
+
+func NewQualityAuditor(timeout time.Duration, limits Limits) *QualityAuditor {
+	return &QualityAuditor{runner{timeout: timeout, limits: limits}}
+}
+
+func (a *QualityAuditor) Available() bool { return onPath("ffmpeg") }
+
+// Audit decodes audioPath and judges its quality against what src claims.
+func (a *QualityAuditor) Audit(ctx context.Context, audioPath string, src QualitySource) (*Quality, error) {
+	if err := checkInput("ffmpeg", audioPath); err != nil {
+		return nil, err
+	}
+	var spec *spectrum
+	err := a.stream(ctx, func(r io.Reader) error {
+		var err error
+		spec, err = readSpectrum(r)
+		return err
+	}, "ffmpeg", "-v", "error", "-i", audioPath, "-vn", "-ac", "1",
+		"-ar", fmt.Sprint(qualitySampleRate), "-f", "f32le", "pipe:1")
+	if err != nil {
+		return nil, err
+	}
+	return assessQuality(spec, src), nil
+}
+
+// spectrum is a track's average power spectrum, plus the coarse spectrum of
+// each frame for the dropout check. Levels are mean power per bin, in dB.
+type spectrum struct {
+	bands  []float64   // qualityBandBins-bin bands, dB
+	frames [][]float32 // per audible frame, qualityCoarseBins-bin bands, dB
+}
+
+func (s *spectrum) bandHz() float64 {
+	return float64(qualitySampleRate) / qualityFrame * qualityBandBins
+}
+
+func powerDB(p float64) float64 {
+	return 10 * math.Log10(p+1e-20)
+}
+
+// readSpectrum reads little-endian float32 samples at qualitySampleRate and
+// averages the power spectra of its audible frames under a Hann window.
+func readSpectrum(r io.Reader) (*spectrum, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	plan := newFFT(qualityFrame)
+	window := make([]float64, qualityFrame)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(qualityFrame-1))
+	}
+	bins := qualityFrame / 2
+	sum := make([]float64, bins)
+	frame := make([]float64, qualityFrame)
+	buf := make([]complex128, qualityFrame)
+	raw := make([]byte, 4*qualityFrame)
+	spec := &spectrum{}
+
+	for {
+		if _, err := io.ReadFull(br, raw); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		var energy float64
+		for i := range frame {
+			frame[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:])))
+			energy += frame[i] * frame[i]
+		}
+		if powerDB(energy/qualityFrame) < qualitySilenceDB {
+			continue
+		}
+		for i, v := range frame {
+			buf[i] = complex(v*window[i], 0)
+		}
+		plan.transform(buf)
+
+		coarse := make([]float32, bins/qualityCoarseBins)
+		var band float64
+		for k := 0; k < bins; k++ {
+			p := real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k])
+			sum[k] += p
+			band += p
+			if (k+1)%qualityCoarseBins == 0 {
+				coarse[k/qualityCoarseBins] = float32(powerDB(band / qualityCoarseBins))
+				band = 0
+			}
+		}
+		spec.frames = append(spec.frames, coarse)
+	}
+
+	n := float64(len(spec.frames))
+	spec.bands = make([]float64, bins/qualityBandBins)
+	for b := range spec.bands {
+		var p float64
+		for k := b * qualityBandBins; k < (b+1)*qualityBandBins; k++ {
+			p += sum[k]
+		}
+		spec.bands[b] = powerDB(p / qualityBandBins / math.Max(n, 1))
+	}
+	return spec, nil
+}
+
+// assessQuality finds the spectrum's cutoff and how sharp it is, and weighs
+// them against what the file's format and bitrate should deliver.
+func assessQuality(spec *spectrum, src QualitySource) *Quality {
+	if len(spec.frames) < qualityMinFrames {
+		return &Quality{Verdict: QualityInconclusive}
+	}
+	bandHz := spec.bandHz()
+	nyquist := float64(qualitySampleRate) / 2
+	if src.SampleRate > 0 {
+		nyquist = math.Min(nyquist, float64(src.SampleRate)/2)
+	}
+
+	// The floor is the quietest band above 1kHz: after an encoder's
+	// lowpass, whatever dither or resampling noise is left up top.
+	floor, peak := math.Inf(1), math.Inf(-1)
+	for b, l := range spec.bands {
+		if float64(b)*bandHz >= 1000 {
+			floor = math.Min(floor, l)
+		}
+		peak = math.Max(peak, l)
+	}
+	if peak-floor < minDynamicDB {
+		return &Quality{Verdict: QualityInconclusive}
+	}
+	cut := 0
+	for b := len(spec.bands) - 1; b >= 0; b-- {
+		if spec.bands[b] > floor+cutoffMarginDB {
+			cut = b
+			break
+		}
+	}
+	cutoff := float64(cut+1) * bandHz
+
+	// Without a sharp edge the top of the spectrum is the music fading out,
+	// not a lowpass: the content runs to the source's Nyquist frequency.
+	sharpness := clamp01((spec.cliff(cut) - 10) / 20)
+	if cutoff >= fullBandRatio*nyquist || sharpness == 0 {
+		return finishQuality(&Quality{CutoffHz: math.Round(nyquist)}, src)
+	}
+	q := &Quality{CutoffHz: math.Round(cutoff)}
+	confidence := 0.0
+	if src.Lossless() {
+		confidence = lowpassScore(cutoff) * sharpness
+	} else if expected := expectedCutoff(src.Bitrate); expected > 0 {
+		confidence = clamp01((expected-cutoff-1000)/3000) * sharpness
+	}
+	if confidence > 0 && spec.dropouts(cut, floor) >= dropoutRatio {
+		confidence = math.Min(1, confidence+0.15)
+	}
+	q.Confidence = math.Round(confidence*100) / 100
+	return finishQuality(q, src)
+}
+
+// finishQuality sets the verdict from the confidence and the file's format.
+func finishQuality(q *Quality, src QualitySource) *Quality {
+	switch {
+	case q.Confidence >= transcodedConfidence:
+		q.Verdict = QualityTranscoded
+	case q.Confidence >= suspiciousConfidence:
+		q.Verdict = QualitySuspicious
+	case src.Lossless():
+		q.Verdict = QualityLossless
+	default:
+		q.Verdict = QualityLossy
+	}
+	return q
+}
+
+// cliff is how far the level falls across band cut, in dB.
+func (s *spectrum) cliff(cut int) float64 {
+	mean := func(from, to int) (float64, bool) {
+		from, to = max(from, 0), min(to, len(s.bands))
+		if from >= to {
+			return 0, false
+		}
+		var sum float64
+		for _, l := range s.bands[from:to] {
+			sum += l
+		}
+		return sum / float64(to-from), true
+	}
+	below, ok1 := mean(cut-cliffBands+1, cut+1)
+	above, ok2 := mean(cut+1, cut+1+cliffBands)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	return below - above
+}
+
+// dropouts is the share of frames in which the last coarse band wholly
+// below the cutoff falls to the floor.
+func (s *spectrum) dropouts(cut int, floor float64) float64 {
+	top := (cut+1)*qualityBandBins/qualityCoarseBins - 1
+	if top < 0 || len(s.frames) == 0 {
+		return 0
+	}
+	n := 0
+	for _, f := range s.frames {
+		if float64(f[top]) < floor+dropoutMarginDB {
+			n++
+		}
+	}
+	return float64(n) / float64(len(s.frames))
+}
+
+// lowpassScore rates how typical a cutoff is of a lossy encoder: 16kHz is
+// a 128kbps MP3's lowpass, 19-20kHz that of V0 and 320kbps, which some
+// mastering lowpasses match too.
+func lowpassScore(hz float64) float64 {
+	switch {
+	case hz <= 16500:
+		return 1
+	case hz <= 19000:
+		return 0.85
+	case hz <= 20500:
+		return 0.55
+	default:
+		return 0.25
+	}
+}
+
+// encoderLowpass is roughly where LAME and common AAC encoders cut off at
+// each bitrate (bits per second).
+var encoderLowpass = []struct {
+	bitrate int
+	hz      float64
+}{
+	{64000, 11000}, {96000, 15000}, {128000, 16500}, {160000, 17500},
+	{192000, 18500}, {256000, 19500}, {320000, 20000},
+}
+
+// expectedCutoff interpolates the lowpass a lossy file of this bitrate
+// should show, or returns 0 if the bitrate is unknown.
+func expectedCutoff(bitrate int) float64 {
+	if bitrate <= 0 {
+		return 0
+	}
+	if bitrate < 1000 {
+		bitrate *= 1000 // stored in kbps
+	}
+	first, last := encoderLowpass[0], encoderLowpass[len(encoderLowpass)-1]
+	if bitrate <= first.bitrate {
+		return first.hz
+	}
+	if bitrate >= last.bitrate {
+		return last.hz
+	}
+	for i := 1; i < len(encoderLowpass); i++ {
+		lo, hi := encoderLowpass[i-1], encoderLowpass[i]
+		if bitrate <= hi.bitrate {
+			t := float64(bitrate-lo.bitrate) / float64(hi.bitrate-lo.bitrate)
+			return lo.hz + t*(hi.hz-lo.hz)
+		}
+	}
+	return last.hz
+}
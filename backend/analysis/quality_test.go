@@ -0,0 +1,138 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestFFT_MatchesDFT(t *testing.T) {
+	const n = 16
+	rng := rand.New(rand.NewSource(1))
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(rng.Float64()-0.5, rng.Float64()-0.5)
+	}
+	want := make([]complex128, n)
+	for k := range want {
+		for j, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(j*k)/n))
+		}
+	}
+	newFFT(n).transform(x)
+	for k := range x {
+		if cmplx.Abs(x[k]-want[k]) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, x[k], want[k])
+		}
+	}
+}
+
+// synthAudio renders seconds of noise at qualitySampleRate whose spectrum
+// follows gain(hz), over a faint broadband floor, as f32le. Blocks line up
+// with the audit's frames, so their edges fall where its window is zero.
+func synthAudio(seconds float64, gain func(hz float64) float64) []byte {
+	rng := rand.New(rand.NewSource(7))
+	plan := newFFT(qualityFrame)
+	var out bytes.Buffer
+	blocks := int(seconds * qualitySampleRate / qualityFrame)
+	x := make([]complex128, qualityFrame)
+	for b := 0; b < blocks; b++ {
+		for k := range x {
+			x[k] = 0
+		}
+		for k := 1; k < qualityFrame/2; k++ {
+			g := gain(float64(k) * qualitySampleRate / qualityFrame)
+			v := cmplx.Rect(g, 2*math.Pi*rng.Float64())
+			// Conjugated for the inverse transform, mirrored to stay real.
+			x[k], x[qualityFrame-k] = cmplx.Conj(v), v
+		}
+		plan.transform(x)
+		for _, v := range x {
+			s := real(v)/qualityFrame*0.5 + (rng.Float64()-0.5)*2e-6
+			binary.Write(&out, binary.LittleEndian, float32(s))
+		}
+	}
+	return out.Bytes()
+}
+
+func lowpassed(cutoff float64) func(float64) float64 {
+	return func(hz float64) float64 {
+		if hz > cutoff {
+			return 0
+		}
+		return 1 / (1 + hz/2000)
+	}
+}
+
+func audit(t *testing.T, audio []byte, src QualitySource) *Quality {
+	t.Helper()
+	spec, err := readSpectrum(bytes.NewReader(audio))
+	if err != nil {
+		t.Fatalf("readSpectrum: %v", err)
+	}
+	return assessQuality(spec, src)
+}
+
+func TestAssessQuality(t *testing.T) {
+	flac := QualitySource{ContentType: "audio/flac", SampleRate: 44100}
+	mp3 := func(kbps int) QualitySource {
+		return QualitySource{ContentType: "audio/mpeg", SampleRate: 44100, Bitrate: kbps * 1000}
+	}
+	// A natural rolloff: falling 60dB from 12kHz to 22kHz, no cliff.
+	rolloff := func(hz float64) float64 {
+		g := 1 / (1 + hz/2000)
+		if hz > 12000 {
+			g *= math.Pow(10, -3*(hz-12000)/1000/10*2)
+		}
+		return g
+	}
+
+	cases := []struct {
+		name    string
+		gain    func(float64) float64
+		src     QualitySource
+		verdict string
+		cutoff  float64 // checked to within 300Hz when set
+	}{
+		{"full-band FLAC", lowpassed(22050), flac, QualityLossless, 22050},
+		{"FLAC with a natural rolloff", rolloff, flac, QualityLossless, 22050},
+		{"FLAC from a 128k MP3", lowpassed(16000), flac, QualityTranscoded, 16000},
+		{"FLAC from a 320k MP3", lowpassed(20000), flac, QualitySuspicious, 20000},
+		{"honest 128k MP3", lowpassed(16500), mp3(128), QualityLossy, 16500},
+		{"320k MP3 from a 128k one", lowpassed(16000), mp3(320), QualityTranscoded, 16000},
+		{"32kHz FLAC", lowpassed(15900), QualitySource{ContentType: "audio/flac", SampleRate: 32000}, QualityLossless, 16000},
+	}
+	for _, c := range cases {
+		q := audit(t, synthAudio(10, c.gain), c.src)
+		if q.Verdict != c.verdict {
+			t.Errorf("%s: verdict = %s (confidence %.2f, cutoff %.0f), want %s", c.name, q.Verdict, q.Confidence, q.CutoffHz, c.verdict)
+		}
+		if c.cutoff > 0 && math.Abs(q.CutoffHz-c.cutoff) > 300 {
+			t.Errorf("%s: cutoff = %.0f, want about %.0f", c.name, q.CutoffHz, c.cutoff)
+		}
+	}
+}
+
+func TestAssessQuality_Inconclusive(t *testing.T) {
+	flac := QualitySource{ContentType: "audio/flac"}
+	if q := audit(t, make([]byte, 4*qualitySampleRate*5), flac); q.Verdict != QualityInconclusive {
+		t.Errorf("silence: verdict = %s", q.Verdict)
+	}
+	if q := audit(t, synthAudio(1, lowpassed(16000)), flac); q.Verdict != QualityInconclusive {
+		t.Errorf("one second: verdict = %s", q.Verdict)
+	}
+}
+
+func TestExpectedCutoff(t *testing.T) {
+	for _, c := range []struct {
+		bitrate int
+		want    float64
+	}{{0, 0}, {32000, 11000}, {128000, 16500}, {144000, 17000}, {320, 20000}, {500000, 20000}} {
+		if got := expectedCutoff(c.bitrate); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("expectedCutoff(%d) = %v, want %v", c.bitrate, got, c.want)
+		}
+	}
+}
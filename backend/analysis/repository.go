@@ -3,6 +3,8 @@ package analysis
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -23,8 +25,10 @@ func backoffFor(retryCount int) time.Duration {
 
 // ClaimedTrack holds the minimum info needed to run analysis.
 type ClaimedTrack struct {
-	ID       string
-	FilePath string
+	ID          string
+	FilePath    string
+	OwnerUserID string
+	Genre       string // "" if untagged
 }
 
 // Repository reads/writes analysis fields on the tracks table. It accepts a
@@ -38,20 +42,30 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// ClaimNextPending returns the next track eligible for analysis, or nil if
-// none. Eligibility: analysis_status='pending' AND next_retry_at is null or
-// in the past. Sorted by upload order so backfill drains oldest first.
+// ClaimNextPending atomically claims the next track eligible for analysis by
+// flipping it to 'analyzing', or returns nil if none. Eligibility:
+// analysis_status='pending' AND next_retry_at is null or in the past, and
+// not in the trash. Sorted by upload order so backfill drains oldest first.
+// The select and update are one statement, so concurrent workers never
+// claim the same row.
 func (r *Repository) ClaimNextPending(ctx context.Context) (*ClaimedTrack, error) {
 	row := r.db.QueryRowContext(ctx, `
-        SELECT id, file_path
-        FROM tracks
-        WHERE analysis_status = 'pending'
-          AND (next_retry_at IS NULL OR next_retry_at <= datetime('now'))
-        ORDER BY created_at ASC
-        LIMIT 1
+        UPDATE tracks
+        SET analysis_status = 'analyzing'
+        WHERE id = (
+            SELECT id
+            FROM tracks
+            WHERE analysis_status = 'pending'
+              AND (next_retry_at IS NULL OR next_retry_at <= datetime('now'))
+              AND deleted_at IS NULL
+            ORDER BY created_at ASC
+            LIMIT 1
+        )
+        AND analysis_status = 'pending'
+        RETURNING id, file_path, owner_user_id, COALESCE(genre, '')
     `)
 	var t ClaimedTrack
-	err := row.Scan(&t.ID, &t.FilePath)
+	err := row.Scan(&t.ID, &t.FilePath, &t.OwnerUserID, &t.Genre)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -61,29 +75,148 @@ func (r *Repository) ClaimNextPending(ctx context.Context) (*ClaimedTrack, error
 	return &t, nil
 }
 
+// ReleaseClaim hands a claimed track back to the queue untouched, for when
+// analysis stopped for reasons that aren't the track's fault (shutdown, no
+// backend installed). Rows that moved on meanwhile are left alone.
+func (r *Repository) ReleaseClaim(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE tracks SET analysis_status = 'pending'
+        WHERE id = ? AND analysis_status = 'analyzing'
+    `, id)
+	return err
+}
+
+// RequeueInterrupted returns tracks left 'analyzing' by a previous run (crash
+// or hard stop) to 'pending'. Call once at startup, before any worker claims.
+func (r *Repository) RequeueInterrupted(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE tracks SET analysis_status = 'pending'
+        WHERE analysis_status = 'analyzing'
+    `)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // MarkAnalyzed writes a successful result and flips status to 'analyzed'.
-// If the key is empty (unknown), musical_key is set NULL but the row is still
-// considered analyzed. No-ops on terminal ('failed') or user-edited rows so a
-// late analysis completion can't overwrite a user override that landed while
-// the analyzer was still running.
+// Fields the chain couldn't detect (zero BPM, empty key) are written NULL, as
+// are their backends, but the row is still considered analyzed; likewise the
+// descriptors when no backend produced them. The raw output behind the
+// descriptors is stored (or cleared) alongside. No-ops on terminal ('failed')
+// or user-edited rows so a late analysis completion can't overwrite a user
+// override that landed while the analyzer was still running.
 func (r *Repository) MarkAnalyzed(ctx context.Context, id string, res Result) error {
-	var key interface{}
+	var bpm, bpmConf, bpmBackend, bpmRaw, bpmSuggested interface{}
+	if res.BPM > 0 {
+		bpm, bpmConf, bpmRaw = res.BPM, res.BPMConfidence, res.BPM
+		if res.BPMBackend != "" {
+			bpmBackend = res.BPMBackend
+		}
+		if res.RawBPM > 0 {
+			bpmRaw = res.RawBPM
+		}
+		if res.SuggestedBPM > 0 {
+			bpmSuggested = res.SuggestedBPM
+		}
+	}
+	var key, keyConf, keyBackend interface{}
 	if res.Key != "" {
-		key = res.Key
+		key, keyConf = res.Key, res.KeyConfidence
+		if res.KeyBackend != "" {
+			keyBackend = res.KeyBackend
+		}
 	}
-	_, err := r.db.ExecContext(ctx, `
+	var raw []byte
+	if res.Descriptors != nil && len(res.Raw) > 0 {
+		var err error
+		if raw, err = CompressRaw(res.Raw); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{bpm, bpmConf, bpmRaw, bpmSuggested, key, keyConf, bpmBackend, keyBackend}
+	args = append(args, descriptorArgs(res.Descriptors)...)
+	updated, err := tx.ExecContext(ctx, `
         UPDATE tracks
-        SET bpm = ?, bpm_confidence = ?, musical_key = ?, key_confidence = ?,
+        SET bpm = ?, bpm_confidence = ?, bpm_raw = ?, bpm_suggested = ?,
+            musical_key = ?, key_confidence = ?,
+            bpm_backend = ?, key_backend = ?,
+            `+descriptorSets+`,
             analyzed_at = datetime('now'),
             analysis_status = 'analyzed',
             analysis_error = NULL,
             next_retry_at = NULL,
             updated_at = datetime('now')
         WHERE id = ? AND analysis_status NOT IN ('user_edited', 'failed')
-    `, res.BPM, res.BPMConfidence, key, res.KeyConfidence, id)
+    `, append(args, id)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := updated.RowsAffected(); n == 0 {
+		return nil
+	}
+	if raw != nil {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO track_analysis_raw (track_id, backend, output, created_at)
+            VALUES (?, ?, ?, datetime('now'))
+            ON CONFLICT(track_id) DO UPDATE SET
+                backend = excluded.backend, output = excluded.output, created_at = excluded.created_at
+        `, id, BackendEssentia, raw)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM track_analysis_raw WHERE track_id = ?`, id)
+	}
+	if err != nil {
+		return err
+	}
+	if err := saveFeatures(ctx, tx, id, res.Descriptors); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveFeatures writes the descriptors' timbre features to track_features,
+// or removes the row when there are none.
+func saveFeatures(ctx context.Context, tx *sql.Tx, id string, d *Descriptors) error {
+	if d == nil || d.MFCC == nil {
+		_, err := tx.ExecContext(ctx, `DELETE FROM track_features WHERE track_id = ?`, id)
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO track_features (track_id, mfcc, spectral_rolloff, spectral_flux, updated_at)
+        VALUES (?, ?, ?, ?, datetime('now'))
+        ON CONFLICT(track_id) DO UPDATE SET
+            mfcc = excluded.mfcc, spectral_rolloff = excluded.spectral_rolloff,
+            spectral_flux = excluded.spectral_flux, updated_at = excluded.updated_at
+    `, id, EncodeMFCC(d.MFCC), d.SpectralRolloff, d.SpectralFlux)
 	return err
 }
 
+// descriptorSets and descriptorArgs write a Descriptors to the tracks
+// columns, all NULL for nil.
+const descriptorSets = `energy = ?, danceability = ?, dynamic_complexity = ?, loudness_lufs = ?,
+            spectral_centroid = ?, onset_rate = ?, mood = ?`
+
+func descriptorArgs(d *Descriptors) []interface{} {
+	if d == nil {
+		return make([]interface{}, 7)
+	}
+	var lufs, mood interface{}
+	if d.LoudnessLUFS != nil {
+		lufs = *d.LoudnessLUFS
+	}
+	if d.Mood != "" {
+		mood = d.Mood
+	}
+	return []interface{}{d.Energy, d.Danceability, d.DynamicComplexity, lufs, d.SpectralCentroid, d.OnsetRate, mood}
+}
+
 // RecordFailure increments retry_count and schedules the next attempt. After
 // maxRetries failures the status flips to 'failed' (terminal). Skips no-ops on
 // rows that are already terminal ('failed') or user-overridden ('user_edited')
@@ -146,3 +279,466 @@ func (r *Repository) RecordTerminalFailure(ctx context.Context, id, errMsg strin
     `, errMsg, id)
 	return err
 }
+
+// BackendCount is how many analyzed tracks got a field from one backend.
+type BackendCount struct {
+	Backend string `json:"backend"`
+	BPM     int    `json:"bpm"`
+	Key     int    `json:"key"`
+}
+
+// CountByBackend tallies analyzed tracks by the backend that produced each
+// field. Tracks analyzed before backends were recorded count under "".
+func (r *Repository) CountByBackend(ctx context.Context) ([]BackendCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT backend, SUM(is_bpm), SUM(is_key) FROM (
+            SELECT COALESCE(bpm_backend, '') AS backend, 1 AS is_bpm, 0 AS is_key
+            FROM tracks WHERE analysis_status = 'analyzed' AND bpm IS NOT NULL AND deleted_at IS NULL
+            UNION ALL
+            SELECT COALESCE(key_backend, ''), 0, 1
+            FROM tracks WHERE analysis_status = 'analyzed' AND musical_key IS NOT NULL AND deleted_at IS NULL
+        )
+        GROUP BY backend
+        ORDER BY backend
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BackendCount
+	for rows.Next() {
+		var c BackendCount
+		if err := rows.Scan(&c.Backend, &c.BPM, &c.Key); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// RequeueByBackend puts every analyzed track whose BPM (field "bpm") or key
+// (field "key") came from the given backend back to 'pending', e.g. after
+// installing essentia on a box that had been falling back to autocorr.
+// User-edited rows are left alone. Returns the number of tracks requeued.
+func (r *Repository) RequeueByBackend(ctx context.Context, field, backend string) (int64, error) {
+	column := "bpm_backend"
+	if field == "key" {
+		column = "key_backend"
+	}
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE tracks
+        SET analysis_status = 'pending',
+            analysis_retry_count = 0,
+            analysis_error = NULL,
+            next_retry_at = NULL,
+            updated_at = datetime('now')
+        WHERE analysis_status = 'analyzed' AND `+column+` = ?
+    `, backend)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Scope selects the tracks an admin requeue or reanalysis applies to. Exactly
+// one field should be set.
+type Scope struct {
+	TrackID    string
+	PlaylistID string
+	All        bool
+}
+
+// clause renders the scope as a WHERE fragment on tracks.
+func (s Scope) clause() (string, []any) {
+	switch {
+	case s.TrackID != "":
+		return "id = ?", []any{s.TrackID}
+	case s.PlaylistID != "":
+		return "id IN (SELECT track_id FROM playlist_tracks WHERE playlist_id = ?)", []any{s.PlaylistID}
+	default:
+		return "1 = 1", nil
+	}
+}
+
+// StatusCounts tallies tracks by analysis_status. retryScheduled counts the
+// pending tracks that are waiting out a back-off rather than queued to run.
+func (r *Repository) StatusCounts(ctx context.Context) (counts map[string]int, retryScheduled int, err error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT analysis_status, COUNT(*) FROM tracks WHERE deleted_at IS NULL GROUP BY analysis_status`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	counts = make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, 0, err
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	err = r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM tracks
+        WHERE analysis_status = 'pending' AND next_retry_at > datetime('now') AND deleted_at IS NULL
+    `).Scan(&retryScheduled)
+	return counts, retryScheduled, err
+}
+
+// FailedTrack is a track whose analysis gave up, with the last error.
+type FailedTrack struct {
+	ID               string    `json:"id"`
+	OwnerUserID      string    `json:"owner_user_id"`
+	Title            *string   `json:"title,omitempty"`
+	Artist           *string   `json:"artist,omitempty"`
+	OriginalFilename string    `json:"original_filename"`
+	Error            *string   `json:"analysis_error,omitempty"`
+	RetryCount       int       `json:"analysis_retry_count"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ListFailed pages through failed tracks, most recently failed first.
+func (r *Repository) ListFailed(ctx context.Context, limit, offset int) ([]*FailedTrack, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tracks WHERE analysis_status = 'failed' AND deleted_at IS NULL`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, owner_user_id, title, artist, original_filename,
+               analysis_error, analysis_retry_count, updated_at
+        FROM tracks
+        WHERE analysis_status = 'failed' AND deleted_at IS NULL
+        ORDER BY updated_at DESC, id
+        LIMIT ? OFFSET ?
+    `, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := []*FailedTrack{}
+	for rows.Next() {
+		var t FailedTrack
+		if err := rows.Scan(&t.ID, &t.OwnerUserID, &t.Title, &t.Artist, &t.OriginalFilename,
+			&t.Error, &t.RetryCount, &t.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, &t)
+	}
+	return out, total, rows.Err()
+}
+
+// TrackExists reports whether a track id exists.
+func (r *Repository) TrackExists(ctx context.Context, id string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tracks WHERE id = ?`, id).Scan(&n)
+	return n > 0, err
+}
+
+// PlaylistExists reports whether a crate id exists.
+func (r *Repository) PlaylistExists(ctx context.Context, id string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM playlists WHERE id = ?`, id).Scan(&n)
+	return n > 0, err
+}
+
+// resetToPending puts tracks in scope whose status is one of statuses back to
+// 'pending' with a clean retry budget.
+func (r *Repository) resetToPending(ctx context.Context, scope Scope, statuses []string) (int64, error) {
+	where, args := scope.clause()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	for _, s := range statuses {
+		args = append(args, s)
+	}
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE tracks
+        SET analysis_status = 'pending',
+            analysis_retry_count = 0,
+            analysis_error = NULL,
+            next_retry_at = NULL,
+            updated_at = datetime('now')
+        WHERE `+where+` AND analysis_status IN (`+placeholders+`)
+    `, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Requeue gives failed tracks in scope, and pending ones waiting out a
+// back-off, a fresh set of retries starting now. Analyzed and user-edited
+// tracks are untouched.
+func (r *Repository) Requeue(ctx context.Context, scope Scope) (int64, error) {
+	return r.resetToPending(ctx, scope, []string{"failed", "pending"})
+}
+
+// Reanalyze queues every track in scope for analysis again, including ones
+// already analyzed. With overrideUserEdits, user-edited tracks are queued too
+// and their BPM and key will be replaced by the new detection. Tracks being
+// analyzed right now are skipped.
+func (r *Repository) Reanalyze(ctx context.Context, scope Scope, overrideUserEdits bool) (int64, error) {
+	statuses := []string{"analyzed", "failed", "pending"}
+	if overrideUserEdits {
+		statuses = append(statuses, "user_edited")
+	}
+	return r.resetToPending(ctx, scope, statuses)
+}
+
+// SaveGrid stores a track's beatgrid and cues, replacing any earlier ones.
+// Detected beats are packed against the grid with EncodeBeats; a grid tracked
+// from onsets stores no beats, since they are the grid itself.
+func (r *Repository) SaveGrid(ctx context.Context, trackID string, g *Grid) error {
+	var beats []byte
+	if g.BeatsDetected {
+		var err error
+		if beats, err = EncodeBeats(g.Beats, g.FirstBeat, g.Interval); err != nil {
+			return err
+		}
+	}
+	cues := g.Cues
+	if cues == nil {
+		cues = []Cue{}
+	}
+	cuesJSON, err := json.Marshal(cues)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+        INSERT INTO track_grids (track_id, bpm, beat_interval, first_beat, first_downbeat, beat_count, beats, cues, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
+        ON CONFLICT(track_id) DO UPDATE SET
+            bpm = excluded.bpm,
+            beat_interval = excluded.beat_interval,
+            first_beat = excluded.first_beat,
+            first_downbeat = excluded.first_downbeat,
+            beat_count = excluded.beat_count,
+            beats = excluded.beats,
+            cues = excluded.cues,
+            updated_at = excluded.updated_at
+    `, trackID, g.BPM, g.Interval, g.FirstBeat, g.FirstDownbeat, g.BeatCount, beats, string(cuesJSON))
+	return err
+}
+
+// RawOutput is a track's stored analyzer output, decompressed.
+type RawOutput struct {
+	TrackID string
+	Backend string
+	Output  []byte
+}
+
+// ListRawOutputs returns up to limit stored outputs for tracks after
+// afterID, in track ID order. With missingOnly, only tracks that have no
+// descriptors or timbre features yet are listed.
+func (r *Repository) ListRawOutputs(ctx context.Context, afterID string, missingOnly bool, limit int) ([]RawOutput, error) {
+	where := ""
+	if missingOnly {
+		where = " AND (t.energy IS NULL OR NOT EXISTS (SELECT 1 FROM track_features f WHERE f.track_id = t.id))"
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT a.track_id, a.backend, a.output
+        FROM track_analysis_raw a
+        INNER JOIN tracks t ON t.id = a.track_id
+        WHERE a.track_id > ?`+where+`
+        ORDER BY a.track_id
+        LIMIT ?
+    `, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RawOutput
+	for rows.Next() {
+		var o RawOutput
+		var blob []byte
+		if err := rows.Scan(&o.TrackID, &o.Backend, &blob); err != nil {
+			return nil, err
+		}
+		// A corrupt blob comes back empty and fails to parse, so one bad row
+		// doesn't stop a backfill.
+		o.Output, _ = DecompressRaw(blob)
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// SaveDescriptors overwrites a track's descriptor columns and timbre
+// features. Unlike MarkAnalyzed it leaves analysis status, BPM and key
+// alone, so it is safe on user-edited tracks.
+func (r *Repository) SaveDescriptors(ctx context.Context, id string, d *Descriptors) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE tracks SET `+descriptorSets+` WHERE id = ?`,
+		append(descriptorArgs(d), id)...); err != nil {
+		return err
+	}
+	if err := saveFeatures(ctx, tx, id, d); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TempoRanges returns a user's preferred BPM ranges, catch-all first.
+func (r *Repository) TempoRanges(ctx context.Context, userID string) ([]TempoRange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT genre, min_bpm, max_bpm FROM user_tempo_ranges
+        WHERE user_id = ?
+        ORDER BY genre
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []TempoRange
+	for rows.Next() {
+		var tr TempoRange
+		if err := rows.Scan(&tr.Genre, &tr.MinBPM, &tr.MaxBPM); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, tr)
+	}
+	return ranges, rows.Err()
+}
+
+// AuditTrack holds what a quality audit needs to know about a track.
+type AuditTrack struct {
+	ID       string
+	FilePath string
+	Source   QualitySource
+}
+
+const auditReturning = `RETURNING id, file_path, content_type, original_filename, COALESCE(sample_rate, 0), COALESCE(bitrate, 0)`
+
+func scanAuditTrack(row *sql.Row) (*AuditTrack, error) {
+	var t AuditTrack
+	err := row.Scan(&t.ID, &t.FilePath, &t.Source.ContentType, &t.Source.Filename, &t.Source.SampleRate, &t.Source.Bitrate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// StartAudit marks a track's quality audit as started and returns it, or nil
+// if the track is gone.
+func (r *Repository) StartAudit(ctx context.Context, id string, now time.Time) (*AuditTrack, error) {
+	return scanAuditTrack(r.db.QueryRowContext(ctx, `
+        UPDATE tracks SET quality_checked_at = ? WHERE id = ?
+        `+auditReturning, now, id))
+}
+
+// ClaimNextAudit claims the oldest analyzed track never audited, for tracks
+// analyzed before audits existed, or returns nil if none. Like
+// ClaimNextPending the select and update are one statement.
+func (r *Repository) ClaimNextAudit(ctx context.Context, now time.Time) (*AuditTrack, error) {
+	return scanAuditTrack(r.db.QueryRowContext(ctx, `
+        UPDATE tracks SET quality_checked_at = ?
+        WHERE id = (
+            SELECT id FROM tracks
+            WHERE quality_checked_at IS NULL
+              AND analysis_status IN ('analyzed', 'user_edited')
+              AND deleted_at IS NULL
+            ORDER BY created_at ASC
+            LIMIT 1
+        )
+        AND quality_checked_at IS NULL
+        `+auditReturning, now))
+}
+
+// ReleaseAudit hands a claimed audit back, for when it could not run
+// (ffmpeg missing, shutdown).
+func (r *Repository) ReleaseAudit(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tracks SET quality_checked_at = NULL WHERE id = ?`, id)
+	return err
+}
+
+// SaveQuality stores an audit's result.
+func (r *Repository) SaveQuality(ctx context.Context, id string, q *Quality) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE tracks SET quality_verdict = ?, quality_confidence = ?, spectral_cutoff_hz = ?
+        WHERE id = ?
+    `, q.Verdict, q.Confidence, q.CutoffHz, id)
+	return err
+}
+
+// QualityCounts counts tracks by quality verdict. Tracks not yet audited,
+// or whose audit failed, count as "unchecked".
+func (r *Repository) QualityCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT COALESCE(quality_verdict, 'unchecked'), COUNT(*) FROM tracks WHERE deleted_at IS NULL GROUP BY 1
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var verdict string
+		var n int
+		if err := rows.Scan(&verdict, &n); err != nil {
+			return nil, err
+		}
+		counts[verdict] = n
+	}
+	return counts, rows.Err()
+}
+
+// QualityTrack is an audited track in the admin quality report.
+type QualityTrack struct {
+	ID                string  `json:"id"`
+	OwnerUserID       string  `json:"owner_user_id"`
+	Title             *string `json:"title,omitempty"`
+	Artist            *string `json:"artist,omitempty"`
+	OriginalFilename  string  `json:"original_filename"`
+	ContentType       string  `json:"content_type"`
+	SampleRate        *int    `json:"sample_rate,omitempty"`
+	Bitrate           *int    `json:"bitrate,omitempty"`
+	QualityVerdict    string  `json:"quality_verdict"`
+	QualityConfidence float64 `json:"quality_confidence"`
+	SpectralCutoffHz  float64 `json:"spectral_cutoff_hz"`
+}
+
+// ListQuality pages through tracks with one of verdicts, most confidently
+// flagged first.
+func (r *Repository) ListQuality(ctx context.Context, verdicts []string, limit, offset int) ([]*QualityTrack, int, error) {
+	in := "?" + strings.Repeat(", ?", len(verdicts)-1)
+	args := make([]any, len(verdicts))
+	for i, v := range verdicts {
+		args[i] = v
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tracks WHERE quality_verdict IN (`+in+`) AND deleted_at IS NULL`, args...).
+		Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, owner_user_id, title, artist, original_filename, content_type, sample_rate, bitrate,
+               quality_verdict, COALESCE(quality_confidence, 0), COALESCE(spectral_cutoff_hz, 0)
+        FROM tracks
+        WHERE quality_verdict IN (`+in+`) AND deleted_at IS NULL
+        ORDER BY quality_confidence DESC, spectral_cutoff_hz ASC, id
+        LIMIT ? OFFSET ?
+    `, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := []*QualityTrack{}
+	for rows.Next() {
+		var t QualityTrack
+		if err := rows.Scan(&t.ID, &t.OwnerUserID, &t.Title, &t.Artist, &t.OriginalFilename, &t.ContentType,
+			&t.SampleRate, &t.Bitrate, &t.QualityVerdict, &t.QualityConfidence, &t.SpectralCutoffHz); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, &t)
+	}
+	return out, total, rows.Err()
+}
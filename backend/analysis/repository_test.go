@@ -3,6 +3,9 @@ package analysis
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,6 +24,8 @@ func newTestDB(t *testing.T) *sql.DB {
             id TEXT PRIMARY KEY,
             owner_user_id TEXT NOT NULL DEFAULT 'u1',
             original_filename TEXT NOT NULL DEFAULT 'f.wav',
+            title TEXT,
+            artist TEXT,
             content_type TEXT NOT NULL DEFAULT 'audio/wav',
             size_bytes INTEGER NOT NULL DEFAULT 0,
             file_path TEXT NOT NULL DEFAULT '/x',
@@ -34,7 +39,60 @@ func newTestDB(t *testing.T) *sql.DB {
             analysis_status TEXT NOT NULL DEFAULT 'pending',
             analysis_error TEXT,
             analysis_retry_count INTEGER NOT NULL DEFAULT 0,
-            next_retry_at DATETIME
+            next_retry_at DATETIME,
+            bpm_backend TEXT,
+            key_backend TEXT,
+            energy INTEGER,
+            danceability REAL,
+            dynamic_complexity REAL,
+            loudness_lufs REAL,
+            spectral_centroid REAL,
+            onset_rate REAL,
+            mood TEXT,
+            bpm_raw REAL,
+            bpm_suggested REAL,
+            genre TEXT,
+            sample_rate INTEGER,
+            bitrate INTEGER,
+            quality_verdict TEXT,
+            quality_confidence REAL,
+            spectral_cutoff_hz REAL,
+            quality_checked_at DATETIME,
+            deleted_at DATETIME
+        );
+        CREATE TABLE user_tempo_ranges (
+            user_id TEXT NOT NULL,
+            genre TEXT NOT NULL DEFAULT '',
+            min_bpm REAL NOT NULL,
+            max_bpm REAL NOT NULL,
+            updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (user_id, genre)
+        );
+        CREATE TABLE track_analysis_raw (
+            track_id TEXT PRIMARY KEY,
+            backend TEXT NOT NULL,
+            output BLOB NOT NULL,
+            created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE TABLE track_features (
+            track_id TEXT PRIMARY KEY,
+            mfcc BLOB NOT NULL,
+            spectral_rolloff REAL,
+            spectral_flux REAL,
+            updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE TABLE playlists (id TEXT PRIMARY KEY);
+        CREATE TABLE playlist_tracks (playlist_id TEXT NOT NULL, track_id TEXT NOT NULL);
+        CREATE TABLE track_grids (
+            track_id TEXT PRIMARY KEY,
+            bpm REAL NOT NULL,
+            beat_interval REAL NOT NULL,
+            first_beat REAL NOT NULL,
+            first_downbeat REAL NOT NULL,
+            beat_count INTEGER NOT NULL,
+            beats BLOB,
+            cues TEXT NOT NULL DEFAULT '[]',
+            updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
         );
     `)
 	if err != nil {
@@ -97,6 +155,21 @@ func TestRepository_ClaimNextPending_SkipsFutureRetry(t *testing.T) {
 	}
 }
 
+func TestRepository_ClaimNextPending_SkipsTrashed(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	_, _ = db.Exec(`UPDATE tracks SET deleted_at = CURRENT_TIMESTAMP WHERE id = 't1'`)
+
+	repo := NewRepository(db)
+	got, err := repo.ClaimNextPending(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimNextPending: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("should skip a track in the trash, got %v", got)
+	}
+}
+
 func TestRepository_MarkAnalyzed_UpdatesFields(t *testing.T) {
 	db := newTestDB(t)
 	seedPending(t, db, "t1", "/a.wav")
@@ -333,3 +406,394 @@ func TestRepository_MarkAnalyzed_SkipsFailedRows(t *testing.T) {
 		t.Errorf("status = %q, want failed (not revived)", status)
 	}
 }
+
+func TestRepository_MarkAnalyzed_PartialResultRecordsBackends(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	repo := NewRepository(db)
+
+	err := repo.MarkAnalyzed(context.Background(), "t1", Result{Key: "8A", KeyConfidence: 0, KeyBackend: BackendKeyFinder})
+	if err != nil {
+		t.Fatalf("MarkAnalyzed: %v", err)
+	}
+
+	var status string
+	var bpm sql.NullFloat64
+	var bpmBackend, keyBackend sql.NullString
+	err = db.QueryRow(`SELECT analysis_status, bpm, bpm_backend, key_backend FROM tracks WHERE id='t1'`).
+		Scan(&status, &bpm, &bpmBackend, &keyBackend)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if status != "analyzed" {
+		t.Errorf("status = %q", status)
+	}
+	if bpm.Valid || bpmBackend.Valid {
+		t.Errorf("bpm = %v, bpm_backend = %v; want both NULL", bpm, bpmBackend)
+	}
+	if keyBackend.String != BackendKeyFinder {
+		t.Errorf("key_backend = %v, want %s", keyBackend, BackendKeyFinder)
+	}
+}
+
+func TestRepository_RequeueByBackend(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"t1", "t2", "t3"} {
+		seedPending(t, db, id, "/"+id+".wav")
+	}
+	repo := NewRepository(db)
+	ctx := context.Background()
+	_ = repo.MarkAnalyzed(ctx, "t1", Result{BPM: 128, BPMBackend: BackendAutocorr, Key: "8A", KeyBackend: BackendKeyFinder})
+	_ = repo.MarkAnalyzed(ctx, "t2", Result{BPM: 124, BPMBackend: BackendEssentia, Key: "9A", KeyBackend: BackendEssentia})
+	_ = repo.MarkAnalyzed(ctx, "t3", Result{BPM: 100, BPMBackend: BackendAutocorr})
+	if _, err := db.Exec(`UPDATE tracks SET analysis_status='user_edited' WHERE id='t3'`); err != nil {
+		t.Fatalf("seed user_edited: %v", err)
+	}
+
+	counts, err := repo.CountByBackend(ctx)
+	if err != nil {
+		t.Fatalf("CountByBackend: %v", err)
+	}
+	want := map[string]BackendCount{
+		BackendAutocorr:  {Backend: BackendAutocorr, BPM: 1},
+		BackendEssentia:  {Backend: BackendEssentia, BPM: 1, Key: 1},
+		BackendKeyFinder: {Backend: BackendKeyFinder, Key: 1},
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %+v", counts)
+	}
+	for _, c := range counts {
+		if want[c.Backend] != c {
+			t.Errorf("count %+v, want %+v", c, want[c.Backend])
+		}
+	}
+
+	n, err := repo.RequeueByBackend(ctx, "bpm", BackendAutocorr)
+	if err != nil {
+		t.Fatalf("RequeueByBackend: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("requeued %d, want 1 (user-edited row skipped)", n)
+	}
+	var status string
+	_ = db.QueryRow(`SELECT analysis_status FROM tracks WHERE id='t1'`).Scan(&status)
+	if status != "pending" {
+		t.Errorf("t1 status = %q, want pending", status)
+	}
+	_ = db.QueryRow(`SELECT analysis_status FROM tracks WHERE id='t3'`).Scan(&status)
+	if status != "user_edited" {
+		t.Errorf("t3 status = %q, want user_edited", status)
+	}
+}
+
+func TestRepository_ClaimNextPending_ClaimsAtomically(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	first, err := repo.ClaimNextPending(ctx)
+	if err != nil || first == nil {
+		t.Fatalf("first claim = %v, %v", first, err)
+	}
+	second, err := repo.ClaimNextPending(ctx)
+	if err != nil {
+		t.Fatalf("second claim: %v", err)
+	}
+	if second != nil {
+		t.Fatalf("track claimed twice: %v", second)
+	}
+
+	var status string
+	_ = db.QueryRow(`SELECT analysis_status FROM tracks WHERE id='t1'`).Scan(&status)
+	if status != "analyzing" {
+		t.Errorf("status = %q, want analyzing", status)
+	}
+
+	n, err := repo.RequeueInterrupted(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("RequeueInterrupted = %d, %v; want 1", n, err)
+	}
+	if again, _ := repo.ClaimNextPending(ctx); again == nil || again.ID != "t1" {
+		t.Errorf("interrupted track not claimable again: %v", again)
+	}
+}
+
+func TestRepository_ClaimNextPending_ConcurrentWorkers(t *testing.T) {
+	// A file-backed DB so every connection sees the same rows; :memory: is
+	// per-connection.
+	db, err := sql.Open("sqlite3", "file:"+t.TempDir()+"/claims.db?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE tracks (
+        id TEXT PRIMARY KEY, file_path TEXT NOT NULL,
+        owner_user_id TEXT NOT NULL DEFAULT 'u1', genre TEXT,
+        created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        analysis_status TEXT NOT NULL DEFAULT 'pending', next_retry_at DATETIME, deleted_at DATETIME)`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	const tracks = 50
+	for i := 0; i < tracks; i++ {
+		seedPending(t, db, fmt.Sprintf("t%02d", i), "/a.wav")
+	}
+	repo := NewRepository(db)
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c, err := repo.ClaimNextPending(context.Background())
+				if err != nil {
+					t.Errorf("claim: %v", err)
+					return
+				}
+				if c == nil {
+					return
+				}
+				mu.Lock()
+				seen[c.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != tracks {
+		t.Errorf("claimed %d distinct tracks, want %d", len(seen), tracks)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("track %s claimed %d times", id, n)
+		}
+	}
+}
+
+// seedStatuses inserts one track per status, with ids equal to the status.
+func seedStatuses(t *testing.T, db *sql.DB) {
+	t.Helper()
+	for _, st := range []string{"pending", "analyzing", "analyzed", "failed", "user_edited"} {
+		_, err := db.Exec(`INSERT INTO tracks (id, analysis_status, analysis_retry_count, analysis_error) VALUES (?, ?, 2, 'boom')`, st, st)
+		if err != nil {
+			t.Fatalf("seed %s: %v", st, err)
+		}
+	}
+}
+
+func statusOf(t *testing.T, db *sql.DB, id string) string {
+	t.Helper()
+	var st string
+	if err := db.QueryRow(`SELECT analysis_status FROM tracks WHERE id = ?`, id).Scan(&st); err != nil {
+		t.Fatalf("read %s: %v", id, err)
+	}
+	return st
+}
+
+func TestRepository_Requeue_OnlyFailedAndPending(t *testing.T) {
+	db := newTestDB(t)
+	seedStatuses(t, db)
+	repo := NewRepository(db)
+
+	n, err := repo.Requeue(context.Background(), Scope{All: true})
+	if err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("requeued %d, want 2", n)
+	}
+	want := map[string]string{"pending": "pending", "analyzing": "analyzing", "analyzed": "analyzed", "failed": "pending", "user_edited": "user_edited"}
+	for id, st := range want {
+		if got := statusOf(t, db, id); got != st {
+			t.Errorf("%s: status = %q, want %q", id, got, st)
+		}
+	}
+	var retries int
+	_ = db.QueryRow(`SELECT analysis_retry_count FROM tracks WHERE id='failed'`).Scan(&retries)
+	if retries != 0 {
+		t.Errorf("retry count = %d, want reset to 0", retries)
+	}
+}
+
+func TestRepository_Reanalyze_UserEdits(t *testing.T) {
+	db := newTestDB(t)
+	seedStatuses(t, db)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	if n, _ := repo.Reanalyze(ctx, Scope{All: true}, false); n != 3 {
+		t.Errorf("reanalyze without override queued %d, want 3", n)
+	}
+	if got := statusOf(t, db, "user_edited"); got != "user_edited" {
+		t.Errorf("user-edited track requeued without override: %q", got)
+	}
+	if n, _ := repo.Reanalyze(ctx, Scope{All: true}, true); n != 4 {
+		t.Errorf("reanalyze with override queued %d, want 4", n)
+	}
+	if got := statusOf(t, db, "user_edited"); got != "pending" {
+		t.Errorf("user-edited track status = %q, want pending with override", got)
+	}
+	if got := statusOf(t, db, "analyzing"); got != "analyzing" {
+		t.Errorf("in-flight track status = %q, want analyzing", got)
+	}
+}
+
+func TestRepository_Reanalyze_CrateScope(t *testing.T) {
+	db := newTestDB(t)
+	seedStatuses(t, db)
+	_, _ = db.Exec(`INSERT INTO playlists (id) VALUES ('c1')`)
+	_, _ = db.Exec(`INSERT INTO playlist_tracks (playlist_id, track_id) VALUES ('c1', 'analyzed')`)
+	repo := NewRepository(db)
+
+	n, err := repo.Reanalyze(context.Background(), Scope{PlaylistID: "c1"}, false)
+	if err != nil {
+		t.Fatalf("Reanalyze: %v", err)
+	}
+	if n != 1 || statusOf(t, db, "analyzed") != "pending" {
+		t.Errorf("queued %d, want only the crate's track", n)
+	}
+	if statusOf(t, db, "failed") != "failed" {
+		t.Error("track outside the crate was requeued")
+	}
+}
+
+func TestRepository_StatusCountsAndFailed(t *testing.T) {
+	db := newTestDB(t)
+	seedStatuses(t, db)
+	_, _ = db.Exec(`UPDATE tracks SET next_retry_at = datetime('now', '+1 hour') WHERE id = 'pending'`)
+	// Tracks in the trash don't count.
+	_, _ = db.Exec(`INSERT INTO tracks (id, analysis_status, deleted_at) VALUES ('trashed', 'failed', CURRENT_TIMESTAMP)`)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	counts, retry, err := repo.StatusCounts(ctx)
+	if err != nil {
+		t.Fatalf("StatusCounts: %v", err)
+	}
+	if counts["failed"] != 1 || counts["analyzed"] != 1 || len(counts) != 5 {
+		t.Errorf("counts = %v", counts)
+	}
+	if retry != 1 {
+		t.Errorf("retry scheduled = %d, want 1", retry)
+	}
+
+	failed, total, err := repo.ListFailed(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("ListFailed: %v", err)
+	}
+	if total != 1 || len(failed) != 1 || failed[0].ID != "failed" {
+		t.Fatalf("failed = %v (total %d)", failed, total)
+	}
+	if failed[0].Error == nil || *failed[0].Error != "boom" || failed[0].RetryCount != 2 {
+		t.Errorf("failed track = %+v", failed[0])
+	}
+}
+
+func TestRepository_MarkAnalyzed_StoresDescriptorsAndRaw(t *testing.T) {
+	db := newTestDB(t)
+	seedPending(t, db, "t1", "/a.wav")
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	raw := []byte(`{"lowlevel":{"average_loudness":0.8},"rhythm":{"bpm":124,"danceability":1.5}}`)
+	d, err := ParseEssentiaDescriptors(raw)
+	if err != nil || d == nil {
+		t.Fatalf("ParseEssentiaDescriptors = %v, %v", d, err)
+	}
+	if err := repo.MarkAnalyzed(ctx, "t1", Result{BPM: 124, Descriptors: d, Raw: raw}); err != nil {
+		t.Fatalf("MarkAnalyzed: %v", err)
+	}
+
+	var energy sql.NullInt64
+	var dance sql.NullFloat64
+	if err := db.QueryRow(`SELECT energy, danceability FROM tracks WHERE id='t1'`).Scan(&energy, &dance); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !energy.Valid || int(energy.Int64) != d.Energy || dance.Float64 != 0.5 {
+		t.Errorf("energy = %v, danceability = %v", energy, dance)
+	}
+	stored, err := repo.ListRawOutputs(ctx, "", false, 10)
+	if err != nil {
+		t.Fatalf("ListRawOutputs: %v", err)
+	}
+	if len(stored) != 1 || string(stored[0].Output) != string(raw) || stored[0].Backend != BackendEssentia {
+		t.Fatalf("raw outputs = %+v", stored)
+	}
+
+	// A later analysis without descriptors clears both.
+	if err := repo.MarkAnalyzed(ctx, "t1", Result{BPM: 124}); err != nil {
+		t.Fatalf("MarkAnalyzed: %v", err)
+	}
+	if err := db.QueryRow(`SELECT energy FROM tracks WHERE id='t1'`).Scan(&energy); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if energy.Valid {
+		t.Errorf("energy = %v, want NULL", energy)
+	}
+	if stored, _ := repo.ListRawOutputs(ctx, "", false, 10); len(stored) != 0 {
+		t.Errorf("raw outputs = %d, want 0", len(stored))
+	}
+}
+
+func TestManager_BackfillDescriptors(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	fixture, err := os.ReadFile("testdata/essentia_descriptors.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	good, _ := CompressRaw(fixture)
+	for _, id := range []string{"a", "b", "c"} {
+		seedPending(t, db, id, "/"+id+".wav")
+	}
+	// a has stored output but no descriptors (e.g. kept by an older parser),
+	// b's blob is corrupt, and c was never stored.
+	_, err = db.Exec(`INSERT INTO track_analysis_raw (track_id, backend, output) VALUES ('a', 'essentia', ?), ('b', 'essentia', ?)`,
+		good, []byte("junk"))
+	if err != nil {
+		t.Fatalf("seed raw: %v", err)
+	}
+	// A user-edited track keeps its status; only descriptors change.
+	_, _ = db.Exec(`UPDATE tracks SET analysis_status = 'user_edited', bpm = 99 WHERE id = 'a'`)
+
+	m := NewManager(repo, &fakeAnalyzer{})
+	n, err := m.BackfillDescriptors(ctx, false)
+	if err != nil {
+		t.Fatalf("BackfillDescriptors: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("updated = %d, want 1", n)
+	}
+	var energy sql.NullInt64
+	var mood, status string
+	var bpm float64
+	err = db.QueryRow(`SELECT energy, mood, analysis_status, bpm FROM tracks WHERE id='a'`).Scan(&energy, &mood, &status, &bpm)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if energy.Int64 != 7 || mood != "party" || status != "user_edited" || bpm != 99 {
+		t.Errorf("track a: energy = %v, mood = %q, status = %q, bpm = %v", energy, mood, status, bpm)
+	}
+	var mfcc []byte
+	var rolloff float64
+	if err := db.QueryRow(`SELECT mfcc, spectral_rolloff FROM track_features WHERE track_id='a'`).Scan(&mfcc, &rolloff); err != nil {
+		t.Fatalf("read features: %v", err)
+	}
+	if got := DecodeMFCC(mfcc); len(got) != MFCCBands || rolloff != 4380.2 {
+		t.Errorf("features: mfcc = %v, rolloff = %v", got, rolloff)
+	}
+
+	// a now has descriptors, so only all=true reparses it.
+	if n, _ := m.BackfillDescriptors(ctx, false); n != 0 {
+		t.Errorf("second backfill updated = %d, want 0", n)
+	}
+	if n, _ := m.BackfillDescriptors(ctx, true); n != 1 {
+		t.Errorf("backfill all updated = %d, want 1", n)
+	}
+}
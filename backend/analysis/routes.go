@@ -0,0 +1,28 @@
+package analysis
+
+import (
+	"github.com/faraz525/home-music-server/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func Routes(manager *Manager, chain *Chain) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager, chain)
+
+		r := rg.Group("/analysis")
+		r.Use(auth.AdminMiddleware())
+		{
+			r.GET("/status", handlers.Status)
+			r.GET("/failed", handlers.ListFailed)
+			r.GET("/quality", handlers.Quality)
+			r.POST("/requeue", handlers.Requeue)
+			r.POST("/reanalyze", handlers.Reanalyze)
+			r.GET("/backends", handlers.ListBackends)
+			r.POST("/requeue-backend", handlers.RequeueBackend)
+			r.GET("/workers", handlers.Workers)
+			r.POST("/pause", handlers.Pause)
+			r.POST("/resume", handlers.Resume)
+			r.POST("/backfill-descriptors", handlers.BackfillDescriptors)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TempoRange is a preferred BPM window. Genre is normalized (see
+// NormalizeGenre); "" applies to tracks of any genre.
+type TempoRange struct {
+	Genre  string  `json:"genre"`
+	MinBPM float64 `json:"min_bpm"`
+	MaxBPM float64 `json:"max_bpm"`
+}
+
+// DefaultTempoRanges cover genres whose tempo is unambiguous enough to
+// correct without asking. A user's own range for the same genre wins.
+var DefaultTempoRanges = []TempoRange{
+	{Genre: "drum and bass", MinBPM: 160, MaxBPM: 185},
+	{Genre: "dnb", MinBPM: 160, MaxBPM: 185},
+	{Genre: "jungle", MinBPM: 155, MaxBPM: 180},
+	{Genre: "dubstep", MinBPM: 135, MaxBPM: 150},
+	{Genre: "house", MinBPM: 115, MaxBPM: 135},
+	{Genre: "techno", MinBPM: 120, MaxBPM: 150},
+	{Genre: "trance", MinBPM: 125, MaxBPM: 145},
+	{Genre: "hip hop", MinBPM: 75, MaxBPM: 115},
+}
+
+// autoCorrectConfidence is the BPM confidence below which a tempo outside
+// the user's catch-all range is corrected outright. Above it the reading is
+// kept and the correction only suggested, since a confident reading outside
+// a catch-all range is as likely an outlier track as an octave error. Genre
+// ranges are specific enough to always correct.
+const autoCorrectConfidence = 0.6
+
+// NormalizeGenre folds a genre tag for range matching: lower case, "&" as
+// "and", and runs of punctuation or spaces as one space, so "Drum & Bass"
+// and "drum-and-bass" match.
+func NormalizeGenre(genre string) string {
+	genre = strings.ReplaceAll(strings.ToLower(genre), "&", " and ")
+	return strings.Join(strings.FieldsFunc(genre, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// genreMatches reports whether the normalized genre contains want as whole
+// words, so "house" matches "deep house" but not "warehouse".
+func genreMatches(genre, want string) bool {
+	return want != "" && strings.Contains(" "+genre+" ", " "+want+" ")
+}
+
+// MatchTempoRange picks the range for a track's genre: the user's most
+// specific genre range, then the built-in one, then the user's catch-all.
+// genreRange reports whether the match was for the genre.
+func MatchTempoRange(genre string, ranges []TempoRange) (r TempoRange, genreRange, ok bool) {
+	genre = NormalizeGenre(genre)
+	best := func(rs []TempoRange) (TempoRange, bool) {
+		var found TempoRange
+		for _, c := range rs {
+			if genreMatches(genre, c.Genre) && len(c.Genre) > len(found.Genre) {
+				found = c
+			}
+		}
+		return found, found.Genre != ""
+	}
+	if r, ok := best(ranges); ok {
+		return r, true, true
+	}
+	if r, ok := best(DefaultTempoRanges); ok {
+		return r, true, true
+	}
+	for _, c := range ranges {
+		if c.Genre == "" {
+			return c, false, true
+		}
+	}
+	return TempoRange{}, false, false
+}
+
+// TempoFactor returns the octave multiple (2 or 0.5) that moves bpm into
+// the range, or 1 when it is already inside or no multiple fits.
+func TempoFactor(bpm float64, r TempoRange) float64 {
+	in := func(v float64) bool { return v >= r.MinBPM && v <= r.MaxBPM }
+	switch {
+	case bpm <= 0 || in(bpm):
+		return 1
+	case in(bpm * 2):
+		return 2
+	case in(bpm / 2):
+		return 0.5
+	}
+	return 1
+}
+
+// NormalizeTempo checks a detected tempo for half- and double-time errors
+// against the user's tempo ranges. factor is the multiple to apply to bpm (1
+// for none); when a correction is likely but not certain enough to apply,
+// factor is 1 and suggested is the corrected BPM (0 otherwise).
+func NormalizeTempo(bpm, confidence float64, genre string, ranges []TempoRange) (factor, suggested float64) {
+	r, genreRange, ok := MatchTempoRange(genre, ranges)
+	if !ok {
+		return 1, 0
+	}
+	factor = TempoFactor(bpm, r)
+	if factor == 1 || genreRange || confidence < autoCorrectConfidence {
+		return factor, 0
+	}
+	return 1, round3(bpm * factor)
+}
+
+// ScaleBeats adapts beat positions to a tempo corrected by factor: doubling
+// adds a beat halfway between each pair, halving keeps every other beat.
+func ScaleBeats(beats []float64, factor float64) []float64 {
+	switch {
+	case len(beats) == 0 || factor == 1:
+		return beats
+	case factor == 2:
+		out := make([]float64, 0, 2*len(beats)-1)
+		for i, b := range beats {
+			if i > 0 {
+				out = append(out, round3((beats[i-1]+b)/2))
+			}
+			out = append(out, b)
+		}
+		return out
+	case factor == 0.5:
+		out := make([]float64, 0, (len(beats)+1)/2)
+		for i := 0; i < len(beats); i += 2 {
+			out = append(out, beats[i])
+		}
+		return out
+	}
+	return beats
+}
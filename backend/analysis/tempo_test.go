@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeGenre(t *testing.T) {
+	cases := map[string]string{
+		"Drum & Bass":         "drum and bass",
+		"drum-and-bass":       "drum and bass",
+		"  Deep  House ":      "deep house",
+		"Hip-Hop/Rap":         "hip hop rap",
+		"":                    "",
+		"Électronique 2-Step": "électronique 2 step",
+	}
+	for in, want := range cases {
+		if got := NormalizeGenre(in); got != want {
+			t.Errorf("NormalizeGenre(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchTempoRange(t *testing.T) {
+	user := []TempoRange{
+		{Genre: "", MinBPM: 90, MaxBPM: 150},
+		{Genre: "house", MinBPM: 118, MaxBPM: 128},
+		{Genre: "tech house", MinBPM: 124, MaxBPM: 130},
+	}
+	cases := []struct {
+		genre    string
+		want     TempoRange
+		genreHit bool
+	}{
+		{"Tech House", user[2], true},
+		{"Deep House", user[1], true},
+		{"Warehouse", user[0], false},
+		{"Drum & Bass", TempoRange{Genre: "drum and bass", MinBPM: 160, MaxBPM: 185}, true},
+		{"", user[0], false},
+	}
+	for _, tc := range cases {
+		got, genreHit, ok := MatchTempoRange(tc.genre, user)
+		if !ok || got != tc.want || genreHit != tc.genreHit {
+			t.Errorf("MatchTempoRange(%q) = %+v, %v, %v; want %+v, %v", tc.genre, got, genreHit, ok, tc.want, tc.genreHit)
+		}
+	}
+	if _, _, ok := MatchTempoRange("ambient", nil); ok {
+		t.Error("no ranges and no built-in genre should not match")
+	}
+}
+
+func TestNormalizeTempo(t *testing.T) {
+	catchAll := []TempoRange{{MinBPM: 100, MaxBPM: 150}}
+	cases := []struct {
+		name      string
+		bpm, conf float64
+		genre     string
+		ranges    []TempoRange
+		factor    float64
+		suggested float64
+	}{
+		{"half-time dnb corrected by genre", 87, 0.9, "Drum & Bass", nil, 2, 0},
+		{"double-time house corrected by genre", 248, 0.9, "House", nil, 0.5, 0},
+		{"house already in range", 124, 0.9, "House", nil, 1, 0},
+		{"low confidence corrected by catch-all", 64, 0.3, "", catchAll, 2, 0},
+		{"confident reading only suggested", 64, 0.9, "", catchAll, 1, 128},
+		{"no multiple fits", 40, 0.3, "", catchAll, 1, 0},
+		{"no ranges", 87, 0.1, "ambient", nil, 1, 0},
+	}
+	for _, tc := range cases {
+		factor, suggested := NormalizeTempo(tc.bpm, tc.conf, tc.genre, tc.ranges)
+		if factor != tc.factor || suggested != tc.suggested {
+			t.Errorf("%s: NormalizeTempo = %v, %v; want %v, %v", tc.name, factor, suggested, tc.factor, tc.suggested)
+		}
+	}
+}
+
+func TestScaleBeats(t *testing.T) {
+	beats := []float64{0.5, 1.2, 1.9, 2.6}
+	if got := ScaleBeats(beats, 2); !reflect.DeepEqual(got, []float64{0.5, 0.85, 1.2, 1.55, 1.9, 2.25, 2.6}) {
+		t.Errorf("doubled = %v", got)
+	}
+	if got := ScaleBeats(beats, 0.5); !reflect.DeepEqual(got, []float64{0.5, 1.9}) {
+		t.Errorf("halved = %v", got)
+	}
+	if got := ScaleBeats(beats, 1); !reflect.DeepEqual(got, beats) {
+		t.Errorf("unchanged = %v", got)
+	}
+}
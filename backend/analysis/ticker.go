@@ -4,48 +4,128 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"time"
 )
 
-// StartLoop polls for pending tracks on the given interval. When ProcessOne
-// reports a track was handled, the loop immediately drains the next one
-// instead of waiting for the next tick — this matters during initial backfill,
-// where a library of N tracks would otherwise take N*interval of idle wait.
+// missingBackoff is how long a worker waits before re-checking for analysis
+// tools after finding none installed.
+const missingBackoff = 10 * time.Minute
+
+// DefaultWorkers leaves half the cores for streaming, transcoding and the
+// rest of the server: two workers on a Pi 5.
+func DefaultWorkers() int {
+	if n := runtime.NumCPU() / 2; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// StartLoop runs a pool of workers that poll for pending tracks on the given
+// interval. Claims are atomic, so workers never analyze the same track. When
+// ProcessOne reports a track was handled, a worker immediately drains the
+// next one instead of waiting for the next tick — this matters during initial
+// backfill, where a library of N tracks would otherwise take N*interval of
+// idle wait.
 //
-// Stops when ctx is cancelled or when ProcessOne reports ErrBinaryMissing
-// (binary won't appear without a server restart, so no point spinning).
-func StartLoop(ctx context.Context, m *Manager, interval time.Duration) {
-	fmt.Printf("[Analysis] Starting loop (interval=%s)\n", interval)
+// Workers stop claiming while the manager is paused or playback is active,
+// re-checking every interval. When ProcessOne reports ErrBinaryMissing (no
+// configured backend is installed) a worker sleeps for missingBackoff rather
+// than exiting, so installing a tool into a running container is picked up
+// without a restart. Returns once every worker has stopped after ctx is
+// cancelled.
+func StartLoop(ctx context.Context, m *Manager, interval time.Duration, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if n, err := m.repo.RequeueInterrupted(ctx); err != nil {
+		fmt.Printf("[Analysis] Requeue interrupted tracks: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("[Analysis] Requeued %d track(s) interrupted by the last shutdown\n", n)
+	}
+	// Cheap: it only parses stored JSON, filling in tracks whose output was
+	// kept but never turned into descriptors.
+	if n, err := m.BackfillDescriptors(ctx, false); err != nil {
+		fmt.Printf("[Analysis] Backfill descriptors: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("[Analysis] Backfilled descriptors for %d track(s) from stored output\n", n)
+	}
+
+	fmt.Printf("[Analysis] Starting %d worker(s) (interval=%s)\n", workers, interval)
+	m.workers.Store(int32(workers))
+	var wg sync.WaitGroup
+	for i := 1; i <= workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			runWorker(ctx, m, id, interval)
+		}(i)
+	}
+	wg.Wait()
+	m.workers.Store(0)
+	fmt.Println("[Analysis] Loop stopped")
+}
+
+func runWorker(ctx context.Context, m *Manager, id int, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	wasHeld := false
 	for {
 		if ctx.Err() != nil {
-			fmt.Println("[Analysis] Loop stopped")
 			return
 		}
 
-		processed, err := m.ProcessOne(ctx)
-		if err != nil {
-			if errors.Is(err, ErrBinaryMissing) {
-				fmt.Println("[Analysis] essentia binary not available; stopping loop until restart")
-				return
+		// Only worker 1 logs hold changes, so the log reads the same whatever
+		// the pool size.
+		held, reason := m.Hold()
+		if held != wasHeld && id == 1 {
+			if held {
+				fmt.Printf("[Analysis] Holding off (%s)\n", reason)
+			} else {
+				fmt.Println("[Analysis] Resuming")
+			}
+		}
+		wasHeld = held
+
+		processed := false
+		if !held {
+			var err error
+			processed, err = m.ProcessOne(ctx)
+			if err != nil {
+				if errors.Is(err, ErrBinaryMissing) {
+					if id == 1 {
+						fmt.Printf("[Analysis] %v; retrying in %s\n", err, missingBackoff)
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(missingBackoff):
+					}
+					continue
+				}
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				fmt.Printf("[Analysis] Worker %d: ProcessOne error: %v\n", id, err)
 			}
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				fmt.Println("[Analysis] Loop stopped")
-				return
+			// Nothing to analyze: catch up on quality audits instead.
+			if !processed && err == nil {
+				processed, err = m.AuditOne(ctx)
+				if err != nil {
+					fmt.Printf("[Analysis] Worker %d: AuditOne error: %v\n", id, err)
+				}
 			}
-			fmt.Printf("[Analysis] ProcessOne error: %v\n", err)
 		}
 
 		// Drain mode: if we just processed a track, loop again without waiting.
-		// If idle, block on the next tick (or shutdown).
+		// If idle or held, block on the next tick (or shutdown).
 		if processed {
 			continue
 		}
 		select {
 		case <-ctx.Done():
-			fmt.Println("[Analysis] Loop stopped")
 			return
 		case <-ticker.C:
 		}
@@ -0,0 +1,84 @@
+package cues
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrTrackNotFound):
+		return http.StatusNotFound, "track_not_found"
+	case errors.Is(err, ErrCueNotFound):
+		return http.StatusNotFound, "cue_not_found"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrSlotTaken):
+		return http.StatusConflict, "slot_taken"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+func (h *Handlers) ListCues(c *gin.Context) {
+	cues, err := h.manager.List(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cues": cues})
+}
+
+func (h *Handlers) CreateCue(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	cue, err := h.manager.Create(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"cue": cue})
+}
+
+func (h *Handlers) UpdateCue(c *gin.Context) {
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	cue, err := h.manager.Update(c.Request.Context(), c.Param("id"), c.Param("cueId"), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cue": cue})
+}
+
+func (h *Handlers) DeleteCue(c *gin.Context) {
+	if err := h.manager.Delete(c.Request.Context(), c.Param("id"), c.Param("cueId"), c.GetString("user_id"), c.GetString("user_role")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "cue deleted"})
+}
@@ -0,0 +1,260 @@
+package cues
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrCueNotFound    = errors.New("cue not found")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrSlotTaken      = errors.New("hot cue slot taken")
+)
+
+// Limits. Eight hot cues matches the pads on CDJs and most controllers.
+const (
+	maxHotCueSlot   = 8
+	maxLabelLength  = 64
+	maxCuesPerTrack = 256
+)
+
+var colorRE = regexp.MustCompile(`^#[0-9A-F]{6}$`)
+
+// Manager stores per-user cue points. Cues are keyed by position in seconds
+// and kept apart from the analysis columns and beatgrid, so reanalysing a
+// track never moves or drops them.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now}
+}
+
+type CreateRequest struct {
+	Kind            string   `json:"kind" binding:"required"`
+	Slot            *int     `json:"slot"`
+	PositionSeconds *float64 `json:"position_seconds" binding:"required"`
+	EndSeconds      *float64 `json:"end_seconds"`
+	Label           string   `json:"label"`
+	Color           string   `json:"color"`
+}
+
+// UpdateRequest changes any of a cue's fields except its kind; omitted fields
+// are left as they are. An empty color clears it.
+type UpdateRequest struct {
+	Slot            *int     `json:"slot"`
+	PositionSeconds *float64 `json:"position_seconds"`
+	EndSeconds      *float64 `json:"end_seconds"`
+	Label           *string  `json:"label"`
+	Color           *string  `json:"color"`
+}
+
+// track loads a track the user may keep cues on: their own, or any for admins.
+func (m *Manager) track(ctx context.Context, trackID, userID, userRole string) (*Track, error) {
+	t, err := m.repo.GetTrack(ctx, trackID)
+	if err != nil {
+		return nil, err
+	}
+	if userRole != "admin" && t.OwnerUserID != userID {
+		return nil, ErrAccessDenied
+	}
+	return t, nil
+}
+
+// normalize tidies user input before validation: trimmed labels and
+// upper-case colours with a leading '#'.
+func normalize(c *Cue) {
+	c.Label = strings.TrimSpace(c.Label)
+	c.Color = strings.ToUpper(strings.TrimSpace(c.Color))
+	if c.Color != "" && !strings.HasPrefix(c.Color, "#") {
+		c.Color = "#" + c.Color
+	}
+}
+
+// validate checks a cue against its kind and the track's duration. A track
+// with no known duration only gets the lower bounds checked.
+func validate(c *Cue, duration float64) error {
+	inTrack := func(name string, s float64) error {
+		if math.IsNaN(s) || math.IsInf(s, 0) || s < 0 {
+			return fmt.Errorf("%w: %s must be a non-negative number of seconds", ErrInvalidRequest, name)
+		}
+		if duration > 0 && s > duration {
+			return fmt.Errorf("%w: %s is past the end of the track (%.3fs)", ErrInvalidRequest, name, duration)
+		}
+		return nil
+	}
+
+	switch c.Kind {
+	case KindHot:
+		if c.Slot == nil || *c.Slot < 1 || *c.Slot > maxHotCueSlot {
+			return fmt.Errorf("%w: hot cues need a slot from 1 to %d", ErrInvalidRequest, maxHotCueSlot)
+		}
+	case KindMemory, KindLoop:
+		if c.Slot != nil {
+			return fmt.Errorf("%w: only hot cues have a slot", ErrInvalidRequest)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be hot, memory or loop", ErrInvalidRequest)
+	}
+	if err := inTrack("position_seconds", c.PositionSeconds); err != nil {
+		return err
+	}
+	if c.Kind == KindLoop {
+		if c.EndSeconds == nil {
+			return fmt.Errorf("%w: loops need end_seconds", ErrInvalidRequest)
+		}
+		if err := inTrack("end_seconds", *c.EndSeconds); err != nil {
+			return err
+		}
+		if *c.EndSeconds <= c.PositionSeconds {
+			return fmt.Errorf("%w: end_seconds must be after position_seconds", ErrInvalidRequest)
+		}
+	} else if c.EndSeconds != nil {
+		return fmt.Errorf("%w: only loops have end_seconds", ErrInvalidRequest)
+	}
+	if utf8.RuneCountInString(c.Label) > maxLabelLength {
+		return fmt.Errorf("%w: label is limited to %d characters", ErrInvalidRequest, maxLabelLength)
+	}
+	if c.Color != "" && !colorRE.MatchString(c.Color) {
+		return fmt.Errorf("%w: color must be a hex colour like #FF8800", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func (m *Manager) checkSlot(ctx context.Context, c *Cue) error {
+	if c.Kind != KindHot {
+		return nil
+	}
+	taken, err := m.repo.SlotTaken(ctx, c.TrackID, c.UserID, *c.Slot, c.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: hot cue %d is already set", ErrSlotTaken, *c.Slot)
+	}
+	return nil
+}
+
+// List returns the user's cues on a track.
+func (m *Manager) List(ctx context.Context, trackID, userID, userRole string) ([]*Cue, error) {
+	if _, err := m.track(ctx, trackID, userID, userRole); err != nil {
+		return nil, err
+	}
+	return m.repo.ListCues(ctx, trackID, userID)
+}
+
+// Create adds a cue to a track for the user.
+func (m *Manager) Create(ctx context.Context, trackID, userID, userRole string, req *CreateRequest) (*Cue, error) {
+	t, err := m.track(ctx, trackID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	c := &Cue{
+		ID:              uuid.New().String(),
+		TrackID:         t.ID,
+		UserID:          userID,
+		Kind:            strings.ToLower(strings.TrimSpace(req.Kind)),
+		Slot:            req.Slot,
+		PositionSeconds: *req.PositionSeconds,
+		EndSeconds:      req.EndSeconds,
+		Label:           req.Label,
+		Color:           req.Color,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	normalize(c)
+	if err := validate(c, t.DurationSeconds); err != nil {
+		return nil, err
+	}
+	n, err := m.repo.CountCues(ctx, t.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxCuesPerTrack {
+		return nil, fmt.Errorf("%w: tracks are limited to %d cues", ErrInvalidRequest, maxCuesPerTrack)
+	}
+	if err := m.checkSlot(ctx, c); err != nil {
+		return nil, err
+	}
+	if err := m.repo.CreateCue(ctx, c); err != nil {
+		return nil, fmt.Errorf("create cue: %w", err)
+	}
+	return c, nil
+}
+
+// get loads one of the user's cues on a track. Cues belonging to someone
+// else, or to another track, are reported as not found.
+func (m *Manager) get(ctx context.Context, trackID, cueID, userID string) (*Cue, error) {
+	c, err := m.repo.GetCue(ctx, cueID)
+	if err != nil {
+		return nil, err
+	}
+	if c.TrackID != trackID || c.UserID != userID {
+		return nil, ErrCueNotFound
+	}
+	return c, nil
+}
+
+// Update applies req to one of the user's cues.
+func (m *Manager) Update(ctx context.Context, trackID, cueID, userID, userRole string, req *UpdateRequest) (*Cue, error) {
+	t, err := m.track(ctx, trackID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	c, err := m.get(ctx, t.ID, cueID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Slot != nil {
+		c.Slot = req.Slot
+	}
+	if req.PositionSeconds != nil {
+		c.PositionSeconds = *req.PositionSeconds
+	}
+	if req.EndSeconds != nil {
+		c.EndSeconds = req.EndSeconds
+	}
+	if req.Label != nil {
+		c.Label = *req.Label
+	}
+	if req.Color != nil {
+		c.Color = *req.Color
+	}
+	normalize(c)
+	if err := validate(c, t.DurationSeconds); err != nil {
+		return nil, err
+	}
+	if err := m.checkSlot(ctx, c); err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = m.now()
+	if err := m.repo.UpdateCue(ctx, c); err != nil {
+		return nil, fmt.Errorf("update cue: %w", err)
+	}
+	return c, nil
+}
+
+// Delete removes one of the user's cues.
+func (m *Manager) Delete(ctx context.Context, trackID, cueID, userID, userRole string) error {
+	t, err := m.track(ctx, trackID, userID, userRole)
+	if err != nil {
+		return err
+	}
+	if _, err := m.get(ctx, t.ID, cueID, userID); err != nil {
+		return err
+	}
+	return m.repo.DeleteCue(ctx, cueID)
+}
@@ -0,0 +1,169 @@
+package cues
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (id TEXT PRIMARY KEY, owner_user_id TEXT, duration_seconds REAL, deleted_at DATETIME);
+		CREATE TABLE track_cues (
+			id TEXT PRIMARY KEY, track_id TEXT NOT NULL, user_id TEXT NOT NULL, kind TEXT NOT NULL,
+			slot INTEGER, position_seconds REAL NOT NULL, end_seconds REAL, label TEXT NOT NULL DEFAULT '',
+			color TEXT, created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL
+		);
+		CREATE UNIQUE INDEX idx_track_cues_hot_slot ON track_cues(track_id, user_id, slot) WHERE kind = 'hot';
+		INSERT INTO tracks (id, owner_user_id, duration_seconds, deleted_at) VALUES
+			('t1', 'dj', 300, NULL), ('t2', 'other', 200, NULL), ('t3', 'dj', NULL, NULL),
+			('trashed', 'dj', 300, CURRENT_TIMESTAMP);
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB}))
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		cue  Cue
+		ok   bool
+	}{
+		{"hot cue", Cue{Kind: KindHot, Slot: ptr(1), PositionSeconds: 12.5}, true},
+		{"hot cue without slot", Cue{Kind: KindHot, PositionSeconds: 12.5}, false},
+		{"hot cue slot out of range", Cue{Kind: KindHot, Slot: ptr(9), PositionSeconds: 1}, false},
+		{"memory cue with slot", Cue{Kind: KindMemory, Slot: ptr(1), PositionSeconds: 1}, false},
+		{"memory cue at the very end", Cue{Kind: KindMemory, PositionSeconds: 300}, true},
+		{"past the end", Cue{Kind: KindMemory, PositionSeconds: 300.5}, false},
+		{"negative", Cue{Kind: KindMemory, PositionSeconds: -1}, false},
+		{"loop", Cue{Kind: KindLoop, PositionSeconds: 60, EndSeconds: ptr(61.875)}, true},
+		{"loop without end", Cue{Kind: KindLoop, PositionSeconds: 60}, false},
+		{"backwards loop", Cue{Kind: KindLoop, PositionSeconds: 60, EndSeconds: ptr(59.0)}, false},
+		{"loop past the end", Cue{Kind: KindLoop, PositionSeconds: 290, EndSeconds: ptr(310.0)}, false},
+		{"end on a cue", Cue{Kind: KindMemory, PositionSeconds: 1, EndSeconds: ptr(2.0)}, false},
+		{"colour", Cue{Kind: KindMemory, PositionSeconds: 1, Color: "#FF8800"}, true},
+		{"bad colour", Cue{Kind: KindMemory, PositionSeconds: 1, Color: "orange"}, false},
+		{"unknown kind", Cue{Kind: "fade", PositionSeconds: 1}, false},
+	}
+	for _, tc := range cases {
+		err := validate(&tc.cue, 300)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: error = %v, want ErrInvalidRequest", tc.name, err)
+		}
+	}
+}
+
+func TestValidate_UnknownDurationOnlyChecksLowerBound(t *testing.T) {
+	if err := validate(&Cue{Kind: KindMemory, PositionSeconds: 5000}, 0); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestCreateListUpdateDelete(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	hot, err := m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: "Hot", Slot: ptr(2), PositionSeconds: ptr(30.0), Label: " Drop ", Color: "ff8800"})
+	if err != nil {
+		t.Fatalf("Create hot: %v", err)
+	}
+	if hot.Kind != KindHot || hot.Label != "Drop" || hot.Color != "#FF8800" {
+		t.Errorf("hot cue = %+v, want normalized kind, label and colour", hot)
+	}
+	if _, err := m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: KindLoop, PositionSeconds: ptr(60.0), EndSeconds: ptr(64.0)}); err != nil {
+		t.Fatalf("Create loop: %v", err)
+	}
+	if _, err := m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: KindMemory, PositionSeconds: ptr(10.0)}); err != nil {
+		t.Fatalf("Create memory: %v", err)
+	}
+
+	cues, err := m.List(ctx, "t1", "dj", "user")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(cues) != 3 || cues[0].ID != hot.ID || cues[1].PositionSeconds != 10 || cues[2].Kind != KindLoop {
+		t.Errorf("List = %+v, want hot cue first then the rest by position", cues)
+	}
+
+	updated, err := m.Update(ctx, "t1", hot.ID, "dj", "user", &UpdateRequest{PositionSeconds: ptr(31.5), Color: ptr("")})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.PositionSeconds != 31.5 || updated.Color != "" || updated.Label != "Drop" {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	if err := m.Delete(ctx, "t1", hot.ID, "dj", "user"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if cues, _ := m.List(ctx, "t1", "dj", "user"); len(cues) != 2 {
+		t.Errorf("%d cues after delete, want 2", len(cues))
+	}
+}
+
+func TestHotCueSlotsAreUniquePerUser(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	first, err := m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: KindHot, Slot: ptr(1), PositionSeconds: ptr(1.0)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, err = m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: KindHot, Slot: ptr(1), PositionSeconds: ptr(2.0)})
+	if !errors.Is(err, ErrSlotTaken) {
+		t.Errorf("second cue in slot 1: error = %v, want ErrSlotTaken", err)
+	}
+	// Moving a cue within its own slot is not a conflict.
+	if _, err := m.Update(ctx, "t1", first.ID, "dj", "user", &UpdateRequest{Slot: ptr(1), PositionSeconds: ptr(3.0)}); err != nil {
+		t.Errorf("Update in place: %v", err)
+	}
+	// An admin's cues are their own.
+	if _, err := m.Create(ctx, "t1", "admin1", "admin", &CreateRequest{Kind: KindHot, Slot: ptr(1), PositionSeconds: ptr(2.0)}); err != nil {
+		t.Errorf("admin cue in slot 1: %v", err)
+	}
+	if cues, _ := m.List(ctx, "t1", "dj", "user"); len(cues) != 1 {
+		t.Errorf("dj sees %d cues, want only their own", len(cues))
+	}
+}
+
+func TestAccess(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.List(ctx, "t2", "dj", "user"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("other user's track: error = %v, want ErrAccessDenied", err)
+	}
+	if _, err := m.List(ctx, "missing", "dj", "user"); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("missing track: error = %v, want ErrTrackNotFound", err)
+	}
+	if _, err := m.Create(ctx, "trashed", "dj", "user", &CreateRequest{Kind: KindMemory, PositionSeconds: ptr(1.0)}); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("track in the trash: error = %v, want ErrTrackNotFound", err)
+	}
+	cue, err := m.Create(ctx, "t1", "dj", "user", &CreateRequest{Kind: KindMemory, PositionSeconds: ptr(1.0)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := m.Delete(ctx, "t3", cue.ID, "dj", "user"); !errors.Is(err, ErrCueNotFound) {
+		t.Errorf("cue through another track: error = %v, want ErrCueNotFound", err)
+	}
+	if err := m.Delete(ctx, "t1", cue.ID, "admin1", "admin"); !errors.Is(err, ErrCueNotFound) {
+		t.Errorf("someone else's cue: error = %v, want ErrCueNotFound", err)
+	}
+}
@@ -0,0 +1,171 @@
+package cues
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Cue kinds.
+const (
+	KindHot    = "hot"    // numbered pad, one per slot
+	KindMemory = "memory" // unnumbered marker
+	KindLoop   = "loop"   // saved loop from position to end
+)
+
+// Cue is one user's cue point or loop on a track.
+type Cue struct {
+	ID              string    `json:"id"`
+	TrackID         string    `json:"track_id"`
+	Kind            string    `json:"kind"`
+	Slot            *int      `json:"slot,omitempty"`
+	PositionSeconds float64   `json:"position_seconds"`
+	EndSeconds      *float64  `json:"end_seconds,omitempty"`
+	Label           string    `json:"label"`
+	Color           string    `json:"color,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	UserID string `json:"-"`
+}
+
+// Track is the subset of a track row cues are validated against.
+type Track struct {
+	ID              string
+	OwnerUserID     string
+	DurationSeconds float64 // 0 when unknown
+}
+
+func (r *Repository) GetTrack(ctx context.Context, trackID string) (*Track, error) {
+	var t Track
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_user_id, COALESCE(duration_seconds, 0) FROM tracks WHERE id = ? AND deleted_at IS NULL`,
+		trackID,
+	).Scan(&t.ID, &t.OwnerUserID, &t.DurationSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTrackNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const cueColumns = `id, track_id, user_id, kind, slot, position_seconds, end_seconds, label, color, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCue(row rowScanner) (*Cue, error) {
+	var c Cue
+	var slot sql.NullInt64
+	var end sql.NullFloat64
+	var color sql.NullString
+	if err := row.Scan(&c.ID, &c.TrackID, &c.UserID, &c.Kind, &slot, &c.PositionSeconds, &end,
+		&c.Label, &color, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if slot.Valid {
+		v := int(slot.Int64)
+		c.Slot = &v
+	}
+	if end.Valid {
+		c.EndSeconds = &end.Float64
+	}
+	c.Color = color.String
+	return &c, nil
+}
+
+// ListCues returns a user's cues on a track: hot cues by slot, then memory
+// cues and loops by position.
+func (r *Repository) ListCues(ctx context.Context, trackID, userID string) ([]*Cue, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+cueColumns+` FROM track_cues
+		WHERE track_id = ? AND user_id = ?
+		ORDER BY kind != 'hot', slot, position_seconds
+	`, trackID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cues := []*Cue{}
+	for rows.Next() {
+		c, err := scanCue(rows)
+		if err != nil {
+			return nil, err
+		}
+		cues = append(cues, c)
+	}
+	return cues, rows.Err()
+}
+
+func (r *Repository) GetCue(ctx context.Context, id string) (*Cue, error) {
+	c, err := scanCue(r.db.QueryRowContext(ctx, `SELECT `+cueColumns+` FROM track_cues WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCueNotFound
+	}
+	return c, err
+}
+
+// CountCues returns how many cues a user has on a track.
+func (r *Repository) CountCues(ctx context.Context, trackID, userID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM track_cues WHERE track_id = ? AND user_id = ?`, trackID, userID,
+	).Scan(&n)
+	return n, err
+}
+
+// SlotTaken reports whether the user already has a hot cue in slot on the
+// track, other than the cue exceptID.
+func (r *Repository) SlotTaken(ctx context.Context, trackID, userID string, slot int, exceptID string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM track_cues
+		WHERE track_id = ? AND user_id = ? AND kind = 'hot' AND slot = ? AND id != ?
+	`, trackID, userID, slot, exceptID).Scan(&n)
+	return n > 0, err
+}
+
+func nullColor(color string) any {
+	if color == "" {
+		return nil
+	}
+	return color
+}
+
+func (r *Repository) CreateCue(ctx context.Context, c *Cue) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO track_cues (id, track_id, user_id, kind, slot, position_seconds, end_seconds, label, color, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.ID, c.TrackID, c.UserID, c.Kind, c.Slot, c.PositionSeconds, c.EndSeconds, c.Label, nullColor(c.Color),
+		c.CreatedAt, c.UpdatedAt)
+	return err
+}
+
+func (r *Repository) UpdateCue(ctx context.Context, c *Cue) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE track_cues
+		SET slot = ?, position_seconds = ?, end_seconds = ?, label = ?, color = ?, updated_at = ?
+		WHERE id = ?
+	`, c.Slot, c.PositionSeconds, c.EndSeconds, c.Label, nullColor(c.Color), c.UpdatedAt, c.ID)
+	return err
+}
+
+func (r *Repository) DeleteCue(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM track_cues WHERE id = ?`, id)
+	return err
+}
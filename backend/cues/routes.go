@@ -0,0 +1,17 @@
+package cues
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/tracks/:id/cues")
+		{
+			r.GET("", handlers.ListCues)
+			r.POST("", handlers.CreateCue)
+			r.PATCH("/:cueId", handlers.UpdateCue)
+			r.DELETE("/:cueId", handlers.DeleteCue)
+		}
+	}
+}
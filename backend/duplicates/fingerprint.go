@@ -0,0 +1,123 @@
+package duplicates
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Fingerprinter computes Chromaprint fingerprints: one 32-bit sub-
+// fingerprint per ~0.12s of audio.
+type Fingerprinter interface {
+	Fingerprint(ctx context.Context, fullPath string) ([]uint32, error)
+}
+
+// fingerprintSeconds is how much of each track is fingerprinted. Two
+// minutes tells recordings apart and keeps fpcalc quick on a Pi.
+const fingerprintSeconds = 120
+
+// FPCalc fingerprints with Chromaprint's fpcalc tool.
+type FPCalc struct{}
+
+// NewFPCalc returns an FPCalc, or nil when fpcalc isn't installed.
+func NewFPCalc() Fingerprinter {
+	if _, err := exec.LookPath("fpcalc"); err != nil {
+		return nil
+	}
+	return &FPCalc{}
+}
+
+func (FPCalc) Fingerprint(ctx context.Context, fullPath string) ([]uint32, error) {
+	out, err := exec.CommandContext(ctx, "fpcalc", "-raw", "-length", strconv.Itoa(fingerprintSeconds), fullPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc: %w", err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if raw, ok := strings.CutPrefix(sc.Text(), "FINGERPRINT="); ok {
+			return parseFingerprint(raw)
+		}
+	}
+	return nil, errors.New("fpcalc printed no fingerprint")
+}
+
+// parseFingerprint reads a comma-separated raw fingerprint, as fpcalc
+// prints it and the tracks table stores it.
+func parseFingerprint(raw string) ([]uint32, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, errors.New("empty fingerprint")
+	}
+	parts := strings.Split(raw, ",")
+	fp := make([]uint32, len(parts))
+	for i, p := range parts {
+		// Older fpcalc builds print signed values.
+		v, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad fingerprint value %q", p)
+		}
+		fp[i] = uint32(v)
+	}
+	return fp, nil
+}
+
+func formatFingerprint(fp []uint32) string {
+	parts := make([]string, len(fp))
+	for i, v := range fp {
+		parts[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// FingerprintThreshold is the Similarity above which two fingerprints are
+// the same recording. Unrelated audio agrees on about half the bits;
+// re-encodes of one recording on well over 90%.
+const FingerprintThreshold = 0.8
+
+// maxShift is how far apart, in sub-fingerprints, two files' audio may
+// start: about five seconds of extra silence or intro.
+const maxShift = 40
+
+// minOverlap is the fewest sub-fingerprints two files must share, about
+// ten seconds, for a comparison to count.
+const minOverlap = 80
+
+// Similarity is the share of bits two fingerprints agree on, from 0.5 for
+// unrelated audio to 1 for the same, at the best alignment within
+// maxShift.
+func Similarity(a, b []uint32) float64 {
+	best := 0.0
+	for shift := -maxShift; shift <= maxShift; shift++ {
+		x, y := a, b
+		if shift > 0 {
+			if shift >= len(x) {
+				continue
+			}
+			x = x[shift:]
+		} else if shift < 0 {
+			if -shift >= len(y) {
+				continue
+			}
+			y = y[-shift:]
+		}
+		n := min(len(x), len(y))
+		if n < minOverlap {
+			continue
+		}
+		diff := 0
+		for i := 0; i < n; i++ {
+			diff += bits.OnesCount32(x[i] ^ y[i])
+		}
+		if s := 1 - float64(diff)/float64(32*n); s > best {
+			best = s
+		}
+	}
+	return best
+}
@@ -0,0 +1,72 @@
+package duplicates
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+// ListGroups lists the user's duplicate groups.
+func (h *Handlers) ListGroups(c *gin.Context) {
+	var opts Options
+	if v := c.Query("tolerance"); v != "" {
+		tolerance, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": "tolerance must be a number of seconds"}})
+			return
+		}
+		opts.Tolerance = tolerance
+	}
+	opts.Fingerprint, _ = strconv.ParseBool(c.Query("fingerprint"))
+	opts.Limit, _ = strconv.Atoi(c.Query("limit"))
+	opts.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	list, err := h.manager.Find(c.Request.Context(), c.GetString("user_id"), &opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Merge keeps one copy of a track and deletes the rest.
+func (h *Handlers) Merge(c *gin.Context) {
+	var req MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	result, err := h.manager.Merge(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,526 @@
+package duplicates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// The reasons tracks are grouped as duplicates.
+const (
+	ReasonISRC        = "isrc"
+	ReasonName        = "name"
+	ReasonFingerprint = "fingerprint"
+)
+
+// Defaults and limits.
+const (
+	// DefaultTolerance is how far apart, in seconds, two copies' durations
+	// may be: enough for a trimmed silence or a different encoder delay,
+	// not for a radio edit.
+	DefaultTolerance = 3.0
+	maxTolerance     = 30.0
+
+	defaultLimit = 50
+	maxLimit     = 200
+
+	// maxFingerprints is how many missing fingerprints one Find computes;
+	// the rest are left for the next call, so a large library doesn't hold
+	// a request for minutes.
+	maxFingerprints = 50
+
+	// maxMerge is how many tracks one merge takes.
+	maxMerge = 50
+)
+
+// Fingerprint candidates are found through an index of sub-fingerprint
+// prefixes: copies of one recording share many of them, unrelated tracks
+// few. Common prefixes (silence, steady tones) say little and are skipped.
+const (
+	prefixShift     = 12
+	maxPostings     = 200
+	minSharedHashes = 10
+)
+
+// Options narrows and pages a duplicate search.
+type Options struct {
+	// Tolerance is the most, in seconds, two copies' durations may differ
+	// when matched by name or fingerprint; DefaultTolerance when zero.
+	Tolerance float64
+	// Fingerprint also compares the audio, computing fingerprints the
+	// tracks don't have yet.
+	Fingerprint bool
+	Limit       int
+	Offset      int
+}
+
+// Group is a set of tracks that look like copies of one recording, best
+// copy first.
+type Group struct {
+	// Reasons says why the tracks were grouped: isrc, name, fingerprint.
+	Reasons []string `json:"reasons"`
+	// Keep is the copy a merge keeps by default.
+	Keep   string   `json:"keep"`
+	Tracks []*Track `json:"tracks"`
+}
+
+// GroupList is a page of duplicate groups.
+type GroupList struct {
+	Items   []*Group `json:"items"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	HasNext bool     `json:"has_next"`
+	// FingerprintsPending counts tracks still to be fingerprinted; fingerprint
+	// matches may be missing until it reaches zero.
+	FingerprintsPending int `json:"fingerprints_pending,omitempty"`
+}
+
+// MergeRequest merges copies of a track into one.
+type MergeRequest struct {
+	TrackIDs []string `json:"track_ids" binding:"required"`
+	// Keep is the copy to keep; the best quality one when empty.
+	Keep string `json:"keep"`
+}
+
+// MergeResult is what a merge kept and what it deleted.
+type MergeResult struct {
+	Kept   string   `json:"kept"`
+	Merged []string `json:"merged"`
+}
+
+// TrackFiles deletes tracks with their files and finds files on disk;
+// tracks.Manager.
+type TrackFiles interface {
+	DeleteTrack(ctx context.Context, trackID string) error
+	ResolveFullPath(relativePath string) (string, bool)
+}
+
+// Manager finds tracks a user has more than one copy of and merges them
+// into the best copy.
+type Manager struct {
+	repo  *Repository
+	files TrackFiles
+	fp    Fingerprinter // nil when fpcalc isn't installed
+}
+
+func NewManager(repo *Repository, files TrackFiles, fp Fingerprinter) *Manager {
+	return &Manager{repo: repo, files: files, fp: fp}
+}
+
+// Find returns a page of the user's duplicate groups. Tracks group when
+// they share an ISRC; when their artist and title match after
+// normalization and their durations are within the tolerance; or, with
+// opts.Fingerprint, when their audio matches.
+func (m *Manager) Find(ctx context.Context, userID string, opts *Options) (*GroupList, error) {
+	o := *opts
+	if o.Tolerance == 0 {
+		o.Tolerance = DefaultTolerance
+	}
+	if o.Tolerance < 0 || o.Tolerance > maxTolerance || math.IsNaN(o.Tolerance) {
+		return nil, fmt.Errorf("%w: tolerance must be between 0 and %g seconds", ErrInvalidRequest, maxTolerance)
+	}
+	if o.Fingerprint && m.fp == nil {
+		return nil, fmt.Errorf("%w: fingerprinting needs fpcalc, which isn't installed", ErrInvalidRequest)
+	}
+	if o.Limit < 1 || o.Limit > maxLimit {
+		o.Limit = defaultLimit
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+
+	tracks, err := m.repo.Tracks(ctx, userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list tracks: %w", err)
+	}
+	g := newGrouping(tracks)
+	g.byISRC()
+	g.byName(o.Tolerance)
+	pending := 0
+	if o.Fingerprint {
+		if pending, err = m.fingerprint(ctx, tracks); err != nil {
+			return nil, err
+		}
+		g.byFingerprint(o.Tolerance)
+	}
+
+	groups := g.groups()
+	total := len(groups)
+	page := []*Group{}
+	if o.Offset < total {
+		page = groups[o.Offset:min(o.Offset+o.Limit, total)]
+	}
+	return &GroupList{
+		Items:               page,
+		Total:               total,
+		Limit:               o.Limit,
+		Offset:              o.Offset,
+		HasNext:             o.Offset+o.Limit < total,
+		FingerprintsPending: pending,
+	}, nil
+}
+
+// fingerprint computes and stores fingerprints for up to maxFingerprints
+// tracks that don't have one, and returns how many are still missing.
+func (m *Manager) fingerprint(ctx context.Context, tracks []*Track) (int, error) {
+	done, pending := 0, 0
+	for _, t := range tracks {
+		if t.fingerprint.Valid {
+			continue
+		}
+		if done == maxFingerprints {
+			pending++
+			continue
+		}
+		done++
+		stored := ""
+		if path, ok := m.files.ResolveFullPath(t.filePath); ok {
+			fp, err := m.fp.Fingerprint(ctx, path)
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			if err != nil {
+				fmt.Printf("[Duplicates] Fingerprint failed for %s: %v\n", t.ID, err)
+			} else {
+				stored = formatFingerprint(fp)
+			}
+		}
+		if err := m.repo.SaveFingerprint(ctx, t.ID, stored); err != nil {
+			return 0, fmt.Errorf("save fingerprint: %w", err)
+		}
+		t.fingerprint.String, t.fingerprint.Valid = stored, true
+	}
+	return pending, nil
+}
+
+// grouping unions tracks into duplicate groups, remembering why.
+type grouping struct {
+	tracks  []*Track
+	parent  []int
+	reasons map[int]map[string]bool
+}
+
+func newGrouping(tracks []*Track) *grouping {
+	g := &grouping{tracks: tracks, parent: make([]int, len(tracks)), reasons: map[int]map[string]bool{}}
+	for i := range g.parent {
+		g.parent[i] = i
+	}
+	return g
+}
+
+func (g *grouping) find(i int) int {
+	for g.parent[i] != i {
+		g.parent[i] = g.parent[g.parent[i]]
+		i = g.parent[i]
+	}
+	return i
+}
+
+func (g *grouping) union(i, j int, reason string) {
+	ri, rj := g.find(i), g.find(j)
+	if ri != rj {
+		g.parent[rj] = ri
+		for r := range g.reasons[rj] {
+			g.addReason(ri, r)
+		}
+		delete(g.reasons, rj)
+	}
+	g.addReason(ri, reason)
+}
+
+func (g *grouping) addReason(root int, reason string) {
+	if g.reasons[root] == nil {
+		g.reasons[root] = map[string]bool{}
+	}
+	g.reasons[root][reason] = true
+}
+
+// byISRC groups tracks with the same ISRC, whatever their lengths: the
+// code names the recording.
+func (g *grouping) byISRC() {
+	first := map[string]int{}
+	for i, t := range g.tracks {
+		if t.ISRC == nil || *t.ISRC == "" {
+			continue
+		}
+		isrc := strings.ToUpper(*t.ISRC)
+		if j, ok := first[isrc]; ok {
+			g.union(j, i, ReasonISRC)
+		} else {
+			first[isrc] = i
+		}
+	}
+}
+
+// byName groups tracks with the same normalized artist and title whose
+// durations are within tolerance of one another. A track of unknown
+// length joins its name's first group.
+func (g *grouping) byName(tolerance float64) {
+	buckets := map[string][]int{}
+	for i, t := range g.tracks {
+		if key := nameKey(deref(t.Title), deref(t.Artist), t.OriginalFilename); key != "" {
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+	for _, idx := range buckets {
+		if len(idx) < 2 {
+			continue
+		}
+		var timed, untimed []int
+		for _, i := range idx {
+			if g.tracks[i].DurationSeconds == nil {
+				untimed = append(untimed, i)
+			} else {
+				timed = append(timed, i)
+			}
+		}
+		slices.SortStableFunc(timed, func(a, b int) int {
+			return compareFloat(*g.tracks[a].DurationSeconds, *g.tracks[b].DurationSeconds)
+		})
+		for k := 1; k < len(timed); k++ {
+			if *g.tracks[timed[k]].DurationSeconds-*g.tracks[timed[k-1]].DurationSeconds <= tolerance {
+				g.union(timed[k-1], timed[k], ReasonName)
+			}
+		}
+		anchor := idx[0]
+		if len(timed) > 0 {
+			anchor = timed[0]
+		}
+		for _, i := range untimed {
+			if i != anchor {
+				g.union(anchor, i, ReasonName)
+			}
+		}
+	}
+}
+
+// byFingerprint groups tracks whose audio matches and whose durations are
+// within tolerance, whatever their tags say.
+func (g *grouping) byFingerprint(tolerance float64) {
+	fps := make([][]uint32, len(g.tracks))
+	index := map[uint32][]int{}
+	for i, t := range g.tracks {
+		if t.fingerprint.String == "" {
+			continue
+		}
+		fp, err := parseFingerprint(t.fingerprint.String)
+		if err != nil {
+			continue
+		}
+		fps[i] = fp
+		seen := map[uint32]bool{}
+		for _, v := range fp {
+			if h := v >> prefixShift; !seen[h] {
+				seen[h] = true
+				index[h] = append(index[h], i)
+			}
+		}
+	}
+
+	shared := map[[2]int]int{}
+	for _, posting := range index {
+		if len(posting) < 2 || len(posting) > maxPostings {
+			continue
+		}
+		for a := 0; a < len(posting); a++ {
+			for b := a + 1; b < len(posting); b++ {
+				shared[[2]int{posting[a], posting[b]}]++
+			}
+		}
+	}
+	for pair, n := range shared {
+		i, j := pair[0], pair[1]
+		if n < minSharedHashes || !withinTolerance(g.tracks[i], g.tracks[j], tolerance) {
+			continue
+		}
+		if Similarity(fps[i], fps[j]) >= FingerprintThreshold {
+			g.union(i, j, ReasonFingerprint)
+		}
+	}
+}
+
+func withinTolerance(a, b *Track, tolerance float64) bool {
+	if a.DurationSeconds == nil || b.DurationSeconds == nil {
+		return true
+	}
+	return math.Abs(*a.DurationSeconds-*b.DurationSeconds) <= tolerance
+}
+
+// groups returns the groups of two or more tracks, best copy first,
+// ordered by artist and title.
+func (g *grouping) groups() []*Group {
+	members := map[int][]*Track{}
+	for i, t := range g.tracks {
+		root := g.find(i)
+		members[root] = append(members[root], t)
+	}
+	groups := []*Group{}
+	for root, tracks := range members {
+		if len(tracks) < 2 {
+			continue
+		}
+		rank(tracks)
+		var reasons []string
+		for _, r := range []string{ReasonISRC, ReasonName, ReasonFingerprint} {
+			if g.reasons[root][r] {
+				reasons = append(reasons, r)
+			}
+		}
+		groups = append(groups, &Group{Reasons: reasons, Keep: tracks[0].ID, Tracks: tracks})
+	}
+	slices.SortFunc(groups, func(a, b *Group) int {
+		if c := strings.Compare(sortKey(a.Tracks[0]), sortKey(b.Tracks[0])); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Keep, b.Keep)
+	})
+	return groups
+}
+
+func sortKey(t *Track) string {
+	return NormalizeArtist(deref(t.Artist)) + "\x00" + NormalizeTitle(deref(t.Title))
+}
+
+// rank sorts copies of a recording best first: copies that passed the
+// quality audit before suspicious and transcoded ones, lossless formats
+// before lossy, then higher bitrate, higher sample rate, bigger file, and
+// the older upload.
+func rank(tracks []*Track) {
+	slices.SortStableFunc(tracks, func(a, b *Track) int {
+		if c := trust(b) - trust(a); c != 0 {
+			return c
+		}
+		if c := boolRank(lossless(b)) - boolRank(lossless(a)); c != 0 {
+			return c
+		}
+		if !lossless(a) {
+			if c := deref(b.Bitrate) - deref(a.Bitrate); c != 0 {
+				return c
+			}
+		}
+		if c := deref(b.SampleRate) - deref(a.SampleRate); c != 0 {
+			return c
+		}
+		if c := compareInt64(b.SizeBytes, a.SizeBytes); c != 0 {
+			return c
+		}
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// trust ranks a track's quality verdict; unaudited tracks are trusted.
+func trust(t *Track) int {
+	switch deref(t.QualityVerdict) {
+	case analysis.QualityTranscoded:
+		return 0
+	case analysis.QualitySuspicious:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func lossless(t *Track) bool {
+	return analysis.QualitySource{ContentType: t.ContentType, Filename: t.OriginalFilename}.Lossless()
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// Merge keeps one of the user's copies of a track and deletes the others,
+// moving their crate memberships, cues, ratings, tags and sync links to the
+// one kept.
+func (m *Manager) Merge(ctx context.Context, userID string, req *MergeRequest) (*MergeResult, error) {
+	var ids []string
+	for _, id := range req.TrackIDs {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("%w: give at least two tracks to merge", ErrInvalidRequest)
+	}
+	if len(ids) > maxMerge {
+		return nil, fmt.Errorf("%w: at most %d tracks can be merged at once", ErrInvalidRequest, maxMerge)
+	}
+	if req.Keep != "" && !slices.Contains(ids, req.Keep) {
+		return nil, fmt.Errorf("%w: keep must be one of track_ids", ErrInvalidRequest)
+	}
+
+	tracks, err := m.repo.Tracks(ctx, userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load tracks: %w", err)
+	}
+	if len(tracks) != len(ids) {
+		return nil, fmt.Errorf("%w: track", ErrNotFound)
+	}
+	rank(tracks)
+	keep := req.Keep
+	if keep == "" {
+		keep = tracks[0].ID
+	}
+	others := slices.DeleteFunc(ids, func(id string) bool { return id == keep })
+
+	if err := m.repo.Merge(ctx, keep, others); err != nil {
+		return nil, fmt.Errorf("merge: %w", err)
+	}
+	// Everything worth keeping has moved; a file left behind by a failed
+	// delete only costs disk space, so carry on with the rest.
+	merged := []string{}
+	for _, id := range others {
+		if err := m.files.DeleteTrack(ctx, id); err != nil {
+			fmt.Printf("[Duplicates] Failed to delete merged track %s: %v\n", id, err)
+			continue
+		}
+		merged = append(merged, id)
+	}
+	return &MergeResult{Kept: keep, Merged: merged}, nil
+}
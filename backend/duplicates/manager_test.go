@@ -0,0 +1,318 @@
+package duplicates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fakeFiles deletes track rows as tracks.Manager would, files aside.
+type fakeFiles struct {
+	db *sql.DB
+}
+
+func (f *fakeFiles) DeleteTrack(ctx context.Context, trackID string) error {
+	_, err := f.db.ExecContext(ctx, `DELETE FROM tracks WHERE id = ?`, trackID)
+	return err
+}
+
+func (f *fakeFiles) ResolveFullPath(relativePath string) (string, bool) {
+	return "/music/" + relativePath, true
+}
+
+// fakeFingerprinter returns canned fingerprints by path.
+type fakeFingerprinter map[string][]uint32
+
+func (f fakeFingerprinter) Fingerprint(ctx context.Context, fullPath string) ([]uint32, error) {
+	if fp, ok := f[fullPath]; ok {
+		return fp, nil
+	}
+	return nil, errors.New("unreadable")
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, title TEXT, artist TEXT, album TEXT, duration_seconds REAL,
+			original_filename TEXT NOT NULL, content_type TEXT NOT NULL, size_bytes INTEGER NOT NULL,
+			bitrate INTEGER, sample_rate INTEGER, quality_verdict TEXT, isrc TEXT, fingerprint TEXT,
+			file_path TEXT NOT NULL, created_at DATETIME NOT NULL, deleted_at DATETIME
+		);
+		CREATE TABLE playlist_tracks (
+			id TEXT PRIMARY KEY, playlist_id TEXT NOT NULL, track_id TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0, UNIQUE(playlist_id, track_id)
+		);
+		CREATE TABLE track_cues (
+			id TEXT PRIMARY KEY, track_id TEXT NOT NULL, user_id TEXT NOT NULL, kind TEXT NOT NULL,
+			slot INTEGER, position_seconds REAL NOT NULL, updated_at DATETIME
+		);
+		CREATE UNIQUE INDEX idx_track_cues_hot_slot ON track_cues(track_id, user_id, slot) WHERE kind = 'hot';
+		CREATE TABLE track_ratings (
+			track_id TEXT NOT NULL, user_id TEXT NOT NULL, rating INTEGER NOT NULL DEFAULT 0,
+			favourite BOOLEAN NOT NULL DEFAULT FALSE, favourited_at DATETIME, color TEXT,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (user_id, track_id)
+		);
+		CREATE TABLE track_tags (
+			track_id TEXT NOT NULL, tag_id TEXT NOT NULL, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (track_id, tag_id)
+		);
+		CREATE TABLE spotify_synced_tracks (id TEXT PRIMARY KEY, spotify_id TEXT NOT NULL UNIQUE, track_id TEXT NOT NULL);
+		CREATE TABLE soundcloud_synced_tracks (id TEXT PRIMARY KEY, soundcloud_id TEXT NOT NULL UNIQUE, track_id TEXT NOT NULL);
+	`)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	return sqlDB
+}
+
+func seed(t *testing.T, sqlDB *sql.DB, query string) {
+	t.Helper()
+	if _, err := sqlDB.Exec(query); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+}
+
+func groupIDs(list *GroupList) [][]string {
+	var out [][]string
+	for _, g := range list.Items {
+		var ids []string
+		for _, t := range g.Tracks {
+			ids = append(ids, t.ID)
+		}
+		out = append(out, ids)
+	}
+	return out
+}
+
+func TestNameKey(t *testing.T) {
+	same := [][2][3]string{
+		{{"Windowlicker (Original Mix)", "Aphex Twin", ""}, {"windowlicker", "APHEX TWIN", ""}},
+		{{"Café del Mar [Remastered 2011]", "Energy 52", ""}, {"Cafe Del Mar", "Energy 52", ""}},
+		{{"Losing It (feat. Someone)", "Fisher", ""}, {"Losing It", "FISHER ft. Someone", ""}},
+		{{"Turn Off The Lights", "Chris Lake & Alexis Roberts", ""}, {"Turn Off The Lights", "Alexis Roberts and Chris Lake", ""}},
+		{{"Aphex Twin - Xtal", "", ""}, {"Xtal", "Aphex Twin", ""}},
+		{{"", "Aphex Twin", "Xtal.flac"}, {"Xtal", "Aphex Twin", ""}},
+	}
+	for _, c := range same {
+		a, b := nameKey(c[0][0], c[0][1], c[0][2]), nameKey(c[1][0], c[1][1], c[1][2])
+		if a == "" || a != b {
+			t.Errorf("nameKey(%q) = %q, nameKey(%q) = %q; want equal", c[0], a, c[1], b)
+		}
+	}
+	different := [][2][3]string{
+		{{"Windowlicker", "Aphex Twin", ""}, {"Windowlicker (Remix)", "Aphex Twin", ""}},
+		{{"Xtal", "Aphex Twin", ""}, {"Xtal", "AFX", ""}},
+	}
+	for _, c := range different {
+		if a, b := nameKey(c[0][0], c[0][1], c[0][2]), nameKey(c[1][0], c[1][1], c[1][2]); a == b {
+			t.Errorf("nameKey(%q) = nameKey(%q) = %q; want different", c[0], c[1], a)
+		}
+	}
+	if k := nameKey("", "", ".mp3"); k != "" {
+		t.Errorf("nameKey of nothing = %q, want empty", k)
+	}
+}
+
+func TestFind(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seed(t, sqlDB, `
+		INSERT INTO tracks (id, owner_user_id, title, artist, duration_seconds, original_filename, content_type, size_bytes, bitrate, sample_rate, quality_verdict, isrc, file_path, created_at) VALUES
+			('mp3', 'dj', 'Xtal', 'Aphex Twin', 294.0, 'xtal.mp3', 'audio/mpeg', 11000000, 320000, 44100, 'lossy', NULL, 'a', '2024-01-01'),
+			('flac', 'dj', 'Xtal (Remastered)', 'Aphex Twin', 295.5, 'xtal.flac', 'audio/flac', 40000000, 900000, 44100, 'lossless', NULL, 'b', '2024-01-02'),
+			('fake', 'dj', 'Xtal', 'Aphex Twin', 294.2, 'xtal2.flac', 'audio/flac', 38000000, 900000, 44100, 'transcoded', NULL, 'c', '2024-01-03'),
+			('edit', 'dj', 'Xtal', 'Aphex Twin', 280.0, 'xtal-edit.mp3', 'audio/mpeg', 8000000, 320000, 44100, NULL, NULL, 'd', '2024-01-04'),
+			('isrc1', 'dj', 'Tha', 'Aphex Twin', 547.0, 'tha.mp3', 'audio/mpeg', 9000000, 192000, 44100, NULL, 'GBAAA9200001', 'e', '2024-01-05'),
+			('isrc2', 'dj', 'Untitled 2', '', 560.0, 'track02.mp3', 'audio/mpeg', 14000000, 320000, 44100, NULL, 'gbaaa9200001', 'f', '2024-01-06'),
+			('alone', 'dj', 'Pulsewidth', 'Aphex Twin', 227.0, 'pw.mp3', 'audio/mpeg', 9000000, 320000, 44100, NULL, NULL, 'g', '2024-01-07'),
+			('theirs', 'other', 'Xtal', 'Aphex Twin', 294.0, 'xtal.mp3', 'audio/mpeg', 11000000, 320000, 44100, NULL, NULL, 'h', '2024-01-08');
+	`)
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}), &fakeFiles{db: sqlDB}, nil)
+
+	list, err := m.Find(context.Background(), "dj", &Options{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	want := [][]string{{"isrc2", "isrc1"}, {"flac", "mp3", "fake"}}
+	if got := groupIDs(list); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+	if list.Total != 2 || list.Items[1].Keep != "flac" || !reflect.DeepEqual(list.Items[0].Reasons, []string{ReasonISRC}) {
+		t.Errorf("list = %+v, %+v", list, list.Items[1])
+	}
+
+	// A wide enough tolerance takes in the edit too.
+	list, err = m.Find(context.Background(), "dj", &Options{Tolerance: 30})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if got := groupIDs(list); len(got) != 2 || len(got[1]) != 4 {
+		t.Errorf("groups with 30s tolerance = %v", got)
+	}
+
+	for _, opts := range []*Options{{Tolerance: -1}, {Tolerance: 120}, {Fingerprint: true}} {
+		if _, err := m.Find(context.Background(), "dj", opts); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Find(%+v) err = %v, want ErrInvalidRequest", opts, err)
+		}
+	}
+}
+
+func TestFind_Fingerprint(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seed(t, sqlDB, `
+		INSERT INTO tracks (id, owner_user_id, title, artist, duration_seconds, original_filename, content_type, size_bytes, file_path, created_at) VALUES
+			('a', 'dj', 'Track 01', NULL, 300, 'track01.wav', 'audio/wav', 50000000, 'a.wav', '2024-01-01'),
+			('b', 'dj', 'Rhubarb', 'Aphex Twin', 301, 'rhubarb.mp3', 'audio/mpeg', 7000000, 'b.mp3', '2024-01-02'),
+			('c', 'dj', 'Something Else', 'Someone', 300, 'else.mp3', 'audio/mpeg', 7000000, 'c.mp3', '2024-01-03'),
+			('d', 'dj', 'Broken', 'Someone', 300, 'broken.mp3', 'audio/mpeg', 7000000, 'd.mp3', '2024-01-04');
+	`)
+	rng := rand.New(rand.NewSource(1))
+	recording := make([]uint32, 1000)
+	other := make([]uint32, 1000)
+	for i := range recording {
+		recording[i], other[i] = rng.Uint32(), rng.Uint32()
+	}
+	// b is a lossy copy of a starting a little later: a few bits differ.
+	copied := make([]uint32, 0, 1000)
+	for i, v := range recording[5:] {
+		if i%7 == 0 {
+			v ^= 1 << (i % 32)
+		}
+		copied = append(copied, v)
+	}
+	fp := fakeFingerprinter{"/music/a.wav": recording, "/music/b.mp3": copied, "/music/c.mp3": other}
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}), &fakeFiles{db: sqlDB}, fp)
+
+	list, err := m.Find(context.Background(), "dj", &Options{Fingerprint: true})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if got, want := groupIDs(list), [][]string{{"a", "b"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(list.Items[0].Reasons, []string{ReasonFingerprint}) {
+		t.Errorf("reasons = %v", list.Items[0].Reasons)
+	}
+
+	// Fingerprints are stored, failures as "", and not computed again.
+	var stored sql.NullString
+	sqlDB.QueryRow(`SELECT fingerprint FROM tracks WHERE id = 'd'`).Scan(&stored)
+	if !stored.Valid || stored.String != "" {
+		t.Errorf("failed fingerprint stored as %+v, want empty", stored)
+	}
+	m.fp = fakeFingerprinter{}
+	if list, err = m.Find(context.Background(), "dj", &Options{Fingerprint: true}); err != nil || len(list.Items) != 1 {
+		t.Errorf("second Find = %v, %v", groupIDs(list), err)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a, b := make([]uint32, 500), make([]uint32, 500)
+	for i := range a {
+		a[i], b[i] = rng.Uint32(), rng.Uint32()
+	}
+	if s := Similarity(a, a[10:]); s != 1 {
+		t.Errorf("shifted copy similarity = %v, want 1", s)
+	}
+	if s := Similarity(a, b); s >= FingerprintThreshold {
+		t.Errorf("unrelated similarity = %v, want below %v", s, FingerprintThreshold)
+	}
+	if s := Similarity(a[:10], a[:10]); s != 0 {
+		t.Errorf("too short similarity = %v, want 0", s)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	sqlDB := newTestDB(t)
+	seed(t, sqlDB, `
+		INSERT INTO tracks (id, owner_user_id, title, artist, duration_seconds, original_filename, content_type, size_bytes, bitrate, isrc, file_path, created_at) VALUES
+			('best', 'dj', 'Xtal', 'Aphex Twin', 294, 'xtal.flac', 'audio/flac', 40000000, NULL, NULL, 'a', '2024-01-02'),
+			('mp3', 'dj', 'Xtal', 'Aphex Twin', 294, 'xtal.mp3', 'audio/mpeg', 11000000, 320000, 'GBAAA9200002', 'b', '2024-01-01'),
+			('low', 'dj', 'Xtal', 'Aphex Twin', 294, 'xtal-128.mp3', 'audio/mpeg', 5000000, 128000, NULL, 'c', '2024-01-03'),
+			('theirs', 'other', 'Xtal', 'Aphex Twin', 294, 'xtal.mp3', 'audio/mpeg', 11000000, 320000, NULL, 'd', '2024-01-01');
+		-- crate1 holds both copies, crate2 only the mp3.
+		INSERT INTO playlist_tracks VALUES
+			('p1', 'crate1', 'x', 0), ('p2', 'crate1', 'mp3', 1), ('p3', 'crate1', 'y', 2), ('p4', 'crate1', 'best', 3),
+			('p5', 'crate2', 'mp3', 0), ('p6', 'crate2', 'z', 1);
+		INSERT INTO track_cues VALUES
+			('c1', 'best', 'dj', 'hot', 1, 10, NULL), ('c2', 'mp3', 'dj', 'hot', 1, 20, NULL),
+			('c3', 'mp3', 'dj', 'hot', 2, 30, NULL), ('c4', 'low', 'dj', 'memory', NULL, 40, NULL);
+		INSERT INTO track_ratings (track_id, user_id, rating, favourite, favourited_at, color) VALUES
+			('best', 'dj', 3, 0, NULL, NULL), ('mp3', 'dj', 5, 1, '2024-02-01', 'red'),
+			('low', 'dj', 2, 1, '2024-01-15', 'blue'), ('low', 'friend', 4, 0, NULL, NULL);
+		INSERT INTO track_tags (track_id, tag_id) VALUES ('best', 'warmup'), ('mp3', 'warmup'), ('low', 'peak');
+		INSERT INTO spotify_synced_tracks VALUES ('s1', 'sp1', 'mp3');
+		INSERT INTO soundcloud_synced_tracks VALUES ('s2', 'sc1', 'low');
+	`)
+	files := &fakeFiles{db: sqlDB}
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}), files, nil)
+	ctx := context.Background()
+
+	for _, req := range []*MergeRequest{
+		{TrackIDs: []string{"best"}},
+		{TrackIDs: []string{"best", "best"}},
+		{TrackIDs: []string{"best", "mp3"}, Keep: "low"},
+	} {
+		if _, err := m.Merge(ctx, "dj", req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Merge(%+v) err = %v, want ErrInvalidRequest", req, err)
+		}
+	}
+	if _, err := m.Merge(ctx, "dj", &MergeRequest{TrackIDs: []string{"best", "theirs"}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("merging another user's track: err = %v, want ErrNotFound", err)
+	}
+
+	result, err := m.Merge(ctx, "dj", &MergeRequest{TrackIDs: []string{"mp3", "low", "best"}})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if result.Kept != "best" || !reflect.DeepEqual(result.Merged, []string{"mp3", "low"}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	query := func(q string) []string {
+		rows, err := sqlDB.Query(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		defer rows.Close()
+		var out []string
+		for rows.Next() {
+			var s string
+			rows.Scan(&s)
+			out = append(out, s)
+		}
+		return out
+	}
+	checks := map[string][]string{
+		`SELECT id FROM tracks ORDER BY id`: {"best", "theirs"},
+		`SELECT playlist_id || ':' || track_id || ':' || position FROM playlist_tracks ORDER BY playlist_id, position`: {
+			"crate1:x:0", "crate1:y:1", "crate1:best:2", "crate2:best:0", "crate2:z:1",
+		},
+		`SELECT id FROM track_cues WHERE track_id = 'best' ORDER BY id`: {"c1", "c3", "c4"},
+		`SELECT user_id || ':' || rating || ':' || favourite || ':' || ifnull(favourited_at, '') || ':' || ifnull(color, '') FROM track_ratings WHERE track_id = 'best' ORDER BY user_id`: {
+			"dj:5:1:2024-01-15:red", "friend:4:0::",
+		},
+		`SELECT tag_id FROM track_tags WHERE track_id = 'best' ORDER BY tag_id`: {"peak", "warmup"},
+		`SELECT track_id FROM spotify_synced_tracks`:                            {"best"},
+		`SELECT track_id FROM soundcloud_synced_tracks`:                         {"best"},
+		`SELECT isrc FROM tracks WHERE id = 'best'`:                             {"GBAAA9200002"},
+	}
+	for q, want := range checks {
+		if got := query(q); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", q, got, want)
+		}
+	}
+}
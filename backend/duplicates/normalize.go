@@ -0,0 +1,70 @@
+package duplicates
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+var (
+	// versionNoise matches bracketed title suffixes that name a release
+	// rather than a recording: "(Original Mix)", "[Remastered 2011]",
+	// "(Official Audio)". Remixes and edits are different recordings and
+	// are left alone; their lengths usually differ anyway.
+	versionNoise = regexp.MustCompile(`(?i)\s*[(\[][^)\]]*\b(original mix|remaster|remastered|explicit|clean|official|lyrics?|hq|hd|free download|free dl)\b[^)\]]*[)\]]`)
+	// featBracket matches "(feat. Someone)" anywhere in a title.
+	featBracket = regexp.MustCompile(`(?i)\s*[(\[]\s*(feat|ft|featuring)\b[^)\]]*[)\]]`)
+	// featTail matches an unbracketed " feat. Someone" to the end.
+	featTail = regexp.MustCompile(`(?i)\s+(feat|ft|featuring)\b.*$`)
+)
+
+// artistFiller are words left out of artist names, so "A & B", "A and B"
+// and "B x A" compare equal.
+var artistFiller = []string{"and", "the", "x", "vs", "with"}
+
+// NormalizeTitle folds a title for comparison: accents and case go, and so
+// do featured artists and release noise like "(Original Mix)".
+func NormalizeTitle(title string) string {
+	title = featBracket.ReplaceAllString(title, "")
+	title = versionNoise.ReplaceAllString(title, "")
+	title = featTail.ReplaceAllString(title, "")
+	return strings.Join(search.Words(title), " ")
+}
+
+// NormalizeArtist folds an artist name for comparison: its words, less
+// featured artists and filler, in order, so credits listed in a different
+// order still match.
+func NormalizeArtist(artist string) string {
+	artist = featBracket.ReplaceAllString(artist, "")
+	artist = featTail.ReplaceAllString(artist, "")
+	words := slices.DeleteFunc(search.Words(artist), func(w string) bool {
+		return slices.Contains(artistFiller, w)
+	})
+	slices.Sort(words)
+	return strings.Join(slices.Compact(words), " ")
+}
+
+// nameKey returns the key tracks must share to be duplicates by name, or
+// "" when the track has no usable title. Tracks without a title tag use
+// their file name. SoundCloud and YouTube titles often read "Artist -
+// Title"; the artist part is split off when it names the track's artist,
+// or when the track has none.
+func nameKey(title, artist, filename string) string {
+	if strings.TrimSpace(title) == "" {
+		title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	a := NormalizeArtist(artist)
+	if left, right, ok := strings.Cut(title, " - "); ok {
+		if l := NormalizeArtist(left); a == "" || l == a {
+			a, title = l, right
+		}
+	}
+	t := NormalizeTitle(title)
+	if t == "" {
+		return ""
+	}
+	return a + "\x00" + t
+}
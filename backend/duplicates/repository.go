@@ -0,0 +1,176 @@
+package duplicates
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Track is a copy of a recording in a duplicate group, with what it takes
+// to judge which copy is best.
+type Track struct {
+	ID               string    `json:"id"`
+	Title            *string   `json:"title,omitempty"`
+	Artist           *string   `json:"artist,omitempty"`
+	Album            *string   `json:"album,omitempty"`
+	DurationSeconds  *float64  `json:"duration_seconds,omitempty"`
+	OriginalFilename string    `json:"original_filename"`
+	ContentType      string    `json:"content_type"`
+	SizeBytes        int64     `json:"size_bytes"`
+	Bitrate          *int      `json:"bitrate,omitempty"`
+	SampleRate       *int      `json:"sample_rate,omitempty"`
+	QualityVerdict   *string   `json:"quality_verdict,omitempty"`
+	ISRC             *string   `json:"isrc,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+
+	filePath string
+	// fingerprint is the stored fpcalc output: NULL before fingerprinting,
+	// "" when fpcalc couldn't read the file.
+	fingerprint sql.NullString
+}
+
+// placeholders returns "?, ?, ..." for n values.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func anys(ids []string) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// Tracks returns userID's tracks outside the trash, or just those of ids
+// when ids isn't nil.
+func (r *Repository) Tracks(ctx context.Context, userID string, ids []string) ([]*Track, error) {
+	query := `SELECT id, title, artist, album, duration_seconds, original_filename, content_type,
+		size_bytes, bitrate, sample_rate, quality_verdict, isrc, created_at, file_path, fingerprint
+		FROM tracks WHERE owner_user_id = ? AND deleted_at IS NULL`
+	args := []any{userID}
+	if ids != nil {
+		query += ` AND id IN (` + placeholders(len(ids)) + `)`
+		args = append(args, anys(ids)...)
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []*Track
+	for rows.Next() {
+		var t Track
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.Album, &t.DurationSeconds, &t.OriginalFilename, &t.ContentType,
+			&t.SizeBytes, &t.Bitrate, &t.SampleRate, &t.QualityVerdict, &t.ISRC, &t.CreatedAt, &t.filePath, &t.fingerprint); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, &t)
+	}
+	return tracks, rows.Err()
+}
+
+// SaveFingerprint stores a track's fingerprint; "" records that fpcalc
+// couldn't read the file, so it isn't tried again.
+func (r *Repository) SaveFingerprint(ctx context.Context, trackID, fingerprint string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tracks SET fingerprint = ? WHERE id = ?`, fingerprint, trackID)
+	return err
+}
+
+// mergeStep is one statement of a merge, and what it moves for errors.
+type mergeStep struct {
+	what  string
+	query string
+	args  []any
+}
+
+// Merge moves everything that hangs off the tracks in others to keep:
+// crate memberships, cues, ratings, tags and sync links, and keep's
+// missing ISRC. Where keep already has the same thing (a crate, a hot cue
+// slot, a tag) keep's stays; ratings combine, taking the higher rating and
+// the earliest favourite. The other tracks are left with nothing attached,
+// ready to be deleted.
+func (r *Repository) Merge(ctx context.Context, keep string, others []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	in := `(` + placeholders(len(others)) + `)`
+	ids := anys(others)
+	withKeep := append([]any{keep}, ids...)
+
+	// Crates: a copy takes the other's place unless keep is already in the
+	// crate; then positions close up as when tracks are removed.
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT playlist_id FROM playlist_tracks WHERE track_id IN `+in, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to find crates: %w", err)
+	}
+	var crates []any
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		crates = append(crates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	steps := []mergeStep{
+		{"crate memberships", `UPDATE OR IGNORE playlist_tracks SET track_id = ? WHERE track_id IN ` + in, withKeep},
+		{"crate memberships", `DELETE FROM playlist_tracks WHERE track_id IN ` + in, ids},
+		{"cues", `UPDATE OR IGNORE track_cues SET track_id = ?, updated_at = CURRENT_TIMESTAMP WHERE track_id IN ` + in, withKeep},
+		{"ratings", `
+			INSERT INTO track_ratings (track_id, user_id, rating, favourite, favourited_at, color, updated_at)
+			SELECT ?, user_id, MAX(rating), MAX(favourite), MIN(favourited_at), MAX(color), CURRENT_TIMESTAMP
+			FROM track_ratings WHERE track_id IN ` + in + ` GROUP BY user_id
+			ON CONFLICT (user_id, track_id) DO UPDATE SET
+				rating = MAX(rating, excluded.rating),
+				favourite = favourite OR excluded.favourite,
+				favourited_at = COALESCE(MIN(favourited_at, excluded.favourited_at), favourited_at, excluded.favourited_at),
+				color = COALESCE(color, excluded.color),
+				updated_at = CURRENT_TIMESTAMP`, withKeep},
+		{"tags", `
+			INSERT OR IGNORE INTO track_tags (track_id, tag_id, created_at)
+			SELECT ?, tag_id, MIN(created_at) FROM track_tags WHERE track_id IN ` + in + ` GROUP BY tag_id`, withKeep},
+		{"Spotify sync links", `UPDATE spotify_synced_tracks SET track_id = ? WHERE track_id IN ` + in, withKeep},
+		{"SoundCloud sync links", `UPDATE soundcloud_synced_tracks SET track_id = ? WHERE track_id IN ` + in, withKeep},
+		{"ISRC", `
+			UPDATE tracks SET isrc = (SELECT isrc FROM tracks WHERE id IN ` + in + ` AND isrc IS NOT NULL LIMIT 1)
+			WHERE id = ? AND isrc IS NULL`, append(ids, keep)},
+	}
+	if len(crates) > 0 {
+		steps = append(steps, mergeStep{"crate positions", `
+			UPDATE playlist_tracks
+			SET position = (
+				SELECT COUNT(*) FROM playlist_tracks pt2
+				WHERE pt2.playlist_id = playlist_tracks.playlist_id
+				AND pt2.position < playlist_tracks.position
+			)
+			WHERE playlist_id IN (` + placeholders(len(crates)) + `)`, crates})
+	}
+	for _, s := range steps {
+		if _, err := tx.ExecContext(ctx, s.query, s.args...); err != nil {
+			return fmt.Errorf("failed to move %s: %w", s.what, err)
+		}
+	}
+	return tx.Commit()
+}
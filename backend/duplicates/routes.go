@@ -0,0 +1,15 @@
+package duplicates
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/duplicates")
+		{
+			r.GET("", handlers.ListGroups)
+			r.POST("/merge", handlers.Merge)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
@@ -12,6 +13,13 @@ type Config struct {
 	BaseURL          string
 	Env              string
 	MonochromeAPIURL string
+	AnalysisBackends string
+	AnalysisWorkers  int
+	AnalysisNice     int
+	AnalysisCPUSecs  int
+	AnalysisIdleIO   bool
+	AnalysisYield    bool
+	TrashRetention   int
 }
 
 func FromEnv() *Config {
@@ -25,6 +33,17 @@ func FromEnv() *Config {
 	cfg.BaseURL = getEnv("BASE_URL", "http://localhost")
 	cfg.Env = getEnv("APP_ENV", "development")
 	cfg.MonochromeAPIURL = getEnv("MONOCHROME_API_URL", "")
+	// Comma-separated analyzer fallback chain; empty uses analysis.DefaultBackends
+	cfg.AnalysisBackends = getEnv("ANALYSIS_BACKENDS", "")
+	// 0 workers means analysis.DefaultWorkers() (half the CPU cores)
+	cfg.AnalysisWorkers = getEnvInt("ANALYSIS_WORKERS", 0)
+	cfg.AnalysisNice = getEnvInt("ANALYSIS_NICE", 10)
+	cfg.AnalysisCPUSecs = getEnvInt("ANALYSIS_CPU_SECONDS", 300)
+	cfg.AnalysisIdleIO = getEnvBool("ANALYSIS_IDLE_IO", true)
+	// Hold analysis while tracks, radio or rooms are playing
+	cfg.AnalysisYield = getEnvBool("ANALYSIS_PAUSE_ON_STREAM", true)
+	// Days deleted tracks stay in the trash until an admin sets otherwise
+	cfg.TrashRetention = getEnvInt("TRASH_RETENTION_DAYS", 30)
 	return cfg
 }
 
@@ -34,3 +53,17 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+func getEnvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
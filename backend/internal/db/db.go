@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -39,6 +40,22 @@ func New(dataDir string) (*DB, error) {
 
 func (d *DB) Close() error { return d.DB.Close() }
 
+// trigramDiacritics is the tracks_trigram tokenizer in migration 022.
+const trigramDiacritics = "'trigram remove_diacritics 1'"
+
+// trigramTokenizer returns the tracks_trigram tokenizer SQLite version
+// supports. The trigram tokenizer takes remove_diacritics from 3.45; older
+// libraries, like the 3.40 in Debian bookworm, index accents as they are,
+// and fuzzy search looks for both the accented and the folded trigrams.
+func trigramTokenizer(version string) string {
+	var major, minor int
+	fmt.Sscanf(version, "%d.%d", &major, &minor)
+	if major > 3 || (major == 3 && minor >= 45) {
+		return trigramDiacritics
+	}
+	return "'trigram'"
+}
+
 func (d *DB) migrate() error {
 	// Check if all required tables exist
 	requiredTables := []string{"users", "tracks", "refresh_tokens", "playlists", "playlist_tracks"}
@@ -179,5 +196,289 @@ func (d *DB) migrate() error {
 		}
 	}
 
+	// Check if mix_jobs table exists
+	var mixTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='mix_jobs'").Scan(&mixTableCount)
+	if mixTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/007_add_mix_jobs.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 007_add_mix_jobs: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 007_add_mix_jobs: %w", err)
+		}
+	}
+
+	// Check if bpm_backend column exists on tracks table
+	var backendColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='bpm_backend'
+	`).Scan(&backendColCount)
+	if backendColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/008_add_analysis_backends.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 008_add_analysis_backends: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 008_add_analysis_backends: %w", err)
+		}
+	}
+
+	// Check if track_grids table exists
+	var gridTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='track_grids'").Scan(&gridTableCount)
+	if gridTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/009_add_track_grids.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 009_add_track_grids: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 009_add_track_grids: %w", err)
+		}
+	}
+
+	// Check if track_cues table exists
+	var cueTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='track_cues'").Scan(&cueTableCount)
+	if cueTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/010_add_track_cues.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 010_add_track_cues: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 010_add_track_cues: %w", err)
+		}
+	}
+
+	// Check if energy column exists on tracks table
+	var energyColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='energy'
+	`).Scan(&energyColCount)
+	if energyColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/011_add_track_descriptors.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 011_add_track_descriptors: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 011_add_track_descriptors: %w", err)
+		}
+	}
+
+	// Check if track_analysis_raw table exists
+	var rawTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='track_analysis_raw'").Scan(&rawTableCount)
+	if rawTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/012_add_analysis_raw.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 012_add_analysis_raw: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 012_add_analysis_raw: %w", err)
+		}
+	}
+
+	// Check if bpm_raw column exists on tracks table
+	var bpmRawColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='bpm_raw'
+	`).Scan(&bpmRawColCount)
+	if bpmRawColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/013_add_bpm_raw.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 013_add_bpm_raw: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 013_add_bpm_raw: %w", err)
+		}
+	}
+
+	// Check if user_tempo_ranges table exists
+	var tempoTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='user_tempo_ranges'").Scan(&tempoTableCount)
+	if tempoTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/014_add_tempo_ranges.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 014_add_tempo_ranges: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 014_add_tempo_ranges: %w", err)
+		}
+	}
+
+	// Check if track_features table exists
+	var featuresTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='track_features'").Scan(&featuresTableCount)
+	if featuresTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/015_add_track_features.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 015_add_track_features: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 015_add_track_features: %w", err)
+		}
+	}
+
+	// Check if user_settings table exists
+	var settingsTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='user_settings'").Scan(&settingsTableCount)
+	if settingsTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/016_add_user_settings.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 016_add_user_settings: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 016_add_user_settings: %w", err)
+		}
+	}
+
+	// Check if quality audit columns exist
+	var qualityColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='quality_verdict'
+	`).Scan(&qualityColCount)
+	if qualityColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/017_add_quality_audit.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 017_add_quality_audit: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 017_add_quality_audit: %w", err)
+		}
+	}
+
+	// Check if the track list indexes exist
+	var listIndexCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name='idx_tracks_owner_bpm'").Scan(&listIndexCount)
+	if listIndexCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/018_add_track_list_indexes.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 018_add_track_list_indexes: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 018_add_track_list_indexes: %w", err)
+		}
+	}
+
+	// Check if the smart crate rules column exists
+	var rulesColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('playlists')
+		WHERE name='rules'
+	`).Scan(&rulesColCount)
+	if rulesColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/019_add_smart_crates.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 019_add_smart_crates: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 019_add_smart_crates: %w", err)
+		}
+	}
+
+	// Check if the search index has a tags column (tags tables come with it)
+	var ftsTagsColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks_fts')
+		WHERE name='tags'
+	`).Scan(&ftsTagsColCount)
+	if ftsTagsColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/020_add_track_tags.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 020_add_track_tags: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 020_add_track_tags: %w", err)
+		}
+	}
+
+	// Check if track_ratings table exists
+	var ratingsTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='track_ratings'").Scan(&ratingsTableCount)
+	if ratingsTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/021_add_track_ratings.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 021_add_track_ratings: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 021_add_track_ratings: %w", err)
+		}
+	}
+
+	// Check if the trigram search index exists
+	var trigramTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='tracks_trigram'").Scan(&trigramTableCount)
+	if trigramTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/022_add_fuzzy_search.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 022_add_fuzzy_search: %w", err)
+		}
+		var version string
+		if err := d.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+			return fmt.Errorf("failed to read SQLite version: %w", err)
+		}
+		migration := strings.Replace(string(migrationSQL), trigramDiacritics, trigramTokenizer(version), 1)
+		if _, err := d.Exec(migration); err != nil {
+			return fmt.Errorf("failed to execute migration 022_add_fuzzy_search: %w", err)
+		}
+	}
+
+	// Check if duplicate detection columns exist
+	var isrcColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='isrc'
+	`).Scan(&isrcColCount)
+	if isrcColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/023_add_duplicate_detection.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 023_add_duplicate_detection: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 023_add_duplicate_detection: %w", err)
+		}
+	}
+
+	// Check if the trash column exists
+	var deletedAtColCount int
+	_ = d.QueryRow(`
+		SELECT COUNT(*)
+		FROM pragma_table_info('tracks')
+		WHERE name='deleted_at'
+	`).Scan(&deletedAtColCount)
+	if deletedAtColCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/024_add_track_trash.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 024_add_track_trash: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 024_add_track_trash: %w", err)
+		}
+	}
+
+	// Check if trash_settings table exists
+	var trashSettingsTableCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='trash_settings'").Scan(&trashSettingsTableCount)
+	if trashSettingsTableCount == 0 {
+		migrationSQL, err := migrationsFS.ReadFile("migrations/025_add_trash_settings.sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration 025_add_trash_settings: %w", err)
+		}
+		if _, err := d.Exec(string(migrationSQL)); err != nil {
+			return fmt.Errorf("failed to execute migration 025_add_trash_settings: %w", err)
+		}
+	}
+
 	return nil
 }
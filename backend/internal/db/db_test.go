@@ -0,0 +1,62 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrigramTokenizer(t *testing.T) {
+	for version, want := range map[string]string{
+		"3.40.1": "'trigram'",
+		"3.44.2": "'trigram'",
+		"3.45.0": trigramDiacritics,
+		"3.50.2": trigramDiacritics,
+		"":       "'trigram'",
+	} {
+		if got := trigramTokenizer(version); got != want {
+			t.Errorf("trigramTokenizer(%q) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+// TestNew migrates a new database with whichever SQLite the build links:
+// the bundled one by default, or the system library with -tags libsqlite3,
+// as the Docker image is built.
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir)
+	if err != nil && strings.Contains(err.Error(), "fts5") {
+		t.Skip("sqlite3 built without FTS5; run with -tags sqlite_fts5 or -tags libsqlite3")
+	}
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var version string
+	_ = d.QueryRow("SELECT sqlite_version()").Scan(&version)
+	t.Logf("SQLite %s", version)
+
+	_, err = d.Exec(`
+		INSERT INTO users (id, email, password_hash, role) VALUES ('u1', 'dj@example.com', 'x', 'user');
+		INSERT INTO tracks (id, owner_user_id, title, artist, original_filename, content_type, size_bytes, file_path)
+		VALUES ('t1', 'u1', 'Halo', 'Beyoncé', 'halo.mp3', 'audio/mpeg', 1, 'u1/t1.mp3');
+	`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Fuzzy search's query for "beyonce" (see search.TrigramMatch), which
+	// must find the track with or without remove_diacritics.
+	var id string
+	err = d.QueryRow(`SELECT track_id FROM tracks_trigram WHERE tracks_trigram MATCH ?`,
+		`artist : (("bey" OR "eyo" OR "yon" OR "onc" OR "nce"))`).Scan(&id)
+	if err != nil || id != "t1" {
+		t.Errorf("trigram match = %q, %v; want t1", id, err)
+	}
+
+	// Opening it again finds everything migrated.
+	d.Close()
+	if d, err = New(dir); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	d.Close()
+}
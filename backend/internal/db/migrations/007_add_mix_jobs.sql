@@ -0,0 +1,24 @@
+-- Background continuous-mix renders of a crate
+CREATE TABLE IF NOT EXISTS mix_jobs (
+    id TEXT PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    playlist_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'rendering', 'done', 'failed')),
+    format TEXT NOT NULL CHECK (format IN ('flac', 'mp3')),
+    bpm_curve TEXT,                    -- JSON [{"at":0,"bpm":122},...]; NULL keeps each track's tempo
+    crossfade_bars INTEGER NOT NULL,
+    bitrate_kbps INTEGER,
+    progress REAL NOT NULL DEFAULT 0,  -- 0..1
+    tracklist TEXT,                    -- JSON, written when rendering starts
+    duration_seconds REAL,
+    size_bytes INTEGER,
+    output_path TEXT,
+    error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+    FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mix_jobs_owner ON mix_jobs(owner_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_mix_jobs_status ON mix_jobs(status, created_at);
@@ -0,0 +1,3 @@
+-- Which analysis backend produced each field (essentia, keyfinder, aubio, autocorr)
+ALTER TABLE tracks ADD COLUMN bpm_backend TEXT;
+ALTER TABLE tracks ADD COLUMN key_backend TEXT;
@@ -0,0 +1,13 @@
+-- Beatgrid, first downbeat and auto-generated cues per analyzed track
+CREATE TABLE IF NOT EXISTS track_grids (
+    track_id TEXT PRIMARY KEY,
+    bpm REAL NOT NULL,
+    beat_interval REAL NOT NULL,       -- seconds, unrounded
+    first_beat REAL NOT NULL,
+    first_downbeat REAL NOT NULL,
+    beat_count INTEGER NOT NULL,
+    beats BLOB,                        -- detected beats packed against the grid; NULL when tracked from onsets
+    cues TEXT NOT NULL DEFAULT '[]',   -- JSON [{"type":"drop","seconds":61.875,"bar":33},...]
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE
+);
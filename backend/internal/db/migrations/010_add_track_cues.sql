@@ -0,0 +1,19 @@
+-- Per-user hot cues, memory cues and saved loops
+CREATE TABLE IF NOT EXISTS track_cues (
+    id TEXT PRIMARY KEY,
+    track_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('hot', 'memory', 'loop')),
+    slot INTEGER,                      -- hot cues only, 1-8
+    position_seconds REAL NOT NULL,
+    end_seconds REAL,                  -- loops only
+    label TEXT NOT NULL DEFAULT '',
+    color TEXT,                        -- '#RRGGBB'; NULL leaves it to the client
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_track_cues_track_user ON track_cues(track_id, user_id, position_seconds);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_track_cues_hot_slot ON track_cues(track_id, user_id, slot) WHERE kind = 'hot';
@@ -0,0 +1,11 @@
+-- Audio descriptors from essentia and the derived 1-10 energy rating
+ALTER TABLE tracks ADD COLUMN energy INTEGER;                -- 1-10
+ALTER TABLE tracks ADD COLUMN danceability REAL;             -- 0-1
+ALTER TABLE tracks ADD COLUMN dynamic_complexity REAL;       -- dB
+ALTER TABLE tracks ADD COLUMN loudness_lufs REAL;            -- integrated, EBU R128
+ALTER TABLE tracks ADD COLUMN spectral_centroid REAL;        -- Hz
+ALTER TABLE tracks ADD COLUMN onset_rate REAL;               -- onsets per second
+ALTER TABLE tracks ADD COLUMN mood TEXT;                     -- happy, sad, aggressive, relaxed, party
+
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_energy ON tracks(owner_user_id, energy);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_danceability ON tracks(owner_user_id, danceability);
@@ -0,0 +1,9 @@
+-- Raw analyzer output (gzipped JSON), kept so descriptors can be re-derived
+-- without rerunning the analyzer
+CREATE TABLE IF NOT EXISTS track_analysis_raw (
+    track_id TEXT PRIMARY KEY,
+    backend TEXT NOT NULL,
+    output BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE
+);
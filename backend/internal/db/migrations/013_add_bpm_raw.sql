@@ -0,0 +1,6 @@
+-- Tempo as reported by the analyzer, before half/double-time correction, and
+-- the correction suggested for probable octave errors left uncorrected
+ALTER TABLE tracks ADD COLUMN bpm_raw REAL;
+ALTER TABLE tracks ADD COLUMN bpm_suggested REAL;
+
+UPDATE tracks SET bpm_raw = bpm WHERE analysis_status = 'analyzed' AND bpm IS NOT NULL;
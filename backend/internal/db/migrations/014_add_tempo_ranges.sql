@@ -0,0 +1,10 @@
+-- Per-user preferred BPM ranges, optionally for one genre
+CREATE TABLE IF NOT EXISTS user_tempo_ranges (
+    user_id TEXT NOT NULL,
+    genre TEXT NOT NULL DEFAULT '',    -- normalized; '' applies to any genre
+    min_bpm REAL NOT NULL,
+    max_bpm REAL NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, genre),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Timbre and spectral features from essentia that don't fit a column each,
+-- used to build the sonic similarity index
+CREATE TABLE IF NOT EXISTS track_features (
+    track_id TEXT PRIMARY KEY,
+    mfcc BLOB NOT NULL,                -- MFCC means, little-endian float32
+    spectral_rolloff REAL,             -- Hz
+    spectral_flux REAL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE
+);
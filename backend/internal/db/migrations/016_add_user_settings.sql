@@ -0,0 +1,7 @@
+-- Per-user display preferences
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id TEXT PRIMARY KEY,
+    key_notation TEXT NOT NULL DEFAULT 'camelot' CHECK (key_notation IN ('camelot', 'open_key', 'standard')),
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- Audio quality audit: spectral cutoff and lossy-source (fake lossless) verdict
+ALTER TABLE tracks ADD COLUMN quality_verdict TEXT;          -- lossless, lossy, suspicious, transcoded, inconclusive
+ALTER TABLE tracks ADD COLUMN quality_confidence REAL;       -- 0-1, that the audio passed through a lossy encoder
+ALTER TABLE tracks ADD COLUMN spectral_cutoff_hz REAL;       -- highest frequency with real content
+ALTER TABLE tracks ADD COLUMN quality_checked_at DATETIME;   -- set when an audit is claimed
+
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_quality ON tracks(owner_user_id, quality_verdict);
@@ -0,0 +1,9 @@
+-- Indexes for sorting and filtering track lists (library, crates, unsorted)
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_bpm ON tracks(owner_user_id, bpm);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_key ON tracks(owner_user_id, musical_key);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_genre ON tracks(owner_user_id, genre COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_format ON tracks(owner_user_id, content_type);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_status ON tracks(owner_user_id, analysis_status);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_title ON tracks(owner_user_id, title COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_artist ON tracks(owner_user_id, artist COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_playlist_tracks_playlist_added ON playlist_tracks(playlist_id, added_at DESC);
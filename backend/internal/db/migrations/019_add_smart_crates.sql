@@ -0,0 +1,3 @@
+-- Smart crates: crates whose tracks are the ones matching saved rules (JSON,
+-- see search.Rules) rather than playlist_tracks rows. NULL for normal crates.
+ALTER TABLE playlists ADD COLUMN rules TEXT;
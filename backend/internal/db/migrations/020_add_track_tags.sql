@@ -0,0 +1,92 @@
+-- Per-user free-form tags ("vocal", "warmup", "B2B with Sam") on tracks
+CREATE TABLE IF NOT EXISTS tags (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    color TEXT,                        -- '#RRGGBB'; NULL leaves it to the client
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags(user_id, name COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS track_tags (
+    track_id TEXT NOT NULL,
+    tag_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (track_id, tag_id),
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_track_tags_tag ON track_tags(tag_id);
+
+-- Rebuild the search index with a tags column holding each track's tag
+-- names. FTS5 tables can't gain columns, so it is recreated and refilled.
+DROP TRIGGER IF EXISTS tracks_fts_insert;
+DROP TRIGGER IF EXISTS tracks_fts_update;
+DROP TRIGGER IF EXISTS tracks_fts_delete;
+DROP TABLE IF EXISTS tracks_fts;
+
+CREATE VIRTUAL TABLE tracks_fts USING fts5(
+    track_id UNINDEXED,
+    title,
+    artist,
+    album,
+    genre,
+    original_filename,
+    tags
+);
+
+INSERT INTO tracks_fts(track_id, title, artist, album, genre, original_filename, tags)
+SELECT t.id, t.title, t.artist, t.album, t.genre, t.original_filename,
+       (SELECT group_concat(g.name, ' ') FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = t.id)
+FROM tracks t;
+
+CREATE TRIGGER tracks_fts_insert
+    AFTER INSERT ON tracks
+BEGIN
+    INSERT INTO tracks_fts(track_id, title, artist, album, genre, original_filename, tags)
+    VALUES (NEW.id, NEW.title, NEW.artist, NEW.album, NEW.genre, NEW.original_filename, NULL);
+END;
+
+CREATE TRIGGER tracks_fts_update
+    AFTER UPDATE ON tracks
+BEGIN
+    DELETE FROM tracks_fts WHERE track_id = OLD.id;
+    INSERT INTO tracks_fts(track_id, title, artist, album, genre, original_filename, tags)
+    VALUES (NEW.id, NEW.title, NEW.artist, NEW.album, NEW.genre, NEW.original_filename,
+            (SELECT group_concat(g.name, ' ') FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = NEW.id));
+END;
+
+CREATE TRIGGER tracks_fts_delete
+    AFTER DELETE ON tracks
+BEGIN
+    DELETE FROM tracks_fts WHERE track_id = OLD.id;
+END;
+
+-- Keep the tags column in step with tagging, untagging and renames
+CREATE TRIGGER IF NOT EXISTS track_tags_fts_insert
+    AFTER INSERT ON track_tags
+BEGIN
+    UPDATE tracks_fts
+    SET tags = (SELECT group_concat(g.name, ' ') FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = NEW.track_id)
+    WHERE track_id = NEW.track_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS track_tags_fts_delete
+    AFTER DELETE ON track_tags
+BEGIN
+    UPDATE tracks_fts
+    SET tags = (SELECT group_concat(g.name, ' ') FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = OLD.track_id)
+    WHERE track_id = OLD.track_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_fts_rename
+    AFTER UPDATE OF name ON tags
+BEGIN
+    UPDATE tracks_fts
+    SET tags = (SELECT group_concat(g.name, ' ') FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = tracks_fts.track_id)
+    WHERE track_id IN (SELECT track_id FROM track_tags WHERE tag_id = NEW.id);
+END;
@@ -0,0 +1,18 @@
+-- Per-user star ratings, favourites and colour labels on tracks. They are
+-- kept apart from the tracks row because a track in a public crate is
+-- rated by everyone who plays it, not just its owner.
+CREATE TABLE IF NOT EXISTS track_ratings (
+    track_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    rating INTEGER NOT NULL DEFAULT 0,     -- 0 (unrated) to 5 stars
+    favourite BOOLEAN NOT NULL DEFAULT FALSE,
+    favourited_at DATETIME,                -- orders the Favourites crate
+    color TEXT,                            -- colour label name; NULL for none
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, track_id),
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_track_ratings_track ON track_ratings(track_id);
+CREATE INDEX IF NOT EXISTS idx_track_ratings_favourites ON track_ratings(user_id, favourited_at) WHERE favourite;
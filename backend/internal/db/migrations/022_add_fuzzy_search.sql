@@ -0,0 +1,39 @@
+-- Trigram index for typo-tolerant search and autocomplete. The trigram
+-- tokenizer indexes every three characters in a row, so "dmau" finds
+-- "deadmau5" and a misspelling still shares most of its trigrams with the
+-- real name; remove_diacritics folds accents, so "beyonce" finds "Beyoncé".
+-- remove_diacritics needs SQLite 3.45; on older versions migrate() drops
+-- it (see trigramTokenizer).
+CREATE VIRTUAL TABLE IF NOT EXISTS tracks_trigram USING fts5(
+    track_id UNINDEXED,
+    title,
+    artist,
+    album,
+    genre,
+    original_filename,
+    tokenize = 'trigram remove_diacritics 1'
+);
+
+INSERT INTO tracks_trigram(track_id, title, artist, album, genre, original_filename)
+SELECT id, title, artist, album, genre, original_filename FROM tracks;
+
+CREATE TRIGGER IF NOT EXISTS tracks_trigram_insert
+    AFTER INSERT ON tracks
+BEGIN
+    INSERT INTO tracks_trigram(track_id, title, artist, album, genre, original_filename)
+    VALUES (NEW.id, NEW.title, NEW.artist, NEW.album, NEW.genre, NEW.original_filename);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_trigram_update
+    AFTER UPDATE OF title, artist, album, genre, original_filename ON tracks
+BEGIN
+    DELETE FROM tracks_trigram WHERE track_id = OLD.id;
+    INSERT INTO tracks_trigram(track_id, title, artist, album, genre, original_filename)
+    VALUES (NEW.id, NEW.title, NEW.artist, NEW.album, NEW.genre, NEW.original_filename);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_trigram_delete
+    AFTER DELETE ON tracks
+BEGIN
+    DELETE FROM tracks_trigram WHERE track_id = OLD.id;
+END;
@@ -0,0 +1,6 @@
+-- Duplicate detection: the recording's ISRC from its tags or Spotify, and a
+-- Chromaprint fingerprint (fpcalc -raw, comma-separated) computed on demand
+ALTER TABLE tracks ADD COLUMN isrc TEXT;
+ALTER TABLE tracks ADD COLUMN fingerprint TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_isrc ON tracks(owner_user_id, isrc) WHERE isrc IS NOT NULL;
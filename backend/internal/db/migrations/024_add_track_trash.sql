@@ -0,0 +1,5 @@
+-- Soft delete: deleted tracks keep their row, crate memberships and analysis
+-- until the trash is purged. deleted_at is NULL for tracks in the library.
+ALTER TABLE tracks ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_deleted ON tracks(owner_user_id, deleted_at) WHERE deleted_at IS NOT NULL;
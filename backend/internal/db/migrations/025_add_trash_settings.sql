@@ -0,0 +1,7 @@
+-- Server-wide trash settings, set by admins; a single row, absent until the
+-- retention is first changed
+CREATE TABLE IF NOT EXISTS trash_settings (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    retention_days INTEGER NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -44,6 +44,24 @@ CREATE TABLE IF NOT EXISTS tracks (
     analysis_error TEXT,
     analysis_retry_count INTEGER NOT NULL DEFAULT 0,
     next_retry_at DATETIME,
+    bpm_backend TEXT,
+    key_backend TEXT,
+    energy INTEGER,
+    danceability REAL,
+    dynamic_complexity REAL,
+    loudness_lufs REAL,
+    spectral_centroid REAL,
+    onset_rate REAL,
+    mood TEXT,
+    bpm_raw REAL,
+    bpm_suggested REAL,
+    quality_verdict TEXT,
+    quality_confidence REAL,
+    spectral_cutoff_hz REAL,
+    quality_checked_at DATETIME,
+    isrc TEXT,
+    fingerprint TEXT,
+    deleted_at DATETIME,                      -- set while the track is in its owner's trash
     file_path TEXT NOT NULL,
     cover_path TEXT,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -70,6 +88,7 @@ CREATE TABLE IF NOT EXISTS playlists (
     description TEXT,
     is_default BOOLEAN NOT NULL DEFAULT FALSE,
     is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    rules TEXT,                               -- smart crate rules (JSON); NULL for normal crates
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
@@ -98,6 +117,18 @@ CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 CREATE INDEX IF NOT EXISTS idx_tracks_owner_created ON tracks(owner_user_id, created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_tracks_created_at ON tracks(created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_tracks_genre ON tracks(genre);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_energy ON tracks(owner_user_id, energy);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_danceability ON tracks(owner_user_id, danceability);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_quality ON tracks(owner_user_id, quality_verdict);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_bpm ON tracks(owner_user_id, bpm);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_key ON tracks(owner_user_id, musical_key);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_genre ON tracks(owner_user_id, genre COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_format ON tracks(owner_user_id, content_type);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_status ON tracks(owner_user_id, analysis_status);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_title ON tracks(owner_user_id, title COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_artist ON tracks(owner_user_id, artist COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_isrc ON tracks(owner_user_id, isrc) WHERE isrc IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tracks_owner_deleted ON tracks(owner_user_id, deleted_at) WHERE deleted_at IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_tracks_analysis_status
     ON tracks(analysis_status, next_retry_at);
 
@@ -109,6 +140,7 @@ CREATE INDEX IF NOT EXISTS idx_playlists_public_not_default ON playlists(is_publ
 
 -- Playlist tracks indexes
 CREATE INDEX IF NOT EXISTS idx_playlist_tracks_playlist_position ON playlist_tracks(playlist_id, position);
+CREATE INDEX IF NOT EXISTS idx_playlist_tracks_playlist_added ON playlist_tracks(playlist_id, added_at DESC);
 CREATE INDEX IF NOT EXISTS idx_playlist_tracks_track ON playlist_tracks(track_id);
 
 -- Refresh token indexes
@@ -11,6 +11,7 @@ type AudioMetadata struct {
     Year            *int
     SampleRate      *int
     Bitrate         *int
+    ISRC            *string
 }
 
 type Extractor interface {
@@ -99,6 +99,17 @@ func (e *FFProbeExtractor) Extract(ctx context.Context, fullPath string) (*metad
 			s := strings.TrimSpace(v)
 			md.Genre = &s
 		}
+		// ISRC: TSRC in ID3, ISRC in Vorbis comments; key case varies by format
+		for key, raw := range tags {
+			if k := strings.ToLower(key); k != "isrc" && k != "tsrc" {
+				continue
+			}
+			if v, ok := raw.(string); ok && strings.TrimSpace(v) != "" {
+				s := strings.ToUpper(strings.TrimSpace(v))
+				md.ISRC = &s
+				break
+			}
+		}
 		// Year: try common fields
 		for _, key := range []string{"date", "year", "creation_time"} {
 			if raw, ok := tags[key]; ok {
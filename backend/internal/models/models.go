@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID           string    `json:"id"`
@@ -28,15 +31,39 @@ type Track struct {
 	Bitrate          *int       `json:"bitrate,omitempty"`
 	BPM              *float64   `json:"bpm,omitempty"`
 	BPMConfidence    *float64   `json:"bpm_confidence,omitempty"`
+	BPMRaw           *float64   `json:"bpm_raw,omitempty"`       // before half/double-time correction
+	BPMSuggested     *float64   `json:"bpm_suggested,omitempty"` // probable octave correction awaiting the user
 	MusicalKey       *string    `json:"musical_key,omitempty"`
 	KeyConfidence    *float64   `json:"key_confidence,omitempty"`
 	AnalyzedAt       *time.Time `json:"analyzed_at,omitempty"`
 	AnalysisStatus   string     `json:"analysis_status"`
-	FilePath         string     `json:"file_path"`
-	CoverPath        *string    `json:"cover_path,omitempty"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-}
+	BPMBackend       *string    `json:"bpm_backend,omitempty"`
+	KeyBackend       *string    `json:"key_backend,omitempty"`
+	// Audio descriptors from essentia; see analysis.Descriptors.
+	Energy            *int     `json:"energy,omitempty"`
+	Danceability      *float64 `json:"danceability,omitempty"`
+	DynamicComplexity *float64 `json:"dynamic_complexity,omitempty"`
+	LoudnessLUFS      *float64 `json:"loudness_lufs,omitempty"`
+	SpectralCentroid  *float64 `json:"spectral_centroid,omitempty"`
+	OnsetRate         *float64 `json:"onset_rate,omitempty"`
+	Mood              *string  `json:"mood,omitempty"`
+	// Audio quality audit; see analysis.Quality.
+	QualityVerdict    *string   `json:"quality_verdict,omitempty"`
+	QualityConfidence *float64  `json:"quality_confidence,omitempty"`
+	SpectralCutoffHz  *float64  `json:"spectral_cutoff_hz,omitempty"`
+	ISRC              *string   `json:"isrc,omitempty"` // from tags or Spotify; stored on import for duplicate detection, not loaded by lists
+	FilePath          string    `json:"file_path"`
+	CoverPath         *string   `json:"cover_path,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// MaxRating is the most stars a user can give a track; 0 is unrated
+const MaxRating = 5
+
+// RatingColors are the colour labels a user can give a track, as in
+// Rekordbox
+var RatingColors = []string{"pink", "red", "orange", "yellow", "green", "aqua", "blue", "purple"}
 
 type RefreshToken struct {
 	ID        string     `json:"-"`
@@ -84,24 +111,61 @@ type APIError struct {
 	Message string `json:"message"`
 }
 
+// TrackList is a page of tracks. NextCursor, when there is a next page,
+// fetches it with cursor=. Facets is set when the request asked for facet
+// counts over the whole list. Fuzzy is set on search results when nothing
+// matched exactly and the tracks are close matches instead
 type TrackList struct {
-	Tracks  []*Track `json:"tracks"`
-	Total   int      `json:"total"`
-	Limit   int      `json:"limit"`
-	Offset  int      `json:"offset"`
-	HasNext bool     `json:"has_next"`
+	Tracks     []*Track `json:"tracks"`
+	Total      int      `json:"total"`
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+	HasNext    bool     `json:"has_next"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Facets     *Facets  `json:"facets,omitempty"`
+	Fuzzy      bool     `json:"fuzzy,omitempty"`
+}
+
+// Facets count a track list's tracks by genre, key and BPM bucket. Only
+// the facets asked for are set; tracks missing a value are not counted
+type Facets struct {
+	Genre []GenreFacet `json:"genre,omitempty"`
+	Key   []KeyFacet   `json:"key,omitempty"`
+	BPM   []BPMFacet   `json:"bpm,omitempty"`
 }
 
-// Playlist represents a music playlist
+type GenreFacet struct {
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+// KeyFacet is named like Track's key so responses convert it to the
+// user's key notation
+type KeyFacet struct {
+	MusicalKey string `json:"musical_key"`
+	Count      int    `json:"count"`
+}
+
+// BPMFacet counts tracks from Min up to (not including) Max BPM
+type BPMFacet struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// Playlist represents a music playlist. Smart playlists (IsSmart) hold the
+// tracks matching Rules rather than tracks added to them
 type Playlist struct {
-	ID          string    `json:"id"`
-	OwnerUserID string    `json:"owner_user_id"`
-	Name        string    `json:"name"`
-	Description *string   `json:"description,omitempty"`
-	IsDefault   bool      `json:"is_default"`
-	IsPublic    bool      `json:"is_public"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string          `json:"id"`
+	OwnerUserID string          `json:"owner_user_id"`
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	IsDefault   bool            `json:"is_default"`
+	IsPublic    bool            `json:"is_public"`
+	IsSmart     bool            `json:"is_smart"`
+	Rules       json.RawMessage `json:"rules,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
 }
 
 // PlaylistTrack represents the relationship between a playlist and a track
@@ -115,35 +179,47 @@ type PlaylistTrack struct {
 
 // PlaylistList represents a paginated list of playlists
 type PlaylistList struct {
-	Playlists []*Playlist `json:"playlists"`
-	Total     int         `json:"total"`
-	Limit     int         `json:"limit"`
-	Offset    int         `json:"offset"`
-	HasNext   bool        `json:"has_next"`
+	Playlists  []*Playlist `json:"playlists"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	HasNext    bool        `json:"has_next"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 // PlaylistWithTracks represents a playlist with its associated tracks
 type PlaylistWithTracks struct {
-	Playlist *Playlist `json:"playlist"`
-	Tracks   []*Track  `json:"tracks"`
-	Total    int       `json:"total"`
-	Limit    int       `json:"limit"`
-	Offset   int       `json:"offset"`
-	HasNext  bool      `json:"has_next"`
+	Playlist   *Playlist `json:"playlist"`
+	Tracks     []*Track  `json:"tracks"`
+	Total      int       `json:"total"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	HasNext    bool      `json:"has_next"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
-// CreatePlaylistRequest represents a request to create a playlist
+// CreatePlaylistRequest represents a request to create a playlist; with
+// Rules it creates a smart crate
 type CreatePlaylistRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Description *string `json:"description,omitempty"`
-	IsPublic    *bool   `json:"is_public,omitempty"`
+	Name        string          `json:"name" binding:"required"`
+	Description *string         `json:"description,omitempty"`
+	IsPublic    *bool           `json:"is_public,omitempty"`
+	Rules       json.RawMessage `json:"rules,omitempty"`
 }
 
-// UpdatePlaylistRequest represents a request to update a playlist
+// UpdatePlaylistRequest represents a request to update a playlist; Rules
+// replaces a smart crate's rules
 type UpdatePlaylistRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Description *string `json:"description,omitempty"`
-	IsPublic    *bool   `json:"is_public,omitempty"`
+	Name        string          `json:"name" binding:"required"`
+	Description *string         `json:"description,omitempty"`
+	IsPublic    *bool           `json:"is_public,omitempty"`
+	Rules       json.RawMessage `json:"rules,omitempty"`
+}
+
+// FreezePlaylistRequest represents a request to copy a smart crate's
+// current tracks into a new normal crate
+type FreezePlaylistRequest struct {
+	Name *string `json:"name,omitempty"`
 }
 
 // AddTracksToPlaylistRequest represents a request to add tracks to a playlist
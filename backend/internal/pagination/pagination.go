@@ -0,0 +1,200 @@
+// Package pagination pages through ordered lists with opaque cursors.
+//
+// A cursor holds the sort key values of the last row on a page, so the
+// next page starts right after that row however many rows come before it,
+// and rows added or removed elsewhere in the list don't shift it. Every
+// list's keys end with a unique column, usually the id, so each row has a
+// place of its own.
+package pagination
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// ErrInvalidCursor is a cursor that can't be read, or was made for a list
+// in a different order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Key is one term of a list's order: an SQL expression, with any COLLATE,
+// and its direction. Args are bound to the expression's placeholders
+// wherever it appears.
+type Key struct {
+	Expr string
+	Desc bool
+	Args []any
+}
+
+// Args returns the arguments of keys rendered with OrderBy or Columns, in
+// order.
+func Args(keys []Key) []any {
+	var args []any
+	for _, k := range keys {
+		args = append(args, k.Args...)
+	}
+	return args
+}
+
+// OrderBy renders keys as an ORDER BY list.
+func OrderBy(keys []Key) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Expr + " ASC"
+		if k.Desc {
+			parts[i] = k.Expr + " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Columns returns the keys as extra select columns, each starting with
+// ", ", for a Scanner to read. The unary plus keeps the stored value: the
+// driver would otherwise turn DATETIME columns into time.Time, which
+// doesn't compare equal to the text in the database.
+func Columns(keys []Key) string {
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(", +(" + k.Expr + ")")
+	}
+	return b.String()
+}
+
+// QueryArgs returns the arguments of a query that selects Columns(keys),
+// then has FROM and WHERE clauses taking args, then ORDER BY
+// OrderBy(keys), then clauses taking rest, such as LIMIT and OFFSET.
+func QueryArgs(keys []Key, args []any, rest ...any) []any {
+	keyArgs := Args(keys)
+	out := make([]any, 0, 2*len(keyArgs)+len(args)+len(rest))
+	out = append(append(out, keyArgs...), args...)
+	return append(append(out, keyArgs...), rest...)
+}
+
+// cursor is a decoded cursor token.
+type cursor struct {
+	// Order identifies the keys the cursor was made for.
+	Order  string `json:"o"`
+	Values []any  `json:"v"`
+}
+
+// signature identifies a list's order, so a cursor from one order isn't
+// used with another.
+func signature(keys []Key) string {
+	h := fnv.New32a()
+	h.Write([]byte(OrderBy(keys)))
+	fmt.Fprint(h, Args(keys)...)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Encode makes the cursor for the row whose keys have values.
+func Encode(keys []Key, values []any) string {
+	b, _ := json.Marshal(cursor{Order: signature(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode reads a cursor token made by Encode for keys.
+func decode(token string, keys []Key) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a cursor", ErrInvalidCursor)
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var c cursor
+	if err := d.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: not a cursor", ErrInvalidCursor)
+	}
+	if c.Order != signature(keys) || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: the cursor is for a different sort order", ErrInvalidCursor)
+	}
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				c.Values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i] = f
+			} else {
+				return nil, fmt.Errorf("%w: bad number", ErrInvalidCursor)
+			}
+		case string, bool, nil:
+		default:
+			return nil, fmt.Errorf("%w: bad value", ErrInvalidCursor)
+		}
+	}
+	return c.Values, nil
+}
+
+// After returns the condition, starting with " AND ", that selects the
+// rows after token's in the order of keys, and its arguments. An empty
+// token selects every row. Rows compare key by key, each key in
+// parentheses so that "x IS NULL" keys compare whole; IS treats NULLs as
+// equal, and a NULL never sorts before or after anything here, so keys
+// that can be NULL need an "expr IS NULL" key before them.
+func After(token string, keys []Key) (string, []any, error) {
+	if token == "" {
+		return "", nil, nil
+	}
+	values, err := decode(token, keys)
+	if err != nil {
+		return "", nil, err
+	}
+	var ors []string
+	var args []any
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, "("+keys[j].Expr+") IS ?")
+			args = append(args, keys[j].Args...)
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if k.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, "("+k.Expr+")"+op)
+		args = append(args, k.Args...)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return " AND (" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+// Scanner reads rows selected with Columns(keys) at the end, remembering
+// each row's key values. Scan takes the row's other columns as usual.
+type Scanner struct {
+	rows   *sql.Rows
+	keys   []Key
+	values [][]any
+}
+
+func NewScanner(rows *sql.Rows, keys []Key) *Scanner {
+	return &Scanner{rows: rows, keys: keys}
+}
+
+func (s *Scanner) Scan(dest ...any) error {
+	values := make([]any, len(s.keys))
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := s.rows.Scan(dest...); err != nil {
+		return err
+	}
+	s.values = append(s.values, values)
+	return nil
+}
+
+// Next returns the cursor for the page after the first limit rows, or ""
+// if there were no more rows than that. Lists fetch limit+1 rows to find
+// out.
+func (s *Scanner) Next(limit int) string {
+	if len(s.values) <= limit || limit < 1 {
+		return ""
+	}
+	return Encode(s.keys, s.values[limit-1])
+}
@@ -0,0 +1,105 @@
+package library
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+	"github.com/faraz525/home-music-server/backend/search"
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, pagination.ErrInvalidCursor):
+		return http.StatusBadRequest, "invalid_cursor"
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+// ListGroups lists the user's groups of one kind.
+func (h *Handlers) ListGroups(kindName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		offset, _ := strconv.Atoi(c.Query("offset"))
+		list, err := h.manager.List(c.Request.Context(), kindName, c.GetString("user_id"), &ListOptions{
+			Query:  c.Query("q"),
+			Sort:   c.Query("sort"),
+			Artist: c.Query("artist"),
+			Limit:  limit,
+			Offset: offset,
+		})
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// GetGroup returns one of the user's groups of one kind.
+func (h *Handlers) GetGroup(kindName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := h.manager.Get(c.Request.Context(), kindName, c.GetString("user_id"), c.Param("id"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"item": group})
+	}
+}
+
+func (h *Handlers) GetArtist(c *gin.Context) {
+	detail, err := h.manager.Artist(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// ListGroupTracks lists the tracks in one of the user's groups, taking the
+// same paging, filter and sort parameters as GET /tracks.
+func (h *Handlers) ListGroupTracks(kindName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		filter, err := search.ParseListFilter(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_filter", "message": err.Error()}})
+			return
+		}
+		list, err := h.manager.Tracks(c.Request.Context(), kindName, c.GetString("user_id"), c.Param("id"), filter, limit, offset)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
@@ -0,0 +1,209 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// The kinds of group the library can be browsed by.
+const (
+	KindArtists = "artists"
+	KindAlbums  = "albums"
+	KindGenres  = "genres"
+	KindYears   = "years"
+)
+
+// CoverSize is the thumbnail size groups' cover URLs ask for.
+const CoverSize = 256
+
+// Page sizes for group lists.
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// ListOptions narrows and pages a list of groups.
+type ListOptions struct {
+	// Query keeps names containing it, ignoring case. Years ignore it.
+	Query string
+	// Sort is "name" (the default; newest first for years) or "tracks",
+	// most tracks first.
+	Sort string
+	// Artist keeps groups with tracks by that artist, ignoring case.
+	Artist string
+	Limit  int
+	Offset int
+}
+
+// GroupList is a page of groups.
+type GroupList struct {
+	Items   []*Group `json:"items"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	HasNext bool     `json:"has_next"`
+}
+
+// ArtistDetail is an artist with their albums.
+type ArtistDetail struct {
+	Artist *Group   `json:"artist"`
+	Albums []*Group `json:"albums"`
+}
+
+// TrackLister lists a user's tracks narrowed by a filter;
+// tracks.Manager.GetTracks.
+type TrackLister interface {
+	GetTracks(ctx context.Context, userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error)
+}
+
+// Manager browses a user's library by artist, album, genre and year, and
+// lists the tracks in each group. Groups are built from the tracks' tags
+// on the fly, so they follow edits without any bookkeeping.
+type Manager struct {
+	repo   *Repository
+	tracks TrackLister
+}
+
+func NewManager(repo *Repository, tracks TrackLister) *Manager {
+	return &Manager{repo: repo, tracks: tracks}
+}
+
+// checkKind reports ErrNotFound for kinds the library doesn't browse by.
+func checkKind(kindName string) error {
+	if _, ok := kinds[kindName]; !ok {
+		return fmt.Errorf("%w: unknown kind %q", ErrNotFound, kindName)
+	}
+	return nil
+}
+
+// List returns a page of the user's groups of one kind.
+func (m *Manager) List(ctx context.Context, kindName, userID string, opts *ListOptions) (*GroupList, error) {
+	if err := checkKind(kindName); err != nil {
+		return nil, err
+	}
+	o := *opts
+	o.Query = strings.TrimSpace(o.Query)
+	switch o.Sort {
+	case "", "name", "tracks":
+	default:
+		return nil, fmt.Errorf("%w: sort must be name or tracks", ErrInvalidRequest)
+	}
+	if o.Limit < 1 || o.Limit > maxLimit {
+		o.Limit = defaultLimit
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+
+	groups, total, err := m.repo.ListGroups(ctx, kindName, userID, &o)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", kindName, err)
+	}
+	for _, g := range groups {
+		setID(kindName, g)
+	}
+	return &GroupList{
+		Items:   groups,
+		Total:   total,
+		Limit:   o.Limit,
+		Offset:  o.Offset,
+		HasNext: o.Offset+o.Limit < total,
+	}, nil
+}
+
+// setID fills in a group's ID and leaves out the fields that don't apply
+// to its kind.
+func setID(kindName string, g *Group) {
+	switch kindName {
+	case KindArtists:
+		g.ArtistCount, g.Artist, g.Year = 0, "", 0
+	case KindAlbums:
+		g.AlbumCount = 0
+	case KindGenres:
+		g.Artist, g.Year = "", 0
+	case KindYears:
+		g.Artist = ""
+	}
+	g.ID = GroupID(g.Name)
+}
+
+// name decodes a group ID, checking its kind too. Years must be numbers.
+func name(kindName, id string) (string, error) {
+	if err := checkKind(kindName); err != nil {
+		return "", err
+	}
+	n, ok := parseGroupID(id)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid id %q", ErrNotFound, id)
+	}
+	if kindName == KindYears {
+		if _, err := strconv.Atoi(n); err != nil {
+			return "", fmt.Errorf("%w: invalid year %q", ErrNotFound, n)
+		}
+	}
+	return n, nil
+}
+
+// Get returns one of the user's groups by ID.
+func (m *Manager) Get(ctx context.Context, kindName, userID, id string) (*Group, error) {
+	n, err := name(kindName, id)
+	if err != nil {
+		return nil, err
+	}
+	g, err := m.repo.GetGroup(ctx, kindName, userID, n)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", kindName, err)
+	}
+	if g == nil {
+		return nil, ErrNotFound
+	}
+	setID(kindName, g)
+	return g, nil
+}
+
+// Artist returns one of the user's artists with all of their albums.
+func (m *Manager) Artist(ctx context.Context, userID, id string) (*ArtistDetail, error) {
+	artist, err := m.Get(ctx, KindArtists, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	albums, err := m.List(ctx, KindAlbums, userID, &ListOptions{Artist: artist.Name, Limit: maxLimit})
+	if err != nil {
+		return nil, err
+	}
+	return &ArtistDetail{Artist: artist, Albums: albums.Items}, nil
+}
+
+// Tracks lists the user's tracks in one group, narrowed and ordered by f.
+// The group replaces any filter f has on the same field.
+func (m *Manager) Tracks(ctx context.Context, kindName, userID, id string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	n, err := name(kindName, id)
+	if err != nil {
+		return nil, err
+	}
+	g := *f
+	g.UserID = userID
+	switch kindName {
+	case KindArtists:
+		g.Artist = n
+	case KindAlbums:
+		g.Album = n
+	case KindGenres:
+		g.Genres = []string{n}
+	case KindYears:
+		year, _ := strconv.Atoi(n)
+		g.Year = &year
+	}
+	return m.tracks.GetTracks(ctx, userID, &g, limit, offset)
+}
@@ -0,0 +1,174 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fakeTracks records the filter the manager asks for.
+type fakeTracks struct {
+	filter *search.ListFilter
+}
+
+func (f *fakeTracks) GetTracks(ctx context.Context, userID string, filter *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	f.filter = filter
+	return &imodels.TrackList{Limit: limit, Offset: offset}, nil
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeTracks) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, artist TEXT, album TEXT,
+			genre TEXT, year INTEGER, cover_path TEXT, deleted_at DATETIME
+		);
+		INSERT INTO tracks (id, owner_user_id, artist, album, genre, year, cover_path) VALUES
+			('t1', 'dj', 'Burial', 'Untrue', 'Dubstep', 2007, '/c/t1.jpg'),
+			('t2', 'dj', 'burial', 'Untrue', 'dubstep', 2007, NULL),
+			('t3', 'dj', 'Burial', 'Rival Dealer', 'Dubstep', 2013, NULL),
+			('t4', 'dj', 'Four Tet', 'Rounds', 'Electronic', 2003, NULL),
+			('t5', 'dj', 'Various', 'Untrue', '', NULL, NULL),
+			('t6', 'dj', NULL, NULL, NULL, NULL, NULL),
+			('t7', 'other', 'Burial', 'Untrue', 'Dubstep', 2007, NULL);
+		-- In the trash, so in no group.
+		INSERT INTO tracks (id, owner_user_id, artist, album, genre, year, deleted_at) VALUES
+			('t8', 'dj', 'Aphex Twin', 'Drukqs', 'Electronic', 2001, '2024-01-01 00:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	tracks := &fakeTracks{}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB}), tracks), tracks
+}
+
+func TestListArtists(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	list, err := m.List(ctx, KindArtists, "dj", &ListOptions{Sort: "tracks"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if list.Total != 3 || len(list.Items) != 3 {
+		t.Fatalf("artists = %d of %d, want 3", len(list.Items), list.Total)
+	}
+	burial := list.Items[0]
+	if burial.TrackCount != 3 || burial.AlbumCount != 2 {
+		t.Errorf("Burial = %+v, want 3 tracks on 2 albums", burial)
+	}
+	if burial.CoverURL != "/api/tracks/t1/cover?size=256" {
+		t.Errorf("cover = %q", burial.CoverURL)
+	}
+
+	list, err = m.List(ctx, KindArtists, "dj", &ListOptions{Query: "tet"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if list.Total != 1 || list.Items[0].Name != "Four Tet" {
+		t.Errorf("q=tet = %+v", list.Items)
+	}
+
+	list, err = m.List(ctx, KindArtists, "dj", &ListOptions{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "Four Tet" || !list.HasNext {
+		t.Errorf("second page = %+v (has_next %v)", list.Items, list.HasNext)
+	}
+
+	if _, err := m.List(ctx, KindArtists, "dj", &ListOptions{Sort: "plays"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("sort=plays: err = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := m.List(ctx, "labels", "dj", &ListOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown kind: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListAlbums(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	list, err := m.List(context.Background(), KindAlbums, "dj", &ListOptions{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	byName := map[string]*Group{}
+	for _, g := range list.Items {
+		byName[g.Name] = g
+	}
+	untrue := byName["Untrue"]
+	if untrue == nil || untrue.TrackCount != 3 || untrue.ArtistCount != 2 || untrue.Artist != "" {
+		t.Errorf("Untrue = %+v, want 3 tracks by 2 artists", untrue)
+	}
+	if rival := byName["Rival Dealer"]; rival == nil || rival.Artist != "Burial" || rival.Year != 2013 {
+		t.Errorf("Rival Dealer = %+v", rival)
+	}
+}
+
+func TestListYears(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	list, err := m.List(context.Background(), KindYears, "dj", &ListOptions{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var years []string
+	for _, g := range list.Items {
+		years = append(years, g.Name)
+	}
+	if len(years) != 3 || years[0] != "2013" || years[2] != "2003" {
+		t.Errorf("years = %v, want newest first", years)
+	}
+}
+
+func TestArtistAndTracks(t *testing.T) {
+	m, tracks := newTestManager(t)
+	ctx := context.Background()
+
+	detail, err := m.Artist(ctx, "dj", GroupID("BURIAL"))
+	if err != nil {
+		t.Fatalf("artist: %v", err)
+	}
+	if detail.Artist.TrackCount != 3 || len(detail.Albums) != 2 {
+		t.Errorf("artist = %+v with %d albums", detail.Artist, len(detail.Albums))
+	}
+	if _, err := m.Artist(ctx, "dj", GroupID("Aphex Twin")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing artist: err = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Artist(ctx, "dj", "!!"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("bad id: err = %v, want ErrNotFound", err)
+	}
+
+	// The group replaces the same filter from the query string.
+	f := &search.ListFilter{Genres: []string{"House"}, Sort: "bpm"}
+	if _, err := m.Tracks(ctx, KindGenres, "dj", GroupID("dubstep"), f, 20, 0); err != nil {
+		t.Fatalf("genre tracks: %v", err)
+	}
+	if got := tracks.filter; len(got.Genres) != 1 || got.Genres[0] != "dubstep" || got.Sort != "bpm" || got.UserID != "dj" {
+		t.Errorf("filter = %+v", got)
+	}
+	if len(f.Genres) != 1 || f.Genres[0] != "House" {
+		t.Errorf("caller's filter changed: %+v", f)
+	}
+
+	if _, err := m.Tracks(ctx, KindYears, "dj", GroupID("2007"), &search.ListFilter{}, 20, 0); err != nil {
+		t.Fatalf("year tracks: %v", err)
+	}
+	if tracks.filter.Year == nil || *tracks.filter.Year != 2007 {
+		t.Errorf("year filter = %v", tracks.filter.Year)
+	}
+	if _, err := m.Tracks(ctx, KindYears, "dj", GroupID("last year"), &search.ListFilter{}, 20, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("bad year: err = %v, want ErrNotFound", err)
+	}
+}
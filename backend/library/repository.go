@@ -0,0 +1,173 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Group is one artist, album, genre or year in a user's library. Fields
+// that don't apply to a kind of group are left out.
+type Group struct {
+	// ID is the name, URL-safe, for the drill-down endpoints.
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	TrackCount  int    `json:"track_count"`
+	ArtistCount int    `json:"artist_count,omitempty"`
+	AlbumCount  int    `json:"album_count,omitempty"`
+	// Artist is an album's artist, when all its tracks share one.
+	Artist string `json:"artist,omitempty"`
+	// Year is an album's latest year.
+	Year int `json:"year,omitempty"`
+	// CoverURL is a thumbnail of one of the group's covers.
+	CoverURL string `json:"cover_url,omitempty"`
+}
+
+// GroupID and parseGroupID map a group's name to and from its ID. Names
+// can hold slashes and other characters paths can't, so IDs are base64.
+func GroupID(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func parseGroupID(id string) (string, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(b) == 0 {
+		return "", false
+	}
+	return string(b), true
+}
+
+// kind describes how to group tracks by one column.
+type kind struct {
+	column string
+	// group is the GROUP BY expression; text ignores case.
+	group string
+	// known excludes tracks missing the value.
+	known string
+	// text kinds can be searched by name.
+	text bool
+}
+
+var kinds = map[string]kind{
+	KindArtists: {column: "t.artist", group: "t.artist COLLATE NOCASE", known: "t.artist IS NOT NULL AND t.artist != ''", text: true},
+	KindAlbums:  {column: "t.album", group: "t.album COLLATE NOCASE", known: "t.album IS NOT NULL AND t.album != ''", text: true},
+	KindGenres:  {column: "t.genre", group: "t.genre COLLATE NOCASE", known: "t.genre IS NOT NULL AND t.genre != ''", text: true},
+	KindYears:   {column: "t.year", group: "t.year", known: "t.year > 0"},
+}
+
+// groupQuery is the grouped selection shared by lists and lookups.
+func groupQuery(k kind, where string) string {
+	return `
+		SELECT MIN(` + k.column + `), COUNT(*),
+		       COUNT(DISTINCT lower(t.artist)), COUNT(DISTINCT lower(t.album)),
+		       CASE WHEN COUNT(DISTINCT lower(t.artist)) = 1 THEN MIN(t.artist) END,
+		       MAX(t.year),
+		       MAX(CASE WHEN t.cover_path IS NOT NULL AND t.cover_path != '' THEN t.id END)
+		FROM tracks t
+		WHERE ` + where + `
+		GROUP BY ` + k.group
+}
+
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
+// where builds the condition for a user's groups of kind k, narrowed by
+// opts.
+func (k kind) where(userID string, opts *ListOptions) (string, []any) {
+	where := "t.owner_user_id = ? AND t.deleted_at IS NULL AND " + k.known
+	args := []any{userID}
+	if opts.Query != "" && k.text {
+		where += " AND " + k.column + ` LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(opts.Query))
+	}
+	if opts.Artist != "" {
+		where += " AND t.artist = ? COLLATE NOCASE"
+		args = append(args, opts.Artist)
+	}
+	return where, args
+}
+
+func scanGroups(rows *sql.Rows) ([]*Group, error) {
+	groups := []*Group{}
+	for rows.Next() {
+		var g Group
+		var artist, coverTrackID sql.NullString
+		var year sql.NullInt64
+		if err := rows.Scan(&g.Name, &g.TrackCount, &g.ArtistCount, &g.AlbumCount, &artist, &year, &coverTrackID); err != nil {
+			return nil, err
+		}
+		g.Artist = artist.String
+		g.Year = int(year.Int64)
+		if coverTrackID.Valid {
+			g.CoverURL = "/api/tracks/" + coverTrackID.String + "/cover?size=" + strconv.Itoa(CoverSize)
+		}
+		groups = append(groups, &g)
+	}
+	return groups, rows.Err()
+}
+
+// ListGroups returns a page of a user's groups of one kind, and how many
+// there are in all.
+func (r *Repository) ListGroups(ctx context.Context, kindName, userID string, opts *ListOptions) ([]*Group, int, error) {
+	k := kinds[kindName]
+	where, args := k.where(userID, opts)
+
+	order := "MIN(" + k.column + ") COLLATE NOCASE"
+	switch {
+	case opts.Sort == "tracks":
+		order = "COUNT(*) DESC, " + order
+	case kindName == KindYears:
+		order = "MIN(t.year) DESC"
+	}
+	rows, err := r.db.QueryContext(ctx, groupQuery(k, where)+`
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, append(args, opts.Limit, opts.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	groups, err := scanGroups(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT 1 FROM tracks t WHERE `+where+` GROUP BY `+k.group+`)`, args...).Scan(&total)
+	return groups, total, err
+}
+
+// GetGroup returns a user's group of one kind with the given name, or nil
+// if they have no tracks in it.
+func (r *Repository) GetGroup(ctx context.Context, kindName, userID, name string) (*Group, error) {
+	k := kinds[kindName]
+	where, args := k.where(userID, &ListOptions{})
+	if k.text {
+		where += " AND " + k.column + " = ? COLLATE NOCASE"
+	} else {
+		where += " AND " + k.column + " = ?"
+	}
+	rows, err := r.db.QueryContext(ctx, groupQuery(k, where), append(args, name)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups, err := scanGroups(rows)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	return groups[0], nil
+}
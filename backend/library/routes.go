@@ -0,0 +1,25 @@
+package library
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/library")
+		{
+			r.GET("/artists", handlers.ListGroups(KindArtists))
+			r.GET("/artists/:id", handlers.GetArtist)
+			r.GET("/artists/:id/tracks", handlers.ListGroupTracks(KindArtists))
+			r.GET("/albums", handlers.ListGroups(KindAlbums))
+			r.GET("/albums/:id", handlers.GetGroup(KindAlbums))
+			r.GET("/albums/:id/tracks", handlers.ListGroupTracks(KindAlbums))
+			r.GET("/genres", handlers.ListGroups(KindGenres))
+			r.GET("/genres/:id", handlers.GetGroup(KindGenres))
+			r.GET("/genres/:id/tracks", handlers.ListGroupTracks(KindGenres))
+			r.GET("/years", handlers.ListGroups(KindYears))
+			r.GET("/years/:id", handlers.GetGroup(KindYears))
+			r.GET("/years/:id/tracks", handlers.ListGroupTracks(KindYears))
+		}
+	}
+}
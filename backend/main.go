@@ -10,16 +10,30 @@ import (
 
 	"github.com/faraz525/home-music-server/backend/analysis"
 	"github.com/faraz525/home-music-server/backend/auth"
+	"github.com/faraz525/home-music-server/backend/cues"
+	"github.com/faraz525/home-music-server/backend/duplicates"
 	"github.com/faraz525/home-music-server/backend/internal/config"
 	idb "github.com/faraz525/home-music-server/backend/internal/db"
 	mlocal "github.com/faraz525/home-music-server/backend/internal/media/metadata/local"
 	slocal "github.com/faraz525/home-music-server/backend/internal/storage/local"
+	"github.com/faraz525/home-music-server/backend/library"
+	"github.com/faraz525/home-music-server/backend/mixes"
 	"github.com/faraz525/home-music-server/backend/monochrome"
 	"github.com/faraz525/home-music-server/backend/playlists"
+	"github.com/faraz525/home-music-server/backend/radio"
+	"github.com/faraz525/home-music-server/backend/ratings"
+	"github.com/faraz525/home-music-server/backend/rooms"
+	"github.com/faraz525/home-music-server/backend/sequence"
 	"github.com/faraz525/home-music-server/backend/server"
+	"github.com/faraz525/home-music-server/backend/settings"
+	"github.com/faraz525/home-music-server/backend/similar"
 	"github.com/faraz525/home-music-server/backend/soundcloud"
 	"github.com/faraz525/home-music-server/backend/spotify"
+	"github.com/faraz525/home-music-server/backend/suggest"
+	"github.com/faraz525/home-music-server/backend/tags"
+	"github.com/faraz525/home-music-server/backend/tempo"
 	"github.com/faraz525/home-music-server/backend/tracks"
+	"github.com/faraz525/home-music-server/backend/trash"
 )
 
 func main() {
@@ -97,12 +111,90 @@ func main() {
 
 	// Initialize analysis (BPM + key detection)
 	analysisRepo := analysis.NewRepository(db.DB)
-	analyzer := analysis.NewAnalyzer(90 * time.Second)
-	analysisManager := analysis.NewManager(analysisRepo, analyzer)
-	if analysis.BinaryAvailable() {
-		fmt.Printf("[CrateDrop] Analysis worker enabled (essentia binary found)\n")
+	analysisChain, err := analysis.NewChain(analysis.ParseBackendNames(cfg.AnalysisBackends), 90*time.Second)
+	if err != nil {
+		log.Fatalf("[CrateDrop] Invalid ANALYSIS_BACKENDS: %v", err)
+	}
+	analysisLimits := analysis.Limits{Nice: cfg.AnalysisNice, IdleIO: cfg.AnalysisIdleIO, CPUSeconds: cfg.AnalysisCPUSecs}
+	analysisChain.SetLimits(analysisLimits)
+	analysisManager := analysis.NewManager(analysisRepo, analysisChain)
+	analysisManager.SetGridDetector(analysis.NewGridDetector(5*time.Minute, analysisLimits))
+	analysisManager.SetQualityAuditor(analysis.NewQualityAuditor(5*time.Minute, analysisLimits))
+	analysisManager.SetPathResolver(storage.ResolveFullPath)
+	analysisWorkers := cfg.AnalysisWorkers
+	if analysisWorkers <= 0 {
+		analysisWorkers = analysis.DefaultWorkers()
+	}
+	var availableBackends []string
+	for _, b := range analysisChain.Backends() {
+		if b.Available() {
+			availableBackends = append(availableBackends, b.Name())
+		}
+	}
+	if len(availableBackends) > 0 {
+		fmt.Printf("[CrateDrop] Analysis worker enabled (backends: %s)\n", strings.Join(availableBackends, ", "))
 	} else {
-		fmt.Printf("[CrateDrop] WARNING: streaming_extractor_music not on PATH — analysis disabled\n")
+		fmt.Printf("[CrateDrop] WARNING: no analysis backend installed — tracks stay pending until one is\n")
+	}
+
+	// Initialize internet radio (continuous crate broadcasts)
+	radioRepo := radio.NewRepository(db)
+	radioManager := radio.NewManager(radioRepo, storage)
+	fmt.Printf("[CrateDrop] Radio manager initialized\n")
+
+	// Initialize listening rooms (synchronized group playback)
+	roomsRepo := rooms.NewRepository(db)
+	roomsManager := rooms.NewManager(roomsRepo, playlistsManager, storage)
+	fmt.Printf("[CrateDrop] Rooms manager initialized\n")
+
+	// Initialize per-user hot cues, memory cues and loops
+	cuesManager := cues.NewManager(cues.NewRepository(db))
+
+	// Initialize per-user track tags
+	tagsManager := tags.NewManager(tags.NewRepository(db))
+
+	// Initialize per-user ratings, favourites and colour labels
+	ratingsManager := ratings.NewManager(ratings.NewRepository(db))
+
+	// Initialize library browsing by artist, album, genre and year
+	libraryManager := library.NewManager(library.NewRepository(db), tracksManager)
+
+	// Initialize search autocomplete
+	suggestManager := suggest.NewManager(suggest.NewRepository(db))
+
+	// Initialize per-user tempo ranges and half/double-time corrections
+	tempoManager := tempo.NewManager(tempo.NewRepository(db))
+
+	// Initialize crate set ordering
+	sequenceManager := sequence.NewManager(sequence.NewRepository(db))
+
+	// Initialize the sonic similarity index, rebuilt as analysis lands
+	similarManager := similar.NewManager(similar.NewRepository(db), similar.IndexFilePath(cfg.DataDir))
+	analysisManager.SetAnalyzedHook(similarManager.Notify)
+
+	// Initialize duplicate detection and merging; fingerprinting needs fpcalc
+	duplicatesManager := duplicates.NewManager(duplicates.NewRepository(db), tracksManager, duplicates.NewFPCalc())
+
+	// Initialize the per-user trash; deleted tracks wait there until purged
+	trashManager := trash.NewManager(trash.NewRepository(db), tracksManager, cfg.TrashRetention)
+
+	// Initialize per-user settings (key notation)
+	settingsManager := settings.NewManager(settings.NewRepository(db))
+
+	// Initialize mix rendering (continuous crate mixes via ffmpeg)
+	mixesRepo := mixes.NewRepository(db)
+	mixesManager := mixes.NewManager(mixesRepo, storage, cfg.DataDir)
+	fmt.Printf("[CrateDrop] Mix renderer initialized\n")
+
+	// Hold analysis while anything is playing, so the Pi's cores go to
+	// streaming first. Library streams count for two minutes after the last
+	// range request, since players fetch a track in bursts.
+	if cfg.AnalysisYield {
+		analysisManager.SetBusyCheck(func() bool {
+			return tracksManager.StreamingWithin(2*time.Minute) ||
+				radioManager.ListenerCount() > 0 ||
+				roomsManager.PlayingCount() > 0
+		})
 	}
 
 	// Initialize router and API group
@@ -112,19 +204,35 @@ func main() {
 	auth.Routes(authManager)(api)
 	protected := api.Group("")
 	protected.Use(auth.AuthMiddleware())
+	protected.Use(settings.KeyNotationMiddleware(settingsManager))
 	tracks.Routes(tracksManager, playlistsManager)(protected)
 	playlists.Routes(playlistsManager)(protected)
 	soundcloud.Routes(soundcloudManager)(protected)
 	spotify.Routes(spotifyManager)(protected)
+	radio.Routes(radioManager)(protected)
+	rooms.Routes(roomsManager)(protected)
+	mixes.Routes(mixesManager)(protected)
+	cues.Routes(cuesManager)(protected)
+	tags.Routes(tagsManager)(protected)
+	ratings.Routes(ratingsManager)(protected)
+	library.Routes(libraryManager)(protected)
+	suggest.Routes(suggestManager)(protected)
+	tempo.Routes(tempoManager)(protected)
+	sequence.Routes(sequenceManager)(protected)
+	similar.Routes(similarManager)(protected)
+	duplicates.Routes(duplicatesManager)(protected)
+	trash.Routes(trashManager)(protected)
+	settings.Routes(settingsManager)(protected)
+	analysis.Routes(analysisManager, analysisChain)(protected)
 
 	// Start sync loops in background
 	ctx := context.Background()
 	go soundcloud.StartSyncLoop(ctx, soundcloudManager)
 	go spotify.StartSyncLoop(ctx, spotifyManager)
-
-	if analysis.BinaryAvailable() {
-		go analysis.StartLoop(ctx, analysisManager, 10*time.Second)
-	}
+	go mixes.StartLoop(ctx, mixesManager, time.Minute)
+	go analysis.StartLoop(ctx, analysisManager, 10*time.Second, analysisWorkers)
+	go similar.StartLoop(ctx, similarManager, 10*time.Minute)
+	go trash.StartLoop(ctx, trashManager, time.Hour)
 
 	addr := "0.0.0.0:" + cfg.Port
 	fmt.Printf("[CrateDrop] Server listening on http://%s\n", addr)
@@ -0,0 +1,132 @@
+package mixes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound, "mix_not_found"
+	case errors.Is(err, ErrCrateNotFound):
+		return http.StatusNotFound, "crate_not_found"
+	case errors.Is(err, ErrJobNotReady):
+		return http.StatusConflict, "mix_not_ready"
+	case errors.Is(err, ErrEmptyCrate):
+		return http.StatusUnprocessableEntity, "empty_crate"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrFFmpegMissing):
+		return http.StatusServiceUnavailable, "ffmpeg_missing"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+func (h *Handlers) CreateMix(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	job, err := h.manager.Create(c.Request.Context(), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"mix": job})
+}
+
+func (h *Handlers) ListMixes(c *gin.Context) {
+	jobs, err := h.manager.List(c.Request.Context(), c.GetString("user_id"), c.GetString("user_role"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mixes": jobs})
+}
+
+func (h *Handlers) GetMix(c *gin.Context) {
+	job, err := h.manager.Get(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mix": job})
+}
+
+func (h *Handlers) DeleteMix(c *gin.Context) {
+	if err := h.manager.Delete(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "mix deleted"})
+}
+
+// downloadName is "<crate name> (mix)" with the format's extension.
+func (h *Handlers) downloadName(c *gin.Context, job *Job, ext string) string {
+	name := h.manager.CrateName(c.Request.Context(), job)
+	name = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "", "?", "", "\"", "'", "<", "", ">", "", "|", "-").Replace(name)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "mix"
+	}
+	return fmt.Sprintf("%s (mix).%s", name, ext)
+}
+
+func (h *Handlers) DownloadMix(c *gin.Context) {
+	job, err := h.manager.Get(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"))
+	if err == nil && (job.Status != StatusDone || job.OutputPath == "") {
+		err = ErrJobNotReady
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	contentType := "audio/flac"
+	if job.Format == FormatMP3 {
+		contentType = "audio/mpeg"
+	}
+	c.Header("Content-Type", contentType)
+	c.FileAttachment(filepath.Clean(job.OutputPath), h.downloadName(c, job, job.Format))
+}
+
+// CueSheet serves the mix's tracklist as a .cue file. It is available as soon
+// as rendering starts, since the tracklist is planned up front.
+func (h *Handlers) CueSheet(c *gin.Context) {
+	job, err := h.manager.Get(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"))
+	if err == nil && len(job.Tracklist) == 0 {
+		err = ErrJobNotReady
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	title := h.manager.CrateName(c.Request.Context(), job)
+	fileName := h.downloadName(c, job, job.Format)
+	cue := cueSheet(title, fileName, job.Format, job.Tracklist)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", strings.TrimSuffix(fileName, "."+job.Format)+".cue"))
+	c.Data(http.StatusOK, "application/x-cue; charset=utf-8", []byte(cue))
+}
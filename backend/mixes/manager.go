@@ -0,0 +1,243 @@
+package mixes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJobNotFound    = errors.New("mix not found")
+	ErrJobNotReady    = errors.New("mix has not finished rendering")
+	ErrCrateNotFound  = errors.New("crate not found")
+	ErrEmptyCrate     = errors.New("crate has no tracks")
+	ErrFFmpegMissing  = errors.New("ffmpeg not found on PATH")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Output formats.
+const (
+	FormatFLAC = "flac"
+	FormatMP3  = "mp3"
+)
+
+// Render defaults and limits. Eight bars is a comfortable blend at club
+// tempos (~15s at 128 BPM); a single ffmpeg graph with hundreds of inputs
+// needs more file handles and memory than a Pi should spend on this.
+const (
+	defaultCrossfadeBars = 8
+	maxCrossfadeBars     = 64
+	defaultMP3Bitrate    = 320
+	minMP3Bitrate        = 128
+	maxMP3Bitrate        = 320
+	maxCurvePoints       = 32
+	maxMixTracks         = 200
+	minCurveBPM          = 40
+	maxCurveBPM          = 250
+)
+
+// Manager queues mix renders and runs them one at a time in the background.
+type Manager struct {
+	repo    *Repository
+	storage storage.Storage
+	dataDir string
+
+	wake chan struct{}
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewManager(repo *Repository, storage storage.Storage, dataDir string) *Manager {
+	return &Manager{
+		repo:    repo,
+		storage: storage,
+		dataDir: dataDir,
+		wake:    make(chan struct{}, 1),
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+type CreateRequest struct {
+	PlaylistID    string       `json:"playlist_id" binding:"required"`
+	Format        string       `json:"format"`         // "flac" (default) or "mp3"
+	BPMCurve      []CurvePoint `json:"bpm_curve"`      // empty keeps each track's own tempo
+	CrossfadeBars *int         `json:"crossfade_bars"` // default 8; 0 butt-splices
+	BitrateKbps   int          `json:"bitrate_kbps"`   // mp3 only, default 320
+}
+
+func validateCurve(curve []CurvePoint) error {
+	if len(curve) > maxCurvePoints {
+		return fmt.Errorf("%w: bpm_curve is limited to %d points", ErrInvalidRequest, maxCurvePoints)
+	}
+	for _, pt := range curve {
+		if pt.At < 0 || pt.At > 1 {
+			return fmt.Errorf("%w: bpm_curve positions must be between 0 and 1", ErrInvalidRequest)
+		}
+		if pt.BPM < minCurveBPM || pt.BPM > maxCurveBPM {
+			return fmt.Errorf("%w: bpm_curve tempos must be between %d and %d", ErrInvalidRequest, minCurveBPM, maxCurveBPM)
+		}
+	}
+	return nil
+}
+
+// Create validates req and queues a render of the crate. Users can render
+// their own crates and public ones; admins can render any.
+func (m *Manager) Create(ctx context.Context, userID, userRole string, req *CreateRequest) (*Job, error) {
+	format := req.Format
+	if format == "" {
+		format = FormatFLAC
+	}
+	if format != FormatFLAC && format != FormatMP3 {
+		return nil, fmt.Errorf("%w: format must be flac or mp3", ErrInvalidRequest)
+	}
+	bars := defaultCrossfadeBars
+	if req.CrossfadeBars != nil {
+		bars = *req.CrossfadeBars
+	}
+	if bars < 0 || bars > maxCrossfadeBars {
+		return nil, fmt.Errorf("%w: crossfade_bars must be between 0 and %d", ErrInvalidRequest, maxCrossfadeBars)
+	}
+	if err := validateCurve(req.BPMCurve); err != nil {
+		return nil, err
+	}
+	var bitrate *int
+	if format == FormatMP3 {
+		b := req.BitrateKbps
+		if b == 0 {
+			b = defaultMP3Bitrate
+		}
+		if b < minMP3Bitrate || b > maxMP3Bitrate {
+			return nil, fmt.Errorf("%w: bitrate_kbps must be between %d and %d", ErrInvalidRequest, minMP3Bitrate, maxMP3Bitrate)
+		}
+		bitrate = &b
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, ErrFFmpegMissing
+	}
+
+	crate, err := m.repo.GetCrate(ctx, req.PlaylistID)
+	if err != nil {
+		return nil, err
+	}
+	if userRole != "admin" && crate.OwnerUserID != userID && !crate.IsPublic {
+		return nil, ErrAccessDenied
+	}
+	tracks, err := m.repo.GetCrateTracks(ctx, crate.ID)
+	if err != nil {
+		return nil, fmt.Errorf("load crate tracks: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrEmptyCrate
+	}
+	if len(tracks) > maxMixTracks {
+		return nil, fmt.Errorf("%w: crates over %d tracks are too long to render as one mix", ErrInvalidRequest, maxMixTracks)
+	}
+
+	job := &Job{
+		ID:            uuid.New().String(),
+		OwnerUserID:   userID,
+		PlaylistID:    crate.ID,
+		Status:        StatusQueued,
+		Format:        format,
+		BPMCurve:      req.BPMCurve,
+		CrossfadeBars: bars,
+		BitrateKbps:   bitrate,
+		CreatedAt:     time.Now(),
+	}
+	if err := m.repo.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("create mix job: %w", err)
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Get returns a job its owner (or an admin) may see. Other users' jobs are
+// reported as not found rather than forbidden.
+func (m *Manager) Get(ctx context.Context, id, userID, userRole string) (*Job, error) {
+	job, err := m.repo.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userRole != "admin" && job.OwnerUserID != userID {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// List returns the user's renders; admins see everyone's.
+func (m *Manager) List(ctx context.Context, userID, userRole string) ([]*Job, error) {
+	if userRole == "admin" {
+		return m.repo.ListJobs(ctx, "")
+	}
+	return m.repo.ListJobs(ctx, userID)
+}
+
+// CrateName returns the name of a job's crate for download filenames, or ""
+// if the crate has since been deleted.
+func (m *Manager) CrateName(ctx context.Context, job *Job) string {
+	crate, err := m.repo.GetCrate(ctx, job.PlaylistID)
+	if err != nil {
+		return ""
+	}
+	return crate.Name
+}
+
+// Delete cancels a render if it is running and removes the job and its file.
+func (m *Manager) Delete(ctx context.Context, id, userID, userRole string) error {
+	job, err := m.Get(ctx, id, userID, userRole)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	cancel, running := m.running[id]
+	m.mu.Unlock()
+	if running {
+		cancel()
+	}
+	if job.OutputPath != "" {
+		if err := os.Remove(job.OutputPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove mix file: %w", err)
+		}
+	}
+	return m.repo.DeleteJob(ctx, id)
+}
+
+// RunNext renders the oldest queued job, if any. It reports whether a job
+// was claimed so the worker knows to look again straight away.
+func (m *Manager) RunNext(ctx context.Context) (bool, error) {
+	job, err := m.repo.ClaimNext(ctx)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.running[job.ID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		cancel()
+		m.mu.Lock()
+		delete(m.running, job.ID)
+		m.mu.Unlock()
+	}()
+
+	fmt.Printf("[Mixes] Rendering job %s (crate=%s format=%s bars=%d)\n", job.ID, job.PlaylistID, job.Format, job.CrossfadeBars)
+	start := time.Now()
+	if err := m.render(jobCtx, job); err != nil {
+		return true, fmt.Errorf("job %s: %w", job.ID, err)
+	}
+	fmt.Printf("[Mixes] Job %s done in %s\n", job.ID, time.Since(start).Round(time.Second))
+	return true, nil
+}
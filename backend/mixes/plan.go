@@ -0,0 +1,285 @@
+package mixes
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxStretch is the furthest a track is time-stretched toward the target
+// tempo. atempo stays clean to about ±8%; past that vocals start to smear, so
+// a track that far off is pulled as close as it will go and left there.
+const maxStretch = 0.08
+
+// CurvePoint is one point on the target tempo curve. At is the position in
+// the set from 0 (first track) to 1 (last track); BPM is the target there.
+type CurvePoint struct {
+	At  float64 `json:"at"`
+	BPM float64 `json:"bpm"`
+}
+
+// targetBPM linearly interpolates the curve at position x (0..1). Points are
+// sorted by At; before the first or after the last point the curve is flat.
+func targetBPM(curve []CurvePoint, x float64) float64 {
+	if len(curve) == 0 {
+		return 0
+	}
+	pts := make([]CurvePoint, len(curve))
+	copy(pts, curve)
+	sort.Slice(pts, func(i, j int) bool { return pts[i].At < pts[j].At })
+	if x <= pts[0].At {
+		return pts[0].BPM
+	}
+	for i := 1; i < len(pts); i++ {
+		if x <= pts[i].At {
+			a, b := pts[i-1], pts[i]
+			if b.At == a.At {
+				return b.BPM
+			}
+			return a.BPM + (b.BPM-a.BPM)*(x-a.At)/(b.At-a.At)
+		}
+	}
+	return pts[len(pts)-1].BPM
+}
+
+// tempoRatio returns the atempo factor that moves a track at bpm toward
+// target. Half and double time count as the same tempo, so a 87 BPM track in
+// a 174 set is left alone rather than sped up 2x. Unknown tempos get 1.
+func tempoRatio(bpm, target float64) float64 {
+	if bpm <= 0 || target <= 0 {
+		return 1
+	}
+	ratio := target / bpm
+	for _, alt := range []float64{target * 2 / bpm, target / 2 / bpm} {
+		if math.Abs(math.Log(alt)) < math.Abs(math.Log(ratio)) {
+			ratio = alt
+		}
+	}
+	return math.Max(1-maxStretch, math.Min(1+maxStretch, ratio))
+}
+
+// PlanTrack is one source track as it will sit in the rendered mix.
+type PlanTrack struct {
+	TrackID      string  `json:"track_id"`
+	Title        string  `json:"title"`
+	Artist       string  `json:"artist"`
+	SourceBPM    float64 `json:"source_bpm,omitempty"`
+	TargetBPM    float64 `json:"target_bpm,omitempty"`
+	TempoRatio   float64 `json:"tempo_ratio"`
+	StartSeconds float64 `json:"start_seconds"`
+	// CrossfadeSeconds is the overlap into the next track; 0 on the last one.
+	CrossfadeSeconds float64 `json:"crossfade_seconds"`
+	// Cues are the mix owner's cues and loops on this track, moved to mix time.
+	Cues []PlanCue `json:"cues,omitempty"`
+
+	path     string
+	duration float64 // after stretching
+}
+
+// PlanCue is a track cue placed in the rendered mix.
+type PlanCue struct {
+	Kind       string   `json:"kind"`
+	Label      string   `json:"label"`
+	Seconds    float64  `json:"seconds"`
+	EndSeconds *float64 `json:"end_seconds,omitempty"`
+}
+
+// cueLabel names a cue for the tracklist: its own label, or "Hot cue 3",
+// "Memory cue", "Loop" when it has none.
+func cueLabel(c MixCue) string {
+	switch {
+	case c.Label != "":
+		return c.Label
+	case c.Kind == "hot":
+		return fmt.Sprintf("Hot cue %d", c.Slot)
+	case c.Kind == "loop":
+		return "Loop"
+	default:
+		return "Memory cue"
+	}
+}
+
+// planCues moves a track's cues into mix time given where it starts and how
+// much it is stretched. Cues past the end of the track are dropped.
+func planCues(cues []MixCue, start, ratio, duration float64) []PlanCue {
+	var out []PlanCue
+	at := func(s float64) float64 { return math.Round((start+s/ratio)*1000) / 1000 }
+	for _, c := range cues {
+		if c.Seconds/ratio > duration {
+			continue
+		}
+		pc := PlanCue{Kind: c.Kind, Label: cueLabel(c), Seconds: at(c.Seconds)}
+		if c.Kind == "loop" && c.EndSeconds > c.Seconds {
+			end := at(math.Min(c.EndSeconds, duration*ratio))
+			pc.EndSeconds = &end
+		}
+		out = append(out, pc)
+	}
+	return out
+}
+
+// plan is a fully resolved render: every input, its stretch, the overlaps
+// and where each track starts in the output.
+type plan struct {
+	tracks   []PlanTrack
+	duration float64
+}
+
+// planInput is what the planner needs per track.
+type planInput struct {
+	track    MixTrack
+	path     string
+	duration float64 // source duration in seconds
+}
+
+// buildPlan lays the crate out end to end. Crossfades are bars×4 beats at the
+// target tempo of the outgoing track (or its own tempo with no curve, or 120
+// when that's unknown too), capped at a third of either neighbour so short
+// edits don't vanish entirely into the fades.
+func buildPlan(inputs []planInput, curve []CurvePoint, bars int) plan {
+	p := plan{tracks: make([]PlanTrack, len(inputs))}
+	for i, in := range inputs {
+		x := 0.0
+		if len(inputs) > 1 {
+			x = float64(i) / float64(len(inputs)-1)
+		}
+		target := targetBPM(curve, x)
+		ratio := tempoRatio(in.track.BPM, target)
+		p.tracks[i] = PlanTrack{
+			TrackID:    in.track.ID,
+			Title:      in.track.DisplayTitle(),
+			Artist:     in.track.Artist,
+			SourceBPM:  in.track.BPM,
+			TargetBPM:  target,
+			TempoRatio: ratio,
+			path:       in.path,
+			duration:   in.duration / ratio,
+		}
+	}
+
+	start := 0.0
+	for i := range p.tracks {
+		t := &p.tracks[i]
+		t.StartSeconds = start
+		t.Cues = planCues(inputs[i].track.Cues, start, t.TempoRatio, t.duration)
+		if i < len(p.tracks)-1 && bars > 0 {
+			beatBPM := t.TargetBPM
+			if beatBPM <= 0 {
+				beatBPM = t.SourceBPM * t.TempoRatio
+			}
+			if beatBPM <= 0 {
+				beatBPM = 120
+			}
+			xf := float64(bars*4) * 60 / beatBPM
+			xf = math.Min(xf, t.duration/3)
+			xf = math.Min(xf, p.tracks[i+1].duration/3)
+			t.CrossfadeSeconds = math.Round(xf*1000) / 1000
+		}
+		start += t.duration - t.CrossfadeSeconds
+	}
+	p.duration = start
+	return p
+}
+
+// filterGraph builds the ffmpeg -filter_complex that normalises, stretches
+// and chains every input. Inputs are numbered in plan order; the result is
+// labelled [out].
+func (p plan) filterGraph() string {
+	var b strings.Builder
+	for i, t := range p.tracks {
+		fmt.Fprintf(&b, "[%d:a]aresample=44100,aformat=sample_fmts=fltp:channel_layouts=stereo", i)
+		if t.TempoRatio != 1 {
+			fmt.Fprintf(&b, ",atempo=%.6f", t.TempoRatio)
+		}
+		fmt.Fprintf(&b, "[s%d];", i)
+	}
+	if len(p.tracks) == 1 {
+		b.WriteString("[s0]anull[out]")
+		return b.String()
+	}
+
+	prev := "s0"
+	for i := 1; i < len(p.tracks); i++ {
+		out := fmt.Sprintf("x%d", i)
+		if i == len(p.tracks)-1 {
+			out = "out"
+		}
+		xf := p.tracks[i-1].CrossfadeSeconds
+		if xf > 0 {
+			// qsin on both sides is an equal-power fade: no dip in the middle.
+			fmt.Fprintf(&b, "[%s][s%d]acrossfade=d=%.3f:c1=qsin:c2=qsin[%s]", prev, i, xf, out)
+		} else {
+			fmt.Fprintf(&b, "[%s][s%d]concat=n=2:v=0:a=1[%s]", prev, i, out)
+		}
+		if out != "out" {
+			b.WriteString(";")
+		}
+		prev = out
+	}
+	return b.String()
+}
+
+// ffmetadata renders the chapter list in ffmpeg's FFMETADATA1 format. The
+// MP3 muxer turns chapters into ID3v2 CHAP frames; FLAC gets them as
+// CHAPTERnnn Vorbis comments.
+func (p plan) ffmetadata(title string) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	fmt.Fprintf(&b, "title=%s\n", escapeFFMetadata(title))
+	for i, t := range p.tracks {
+		end := p.duration
+		if i < len(p.tracks)-1 {
+			end = p.tracks[i+1].StartSeconds
+		}
+		b.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\nEND=%d\n", int64(t.StartSeconds*1000), int64(end*1000))
+		fmt.Fprintf(&b, "title=%s\n", escapeFFMetadata(t.Title))
+	}
+	return b.String()
+}
+
+func escapeFFMetadata(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n")
+	return r.Replace(s)
+}
+
+// cueSheet renders a standard CUE sheet for the mix. It is embedded in FLAC
+// output as a CUESHEET tag and served alongside either format.
+func cueSheet(title, fileName, format string, tracks []PlanTrack) string {
+	fileType := "WAVE"
+	if format == FormatMP3 {
+		fileType = "MP3"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE %s\n", cueQuote(title))
+	fmt.Fprintf(&b, "FILE %s %s\n", cueQuote(fileName), fileType)
+	for i, t := range tracks {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", cueQuote(t.Title))
+		if t.Artist != "" {
+			fmt.Fprintf(&b, "    PERFORMER %s\n", cueQuote(t.Artist))
+		}
+		// Track cues ride along as REM comments; players ignore them, and
+		// they keep a DJ's prepared points with the recording.
+		for _, c := range t.Cues {
+			if c.EndSeconds != nil {
+				fmt.Fprintf(&b, "    REM LOOP %s %s %s\n", cueTimestamp(c.Seconds), cueTimestamp(*c.EndSeconds), cueQuote(c.Label))
+			} else {
+				fmt.Fprintf(&b, "    REM CUE %s %s\n", cueTimestamp(c.Seconds), cueQuote(c.Label))
+			}
+		}
+		fmt.Fprintf(&b, "    INDEX 01 %s\n", cueTimestamp(t.StartSeconds))
+	}
+	return b.String()
+}
+
+// cueTimestamp formats seconds as MM:SS:FF with 75 frames per second.
+func cueTimestamp(seconds float64) string {
+	frames := int64(math.Round(seconds * 75))
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(75*60), (frames/75)%60, frames%75)
+}
+
+func cueQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
@@ -0,0 +1,209 @@
+package mixes
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestTargetBPM(t *testing.T) {
+	curve := []CurvePoint{{At: 1, BPM: 130}, {At: 0, BPM: 120}, {At: 0.5, BPM: 124}}
+	cases := []struct {
+		x, want float64
+	}{
+		{0, 120},
+		{0.25, 122},
+		{0.5, 124},
+		{0.75, 127},
+		{1, 130},
+		{-1, 120},
+		{2, 130},
+	}
+	for _, tc := range cases {
+		if got := targetBPM(curve, tc.x); !approx(got, tc.want) {
+			t.Errorf("targetBPM(%v) = %v, want %v", tc.x, got, tc.want)
+		}
+	}
+	if got := targetBPM(nil, 0.5); got != 0 {
+		t.Errorf("empty curve = %v, want 0", got)
+	}
+}
+
+func TestTempoRatio(t *testing.T) {
+	cases := []struct {
+		name        string
+		bpm, target float64
+		want        float64
+	}{
+		{"exact", 128, 128, 1},
+		{"small speed-up", 124, 128, 128.0 / 124},
+		{"clamped slow-down", 140, 120, 1 - maxStretch},
+		{"half time counts as a match", 87, 174, 1},
+		{"double time counts as a match", 172, 86, 1},
+		{"unknown tempo", 0, 128, 1},
+		{"no target", 128, 0, 1},
+	}
+	for _, tc := range cases {
+		if got := tempoRatio(tc.bpm, tc.target); !approx(got, tc.want) {
+			t.Errorf("%s: tempoRatio(%v, %v) = %v, want %v", tc.name, tc.bpm, tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestBuildPlanTimeline(t *testing.T) {
+	inputs := []planInput{
+		{track: MixTrack{ID: "a", Title: "A", BPM: 120}, path: "/a", duration: 240},
+		{track: MixTrack{ID: "b", Title: "B", BPM: 120}, path: "/b", duration: 180},
+		{track: MixTrack{ID: "c", Title: "C", BPM: 120}, path: "/c", duration: 30},
+	}
+	p := buildPlan(inputs, []CurvePoint{{At: 0, BPM: 120}}, 8)
+
+	// 8 bars at 120 BPM is 16s; the fade into the 30s track is capped at 10s.
+	if got := p.tracks[0].CrossfadeSeconds; !approx(got, 16) {
+		t.Errorf("first crossfade = %v, want 16", got)
+	}
+	if got := p.tracks[1].CrossfadeSeconds; !approx(got, 10) {
+		t.Errorf("second crossfade = %v, want 10 (capped)", got)
+	}
+	if got := p.tracks[2].CrossfadeSeconds; got != 0 {
+		t.Errorf("last track crossfade = %v, want 0", got)
+	}
+	if got := p.tracks[1].StartSeconds; !approx(got, 224) {
+		t.Errorf("second start = %v, want 224", got)
+	}
+	if got := p.tracks[2].StartSeconds; !approx(got, 394) {
+		t.Errorf("third start = %v, want 394", got)
+	}
+	if !approx(p.duration, 424) {
+		t.Errorf("duration = %v, want 424", p.duration)
+	}
+}
+
+func TestBuildPlanStretchShortensTimeline(t *testing.T) {
+	inputs := []planInput{
+		{track: MixTrack{ID: "a", BPM: 120}, path: "/a", duration: 240},
+		{track: MixTrack{ID: "b", BPM: 120}, path: "/b", duration: 240},
+	}
+	p := buildPlan(inputs, []CurvePoint{{At: 0, BPM: 126}}, 0)
+	if got := p.tracks[0].TempoRatio; !approx(got, 1.05) {
+		t.Fatalf("ratio = %v, want 1.05", got)
+	}
+	if !approx(p.duration, 480/1.05) {
+		t.Errorf("duration = %v, want %v", p.duration, 480/1.05)
+	}
+	graph := p.filterGraph()
+	if !strings.Contains(graph, "atempo=1.050000") {
+		t.Errorf("filter graph missing atempo: %s", graph)
+	}
+	if !strings.Contains(graph, "concat=n=2") || strings.Contains(graph, "acrossfade") {
+		t.Errorf("zero-bar crossfade should concat: %s", graph)
+	}
+}
+
+func TestFilterGraphChainsCrossfades(t *testing.T) {
+	inputs := []planInput{
+		{track: MixTrack{ID: "a"}, path: "/a", duration: 200},
+		{track: MixTrack{ID: "b"}, path: "/b", duration: 200},
+		{track: MixTrack{ID: "c"}, path: "/c", duration: 200},
+	}
+	graph := buildPlan(inputs, nil, 4).filterGraph()
+	for _, want := range []string{
+		"[0:a]aresample=44100,aformat=sample_fmts=fltp:channel_layouts=stereo[s0];",
+		"[s0][s1]acrossfade=d=8.000:c1=qsin:c2=qsin[x1];",
+		"[x1][s2]acrossfade=d=8.000:c1=qsin:c2=qsin[out]",
+	} {
+		if !strings.Contains(graph, want) {
+			t.Errorf("filter graph missing %q:\n%s", want, graph)
+		}
+	}
+	if strings.Contains(graph, "atempo") {
+		t.Errorf("no curve should mean no stretching: %s", graph)
+	}
+}
+
+func TestChaptersAndCueSheet(t *testing.T) {
+	p := plan{
+		tracks: []PlanTrack{
+			{Title: "A - One", Artist: "A", StartSeconds: 0},
+			{Title: `B - "Two"; =#`, Artist: "B", StartSeconds: 61.5},
+		},
+		duration: 120,
+	}
+	meta := p.ffmetadata("Warmup")
+	for _, want := range []string{
+		";FFMETADATA1\ntitle=Warmup\n",
+		"START=0\nEND=61500\ntitle=A - One\n",
+		"START=61500\nEND=120000\ntitle=B - \"Two\"\\; \\=\\#\n",
+	} {
+		if !strings.Contains(meta, want) {
+			t.Errorf("ffmetadata missing %q:\n%s", want, meta)
+		}
+	}
+
+	cue := cueSheet("Warmup", "warmup.flac", FormatFLAC, p.tracks)
+	for _, want := range []string{
+		"FILE \"warmup.flac\" WAVE\n",
+		"  TRACK 02 AUDIO\n    TITLE \"B - 'Two'; =#\"\n    PERFORMER \"B\"\n    INDEX 01 01:01:38\n",
+	} {
+		if !strings.Contains(cue, want) {
+			t.Errorf("cue sheet missing %q:\n%s", want, cue)
+		}
+	}
+}
+
+func TestParseProgressLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"out_time_us=12500000", 12.5, true},
+		{"out_time_ms=3000000", 3, true},
+		{"out_time=00:00:03.000000", 0, false},
+		{"out_time_us=N/A", 0, false},
+		{"progress=continue", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := parseProgressLine(tc.line)
+		if ok != tc.ok || !approx(got, tc.want) {
+			t.Errorf("parseProgressLine(%q) = %v, %v; want %v, %v", tc.line, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPlanCarriesCuesIntoMixTime(t *testing.T) {
+	inputs := []planInput{
+		{track: MixTrack{ID: "a", BPM: 120}, path: "/a", duration: 240},
+		{track: MixTrack{ID: "b", BPM: 120, Cues: []MixCue{
+			{Kind: "hot", Slot: 1, Seconds: 21},
+			{Kind: "loop", Label: "Break", Seconds: 42, EndSeconds: 50.4},
+			{Kind: "memory", Seconds: 300}, // past the end of the track
+		}}, path: "/b", duration: 240},
+	}
+	p := buildPlan(inputs, []CurvePoint{{At: 0, BPM: 126}}, 0)
+	cues := p.tracks[1].Cues
+	if len(cues) != 2 {
+		t.Fatalf("cues = %+v, want 2", cues)
+	}
+	start := p.tracks[1].StartSeconds
+	// Cue times are rounded to the millisecond.
+	ms := func(a, b float64) bool { return math.Abs(a-b) < 0.0006 }
+	if cues[0].Label != "Hot cue 1" || !ms(cues[0].Seconds, start+20) {
+		t.Errorf("hot cue = %+v, want %q at %v", cues[0], "Hot cue 1", start+20)
+	}
+	if cues[1].EndSeconds == nil || !ms(cues[1].Seconds, start+40) || !ms(*cues[1].EndSeconds, start+48) {
+		t.Errorf("loop = %+v, want %v..%v", cues[1], start+40, start+48)
+	}
+
+	sheet := cueSheet("Set", "set.flac", FormatFLAC, p.tracks)
+	for _, want := range []string{
+		`    REM CUE ` + cueTimestamp(start+20) + ` "Hot cue 1"` + "\n",
+		`    REM LOOP ` + cueTimestamp(start+40) + ` ` + cueTimestamp(start+48) + ` "Break"` + "\n",
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("cue sheet missing %q:\n%s", want, sheet)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package mixes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// progressInterval throttles progress writes to the database.
+const progressInterval = 2 * time.Second
+
+// render produces the mix file for job and records the result. It returns
+// an error only for failures the caller should log; the job row is always
+// left in a final state.
+func (m *Manager) render(ctx context.Context, job *Job) error {
+	outPath, size, err := m.renderFile(ctx, job)
+	if err != nil {
+		msg := err.Error()
+		if ctx.Err() != nil {
+			msg = "render cancelled"
+		}
+		if markErr := m.repo.MarkFailed(context.Background(), job.ID, msg); markErr != nil {
+			return fmt.Errorf("mark failed: %w (render: %v)", markErr, err)
+		}
+		return err
+	}
+	return m.repo.MarkDone(ctx, job.ID, outPath, size)
+}
+
+func (m *Manager) renderFile(ctx context.Context, job *Job) (string, int64, error) {
+	crate, err := m.repo.GetCrate(ctx, job.PlaylistID)
+	if err != nil {
+		return "", 0, err
+	}
+	tracks, err := m.repo.GetCrateTracks(ctx, job.PlaylistID)
+	if err != nil {
+		return "", 0, fmt.Errorf("load crate tracks: %w", err)
+	}
+	if len(tracks) == 0 {
+		return "", 0, ErrEmptyCrate
+	}
+	cues, err := m.repo.GetCrateCues(ctx, job.PlaylistID, job.OwnerUserID)
+	if err != nil {
+		return "", 0, fmt.Errorf("load cues: %w", err)
+	}
+	for i := range tracks {
+		tracks[i].Cues = cues[tracks[i].ID]
+	}
+
+	inputs := make([]planInput, 0, len(tracks))
+	for _, t := range tracks {
+		path, ok := m.storage.ResolveFullPath(t.FilePath)
+		if !ok {
+			return "", 0, fmt.Errorf("track %s: file not found", t.ID)
+		}
+		duration := t.DurationSeconds
+		if duration <= 0 {
+			if duration, err = probeDuration(ctx, path); err != nil {
+				return "", 0, fmt.Errorf("track %s: %w", t.ID, err)
+			}
+		}
+		inputs = append(inputs, planInput{track: t, path: path, duration: duration})
+	}
+
+	p := buildPlan(inputs, job.BPMCurve, job.CrossfadeBars)
+	if err := m.repo.SetTracklist(ctx, job.ID, p.tracks, p.duration); err != nil {
+		return "", 0, fmt.Errorf("save tracklist: %w", err)
+	}
+
+	outDir := filepath.Join(m.dataDir, "mixes")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("create mixes dir: %w", err)
+	}
+	outPath := filepath.Join(outDir, job.ID+"."+job.Format)
+	partPath := outPath + ".part"
+	metaPath := filepath.Join(outDir, job.ID+".ffmeta")
+	if err := os.WriteFile(metaPath, []byte(p.ffmetadata(crate.Name)), 0644); err != nil {
+		return "", 0, fmt.Errorf("write chapters: %w", err)
+	}
+	defer os.Remove(metaPath)
+	defer os.Remove(partPath)
+
+	args := []string{"-hide_banner", "-v", "error", "-nostats", "-progress", "pipe:1", "-y"}
+	for _, t := range p.tracks {
+		args = append(args, "-i", t.path)
+	}
+	metaInput := strconv.Itoa(len(p.tracks))
+	args = append(args, "-f", "ffmetadata", "-i", metaPath,
+		"-filter_complex", p.filterGraph(),
+		"-map", "[out]", "-map_metadata", metaInput, "-map_chapters", metaInput)
+	switch job.Format {
+	case FormatMP3:
+		bitrate := defaultMP3Bitrate
+		if job.BitrateKbps != nil {
+			bitrate = *job.BitrateKbps
+		}
+		// Chapters are written as ID3v2 CHAP frames, which need an ID3v2 header.
+		args = append(args, "-c:a", "libmp3lame", "-b:a", fmt.Sprintf("%dk", bitrate), "-id3v2_version", "3", "-f", "mp3")
+	default:
+		cue := cueSheet(crate.Name, job.ID+".flac", FormatFLAC, p.tracks)
+		args = append(args, "-c:a", "flac", "-compression_level", "5", "-metadata", "CUESHEET="+cue, "-f", "flac")
+	}
+	args = append(args, partPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", 0, fmt.Errorf("ffmpeg stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", 0, fmt.Errorf("start ffmpeg: %w", err)
+	}
+	m.trackProgress(ctx, job.ID, stdout, p.duration)
+	if err := cmd.Wait(); err != nil {
+		return "", 0, fmt.Errorf("ffmpeg: %w: %s", err, lastLine(stderr.String()))
+	}
+
+	if err := os.Rename(partPath, outPath); err != nil {
+		return "", 0, fmt.Errorf("move output: %w", err)
+	}
+	info, err := os.Stat(outPath)
+	if err != nil {
+		return "", 0, err
+	}
+	return outPath, info.Size(), nil
+}
+
+// trackProgress reads ffmpeg's -progress output until EOF, saving the
+// fraction rendered at most every progressInterval.
+func (m *Manager) trackProgress(ctx context.Context, jobID string, r io.Reader, total float64) {
+	var last time.Time
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		secs, ok := parseProgressLine(sc.Text())
+		if !ok || total <= 0 || time.Since(last) < progressInterval {
+			continue
+		}
+		last = time.Now()
+		// Hold back the last percent for the rename and stat after ffmpeg exits.
+		progress := min(secs/total, 0.99)
+		if err := m.repo.SetProgress(ctx, jobID, progress); err != nil {
+			fmt.Printf("[Mixes] Job %s: save progress: %v\n", jobID, err)
+		}
+	}
+}
+
+// parseProgressLine extracts the output position in seconds from one line of
+// ffmpeg -progress output. Older ffmpeg only writes out_time_ms, which despite
+// the name is also in microseconds.
+func parseProgressLine(line string) (float64, bool) {
+	key, val, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok || (key != "out_time_us" && key != "out_time_ms") {
+		return 0, false
+	}
+	us, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || us < 0 {
+		return 0, false
+	}
+	return float64(us) / 1e6, true
+}
+
+// probeDuration asks ffprobe for a file's length when the library doesn't
+// have it.
+func probeDuration(ctx context.Context, path string) (float64, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("ffprobe: no duration for %s", filepath.Base(path))
+	}
+	return d, nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,330 @@
+package mixes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRendering = "rendering"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+)
+
+// Job is one mix render, from request to finished file.
+type Job struct {
+	ID              string       `json:"id"`
+	OwnerUserID     string       `json:"owner_user_id"`
+	PlaylistID      string       `json:"playlist_id"`
+	Status          string       `json:"status"`
+	Format          string       `json:"format"`
+	BPMCurve        []CurvePoint `json:"bpm_curve,omitempty"`
+	CrossfadeBars   int          `json:"crossfade_bars"`
+	BitrateKbps     *int         `json:"bitrate_kbps,omitempty"`
+	Progress        float64      `json:"progress"`
+	Tracklist       []PlanTrack  `json:"tracklist,omitempty"`
+	DurationSeconds *float64     `json:"duration_seconds,omitempty"`
+	SizeBytes       *int64       `json:"size_bytes,omitempty"`
+	Error           *string      `json:"error,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	StartedAt       *time.Time   `json:"started_at,omitempty"`
+	FinishedAt      *time.Time   `json:"finished_at,omitempty"`
+
+	OutputPath string `json:"-"`
+}
+
+// Crate is the subset of a playlist row a render needs for access checks.
+type Crate struct {
+	ID          string
+	OwnerUserID string
+	Name        string
+	IsPublic    bool
+}
+
+// MixTrack is the subset of a track row a render needs.
+type MixTrack struct {
+	ID               string
+	Title            string
+	Artist           string
+	OriginalFilename string
+	FilePath         string
+	DurationSeconds  float64 // 0 when unknown
+	BPM              float64 // 0 when not analyzed
+	Cues             []MixCue
+}
+
+// MixCue is one of the mix owner's cues on a track, in track time.
+type MixCue struct {
+	Kind       string
+	Slot       int // hot cues only
+	Label      string
+	Seconds    float64
+	EndSeconds float64 // loops only
+}
+
+// DisplayTitle is the "Artist - Title" string used for chapters.
+func (t MixTrack) DisplayTitle() string {
+	switch {
+	case t.Artist != "" && t.Title != "":
+		return t.Artist + " - " + t.Title
+	case t.Title != "":
+		return t.Title
+	default:
+		return t.OriginalFilename
+	}
+}
+
+const jobColumns = `id, owner_user_id, playlist_id, status, format, bpm_curve, crossfade_bars, bitrate_kbps,
+	progress, tracklist, duration_seconds, size_bytes, output_path, error, created_at, started_at, finished_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	var curve, tracklist, outputPath, errMsg sql.NullString
+	var bitrate, size sql.NullInt64
+	var duration sql.NullFloat64
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&j.ID, &j.OwnerUserID, &j.PlaylistID, &j.Status, &j.Format, &curve, &j.CrossfadeBars,
+		&bitrate, &j.Progress, &tracklist, &duration, &size, &outputPath, &errMsg,
+		&j.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if curve.Valid {
+		_ = json.Unmarshal([]byte(curve.String), &j.BPMCurve)
+	}
+	if tracklist.Valid {
+		_ = json.Unmarshal([]byte(tracklist.String), &j.Tracklist)
+	}
+	if bitrate.Valid {
+		v := int(bitrate.Int64)
+		j.BitrateKbps = &v
+	}
+	if duration.Valid {
+		j.DurationSeconds = &duration.Float64
+	}
+	if size.Valid {
+		j.SizeBytes = &size.Int64
+	}
+	if outputPath.Valid {
+		j.OutputPath = outputPath.String
+	}
+	if errMsg.Valid {
+		j.Error = &errMsg.String
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+func (r *Repository) CreateJob(ctx context.Context, j *Job) error {
+	var curve any
+	if len(j.BPMCurve) > 0 {
+		b, err := json.Marshal(j.BPMCurve)
+		if err != nil {
+			return err
+		}
+		curve = string(b)
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO mix_jobs (id, owner_user_id, playlist_id, status, format, bpm_curve, crossfade_bars, bitrate_kbps, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, j.ID, j.OwnerUserID, j.PlaylistID, j.Status, j.Format, curve, j.CrossfadeBars, j.BitrateKbps, j.CreatedAt)
+	return err
+}
+
+func (r *Repository) GetJob(ctx context.Context, id string) (*Job, error) {
+	j, err := scanJob(r.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM mix_jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	return j, err
+}
+
+// ListJobs returns a user's renders, newest first. An empty userID lists
+// everyone's (for admins).
+func (r *Repository) ListJobs(ctx context.Context, userID string) ([]*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM mix_jobs`
+	var args []any
+	if userID != "" {
+		query += ` WHERE owner_user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimNext moves the oldest queued job to rendering and returns it, or
+// returns nil when nothing is queued. The status check in the UPDATE makes
+// the claim safe even if two workers race.
+func (r *Repository) ClaimNext(ctx context.Context) (*Job, error) {
+	var id string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id FROM mix_jobs WHERE status = ? ORDER BY created_at ASC LIMIT 1`, StatusQueued,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE mix_jobs SET status = ?, started_at = ?, progress = 0 WHERE id = ? AND status = ?`,
+		StatusRendering, time.Now(), id, StatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return r.GetJob(ctx, id)
+}
+
+// RequeueInterrupted puts renders that were running when the server stopped
+// back in the queue. Partial output is overwritten when they run again.
+func (r *Repository) RequeueInterrupted(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE mix_jobs SET status = ?, progress = 0, started_at = NULL WHERE status = ?`,
+		StatusQueued, StatusRendering)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repository) SetTracklist(ctx context.Context, id string, tracks []PlanTrack, duration float64) error {
+	b, err := json.Marshal(tracks)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE mix_jobs SET tracklist = ?, duration_seconds = ? WHERE id = ?`, string(b), duration, id)
+	return err
+}
+
+func (r *Repository) SetProgress(ctx context.Context, id string, progress float64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE mix_jobs SET progress = ? WHERE id = ?`, progress, id)
+	return err
+}
+
+func (r *Repository) MarkDone(ctx context.Context, id, outputPath string, size int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE mix_jobs SET status = ?, progress = 1, output_path = ?, size_bytes = ?, error = NULL, finished_at = ?
+		WHERE id = ?
+	`, StatusDone, outputPath, size, time.Now(), id)
+	return err
+}
+
+func (r *Repository) MarkFailed(ctx context.Context, id, msg string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE mix_jobs SET status = ?, error = ?, finished_at = ? WHERE id = ?`,
+		StatusFailed, msg, time.Now(), id)
+	return err
+}
+
+func (r *Repository) DeleteJob(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM mix_jobs WHERE id = ?`, id)
+	return err
+}
+
+func (r *Repository) GetCrate(ctx context.Context, playlistID string) (*Crate, error) {
+	var c Crate
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_user_id, name, is_public FROM playlists WHERE id = ?`,
+		playlistID,
+	).Scan(&c.ID, &c.OwnerUserID, &c.Name, &c.IsPublic)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCrateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCrateTracks returns every track in a crate in crate order.
+func (r *Repository) GetCrateTracks(ctx context.Context, playlistID string) ([]MixTrack, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, COALESCE(t.title, ''), COALESCE(t.artist, ''), t.original_filename,
+		       t.file_path, COALESCE(t.duration_seconds, 0), COALESCE(t.bpm, 0)
+		FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL
+		ORDER BY pt.position ASC, pt.added_at ASC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []MixTrack
+	for rows.Next() {
+		var t MixTrack
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.OriginalFilename, &t.FilePath, &t.DurationSeconds, &t.BPM); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// GetCrateCues returns userID's cues on every track in a crate, keyed by
+// track ID and in track order.
+func (r *Repository) GetCrateCues(ctx context.Context, playlistID, userID string) (map[string][]MixCue, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.track_id, c.kind, COALESCE(c.slot, 0), c.label, c.position_seconds, COALESCE(c.end_seconds, 0)
+		FROM track_cues c
+		WHERE c.user_id = ? AND c.track_id IN (SELECT track_id FROM playlist_tracks WHERE playlist_id = ?)
+		ORDER BY c.track_id, c.position_seconds
+	`, userID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]MixCue)
+	for rows.Next() {
+		var trackID string
+		var c MixCue
+		if err := rows.Scan(&trackID, &c.Kind, &c.Slot, &c.Label, &c.Seconds, &c.EndSeconds); err != nil {
+			return nil, err
+		}
+		out[trackID] = append(out[trackID], c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,19 @@
+package mixes
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/mixes")
+		{
+			r.GET("", handlers.ListMixes)
+			r.POST("", handlers.CreateMix)
+			r.GET("/:id", handlers.GetMix)
+			r.DELETE("/:id", handlers.DeleteMix)
+			r.GET("/:id/download", handlers.DownloadMix)
+			r.GET("/:id/cue", handlers.CueSheet)
+		}
+	}
+}
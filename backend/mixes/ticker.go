@@ -0,0 +1,44 @@
+package mixes
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StartLoop renders queued mixes one at a time. It wakes immediately when a
+// job is created and otherwise polls on interval as a safety net. Renders
+// interrupted by a restart are queued again on startup.
+func StartLoop(ctx context.Context, m *Manager, interval time.Duration) {
+	if n, err := m.repo.RequeueInterrupted(ctx); err != nil {
+		fmt.Printf("[Mixes] Failed to requeue interrupted renders: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("[Mixes] Requeued %d interrupted render(s)\n", n)
+	}
+
+	fmt.Printf("[Mixes] Starting render loop (interval=%s)\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if ctx.Err() != nil {
+			fmt.Println("[Mixes] Render loop stopped")
+			return
+		}
+
+		claimed, err := m.RunNext(ctx)
+		if err != nil {
+			fmt.Printf("[Mixes] Render failed: %v\n", err)
+		}
+		if claimed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("[Mixes] Render loop stopped")
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
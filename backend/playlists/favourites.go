@@ -0,0 +1,145 @@
+package playlists
+
+import (
+	"database/sql"
+	"fmt"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+// favouritesFrom selects a user's favourite tracks that they can still
+// see: their own, and others' that are in a public crate, unless trashed.
+const favouritesFrom = `
+		FROM track_ratings r
+		JOIN tracks t ON t.id = r.track_id
+		WHERE r.user_id = ? AND r.favourite = TRUE AND t.deleted_at IS NULL
+		AND (t.owner_user_id = r.user_id OR EXISTS (
+			SELECT 1 FROM playlist_tracks pt JOIN playlists p ON p.id = pt.playlist_id
+			WHERE pt.track_id = t.id AND p.is_public = TRUE
+		))`
+
+// GetFavouriteTracks returns the tracks userID has marked as favourites,
+// narrowed and ordered by f, most recently favourited first by default
+func (r *Repository) GetFavouriteTracks(userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	cond, fargs := f.Where("t.")
+	args := append([]any{userID}, fargs...)
+	keys := f.PageKeys("t.", pagination.Key{Expr: "r.favourited_at", Desc: true}, pagination.Key{Expr: "t.created_at", Desc: true})
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
+		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
+		       t.sample_rate, t.bitrate,
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+		       t.file_path, t.cover_path, t.created_at, t.updated_at` + pagination.Columns(keys) + favouritesFrom + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, pagination.QueryArgs(keys, append(args, afterArgs...), limit+1, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favourite tracks: %w", err)
+	}
+	defer rows.Close()
+
+	sc := pagination.NewScanner(rows, keys)
+	var tracks []*imodels.Track
+	for rows.Next() {
+		var track imodels.Track
+		var bpm, bpmConf, keyConf sql.NullFloat64
+		var musicalKey, coverPath sql.NullString
+		var analyzedAt sql.NullTime
+		err := sc.Scan(
+			&track.ID,
+			&track.OwnerUserID,
+			&track.OriginalFilename,
+			&track.ContentType,
+			&track.SizeBytes,
+			&track.DurationSeconds,
+			&track.Title,
+			&track.Artist,
+			&track.Album,
+			&track.Genre,
+			&track.Year,
+			&track.SampleRate,
+			&track.Bitrate,
+			&bpm,
+			&bpmConf,
+			&musicalKey,
+			&keyConf,
+			&analyzedAt,
+			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
+			&track.FilePath,
+			&coverPath,
+			&track.CreatedAt,
+			&track.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan track: %w", err)
+		}
+		if bpm.Valid {
+			track.BPM = &bpm.Float64
+		}
+		if bpmConf.Valid {
+			track.BPMConfidence = &bpmConf.Float64
+		}
+		if musicalKey.Valid {
+			v := musicalKey.String
+			track.MusicalKey = &v
+		}
+		if keyConf.Valid {
+			track.KeyConfidence = &keyConf.Float64
+		}
+		if analyzedAt.Valid {
+			track.AnalyzedAt = &analyzedAt.Time
+		}
+		if coverPath.Valid {
+			v := coverPath.String
+			track.CoverPath = &v
+		}
+
+		tracks = append(tracks, &track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read favourite tracks: %w", err)
+	}
+
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*)`+favouritesFrom+cond, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to get favourite track count: %w", err)
+	}
+
+	return &imodels.TrackList{
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
+	}, nil
+}
@@ -1,12 +1,17 @@
 package playlists
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+	"github.com/faraz525/home-music-server/backend/search"
 )
 
 // CreatePlaylistHandler creates a new playlist
@@ -74,7 +79,10 @@ func GetPlaylistsHandler(manager *Manager) gin.HandlerFunc {
 			offset = 0
 		}
 
-		playlists, err := manager.GetUserPlaylists(userID.(string), limit, offset)
+		playlists, err := manager.GetUserPlaylists(userID.(string), c.Query("cursor"), limit, offset)
+		if invalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, imodels.APIResponse{
 				Success: false,
@@ -160,6 +168,9 @@ func UpdatePlaylistHandler(manager *Manager) gin.HandlerFunc {
 			} else if err.Error() == "access denied: playlist belongs to another user" ||
 				err.Error() == "cannot modify the default playlist" {
 				statusCode = http.StatusForbidden
+			} else if strings.HasPrefix(err.Error(), "invalid rules") ||
+				err.Error() == "rules can only be set on a smart crate" {
+				statusCode = http.StatusBadRequest
 			}
 
 			c.JSON(statusCode, imodels.APIResponse{
@@ -246,6 +257,8 @@ func AddTracksToPlaylistHandler(manager *Manager) gin.HandlerFunc {
 				statusCode = http.StatusNotFound
 			} else if err.Error() == "access denied: playlist belongs to another user" {
 				statusCode = http.StatusForbidden
+			} else if err.Error() == "cannot add tracks to a smart crate" {
+				statusCode = http.StatusConflict
 			}
 
 			c.JSON(statusCode, imodels.APIResponse{
@@ -293,6 +306,8 @@ func RemoveTracksFromPlaylistHandler(manager *Manager) gin.HandlerFunc {
 				statusCode = http.StatusNotFound
 			} else if err.Error() == "access denied: playlist belongs to another user" {
 				statusCode = http.StatusForbidden
+			} else if err.Error() == "cannot remove tracks from a smart crate" {
+				statusCode = http.StatusConflict
 			}
 
 			c.JSON(statusCode, imodels.APIResponse{
@@ -338,7 +353,20 @@ func GetPlaylistTracksHandler(manager *Manager) gin.HandlerFunc {
 			offset = 0
 		}
 
-		playlistTracks, err := manager.GetPlaylistTracks(playlistID, userID.(string), limit, offset)
+		filter, err := search.ParseListFilter(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "invalid_filter", Message: err.Error()},
+			})
+			return
+		}
+		filter.UserID = userID.(string)
+
+		playlistTracks, err := manager.GetPlaylistTracks(playlistID, userID.(string), filter, limit, offset)
+		if invalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			statusCode := http.StatusInternalServerError
 			if err.Error() == "playlist not found" {
@@ -388,7 +416,20 @@ func GetUnsortedTracksHandler(manager *Manager) gin.HandlerFunc {
 			offset = 0
 		}
 
-		tracks, err := manager.GetUnsortedTracks(userID.(string), limit, offset)
+		filter, err := search.ParseListFilter(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "invalid_filter", Message: err.Error()},
+			})
+			return
+		}
+		filter.UserID = userID.(string)
+
+		tracks, err := manager.GetUnsortedTracks(userID.(string), filter, limit, offset)
+		if invalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, imodels.APIResponse{
 				Success: false,
@@ -404,6 +445,62 @@ func GetUnsortedTracksHandler(manager *Manager) gin.HandlerFunc {
 	}
 }
 
+// GetFavouriteTracksHandler returns the user's favourite tracks
+func GetFavouriteTracksHandler(manager *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user ID from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "unauthorized", Message: "User not authenticated"},
+			})
+			return
+		}
+
+		// Parse pagination parameters
+		limitStr := c.DefaultQuery("limit", "20")
+		offsetStr := c.DefaultQuery("offset", "0")
+
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+
+		filter, err := search.ParseListFilter(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "invalid_filter", Message: err.Error()},
+			})
+			return
+		}
+		filter.UserID = userID.(string)
+
+		tracks, err := manager.GetFavouriteTracks(userID.(string), filter, limit, offset)
+		if invalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "server_error", Message: "Failed to fetch favourite tracks"},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, imodels.APIResponse{
+			Success: true,
+			Data:    tracks,
+		})
+	}
+}
+
 // GetPublicPlaylistsHandler returns all public playlists
 func GetPublicPlaylistsHandler(manager *Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -421,7 +518,10 @@ func GetPublicPlaylistsHandler(manager *Manager) gin.HandlerFunc {
 			offset = 0
 		}
 
-		playlists, total, hasNext, err := manager.GetPublicPlaylists(limit, offset)
+		playlists, total, next, err := manager.GetPublicPlaylists(c.Query("cursor"), limit, offset)
+		if invalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, imodels.APIResponse{
 				Success: false,
@@ -430,15 +530,20 @@ func GetPublicPlaylistsHandler(manager *Manager) gin.HandlerFunc {
 			return
 		}
 
+		data := map[string]interface{}{
+			"crates":   playlists,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+			"has_next": next != "",
+		}
+		if next != "" {
+			data["next_cursor"] = next
+		}
+
 		c.JSON(http.StatusOK, imodels.APIResponse{
 			Success: true,
-			Data: map[string]interface{}{
-				"crates":   playlists,
-				"total":    total,
-				"limit":    limit,
-				"offset":   offset,
-				"has_next": hasNext,
-			},
+			Data:    data,
 		})
 	}
 }
@@ -492,3 +597,69 @@ func UpdatePlaylistVisibilityHandler(manager *Manager) gin.HandlerFunc {
 		})
 	}
 }
+
+// FreezePlaylistHandler copies a smart crate's current tracks into a new
+// normal crate
+func FreezePlaylistHandler(manager *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user ID from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "unauthorized", Message: "User not authenticated"},
+			})
+			return
+		}
+
+		playlistID := c.Param("id")
+
+		// The body is optional; without one the new crate gets a default name
+		var req imodels.FreezePlaylistRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "invalid_request", Message: err.Error()},
+			})
+			return
+		}
+
+		playlist, err := manager.FreezePlaylist(playlistID, userID.(string), &req)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			if err.Error() == "playlist not found" {
+				statusCode = http.StatusNotFound
+			} else if err.Error() == "access denied: playlist belongs to another user" {
+				statusCode = http.StatusForbidden
+			} else if err.Error() == "only smart crates can be frozen" {
+				statusCode = http.StatusConflict
+			} else if strings.HasPrefix(err.Error(), "playlist name") {
+				statusCode = http.StatusBadRequest
+			}
+
+			c.JSON(statusCode, imodels.APIResponse{
+				Success: false,
+				Error:   &imodels.APIError{Code: "freeze_failed", Message: err.Error()},
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, imodels.APIResponse{
+			Success: true,
+			Data:    playlist,
+		})
+	}
+}
+
+// invalidCursor responds with 400 when err is a bad cursor, reporting
+// whether it did
+func invalidCursor(c *gin.Context, err error) bool {
+	if !errors.Is(err, pagination.ErrInvalidCursor) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, imodels.APIResponse{
+		Success: false,
+		Error:   &imodels.APIError{Code: "invalid_cursor", Message: err.Error()},
+	})
+	return true
+}
@@ -2,8 +2,11 @@ package playlists
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
 )
 
 // Manager handles business logic for playlists
@@ -31,6 +34,17 @@ func (m *Manager) CreatePlaylist(ownerUserID string, req *imodels.CreatePlaylist
 		return nil, fmt.Errorf("playlist description cannot exceed 500 characters")
 	}
 
+	// Rules make a smart crate; store them validated and normalised
+	if hasRules(req.Rules) {
+		rules, err := normaliseRules(req.Rules)
+		if err != nil {
+			return nil, err
+		}
+		req.Rules = rules
+	} else {
+		req.Rules = nil
+	}
+
 	// Create the playlist
 	playlist, err := m.repo.CreatePlaylist(ownerUserID, req)
 	if err != nil {
@@ -40,8 +54,9 @@ func (m *Manager) CreatePlaylist(ownerUserID string, req *imodels.CreatePlaylist
 	return playlist, nil
 }
 
-// GetUserPlaylists returns all playlists for a user, including virtual "Unsorted" crate
-func (m *Manager) GetUserPlaylists(userID string, limit, offset int) (*imodels.PlaylistList, error) {
+// GetUserPlaylists returns all playlists for a user, including virtual
+// "Unsorted" crate, starting after cursor when it is set
+func (m *Manager) GetUserPlaylists(userID, cursor string, limit, offset int) (*imodels.PlaylistList, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -49,7 +64,7 @@ func (m *Manager) GetUserPlaylists(userID string, limit, offset int) (*imodels.P
 		offset = 0
 	}
 
-	return m.repo.GetUserPlaylistsWithVirtual(userID, limit, offset)
+	return m.repo.GetUserPlaylistsWithVirtual(userID, cursor, limit, offset)
 }
 
 // GetPlaylist returns a specific playlist with ownership validation
@@ -109,6 +124,20 @@ func (m *Manager) UpdatePlaylist(playlistID, requestingUserID string, req *imode
 		return fmt.Errorf("playlist description cannot exceed 500 characters")
 	}
 
+	// Only smart crates have rules to replace
+	if hasRules(req.Rules) {
+		if !playlist.IsSmart {
+			return fmt.Errorf("rules can only be set on a smart crate")
+		}
+		rules, err := normaliseRules(req.Rules)
+		if err != nil {
+			return err
+		}
+		req.Rules = rules
+	} else {
+		req.Rules = nil
+	}
+
 	return m.repo.UpdatePlaylist(playlistID, req)
 }
 
@@ -165,6 +194,10 @@ func (m *Manager) AddTracksToPlaylist(playlistID, requestingUserID string, req *
 		return fmt.Errorf("access denied: playlist belongs to another user")
 	}
 
+	if playlist.IsSmart {
+		return fmt.Errorf("cannot add tracks to a smart crate")
+	}
+
 	// TODO: Validate that tracks belong to the user
 	// This would require checking track ownership in the tracks repository
 
@@ -192,11 +225,16 @@ func (m *Manager) RemoveTracksFromPlaylist(playlistID, requestingUserID string,
 		return fmt.Errorf("access denied: playlist belongs to another user")
 	}
 
+	if playlist.IsSmart {
+		return fmt.Errorf("cannot remove tracks from a smart crate")
+	}
+
 	return m.repo.RemoveTracksFromPlaylist(playlistID, req.TrackIDs)
 }
 
-// GetPlaylistTracks returns tracks for a playlist with ownership validation
-func (m *Manager) GetPlaylistTracks(playlistID, requestingUserID string, limit, offset int) (*imodels.PlaylistWithTracks, error) {
+// GetPlaylistTracks returns tracks for a playlist with ownership validation,
+// narrowed and ordered by f
+func (m *Manager) GetPlaylistTracks(playlistID, requestingUserID string, f *search.ListFilter, limit, offset int) (*imodels.PlaylistWithTracks, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -206,7 +244,7 @@ func (m *Manager) GetPlaylistTracks(playlistID, requestingUserID string, limit,
 
 	// Handle virtual "unsorted" playlist
 	if playlistID == "unsorted" {
-		trackList, err := m.repo.GetTracksNotInPlaylist(requestingUserID, limit, offset)
+		trackList, err := m.repo.GetTracksNotInPlaylist(requestingUserID, f, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -220,11 +258,12 @@ func (m *Manager) GetPlaylistTracks(playlistID, requestingUserID string, limit,
 				Description: &description,
 				IsDefault:   true,
 			},
-			Tracks:  trackList.Tracks,
-			Total:   trackList.Total,
-			Limit:   trackList.Limit,
-			Offset:  trackList.Offset,
-			HasNext: trackList.HasNext,
+			Tracks:     trackList.Tracks,
+			Total:      trackList.Total,
+			Limit:      trackList.Limit,
+			Offset:     trackList.Offset,
+			HasNext:    trackList.HasNext,
+			NextCursor: trackList.NextCursor,
 		}, nil
 	}
 
@@ -239,11 +278,60 @@ func (m *Manager) GetPlaylistTracks(playlistID, requestingUserID string, limit,
 		return nil, fmt.Errorf("access denied: playlist belongs to another user")
 	}
 
-	return m.repo.GetPlaylistTracks(playlistID, limit, offset)
+	// Smart crates are evaluated against the owner's library on every read
+	if playlist.IsSmart {
+		rules, err := playlistRules(playlist)
+		if err != nil {
+			return nil, err
+		}
+		trackList, err := m.repo.GetSmartPlaylistTracks(playlist.OwnerUserID, rules, nil, f, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		return &imodels.PlaylistWithTracks{
+			Playlist:   playlist,
+			Tracks:     trackList.Tracks,
+			Total:      trackList.Total,
+			Limit:      trackList.Limit,
+			Offset:     trackList.Offset,
+			HasNext:    trackList.HasNext,
+			NextCursor: trackList.NextCursor,
+		}, nil
+	}
+
+	return m.repo.GetPlaylistTracks(playlistID, f, limit, offset)
 }
 
-// GetUnsortedTracks returns tracks not in any playlist for a user
-func (m *Manager) GetUnsortedTracks(userID string, limit, offset int) (*imodels.TrackList, error) {
+// TrackScope returns a condition on tracks aliased t that picks a
+// playlist's tracks, for counting over them (see tracks.Manager.Facets).
+// It checks access as GetPlaylistTracks does, and takes "unsorted" too.
+func (m *Manager) TrackScope(playlistID, requestingUserID string) (string, []any, error) {
+	if playlistID == "unsorted" {
+		return "t.owner_user_id = ? AND NOT EXISTS (SELECT 1 FROM playlist_tracks pt WHERE pt.track_id = t.id)",
+			[]any{requestingUserID}, nil
+	}
+
+	playlist, err := m.repo.GetPlaylist(playlistID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !playlist.IsPublic && playlist.OwnerUserID != requestingUserID {
+		return "", nil, fmt.Errorf("access denied: playlist belongs to another user")
+	}
+	if playlist.IsSmart {
+		rules, err := playlistRules(playlist)
+		if err != nil {
+			return "", nil, err
+		}
+		members, args := rules.Members(playlist.OwnerUserID, time.Now())
+		return "1 = 1" + members, args, nil
+	}
+	return "t.id IN (SELECT pt.track_id FROM playlist_tracks pt WHERE pt.playlist_id = ?)", []any{playlistID}, nil
+}
+
+// GetUnsortedTracks returns tracks not in any playlist for a user, narrowed
+// and ordered by f
+func (m *Manager) GetUnsortedTracks(userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -251,7 +339,20 @@ func (m *Manager) GetUnsortedTracks(userID string, limit, offset int) (*imodels.
 		offset = 0
 	}
 
-	return m.repo.GetTracksNotInPlaylist(userID, limit, offset)
+	return m.repo.GetTracksNotInPlaylist(userID, f, limit, offset)
+}
+
+// GetFavouriteTracks returns the user's favourite tracks: the Favourites
+// virtual crate
+func (m *Manager) GetFavouriteTracks(userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return m.repo.GetFavouriteTracks(userID, f, limit, offset)
 }
 
 // GetDefaultPlaylist returns the default playlist for a user
@@ -273,8 +374,9 @@ func (m *Manager) CanAccessPlaylist(playlistID, userID string) error {
 	return nil
 }
 
-// SearchUnsortedTracks searches tracks not in any playlist using FTS5
-func (m *Manager) SearchUnsortedTracks(userID, query string, limit, offset int) (*imodels.TrackList, error) {
+// SearchUnsortedTracks searches tracks not in any playlist with a parsed
+// query, narrowed and ordered by f
+func (m *Manager) SearchUnsortedTracks(userID string, q *search.Query, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -282,11 +384,12 @@ func (m *Manager) SearchUnsortedTracks(userID, query string, limit, offset int)
 		offset = 0
 	}
 
-	return m.repo.SearchTracksNotInPlaylist(userID, query, limit, offset)
+	return m.repo.SearchTracksNotInPlaylist(userID, q, f, limit, offset)
 }
 
-// SearchPlaylistTracks searches within a specific playlist using FTS5
-func (m *Manager) SearchPlaylistTracks(playlistID, userID, query string, limit, offset int) (*imodels.TrackList, error) {
+// SearchPlaylistTracks searches within a specific playlist with a parsed
+// query, narrowed and ordered by f
+func (m *Manager) SearchPlaylistTracks(playlistID, userID string, q *search.Query, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -304,11 +407,62 @@ func (m *Manager) SearchPlaylistTracks(playlistID, userID, query string, limit,
 		return nil, fmt.Errorf("access denied: playlist belongs to another user")
 	}
 
-	return m.repo.SearchPlaylistTracks(playlistID, query, limit, offset)
+	if playlist.IsSmart {
+		rules, err := playlistRules(playlist)
+		if err != nil {
+			return nil, err
+		}
+		return m.repo.GetSmartPlaylistTracks(userID, rules, q, f, limit, offset)
+	}
+
+	return m.repo.SearchPlaylistTracks(playlistID, userID, q, f, limit, offset)
+}
+
+// FreezePlaylist copies a smart crate's current tracks, in its order, into
+// a new normal crate named req.Name (by default the smart crate's name
+// with " (frozen)" added). The smart crate itself is left as it is.
+func (m *Manager) FreezePlaylist(playlistID, requestingUserID string, req *imodels.FreezePlaylistRequest) (*imodels.Playlist, error) {
+	playlist, err := m.repo.GetPlaylist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	if playlist.OwnerUserID != requestingUserID {
+		return nil, fmt.Errorf("access denied: playlist belongs to another user")
+	}
+
+	if !playlist.IsSmart {
+		return nil, fmt.Errorf("only smart crates can be frozen")
+	}
+
+	name := playlist.Name + " (frozen)"
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("playlist name cannot be empty")
+	}
+	if len(name) > 100 {
+		return nil, fmt.Errorf("playlist name cannot exceed 100 characters")
+	}
+
+	rules, err := playlistRules(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	isPublic := playlist.IsPublic
+	return m.repo.FreezeSmartPlaylist(requestingUserID, rules, &imodels.CreatePlaylistRequest{
+		Name:        name,
+		Description: playlist.Description,
+		IsPublic:    &isPublic,
+	})
 }
 
-// GetPublicPlaylists returns all public playlists with pagination
-func (m *Manager) GetPublicPlaylists(limit, offset int) ([]*imodels.PlaylistWithOwner, int, bool, error) {
+// GetPublicPlaylists returns all public playlists with pagination, starting
+// after cursor when it is set, with the total and the next page's cursor
+// ("" on the last page)
+func (m *Manager) GetPublicPlaylists(cursor string, limit, offset int) ([]*imodels.PlaylistWithOwner, int, string, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
@@ -316,13 +470,7 @@ func (m *Manager) GetPublicPlaylists(limit, offset int) ([]*imodels.PlaylistWith
 		offset = 0
 	}
 
-	playlists, total, err := m.repo.GetPublicPlaylists(limit, offset)
-	if err != nil {
-		return nil, 0, false, err
-	}
-
-	hasNext := offset+limit < total
-	return playlists, total, hasNext, nil
+	return m.repo.GetPublicPlaylists(cursor, limit, offset)
 }
 
 // UpdatePlaylistVisibility updates the visibility of a playlist with ownership validation
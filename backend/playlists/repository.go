@@ -8,6 +8,8 @@ import (
 
 	idb "github.com/faraz525/home-music-server/backend/internal/db"
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+	"github.com/faraz525/home-music-server/backend/search"
 	"github.com/google/uuid"
 )
 
@@ -36,8 +38,8 @@ func (r *Repository) CreatePlaylist(ownerUserID string, req *imodels.CreatePlayl
 	}
 
 	query := `
-		INSERT INTO playlists (id, owner_user_id, name, description, is_default, is_public)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO playlists (id, owner_user_id, name, description, is_default, is_public, rules)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
 	var description interface{}
@@ -53,7 +55,13 @@ func (r *Repository) CreatePlaylist(ownerUserID string, req *imodels.CreatePlayl
 		isPublic = *req.IsPublic
 	}
 
-	_, err = r.db.DB.Exec(query, id, ownerUserID, req.Name, description, false, isPublic)
+	// Normal crates have no rules
+	var rules interface{}
+	if len(req.Rules) > 0 {
+		rules = string(req.Rules)
+	}
+
+	_, err = r.db.DB.Exec(query, id, ownerUserID, req.Name, description, false, isPublic, rules)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create playlist: %w", err)
 	}
@@ -61,34 +69,45 @@ func (r *Repository) CreatePlaylist(ownerUserID string, req *imodels.CreatePlayl
 	return r.GetPlaylist(id)
 }
 
-// GetUserPlaylists returns all playlists for a user, including a virtual "Unsorted" playlist
-func (r *Repository) GetUserPlaylists(userID string, limit, offset int) (*imodels.PlaylistList, error) {
+// userPlaylistKeys order a user's playlists: the default first, then newest
+var userPlaylistKeys = []pagination.Key{{Expr: "is_default", Desc: true}, {Expr: "created_at", Desc: true}, {Expr: "id"}}
+
+// GetUserPlaylists returns all playlists for a user, starting after cursor
+// when it is set
+func (r *Repository) GetUserPlaylists(userID, cursor string, limit, offset int) (*imodels.PlaylistList, error) {
+	after, afterArgs, err := pagination.After(cursor, userPlaylistKeys)
+	if err != nil {
+		return nil, err
+	}
 	query := `
-		SELECT id, owner_user_id, name, description, is_default, is_public, created_at, updated_at
+		SELECT id, owner_user_id, name, description, is_default, is_public, rules, created_at, updated_at` + pagination.Columns(userPlaylistKeys) + `
 		FROM playlists
-		WHERE owner_user_id = ?
-		ORDER BY is_default DESC, created_at DESC
+		WHERE owner_user_id = ?` + after + `
+		ORDER BY ` + pagination.OrderBy(userPlaylistKeys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(query, userID, limit, offset)
+	args := append(append([]any{userID}, afterArgs...), limit+1, offset)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user playlists: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, userPlaylistKeys)
 	var playlists []*imodels.Playlist
 	for rows.Next() {
 		playlist := &imodels.Playlist{}
-		var description sql.NullString
+		var description, rules sql.NullString
 
-		err := rows.Scan(
+		err := sc.Scan(
 			&playlist.ID,
 			&playlist.OwnerUserID,
 			&playlist.Name,
 			&description,
 			&playlist.IsDefault,
 			&playlist.IsPublic,
+			&rules,
 			&playlist.CreatedAt,
 			&playlist.UpdatedAt,
 		)
@@ -99,10 +118,16 @@ func (r *Repository) GetUserPlaylists(userID string, limit, offset int) (*imodel
 		if description.Valid {
 			playlist.Description = &description.String
 		}
+		setRules(playlist, rules)
 
 		playlists = append(playlists, playlist)
 	}
 
+	next := sc.Next(limit)
+	if len(playlists) > limit {
+		playlists = playlists[:limit]
+	}
+
 	// Get total count
 	countQuery := `SELECT COUNT(*) FROM playlists WHERE owner_user_id = ?`
 	var total int
@@ -111,27 +136,26 @@ func (r *Repository) GetUserPlaylists(userID string, limit, offset int) (*imodel
 		return nil, fmt.Errorf("failed to get playlist count: %w", err)
 	}
 
-	hasNext := offset+limit < total
-
 	return &imodels.PlaylistList{
-		Playlists: playlists,
-		Total:     total,
-		Limit:     limit,
-		Offset:    offset,
-		HasNext:   hasNext,
+		Playlists:  playlists,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
 	}, nil
 }
 
 // GetUserPlaylistsWithVirtual returns all playlists for a user, including a virtual "Unsorted" playlist
-func (r *Repository) GetUserPlaylistsWithVirtual(userID string, limit, offset int) (*imodels.PlaylistList, error) {
+func (r *Repository) GetUserPlaylistsWithVirtual(userID, cursor string, limit, offset int) (*imodels.PlaylistList, error) {
 	// First get the regular playlists
-	result, err := r.GetUserPlaylists(userID, limit, offset)
+	result, err := r.GetUserPlaylists(userID, cursor, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	// Only inject virtual "Unsorted" on first page (offset == 0)
-	if offset == 0 {
+	// Only inject virtual "Unsorted" on the first page
+	if offset == 0 && cursor == "" {
 		// Create virtual "Unsorted" playlist
 		description := "Tracks not assigned to any crate"
 		now := time.Now()
@@ -163,13 +187,13 @@ func (r *Repository) GetUserPlaylistsWithVirtual(userID string, limit, offset in
 // GetPlaylist returns a specific playlist by ID
 func (r *Repository) GetPlaylist(playlistID string) (*imodels.Playlist, error) {
 	query := `
-		SELECT id, owner_user_id, name, description, is_default, is_public, created_at, updated_at
+		SELECT id, owner_user_id, name, description, is_default, is_public, rules, created_at, updated_at
 		FROM playlists
 		WHERE id = ?
 	`
 
 	playlist := &imodels.Playlist{}
-	var description sql.NullString
+	var description, rules sql.NullString
 
 	err := r.db.QueryRow(query, playlistID).Scan(
 		&playlist.ID,
@@ -178,6 +202,7 @@ func (r *Repository) GetPlaylist(playlistID string) (*imodels.Playlist, error) {
 		&description,
 		&playlist.IsDefault,
 		&playlist.IsPublic,
+		&rules,
 		&playlist.CreatedAt,
 		&playlist.UpdatedAt,
 	)
@@ -192,6 +217,7 @@ func (r *Repository) GetPlaylist(playlistID string) (*imodels.Playlist, error) {
 	if description.Valid {
 		playlist.Description = &description.String
 	}
+	setRules(playlist, rules)
 
 	return playlist, nil
 }
@@ -216,6 +242,12 @@ func (r *Repository) UpdatePlaylist(playlistID string, req *imodels.UpdatePlayli
 		args = append(args, *req.IsPublic)
 	}
 
+	// Handle smart crate rules update if provided
+	if len(req.Rules) > 0 {
+		query += ", rules = ?"
+		args = append(args, string(req.Rules))
+	}
+
 	query += ", updated_at = CURRENT_TIMESTAMP WHERE id = ?"
 	args = append(args, playlistID)
 
@@ -291,13 +323,13 @@ func (r *Repository) CreateDefaultPlaylist(ownerUserID string) (*imodels.Playlis
 // GetDefaultPlaylist returns the default playlist for a user
 func (r *Repository) GetDefaultPlaylist(userID string) (*imodels.Playlist, error) {
 	query := `
-		SELECT id, owner_user_id, name, description, is_default, is_public, created_at, updated_at
+		SELECT id, owner_user_id, name, description, is_default, is_public, rules, created_at, updated_at
 		FROM playlists
 		WHERE owner_user_id = ? AND is_default = true
 	`
 
 	playlist := &imodels.Playlist{}
-	var description sql.NullString
+	var description, rules sql.NullString
 
 	err := r.db.QueryRow(query, userID).Scan(
 		&playlist.ID,
@@ -306,6 +338,7 @@ func (r *Repository) GetDefaultPlaylist(userID string) (*imodels.Playlist, error
 		&description,
 		&playlist.IsDefault,
 		&playlist.IsPublic,
+		&rules,
 		&playlist.CreatedAt,
 		&playlist.UpdatedAt,
 	)
@@ -320,6 +353,7 @@ func (r *Repository) GetDefaultPlaylist(userID string) (*imodels.Playlist, error
 	if description.Valid {
 		playlist.Description = &description.String
 	}
+	setRules(playlist, rules)
 
 	return playlist, nil
 }
@@ -429,8 +463,9 @@ func (r *Repository) RemoveTracksFromPlaylist(playlistID string, trackIDs []stri
 	return tx.Commit()
 }
 
-// GetPlaylistTracks returns tracks for a specific playlist with pagination
-func (r *Repository) GetPlaylistTracks(playlistID string, limit, offset int) (*imodels.PlaylistWithTracks, error) {
+// GetPlaylistTracks returns tracks for a specific playlist with pagination,
+// narrowed and ordered by f
+func (r *Repository) GetPlaylistTracks(playlistID string, f *search.ListFilter, limit, offset int) (*imodels.PlaylistWithTracks, error) {
 	// First get the playlist
 	playlist, err := r.GetPlaylist(playlistID)
 	if err != nil {
@@ -438,33 +473,43 @@ func (r *Repository) GetPlaylistTracks(playlistID string, limit, offset int) (*i
 	}
 
 	// Get tracks for this playlist
+	cond, args := f.Where("t.")
+	keys := f.PageKeys("t.", pagination.Key{Expr: "pt.added_at", Desc: true})
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
 	query := `
 		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 		       t.sample_rate, t.bitrate,
-		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
 		       t.file_path, t.cover_path, t.created_at, t.updated_at,
-		       pt.added_at
+		       pt.added_at` + pagination.Columns(keys) + `
 		FROM tracks t
 		INNER JOIN playlist_tracks pt ON t.id = pt.track_id
-		WHERE pt.playlist_id = ?
-		ORDER BY pt.added_at DESC
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL` + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(query, playlistID, limit, offset)
+	args = append([]any{playlistID}, args...)
+	rows, err := r.db.Query(query, pagination.QueryArgs(keys, append(args, afterArgs...), limit+1, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playlist tracks: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, keys)
 	var tracks []*imodels.Track
 	for rows.Next() {
 		var track imodels.Track
 		var bpm, bpmConf, keyConf sql.NullFloat64
 		var musicalKey, coverPath sql.NullString
 		var analyzedAt sql.NullTime
-		err := rows.Scan(
+		err := sc.Scan(
 			&track.ID,
 			&track.OwnerUserID,
 			&track.OriginalFilename,
@@ -484,6 +529,20 @@ func (r *Repository) GetPlaylistTracks(playlistID string, limit, offset int) (*i
 			&keyConf,
 			&analyzedAt,
 			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
 			&track.FilePath,
 			&coverPath,
 			&track.CreatedAt,
@@ -517,57 +576,73 @@ func (r *Repository) GetPlaylistTracks(playlistID string, limit, offset int) (*i
 		tracks = append(tracks, &track)
 	}
 
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
 	// Get total count
-	countQuery := `SELECT COUNT(*) FROM playlist_tracks WHERE playlist_id = ?`
+	countQuery := `SELECT COUNT(*) FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL` + cond
 	var total int
-	err = r.db.QueryRow(countQuery, playlistID).Scan(&total)
+	err = r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playlist track count: %w", err)
 	}
 
-	hasNext := offset+limit < total
-
 	return &imodels.PlaylistWithTracks{
-		Playlist: playlist,
-		Tracks:   tracks,
-		Total:    total,
-		Limit:    limit,
-		Offset:   offset,
-		HasNext:  hasNext,
+		Playlist:   playlist,
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
 	}, nil
 }
 
-// GetTracksNotInPlaylist returns tracks that are not in any playlist for a user
+// GetTracksNotInPlaylist returns tracks that are not in any playlist for a user,
+// narrowed and ordered by f
 // Optimized query using LEFT JOIN instead of NOT IN for better performance on Raspberry Pi
-func (r *Repository) GetTracksNotInPlaylist(userID string, limit, offset int) (*imodels.TrackList, error) {
+func (r *Repository) GetTracksNotInPlaylist(userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	cond, args := f.Where("t.")
+	keys := f.PageKeys("t.", pagination.Key{Expr: "t.created_at", Desc: true})
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
 	query := `
 		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 		       t.sample_rate, t.bitrate,
-		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
-		       t.file_path, t.cover_path, t.created_at, t.updated_at
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+		       t.file_path, t.cover_path, t.created_at, t.updated_at` + pagination.Columns(keys) + `
 		FROM tracks t
 		LEFT JOIN playlist_tracks pt ON t.id = pt.track_id
 		WHERE t.owner_user_id = ?
-		AND pt.track_id IS NULL
+		AND pt.track_id IS NULL AND t.deleted_at IS NULL` + cond + after + `
 		GROUP BY t.id
-		ORDER BY t.created_at DESC
+		ORDER BY ` + pagination.OrderBy(keys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(query, userID, limit, offset)
+	rows, err := r.db.Query(query, pagination.QueryArgs(keys, append(append([]any{userID}, args...), afterArgs...), limit+1, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tracks not in playlist: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, keys)
 	var tracks []*imodels.Track
 	for rows.Next() {
 		var track imodels.Track
 		var bpm, bpmConf, keyConf sql.NullFloat64
 		var musicalKey, coverPath sql.NullString
 		var analyzedAt sql.NullTime
-		err := rows.Scan(
+		err := sc.Scan(
 			&track.ID,
 			&track.OwnerUserID,
 			&track.OriginalFilename,
@@ -587,6 +662,20 @@ func (r *Repository) GetTracksNotInPlaylist(userID string, limit, offset int) (*
 			&keyConf,
 			&analyzedAt,
 			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
 			&track.FilePath,
 			&coverPath,
 			&track.CreatedAt,
@@ -619,71 +708,86 @@ func (r *Repository) GetTracksNotInPlaylist(userID string, limit, offset int) (*
 		tracks = append(tracks, &track)
 	}
 
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
 	// Get total count
-	total, err := r.GetUnsortedTrackCount(userID)
+	total, err := r.GetUnsortedTrackCount(userID, f)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get track count: %w", err)
 	}
 
-	hasNext := offset+limit < total
-
 	return &imodels.TrackList{
-		Tracks:  tracks,
-		Total:   total,
-		Limit:   limit,
-		Offset:  offset,
-		HasNext: hasNext,
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
 	}, nil
 }
 
 // GetUnsortedTrackCount returns the count of tracks not in any playlist for a user
+// that match f
 // Optimized query using LEFT JOIN instead of NOT IN for better performance on Raspberry Pi
-func (r *Repository) GetUnsortedTrackCount(userID string) (int, error) {
+func (r *Repository) GetUnsortedTrackCount(userID string, f *search.ListFilter) (int, error) {
+	cond, args := f.Where("t.")
 	countQuery := `
 		SELECT COUNT(DISTINCT t.id) FROM tracks t
 		LEFT JOIN playlist_tracks pt ON t.id = pt.track_id
 		WHERE t.owner_user_id = ?
-		AND pt.track_id IS NULL
-	`
+		AND pt.track_id IS NULL AND t.deleted_at IS NULL` + cond
 	var total int
-	err := r.db.QueryRow(countQuery, userID).Scan(&total)
+	err := r.db.QueryRow(countQuery, append([]any{userID}, args...)...).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get unsorted track count: %w", err)
 	}
 	return total, nil
 }
 
-// SearchTracksNotInPlaylist searches unsorted tracks using FTS5
-func (r *Repository) SearchTracksNotInPlaylist(userID, query string, limit, offset int) (*imodels.TrackList, error) {
+// SearchTracksNotInPlaylist searches unsorted tracks with a parsed query
+func (r *Repository) SearchTracksNotInPlaylist(userID string, q *search.Query, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	compiled := q.Compile(userID)
+	keys := f.PageKeys("t.", compiled.PageKeys(pagination.Key{Expr: "t.created_at", Desc: true})...)
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
+	qcond, qargs := compiled.Where()
+	cond, fargs := f.Where("t.")
 	searchQuery := `
 		SELECT DISTINCT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 		       t.sample_rate, t.bitrate,
-		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
-		       t.file_path, t.created_at, t.updated_at
-		FROM tracks t
-		INNER JOIN tracks_fts fts ON t.id = fts.track_id
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+		       t.file_path, t.created_at, t.updated_at` + pagination.Columns(keys) + `
+		FROM tracks t` + compiled.Join() + `
 		LEFT JOIN playlist_tracks pt ON t.id = pt.track_id
 		WHERE t.owner_user_id = ?
-		AND pt.track_id IS NULL
-		AND tracks_fts MATCH ?
-		ORDER BY fts.rank, t.created_at DESC
+		AND pt.track_id IS NULL AND t.deleted_at IS NULL` + qcond + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(searchQuery, userID, query, limit, offset)
+	args := append(append([]any{userID}, qargs...), fargs...)
+	rows, err := r.db.Query(searchQuery, pagination.QueryArgs(keys, append(args, afterArgs...), limit+1, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search unsorted tracks: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, keys)
 	var tracks []*imodels.Track
 	for rows.Next() {
 		var track imodels.Track
 		var bpm, bpmConf, keyConf sql.NullFloat64
 		var musicalKey sql.NullString
 		var analyzedAt sql.NullTime
-		err := rows.Scan(
+		err := sc.Scan(
 			&track.ID,
 			&track.OwnerUserID,
 			&track.OriginalFilename,
@@ -703,6 +807,20 @@ func (r *Repository) SearchTracksNotInPlaylist(userID, query string, limit, offs
 			&keyConf,
 			&analyzedAt,
 			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
 			&track.FilePath,
 			&track.CreatedAt,
 			&track.UpdatedAt,
@@ -730,63 +848,76 @@ func (r *Repository) SearchTracksNotInPlaylist(userID, query string, limit, offs
 		tracks = append(tracks, &track)
 	}
 
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
 	// Get total count of matching unsorted tracks
 	countQuery := `
 		SELECT COUNT(DISTINCT t.id)
-		FROM tracks t
-		INNER JOIN tracks_fts fts ON t.id = fts.track_id
+		FROM tracks t` + compiled.Join() + `
 		LEFT JOIN playlist_tracks pt ON t.id = pt.track_id
 		WHERE t.owner_user_id = ?
-		AND pt.track_id IS NULL
-		AND tracks_fts MATCH ?
+		AND pt.track_id IS NULL AND t.deleted_at IS NULL` + qcond + cond + `
 	`
 	var total int
-	err = r.db.QueryRow(countQuery, userID, query).Scan(&total)
+	err = r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count matching unsorted tracks: %w", err)
 	}
 
-	hasNext := offset+limit < total
-
 	return &imodels.TrackList{
-		Tracks:  tracks,
-		Total:   total,
-		Limit:   limit,
-		Offset:  offset,
-		HasNext: hasNext,
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
 	}, nil
 }
 
-// SearchPlaylistTracks searches within a specific playlist using FTS5
-func (r *Repository) SearchPlaylistTracks(playlistID, query string, limit, offset int) (*imodels.TrackList, error) {
+// SearchPlaylistTracks searches within a specific playlist with a query
+// parsed for userID
+func (r *Repository) SearchPlaylistTracks(playlistID, userID string, q *search.Query, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	compiled := q.Compile(userID)
+	keys := f.PageKeys("t.", compiled.PageKeys(pagination.Key{Expr: "pt.position"}, pagination.Key{Expr: "t.created_at", Desc: true})...)
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
+	qcond, qargs := compiled.Where()
+	cond, fargs := f.Where("t.")
 	searchQuery := `
 		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 		       t.sample_rate, t.bitrate,
-		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
-		       t.file_path, t.created_at, t.updated_at
-		FROM tracks t
-		INNER JOIN tracks_fts fts ON t.id = fts.track_id
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+		       t.file_path, t.created_at, t.updated_at` + pagination.Columns(keys) + `
+		FROM tracks t` + compiled.Join() + `
 		INNER JOIN playlist_tracks pt ON t.id = pt.track_id
-		WHERE pt.playlist_id = ?
-		AND tracks_fts MATCH ?
-		ORDER BY fts.rank, pt.position, t.created_at DESC
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL` + qcond + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(searchQuery, playlistID, query, limit, offset)
+	args := append(append([]any{playlistID}, qargs...), fargs...)
+	rows, err := r.db.Query(searchQuery, pagination.QueryArgs(keys, append(args, afterArgs...), limit+1, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search playlist tracks: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, keys)
 	var tracks []*imodels.Track
 	for rows.Next() {
 		var track imodels.Track
 		var bpm, bpmConf, keyConf sql.NullFloat64
 		var musicalKey sql.NullString
 		var analyzedAt sql.NullTime
-		err := rows.Scan(
+		err := sc.Scan(
 			&track.ID,
 			&track.OwnerUserID,
 			&track.OriginalFilename,
@@ -806,6 +937,20 @@ func (r *Repository) SearchPlaylistTracks(playlistID, query string, limit, offse
 			&keyConf,
 			&analyzedAt,
 			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
 			&track.FilePath,
 			&track.CreatedAt,
 			&track.UpdatedAt,
@@ -833,75 +978,88 @@ func (r *Repository) SearchPlaylistTracks(playlistID, query string, limit, offse
 		tracks = append(tracks, &track)
 	}
 
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
 	// Get total count of matching tracks in this playlist
 	countQuery := `
 		SELECT COUNT(*)
-		FROM tracks t
-		INNER JOIN tracks_fts fts ON t.id = fts.track_id
+		FROM tracks t` + compiled.Join() + `
 		INNER JOIN playlist_tracks pt ON t.id = pt.track_id
-		WHERE pt.playlist_id = ?
-		AND tracks_fts MATCH ?
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL` + qcond + cond + `
 	`
 	var total int
-	err = r.db.QueryRow(countQuery, playlistID, query).Scan(&total)
+	err = r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count matching playlist tracks: %w", err)
 	}
 
-	hasNext := offset+limit < total
-
 	return &imodels.TrackList{
-		Tracks:  tracks,
-		Total:   total,
-		Limit:   limit,
-		Offset:  offset,
-		HasNext: hasNext,
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
 	}, nil
 }
 
-// GetPublicPlaylists returns all public playlists with owner information
+// publicPlaylistKeys order public playlists newest first
+var publicPlaylistKeys = []pagination.Key{{Expr: "p.created_at", Desc: true}, {Expr: "p.id"}}
+
+// GetPublicPlaylists returns all public playlists with owner information,
+// starting after cursor when it is set, and the next page's cursor
 // Excludes default (unsorted) playlists which are always private
-func (r *Repository) GetPublicPlaylists(limit, offset int) ([]*imodels.PlaylistWithOwner, int, error) {
+func (r *Repository) GetPublicPlaylists(cursor string, limit, offset int) ([]*imodels.PlaylistWithOwner, int, string, error) {
+	after, afterArgs, err := pagination.After(cursor, publicPlaylistKeys)
+	if err != nil {
+		return nil, 0, "", err
+	}
 	query := `
-		SELECT p.id, p.owner_user_id, p.name, p.description, p.is_default, p.is_public,
-		       p.created_at, p.updated_at, u.email
+		SELECT p.id, p.owner_user_id, p.name, p.description, p.is_default, p.is_public, p.rules,
+		       p.created_at, p.updated_at, u.email` + pagination.Columns(publicPlaylistKeys) + `
 		FROM playlists p
 		INNER JOIN users u ON p.owner_user_id = u.id
-		WHERE p.is_public = TRUE AND p.is_default = FALSE
-		ORDER BY p.created_at DESC
+		WHERE p.is_public = TRUE AND p.is_default = FALSE` + after + `
+		ORDER BY ` + pagination.OrderBy(publicPlaylistKeys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(query, limit, offset)
+	rows, err := r.db.Query(query, append(afterArgs, limit+1, offset)...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get public playlists: %w", err)
+		return nil, 0, "", fmt.Errorf("failed to get public playlists: %w", err)
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, publicPlaylistKeys)
 	var playlists []*imodels.PlaylistWithOwner
 	for rows.Next() {
 		playlist := &imodels.Playlist{}
-		var description sql.NullString
+		var description, rules sql.NullString
 		var ownerEmail string
 
-		err := rows.Scan(
+		err := sc.Scan(
 			&playlist.ID,
 			&playlist.OwnerUserID,
 			&playlist.Name,
 			&description,
 			&playlist.IsDefault,
 			&playlist.IsPublic,
+			&rules,
 			&playlist.CreatedAt,
 			&playlist.UpdatedAt,
 			&ownerEmail,
 		)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan playlist: %w", err)
+			return nil, 0, "", fmt.Errorf("failed to scan playlist: %w", err)
 		}
 
 		if description.Valid {
 			playlist.Description = &description.String
 		}
+		setRules(playlist, rules)
 
 		playlistWithOwner := &imodels.PlaylistWithOwner{
 			Playlist:   playlist,
@@ -911,15 +1069,20 @@ func (r *Repository) GetPublicPlaylists(limit, offset int) ([]*imodels.PlaylistW
 		playlists = append(playlists, playlistWithOwner)
 	}
 
+	next := sc.Next(limit)
+	if len(playlists) > limit {
+		playlists = playlists[:limit]
+	}
+
 	// Get total count of public playlists
 	countQuery := `SELECT COUNT(*) FROM playlists WHERE is_public = TRUE AND is_default = FALSE`
 	var total int
 	err = r.db.QueryRow(countQuery).Scan(&total)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get public playlist count: %w", err)
+		return nil, 0, "", fmt.Errorf("failed to get public playlist count: %w", err)
 	}
 
-	return playlists, total, nil
+	return playlists, total, next, nil
 }
 
 // UpdatePlaylistVisibility updates the visibility of a playlist
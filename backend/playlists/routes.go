@@ -17,9 +17,15 @@ func Routes(m *Manager) func(*gin.RouterGroup) {
 		g.DELETE("/:id/tracks", RemoveTracksFromPlaylistHandler(m))
 		g.GET("/:id/tracks", GetPlaylistTracksHandler(m))
 
+		// Copy a smart crate's current tracks into a normal crate
+		g.POST("/:id/freeze", FreezePlaylistHandler(m))
+
 		// Special endpoint for unsorted tracks
 		g.GET("/unsorted", GetUnsortedTracksHandler(m))
 
+		// Virtual crate of the user's favourite tracks
+		g.GET("/favourites", GetFavouriteTracksHandler(m))
+
 		// Playlist visibility management
 		g.PATCH("/:id/visibility", UpdatePlaylistVisibilityHandler(m))
 
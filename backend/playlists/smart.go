@@ -0,0 +1,248 @@
+package playlists
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+	"github.com/faraz525/home-music-server/backend/search"
+	"github.com/google/uuid"
+)
+
+// setRules marks a playlist read from the database as smart if it has rules
+func setRules(playlist *imodels.Playlist, rules sql.NullString) {
+	if rules.Valid {
+		playlist.IsSmart = true
+		playlist.Rules = json.RawMessage(rules.String)
+	}
+}
+
+// hasRules reports whether a request carries smart crate rules; a JSON
+// null counts as none
+func hasRules(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
+
+// normaliseRules validates smart crate rules from a request and returns
+// them re-encoded in canonical form for storage
+func normaliseRules(raw json.RawMessage) (json.RawMessage, error) {
+	rules, err := search.ParseRules(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	return json.Marshal(rules)
+}
+
+// playlistRules parses a smart playlist's stored rules
+func playlistRules(playlist *imodels.Playlist) (*search.Rules, error) {
+	rules, err := search.ParseRules(playlist.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("smart crate %s has invalid rules: %w", playlist.ID, err)
+	}
+	return rules, nil
+}
+
+// GetSmartPlaylistTracks returns the tracks in ownerUserID's library that
+// match a smart playlist's rules right now, narrowed by q (if not nil) and
+// f. They come in the rules' order unless f or q orders them.
+func (r *Repository) GetSmartPlaylistTracks(ownerUserID string, rules *search.Rules, q *search.Query, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	members, args := rules.Members(ownerUserID, time.Now())
+	compiled := &search.Compiled{}
+	if q != nil {
+		compiled = q.Compile(ownerUserID)
+	}
+	keys := f.PageKeys("t.", compiled.PageKeys(rules.SortKeys("t.", ownerUserID)...)...)
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, err
+	}
+	qcond, qargs := compiled.Where()
+	cond, fargs := f.Where("t.")
+	args = append(append(args, qargs...), fargs...)
+
+	query := `
+		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
+		       t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
+		       t.sample_rate, t.bitrate,
+		       t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+		       t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+		       t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+		       t.file_path, t.cover_path, t.created_at, t.updated_at` + pagination.Columns(keys) + `
+		FROM tracks t` + compiled.Join() + `
+		WHERE 1 = 1` + members + qcond + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, pagination.QueryArgs(keys, append(args, afterArgs...), limit+1, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smart crate tracks: %w", err)
+	}
+	defer rows.Close()
+
+	sc := pagination.NewScanner(rows, keys)
+	var tracks []*imodels.Track
+	for rows.Next() {
+		var track imodels.Track
+		var bpm, bpmConf, keyConf sql.NullFloat64
+		var musicalKey, coverPath sql.NullString
+		var analyzedAt sql.NullTime
+		err := sc.Scan(
+			&track.ID,
+			&track.OwnerUserID,
+			&track.OriginalFilename,
+			&track.ContentType,
+			&track.SizeBytes,
+			&track.DurationSeconds,
+			&track.Title,
+			&track.Artist,
+			&track.Album,
+			&track.Genre,
+			&track.Year,
+			&track.SampleRate,
+			&track.Bitrate,
+			&bpm,
+			&bpmConf,
+			&musicalKey,
+			&keyConf,
+			&analyzedAt,
+			&track.AnalysisStatus,
+			&track.BPMBackend,
+			&track.KeyBackend,
+			&track.Energy,
+			&track.Danceability,
+			&track.DynamicComplexity,
+			&track.LoudnessLUFS,
+			&track.SpectralCentroid,
+			&track.OnsetRate,
+			&track.Mood,
+			&track.BPMRaw,
+			&track.BPMSuggested,
+			&track.QualityVerdict,
+			&track.QualityConfidence,
+			&track.SpectralCutoffHz,
+			&track.FilePath,
+			&coverPath,
+			&track.CreatedAt,
+			&track.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan track: %w", err)
+		}
+		if bpm.Valid {
+			track.BPM = &bpm.Float64
+		}
+		if bpmConf.Valid {
+			track.BPMConfidence = &bpmConf.Float64
+		}
+		if musicalKey.Valid {
+			v := musicalKey.String
+			track.MusicalKey = &v
+		}
+		if keyConf.Valid {
+			track.KeyConfidence = &keyConf.Float64
+		}
+		if analyzedAt.Valid {
+			track.AnalyzedAt = &analyzedAt.Time
+		}
+		if coverPath.Valid {
+			v := coverPath.String
+			track.CoverPath = &v
+		}
+
+		tracks = append(tracks, &track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read smart crate tracks: %w", err)
+	}
+
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	// Get total count
+	countQuery := `SELECT COUNT(*) FROM tracks t` + compiled.Join() + `
+		WHERE 1 = 1` + members + qcond + cond
+	var total int
+	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to get smart crate track count: %w", err)
+	}
+
+	return &imodels.TrackList{
+		Tracks:     tracks,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		HasNext:    next != "",
+		NextCursor: next,
+	}, nil
+}
+
+// FreezeSmartPlaylist creates a normal playlist holding the tracks that
+// match rules in ownerUserID's library right now, in the rules' order
+func (r *Repository) FreezeSmartPlaylist(ownerUserID string, rules *search.Rules, req *imodels.CreatePlaylistRequest) (*imodels.Playlist, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	members, args := rules.Members(ownerUserID, time.Now())
+	order, orderArgs := rules.OrderBy("t.", ownerUserID)
+	rows, err := tx.Query(`SELECT t.id FROM tracks t WHERE 1 = 1`+members+` ORDER BY `+order, append(args, orderArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smart crate tracks: %w", err)
+	}
+	var trackIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan track: %w", err)
+		}
+		trackIDs = append(trackIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read smart crate tracks: %w", err)
+	}
+
+	var description interface{}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	isPublic := true
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+	id := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO playlists (id, owner_user_id, name, description, is_default, is_public)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, id, ownerUserID, req.Name, description, false, isPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playlist: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO playlist_tracks (id, playlist_id, track_id, position)
+		VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for position, trackID := range trackIDs {
+		if _, err := stmt.Exec(uuid.New().String(), id, trackID, position); err != nil {
+			return nil, fmt.Errorf("failed to add track %s to playlist: %w", trackID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit frozen crate: %w", err)
+	}
+	return r.GetPlaylist(id)
+}
@@ -0,0 +1,137 @@
+package radio
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrStationNotFound):
+		return http.StatusNotFound, "station_not_found"
+	case errors.Is(err, ErrCrateNotFound):
+		return http.StatusNotFound, "crate_not_found"
+	case errors.Is(err, ErrEmptyCrate):
+		return http.StatusUnprocessableEntity, "empty_crate"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrFFmpegMissing):
+		return http.StatusServiceUnavailable, "ffmpeg_missing"
+	case errors.Is(err, ErrStopTimeout):
+		return http.StatusServiceUnavailable, "stop_timeout"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func (h *Handlers) ListStations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	userRole := c.GetString("user_role")
+	c.JSON(http.StatusOK, gin.H{"stations": h.manager.List(c.Request.Context(), userID, userRole)})
+}
+
+func (h *Handlers) GetStation(c *gin.Context) {
+	s, err := h.manager.Get(c.Param("id"))
+	if err == nil {
+		err = h.manager.CanListen(c.Request.Context(), s, c.GetString("user_id"), c.GetString("user_role"))
+	}
+	if err != nil {
+		status, code := errorStatus(err)
+		c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"station": s.Info()})
+}
+
+func (h *Handlers) StartStation(c *gin.Context) {
+	var req StartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	s, err := h.manager.Start(c.Request.Context(), &req)
+	if err != nil {
+		status, code := errorStatus(err)
+		c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"station": s.Info()})
+}
+
+func (h *Handlers) StopStation(c *gin.Context) {
+	if err := h.manager.Stop(c.Param("id")); err != nil {
+		status, code := errorStatus(err)
+		c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "station stopped"})
+}
+
+// Stream serves the live audio. Clients that send `Icy-MetaData: 1` (VLC,
+// most hardware and web radio players) get now-playing titles interleaved
+// every icyMetaInt bytes; everyone else gets plain audio.
+func (h *Handlers) Stream(c *gin.Context) {
+	s, err := h.manager.Get(c.Param("id"))
+	if err == nil {
+		err = h.manager.CanListen(c.Request.Context(), s, c.GetString("user_id"), c.GetString("user_role"))
+	}
+	if err != nil {
+		status, code := errorStatus(err)
+		c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+		return
+	}
+
+	l, initial, ok := s.hub.subscribe()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "station_not_found", "message": ErrStationNotFound.Error()}})
+		return
+	}
+	defer s.hub.unsubscribe(l)
+
+	c.Header("Content-Type", s.ContentType())
+	c.Header("Cache-Control", "no-cache, no-store")
+	c.Header("icy-name", s.Name)
+	c.Header("icy-br", strconv.Itoa(s.Bitrate))
+	var w io.Writer = c.Writer
+	if c.GetHeader("Icy-MetaData") == "1" {
+		c.Header("icy-metaint", strconv.Itoa(icyMetaInt))
+		w = newICYWriter(c.Writer, s.Title)
+	}
+	c.Status(http.StatusOK)
+
+	if _, err := w.Write(initial); err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-l.ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package radio
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+const (
+	// listenerQueue is how many chunks a listener may fall behind before the
+	// hub drops it. At ~8 KB per chunk that is a few seconds of 192 kbps audio.
+	listenerQueue = 64
+	// burstSize is how much recent audio a new listener receives up front so
+	// playback starts immediately instead of waiting for the buffer to fill.
+	burstSize = 64 * 1024
+)
+
+// listener is one connected client. The hub closes ch when the listener is
+// dropped or the station stops.
+type listener struct {
+	ch chan []byte
+}
+
+// hub fans a single encoder's output out to every listener, so a station
+// costs one ffmpeg encoder no matter how many people are tuned in.
+type hub struct {
+	mu        sync.Mutex
+	listeners map[*listener]struct{}
+	header    []byte   // Ogg stream header pages; empty for MP3
+	burst     [][]byte // recent chunks, newest last, at most burstSize bytes
+	burstLen  int
+	closed    bool
+}
+
+func newHub() *hub {
+	return &hub{listeners: make(map[*listener]struct{})}
+}
+
+// subscribe registers a listener and returns the bytes it must be sent before
+// the live chunks: Ogg headers (if any) followed by the burst buffer. ok is
+// false once the station has stopped.
+func (h *hub) subscribe() (l *listener, initial []byte, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, false
+	}
+	initial = make([]byte, 0, len(h.header)+h.burstLen)
+	initial = append(initial, h.header...)
+	for _, c := range h.burst {
+		initial = append(initial, c...)
+	}
+	l = &listener{ch: make(chan []byte, listenerQueue)}
+	h.listeners[l] = struct{}{}
+	return l, initial, true
+}
+
+func (h *hub) unsubscribe(l *listener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.listeners[l]; ok {
+		delete(h.listeners, l)
+		close(l.ch)
+	}
+}
+
+// appendHeader records stream header bytes that every listener needs before any
+// audio (the Vorbis identification/comment/setup pages for Ogg).
+func (h *hub) appendHeader(b []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header = append(h.header, b...)
+}
+
+// broadcast hands chunk to every listener without blocking. A listener whose
+// queue is full is disconnected rather than allowed to stall the station.
+func (h *hub) broadcast(chunk []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.burst = append(h.burst, chunk)
+	h.burstLen += len(chunk)
+	for len(h.burst) > 1 && h.burstLen-len(h.burst[0]) >= burstSize {
+		h.burstLen -= len(h.burst[0])
+		h.burst = h.burst[1:]
+	}
+	for l := range h.listeners {
+		select {
+		case l.ch <- chunk:
+		default:
+			delete(h.listeners, l)
+			close(l.ch)
+		}
+	}
+}
+
+func (h *hub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.listeners)
+}
+
+// close disconnects every listener and rejects new ones.
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for l := range h.listeners {
+		delete(h.listeners, l)
+		close(l.ch)
+	}
+}
+
+// oggPager reassembles an Ogg byte stream into whole pages. Ogg listeners
+// must start on a page boundary, so the hub only ever broadcasts full pages.
+type oggPager struct {
+	buf []byte
+}
+
+// oggHeaderLen is the fixed part of an Ogg page header, before the segment table.
+const oggHeaderLen = 27
+
+// push appends b and returns every complete page now available.
+func (p *oggPager) push(b []byte) [][]byte {
+	p.buf = append(p.buf, b...)
+	var pages [][]byte
+	for {
+		if len(p.buf) < oggHeaderLen {
+			break
+		}
+		nsegs := int(p.buf[26])
+		if len(p.buf) < oggHeaderLen+nsegs {
+			break
+		}
+		size := oggHeaderLen + nsegs
+		for _, s := range p.buf[oggHeaderLen : oggHeaderLen+nsegs] {
+			size += int(s)
+		}
+		if len(p.buf) < size {
+			break
+		}
+		page := make([]byte, size)
+		copy(page, p.buf[:size])
+		pages = append(pages, page)
+		p.buf = p.buf[size:]
+	}
+	return pages
+}
+
+// oggGranule returns the granule position of a complete page. Vorbis header
+// pages carry granule 0; the first audio page is the first with a non-zero one.
+func oggGranule(page []byte) uint64 {
+	return binary.LittleEndian.Uint64(page[6:14])
+}
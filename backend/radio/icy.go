@@ -0,0 +1,71 @@
+package radio
+
+import (
+	"io"
+	"strings"
+)
+
+// icyMetaInt is the number of audio bytes between ICY metadata blocks. 16000
+// is what Icecast and SHOUTcast default to, so every player handles it.
+const icyMetaInt = 16000
+
+// icyWriter interleaves SHOUTcast/Icecast "ICY" metadata into an audio stream
+// for clients that sent `Icy-MetaData: 1`. Every metaInt audio bytes it emits
+// one length-prefixed block; the block is empty unless the title changed.
+type icyWriter struct {
+	w         io.Writer
+	title     func() string
+	untilMeta int
+	lastTitle string
+}
+
+func newICYWriter(w io.Writer, title func() string) *icyWriter {
+	return &icyWriter{w: w, title: title, untilMeta: icyMetaInt}
+}
+
+func (iw *icyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iw.untilMeta)
+		w, err := iw.w.Write(p[:n])
+		written += w
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		iw.untilMeta -= n
+		if iw.untilMeta == 0 {
+			title := iw.title()
+			block := []byte{0}
+			if title != iw.lastTitle {
+				block = icyMetadataBlock(title)
+				iw.lastTitle = title
+			}
+			if _, err := iw.w.Write(block); err != nil {
+				return written, err
+			}
+			iw.untilMeta = icyMetaInt
+		}
+	}
+	return written, nil
+}
+
+// maxICYTitle keeps the block within the 255*16 bytes a one-byte length
+// prefix can describe, leaving room for the StreamTitle wrapper.
+const maxICYTitle = 255*16 - len("StreamTitle='';")
+
+// icyMetadataBlock encodes a StreamTitle block: one length byte (in 16-byte
+// units) followed by the zero-padded payload. The protocol has no escaping,
+// so single quotes in titles are swapped for a typographic apostrophe.
+func icyMetadataBlock(title string) []byte {
+	title = strings.ReplaceAll(title, "'", "’")
+	if len(title) > maxICYTitle {
+		title = title[:maxICYTitle]
+	}
+	payload := "StreamTitle='" + title + "';"
+	blocks := (len(payload) + 15) / 16
+	out := make([]byte, 1+blocks*16)
+	out[0] = byte(blocks)
+	copy(out[1:], payload)
+	return out
+}
@@ -0,0 +1,67 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestICYMetadataBlock(t *testing.T) {
+	b := icyMetadataBlock("DJ Koze - Pick Up")
+	payload := "StreamTitle='DJ Koze - Pick Up';"
+	wantBlocks := (len(payload) + 15) / 16
+	if int(b[0]) != wantBlocks || len(b) != 1+wantBlocks*16 {
+		t.Fatalf("length byte = %d, total = %d; want %d blocks", b[0], len(b), wantBlocks)
+	}
+	if !bytes.HasPrefix(b[1:], []byte(payload)) {
+		t.Errorf("payload = %q", b[1:])
+	}
+	if quoted := icyMetadataBlock("Don't Stop"); bytes.Contains(quoted[1:], []byte("Don't")) {
+		t.Error("single quote not replaced; it would terminate StreamTitle early")
+	}
+}
+
+func TestICYWriter_InterleavesAtMetaInt(t *testing.T) {
+	var out bytes.Buffer
+	title := "A - B"
+	w := newICYWriter(&out, func() string { return title })
+
+	audio := bytes.Repeat([]byte{0xAA}, icyMetaInt*2)
+	if _, err := w.Write(audio); err != nil {
+		t.Fatal(err)
+	}
+	got := out.Bytes()
+	first := icyMetadataBlock(title)
+	if !bytes.Equal(got[icyMetaInt:icyMetaInt+len(first)], first) {
+		t.Fatal("first metadata block missing or misplaced")
+	}
+	// Title unchanged: the second block is a single zero length byte.
+	second := icyMetaInt + len(first) + icyMetaInt
+	if len(got) != second+1 || got[second] != 0 {
+		t.Errorf("len = %d, want %d with trailing empty block", len(got), second+1)
+	}
+}
+
+func oggPage(granule uint64, body []byte) []byte {
+	p := make([]byte, oggHeaderLen+1)
+	copy(p, "OggS")
+	binary.LittleEndian.PutUint64(p[6:14], granule)
+	p[26] = 1
+	p[27] = byte(len(body))
+	return append(p, body...)
+}
+
+func TestOggPager_SplitsPagesAcrossReads(t *testing.T) {
+	stream := append(oggPage(0, []byte("hdr")), oggPage(4096, []byte("audio"))...)
+	var p oggPager
+	var pages [][]byte
+	for _, b := range stream {
+		pages = append(pages, p.push([]byte{b})...)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	if oggGranule(pages[0]) != 0 || oggGranule(pages[1]) != 4096 {
+		t.Errorf("granules = %d, %d", oggGranule(pages[0]), oggGranule(pages[1]))
+	}
+}
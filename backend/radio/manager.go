@@ -0,0 +1,218 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrStationNotFound = errors.New("station not found")
+	ErrCrateNotFound   = errors.New("crate not found")
+	ErrEmptyCrate      = errors.New("crate has no tracks")
+	ErrFFmpegMissing   = errors.New("ffmpeg not found on PATH")
+	ErrAccessDenied    = errors.New("access denied")
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrStopTimeout     = errors.New("station did not stop in time")
+)
+
+// Station defaults and limits. 6s is a typical club crossfade; past 15s the
+// overlap starts eating whole phrases of short edits.
+const (
+	defaultCrossfade = 6 * time.Second
+	maxCrossfade     = 15 * time.Second
+	defaultBitrate   = 192
+	minBitrate       = 64
+	maxBitrate       = 320
+)
+
+// Manager owns the set of live stations. Stations live only in memory: a
+// server restart takes them off air and an admin starts them again.
+type Manager struct {
+	repo    *Repository
+	storage storage.Storage
+
+	mu       sync.Mutex
+	stations map[string]*Station
+}
+
+func NewManager(repo *Repository, storage storage.Storage) *Manager {
+	return &Manager{repo: repo, storage: storage, stations: make(map[string]*Station)}
+}
+
+type StartRequest struct {
+	PlaylistID       string   `json:"playlist_id" binding:"required"`
+	Name             string   `json:"name"`
+	Format           string   `json:"format"`            // "mp3" (default) or "ogg"
+	Order            string   `json:"order"`             // "shuffle" (default) or "harmonic"
+	CrossfadeSeconds *float64 `json:"crossfade_seconds"` // default 6; 0 disables
+	BitrateKbps      int      `json:"bitrate_kbps"`      // default 192
+}
+
+// Start validates req and puts a new station on air. The station runs on its
+// own background context, independent of the request that started it.
+func (m *Manager) Start(ctx context.Context, req *StartRequest) (*Station, error) {
+	format := req.Format
+	if format == "" {
+		format = FormatMP3
+	}
+	if !isValidFormat(format) {
+		return nil, fmt.Errorf("%w: format must be mp3 or ogg", ErrInvalidRequest)
+	}
+	order := req.Order
+	if order == "" {
+		order = OrderShuffle
+	}
+	if !isValidOrder(order) {
+		return nil, fmt.Errorf("%w: order must be shuffle or harmonic", ErrInvalidRequest)
+	}
+	crossfade := defaultCrossfade
+	if req.CrossfadeSeconds != nil {
+		crossfade = time.Duration(*req.CrossfadeSeconds * float64(time.Second))
+	}
+	if crossfade < 0 || crossfade > maxCrossfade {
+		return nil, fmt.Errorf("%w: crossfade_seconds must be between 0 and %d", ErrInvalidRequest, int(maxCrossfade.Seconds()))
+	}
+	bitrate := req.BitrateKbps
+	if bitrate == 0 {
+		bitrate = defaultBitrate
+	}
+	if bitrate < minBitrate || bitrate > maxBitrate {
+		return nil, fmt.Errorf("%w: bitrate_kbps must be between %d and %d", ErrInvalidRequest, minBitrate, maxBitrate)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, ErrFFmpegMissing
+	}
+
+	crate, err := m.repo.GetCrate(ctx, req.PlaylistID)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := m.repo.GetCrateTracks(ctx, crate.ID)
+	if err != nil {
+		return nil, fmt.Errorf("load crate tracks: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrEmptyCrate
+	}
+
+	name := req.Name
+	if name == "" {
+		name = crate.Name
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	s := &Station{
+		ID:         uuid.New().String(),
+		Name:       name,
+		PlaylistID: crate.ID,
+		Format:     format,
+		Order:      order,
+		Crossfade:  crossfade,
+		Bitrate:    bitrate,
+		StartedAt:  time.Now(),
+		repo:       m.repo,
+		storage:    m.storage,
+		hub:        newHub(),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.stations[s.ID] = s
+	m.mu.Unlock()
+
+	go func() {
+		s.run(runCtx)
+		m.mu.Lock()
+		delete(m.stations, s.ID)
+		m.mu.Unlock()
+	}()
+
+	fmt.Printf("[Radio] Station %s (%q) on air: crate=%s format=%s order=%s crossfade=%s\n",
+		s.ID, s.Name, s.PlaylistID, s.Format, s.Order, s.Crossfade)
+	return s, nil
+}
+
+// Stop takes a station off air and waits briefly for its encoder to exit,
+// returning ErrStopTimeout if it is still running after that.
+func (m *Manager) Stop(id string) error {
+	m.mu.Lock()
+	s, ok := m.stations[id]
+	m.mu.Unlock()
+	if !ok {
+		return ErrStationNotFound
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(5 * time.Second):
+		fmt.Printf("[Radio] Station %s still stopping after 5s\n", id)
+		return ErrStopTimeout
+	}
+}
+
+func (m *Manager) Get(id string) (*Station, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.stations[id]
+	if !ok {
+		return nil, ErrStationNotFound
+	}
+	return s, nil
+}
+
+// ListenerCount is the number of listeners across all stations.
+func (m *Manager) ListenerCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, s := range m.stations {
+		n += s.hub.count()
+	}
+	return n
+}
+
+// List returns the stations the user may tune into, oldest first.
+func (m *Manager) List(ctx context.Context, userID, userRole string) []StationInfo {
+	m.mu.Lock()
+	stations := make([]*Station, 0, len(m.stations))
+	for _, s := range m.stations {
+		stations = append(stations, s)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(stations, func(i, j int) bool { return stations[i].StartedAt.Before(stations[j].StartedAt) })
+	out := make([]StationInfo, 0, len(stations))
+	for _, s := range stations {
+		if m.CanListen(ctx, s, userID, userRole) == nil {
+			out = append(out, s.Info())
+		}
+	}
+	return out
+}
+
+// CanListen applies the crate visibility rules to a station: admins and the
+// crate owner can always listen; everyone else only if the crate is public.
+func (m *Manager) CanListen(ctx context.Context, s *Station, userID, userRole string) error {
+	if userRole == "admin" {
+		return nil
+	}
+	crate, err := m.repo.GetCrate(ctx, s.PlaylistID)
+	if err != nil {
+		return err
+	}
+	if crate.IsPublic || crate.OwnerUserID == userID {
+		return nil
+	}
+	return ErrAccessDenied
+}
@@ -0,0 +1,139 @@
+package radio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"time"
+)
+
+// All decoding and mixing happens on interleaved signed 16-bit little-endian
+// stereo PCM at 44.1 kHz. ffmpeg resamples every source to this on decode, so
+// the mixer never has to care what format a track was uploaded in.
+const (
+	sampleRate     = 44100
+	channels       = 2
+	bytesPerFrame  = channels * 2
+	bytesPerSecond = sampleRate * bytesPerFrame
+)
+
+// decoder streams one track as PCM through an ffmpeg subprocess.
+type decoder struct {
+	cmd *exec.Cmd
+	out io.ReadCloser
+}
+
+func openDecoder(ctx context.Context, path string) (*decoder, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-i", path,
+		"-vn",
+		"-f", "s16le",
+		"-ac", fmt.Sprint(channels),
+		"-ar", fmt.Sprint(sampleRate),
+		"pipe:1",
+	)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("decoder stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start decoder: %w", err)
+	}
+	return &decoder{cmd: cmd, out: out}, nil
+}
+
+func (d *decoder) Read(p []byte) (int, error) { return d.out.Read(p) }
+
+// Close stops ffmpeg if it is still running (e.g. the station was stopped
+// mid-track) and reaps the process.
+func (d *decoder) Close() error {
+	if d.cmd.Process != nil {
+		_ = d.cmd.Process.Kill()
+	}
+	_ = d.out.Close()
+	return d.cmd.Wait()
+}
+
+// crossfade mixes the tail of the outgoing track with the head of the
+// incoming one using an equal-power curve, so perceived loudness stays level
+// through the transition. head may be shorter than tail (very short tracks);
+// the missing part of the incoming signal is treated as silence. The result
+// is always len(tail) bytes, rounded down to whole frames.
+func crossfade(tail, head []byte) []byte {
+	frames := len(tail) / bytesPerFrame
+	out := make([]byte, frames*bytesPerFrame)
+	if frames == 0 {
+		return out
+	}
+	for f := 0; f < frames; f++ {
+		x := float64(f) / float64(frames)
+		gainOut := math.Cos(x * math.Pi / 2)
+		gainIn := math.Sin(x * math.Pi / 2)
+		for ch := 0; ch < channels; ch++ {
+			i := f*bytesPerFrame + ch*2
+			a := float64(int16(binary.LittleEndian.Uint16(tail[i:])))
+			var b float64
+			if i+2 <= len(head) {
+				b = float64(int16(binary.LittleEndian.Uint16(head[i:])))
+			}
+			v := a*gainOut + b*gainIn
+			if v > math.MaxInt16 {
+				v = math.MaxInt16
+			} else if v < math.MinInt16 {
+				v = math.MinInt16
+			}
+			binary.LittleEndian.PutUint16(out[i:], uint16(int16(v)))
+		}
+	}
+	return out
+}
+
+// maxLead is how far ahead of wall-clock the mixer may run. A little lead
+// keeps the encoder fed through scheduling hiccups; too much and now-playing
+// titles drift ahead of what listeners actually hear.
+const maxLead = 2 * time.Second
+
+// pacedWriter throttles PCM writes to real time. Without it the encoder would
+// race through the crate as fast as the CPU allows and listeners would fall
+// hopelessly behind (or the hub would drop them as slow consumers).
+type pacedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	start   time.Time
+	written int64
+}
+
+func newPacedWriter(ctx context.Context, w io.Writer) *pacedWriter {
+	return &pacedWriter{ctx: ctx, w: w, start: time.Now()}
+}
+
+func (p *pacedWriter) Write(b []byte) (int, error) {
+	const slice = bytesPerSecond / 10 // 100ms of audio per write
+	total := 0
+	for len(b) > 0 {
+		n := len(b)
+		if n > slice {
+			n = slice
+		}
+		audioClock := time.Duration(p.written) * time.Second / bytesPerSecond
+		if wait := audioClock - time.Since(p.start) - maxLead; wait > 0 {
+			select {
+			case <-p.ctx.Done():
+				return total, p.ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		w, err := p.w.Write(b[:n])
+		total += w
+		p.written += int64(w)
+		if err != nil {
+			return total, err
+		}
+		b = b[n:]
+	}
+	return total, nil
+}
@@ -0,0 +1,58 @@
+package radio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func pcm(frames int, value int16) []byte {
+	b := make([]byte, frames*bytesPerFrame)
+	for i := 0; i < len(b); i += 2 {
+		binary.LittleEndian.PutUint16(b[i:], uint16(value))
+	}
+	return b
+}
+
+func sampleAt(b []byte, frame int) int16 {
+	return int16(binary.LittleEndian.Uint16(b[frame*bytesPerFrame:]))
+}
+
+func TestCrossfade_FadesFromTailToHead(t *testing.T) {
+	tail := pcm(1000, 10000)
+	head := pcm(1000, -10000)
+	out := crossfade(tail, head)
+
+	if len(out) != len(tail) {
+		t.Fatalf("len = %d, want %d", len(out), len(tail))
+	}
+	if got := sampleAt(out, 0); got != 10000 {
+		t.Errorf("first frame = %d, want outgoing track at full level", got)
+	}
+	if got := sampleAt(out, 999); got > -9900 {
+		t.Errorf("last frame = %d, want incoming track at ~full level", got)
+	}
+	if got := sampleAt(out, 500); got < -100 || got > 100 {
+		t.Errorf("midpoint = %d, want equal-power cancellation near 0", got)
+	}
+}
+
+func TestCrossfade_ShortHeadIsPaddedWithSilence(t *testing.T) {
+	tail := pcm(100, 8000)
+	head := pcm(10, 8000)
+	out := crossfade(tail, head)
+	if len(out) != len(tail) {
+		t.Fatalf("len = %d, want %d", len(out), len(tail))
+	}
+	if got := sampleAt(out, 99); got > 200 {
+		t.Errorf("last frame = %d, want fade to silence past end of short head", got)
+	}
+}
+
+func TestCrossfade_ClampsInsteadOfWrapping(t *testing.T) {
+	out := crossfade(pcm(100, 32000), pcm(100, 32000))
+	for f := 0; f < 100; f++ {
+		if sampleAt(out, f) < 0 {
+			t.Fatalf("frame %d wrapped negative", f)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package radio
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestOrderTracks_ShuffleKeepsEveryTrack(t *testing.T) {
+	in := []QueueTrack{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	got := orderTracks(in, OrderShuffle, rand.New(rand.NewSource(1)))
+	if len(got) != len(in) {
+		t.Fatalf("len = %d, want %d", len(got), len(in))
+	}
+	seen := map[string]bool{}
+	for _, tr := range got {
+		seen[tr.ID] = true
+	}
+	for _, tr := range in {
+		if !seen[tr.ID] {
+			t.Errorf("track %s missing from shuffled queue", tr.ID)
+		}
+	}
+	if in[0].ID != "a" || in[3].ID != "d" {
+		t.Error("input slice was mutated")
+	}
+}
+
+func TestOrderTracks_HarmonicFollowsTheWheel(t *testing.T) {
+	in := []QueueTrack{
+		{ID: "8A", MusicalKey: "8A", BPM: 124},
+		{ID: "2B", MusicalKey: "2B", BPM: 124},
+		{ID: "9A", MusicalKey: "9A", BPM: 124},
+		{ID: "10A", MusicalKey: "10A", BPM: 124},
+		{ID: "3B", MusicalKey: "3B", BPM: 124},
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		got := orderTracks(in, OrderHarmonic, rand.New(rand.NewSource(seed)))
+		clashes := 0
+		for i := 1; i < len(got); i++ {
+			if transitionCost(got[i-1], got[i]) > 1 {
+				clashes++
+			}
+		}
+		// Two harmonic clusters ({8A,9A,10A} and {2B,3B}) need exactly one jump.
+		if clashes > 1 {
+			t.Errorf("seed %d: %d clashing transitions in %v", seed, clashes, ids(got))
+		}
+	}
+}
+
+func TestTransitionCost_HalfTimeIsAMatch(t *testing.T) {
+	a := QueueTrack{MusicalKey: "8A", BPM: 87}
+	b := QueueTrack{MusicalKey: "8A", BPM: 174}
+	if got := transitionCost(a, b); got != 0 {
+		t.Errorf("transitionCost(87 -> 174) = %v, want 0", got)
+	}
+	unknown := QueueTrack{}
+	if got := transitionCost(a, unknown); got != 2*unknownStepCost {
+		t.Errorf("transitionCost(unknown) = %v, want %v", got, 2*unknownStepCost)
+	}
+}
+
+func ids(ts []QueueTrack) []string {
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = t.ID
+	}
+	return out
+}
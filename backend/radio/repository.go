@@ -0,0 +1,90 @@
+package radio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Crate is the subset of a playlist row a station needs for access checks.
+type Crate struct {
+	ID          string
+	OwnerUserID string
+	Name        string
+	IsPublic    bool
+}
+
+// QueueTrack is the subset of a track row a station needs to play it.
+// BPM is 0 and MusicalKey is "" when the track has not been analyzed.
+type QueueTrack struct {
+	ID               string
+	Title            string
+	Artist           string
+	OriginalFilename string
+	FilePath         string
+	BPM              float64
+	MusicalKey       string
+}
+
+// DisplayTitle is the "Artist - Title" string sent as ICY StreamTitle.
+func (t QueueTrack) DisplayTitle() string {
+	switch {
+	case t.Artist != "" && t.Title != "":
+		return t.Artist + " - " + t.Title
+	case t.Title != "":
+		return t.Title
+	default:
+		return t.OriginalFilename
+	}
+}
+
+func (r *Repository) GetCrate(ctx context.Context, playlistID string) (*Crate, error) {
+	var c Crate
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_user_id, name, is_public FROM playlists WHERE id = ?`,
+		playlistID,
+	).Scan(&c.ID, &c.OwnerUserID, &c.Name, &c.IsPublic)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCrateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCrateTracks returns every track in a crate in crate order.
+func (r *Repository) GetCrateTracks(ctx context.Context, playlistID string) ([]QueueTrack, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, COALESCE(t.title, ''), COALESCE(t.artist, ''), t.original_filename,
+		       t.file_path, COALESCE(t.bpm, 0), COALESCE(t.musical_key, '')
+		FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL
+		ORDER BY pt.position ASC, pt.added_at ASC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []QueueTrack
+	for rows.Next() {
+		var t QueueTrack
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.OriginalFilename, &t.FilePath, &t.BPM, &t.MusicalKey); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
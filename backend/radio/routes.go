@@ -0,0 +1,24 @@
+package radio
+
+import (
+	"github.com/faraz525/home-music-server/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/radio")
+		{
+			r.GET("/stations", handlers.ListStations)
+			r.GET("/stations/:id", handlers.GetStation)
+			r.GET("/stations/:id/stream", handlers.Stream)
+
+			admin := r.Group("")
+			admin.Use(auth.AdminMiddleware())
+			admin.POST("/stations", handlers.StartStation)
+			admin.DELETE("/stations/:id", handlers.StopStation)
+		}
+	}
+}
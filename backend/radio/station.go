@@ -0,0 +1,337 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/storage"
+)
+
+// Output formats a station can encode to.
+const (
+	FormatMP3 = "mp3"
+	FormatOgg = "ogg"
+)
+
+func isValidFormat(f string) bool {
+	return f == FormatMP3 || f == FormatOgg
+}
+
+// Station is one live broadcast of a crate: a mixer goroutine feeding a single
+// ffmpeg encoder whose output is fanned out to every listener by the hub.
+type Station struct {
+	ID         string
+	Name       string
+	PlaylistID string
+	Format     string
+	Order      string
+	Crossfade  time.Duration
+	Bitrate    int // kbps
+	StartedAt  time.Time
+
+	repo    *Repository
+	storage storage.Storage
+	hub     *hub
+	rng     *rand.Rand
+
+	mu         sync.RWMutex
+	nowPlaying *QueueTrack
+	queue      []QueueTrack
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NowPlaying identifies the track currently on air.
+type NowPlaying struct {
+	TrackID string `json:"track_id"`
+	Title   string `json:"title"`
+}
+
+// StationInfo is the JSON view of a running station.
+type StationInfo struct {
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	PlaylistID       string      `json:"playlist_id"`
+	Format           string      `json:"format"`
+	Order            string      `json:"order"`
+	CrossfadeSeconds float64     `json:"crossfade_seconds"`
+	BitrateKbps      int         `json:"bitrate_kbps"`
+	NowPlaying       *NowPlaying `json:"now_playing,omitempty"`
+	Listeners        int         `json:"listeners"`
+	StartedAt        time.Time   `json:"started_at"`
+	StreamURL        string      `json:"stream_url"`
+}
+
+func (s *Station) Info() StationInfo {
+	info := StationInfo{
+		ID:               s.ID,
+		Name:             s.Name,
+		PlaylistID:       s.PlaylistID,
+		Format:           s.Format,
+		Order:            s.Order,
+		CrossfadeSeconds: s.Crossfade.Seconds(),
+		BitrateKbps:      s.Bitrate,
+		Listeners:        s.hub.count(),
+		StartedAt:        s.StartedAt,
+		StreamURL:        "/api/radio/stations/" + s.ID + "/stream",
+	}
+	s.mu.RLock()
+	if s.nowPlaying != nil {
+		info.NowPlaying = &NowPlaying{TrackID: s.nowPlaying.ID, Title: s.nowPlaying.DisplayTitle()}
+	}
+	s.mu.RUnlock()
+	return info
+}
+
+// ContentType is the MIME type listeners receive.
+func (s *Station) ContentType() string {
+	if s.Format == FormatOgg {
+		return "application/ogg"
+	}
+	return "audio/mpeg"
+}
+
+// Title returns the current ICY StreamTitle.
+func (s *Station) Title() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.nowPlaying == nil {
+		return s.Name
+	}
+	return s.nowPlaying.DisplayTitle()
+}
+
+func (s *Station) setNowPlaying(t QueueTrack) {
+	s.mu.Lock()
+	s.nowPlaying = &t
+	s.mu.Unlock()
+}
+
+// next pops the next track off the queue, rebuilding it from the crate when it
+// runs dry. Reloading each pass picks up tracks added to the crate while the
+// station is on air. The crate is loaded without holding s.mu, so Info,
+// Title and the metadata writers don't wait on the database.
+func (s *Station) next(ctx context.Context) (QueueTrack, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			t := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return t, nil
+		}
+		s.mu.Unlock()
+
+		tracks, err := s.repo.GetCrateTracks(ctx, s.PlaylistID)
+		if err != nil {
+			return QueueTrack{}, fmt.Errorf("load crate tracks: %w", err)
+		}
+		if len(tracks) == 0 {
+			return QueueTrack{}, ErrEmptyCrate
+		}
+
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.queue = orderTracks(tracks, s.Order, s.rng)
+			// Don't play the same track twice in a row across a reshuffle.
+			if s.nowPlaying != nil && len(s.queue) > 1 && s.queue[0].ID == s.nowPlaying.ID {
+				last := len(s.queue) - 1
+				s.queue[0], s.queue[last] = s.queue[last], s.queue[0]
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run drives the station until ctx is cancelled or the encoder dies. It
+// always closes the hub (disconnecting listeners) and s.done on return.
+func (s *Station) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.hub.close()
+
+	enc := exec.CommandContext(ctx, "ffmpeg", encoderArgs(s.Format, s.Bitrate)...)
+	stdin, err := enc.StdinPipe()
+	if err != nil {
+		fmt.Printf("[Radio] Station %s: encoder stdin pipe: %v\n", s.ID, err)
+		return
+	}
+	stdout, err := enc.StdoutPipe()
+	if err != nil {
+		fmt.Printf("[Radio] Station %s: encoder stdout pipe: %v\n", s.ID, err)
+		return
+	}
+	if err := enc.Start(); err != nil {
+		fmt.Printf("[Radio] Station %s: start encoder: %v\n", s.ID, err)
+		return
+	}
+
+	mixErr := make(chan error, 1)
+	go func() {
+		err := s.mix(ctx, newPacedWriter(ctx, stdin))
+		// Closing stdin lets the encoder flush and exit, which ends pump.
+		stdin.Close()
+		mixErr <- err
+	}()
+
+	s.pump(stdout)
+	// The encoder is gone (or stopping); make sure the mixer follows.
+	s.cancel()
+	_ = enc.Wait()
+	if err := <-mixErr; err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("[Radio] Station %s stopped: %v\n", s.ID, err)
+		return
+	}
+	fmt.Printf("[Radio] Station %s stopped\n", s.ID)
+}
+
+// maxConsecutiveFailures is how many tracks in a row may fail to decode before
+// the mixer pauses, so a crate full of missing files can't spin the CPU.
+const maxConsecutiveFailures = 3
+
+// mix decodes tracks one after another and writes continuous PCM to out. The
+// last Crossfade worth of each track is held back and blended with the head
+// of the next one.
+func (s *Station) mix(ctx context.Context, out io.Writer) error {
+	xfadeBytes := int(s.Crossfade.Seconds()*sampleRate) * bytesPerFrame
+	flushAt := xfadeBytes + bytesPerSecond
+	readBuf := make([]byte, 32*1024)
+	pending := make([]byte, 0, flushAt+len(readBuf))
+	var tail []byte
+	failures := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if failures >= maxConsecutiveFailures {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+			failures = 0
+		}
+
+		track, err := s.next(ctx)
+		if err != nil {
+			return err
+		}
+		fullPath, ok := s.storage.ResolveFullPath(track.FilePath)
+		if !ok {
+			failures++
+			continue
+		}
+		dec, err := openDecoder(ctx, fullPath)
+		if err != nil {
+			fmt.Printf("[Radio] Station %s: skip %s: %v\n", s.ID, track.ID, err)
+			failures++
+			continue
+		}
+		s.setNowPlaying(track)
+
+		decoded := 0
+		if len(tail) > 0 {
+			head := make([]byte, len(tail))
+			n, _ := io.ReadFull(dec, head)
+			decoded += n
+			if _, err := out.Write(crossfade(tail, head[:n])); err != nil {
+				dec.Close()
+				return err
+			}
+			tail = nil
+		}
+
+		pending = pending[:0]
+		for {
+			n, rerr := dec.Read(readBuf)
+			decoded += n
+			pending = append(pending, readBuf[:n]...)
+			if len(pending) >= flushAt {
+				cut := (len(pending) - xfadeBytes) / bytesPerFrame * bytesPerFrame
+				if _, err := out.Write(pending[:cut]); err != nil {
+					dec.Close()
+					return err
+				}
+				pending = append(pending[:0], pending[cut:]...)
+			}
+			if rerr != nil {
+				break
+			}
+		}
+		_ = dec.Close()
+
+		if decoded == 0 {
+			fmt.Printf("[Radio] Station %s: %s decoded to nothing, skipping\n", s.ID, track.ID)
+			failures++
+			continue
+		}
+		failures = 0
+
+		cut := max((len(pending)-xfadeBytes)/bytesPerFrame*bytesPerFrame, 0)
+		if cut > 0 {
+			if _, err := out.Write(pending[:cut]); err != nil {
+				return err
+			}
+		}
+		tail = append([]byte(nil), pending[cut:]...)
+	}
+}
+
+// pump reads encoded audio and hands it to the hub. For Ogg it splits the
+// stream into pages and captures the leading header pages separately, since
+// a listener joining mid-stream can't decode anything without them.
+func (s *Station) pump(r io.Reader) {
+	buf := make([]byte, 8*1024)
+	var pager *oggPager
+	if s.Format == FormatOgg {
+		pager = &oggPager{}
+	}
+	inHeader := true
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := append([]byte(nil), buf[:n]...)
+			if pager == nil {
+				s.hub.broadcast(chunk)
+			} else {
+				for _, page := range pager.push(chunk) {
+					if inHeader && oggGranule(page) == 0 {
+						s.hub.appendHeader(page)
+						continue
+					}
+					inHeader = false
+					s.hub.broadcast(page)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func encoderArgs(format string, bitrateKbps int) []string {
+	args := []string{
+		"-v", "error",
+		"-f", "s16le",
+		"-ar", fmt.Sprint(sampleRate),
+		"-ac", fmt.Sprint(channels),
+		"-i", "pipe:0",
+		"-b:a", fmt.Sprintf("%dk", bitrateKbps),
+		"-flush_packets", "1",
+	}
+	switch format {
+	case FormatOgg:
+		args = append(args, "-c:a", "libvorbis", "-f", "ogg")
+	default:
+		args = append(args, "-c:a", "libmp3lame", "-f", "mp3")
+	}
+	return append(args, "pipe:1")
+}
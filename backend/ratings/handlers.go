@@ -0,0 +1,82 @@
+package ratings
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrTrackNotFound):
+		return http.StatusNotFound, "track_not_found"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+func (h *Handlers) GetRating(c *gin.Context) {
+	rating, err := h.manager.Get(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rating": rating})
+}
+
+func (h *Handlers) UpdateRating(c *gin.Context) {
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	rating, err := h.manager.Update(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rating": rating})
+}
+
+// LookupRatings returns the user's ratings on the comma-separated
+// track_ids, for a page of tracks.
+func (h *Handlers) LookupRatings(c *gin.Context) {
+	ratings, err := h.manager.Lookup(c.Request.Context(), c.GetString("user_id"), strings.Split(c.Query("track_ids"), ","))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ratings": ratings})
+}
+
+func (h *Handlers) BulkUpdate(c *gin.Context) {
+	var req BulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	updated, err := h.manager.Bulk(c.Request.Context(), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
@@ -0,0 +1,198 @@
+package ratings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+)
+
+var (
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// maxBulkTracks matches the size of a page of tracks in the client.
+const maxBulkTracks = 500
+
+// Manager stores each user's ratings, favourites and colour labels. Users
+// can mark their own tracks and any track in a public crate; admins can
+// mark any track.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now}
+}
+
+// UpdateRequest changes a rating; omitted fields are left as they are. A
+// rating of 0 or an empty color clears it.
+type UpdateRequest struct {
+	Rating    *int    `json:"rating"`
+	Favourite *bool   `json:"favourite"`
+	Color     *string `json:"color"`
+}
+
+// BulkRequest applies the same change to several tracks.
+type BulkRequest struct {
+	TrackIDs []string `json:"track_ids" binding:"required"`
+	UpdateRequest
+}
+
+// check validates and tidies req.
+func (req *UpdateRequest) check() error {
+	if req.Rating == nil && req.Favourite == nil && req.Color == nil {
+		return fmt.Errorf("%w: nothing to change; set rating, favourite or color", ErrInvalidRequest)
+	}
+	if req.Rating != nil && (*req.Rating < 0 || *req.Rating > imodels.MaxRating) {
+		return fmt.Errorf("%w: rating must be from 0 to %d", ErrInvalidRequest, imodels.MaxRating)
+	}
+	if req.Color != nil {
+		color := strings.ToLower(strings.TrimSpace(*req.Color))
+		if color != "" && !slices.Contains(imodels.RatingColors, color) {
+			return fmt.Errorf("%w: color must be one of %s, or empty to clear it", ErrInvalidRequest, strings.Join(imodels.RatingColors, ", "))
+		}
+		req.Color = &color
+	}
+	return nil
+}
+
+// apply makes req's changes to rt.
+func (req *UpdateRequest) apply(rt *Rating, now time.Time) {
+	if req.Rating != nil {
+		rt.Rating = *req.Rating
+	}
+	if req.Favourite != nil && *req.Favourite != rt.Favourite {
+		rt.Favourite = *req.Favourite
+		rt.FavouritedAt = nil
+		if rt.Favourite {
+			rt.FavouritedAt = &now
+		}
+	}
+	if req.Color != nil {
+		rt.Color = *req.Color
+	}
+	rt.UpdatedAt = &now
+}
+
+// checkVisible reports ErrTrackNotFound unless the user can see every one
+// of trackIDs.
+func (m *Manager) checkVisible(ctx context.Context, userID, userRole string, trackIDs []string) error {
+	visible, err := m.repo.VisibleTracks(ctx, userID, trackIDs, userRole == "admin")
+	if err != nil {
+		return err
+	}
+	for _, id := range trackIDs {
+		if !visible[id] {
+			return fmt.Errorf("%w: %s", ErrTrackNotFound, id)
+		}
+	}
+	return nil
+}
+
+// Get returns the user's rating on a track they can see.
+func (m *Manager) Get(ctx context.Context, trackID, userID, userRole string) (*Rating, error) {
+	if err := m.checkVisible(ctx, userID, userRole, []string{trackID}); err != nil {
+		return nil, err
+	}
+	ratings, err := m.repo.Ratings(ctx, userID, []string{trackID})
+	if err != nil {
+		return nil, err
+	}
+	if rt, ok := ratings[trackID]; ok {
+		return rt, nil
+	}
+	return &Rating{TrackID: trackID, UserID: userID}, nil
+}
+
+// Lookup returns the user's ratings on trackIDs, in that order, for
+// showing alongside a page of tracks. Tracks they haven't marked are left
+// out.
+func (m *Manager) Lookup(ctx context.Context, userID string, trackIDs []string) ([]*Rating, error) {
+	ids := dedupe(trackIDs)
+	if len(ids) > maxBulkTracks {
+		return nil, fmt.Errorf("%w: at most %d tracks at once", ErrInvalidRequest, maxBulkTracks)
+	}
+	ratings, err := m.repo.Ratings(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := []*Rating{}
+	for _, id := range ids {
+		if rt, ok := ratings[id]; ok {
+			out = append(out, rt)
+		}
+	}
+	return out, nil
+}
+
+// Update applies req to the user's rating on a track they can see.
+func (m *Manager) Update(ctx context.Context, trackID, userID, userRole string, req *UpdateRequest) (*Rating, error) {
+	if err := req.check(); err != nil {
+		return nil, err
+	}
+	rt, err := m.Get(ctx, trackID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	req.apply(rt, m.now())
+	if err := m.repo.Save(ctx, []*Rating{rt}); err != nil {
+		return nil, fmt.Errorf("save rating: %w", err)
+	}
+	return rt, nil
+}
+
+// Bulk applies the same change to the user's ratings on several tracks,
+// all or nothing, and returns how many tracks it covered.
+func (m *Manager) Bulk(ctx context.Context, userID, userRole string, req *BulkRequest) (int, error) {
+	if err := req.check(); err != nil {
+		return 0, err
+	}
+	ids := dedupe(req.TrackIDs)
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("%w: no track IDs provided", ErrInvalidRequest)
+	}
+	if len(ids) > maxBulkTracks {
+		return 0, fmt.Errorf("%w: at most %d tracks at once", ErrInvalidRequest, maxBulkTracks)
+	}
+	if err := m.checkVisible(ctx, userID, userRole, ids); err != nil {
+		return 0, err
+	}
+	existing, err := m.repo.Ratings(ctx, userID, ids)
+	if err != nil {
+		return 0, err
+	}
+	now := m.now()
+	ratings := make([]*Rating, len(ids))
+	for i, id := range ids {
+		rt, ok := existing[id]
+		if !ok {
+			rt = &Rating{TrackID: id, UserID: userID}
+		}
+		req.apply(rt, now)
+		ratings[i] = rt
+	}
+	if err := m.repo.Save(ctx, ratings); err != nil {
+		return 0, fmt.Errorf("save ratings: %w", err)
+	}
+	return len(ratings), nil
+}
+
+// dedupe trims IDs and drops blanks and repeats.
+func dedupe(ids []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
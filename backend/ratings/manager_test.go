@@ -0,0 +1,156 @@
+package ratings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (id TEXT PRIMARY KEY, owner_user_id TEXT, deleted_at DATETIME);
+		CREATE TABLE playlists (id TEXT PRIMARY KEY, owner_user_id TEXT, is_public BOOLEAN);
+		CREATE TABLE playlist_tracks (playlist_id TEXT, track_id TEXT);
+		CREATE TABLE track_ratings (
+			track_id TEXT NOT NULL, user_id TEXT NOT NULL, rating INTEGER NOT NULL DEFAULT 0,
+			favourite BOOLEAN NOT NULL DEFAULT FALSE, favourited_at DATETIME, color TEXT,
+			updated_at DATETIME NOT NULL, PRIMARY KEY (user_id, track_id)
+		);
+		INSERT INTO tracks (id, owner_user_id, deleted_at) VALUES
+			('t1', 'dj', NULL), ('t2', 'other', NULL), ('t3', 'other', NULL), ('trashed', 'dj', CURRENT_TIMESTAMP);
+		INSERT INTO playlists VALUES ('p1', 'other', TRUE), ('p2', 'other', FALSE);
+		INSERT INTO playlist_tracks VALUES ('p1', 't2'), ('p2', 't3');
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}))
+	m.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	return m
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestUpdate(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	rt, err := m.Get(ctx, "t1", "dj", "user")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if rt.Rating != 0 || rt.Favourite || rt.Color != "" {
+		t.Errorf("unrated track = %+v", rt)
+	}
+
+	rt, err = m.Update(ctx, "t1", "dj", "user", &UpdateRequest{Rating: ptr(4), Favourite: ptr(true), Color: ptr(" Red ")})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if rt.Rating != 4 || !rt.Favourite || rt.FavouritedAt == nil || rt.Color != "red" {
+		t.Errorf("rating = %+v", rt)
+	}
+
+	// A partial update keeps the other fields.
+	rt, err = m.Update(ctx, "t1", "dj", "user", &UpdateRequest{Rating: ptr(5)})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if rt.Rating != 5 || !rt.Favourite || rt.Color != "red" {
+		t.Errorf("after partial update = %+v", rt)
+	}
+
+	// Clearing everything deletes the row.
+	if _, err := m.Update(ctx, "t1", "dj", "user", &UpdateRequest{Rating: ptr(0), Favourite: ptr(false), Color: ptr("")}); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if got, _ := m.Lookup(ctx, "dj", []string{"t1"}); len(got) != 0 {
+		t.Errorf("cleared rating still stored: %+v", got[0])
+	}
+}
+
+func TestUpdate_Invalid(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	for _, req := range []UpdateRequest{{}, {Rating: ptr(6)}, {Rating: ptr(-1)}, {Color: ptr("beige")}} {
+		if _, err := m.Update(ctx, "t1", "dj", "user", &req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("update %+v: error = %v, want ErrInvalidRequest", req, err)
+		}
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	// Another user's track in a public crate can be rated; one only in a
+	// private crate can't, except by an admin.
+	if _, err := m.Update(ctx, "t2", "dj", "user", &UpdateRequest{Rating: ptr(3)}); err != nil {
+		t.Errorf("public track: %v", err)
+	}
+	if _, err := m.Update(ctx, "t3", "dj", "user", &UpdateRequest{Rating: ptr(3)}); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("private track: error = %v, want ErrTrackNotFound", err)
+	}
+	if _, err := m.Update(ctx, "t3", "admin", "admin", &UpdateRequest{Rating: ptr(3)}); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+	// Tracks in the trash can't be rated, even by their owner or an admin.
+	for _, role := range []string{"user", "admin"} {
+		if _, err := m.Update(ctx, "trashed", "dj", role, &UpdateRequest{Rating: ptr(3)}); !errors.Is(err, ErrTrackNotFound) {
+			t.Errorf("track in the trash (%s): error = %v, want ErrTrackNotFound", role, err)
+		}
+	}
+
+	// Ratings are per user.
+	own, err := m.Get(ctx, "t2", "other", "user")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if own.Rating != 0 {
+		t.Errorf("owner sees another user's rating: %+v", own)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.Update(ctx, "t1", "dj", "user", &UpdateRequest{Rating: ptr(2)}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	n, err := m.Bulk(ctx, "dj", "user", &BulkRequest{TrackIDs: []string{"t1", "t2", "t1"}, UpdateRequest: UpdateRequest{Favourite: ptr(true)}})
+	if err != nil {
+		t.Fatalf("bulk: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("updated = %d, want 2", n)
+	}
+	got, err := m.Lookup(ctx, "dj", []string{"t2", "t1"})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(got) != 2 || got[0].TrackID != "t2" || !got[0].Favourite || got[1].Rating != 2 || !got[1].Favourite {
+		t.Errorf("ratings = %+v %+v", got[0], got[1])
+	}
+
+	// All or nothing: one hidden track fails the lot.
+	_, err = m.Bulk(ctx, "dj", "user", &BulkRequest{TrackIDs: []string{"t1", "t3"}, UpdateRequest: UpdateRequest{Rating: ptr(5)}})
+	if !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("bulk with a hidden track: error = %v, want ErrTrackNotFound", err)
+	}
+	if rt, _ := m.Get(ctx, "t1", "dj", "user"); rt.Rating != 2 {
+		t.Errorf("failed bulk changed t1: %+v", rt)
+	}
+}
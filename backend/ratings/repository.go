@@ -0,0 +1,143 @@
+package ratings
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Rating is a user's star rating, favourite flag and colour label on a
+// track. A track the user hasn't marked has the zero Rating.
+type Rating struct {
+	TrackID      string     `json:"track_id"`
+	Rating       int        `json:"rating"`
+	Favourite    bool       `json:"favourite"`
+	FavouritedAt *time.Time `json:"favourited_at,omitempty"`
+	Color        string     `json:"color,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+
+	UserID string `json:"-"`
+}
+
+// empty reports whether the rating holds nothing worth storing.
+func (r *Rating) empty() bool {
+	return r.Rating == 0 && !r.Favourite && r.Color == ""
+}
+
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+// VisibleTracks returns which of trackIDs userID can see: their own tracks
+// and any in a public crate. With all set, every existing track counts.
+// Tracks in the trash are never visible.
+func (r *Repository) VisibleTracks(ctx context.Context, userID string, trackIDs []string, all bool) (map[string]bool, error) {
+	visible := make(map[string]bool, len(trackIDs))
+	if len(trackIDs) == 0 {
+		return visible, nil
+	}
+	args := make([]any, 0, len(trackIDs)+1)
+	for _, id := range trackIDs {
+		args = append(args, id)
+	}
+	query := `SELECT t.id FROM tracks t WHERE t.id IN (` + placeholders(len(trackIDs)) + `) AND t.deleted_at IS NULL`
+	if !all {
+		query += ` AND (t.owner_user_id = ? OR EXISTS (
+			SELECT 1 FROM playlist_tracks pt JOIN playlists p ON p.id = pt.playlist_id
+			WHERE pt.track_id = t.id AND p.is_public = TRUE
+		))`
+		args = append(args, userID)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		visible[id] = true
+	}
+	return visible, rows.Err()
+}
+
+// Ratings returns userID's stored ratings on trackIDs, by track ID. Tracks
+// the user hasn't marked are missing.
+func (r *Repository) Ratings(ctx context.Context, userID string, trackIDs []string) (map[string]*Rating, error) {
+	ratings := make(map[string]*Rating, len(trackIDs))
+	if len(trackIDs) == 0 {
+		return ratings, nil
+	}
+	args := []any{userID}
+	for _, id := range trackIDs {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT track_id, rating, favourite, favourited_at, color, updated_at
+		FROM track_ratings
+		WHERE user_id = ? AND track_id IN (`+placeholders(len(trackIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rt := Rating{UserID: userID}
+		var favouritedAt sql.NullTime
+		var color sql.NullString
+		var updatedAt time.Time
+		if err := rows.Scan(&rt.TrackID, &rt.Rating, &rt.Favourite, &favouritedAt, &color, &updatedAt); err != nil {
+			return nil, err
+		}
+		if favouritedAt.Valid {
+			rt.FavouritedAt = &favouritedAt.Time
+		}
+		rt.Color = color.String
+		rt.UpdatedAt = &updatedAt
+		ratings[rt.TrackID] = &rt
+	}
+	return ratings, rows.Err()
+}
+
+// Save writes ratings in one transaction. A rating left empty is deleted
+// rather than stored.
+func (r *Repository) Save(ctx context.Context, ratings []*Rating) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rt := range ratings {
+		if rt.empty() {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM track_ratings WHERE user_id = ? AND track_id = ?`, rt.UserID, rt.TrackID); err != nil {
+				return err
+			}
+			continue
+		}
+		var color any
+		if rt.Color != "" {
+			color = rt.Color
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO track_ratings (track_id, user_id, rating, favourite, favourited_at, color, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, rt.TrackID, rt.UserID, rt.Rating, rt.Favourite, rt.FavouritedAt, color, rt.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
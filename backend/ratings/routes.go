@@ -0,0 +1,17 @@
+package ratings
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/ratings")
+		{
+			r.GET("", handlers.LookupRatings)
+			r.POST("/bulk", handlers.BulkUpdate)
+		}
+		rg.GET("/tracks/:id/rating", handlers.GetRating)
+		rg.PATCH("/tracks/:id/rating", handlers.UpdateRating)
+	}
+}
@@ -0,0 +1,188 @@
+package rooms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/server"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// maxMessageBytes bounds a single inbound WebSocket message; the largest
+// legitimate one is a chat line.
+const maxMessageBytes = 16 * 1024
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code. The code
+// doubles as the WebSocket error event code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrRoomNotFound):
+		return http.StatusNotFound, "room_not_found"
+	case errors.Is(err, ErrCrateNotFound):
+		return http.StatusNotFound, "crate_not_found"
+	case errors.Is(err, ErrTrackNotInCrate):
+		return http.StatusNotFound, "track_not_in_crate"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrPrivateCrate):
+		return http.StatusForbidden, "private_crate"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+func (h *Handlers) ListRooms(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rooms": h.manager.List(c.Request.Context(), c.GetString("user_id"), c.GetString("user_role"))})
+}
+
+func (h *Handlers) CreateRoom(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	room, err := h.manager.Create(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"room": room.Info()})
+}
+
+// joinable loads the room named in the path and checks the caller may join.
+func (h *Handlers) joinable(c *gin.Context) (*Room, bool) {
+	room, err := h.manager.Get(c.Param("id"))
+	if err == nil {
+		err = h.manager.CanJoin(c.Request.Context(), room, c.GetString("user_id"), c.GetString("user_role"))
+	}
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return room, true
+}
+
+func (h *Handlers) GetRoom(c *gin.Context) {
+	room, ok := h.joinable(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"room": room.Info()})
+}
+
+func (h *Handlers) CloseRoom(c *gin.Context) {
+	if err := h.manager.Close(c.Param("id"), c.GetString("user_id"), c.GetString("user_role")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "room closed"})
+}
+
+// checkOrigin rejects cross-site WebSocket upgrades. Browsers send the auth
+// cookie with them and CORS doesn't apply, so without this any page could
+// drive a room as the logged-in user. Non-browser clients send no Origin.
+func checkOrigin(_ *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" || server.IsAllowedOrigin(origin) {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == req.Host {
+		return nil
+	}
+	return ErrAccessDenied
+}
+
+// Connect upgrades to a WebSocket and attaches the caller to the room. Each
+// connection gets a writer goroutine draining its send buffer; the handler
+// goroutine reads messages until the socket closes.
+func (h *Handlers) Connect(c *gin.Context) {
+	room, ok := h.joinable(c)
+	if !ok {
+		return
+	}
+	cl := newClient(c.GetString("user_id"), c.GetString("user_email"))
+
+	ws := websocket.Server{
+		Handshake: checkOrigin,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			conn.MaxPayloadBytes = maxMessageBytes
+			if err := room.join(cl); err != nil {
+				_ = websocket.JSON.Send(conn, errorEvent("room_not_found", err.Error()))
+				return
+			}
+			defer room.leave(cl)
+
+			go func() {
+				for ev := range cl.send {
+					_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+					if err := websocket.JSON.Send(conn, ev); err != nil {
+						break
+					}
+				}
+				// Either the room dropped us or the socket failed; both end the read loop.
+				conn.Close()
+			}()
+
+			ctx := c.Request.Context()
+			for {
+				var msg inbound
+				if err := websocket.JSON.Receive(conn, &msg); err != nil {
+					var syntaxErr *json.SyntaxError
+					var typeErr *json.UnmarshalTypeError
+					if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+						room.reply(cl, errorEvent("invalid_request", "messages must be JSON objects"))
+						continue
+					}
+					return
+				}
+				room.handle(ctx, cl, msg)
+			}
+		},
+	}
+	ws.ServeHTTP(c.Writer, c.Request)
+}
+
+// StreamTrack serves a track's audio to room members, with range support so
+// players can seek to the room clock. Only tracks playing, queued or just
+// played in the room are served.
+func (h *Handlers) StreamTrack(c *gin.Context) {
+	room, ok := h.joinable(c)
+	if !ok {
+		return
+	}
+	track, ok := room.StreamableTrack(c.Param("trackId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "track_not_found", "message": "Track is not in this room"}})
+		return
+	}
+	file, _, err := h.manager.storage.Open(c.Request.Context(), track.FilePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "server_error", "message": "Failed to open file"}})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", track.ContentType)
+	c.Header("Cache-Control", "private, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, track.OriginalFilename, time.Time{}, file)
+}
@@ -0,0 +1,192 @@
+package rooms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRoomNotFound    = errors.New("room not found")
+	ErrCrateNotFound   = errors.New("crate not found")
+	ErrTrackNotInCrate = errors.New("track is not in that crate")
+	ErrAccessDenied    = errors.New("access denied")
+	ErrPrivateCrate    = errors.New("only public crates or the room's own crate can be queued")
+	ErrInvalidRequest  = errors.New("invalid request")
+)
+
+// CrateAccess is the slice of the playlists manager rooms need. Taking an
+// interface keeps rooms from importing playlists.
+type CrateAccess interface {
+	CanAccessPlaylist(playlistID, userID string) error
+}
+
+// Manager owns the open rooms. Like radio stations, rooms live only in
+// memory and end with the process.
+type Manager struct {
+	repo    *Repository
+	crates  CrateAccess
+	storage storage.Storage
+
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+func NewManager(repo *Repository, crates CrateAccess, storage storage.Storage) *Manager {
+	return &Manager{repo: repo, crates: crates, storage: storage, rooms: make(map[string]*Room)}
+}
+
+type CreateRequest struct {
+	PlaylistID string `json:"playlist_id" binding:"required"`
+	Name       string `json:"name"`
+}
+
+// Create opens a room on one of the host's own crates.
+func (m *Manager) Create(ctx context.Context, hostUserID string, req *CreateRequest) (*Room, error) {
+	crate, err := m.repo.GetCrate(ctx, req.PlaylistID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.crates.CanAccessPlaylist(crate.ID, hostUserID); err != nil {
+		return nil, ErrAccessDenied
+	}
+
+	name := req.Name
+	if name == "" {
+		name = crate.Name
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	room := &Room{
+		ID:         uuid.New().String(),
+		Name:       name,
+		HostUserID: hostUserID,
+		PlaylistID: crate.ID,
+		CreatedAt:  now,
+		manager:    m,
+		now:        time.Now,
+		clients:    make(map[*client]struct{}),
+		emptySince: now,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.rooms[room.ID] = room
+	m.mu.Unlock()
+
+	go func() {
+		defer close(room.done)
+		room.run(runCtx)
+		room.close("room closed")
+		m.mu.Lock()
+		delete(m.rooms, room.ID)
+		m.mu.Unlock()
+		fmt.Printf("[Rooms] Room %s closed\n", room.ID)
+	}()
+
+	fmt.Printf("[Rooms] Room %s (%q) opened by %s on crate %s\n", room.ID, room.Name, hostUserID, crate.ID)
+	return room, nil
+}
+
+// Close ends a room. Only its host or an admin may close it.
+func (m *Manager) Close(id, userID, userRole string) error {
+	room, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if userRole != "admin" && room.HostUserID != userID {
+		return ErrAccessDenied
+	}
+	room.cancel()
+	<-room.done
+	return nil
+}
+
+func (m *Manager) Get(id string) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room, ok := m.rooms[id]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// PlayingCount is the number of rooms with members and a track playing.
+func (m *Manager) PlayingCount() int {
+	m.mu.Lock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	m.mu.Unlock()
+
+	n := 0
+	for _, room := range rooms {
+		if info := room.Info(); info.Playing && info.Members > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// List returns the rooms the user may join, newest first.
+func (m *Manager) List(ctx context.Context, userID, userRole string) []RoomInfo {
+	m.mu.Lock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].CreatedAt.After(rooms[j].CreatedAt) })
+	out := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		if m.CanJoin(ctx, room, userID, userRole) == nil {
+			out = append(out, room.Info())
+		}
+	}
+	return out
+}
+
+// CanJoin applies the crate visibility rules to a room: the host and admins
+// can always join; anyone else only while the room's crate is public or
+// accessible to them. It is checked on every connect, so making the crate
+// private locks out new joiners without kicking anyone already listening.
+func (m *Manager) CanJoin(ctx context.Context, room *Room, userID, userRole string) error {
+	if userRole == "admin" || room.HostUserID == userID {
+		return nil
+	}
+	crate, err := m.repo.GetCrate(ctx, room.PlaylistID)
+	if err != nil {
+		return err
+	}
+	if crate.IsPublic || m.crates.CanAccessPlaylist(crate.ID, userID) == nil {
+		return nil
+	}
+	return ErrAccessDenied
+}
+
+// queueable returns the track if the host may add it to the room's queue: it
+// must come from a crate the host can access, and that crate must be public
+// or the room's own crate so members only ever hear what they could browse.
+func (m *Manager) queueable(ctx context.Context, room *Room, playlistID, trackID string) (*Track, error) {
+	crate, err := m.repo.GetCrate(ctx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.crates.CanAccessPlaylist(crate.ID, room.HostUserID); err != nil {
+		return nil, ErrAccessDenied
+	}
+	if crate.ID != room.PlaylistID && !crate.IsPublic {
+		return nil, ErrPrivateCrate
+	}
+	return m.repo.GetCrateTrack(ctx, crate.ID, trackID)
+}
@@ -0,0 +1,80 @@
+package rooms
+
+import "time"
+
+// driftTolerance is how far a member's reported position may stray from the
+// room clock before the server tells it to jump. Below ~300ms two speakers in
+// different houses sound "together"; correcting tighter than that just makes
+// players stutter on every network hiccup.
+const driftTolerance = 300 * time.Millisecond
+
+// playback is the room clock. The host's commands set it; everyone else
+// derives where they should be from it. Position is stored as of updatedAt
+// and extrapolated while playing, so nothing has to tick.
+type playback struct {
+	track     *Track
+	playing   bool
+	position  time.Duration // at updatedAt
+	updatedAt time.Time
+}
+
+// positionAt returns where playback is at t, clamped to the track length
+// when it is known.
+func (p *playback) positionAt(t time.Time) time.Duration {
+	pos := p.position
+	if p.playing {
+		pos += t.Sub(p.updatedAt)
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	if d := p.duration(); d > 0 && pos > d {
+		pos = d
+	}
+	return pos
+}
+
+func (p *playback) duration() time.Duration {
+	if p.track == nil {
+		return 0
+	}
+	return time.Duration(p.track.DurationSeconds * float64(time.Second))
+}
+
+// ended reports whether a playing track has run past its known length.
+func (p *playback) ended(t time.Time) bool {
+	d := p.duration()
+	return p.track != nil && p.playing && d > 0 && p.positionAt(t) >= d
+}
+
+// load switches to a new track from the top.
+func (p *playback) load(track *Track, autoplay bool, t time.Time) {
+	p.track = track
+	p.playing = autoplay
+	p.position = 0
+	p.updatedAt = t
+}
+
+func (p *playback) setPlaying(playing bool, t time.Time) {
+	p.position = p.positionAt(t)
+	p.playing = playing
+	p.updatedAt = t
+}
+
+func (p *playback) seek(pos time.Duration, t time.Time) {
+	p.position = pos
+	p.updatedAt = t
+	p.position = p.positionAt(t) // clamp
+}
+
+// drift compares a member's reported position with the room clock. It
+// returns the expected position and whether the member is far enough off to
+// need a correction.
+func (p *playback) drift(reported time.Duration, t time.Time) (expected time.Duration, correct bool) {
+	expected = p.positionAt(t)
+	diff := reported - expected
+	if diff < 0 {
+		diff = -diff
+	}
+	return expected, diff > driftTolerance
+}
@@ -0,0 +1,81 @@
+package rooms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaybackPositionExtrapolatesWhilePlaying(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var p playback
+	p.load(&Track{ID: "t1", DurationSeconds: 60}, true, t0)
+
+	if got := p.positionAt(t0.Add(1500 * time.Millisecond)); got != 1500*time.Millisecond {
+		t.Errorf("playing position = %v, want 1.5s", got)
+	}
+
+	p.setPlaying(false, t0.Add(2*time.Second))
+	if got := p.positionAt(t0.Add(10 * time.Second)); got != 2*time.Second {
+		t.Errorf("paused position = %v, want 2s", got)
+	}
+
+	p.seek(30*time.Second, t0.Add(11*time.Second))
+	p.setPlaying(true, t0.Add(11*time.Second))
+	if got := p.positionAt(t0.Add(12 * time.Second)); got != 31*time.Second {
+		t.Errorf("after seek = %v, want 31s", got)
+	}
+}
+
+func TestPlaybackClampsAndEnds(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var p playback
+	p.load(&Track{ID: "t1", DurationSeconds: 10}, true, t0)
+
+	if p.ended(t0.Add(9 * time.Second)) {
+		t.Error("ended before the track's duration")
+	}
+	if got := p.positionAt(t0.Add(20 * time.Second)); got != 10*time.Second {
+		t.Errorf("position past end = %v, want clamp to 10s", got)
+	}
+	if !p.ended(t0.Add(20 * time.Second)) {
+		t.Error("not ended past the track's duration")
+	}
+
+	p.seek(-5*time.Second, t0)
+	if got := p.positionAt(t0); got != 0 {
+		t.Errorf("negative seek = %v, want 0", got)
+	}
+
+	// Unknown duration never ends on its own; the host has to move on.
+	p.load(&Track{ID: "t2"}, true, t0)
+	if p.ended(t0.Add(time.Hour)) {
+		t.Error("track with unknown duration reported ended")
+	}
+}
+
+func TestPlaybackDrift(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var p playback
+	p.load(&Track{ID: "t1", DurationSeconds: 300}, true, t0)
+	now := t0.Add(20 * time.Second)
+
+	cases := []struct {
+		reported time.Duration
+		correct  bool
+	}{
+		{20 * time.Second, false},
+		{20*time.Second + 250*time.Millisecond, false},
+		{20*time.Second - 250*time.Millisecond, false},
+		{20*time.Second + 400*time.Millisecond, true},
+		{18 * time.Second, true},
+	}
+	for _, tc := range cases {
+		expected, correct := p.drift(tc.reported, now)
+		if expected != 20*time.Second {
+			t.Errorf("drift(%v) expected = %v, want 20s", tc.reported, expected)
+		}
+		if correct != tc.correct {
+			t.Errorf("drift(%v) correct = %v, want %v", tc.reported, correct, tc.correct)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package rooms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Crate is the subset of a playlist row a room needs for access checks.
+type Crate struct {
+	ID          string
+	OwnerUserID string
+	Name        string
+	IsPublic    bool
+}
+
+// Track is the subset of a track row a room needs to queue and serve it.
+type Track struct {
+	ID               string
+	Title            string
+	Artist           string
+	OriginalFilename string
+	DurationSeconds  float64 // 0 when unknown
+	ContentType      string
+	FilePath         string
+}
+
+func (r *Repository) GetCrate(ctx context.Context, playlistID string) (*Crate, error) {
+	var c Crate
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_user_id, name, is_public FROM playlists WHERE id = ?`,
+		playlistID,
+	).Scan(&c.ID, &c.OwnerUserID, &c.Name, &c.IsPublic)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCrateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCrateTrack returns a track only if it belongs to the given crate, so a
+// host can't queue arbitrary track IDs through a crate they happen to own.
+func (r *Repository) GetCrateTrack(ctx context.Context, playlistID, trackID string) (*Track, error) {
+	var t Track
+	err := r.db.QueryRowContext(ctx, `
+		SELECT t.id, COALESCE(t.title, ''), COALESCE(t.artist, ''), t.original_filename,
+		       COALESCE(t.duration_seconds, 0), t.content_type, t.file_path
+		FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND pt.track_id = ? AND t.deleted_at IS NULL
+	`, playlistID, trackID).Scan(&t.ID, &t.Title, &t.Artist, &t.OriginalFilename,
+		&t.DurationSeconds, &t.ContentType, &t.FilePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTrackNotInCrate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
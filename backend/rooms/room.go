@@ -0,0 +1,523 @@
+package rooms
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Room timing and size limits.
+const (
+	// syncInterval is how often the room clock is rebroadcast so members can
+	// correct drift even when nobody touches the transport.
+	syncInterval = 5 * time.Second
+	// emptyRoomTTL is how long a room with nobody connected stays open, long
+	// enough for the host to reload the page or hop networks.
+	emptyRoomTTL   = 10 * time.Minute
+	maxChatLength  = 500
+	chatHistory    = 50
+	maxQueueLength = 200
+	// playedHistory is how many finished tracks stay streamable, so members
+	// whose players lag a few seconds behind a track change can still finish.
+	playedHistory = 5
+	clientBuffer  = 32
+)
+
+// event is one JSON message sent to members.
+type event map[string]any
+
+// inbound is a message from a member. Only the fields relevant to Type are set.
+type inbound struct {
+	Type       string `json:"type"`
+	TrackID    string `json:"track_id"`
+	PlaylistID string `json:"playlist_id"`
+	ItemID     string `json:"item_id"`
+	PositionMs *int64 `json:"position_ms"`
+	Autoplay   *bool  `json:"autoplay"`
+	ClientTime int64  `json:"client_time"`
+	Text       string `json:"text"`
+}
+
+// TrackInfo is the JSON view of a track in a room.
+type TrackInfo struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	Artist           string  `json:"artist"`
+	OriginalFilename string  `json:"original_filename"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	StreamURL        string  `json:"stream_url"`
+}
+
+// QueueItem is one entry in the shared queue.
+type QueueItem struct {
+	ID         string    `json:"id"`
+	Track      TrackInfo `json:"track"`
+	PlaylistID string    `json:"playlist_id"`
+	AddedAt    time.Time `json:"added_at"`
+
+	track *Track
+}
+
+type ChatMessage struct {
+	UserID string    `json:"user_id"`
+	Email  string    `json:"email"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+type Member struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	IsHost bool   `json:"is_host"`
+}
+
+// RoomInfo is the REST view of a room.
+type RoomInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	HostUserID string     `json:"host_user_id"`
+	PlaylistID string     `json:"playlist_id"`
+	Members    int        `json:"members"`
+	NowPlaying *TrackInfo `json:"now_playing,omitempty"`
+	Playing    bool       `json:"playing"`
+	CreatedAt  time.Time  `json:"created_at"`
+	SocketURL  string     `json:"socket_url"`
+}
+
+// client is one WebSocket connection. A user may hold several (phone and
+// laptop); each gets its own send buffer.
+type client struct {
+	userID string
+	email  string
+	send   chan event
+}
+
+func newClient(userID, email string) *client {
+	return &client{userID: userID, email: email, send: make(chan event, clientBuffer)}
+}
+
+// Room is a listening session bound to one of the host's crates. All state is
+// guarded by mu; sends to members never block, so a stalled connection can't
+// hold up the room.
+type Room struct {
+	ID         string
+	Name       string
+	HostUserID string
+	PlaylistID string
+	CreatedAt  time.Time
+
+	manager *Manager
+	now     func() time.Time
+
+	mu         sync.Mutex
+	clients    map[*client]struct{}
+	pb         playback
+	queue      []QueueItem
+	played     []*Track
+	chat       []ChatMessage
+	emptySince time.Time
+	closed     bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (r *Room) isHost(c *client) bool {
+	return c.userID == r.HostUserID
+}
+
+func (r *Room) trackInfo(t *Track) TrackInfo {
+	return TrackInfo{
+		ID:               t.ID,
+		Title:            t.Title,
+		Artist:           t.Artist,
+		OriginalFilename: t.OriginalFilename,
+		DurationSeconds:  t.DurationSeconds,
+		StreamURL:        "/api/rooms/" + r.ID + "/tracks/" + t.ID + "/stream",
+	}
+}
+
+func (r *Room) Info() RoomInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := RoomInfo{
+		ID:         r.ID,
+		Name:       r.Name,
+		HostUserID: r.HostUserID,
+		PlaylistID: r.PlaylistID,
+		Members:    len(r.membersLocked()),
+		Playing:    r.pb.playing,
+		CreatedAt:  r.CreatedAt,
+		SocketURL:  "/api/rooms/" + r.ID + "/ws",
+	}
+	if r.pb.track != nil {
+		ti := r.trackInfo(r.pb.track)
+		info.NowPlaying = &ti
+	}
+	return info
+}
+
+// membersLocked lists connected users once each, host first.
+func (r *Room) membersLocked() []Member {
+	seen := make(map[string]bool)
+	members := []Member{}
+	for c := range r.clients {
+		if seen[c.userID] {
+			continue
+		}
+		seen[c.userID] = true
+		m := Member{UserID: c.userID, Email: c.email, IsHost: r.isHost(c)}
+		if m.IsHost {
+			members = append([]Member{m}, members...)
+		} else {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+func (r *Room) playbackEventLocked(reason string, now time.Time) event {
+	state := event{
+		"playing":     r.pb.playing,
+		"position_ms": r.pb.positionAt(now).Milliseconds(),
+		"track":       nil,
+	}
+	if r.pb.track != nil {
+		state["track"] = r.trackInfo(r.pb.track)
+	}
+	return event{"type": "playback", "reason": reason, "playback": state, "server_time": now.UnixMilli()}
+}
+
+func (r *Room) queueEventLocked() event {
+	queue := make([]QueueItem, len(r.queue))
+	copy(queue, r.queue)
+	return event{"type": "queue", "queue": queue}
+}
+
+func (r *Room) membersEventLocked() event {
+	return event{"type": "members", "members": r.membersLocked()}
+}
+
+// sendLocked queues ev for one client, dropping the client if its buffer is
+// full. Its writer sees the closed channel and hangs up.
+func (r *Room) sendLocked(c *client, ev event) {
+	select {
+	case c.send <- ev:
+	default:
+		delete(r.clients, c)
+		close(c.send)
+		if len(r.clients) == 0 {
+			r.emptySince = r.now()
+		}
+	}
+}
+
+func (r *Room) broadcastLocked(ev event) {
+	for c := range r.clients {
+		r.sendLocked(c, ev)
+	}
+}
+
+func errorEvent(code, message string) event {
+	return event{"type": "error", "code": code, "message": message}
+}
+
+// join registers c and sends it a full snapshot of the room.
+func (r *Room) join(c *client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRoomNotFound
+	}
+	now := r.now()
+	r.clients[c] = struct{}{}
+	chat := make([]ChatMessage, len(r.chat))
+	copy(chat, r.chat)
+	r.sendLocked(c, event{
+		"type":        "state",
+		"room":        event{"id": r.ID, "name": r.Name, "host_user_id": r.HostUserID, "playlist_id": r.PlaylistID},
+		"you":         Member{UserID: c.userID, Email: c.email, IsHost: r.isHost(c)},
+		"playback":    r.playbackEventLocked("join", now)["playback"],
+		"queue":       r.queueEventLocked()["queue"],
+		"members":     r.membersLocked(),
+		"chat":        chat,
+		"server_time": now.UnixMilli(),
+	})
+	r.broadcastLocked(r.membersEventLocked())
+	return nil
+}
+
+func (r *Room) leave(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[c]; !ok {
+		return
+	}
+	delete(r.clients, c)
+	close(c.send)
+	if len(r.clients) == 0 {
+		r.emptySince = r.now()
+	}
+	r.broadcastLocked(r.membersEventLocked())
+}
+
+// handle applies one message from c. Failures go back to c alone as an error
+// event; they never end the connection.
+func (r *Room) handle(ctx context.Context, c *client, msg inbound) {
+	if msg.Type == "queue_add" {
+		// The crate lookups hit the database, so do them before taking the lock.
+		if !r.isHost(c) {
+			r.reply(c, errorEvent("host_only", "only the host can change the queue"))
+			return
+		}
+		if err := r.addToQueue(ctx, msg.PlaylistID, msg.TrackID); err != nil {
+			_, code := errorStatus(err)
+			r.reply(c, errorEvent(code, err.Error()))
+		}
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	now := r.now()
+
+	switch msg.Type {
+	case "ping":
+		// Members estimate their clock offset from the round trip, NTP-style.
+		r.sendLocked(c, event{"type": "pong", "client_time": msg.ClientTime, "server_time": now.UnixMilli()})
+		return
+	case "position":
+		if msg.PositionMs == nil || r.pb.track == nil {
+			return
+		}
+		expected, correct := r.pb.drift(time.Duration(*msg.PositionMs)*time.Millisecond, now)
+		if correct {
+			r.sendLocked(c, event{
+				"type":        "correct",
+				"position_ms": expected.Milliseconds(),
+				"drift_ms":    *msg.PositionMs - expected.Milliseconds(),
+				"playing":     r.pb.playing,
+				"server_time": now.UnixMilli(),
+			})
+		}
+		return
+	case "chat":
+		text := strings.TrimSpace(msg.Text)
+		if text == "" {
+			return
+		}
+		if len([]rune(text)) > maxChatLength {
+			r.sendLocked(c, errorEvent("invalid_request", fmt.Sprintf("chat messages are limited to %d characters", maxChatLength)))
+			return
+		}
+		m := ChatMessage{UserID: c.userID, Email: c.email, Text: text, SentAt: now}
+		r.chat = append(r.chat, m)
+		if len(r.chat) > chatHistory {
+			r.chat = r.chat[len(r.chat)-chatHistory:]
+		}
+		r.broadcastLocked(event{"type": "chat", "message": m})
+		return
+	}
+
+	if !r.isHost(c) {
+		r.sendLocked(c, errorEvent("host_only", "only the host can control playback"))
+		return
+	}
+
+	switch msg.Type {
+	case "play", "pause":
+		if r.pb.track == nil {
+			r.sendLocked(c, errorEvent("nothing_loaded", "no track is loaded"))
+			return
+		}
+		if msg.PositionMs != nil {
+			r.pb.seek(time.Duration(*msg.PositionMs)*time.Millisecond, now)
+		}
+		r.pb.setPlaying(msg.Type == "play", now)
+		r.broadcastLocked(r.playbackEventLocked(msg.Type, now))
+	case "seek":
+		if r.pb.track == nil || msg.PositionMs == nil {
+			r.sendLocked(c, errorEvent("invalid_request", "seek needs a loaded track and position_ms"))
+			return
+		}
+		r.pb.seek(time.Duration(*msg.PositionMs)*time.Millisecond, now)
+		r.broadcastLocked(r.playbackEventLocked("seek", now))
+	case "load":
+		idx := r.queueIndexLocked(msg.ItemID)
+		if idx < 0 {
+			r.sendLocked(c, errorEvent("item_not_found", "queue item not found"))
+			return
+		}
+		r.loadLocked(idx, msg.Autoplay == nil || *msg.Autoplay, now)
+		r.broadcastLocked(r.playbackEventLocked("load", now))
+		r.broadcastLocked(r.queueEventLocked())
+	case "next":
+		if len(r.queue) == 0 {
+			r.sendLocked(c, errorEvent("queue_empty", "the queue is empty"))
+			return
+		}
+		r.loadLocked(0, msg.Autoplay == nil || *msg.Autoplay, now)
+		r.broadcastLocked(r.playbackEventLocked("load", now))
+		r.broadcastLocked(r.queueEventLocked())
+	case "queue_remove":
+		idx := r.queueIndexLocked(msg.ItemID)
+		if idx < 0 {
+			r.sendLocked(c, errorEvent("item_not_found", "queue item not found"))
+			return
+		}
+		r.queue = append(r.queue[:idx], r.queue[idx+1:]...)
+		r.broadcastLocked(r.queueEventLocked())
+	default:
+		r.sendLocked(c, errorEvent("unknown_type", fmt.Sprintf("unknown message type %q", msg.Type)))
+	}
+}
+
+func (r *Room) reply(c *client, ev event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[c]; ok {
+		r.sendLocked(c, ev)
+	}
+}
+
+func (r *Room) addToQueue(ctx context.Context, playlistID, trackID string) error {
+	if trackID == "" {
+		return fmt.Errorf("%w: track_id is required", ErrInvalidRequest)
+	}
+	if playlistID == "" {
+		playlistID = r.PlaylistID
+	}
+	track, err := r.manager.queueable(ctx, r, playlistID, trackID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRoomNotFound
+	}
+	if len(r.queue) >= maxQueueLength {
+		return fmt.Errorf("%w: the queue is limited to %d tracks", ErrInvalidRequest, maxQueueLength)
+	}
+	r.queue = append(r.queue, QueueItem{
+		ID:         uuid.New().String(),
+		Track:      r.trackInfo(track),
+		PlaylistID: playlistID,
+		AddedAt:    r.now(),
+		track:      track,
+	})
+	r.broadcastLocked(r.queueEventLocked())
+	return nil
+}
+
+func (r *Room) queueIndexLocked(itemID string) int {
+	for i, item := range r.queue {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadLocked takes queue[idx] out of the queue and makes it the current track.
+func (r *Room) loadLocked(idx int, autoplay bool, now time.Time) {
+	item := r.queue[idx]
+	r.queue = append(r.queue[:idx], r.queue[idx+1:]...)
+	if r.pb.track != nil {
+		r.played = append(r.played, r.pb.track)
+		if len(r.played) > playedHistory {
+			r.played = r.played[len(r.played)-playedHistory:]
+		}
+	}
+	r.pb.load(item.track, autoplay, now)
+}
+
+// StreamableTrack returns the track if it is playing, queued or just played
+// in this room. Members stream through the room rather than /api/tracks,
+// which only serves a track to its owner.
+func (r *Room) StreamableTrack(trackID string) (*Track, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pb.track != nil && r.pb.track.ID == trackID {
+		return r.pb.track, true
+	}
+	for _, item := range r.queue {
+		if item.track.ID == trackID {
+			return item.track, true
+		}
+	}
+	for _, t := range r.played {
+		if t.ID == trackID {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// run rebroadcasts the room clock, advances the queue when a track ends, and
+// returns once ctx is cancelled or the room has sat empty for emptyRoomTTL.
+func (r *Room) run(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.tick() {
+				return
+			}
+		}
+	}
+}
+
+// tick does one sync pass. It returns false when the room should close.
+func (r *Room) tick() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if len(r.clients) == 0 {
+		return now.Sub(r.emptySince) < emptyRoomTTL
+	}
+	if r.pb.ended(now) {
+		if len(r.queue) > 0 {
+			r.loadLocked(0, true, now)
+			r.broadcastLocked(r.playbackEventLocked("ended", now))
+			r.broadcastLocked(r.queueEventLocked())
+			return true
+		}
+		r.pb.setPlaying(false, now)
+		r.broadcastLocked(r.playbackEventLocked("ended", now))
+		return true
+	}
+	if r.pb.track != nil {
+		r.broadcastLocked(r.playbackEventLocked("sync", now))
+	}
+	return true
+}
+
+// close disconnects every member with a final "closed" event.
+func (r *Room) close(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	for c := range r.clients {
+		select {
+		case c.send <- event{"type": "closed", "reason": reason}:
+		default:
+		}
+		close(c.send)
+	}
+	r.clients = map[*client]struct{}{}
+}
@@ -0,0 +1,198 @@
+package rooms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ownerAccess mirrors playlists.Manager.CanAccessPlaylist: owners only.
+type ownerAccess struct{ owners map[string]string }
+
+func (a ownerAccess) CanAccessPlaylist(playlistID, userID string) error {
+	if a.owners[playlistID] != userID {
+		return errors.New("access denied")
+	}
+	return nil
+}
+
+func newTestRoom(t *testing.T) (*Room, *time.Time) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE playlists (id TEXT PRIMARY KEY, owner_user_id TEXT, name TEXT, is_public BOOLEAN);
+		CREATE TABLE tracks (id TEXT PRIMARY KEY, title TEXT, artist TEXT, original_filename TEXT,
+			duration_seconds REAL, content_type TEXT, file_path TEXT, deleted_at DATETIME);
+		CREATE TABLE playlist_tracks (playlist_id TEXT, track_id TEXT);
+		INSERT INTO playlists VALUES ('room', 'host', 'Room crate', FALSE),
+			('public', 'host', 'Public crate', TRUE),
+			('private', 'host', 'Private crate', FALSE),
+			('other', 'guest', 'Guest crate', TRUE);
+		INSERT INTO tracks (id, title, artist, original_filename, duration_seconds, content_type, file_path) VALUES ('t1', 'One', 'A', 'one.mp3', 10, 'audio/mpeg', 'u/t1.mp3'),
+			('t2', 'Two', 'B', 'two.mp3', 20, 'audio/mpeg', 'u/t2.mp3'),
+			('t3', 'Three', 'C', 'three.mp3', 30, 'audio/mpeg', 'u/t3.mp3'),
+			('t4', 'Four', 'D', 'four.mp3', 40, 'audio/mpeg', 'u/t4.mp3');
+		INSERT INTO playlist_tracks VALUES ('room', 't1'), ('public', 't2'), ('private', 't3'), ('other', 't4');
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	access := ownerAccess{owners: map[string]string{"room": "host", "public": "host", "private": "host", "other": "guest"}}
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}), access, nil)
+	clock := time.Unix(1000, 0)
+	room := &Room{
+		ID:         "r1",
+		HostUserID: "host",
+		PlaylistID: "room",
+		manager:    m,
+		now:        func() time.Time { return clock },
+		clients:    make(map[*client]struct{}),
+	}
+	return room, &clock
+}
+
+// drain returns every event queued for c so far.
+func drain(c *client) []event {
+	var out []event
+	for {
+		select {
+		case ev := <-c.send:
+			out = append(out, ev)
+		default:
+			return out
+		}
+	}
+}
+
+func lastOfType(events []event, typ string) event {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i]["type"] == typ {
+			return events[i]
+		}
+	}
+	return nil
+}
+
+func ms(v int64) *int64 { return &v }
+
+func TestRoomHostControlsAndQueue(t *testing.T) {
+	room, clock := newTestRoom(t)
+	ctx := context.Background()
+	host := newClient("host", "host@x")
+	guest := newClient("guest", "guest@x")
+	if err := room.join(host); err != nil {
+		t.Fatal(err)
+	}
+	if err := room.join(guest); err != nil {
+		t.Fatal(err)
+	}
+	if ev := lastOfType(drain(guest), "state"); ev == nil {
+		t.Fatal("guest got no state snapshot on join")
+	}
+	drain(host)
+
+	room.handle(ctx, guest, inbound{Type: "queue_add", TrackID: "t1"})
+	if ev := lastOfType(drain(guest), "error"); ev == nil || ev["code"] != "host_only" {
+		t.Fatalf("guest queue_add: got %v, want host_only error", ev)
+	}
+
+	room.handle(ctx, host, inbound{Type: "queue_add", TrackID: "t1"})
+	room.handle(ctx, host, inbound{Type: "queue_add", PlaylistID: "public", TrackID: "t2"})
+	room.handle(ctx, host, inbound{Type: "queue_add", PlaylistID: "private", TrackID: "t3"})
+	if ev := lastOfType(drain(host), "error"); ev == nil || ev["code"] != "private_crate" {
+		t.Errorf("private crate queue_add: got %v, want private_crate error", ev)
+	}
+	room.handle(ctx, host, inbound{Type: "queue_add", PlaylistID: "other", TrackID: "t4"})
+	if ev := lastOfType(drain(host), "error"); ev == nil || ev["code"] != "access_denied" {
+		t.Errorf("someone else's crate queue_add: got %v, want access_denied error", ev)
+	}
+	room.handle(ctx, host, inbound{Type: "queue_add", TrackID: "t2"})
+	if ev := lastOfType(drain(host), "error"); ev == nil || ev["code"] != "track_not_in_crate" {
+		t.Errorf("track outside crate: got %v, want track_not_in_crate error", ev)
+	}
+	if q := lastOfType(drain(guest), "queue"); q == nil || len(q["queue"].([]QueueItem)) != 2 {
+		t.Fatalf("guest queue = %v, want 2 items", q)
+	}
+
+	room.handle(ctx, guest, inbound{Type: "next"})
+	if ev := lastOfType(drain(guest), "error"); ev == nil || ev["code"] != "host_only" {
+		t.Errorf("guest next: got %v, want host_only error", ev)
+	}
+
+	room.handle(ctx, host, inbound{Type: "next"})
+	ev := lastOfType(drain(guest), "playback")
+	if ev == nil || ev["reason"] != "load" {
+		t.Fatalf("guest playback after next = %v", ev)
+	}
+	if _, ok := room.StreamableTrack("t1"); !ok {
+		t.Error("current track should be streamable")
+	}
+	if _, ok := room.StreamableTrack("t3"); ok {
+		t.Error("track never queued should not be streamable")
+	}
+
+	// Members drifting past the tolerance get told where to be.
+	*clock = clock.Add(4 * time.Second)
+	room.handle(ctx, guest, inbound{Type: "position", PositionMs: ms(3000)})
+	corr := lastOfType(drain(guest), "correct")
+	if corr == nil || corr["position_ms"] != int64(4000) {
+		t.Errorf("drift correction = %v, want position_ms 4000", corr)
+	}
+	room.handle(ctx, guest, inbound{Type: "position", PositionMs: ms(4100)})
+	if corr := lastOfType(drain(guest), "correct"); corr != nil {
+		t.Errorf("in-tolerance report got correction %v", corr)
+	}
+
+	// The room advances on its own when the current track runs out.
+	*clock = clock.Add(7 * time.Second)
+	if !room.tick() {
+		t.Fatal("tick closed an occupied room")
+	}
+	ev = lastOfType(drain(guest), "playback")
+	if ev == nil || ev["reason"] != "ended" {
+		t.Fatalf("playback after track end = %v", ev)
+	}
+	if got := ev["playback"].(event)["track"].(TrackInfo).ID; got != "t2" {
+		t.Errorf("auto-advanced to %s, want t2", got)
+	}
+	if _, ok := room.StreamableTrack("t1"); !ok {
+		t.Error("just-played track should stay streamable")
+	}
+}
+
+func TestRoomChatAndEmptyTimeout(t *testing.T) {
+	room, clock := newTestRoom(t)
+	ctx := context.Background()
+	host := newClient("host", "host@x")
+	guest := newClient("guest", "guest@x")
+	room.join(host)
+	room.join(guest)
+	drain(host)
+
+	room.handle(ctx, guest, inbound{Type: "chat", Text: "  nice one  "})
+	ev := lastOfType(drain(host), "chat")
+	if ev == nil || ev["message"].(ChatMessage).Text != "nice one" {
+		t.Fatalf("host chat event = %v", ev)
+	}
+
+	room.leave(host)
+	room.leave(guest)
+	*clock = clock.Add(emptyRoomTTL - time.Second)
+	if !room.tick() {
+		t.Error("room closed before emptyRoomTTL")
+	}
+	*clock = clock.Add(2 * time.Second)
+	if room.tick() {
+		t.Error("room still open after emptyRoomTTL")
+	}
+}
@@ -0,0 +1,19 @@
+package rooms
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/rooms")
+		{
+			r.GET("", handlers.ListRooms)
+			r.POST("", handlers.CreateRoom)
+			r.GET("/:id", handlers.GetRoom)
+			r.DELETE("/:id", handlers.CloseRoom)
+			r.GET("/:id/ws", handlers.Connect)
+			r.GET("/:id/tracks/:trackId/stream", handlers.StreamTrack)
+		}
+	}
+}
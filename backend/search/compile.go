@@ -0,0 +1,245 @@
+package search
+
+import (
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+)
+
+// node is one part of a parsed query. sql renders it as a condition on
+// tracks aliased t, appending its arguments to args.
+type node interface {
+	sql(c *compiler) string
+}
+
+type compiler struct {
+	userID string
+	// now is when relative dates are measured from.
+	now  time.Time
+	args []any
+}
+
+type andNode []node
+type orNode []node
+type notNode struct{ node }
+
+// allNode matches every track.
+type allNode struct{}
+
+// textNode is a text search in one tracks_fts column, or all of them.
+type textNode struct {
+	column string
+	text   string
+	phrase bool
+}
+
+// condNode is a ready-made condition.
+type condNode struct {
+	cond string
+	args []any
+}
+
+// crateNode matches tracks in one of the searching user's crates.
+type crateNode struct {
+	name string
+}
+
+// markNode is a condition on the searching user's own rating, favourite
+// flag or colour label, written with markToken for the column.
+type markNode struct {
+	column string
+	node
+}
+
+func (n andNode) sql(c *compiler) string { return c.join(n, " AND ") }
+func (n orNode) sql(c *compiler) string  { return c.join(n, " OR ") }
+
+// sql for NOT treats unknown as false first, so bpm:120 matches tracks with
+// a BPM of 120 and -bpm:120 every other track, including unanalyzed ones.
+func (n notNode) sql(c *compiler) string { return "NOT COALESCE(" + n.node.sql(c) + ", 0)" }
+
+func (allNode) sql(*compiler) string { return "1" }
+
+func (n textNode) sql(c *compiler) string {
+	c.args = append(c.args, n.fts())
+	return "t.id IN (SELECT track_id FROM tracks_fts WHERE tracks_fts MATCH ?)"
+}
+
+func (n condNode) sql(c *compiler) string {
+	c.args = append(c.args, n.args...)
+	return "(" + n.cond + ")"
+}
+
+func (n crateNode) sql(c *compiler) string {
+	c.args = append(c.args, c.userID, n.name, n.name)
+	return `t.id IN (SELECT pt.track_id FROM playlist_tracks pt
+		JOIN playlists p ON p.id = pt.playlist_id
+		WHERE p.owner_user_id = ? AND (p.id = ? OR p.name = ? COLLATE NOCASE))`
+}
+
+// sql for a mark condition binds the user ID for each markToken and the
+// condition's own arguments in the order their placeholders appear.
+func (n markNode) sql(c *compiler) string {
+	inner := &compiler{userID: c.userID, now: c.now}
+	cond := n.node.sql(inner)
+	col := markColumn("t.", n.column)
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(cond); i++ {
+		switch {
+		case cond[i] == '\'':
+			quoted = !quoted
+		case quoted:
+		case strings.HasPrefix(cond[i:], markToken):
+			b.WriteString(col)
+			c.args = append(c.args, c.userID)
+			i += len(markToken) - 1
+			continue
+		case cond[i] == '?':
+			c.args = append(c.args, inner.args[0])
+			inner.args = inner.args[1:]
+		}
+		b.WriteByte(cond[i])
+	}
+	return b.String()
+}
+
+func (c *compiler) join(nodes []node, op string) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.sql(c)
+	}
+	return "(" + strings.Join(parts, op) + ")"
+}
+
+// fts renders the term as an FTS5 string, quoted so FTS5 operators and
+// punctuation in it are searched for as text. Unquoted terms match as a
+// prefix, so results appear as you type.
+func (n textNode) fts() string {
+	s := `"` + strings.ReplaceAll(n.text, `"`, `""`) + `"`
+	if !n.phrase {
+		s += "*"
+	}
+	if n.column != "" {
+		s = n.column + " : " + s
+	}
+	return s
+}
+
+// ftsExpr renders n as one FTS5 expression if it is made only of text
+// terms, AND and OR. FTS5 has no unary NOT, so negations stay in SQL.
+func ftsExpr(n node) (string, bool) {
+	var parts []string
+	var op string
+	switch n := n.(type) {
+	case textNode:
+		return n.fts(), true
+	case andNode:
+		parts, op = make([]string, 0, len(n)), " AND "
+		for _, child := range n {
+			s, ok := ftsExpr(child)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+	case orNode:
+		parts, op = make([]string, 0, len(n)), " OR "
+		for _, child := range n {
+			s, ok := ftsExpr(child)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+	default:
+		return "", false
+	}
+	return "(" + strings.Join(parts, op) + ")", true
+}
+
+// Compiled is a query as SQL over tracks aliased t.
+type Compiled struct {
+	match string
+	where []string
+	args  []any
+}
+
+// Compile turns the query into SQL for userID's library. The query's
+// top-level text terms become a single FTS5 MATCH against tracks_fts,
+// joined as fts, so results can be ranked; everything else becomes
+// parameterized conditions.
+func (q *Query) Compile(userID string) *Compiled {
+	top := []node{q.root}
+	if and, ok := q.root.(andNode); ok {
+		top = and
+	}
+	var match []string
+	c := &compiler{userID: userID}
+	out := &Compiled{}
+	for _, n := range top {
+		if s, ok := ftsExpr(n); ok {
+			match = append(match, s)
+			continue
+		}
+		if _, ok := n.(allNode); ok {
+			continue
+		}
+		out.where = append(out.where, n.sql(c))
+	}
+	out.match = strings.Join(match, " AND ")
+	out.args = c.args
+	return out
+}
+
+// Ranked reports whether the query joins tracks_fts, so results can be
+// ordered by Rank.
+func (c *Compiled) Ranked() bool { return c.match != "" }
+
+// Join returns the join onto tracks_fts, or "" if the query has no
+// top-level text.
+func (c *Compiled) Join() string {
+	if c.match == "" {
+		return ""
+	}
+	return " INNER JOIN tracks_fts fts ON t.id = fts.track_id"
+}
+
+// Where returns the query's conditions, each prefixed with " AND ", and
+// their arguments.
+func (c *Compiled) Where() (string, []any) {
+	var b strings.Builder
+	var args []any
+	if c.match != "" {
+		b.WriteString(" AND tracks_fts MATCH ?")
+		args = append(args, c.match)
+	}
+	for _, w := range c.where {
+		b.WriteString(" AND " + w)
+	}
+	return b.String(), append(args, c.args...)
+}
+
+// Rank is the bm25 score of a track_fts match, lowest best. Hits in the
+// title and artist count most, then album and tags, and the filename least.
+// The weights follow tracks_fts's columns, starting with track_id.
+const Rank = "bm25(tracks_fts, 0, 10, 8, 4, 2, 1, 4)"
+
+// PageKeys returns the order of OrderBy as keys for cursor pagination:
+// best FTS match first when the query is ranked, then fallback.
+func (c *Compiled) PageKeys(fallback ...pagination.Key) []pagination.Key {
+	if c.Ranked() {
+		return append([]pagination.Key{{Expr: Rank}}, fallback...)
+	}
+	return fallback
+}
+
+// OrderBy returns the ORDER BY expression: best FTS match first, then
+// fallback, when the query is ranked, otherwise just fallback.
+func (c *Compiled) OrderBy(fallback string) string {
+	if c.Ranked() {
+		return Rank + ", " + fallback
+	}
+	return fallback
+}
@@ -0,0 +1,31 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FacetNames lists the facets= values.
+var FacetNames = []string{"genre", "key", "bpm"}
+
+// BPMBucketWidth is how many BPM each bucket of the bpm facet spans.
+const BPMBucketWidth = 5
+
+// ParseFacets reads a facets= parameter: a comma-separated list of
+// FacetNames, or "all" for every facet. An empty parameter asks for none.
+func ParseFacets(s string) ([]string, error) {
+	var names []string
+	for _, name := range splitList(strings.ToLower(s)) {
+		if name == "all" {
+			return FacetNames, nil
+		}
+		if !slices.Contains(FacetNames, name) {
+			return nil, fmt.Errorf("facets must be all or a list of %s", strings.Join(FacetNames, ", "))
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,285 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+)
+
+// textFields maps text field names to their tracks_fts columns.
+var textFields = map[string]string{
+	"title":    "title",
+	"artist":   "artist",
+	"album":    "album",
+	"genre":    "genre",
+	"filename": "original_filename",
+}
+
+// fields builds the node for every other field from its value.
+var fields = map[string]func(value string) (node, error){
+	"bpm":       numberField("bpm", "t.bpm", parseNumber, true),
+	"year":      numberField("year", "t.year", parseWhole, false),
+	"energy":    numberField("energy", "t.energy", parseWhole, false),
+	"duration":  numberField("duration", "t.duration_seconds", parseDuration, false),
+	"key":       keyField,
+	"status":    listField("status", "t.analysis_status", AnalysisStatuses, nil),
+	"mood":      listField("mood", "t.mood", analysis.Moods, nil),
+	"quality":   listField("quality", "t.quality_verdict", analysis.QualityVerdicts, nil),
+	"format":    listField("format", "t.content_type", formatNames(), formatTypes),
+	"crate":     crateField,
+	"tag":       tagField,
+	"rating":    markField("rating", numberField("rating", markToken, parseWhole, false)),
+	"favourite": favouriteField,
+	"color":     markField("color", listField("color", markToken, imodels.RatingColors, nil)),
+}
+
+// AnalysisStatuses lists the values of status:.
+var AnalysisStatuses = []string{"pending", "analyzing", "analyzed", "failed", "user_edited"}
+
+// formats maps format: values to the content types uploads are stored with.
+var formats = map[string][]string{
+	"mp3":  {"audio/mpeg", "audio/mp3", "audio/x-mp3"},
+	"flac": {"audio/flac", "audio/x-flac"},
+	"wav":  {"audio/wav", "audio/wave", "audio/x-wav"},
+	"aiff": {"audio/aiff", "audio/x-aiff"},
+}
+
+func formatNames() []string {
+	return []string{"mp3", "flac", "wav", "aiff", "lossless", "lossy"}
+}
+
+func formatTypes(format string) []string {
+	switch format {
+	case "lossless":
+		return slices.Concat(formats["flac"], formats["wav"], formats["aiff"])
+	case "lossy":
+		return formats["mp3"]
+	}
+	return formats[format]
+}
+
+// isField reports whether name is a field of the query language.
+func isField(name string) bool {
+	_, text := textFields[name]
+	_, ok := fields[name]
+	return text || ok
+}
+
+// numberField accepts an exact value, a range ("122-128") or a comparison
+// (">2019", "<=5:00"). An exact value matches the whole unit it names:
+// bpm:128 is 127.5 up to 128.5 when round is set, duration:5:00 is 300 up
+// to 301 seconds.
+func numberField(name, column string, parse func(string) (float64, bool), round bool) func(string) (node, error) {
+	return func(value string) (node, error) {
+		ex := numberExamples[name]
+		bad := fmt.Errorf("%s needs a value like %[1]s:%[2]s, %[1]s:%[3]s or %[1]s:>%[2]s", name, ex[0], ex[1])
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if rest, ok := strings.CutPrefix(value, op); ok {
+				v, ok := parse(rest)
+				if !ok {
+					return nil, bad
+				}
+				return condNode{cond: column + " " + op + " ?", args: []any{v}}, nil
+			}
+		}
+		if lo, hi, ok := strings.Cut(value, "-"); ok {
+			from, okFrom := parse(lo)
+			to, okTo := parse(hi)
+			if !okFrom || !okTo {
+				return nil, bad
+			}
+			if from > to {
+				return nil, fmt.Errorf("%s range %s runs backwards", name, value)
+			}
+			return condNode{cond: column + " >= ? AND " + column + " <= ?", args: []any{from, to}}, nil
+		}
+		v, ok := parse(value)
+		if !ok {
+			return nil, bad
+		}
+		lo := v
+		if round {
+			lo -= 0.5
+		}
+		return condNode{cond: column + " >= ? AND " + column + " < ?", args: []any{lo, lo + 1}}, nil
+	}
+}
+
+// numberExamples are an exact value and a range for each number field,
+// for error messages.
+var numberExamples = map[string][2]string{
+	"bpm":      {"128", "122-128"},
+	"year":     {"2019", "2015-2019"},
+	"energy":   {"7", "6-8"},
+	"duration": {"5:30", "3:00-6:00"},
+	"rating":   {"5", "3-5"},
+}
+
+func parseNumber(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && v >= 0 && !math.IsInf(v, 0)
+}
+
+func parseWhole(s string) (float64, bool) {
+	v, err := strconv.Atoi(s)
+	return float64(v), err == nil && v >= 0
+}
+
+// parseDuration reads seconds ("330"), m:ss ("5:30") or h:mm:ss.
+func parseDuration(s string) (float64, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	var total float64
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 || (i > 0 && (len(p) != 2 || v > 59)) {
+			return 0, false
+		}
+		total = total*60 + float64(v)
+	}
+	return total, true
+}
+
+// keyField matches a comma-separated list of keys in any notation. A key
+// followed by ~ also matches its wheel neighbours: one step either way and
+// the relative major or minor.
+func keyField(value string) (node, error) {
+	var keys []string
+	for _, k := range strings.Split(value, ",") {
+		k, near := strings.CutSuffix(k, "~")
+		camelot, ok := analysis.ParseKey(k)
+		if !ok {
+			return nil, fmt.Errorf("key %q is not a key like 8A, 1m or Am", k)
+		}
+		keys = append(keys, camelot)
+		if near {
+			for neighbour := range analysis.CompatibleKeys(camelot, false) {
+				keys = append(keys, neighbour)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return inNode("t.musical_key", slices.Compact(keys)), nil
+}
+
+// listField matches a comma-separated list of allowed values, each
+// expanded by expand (if set) into the column values it stands for.
+func listField(name, column string, allowed []string, expand func(string) []string) func(string) (node, error) {
+	return func(value string) (node, error) {
+		var values []string
+		for _, v := range strings.Split(strings.ToLower(value), ",") {
+			if !slices.Contains(allowed, v) {
+				return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+			}
+			if expand != nil {
+				values = append(values, expand(v)...)
+			} else {
+				values = append(values, v)
+			}
+		}
+		return inNode(column, values), nil
+	}
+}
+
+func inNode(column string, values []string) node {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return condNode{cond: column + " IN (?" + strings.Repeat(", ?", len(values)-1) + ")", args: args}
+}
+
+// crateField matches tracks in the user's crate with the given name
+// (ignoring case) or id. crate:none matches tracks in no crate at all.
+func crateField(value string) (node, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, errors.New("crate needs a name")
+	}
+	if strings.EqualFold(value, "none") {
+		return condNode{cond: "NOT EXISTS (SELECT 1 FROM playlist_tracks pt WHERE pt.track_id = t.id)"}, nil
+	}
+	return crateNode{name: value}, nil
+}
+
+// tagField matches tracks with any of a comma-separated list of the owner's
+// tags, ignoring case. tag:none matches untagged tracks.
+func tagField(value string) (node, error) {
+	names := splitList(value)
+	if len(names) == 0 {
+		return nil, errors.New("tag needs a name")
+	}
+	if len(names) == 1 && strings.EqualFold(names[0], "none") {
+		return notNode{condNode{cond: tagged("t.", nil)}}, nil
+	}
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	return condNode{cond: tagged("t.", names), args: args}, nil
+}
+
+// tagged is a condition that a track (with columns qualified by prefix,
+// "" meaning the tracks table itself) has any of the named tags, or any tag
+// at all if names is nil. Only a track's owner can tag it, so the tags are
+// the owner's.
+func tagged(prefix string, names []string) string {
+	if prefix == "" {
+		prefix = "tracks."
+	}
+	cond := "EXISTS (SELECT 1 FROM track_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.track_id = " + prefix + "id"
+	if names != nil {
+		cond += " AND g.name COLLATE NOCASE IN (?" + strings.Repeat(", ?", len(names)-1) + ")"
+	}
+	return cond + ")"
+}
+
+// markField wraps a field whose conditions are on markToken, so they test
+// the searching user's own rating, favourite flag or colour label.
+func markField(column string, field func(string) (node, error)) func(string) (node, error) {
+	return func(value string) (node, error) {
+		n, err := field(value)
+		if err != nil {
+			return nil, err
+		}
+		return markNode{column: column, node: n}, nil
+	}
+}
+
+// favouriteField matches the searching user's favourites, or with
+// favourite:no everything else.
+func favouriteField(value string) (node, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return markNode{column: "favourite", node: condNode{cond: markToken + " = 1"}}, nil
+	case "no", "false":
+		return markNode{column: "favourite", node: condNode{cond: markToken + " = 0"}}, nil
+	}
+	return nil, errors.New("favourite must be yes or no")
+}
+
+// markToken stands for a rating column in a markNode's condition.
+const markToken = "{mark}"
+
+// markColumn is an expression for a user's rating, favourite or color on
+// a track (with columns qualified by prefix, "" meaning the tracks table
+// itself), taking the user ID as its one argument. Ratings and favourites
+// are 0 when the user hasn't set them.
+func markColumn(prefix, column string) string {
+	if prefix == "" {
+		prefix = "tracks."
+	}
+	col := "(SELECT r." + column + " FROM track_ratings r WHERE r.track_id = " + prefix + "id AND r.user_id = ?)"
+	if column != "color" {
+		col = "COALESCE(" + col + ", 0)"
+	}
+	return col
+}
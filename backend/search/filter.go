@@ -0,0 +1,480 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+)
+
+// ListFilter narrows and orders a track list: the library, a crate,
+// unsorted tracks or search results. The zero value lists everything in the
+// endpoint's default order.
+type ListFilter struct {
+	EnergyMin       *int
+	EnergyMax       *int
+	DanceabilityMin *float64
+	DanceabilityMax *float64
+	BPMMin          *float64
+	BPMMax          *float64
+	Mood            string
+	Keys            []string // Camelot
+	Quality         []string // quality audit verdicts
+	Genres          []string // matched ignoring case
+	Artist          string   // the whole artist, ignoring case
+	Album           string   // the whole album, ignoring case
+	Year            *int
+	ContentTypes    []string // from format=
+	Statuses        []string // analysis statuses
+	Tags            []string // any of the owner's tags, ignoring case
+	RatingMin       *int
+	RatingMax       *int
+	Favourite       *bool
+	Colors          []string // colour labels
+	AddedAfter      *time.Time
+	AddedBefore     *time.Time
+	Sort            string // one of sortColumns' keys, "" for the default
+	Desc            bool
+
+	// UserID is whose ratings, favourites and colour labels the filter
+	// and the rating sort use: the user asking for the list.
+	UserID string
+
+	// Cursor is where the page starts, from a previous page's next_cursor;
+	// "" starts at offset instead. See PageKeys.
+	Cursor string
+}
+
+// sortColumn is a sort= value's column and the direction it sorts in when
+// order= is not given.
+type sortColumn struct {
+	column string
+	desc   bool
+	// text columns sort ignoring case.
+	text bool
+	// mark columns are the listing user's, from track_ratings.
+	mark bool
+}
+
+// sortColumns maps the sort= values to columns. Descriptors and dates sort
+// highest or newest first, like the default listing; BPM, key and text
+// sort up from the lowest. Tracks missing the value always sort last.
+var sortColumns = map[string]sortColumn{
+	"energy":             {column: "energy", desc: true},
+	"danceability":       {column: "danceability", desc: true},
+	"loudness":           {column: "loudness_lufs", desc: true},
+	"dynamic_complexity": {column: "dynamic_complexity", desc: true},
+	"created_at":         {column: "created_at", desc: true},
+	"year":               {column: "year", desc: true},
+	"bpm":                {column: "bpm"},
+	"key":                {column: "musical_key"},
+	"duration":           {column: "duration_seconds"},
+	"title":              {column: "title", text: true},
+	"artist":             {column: "artist", text: true},
+	"album":              {column: "album", text: true},
+	"genre":              {column: "genre", text: true},
+	"rating":             {column: "rating", desc: true, mark: true},
+}
+
+// SortNames lists the sort= values, for error messages and docs.
+var SortNames = []string{
+	"created_at", "bpm", "key", "energy", "danceability", "loudness", "dynamic_complexity",
+	"title", "artist", "album", "genre", "year", "duration", "rating",
+}
+
+// IsZero reports whether the filter leaves a list unchanged.
+func (f *ListFilter) IsZero() bool {
+	return f == nil || (f.EnergyMin == nil && f.EnergyMax == nil &&
+		f.DanceabilityMin == nil && f.DanceabilityMax == nil &&
+		f.BPMMin == nil && f.BPMMax == nil && f.Mood == "" &&
+		len(f.Keys) == 0 && len(f.Quality) == 0 && len(f.Genres) == 0 &&
+		f.Artist == "" && f.Album == "" && f.Year == nil &&
+		len(f.ContentTypes) == 0 && len(f.Statuses) == 0 && len(f.Tags) == 0 &&
+		f.RatingMin == nil && f.RatingMax == nil && f.Favourite == nil && len(f.Colors) == 0 &&
+		f.AddedAfter == nil && f.AddedBefore == nil && f.Sort == "" && !f.Desc && f.Cursor == "")
+}
+
+// ParseListFilter reads energy_min, energy_max, danceability_min,
+// danceability_max, bpm_min, bpm_max, mood, key, quality, genre, artist,
+// album, year, format, status, tag, rating_min, rating_max, favourite,
+// color, added_after, added_before, sort, order and cursor from a query
+// string. The key, quality, genre, format, status, tag and color
+// parameters take comma-separated lists, with keys in any notation
+// analysis.ParseKey understands. Dates are YYYY-MM-DD or RFC 3339, and
+// added_before excludes its day. The caller sets UserID.
+func ParseListFilter(q url.Values) (*ListFilter, error) {
+	f := &ListFilter{}
+
+	parseInt := func(name string, dst **int) error {
+		s := q.Get(name)
+		if s == "" {
+			return nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > 10 {
+			return fmt.Errorf("%s must be a whole number from 1 to 10", name)
+		}
+		*dst = &v
+		return nil
+	}
+	parseUnit := func(name string, dst **float64) error {
+		s := q.Get(name)
+		if s == "" {
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > 1 {
+			return fmt.Errorf("%s must be a number from 0 to 1", name)
+		}
+		*dst = &v
+		return nil
+	}
+	if err := parseInt("energy_min", &f.EnergyMin); err != nil {
+		return nil, err
+	}
+	if err := parseInt("energy_max", &f.EnergyMax); err != nil {
+		return nil, err
+	}
+	if err := parseUnit("danceability_min", &f.DanceabilityMin); err != nil {
+		return nil, err
+	}
+	if err := parseUnit("danceability_max", &f.DanceabilityMax); err != nil {
+		return nil, err
+	}
+
+	if mood := strings.ToLower(strings.TrimSpace(q.Get("mood"))); mood != "" {
+		if !slices.Contains(analysis.Moods, mood) {
+			return nil, fmt.Errorf("mood must be one of %s", strings.Join(analysis.Moods, ", "))
+		}
+		f.Mood = mood
+	}
+
+	if keys := q.Get("key"); keys != "" {
+		for _, k := range strings.Split(keys, ",") {
+			camelot, ok := analysis.ParseKey(k)
+			if !ok {
+				return nil, fmt.Errorf("key %q is not a key like 8A, 1m or Am", strings.TrimSpace(k))
+			}
+			if !slices.Contains(f.Keys, camelot) {
+				f.Keys = append(f.Keys, camelot)
+			}
+		}
+	}
+
+	if verdicts := q.Get("quality"); verdicts != "" {
+		for _, v := range strings.Split(verdicts, ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if !slices.Contains(analysis.QualityVerdicts, v) {
+				return nil, fmt.Errorf("quality must be one of %s", strings.Join(analysis.QualityVerdicts, ", "))
+			}
+			if !slices.Contains(f.Quality, v) {
+				f.Quality = append(f.Quality, v)
+			}
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **float64
+	}{{"bpm_min", &f.BPMMin}, {"bpm_max", &f.BPMMax}} {
+		s := q.Get(bound.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 || v > 400 {
+			return nil, fmt.Errorf("%s must be a BPM from 1 to 400", bound.name)
+		}
+		*bound.dst = &v
+	}
+
+	for _, g := range splitList(q.Get("genre")) {
+		if !slices.ContainsFunc(f.Genres, func(have string) bool { return strings.EqualFold(have, g) }) {
+			f.Genres = append(f.Genres, g)
+		}
+	}
+	// Artist and album names can contain commas, so they take one value.
+	f.Artist = strings.TrimSpace(q.Get("artist"))
+	f.Album = strings.TrimSpace(q.Get("album"))
+	if s := q.Get("year"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 || v > 9999 {
+			return nil, errors.New("year must be a year like 1999")
+		}
+		f.Year = &v
+	}
+
+	for _, format := range splitList(strings.ToLower(q.Get("format"))) {
+		types := formatTypes(format)
+		if types == nil {
+			return nil, fmt.Errorf("format must be one of %s", strings.Join(formatNames(), ", "))
+		}
+		for _, ct := range types {
+			if !slices.Contains(f.ContentTypes, ct) {
+				f.ContentTypes = append(f.ContentTypes, ct)
+			}
+		}
+	}
+	for _, st := range splitList(strings.ToLower(q.Get("status"))) {
+		if !slices.Contains(AnalysisStatuses, st) {
+			return nil, fmt.Errorf("status must be one of %s", strings.Join(AnalysisStatuses, ", "))
+		}
+		if !slices.Contains(f.Statuses, st) {
+			f.Statuses = append(f.Statuses, st)
+		}
+	}
+
+	for _, tag := range splitList(q.Get("tag")) {
+		if !slices.ContainsFunc(f.Tags, func(have string) bool { return strings.EqualFold(have, tag) }) {
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **int
+	}{{"rating_min", &f.RatingMin}, {"rating_max", &f.RatingMax}} {
+		s := q.Get(bound.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 || v > imodels.MaxRating {
+			return nil, fmt.Errorf("%s must be a whole number from 0 to %d", bound.name, imodels.MaxRating)
+		}
+		*bound.dst = &v
+	}
+	if s := q.Get("favourite"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.New("favourite must be true or false")
+		}
+		f.Favourite = &v
+	}
+	for _, color := range splitList(strings.ToLower(q.Get("color"))) {
+		if !slices.Contains(imodels.RatingColors, color) {
+			return nil, fmt.Errorf("color must be one of %s", strings.Join(imodels.RatingColors, ", "))
+		}
+		if !slices.Contains(f.Colors, color) {
+			f.Colors = append(f.Colors, color)
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"added_after", &f.AddedAfter}, {"added_before", &f.AddedBefore}} {
+		s := q.Get(bound.name)
+		if s == "" {
+			continue
+		}
+		t, ok := parseTime(s)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a date like 2024-01-31 or an RFC 3339 time", bound.name)
+		}
+		*bound.dst = &t
+	}
+
+	// order=asc on its own lists oldest first.
+	sort := q.Get("sort")
+	if sort != "" {
+		if _, ok := sortColumns[sort]; !ok {
+			return nil, fmt.Errorf("sort must be one of %s", strings.Join(SortNames, ", "))
+		}
+	}
+	switch q.Get("order") {
+	case "":
+		f.Sort, f.Desc = sort, sortColumns[sort].desc
+	case "desc":
+		f.Sort, f.Desc = sort, sort != ""
+	case "asc":
+		f.Sort = sort
+		if f.Sort == "" {
+			f.Sort = "created_at"
+		}
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	// The cursor is checked against the list's order when it is used.
+	f.Cursor = strings.TrimSpace(q.Get("cursor"))
+	return f, nil
+}
+
+// parseTime reads a date (YYYY-MM-DD, midnight UTC) or an RFC 3339 time.
+func parseTime(s string) (time.Time, bool) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return t.UTC(), true
+}
+
+// splitList splits a comma-separated parameter, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Where returns the filter's conditions, each prefixed with " AND ", for
+// columns qualified by prefix (e.g. "t.").
+func (f *ListFilter) Where(prefix string) (string, []any) {
+	if f == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	var args []any
+	add := func(cond string, arg any) {
+		b.WriteString(" AND " + prefix + cond)
+		args = append(args, arg)
+	}
+	if f.EnergyMin != nil {
+		add("energy >= ?", *f.EnergyMin)
+	}
+	if f.EnergyMax != nil {
+		add("energy <= ?", *f.EnergyMax)
+	}
+	if f.DanceabilityMin != nil {
+		add("danceability >= ?", *f.DanceabilityMin)
+	}
+	if f.DanceabilityMax != nil {
+		add("danceability <= ?", *f.DanceabilityMax)
+	}
+	if f.Mood != "" {
+		add("mood = ?", f.Mood)
+	}
+	if len(f.Keys) > 0 {
+		b.WriteString(" AND " + prefix + "musical_key IN (?" + strings.Repeat(", ?", len(f.Keys)-1) + ")")
+		for _, k := range f.Keys {
+			args = append(args, k)
+		}
+	}
+	if f.BPMMin != nil {
+		add("bpm >= ?", *f.BPMMin)
+	}
+	if f.BPMMax != nil {
+		add("bpm <= ?", *f.BPMMax)
+	}
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		b.WriteString(" AND " + prefix + column + " IN (?" + strings.Repeat(", ?", len(values)-1) + ")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	in("quality_verdict", f.Quality)
+	in("genre COLLATE NOCASE", f.Genres)
+	if f.Artist != "" {
+		add("artist = ? COLLATE NOCASE", f.Artist)
+	}
+	if f.Album != "" {
+		add("album = ? COLLATE NOCASE", f.Album)
+	}
+	if f.Year != nil {
+		add("year = ?", *f.Year)
+	}
+	in("content_type", f.ContentTypes)
+	in("analysis_status", f.Statuses)
+	if len(f.Tags) > 0 {
+		b.WriteString(" AND " + tagged(prefix, f.Tags))
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+	}
+	mark := func(column, cond string, arg any) {
+		b.WriteString(" AND " + markColumn(prefix, column) + cond)
+		args = append(args, f.UserID, arg)
+	}
+	if f.RatingMin != nil {
+		mark("rating", " >= ?", *f.RatingMin)
+	}
+	if f.RatingMax != nil {
+		mark("rating", " <= ?", *f.RatingMax)
+	}
+	if f.Favourite != nil {
+		mark("favourite", " = ?", *f.Favourite)
+	}
+	if len(f.Colors) > 0 {
+		b.WriteString(" AND " + markColumn(prefix, "color") + " IN (?" + strings.Repeat(", ?", len(f.Colors)-1) + ")")
+		args = append(args, f.UserID)
+		for _, color := range f.Colors {
+			args = append(args, color)
+		}
+	}
+	if f.AddedAfter != nil {
+		add("created_at >= ?", *f.AddedAfter)
+	}
+	if f.AddedBefore != nil {
+		add("created_at < ?", *f.AddedBefore)
+	}
+	return b.String(), args
+}
+
+// OrderBy returns the ORDER BY expression for the filter's sort, or
+// fallback when it has none, and its arguments. Ties break newest first.
+func (f *ListFilter) OrderBy(prefix, fallback string) (string, []any) {
+	if f == nil || f.Sort == "" {
+		return fallback, nil
+	}
+	keys := f.sortKeys(prefix)
+	return pagination.OrderBy(keys), pagination.Args(keys)
+}
+
+// sortKeys returns the filter's sort as keys, for columns qualified by
+// prefix. Tracks missing the value sort last and ties break newest first.
+func (f *ListFilter) sortKeys(prefix string) []pagination.Key {
+	sc := sortColumns[f.Sort]
+	col := prefix + sc.column
+	var args []any
+	if sc.mark {
+		col, args = markColumn(prefix, sc.column), []any{f.UserID}
+	}
+	keys := []pagination.Key{{Expr: col + " IS NULL", Args: args}}
+	switch {
+	case f.Sort == "key":
+		// Camelot keys sort around the wheel, 2A before 10A.
+		keys = append(keys, pagination.Key{Expr: "CAST(" + col + " AS INTEGER)", Desc: f.Desc}, pagination.Key{Expr: col, Desc: f.Desc})
+	case sc.text:
+		keys = append(keys, pagination.Key{Expr: col + " COLLATE NOCASE", Desc: f.Desc})
+	default:
+		keys = append(keys, pagination.Key{Expr: col, Desc: f.Desc, Args: args})
+	}
+	return append(keys, pagination.Key{Expr: prefix + "created_at", Desc: true})
+}
+
+// PageKeys returns a list's whole order for cursor pagination: the filter's
+// sort, or fallback when it has none, then prefix+"id" so that no two
+// tracks tie. Lists order by pagination.OrderBy(keys) and start after
+// pagination.After(f.Cursor, keys).
+func (f *ListFilter) PageKeys(prefix string, fallback ...pagination.Key) []pagination.Key {
+	keys := fallback
+	if f != nil && f.Sort != "" {
+		keys = f.sortKeys(prefix)
+	}
+	return append(slices.Clip(keys), pagination.Key{Expr: prefix + "id"})
+}
+
+// After returns the condition starting a list ordered by keys after
+// f.Cursor (see pagination.After).
+func (f *ListFilter) After(keys []pagination.Key) (string, []any, error) {
+	if f == nil {
+		return "", nil, nil
+	}
+	return pagination.After(f.Cursor, keys)
+}
@@ -0,0 +1,234 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseListFilter_Valid(t *testing.T) {
+	q, _ := url.ParseQuery("energy_min=6&energy_max=9&danceability_min=0.5&mood=Party&sort=energy&order=asc")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if *f.EnergyMin != 6 || *f.EnergyMax != 9 || *f.DanceabilityMin != 0.5 || f.DanceabilityMax != nil {
+		t.Errorf("ranges = %+v", f)
+	}
+	if f.Mood != "party" || f.Sort != "energy" || f.Desc {
+		t.Errorf("mood/sort = %q %q desc=%v", f.Mood, f.Sort, f.Desc)
+	}
+
+	cond, args := f.Where("t.")
+	want := " AND t.energy >= ? AND t.energy <= ? AND t.danceability >= ? AND t.mood = ?"
+	if cond != want || !reflect.DeepEqual(args, []any{6, 9, 0.5, "party"}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+	if got, args := f.OrderBy("t.", "x"); got != "t.energy IS NULL ASC, t.energy ASC, t.created_at DESC" || args != nil {
+		t.Errorf("orderBy = %q %v", got, args)
+	}
+}
+
+func TestParseListFilter_Defaults(t *testing.T) {
+	f, err := ParseListFilter(url.Values{})
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if order, _ := f.OrderBy("", "created_at DESC"); !f.IsZero() || order != "created_at DESC" {
+		t.Errorf("empty query gave %+v", f)
+	}
+
+	q, _ := url.ParseQuery("sort=danceability")
+	if f, _ := ParseListFilter(q); !f.Desc {
+		t.Error("descriptor sort should default to descending")
+	}
+	q, _ = url.ParseQuery("order=asc")
+	if f, _ := ParseListFilter(q); f.Sort != "created_at" || f.Desc {
+		t.Errorf("order=asc alone = %+v, want oldest first", f)
+	}
+}
+
+func TestParseListFilter_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"energy_min=0",
+		"energy_max=11",
+		"energy_min=high",
+		"danceability_max=1.5",
+		"mood=angsty",
+		"order=sideways",
+		"key=8A,H",
+		"quality=great",
+		"bpm_min=0",
+		"bpm_max=fast",
+		"format=ogg",
+		"status=done",
+		"added_after=yesterday",
+		"sort=plays",
+		"year=recent",
+	} {
+		q, _ := url.ParseQuery(raw)
+		if _, err := ParseListFilter(q); err == nil {
+			t.Errorf("ParseListFilter(%q) accepted invalid input", raw)
+		}
+	}
+}
+
+func TestParseListFilter_KeysInAnyNotation(t *testing.T) {
+	q, _ := url.ParseQuery("key=8a, 1m ,Am,C")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if !reflect.DeepEqual(f.Keys, []string{"8A", "8B"}) || f.IsZero() {
+		t.Errorf("keys = %v", f.Keys)
+	}
+	cond, args := f.Where("t.")
+	if cond != " AND t.musical_key IN (?, ?)" || !reflect.DeepEqual(args, []any{"8A", "8B"}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+}
+
+func TestParseListFilter_Quality(t *testing.T) {
+	q, _ := url.ParseQuery("quality=Transcoded,suspicious,transcoded")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if !reflect.DeepEqual(f.Quality, []string{"transcoded", "suspicious"}) || f.IsZero() {
+		t.Errorf("quality = %v", f.Quality)
+	}
+	cond, args := f.Where("")
+	if cond != " AND quality_verdict IN (?, ?)" || !reflect.DeepEqual(args, []any{"transcoded", "suspicious"}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+}
+
+func TestParseListFilter_LibraryFilters(t *testing.T) {
+	q, _ := url.ParseQuery("bpm_min=122&bpm_max=128&genre=House,house,Techno&format=flac,wav&status=analyzed&added_after=2024-01-01&added_before=2024-02-01T00:00:00Z")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if f.IsZero() {
+		t.Error("IsZero with filters set")
+	}
+	cond, args := f.Where("t.")
+	want := " AND t.bpm >= ? AND t.bpm <= ?" +
+		" AND t.genre COLLATE NOCASE IN (?, ?)" +
+		" AND t.content_type IN (?, ?, ?, ?, ?)" +
+		" AND t.analysis_status IN (?)" +
+		" AND t.created_at >= ? AND t.created_at < ?"
+	if cond != want {
+		t.Errorf("where = %q", cond)
+	}
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	wantArgs := []any{122.0, 128.0, "House", "Techno",
+		"audio/flac", "audio/x-flac", "audio/wav", "audio/wave", "audio/x-wav", "analyzed", after, before}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestParseListFilter_ArtistAlbumYear(t *testing.T) {
+	q, _ := url.ParseQuery("artist=+Burial+&album=Untrue&year=2007")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	cond, args := f.Where("t.")
+	want := " AND t.artist = ? COLLATE NOCASE AND t.album = ? COLLATE NOCASE AND t.year = ?"
+	if cond != want || !reflect.DeepEqual(args, []any{"Burial", "Untrue", 2007}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+}
+
+func TestParseFacets(t *testing.T) {
+	for raw, want := range map[string][]string{
+		"":              nil,
+		"bpm":           {"bpm"},
+		"Genre,key,bpm": {"genre", "key", "bpm"},
+		"key,key":       {"key"},
+		"all":           FacetNames,
+	} {
+		got, err := ParseFacets(raw)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseFacets(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	if _, err := ParseFacets("genre,mood"); err == nil {
+		t.Error("ParseFacets accepted an unknown facet")
+	}
+}
+
+func TestParseListFilter_Tags(t *testing.T) {
+	q, _ := url.ParseQuery("tag=Vocal,vocal, warmup")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if !reflect.DeepEqual(f.Tags, []string{"Vocal", "warmup"}) {
+		t.Errorf("Tags = %v", f.Tags)
+	}
+	// Without a prefix the subquery still has to reach the outer row.
+	cond, args := f.Where("")
+	want := " AND EXISTS (SELECT 1 FROM track_tags tt JOIN tags g ON g.id = tt.tag_id" +
+		" WHERE tt.track_id = tracks.id AND g.name COLLATE NOCASE IN (?, ?))"
+	if cond != want || !reflect.DeepEqual(args, []any{"Vocal", "warmup"}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+}
+
+func TestParseListFilter_Ratings(t *testing.T) {
+	q, _ := url.ParseQuery("rating_min=3&favourite=true&color=Red,red,blue&sort=rating")
+	f, err := ParseListFilter(q)
+	if err != nil {
+		t.Fatalf("ParseListFilter: %v", err)
+	}
+	if *f.RatingMin != 3 || !*f.Favourite || !reflect.DeepEqual(f.Colors, []string{"red", "blue"}) || !f.Desc {
+		t.Errorf("filter = %+v", f)
+	}
+	f.UserID = "o'brien"
+	cond, args := f.Where("t.")
+	col := "(SELECT r.%s FROM track_ratings r WHERE r.track_id = t.id AND r.user_id = ?)"
+	want := " AND COALESCE(" + fmt.Sprintf(col, "rating") + ", 0) >= ?" +
+		" AND COALESCE(" + fmt.Sprintf(col, "favourite") + ", 0) = ?" +
+		" AND " + fmt.Sprintf(col, "color") + " IN (?, ?)"
+	if cond != want || !reflect.DeepEqual(args, []any{"o'brien", 3, "o'brien", true, "o'brien", "red", "blue"}) {
+		t.Errorf("where = %q %v", cond, args)
+	}
+	rating := "COALESCE(" + fmt.Sprintf(col, "rating") + ", 0)"
+	order, args := f.OrderBy("t.", "x")
+	if order != rating+" IS NULL ASC, "+rating+" DESC, t.created_at DESC" || !reflect.DeepEqual(args, []any{"o'brien", "o'brien"}) {
+		t.Errorf("orderBy = %q %v", order, args)
+	}
+
+	for _, bad := range []string{"rating_min=6", "rating_max=-1", "favourite=maybe", "color=beige"} {
+		q, _ := url.ParseQuery(bad)
+		if _, err := ParseListFilter(q); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestListFilter_OrderBy(t *testing.T) {
+	cases := map[string]string{
+		"sort=bpm":                "t.bpm IS NULL ASC, t.bpm ASC, t.created_at DESC",
+		"sort=year":               "t.year IS NULL ASC, t.year DESC, t.created_at DESC",
+		"sort=key&order=desc":     "t.musical_key IS NULL ASC, CAST(t.musical_key AS INTEGER) DESC, t.musical_key DESC, t.created_at DESC",
+		"sort=artist":             "t.artist IS NULL ASC, t.artist COLLATE NOCASE ASC, t.created_at DESC",
+		"sort=duration&order=asc": "t.duration_seconds IS NULL ASC, t.duration_seconds ASC, t.created_at DESC",
+	}
+	for raw, want := range cases {
+		q, _ := url.ParseQuery(raw)
+		f, err := ParseListFilter(q)
+		if err != nil {
+			t.Fatalf("ParseListFilter(%q): %v", raw, err)
+		}
+		if got, _ := f.OrderBy("t.", "x"); got != want {
+			t.Errorf("%s: OrderBy = %q, want %q", raw, got, want)
+		}
+	}
+}
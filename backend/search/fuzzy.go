@@ -0,0 +1,239 @@
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MinSimilarity is how close, by Similarity, a word must be to one in a
+// track for a fuzzy match: close enough for a typo or two in a word, like
+// "deadmou5" for "deadmau5" (0.5).
+const MinSimilarity = 0.35
+
+// Fold lower-cases s and strips its accents, as the search indexes do, so
+// "Beyoncé" and "beyonce" compare equal.
+func Fold(s string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// Words splits s into folded words of letters and digits.
+func Words(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool { return !isWordRune(r) })
+}
+
+// trigrams returns the distinct trigrams of a folded word padded with two
+// spaces in front and one behind, so that short words and word starts
+// count for something.
+func trigrams(word string) []string {
+	r := []rune("  " + word + " ")
+	var out []string
+	for i := 0; i+3 <= len(r); i++ {
+		if g := string(r[i : i+3]); !slices.Contains(out, g) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// Similarity is the share of trigrams two words have in common, from 0
+// for nothing alike to 1 for the same word once folded.
+func Similarity(a, b string) float64 {
+	ga, gb := trigrams(Fold(a)), trigrams(Fold(b))
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, g := range ga {
+		if slices.Contains(gb, g) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+// wordScore is how well word matches texts: 1 if it starts a word in one
+// of them, otherwise its best Similarity to any of their words.
+func wordScore(word string, texts []string) float64 {
+	best := 0.0
+	for _, text := range texts {
+		for _, w := range Words(text) {
+			if strings.HasPrefix(w, word) {
+				return 1
+			}
+			best = max(best, Similarity(word, w))
+		}
+	}
+	return best
+}
+
+// Matches reports whether every word starts a word of one of texts,
+// ignoring case and accents.
+func Matches(words []string, texts ...string) bool {
+	for _, word := range words {
+		if wordScore(Fold(word), texts) < 1 {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// FuzzyScore scores how closely texts match words with typos allowed: the
+// mean over words of their best Similarity to a word of texts, or 1 for a
+// word that starts one. ok is false if any word is further than
+// MinSimilarity from all of them.
+func FuzzyScore(words []string, texts ...string) (score float64, ok bool) {
+	if len(words) == 0 {
+		return 0, false
+	}
+	for _, word := range words {
+		s := wordScore(Fold(word), texts)
+		if s < MinSimilarity {
+			return 0, false
+		}
+		score += s
+	}
+	return score / float64(len(words)), true
+}
+
+// TrigramMatch renders words as an FTS5 query for the tracks_trigram
+// index, in one column or all of them if column is "". Each word must
+// share a trigram with the track, so the query finds candidates for
+// FuzzyScore rather than matches. A word with accents looks for its
+// trigrams both with and without them, as an index built without
+// remove_diacritics (SQLite before 3.45) keeps accents. Words under three
+// letters have no trigrams to look for and are left out; if none are left
+// it returns "".
+func TrigramMatch(column string, words []string) string {
+	var parts []string
+	for _, word := range words {
+		var grams []string
+		for _, form := range []string{Fold(word), strings.ToLower(word)} {
+			r := []rune(form)
+			for i := 0; i+3 <= len(r); i++ {
+				if g := `"` + strings.ReplaceAll(string(r[i:i+3]), `"`, `""`) + `"`; !slices.Contains(grams, g) {
+					grams = append(grams, g)
+				}
+			}
+		}
+		if len(grams) > 0 {
+			parts = append(parts, "("+strings.Join(grams, " OR ")+")")
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	match := strings.Join(parts, " AND ")
+	if column != "" {
+		match = column + " : (" + match + ")"
+	}
+	return match
+}
+
+// Highlight HTML-escapes text and wraps the parts matching words in
+// <mark></mark>: every place a word appears, ignoring case and accents,
+// or failing that, the words of text it fuzzily matches.
+func Highlight(text string, words []string) string {
+	src := []rune(text)
+	// folded holds text folded rune by rune; at maps each folded rune
+	// back to the rune of text it came from.
+	var folded []rune
+	var at []int
+	for i, r := range src {
+		for _, f := range Fold(string(r)) {
+			folded = append(folded, f)
+			at = append(at, i)
+		}
+	}
+
+	marked := make([]bool, len(src))
+	for _, word := range words {
+		w := []rune(Fold(word))
+		if len(w) == 0 {
+			continue
+		}
+		found := false
+		for i := 0; i+len(w) <= len(folded); i++ {
+			if slices.Equal(folded[i:i+len(w)], w) {
+				for j := i; j < i+len(w); j++ {
+					marked[at[j]] = true
+				}
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		// No exact match: mark the words of text close to this one.
+		for start := 0; start < len(src); {
+			if !isWordRune(src[start]) {
+				start++
+				continue
+			}
+			end := start
+			for end < len(src) && isWordRune(src[end]) {
+				end++
+			}
+			if Similarity(string(w), string(src[start:end])) >= MinSimilarity {
+				for j := start; j < end; j++ {
+					marked[j] = true
+				}
+			}
+			start = end
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(src); {
+		j := i
+		for j < len(src) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			b.WriteString("<mark>" + html.EscapeString(string(src[i:j])) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(string(src[i:j])))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// Fuzzy splits the query for typo-tolerant matching. It returns the words
+// of the query's top-level free text, unquoted and in no field, and the
+// rest of the query, or nil if there is no rest. Queries without such text
+// return no words.
+func (q *Query) Fuzzy() ([]string, *Query) {
+	top := []node{q.root}
+	if and, ok := q.root.(andNode); ok {
+		top = and
+	}
+	var words []string
+	var rest andNode
+	for _, n := range top {
+		if t, ok := n.(textNode); ok && t.column == "" && !t.phrase {
+			words = append(words, Words(t.text)...)
+			continue
+		}
+		rest = append(rest, n)
+	}
+	switch len(rest) {
+	case 0:
+		return words, nil
+	case 1:
+		return words, &Query{root: rest[0]}
+	default:
+		return words, &Query{root: rest}
+	}
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"Beyoncé":     "beyonce",
+		"Sigur Rós":   "sigur ros",
+		"MØ":          "mø", // a letter of its own, not an accented O
+		"Röyksopp":    "royksopp",
+		"deadmau5":    "deadmau5",
+		"Ünïcödé ÀÉÎ": "unicode aei",
+	} {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := Words("Daft Punk - One More Time (Club Mix)"); !reflect.DeepEqual(got, []string{"daft", "punk", "one", "more", "time", "club", "mix"}) {
+		t.Errorf("Words = %q", got)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("deadmou5", "deadmau5"); s < MinSimilarity {
+		t.Errorf("one-letter typo similarity = %.2f, want >= %.2f", s, MinSimilarity)
+	}
+	if s := Similarity("Beyonce", "beyoncé"); s != 1 {
+		t.Errorf("folded similarity = %.2f, want 1", s)
+	}
+	if s := Similarity("deadmau5", "skrillex"); s >= MinSimilarity {
+		t.Errorf("unrelated similarity = %.2f", s)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := FuzzyScore([]string{"deadmou5", "strob"}, "Strobe", "deadmau5"); !ok {
+		t.Error("typo and prefix should match")
+	}
+	if _, ok := FuzzyScore([]string{"deadmou5", "halo"}, "Strobe", "deadmau5"); ok {
+		t.Error("every word must match")
+	}
+	exact, _ := FuzzyScore([]string{"strobe"}, "Strobe")
+	typo, _ := FuzzyScore([]string{"strobr"}, "Strobe")
+	if exact != 1 || typo >= exact {
+		t.Errorf("scores exact %.2f typo %.2f", exact, typo)
+	}
+	if !Matches([]string{"sea", "warm"}, "Warm-up Séance") || Matches([]string{"ance"}, "Warm-up Séance") {
+		t.Error("Matches should take word prefixes only")
+	}
+}
+
+func TestTrigramMatch(t *testing.T) {
+	got := TrigramMatch("artist", []string{"Dead", "dj", `a"bc`})
+	want := `artist : (("dea" OR "ead") AND ("a""b" OR """bc"))`
+	if got != want {
+		t.Errorf("TrigramMatch = %s, want %s", got, want)
+	}
+	if got, want := TrigramMatch("", []string{"Été"}), `("ete" OR "été")`; got != want {
+		t.Errorf("TrigramMatch = %s, want %s", got, want)
+	}
+	if got := TrigramMatch("", []string{"dj"}); got != "" {
+		t.Errorf("short words gave %q", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		words []string
+		want  string
+	}{
+		{"Beyoncé", []string{"once"}, "Bey<mark>oncé</mark>"},
+		{"Strobe (Club Edit)", []string{"club", "str"}, "<mark>Str</mark>obe (<mark>Club</mark> Edit)"},
+		{"deadmau5 & Kaskade", []string{"deadmou5"}, "<mark>deadmau5</mark> &amp; Kaskade"},
+		{"<b>Halo</b>", []string{"zzz"}, "&lt;b&gt;Halo&lt;/b&gt;"},
+	} {
+		if got := Highlight(tc.text, tc.words); got != tc.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tc.text, tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestQuery_Fuzzy(t *testing.T) {
+	q, err := Parse(`deadmou5 bpm:128 "one more" artist:daft Strób`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	words, rest := q.Fuzzy()
+	if !reflect.DeepEqual(words, []string{"deadmou5", "strob"}) {
+		t.Errorf("words = %q", words)
+	}
+	if rest == nil {
+		t.Fatal("rest is nil")
+	}
+	if got, _ := rest.Fuzzy(); len(got) != 0 {
+		t.Errorf("rest still has free text %q", got)
+	}
+
+	q, _ = Parse("deadmou5")
+	if words, rest := q.Fuzzy(); len(words) != 1 || rest != nil {
+		t.Errorf("plain word = %q, %v", words, rest)
+	}
+}
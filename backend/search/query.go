@@ -0,0 +1,353 @@
+// Package search parses the track search query language used by
+// /api/tracks?q= and compiles it to SQL.
+//
+// A query is a list of terms, all of which must match:
+//
+//	daft punk                   free text, prefix-matched against every text field
+//	"one more time"             an exact phrase
+//	artist:daft genre:house     text in one field (title, artist, album, genre, filename)
+//	bpm:122-128 year:>2019      numbers: exact, a range, or >, >=, <, <=
+//	duration:3:00-6:00          durations in seconds or m:ss
+//	key:8A~ key:Am,Em           keys in any notation; ~ adds the wheel neighbours
+//	status:failed format:flac   analysis status, file format
+//	mood:happy quality:transcoded
+//	crate:"Warm up" crate:none  crate membership by name or id; none for unsorted
+//	tag:vocal tag:"B2B with Sam" your tags; none for untagged
+//	rating:>=4 favourite:yes    your star rating (0 for unrated) and favourites
+//	color:red,pink              your colour labels
+//
+// Terms combine with AND (implied), OR and parentheses, and are negated
+// with NOT or a leading minus: house -remix, (key:8A OR key:9A) NOT status:failed.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error is a query the parser could not read. Pos is the 1-based
+// character position of the problem.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (at character %d)", e.Msg, e.Pos)
+}
+
+// Query is a parsed search query, ready to Compile.
+type Query struct {
+	root node
+}
+
+// Parse reads a query. Errors are *Error.
+func Parse(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: s, toks: toks}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorf(p.peek(), "query is empty")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		// parseAnd stops only at ")", OR or the end.
+		return nil, p.errorf(t, `unexpected ")"`)
+	}
+	return &Query{root: root}, nil
+}
+
+// Text reads s as plain words, all of which must match, ignoring fields,
+// operators and quotes. It is for a query still being typed that does not
+// parse yet, and returns nil if s has no words.
+func Text(s string) *Query {
+	var words andNode
+	for _, w := range Words(s) {
+		words = append(words, textNode{text: w})
+	}
+	switch len(words) {
+	case 0:
+		return nil
+	case 1:
+		return &Query{root: words[0]}
+	default:
+		return &Query{root: words}
+	}
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokTerm
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind  tokKind
+	pos   int // byte offset
+	field string
+	value string
+	// quoted is set for "phrases" and field:"quoted values".
+	quoted bool
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			toks = append(toks, token{kind: tokLParen, pos: i})
+			i++
+		case r == ')':
+			toks = append(toks, token{kind: tokRParen, pos: i})
+			i++
+		case r == '-' && i+1 < len(s) && !isSpace(s[i+1:]):
+			toks = append(toks, token{kind: tokNot, pos: i})
+			i++
+		case r == '"':
+			text, end, err := readQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokTerm, pos: i, value: text, quoted: true})
+			i = end
+		default:
+			t, end, err := readWord(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, t)
+			i = end
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+// readQuoted reads the quoted string starting at s[start], returning its
+// text and the offset just past the closing quote.
+func readQuoted(s string, start int) (string, int, error) {
+	end := strings.IndexByte(s[start+1:], '"')
+	if end < 0 {
+		return "", 0, &Error{Pos: charPos(s, start), Msg: "unclosed quote"}
+	}
+	return s[start+1 : start+1+end], start + end + 2, nil
+}
+
+// readWord reads a bare word or field:value term starting at s[start]. A
+// field's value may be quoted to include spaces. A word whose prefix is not
+// a field, or whose value is empty, is free text as written, so
+// "Mission: Impossible" and 12:34 need no quotes.
+func readWord(s string, start int) (token, int, error) {
+	end := start
+	for end < len(s) && !isSpace(s[end:]) && s[end] != '(' && s[end] != ')' && s[end] != ':' {
+		end++
+	}
+	word := s[start:end]
+	if end == len(s) || s[end] != ':' {
+		switch word {
+		case "AND":
+			return token{kind: tokAnd, pos: start}, end, nil
+		case "OR":
+			return token{kind: tokOr, pos: start}, end, nil
+		case "NOT":
+			return token{kind: tokNot, pos: start}, end, nil
+		}
+		return token{kind: tokTerm, pos: start, value: word}, end, nil
+	}
+
+	t := token{kind: tokTerm, pos: start, field: strings.ToLower(word)}
+	end++ // the colon
+	if end < len(s) && s[end] == '"' {
+		text, next, err := readQuoted(s, end)
+		if err != nil {
+			return token{}, 0, err
+		}
+		t.value, t.quoted, end = text, true, next
+	} else {
+		valueStart := end
+		for end < len(s) && !isSpace(s[end:]) && s[end] != '(' && s[end] != ')' {
+			end++
+		}
+		t.value = s[valueStart:end]
+	}
+	if t.value == "" || !isField(t.field) {
+		return token{kind: tokTerm, pos: start, value: s[start:end]}, end, nil
+	}
+	return t, end, nil
+}
+
+// charPos converts a byte offset in s to a 1-based character position.
+func charPos(s string, offset int) int {
+	return utf8.RuneCountInString(s[:offset]) + 1
+}
+
+// parser is a recursive-descent parser over the tokens:
+//
+//	or    = and { "OR" and }
+//	and   = unary { ["AND"] unary }
+//	unary = ("NOT" | "-") unary | "(" or ")" | term
+type parser struct {
+	src  string
+	toks []token
+	i    int
+	open []token // the "(" of each group being parsed, innermost last
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{Pos: charPos(p.src, t.pos), Msg: fmt.Sprintf(format, args...)}
+}
+
+// missingf reports a term missing at t, unless the query ended inside a
+// group, where the closing parenthesis is what is missing.
+func (p *parser) missingf(t token, format string, args ...any) error {
+	if p.peek().kind == tokEOF && len(p.open) > 0 {
+		return p.errorf(p.open[len(p.open)-1], "missing closing parenthesis")
+	}
+	return p.errorf(t, format, args...)
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{first}
+	for p.peek().kind == tokOr {
+		op := p.next()
+		if k := p.peek().kind; k == tokEOF || k == tokRParen || k == tokOr {
+			return nil, p.missingf(op, "OR needs a term on each side")
+		}
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return orNode(nodes), nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var nodes []node
+	for {
+		t := p.peek()
+		switch t.kind {
+		case tokEOF, tokRParen, tokOr:
+			if len(nodes) == 0 {
+				if t.kind == tokRParen {
+					return nil, p.errorf(t, `unexpected ")"`)
+				}
+				return nil, p.missingf(t, "OR needs a term on each side")
+			}
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+			return andNode(nodes), nil
+		case tokAnd:
+			p.next()
+			if len(nodes) == 0 {
+				return nil, p.errorf(t, "AND needs a term on each side")
+			}
+			if k := p.peek().kind; k == tokEOF || k == tokRParen || k == tokOr || k == tokAnd {
+				return nil, p.missingf(t, "AND needs a term on each side")
+			}
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNot:
+		if k := p.peek().kind; k == tokEOF || k == tokRParen || k == tokOr || k == tokAnd {
+			return nil, p.missingf(t, "NOT needs a term after it")
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, p.errorf(t, "empty parentheses")
+		}
+		p.open = append(p.open, t)
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.open = p.open[:len(p.open)-1]
+		if p.next().kind != tokRParen {
+			return nil, p.errorf(t, "missing closing parenthesis")
+		}
+		return n, nil
+	case tokTerm:
+		return p.term(t)
+	default:
+		// parseAnd and the operator checks leave only terms, NOT and "(" here.
+		return nil, p.errorf(t, "expected a search term")
+	}
+}
+
+// term builds the node for a single term.
+func (p *parser) term(t token) (node, error) {
+	if t.field == "" {
+		return textTerm("", t.value, t.quoted), nil
+	}
+	if col, ok := textFields[t.field]; ok {
+		return textTerm(col, t.value, t.quoted), nil
+	}
+	// readWord leaves only known fields.
+	n, err := fields[t.field](t.value)
+	if err != nil {
+		return nil, p.errorf(t, "%s", err)
+	}
+	return n, nil
+}
+
+// textTerm is a text search; terms with no letters or digits (a lone "&")
+// match everything rather than tripping up FTS5.
+func textTerm(column, text string, phrase bool) node {
+	if !strings.ContainsFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+		return allNode{}
+	}
+	return textNode{column: column, text: text, phrase: phrase}
+}
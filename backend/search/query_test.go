@@ -0,0 +1,229 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func compile(t *testing.T, q string) (match string, where string, args []any) {
+	t.Helper()
+	parsed, err := Parse(q)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", q, err)
+	}
+	c := parsed.Compile("u1")
+	where, args = c.Where()
+	return c.match, where, args
+}
+
+func TestCompile_Text(t *testing.T) {
+	cases := []struct {
+		q     string
+		match string
+	}{
+		{"feel the vibration", `"feel"* AND "the"* AND "vibration"*`},
+		{`"one more time"`, `"one more time"`},
+		{`artist:daft genre:"deep house"`, `artist : "daft"* AND genre : "deep house"`},
+		{"filename:mix-01", `original_filename : "mix-01"*`},
+		{"techno OR house", `("techno"* OR "house"*)`},
+		{"on & on", `"on"* AND "on"*`},
+		// A colon outside a field is text.
+		{"Mission: Impossible", `"Mission:"* AND "Impossible"*`},
+		{"12:34 re:set", `"12:34"* AND "re:set"*`},
+		{"bmp:120", `"bmp:120"*`},
+		{"artist: daft", `"artist:"* AND "daft"*`},
+	}
+	for _, tc := range cases {
+		match, where, _ := compile(t, tc.q)
+		if match != tc.match || where != " AND tracks_fts MATCH ?" {
+			t.Errorf("%q: match %q where %q, want %q", tc.q, match, where, tc.match)
+		}
+	}
+}
+
+func TestCompile_Fields(t *testing.T) {
+	cases := []struct {
+		q    string
+		cond string
+		args []any
+	}{
+		{"bpm:122-128", "(t.bpm >= ? AND t.bpm <= ?)", []any{122.0, 128.0}},
+		{"bpm:128", "(t.bpm >= ? AND t.bpm < ?)", []any{127.5, 128.5}},
+		{"year:>2019", "(t.year > ?)", []any{2019.0}},
+		{"energy:<=4", "(t.energy <= ?)", []any{4.0}},
+		{"duration:3:00-6:30", "(t.duration_seconds >= ? AND t.duration_seconds <= ?)", []any{180.0, 390.0}},
+		{"duration:>=1:02:03", "(t.duration_seconds >= ?)", []any{3723.0}},
+		{"key:Am", "(t.musical_key IN (?))", []any{"8A"}},
+		{"key:8A~", "(t.musical_key IN (?, ?, ?, ?))", []any{"7A", "8A", "8B", "9A"}},
+		{"key:1m,8A", "(t.musical_key IN (?))", []any{"8A"}},
+		{"status:failed,pending", "(t.analysis_status IN (?, ?))", []any{"failed", "pending"}},
+		{"format:FLAC", "(t.content_type IN (?, ?))", []any{"audio/flac", "audio/x-flac"}},
+		{"mood:happy", "(t.mood IN (?))", []any{"happy"}},
+		{"quality:transcoded", "(t.quality_verdict IN (?))", []any{"transcoded"}},
+		{"crate:none", "(NOT EXISTS (SELECT 1 FROM playlist_tracks pt WHERE pt.track_id = t.id))", nil},
+	}
+	for _, tc := range cases {
+		match, where, args := compile(t, tc.q)
+		if match != "" || where != " AND "+tc.cond || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("%q: match %q where %q %v, want %q %v", tc.q, match, where, args, tc.cond, tc.args)
+		}
+	}
+}
+
+func TestCompile_Boolean(t *testing.T) {
+	// Top-level text is ranked through the join; the rest stays in SQL.
+	match, where, args := compile(t, `house -remix (key:8A OR bpm:>125) NOT status:failed`)
+	if match != `"house"*` {
+		t.Errorf("match = %q", match)
+	}
+	want := " AND tracks_fts MATCH ?" +
+		" AND NOT COALESCE(t.id IN (SELECT track_id FROM tracks_fts WHERE tracks_fts MATCH ?), 0)" +
+		" AND ((t.musical_key IN (?)) OR (t.bpm > ?))" +
+		" AND NOT COALESCE((t.analysis_status IN (?)), 0)"
+	if where != want {
+		t.Errorf("where =\n%s\nwant\n%s", where, want)
+	}
+	if !reflect.DeepEqual(args, []any{`"house"*`, `"remix"*`, "8A", 125.0, "failed"}) {
+		t.Errorf("args = %v", args)
+	}
+
+	// Text under OR with a field filter can't be one MATCH.
+	match, where, _ = compile(t, "artist:daft OR bpm:128")
+	if match != "" || !strings.Contains(where, "tracks_fts MATCH ?) OR (t.bpm") {
+		t.Errorf("match %q where %q", match, where)
+	}
+
+	// Explicit AND is the same as implied AND.
+	a, _, _ := compile(t, "daft AND punk")
+	b, _, _ := compile(t, "daft punk")
+	if a != b {
+		t.Errorf("AND: %q vs %q", a, b)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		q   string
+		pos int
+		msg string
+	}{
+		{"", 1, "query is empty"},
+		{"house bpm:fast", 7, "bpm needs a value like bpm:128, bpm:122-128 or bpm:>128"},
+		{"bpm:128-122", 1, "bpm range 128-122 runs backwards"},
+		{"key:H", 1, `key "H" is not a key`},
+		{"status:done", 1, "status must be one of pending, analyzing"},
+		{"format:ogg", 1, "format must be one of mp3"},
+		{`say "hi`, 5, "unclosed quote"},
+		{"(house OR techno", 1, "missing closing parenthesis"},
+		{"house (techno OR", 7, "missing closing parenthesis"},
+		{"(house (techno AND", 8, "missing closing parenthesis"},
+		{"(NOT", 1, "missing closing parenthesis"},
+		{"(", 1, "missing closing parenthesis"},
+		{"house)", 6, `unexpected ")"`},
+		{"house OR", 7, "OR needs a term on each side"},
+		{"AND house", 1, "AND needs a term on each side"},
+		{"house NOT", 7, "NOT needs a term after it"},
+		{"()", 1, "empty parentheses"},
+		{"duration:5:75", 1, "duration needs a value"},
+	}
+	for _, tc := range cases {
+		_, err := Parse(tc.q)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) err = %v, want *Error", tc.q, err)
+			continue
+		}
+		if perr.Pos != tc.pos || !strings.Contains(perr.Msg, tc.msg) {
+			t.Errorf("Parse(%q) = %d %q, want %d %q", tc.q, perr.Pos, perr.Msg, tc.pos, tc.msg)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	if q := Text(`" ( -`); q != nil {
+		t.Errorf("Text of no words = %v, want nil", q)
+	}
+	if match := Text(`(artist:"daft OR`).Compile("u1").match; match != `"artist"* AND "daft"* AND "or"*` {
+		t.Errorf("match = %q", match)
+	}
+}
+
+// TestCompile_RunsInSQLite runs the field filters, whose SQL doesn't need
+// FTS5, against a small library.
+func TestCompile_RunsInSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+        CREATE TABLE tracks (
+            id TEXT PRIMARY KEY, owner_user_id TEXT, content_type TEXT, duration_seconds REAL,
+            year INTEGER, bpm REAL, musical_key TEXT, analysis_status TEXT, energy INTEGER,
+            mood TEXT, quality_verdict TEXT, created_at DATETIME
+        );
+        CREATE TABLE playlists (id TEXT PRIMARY KEY, owner_user_id TEXT, name TEXT);
+        CREATE TABLE playlist_tracks (playlist_id TEXT, track_id TEXT);
+        CREATE TABLE tags (id TEXT PRIMARY KEY, user_id TEXT, name TEXT);
+        CREATE TABLE track_tags (track_id TEXT, tag_id TEXT);
+        INSERT INTO tags VALUES ('g1', 'u1', 'B2B with Sam'), ('g2', 'u1', 'vocal');
+        INSERT INTO track_tags VALUES ('a', 'g1'), ('b', 'g2'), ('a', 'g2');
+        CREATE TABLE track_ratings (track_id TEXT, user_id TEXT, rating INTEGER, favourite BOOLEAN, color TEXT);
+        INSERT INTO track_ratings VALUES ('a', 'u1', 4, 1, 'red'), ('b', 'u1', 2, 0, NULL), ('c', 'u2', 5, 1, 'red');
+        INSERT INTO tracks VALUES
+            ('a', 'u1', 'audio/flac', 300, 2021, 124, '8A', 'analyzed', 7, NULL, NULL, '2024-01-01'),
+            ('b', 'u1', 'audio/mpeg', 200, 2010, 174, '9A', 'analyzed', 9, NULL, NULL, '2024-01-02'),
+            ('c', 'u1', 'audio/wav', NULL, NULL, NULL, NULL, 'pending', NULL, NULL, NULL, '2024-01-03');
+        INSERT INTO playlists VALUES ('p1', 'u1', 'Warm Up'), ('p2', 'u2', 'Warm Up');
+        INSERT INTO playlist_tracks VALUES ('p1', 'a'), ('p2', 'b');
+    `)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	cases := map[string][]string{
+		"bpm:120-130":                      {"a"},
+		"-bpm:120-130":                     {"b", "c"},
+		"year:>2019 OR format:mp3":         {"a", "b"},
+		"key:8A~ duration:<=5:00":          {"a", "b"},
+		`crate:"warm up"`:                  {"a"},
+		"crate:none":                       {"c"},
+		"NOT (status:pending OR energy:9)": {"a"},
+		"format:lossless -format:flac":     {"c"},
+		`tag:"b2b with sam"`:               {"a"},
+		"tag:vocal,warmup bpm:>150":        {"b"},
+		"tag:none":                         {"c"},
+		"rating:>=2":                       {"a", "b"},
+		"rating:0":                         {"c"},
+		"favourite:yes":                    {"a"},
+		"-color:red":                       {"b", "c"},
+		"bpm:>100 rating:3-5 color:red":    {"a"},
+	}
+	for q, want := range cases {
+		parsed, err := Parse(q)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", q, err)
+		}
+		c := parsed.Compile("u1")
+		where, args := c.Where()
+		rows, err := db.Query(`SELECT t.id FROM tracks t`+c.Join()+` WHERE t.owner_user_id = 'u1'`+where+
+			` ORDER BY `+c.OrderBy("t.created_at"), args...)
+		if err != nil {
+			t.Fatalf("%q: %v", q, err)
+		}
+		var got []string
+		for rows.Next() {
+			var id string
+			_ = rows.Scan(&id)
+			got = append(got, id)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q = %v, want %v", q, got, want)
+		}
+	}
+}
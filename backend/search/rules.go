@@ -0,0 +1,435 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
+)
+
+// Rules define a smart crate: the tracks in its owner's library that match
+// them, worked out each time the crate is read. They are stored as JSON:
+//
+//	{
+//	  "match": "all",
+//	  "conditions": [
+//	    {"field": "genre", "op": "is", "value": "techno"},
+//	    {"field": "bpm", "op": "between", "value": [128, 135]},
+//	    {"field": "added", "op": "in_last_days", "value": 30}
+//	  ],
+//	  "sort": "energy",
+//	  "limit": 100
+//	}
+type Rules struct {
+	// Match is "all" (the default) or "any": whether a track has to meet
+	// every condition or just one.
+	Match      string      `json:"match"`
+	Conditions []Condition `json:"conditions"`
+	// Sort is one of SortNames, "" for newest first. Order is "asc" or
+	// "desc", "" for the sort's usual direction.
+	Sort  string `json:"sort,omitempty"`
+	Order string `json:"order,omitempty"`
+	// Limit keeps only the first Limit tracks in that order; 0 keeps all.
+	Limit int `json:"limit,omitempty"`
+
+	root node
+}
+
+// Condition is one rule: a track's Field compared by Op with Value.
+type Condition struct {
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Bounds on a smart crate's rules.
+const (
+	MaxConditions = 50
+	MaxRulesLimit = 5000
+)
+
+// ruleTextFields maps the text fields rules can test to their columns.
+var ruleTextFields = map[string]string{
+	"title":    "t.title",
+	"artist":   "t.artist",
+	"album":    "t.album",
+	"genre":    "t.genre",
+	"filename": "t.original_filename",
+}
+
+// ruleNumberFields maps the number fields rules can compare to their
+// columns. Durations are in seconds and loudness in LUFS.
+var ruleNumberFields = map[string]string{
+	"bpm":          "t.bpm",
+	"year":         "t.year",
+	"energy":       "t.energy",
+	"danceability": "t.danceability",
+	"duration":     "t.duration_seconds",
+	"loudness":     "t.loudness_lufs",
+}
+
+// ruleListFields are matched against a list of values, using the query
+// language's field of the same name to check and expand them.
+var ruleListFields = []string{"key", "mood", "status", "format", "quality", "tag", "color"}
+
+// ruleFieldNames lists every field rules can test, for error messages.
+func ruleFieldNames() []string {
+	names := append([]string{"added", "crate", "rating", "favourite"}, ruleListFields...)
+	for name := range ruleTextFields {
+		names = append(names, name)
+	}
+	for name := range ruleNumberFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseRules reads and validates a smart crate's rules. Field, op, match
+// and order names are normalised to lower case, so the parsed rules
+// re-encode in a canonical form.
+func ParseRules(data []byte) (*Rules, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	r := &Rules{}
+	if err := dec.Decode(r); err != nil {
+		return nil, fmt.Errorf("rules are not valid: %v", err)
+	}
+
+	r.Match = strings.ToLower(r.Match)
+	if r.Match == "" {
+		r.Match = "all"
+	}
+	if r.Match != "all" && r.Match != "any" {
+		return nil, errors.New("match must be all or any")
+	}
+	if r.Conditions == nil {
+		r.Conditions = []Condition{}
+	}
+	if len(r.Conditions) > MaxConditions {
+		return nil, fmt.Errorf("rules can have at most %d conditions", MaxConditions)
+	}
+	if r.Sort != "" {
+		if _, ok := sortColumns[r.Sort]; !ok {
+			return nil, fmt.Errorf("sort must be one of %s", strings.Join(SortNames, ", "))
+		}
+	}
+	r.Order = strings.ToLower(r.Order)
+	if r.Order != "" && r.Order != "asc" && r.Order != "desc" {
+		return nil, errors.New("order must be asc or desc")
+	}
+	if r.Limit < 0 || r.Limit > MaxRulesLimit {
+		return nil, fmt.Errorf("limit must be from 1 to %d, or 0 for no limit", MaxRulesLimit)
+	}
+
+	nodes := make([]node, 0, len(r.Conditions))
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		c.Field = strings.ToLower(strings.TrimSpace(c.Field))
+		c.Op = strings.ToLower(strings.TrimSpace(c.Op))
+		n, err := c.node()
+		if err != nil {
+			return nil, fmt.Errorf("condition %d: %w", i+1, err)
+		}
+		nodes = append(nodes, n)
+	}
+	switch {
+	case len(nodes) == 0:
+		r.root = allNode{}
+	case len(nodes) == 1:
+		r.root = nodes[0]
+	case r.Match == "any":
+		r.root = orNode(nodes)
+	default:
+		r.root = andNode(nodes)
+	}
+	return r, nil
+}
+
+// node builds the condition's node, checking its op and value.
+func (c *Condition) node() (node, error) {
+	if col, ok := ruleTextFields[c.Field]; ok {
+		return c.textNode(col)
+	}
+	if col, ok := ruleNumberFields[c.Field]; ok {
+		return c.numberNode(col)
+	}
+	for _, name := range ruleListFields {
+		if c.Field == name {
+			return c.listNode()
+		}
+	}
+	switch c.Field {
+	case "rating":
+		n, err := c.numberNode(markToken)
+		if err != nil {
+			return nil, err
+		}
+		return markNode{column: "rating", node: n}, nil
+	case "favourite":
+		return c.favouriteNode()
+	case "added":
+		return c.addedNode()
+	case "crate":
+		return c.crateNode()
+	}
+	return nil, fmt.Errorf("unknown field %q; fields are %s", c.Field, strings.Join(ruleFieldNames(), ", "))
+}
+
+func (c *Condition) badOp(ops ...string) error {
+	return fmt.Errorf("%s op must be one of %s", c.Field, strings.Join(ops, ", "))
+}
+
+// likePattern escapes LIKE's wildcards in s.
+func likePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// textNode compares a text field ignoring case.
+func (c *Condition) textNode(column string) (node, error) {
+	var s string
+	if err := json.Unmarshal(c.Value, &s); err != nil || strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("%s needs some text as its value", c.Field)
+	}
+	switch c.Op {
+	case "is":
+		return condNode{cond: column + " = ? COLLATE NOCASE", args: []any{s}}, nil
+	case "is_not":
+		return notNode{condNode{cond: column + " = ? COLLATE NOCASE", args: []any{s}}}, nil
+	case "contains":
+		return condNode{cond: column + ` LIKE ? ESCAPE '\'`, args: []any{"%" + likePattern(s) + "%"}}, nil
+	case "not_contains":
+		return notNode{condNode{cond: column + ` LIKE ? ESCAPE '\'`, args: []any{"%" + likePattern(s) + "%"}}}, nil
+	case "starts_with":
+		return condNode{cond: column + ` LIKE ? ESCAPE '\'`, args: []any{likePattern(s) + "%"}}, nil
+	}
+	return nil, c.badOp("is", "is_not", "contains", "not_contains", "starts_with")
+}
+
+// numberNode compares a number field. bpm "is" matches the whole BPM it
+// names, as bpm:128 does in a search.
+func (c *Condition) numberNode(column string) (node, error) {
+	if c.Op == "between" {
+		var bounds []float64
+		if err := json.Unmarshal(c.Value, &bounds); err != nil || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s between needs two numbers, like [128, 135]", c.Field)
+		}
+		if bounds[0] > bounds[1] {
+			return nil, fmt.Errorf("%s range %g-%g runs backwards", c.Field, bounds[0], bounds[1])
+		}
+		return condNode{cond: column + " >= ? AND " + column + " <= ?", args: []any{bounds[0], bounds[1]}}, nil
+	}
+	var v float64
+	if err := json.Unmarshal(c.Value, &v); err != nil {
+		return nil, fmt.Errorf("%s needs a number as its value", c.Field)
+	}
+	is := condNode{cond: column + " = ?", args: []any{v}}
+	if c.Field == "bpm" {
+		is = condNode{cond: column + " >= ? AND " + column + " < ?", args: []any{v - 0.5, v + 0.5}}
+	}
+	switch c.Op {
+	case "is":
+		return is, nil
+	case "is_not":
+		return notNode{is}, nil
+	case "gt", "gte", "lt", "lte":
+		op := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}[c.Op]
+		return condNode{cond: column + " " + op + " ?", args: []any{v}}, nil
+	}
+	return nil, c.badOp("is", "is_not", "gt", "gte", "lt", "lte", "between")
+}
+
+// stringsValue reads a value that is one string or a list of them.
+func (c *Condition) stringsValue() ([]string, error) {
+	var one string
+	if err := json.Unmarshal(c.Value, &one); err == nil {
+		if strings.TrimSpace(one) == "" {
+			return nil, fmt.Errorf("%s needs a value", c.Field)
+		}
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(c.Value, &many); err != nil || len(many) == 0 {
+		return nil, fmt.Errorf("%s needs a value or a list of values", c.Field)
+	}
+	for _, v := range many {
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("%s values can't be blank", c.Field)
+		}
+	}
+	return many, nil
+}
+
+// listNode matches one of a list of keys, moods, statuses, formats or
+// verdicts. key "compatible" also matches each key's wheel neighbours.
+func (c *Condition) listNode() (node, error) {
+	values, err := c.stringsValue()
+	if err != nil {
+		return nil, err
+	}
+	ops := []string{"in", "not_in"}
+	if c.Field == "key" {
+		ops = append(ops, "compatible")
+	}
+	var negate bool
+	switch c.Op {
+	case "in":
+	case "not_in":
+		negate = true
+	case "compatible":
+		if c.Field != "key" {
+			return nil, c.badOp(ops...)
+		}
+		for i, v := range values {
+			values[i] = strings.TrimSpace(v) + "~"
+		}
+	default:
+		return nil, c.badOp(ops...)
+	}
+	n, err := fields[c.Field](strings.Join(values, ","))
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		return notNode{n}, nil
+	}
+	return n, nil
+}
+
+// favouriteNode matches the owner's favourites, or with false everything
+// else.
+func (c *Condition) favouriteNode() (node, error) {
+	if c.Op != "is" {
+		return nil, c.badOp("is")
+	}
+	var v bool
+	if err := json.Unmarshal(c.Value, &v); err != nil {
+		return nil, errors.New("favourite needs true or false as its value")
+	}
+	if v {
+		return favouriteField("yes")
+	}
+	return favouriteField("no")
+}
+
+// addedNode compares when a track was added. in_last_days is measured back
+// from whenever the crate is read.
+func (c *Condition) addedNode() (node, error) {
+	switch c.Op {
+	case "in_last_days":
+		var days int
+		if err := json.Unmarshal(c.Value, &days); err != nil || days < 1 || days > 36500 {
+			return nil, errors.New("added in_last_days needs a whole number of days from 1 to 36500")
+		}
+		return sinceNode{days: days}, nil
+	case "before", "after":
+		var s string
+		_ = json.Unmarshal(c.Value, &s)
+		t, ok := parseTime(s)
+		if !ok {
+			return nil, fmt.Errorf("added %s needs a date like 2024-01-31 or an RFC 3339 time", c.Op)
+		}
+		if c.Op == "before" {
+			return condNode{cond: "t.created_at < ?", args: []any{t}}, nil
+		}
+		return condNode{cond: "t.created_at >= ?", args: []any{t}}, nil
+	}
+	return nil, c.badOp("in_last_days", "before", "after")
+}
+
+// crateNode matches membership of the owner's crates by name or id, as
+// crate: does in a search; "none" is tracks in no crate.
+func (c *Condition) crateNode() (node, error) {
+	names, err := c.stringsValue()
+	if err != nil {
+		return nil, err
+	}
+	var negate bool
+	switch c.Op {
+	case "in":
+	case "not_in":
+		negate = true
+	default:
+		return nil, c.badOp("in", "not_in")
+	}
+	nodes := make(orNode, 0, len(names))
+	for _, name := range names {
+		n, err := crateField(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	var n node = nodes
+	if len(nodes) == 1 {
+		n = nodes[0]
+	}
+	if negate {
+		return notNode{n}, nil
+	}
+	return n, nil
+}
+
+// sinceNode matches tracks added in the days before the compiler's now.
+type sinceNode struct {
+	days int
+}
+
+func (n sinceNode) sql(c *compiler) string {
+	c.args = append(c.args, c.now.AddDate(0, 0, -n.days).UTC())
+	return "(t.created_at >= ?)"
+}
+
+// Members returns a condition, prefixed with " AND ", matching the smart
+// crate's tracks in userID's library as of now, for tracks aliased t. With
+// a limit, only the first Limit tracks in the rules' order are members.
+func (r *Rules) Members(userID string, now time.Time) (string, []any) {
+	c := &compiler{userID: userID, now: now}
+	cond := "t.owner_user_id = ? AND t.deleted_at IS NULL"
+	if _, ok := r.root.(allNode); !ok {
+		cond += " AND " + r.root.sql(c)
+	}
+	args := append([]any{userID}, c.args...)
+	if r.Limit == 0 {
+		return " AND " + cond, args
+	}
+	order, orderArgs := r.OrderBy("t.", userID)
+	args = append(args, orderArgs...)
+	return " AND t.id IN (SELECT t.id FROM tracks t WHERE " + cond +
+		" ORDER BY " + order + " LIMIT ?)", append(args, r.Limit)
+}
+
+// sortFilter is the rules' sort as a ListFilter sort.
+func (r *Rules) sortFilter(userID string) *ListFilter {
+	f := &ListFilter{Sort: r.Sort, Desc: sortColumns[r.Sort].desc, UserID: userID}
+	switch r.Order {
+	case "asc":
+		f.Desc = false
+		if f.Sort == "" {
+			f.Sort = "created_at"
+		}
+	case "desc":
+		f.Desc = f.Sort != ""
+	}
+	return f
+}
+
+// OrderBy returns the ORDER BY expression for the rules' sort, for columns
+// qualified by prefix, and its arguments. A rating sort uses userID's
+// ratings.
+func (r *Rules) OrderBy(prefix, userID string) (string, []any) {
+	return r.sortFilter(userID).OrderBy(prefix, prefix+"created_at DESC")
+}
+
+// SortKeys returns the order of OrderBy as keys for cursor pagination.
+func (r *Rules) SortKeys(prefix, userID string) []pagination.Key {
+	if f := r.sortFilter(userID); f.Sort != "" {
+		return f.sortKeys(prefix)
+	}
+	return []pagination.Key{{Expr: prefix + "created_at", Desc: true}}
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRules_Errors(t *testing.T) {
+	cases := []struct {
+		rules string
+		msg   string
+	}{
+		{`[]`, "rules are not valid"},
+		{`{"conditions": [], "limt": 5}`, `unknown field "limt"`},
+		{`{"match": "some"}`, "match must be all or any"},
+		{`{"sort": "plays"}`, "sort must be one of"},
+		{`{"order": "up"}`, "order must be asc or desc"},
+		{`{"limit": 5001}`, "limit must be from 1 to 5000"},
+		{`{"conditions": [{"field": "bmp", "op": "is", "value": 128}]}`, `condition 1: unknown field "bmp"`},
+		{`{"conditions": [{"field": "bpm", "op": "around", "value": 128}]}`, "bpm op must be one of"},
+		{`{"conditions": [{"field": "bpm", "op": "between", "value": [135, 128]}]}`, "runs backwards"},
+		{`{"conditions": [{"field": "bpm", "op": "between", "value": 128}]}`, "needs two numbers"},
+		{`{"conditions": [{"field": "genre", "op": "is", "value": ""}]}`, "genre needs some text"},
+		{`{"conditions": [{"field": "key", "op": "in", "value": ["8A", "H"]}]}`, `key "H" is not a key`},
+		{`{"conditions": [{"field": "mood", "op": "compatible", "value": "happy"}]}`, "mood op must be one of in, not_in"},
+		{`{"conditions": [{"field": "added", "op": "in_last_days", "value": 0}]}`, "whole number of days"},
+		{`{"conditions": [{"field": "added", "op": "after", "value": "last week"}]}`, "added after needs a date"},
+		{`{"conditions": [{"field": "title", "op": "is", "value": "a"}, {"field": "crate", "op": "has", "value": "x"}]}`, "condition 2: crate op"},
+	}
+	for _, tc := range cases {
+		_, err := ParseRules([]byte(tc.rules))
+		if err == nil || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("ParseRules(%s) err = %v, want %q", tc.rules, err, tc.msg)
+		}
+	}
+}
+
+func TestParseRules_Normalises(t *testing.T) {
+	r, err := ParseRules([]byte(`{"conditions": [{"field": " Genre", "op": "IS", "value": "Techno"}], "order": "DESC"}`))
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	out, _ := json.Marshal(r)
+	want := `{"match":"all","conditions":[{"field":"genre","op":"is","value":"Techno"}],"order":"desc"}`
+	if string(out) != want {
+		t.Errorf("re-encoded = %s, want %s", out, want)
+	}
+}
+
+// TestRules_RunInSQLite evaluates rules against a small library, including
+// the relative date and the limit.
+func TestRules_RunInSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+        CREATE TABLE tracks (
+            id TEXT PRIMARY KEY, owner_user_id TEXT, title TEXT, artist TEXT, genre TEXT,
+            original_filename TEXT, content_type TEXT, bpm REAL, musical_key TEXT,
+            energy INTEGER, created_at DATETIME, deleted_at DATETIME
+        );
+        CREATE TABLE playlists (id TEXT PRIMARY KEY, owner_user_id TEXT, name TEXT);
+        CREATE TABLE playlist_tracks (playlist_id TEXT, track_id TEXT);
+        INSERT INTO tracks (id, owner_user_id, title, artist, genre, original_filename, content_type, bpm, musical_key, energy, created_at) VALUES
+            ('a', 'u1', 'Spastik', 'Plastikman', 'Techno', 'a.flac', 'audio/flac', 130, '8A', 9, '2026-10-10 12:00:00'),
+            ('b', 'u1', 'Strings of Life', 'Rhythim Is Rhythim', 'techno', 'b.mp3', 'audio/mpeg', 128, '9A', 7, '2026-10-01 12:00:00'),
+            ('c', 'u1', 'Hold On', 'Rezz', 'Techno', 'c.mp3', 'audio/mpeg', 140, '8B', 8, '2026-10-15 12:00:00'),
+            ('d', 'u1', 'Finally', 'Kings of Tomorrow', 'House', 'd_100%.wav', 'audio/wav', 124, '8A', NULL, '2026-10-16 12:00:00'),
+            ('e', 'u2', 'Spastik', 'Plastikman', 'Techno', 'e.flac', 'audio/flac', 130, '8A', 9, '2026-10-16 12:00:00');
+        INSERT INTO tracks (id, owner_user_id, title, genre, bpm, musical_key, energy, created_at, deleted_at) VALUES
+            ('f', 'u1', 'Trashed', 'Techno', 130, '8A', 10, '2026-10-17 12:00:00', '2026-10-17 13:00:00');
+        INSERT INTO playlists VALUES ('p1', 'u1', 'Peak Time');
+        INSERT INTO playlist_tracks VALUES ('p1', 'c');
+        CREATE TABLE track_ratings (track_id TEXT, user_id TEXT, rating INTEGER, favourite BOOLEAN, color TEXT);
+        INSERT INTO track_ratings VALUES ('a', 'u1', 3, 0, NULL), ('b', 'u1', 5, 1, 'green'), ('c', 'u2', 5, 0, NULL);
+    `)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cases := map[string][]string{
+		// The request's example: genre, BPM range, recent, by energy.
+		`{"conditions": [
+            {"field": "genre", "op": "is", "value": "techno"},
+            {"field": "bpm", "op": "between", "value": [128, 135]},
+            {"field": "added", "op": "in_last_days", "value": 30}
+        ], "sort": "energy"}`: {"a", "b"},
+		`{"conditions": [{"field": "added", "op": "in_last_days", "value": 7}]}`: {"d", "c"},
+		`{"match": "any", "conditions": [
+            {"field": "key", "op": "compatible", "value": "Am"},
+            {"field": "format", "op": "in", "value": "lossless"}
+        ], "sort": "bpm"}`: {"d", "b", "a", "c"},
+		`{"conditions": [{"field": "crate", "op": "not_in", "value": "peak time"}, {"field": "energy", "op": "gte", "value": 7}]}`: {"a", "b"},
+		`{"conditions": [{"field": "filename", "op": "contains", "value": "_100%"}]}`:                                              {"d"},
+		`{"conditions": [{"field": "title", "op": "is_not", "value": "spastik"}], "sort": "title", "order": "desc"}`:               {"b", "c", "d"},
+		`{"conditions": [], "sort": "energy", "limit": 2}`:                                                                         {"a", "c"},
+		`{"order": "asc", "limit": 1}`: {"b"},
+		// Ratings are the owner's, and sort best first.
+		`{"conditions": [{"field": "rating", "op": "gte", "value": 3}], "sort": "rating"}`:                                                        {"b", "a"},
+		`{"match": "any", "conditions": [{"field": "favourite", "op": "is", "value": true}, {"field": "color", "op": "in", "value": ["green"]}]}`: {"b"},
+	}
+	for rules, want := range cases {
+		r, err := ParseRules([]byte(rules))
+		if err != nil {
+			t.Fatalf("ParseRules(%s): %v", rules, err)
+		}
+		cond, args := r.Members("u1", now)
+		order, orderArgs := r.OrderBy("t.", "u1")
+		rows, err := db.Query(`SELECT t.id FROM tracks t WHERE 1`+cond+` ORDER BY `+order, append(args, orderArgs...)...)
+		if err != nil {
+			t.Fatalf("%s: %v", rules, err)
+		}
+		var got []string
+		for rows.Next() {
+			var id string
+			_ = rows.Scan(&id)
+			got = append(got, id)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", rules, got, want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package sequence
+
+import (
+	"math"
+	"sort"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+)
+
+// Track is the subset of a track the sequencer orders by.
+type Track struct {
+	ID         string  `json:"track_id"`
+	Title      string  `json:"title"`
+	Artist     string  `json:"artist"`
+	BPM        float64 `json:"bpm,omitempty"`         // 0 if unknown
+	MusicalKey string  `json:"musical_key,omitempty"` // Camelot; "" if unknown
+	Energy     int     `json:"energy,omitempty"`      // 1-10; 0 if unknown
+}
+
+// EnergyPoint is one point on the target energy curve. At is the position in
+// the set from 0 (first track) to 1 (last track).
+type EnergyPoint struct {
+	At     float64 `json:"at"`
+	Energy float64 `json:"energy"`
+}
+
+// PeakCurve is the classic warm-up, peak, cool-down shape.
+var PeakCurve = []EnergyPoint{{At: 0, Energy: 3}, {At: 0.7, Energy: 9}, {At: 1, Energy: 5}}
+
+// Constraints shape an order: tracks pinned to 0-based positions, and an
+// optional energy curve to follow.
+type Constraints struct {
+	Pins  map[string]int // track ID -> position
+	Curve []EnergyPoint  // nil to ignore energy
+}
+
+// Transition costs. A clean mix (same or adjacent key, a couple of percent
+// of tempo) costs about 1; a clash costs several. Unknown keys and tempos
+// cost a middling amount so they neither attract nor repel.
+const (
+	tempoStep        = 0.02 // tempo difference costing 1
+	maxTempoCost     = 8
+	unknownKeyCost   = 3
+	unknownTempoCost = 3
+	energyCost       = 0.75 // per energy point off the curve
+	unknownEnergy    = 1.5
+	maxStarts        = 16   // greedy construction attempts
+	startBudget      = 2000 // tracks x starts; big crates get fewer starts
+	maxPasses        = 50   // local search passes
+	maxReversal      = 32   // longest run a 2-opt move reverses
+)
+
+var keyCosts = []float64{0, 1, 3, 6} // by Camelot distance; further is 6 too
+
+// transitionCost scores mixing from a into b.
+func transitionCost(a, b *Track) float64 {
+	cost := float64(unknownKeyCost)
+	if d := analysis.CamelotDistance(a.MusicalKey, b.MusicalKey); d >= 0 {
+		cost = keyCosts[min(d, len(keyCosts)-1)]
+	}
+	if _, diff, ok := analysis.TempoMatch(a.BPM, b.BPM, math.Inf(1)); ok {
+		cost += math.Min(maxTempoCost, diff/tempoStep)
+	} else {
+		cost += unknownTempoCost
+	}
+	return cost
+}
+
+// targetEnergy linearly interpolates the curve at x (0..1), flat beyond its
+// ends. The curve must be sorted by At.
+func targetEnergy(curve []EnergyPoint, x float64) float64 {
+	if x <= curve[0].At {
+		return curve[0].Energy
+	}
+	for i := 1; i < len(curve); i++ {
+		if x <= curve[i].At {
+			a, b := curve[i-1], curve[i]
+			if b.At == a.At {
+				return b.Energy
+			}
+			return a.Energy + (b.Energy-a.Energy)*(x-a.At)/(b.At-a.At)
+		}
+	}
+	return curve[len(curve)-1].Energy
+}
+
+// problem holds precomputed costs for one crate.
+type problem struct {
+	tracks []*Track
+	trans  [][]float64 // trans[i][j]: track i into track j
+	place  [][]float64 // place[i][p]: track i at position p
+	fixed  []int       // fixed[p]: track index pinned at p, or -1
+	pinned []bool      // pinned[i]: track i is fixed somewhere
+}
+
+func newProblem(tracks []*Track, c Constraints) *problem {
+	n := len(tracks)
+	p := &problem{
+		tracks: tracks,
+		trans:  make([][]float64, n),
+		place:  make([][]float64, n),
+		fixed:  make([]int, n),
+		pinned: make([]bool, n),
+	}
+	var curve []EnergyPoint
+	if len(c.Curve) > 0 {
+		curve = append(curve, c.Curve...)
+		sort.SliceStable(curve, func(i, j int) bool { return curve[i].At < curve[j].At })
+	}
+	for i, a := range tracks {
+		p.trans[i] = make([]float64, n)
+		for j, b := range tracks {
+			if i != j {
+				p.trans[i][j] = transitionCost(a, b)
+			}
+		}
+		p.place[i] = make([]float64, n)
+		if curve == nil {
+			continue
+		}
+		for pos := range p.place[i] {
+			x := 0.0
+			if n > 1 {
+				x = float64(pos) / float64(n-1)
+			}
+			if a.Energy == 0 {
+				p.place[i][pos] = unknownEnergy
+			} else {
+				p.place[i][pos] = energyCost * math.Abs(float64(a.Energy)-targetEnergy(curve, x))
+			}
+		}
+	}
+
+	for pos := range p.fixed {
+		p.fixed[pos] = -1
+	}
+	index := make(map[string]int, n)
+	for i, t := range tracks {
+		index[t.ID] = i
+	}
+	for id, pos := range c.Pins {
+		i := index[id]
+		p.fixed[pos], p.pinned[i] = i, true
+	}
+	return p
+}
+
+// cost totals an order: every transition plus every track's distance from
+// the energy curve.
+func (p *problem) cost(order []int) float64 {
+	total := 0.0
+	for pos, i := range order {
+		total += p.place[i][pos]
+		if pos > 0 {
+			total += p.trans[order[pos-1]][i]
+		}
+	}
+	return total
+}
+
+// greedy builds an order position by position, taking the cheapest free
+// track each time and looking one step ahead to a pinned neighbour.
+func (p *problem) greedy(start int) []int {
+	n := len(p.tracks)
+	order := make([]int, n)
+	used := make([]bool, n)
+	for pos := 0; pos < n; pos++ {
+		if i := p.fixed[pos]; i >= 0 {
+			order[pos], used[i] = i, true
+			continue
+		}
+		if pos == 0 && start >= 0 {
+			order[0], used[start] = start, true
+			continue
+		}
+		best, bestCost := -1, math.Inf(1)
+		for i := 0; i < n; i++ {
+			if used[i] || p.pinned[i] {
+				continue
+			}
+			c := p.place[i][pos]
+			if pos > 0 {
+				c += p.trans[order[pos-1]][i]
+			}
+			if pos+1 < n && p.fixed[pos+1] >= 0 {
+				c += p.trans[i][p.fixed[pos+1]]
+			}
+			if c < bestCost {
+				best, bestCost = i, c
+			}
+		}
+		order[pos], used[best] = best, true
+	}
+	return order
+}
+
+// around sums the costs that depend on which track sits at pos.
+func (p *problem) around(order []int, pos int) float64 {
+	i := order[pos]
+	c := p.place[i][pos]
+	if pos > 0 {
+		c += p.trans[order[pos-1]][i]
+	}
+	if pos+1 < len(order) {
+		c += p.trans[i][order[pos+1]]
+	}
+	return c
+}
+
+// improve runs swap and 2-opt moves over the free positions until neither
+// finds a cheaper order. Returns the final cost.
+func (p *problem) improve(order []int) float64 {
+	n := len(order)
+	free := make([]int, 0, n)
+	for pos := 0; pos < n; pos++ {
+		if p.fixed[pos] < 0 {
+			free = append(free, pos)
+		}
+	}
+	current := p.cost(order)
+	const eps = 1e-9
+	for pass := 0; pass < maxPasses; pass++ {
+		improved := false
+
+		// Swap two tracks. Neighbouring positions share a transition, so
+		// the delta is taken over both at once.
+		for a := 0; a < len(free); a++ {
+			for b := a + 1; b < len(free); b++ {
+				x, y := free[a], free[b]
+				local := func() float64 {
+					if y == x+1 {
+						return p.segmentCost(order, max(0, x-1), min(n-1, y+1))
+					}
+					return p.around(order, x) + p.around(order, y)
+				}
+				before := local()
+				order[x], order[y] = order[y], order[x]
+				after := local()
+				if after < before-eps {
+					current += after - before
+					improved = true
+				} else {
+					order[x], order[y] = order[y], order[x]
+				}
+			}
+		}
+
+		// Reverse a short run of free positions.
+		for a := 0; a < len(free); a++ {
+			for b := a + 1; b < len(free) && b-a < maxReversal && free[b]-free[a] == b-a; b++ {
+				x, y := free[a], free[b]
+				lo, hi := max(0, x-1), min(n-1, y+1)
+				before := p.segmentCost(order, lo, hi)
+				reverse(order[x : y+1])
+				after := p.segmentCost(order, lo, hi)
+				if after < before-eps {
+					current += after - before
+					improved = true
+				} else {
+					reverse(order[x : y+1])
+				}
+			}
+		}
+
+		if !improved {
+			break
+		}
+	}
+	return current
+}
+
+// segmentCost is the cost of positions lo..hi, counting the transitions
+// between them and each track's placement.
+func (p *problem) segmentCost(order []int, lo, hi int) float64 {
+	c := 0.0
+	for pos := lo; pos <= hi; pos++ {
+		c += p.place[order[pos]][pos]
+		if pos > lo {
+			c += p.trans[order[pos-1]][order[pos]]
+		}
+	}
+	return c
+}
+
+func reverse(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// Order proposes a playing order for tracks that keeps key clashes and tempo
+// jumps down and, with a curve, follows it in energy. It is a heuristic
+// path search: greedy nearest-neighbour orders from several starts, each
+// refined by swap and 2-opt moves, keeping the cheapest. Constraints must
+// already be valid for tracks. Returns the order and its cost.
+func Order(tracks []*Track, c Constraints) ([]*Track, float64) {
+	n := len(tracks)
+	if n == 0 {
+		return nil, 0
+	}
+	p := newProblem(tracks, c)
+
+	starts := []int{-1}
+	if p.fixed[0] < 0 {
+		// Seed from the tracks that fit the opening slot best.
+		var cands []int
+		for i := range tracks {
+			if !p.pinned[i] {
+				cands = append(cands, i)
+			}
+		}
+		sort.SliceStable(cands, func(a, b int) bool { return p.place[cands[a]][0] < p.place[cands[b]][0] })
+		starts = cands[:min(len(cands), maxStarts, max(1, startBudget/n))]
+	}
+
+	var best []int
+	bestCost := math.Inf(1)
+	for _, s := range starts {
+		order := p.greedy(s)
+		if c := p.improve(order); c < bestCost-1e-9 {
+			best, bestCost = order, c
+		}
+	}
+
+	out := make([]*Track, n)
+	for pos, i := range best {
+		out[pos] = tracks[i]
+	}
+	return out, bestCost
+}
+
+// Cost scores tracks in the given order, for comparing against a proposal.
+func Cost(tracks []*Track, curve []EnergyPoint) float64 {
+	p := newProblem(tracks, Constraints{Curve: curve})
+	order := make([]int, len(tracks))
+	for i := range order {
+		order[i] = i
+	}
+	return p.cost(order)
+}
+
+// TransitionCost scores mixing from a into b, without the energy curve.
+func TransitionCost(a, b *Track) float64 {
+	return transitionCost(a, b)
+}
@@ -0,0 +1,118 @@
+package sequence
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ids(tracks []*Track) []string {
+	out := make([]string, len(tracks))
+	for i, t := range tracks {
+		out[i] = t.ID
+	}
+	return out
+}
+
+// wheel returns tracks in keys 1A..nA at one tempo, listed out of order.
+func wheel(n int) []*Track {
+	var tracks []*Track
+	for _, k := range []int{5, 1, 8, 3, 6, 2, 7, 4}[:n] {
+		tracks = append(tracks, &Track{ID: fmt.Sprintf("k%d", k), MusicalKey: fmt.Sprintf("%dA", k), BPM: 124})
+	}
+	return tracks
+}
+
+func TestTransitionCost(t *testing.T) {
+	a := &Track{MusicalKey: "8A", BPM: 124}
+	cases := []struct {
+		name string
+		b    *Track
+		want float64
+	}{
+		{"same key and tempo", &Track{MusicalKey: "8A", BPM: 124}, 0},
+		{"adjacent key", &Track{MusicalKey: "9A", BPM: 124}, 1},
+		{"half time", &Track{MusicalKey: "8A", BPM: 62}, 0},
+		{"clash", &Track{MusicalKey: "2A", BPM: 124}, 6},
+		{"tempo jump capped", &Track{MusicalKey: "8A", BPM: 100}, maxTempoCost},
+		{"unknown", &Track{}, unknownKeyCost + unknownTempoCost},
+	}
+	for _, tc := range cases {
+		if got := transitionCost(a, tc.b); got != tc.want {
+			t.Errorf("%s: cost = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTargetEnergy(t *testing.T) {
+	for _, tc := range []struct{ x, want float64 }{{0, 3}, {0.35, 6}, {0.7, 9}, {0.85, 7}, {1, 5}} {
+		if got := targetEnergy(PeakCurve, tc.x); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("targetEnergy(%v) = %v, want %v", tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestOrder_WalksTheWheel(t *testing.T) {
+	tracks := wheel(8)
+	order, cost := Order(tracks, Constraints{})
+	if cost != 7 {
+		t.Fatalf("cost = %v (%v), want 7: one step per transition", cost, ids(order))
+	}
+	if cost >= Cost(tracks, nil) {
+		t.Errorf("proposal is no better than the original order")
+	}
+	if got := Cost(order, nil); got != cost {
+		t.Errorf("reported cost %v, rescored %v", cost, got)
+	}
+}
+
+func TestOrder_Pins(t *testing.T) {
+	tracks := wheel(8)
+	c := Constraints{Pins: map[string]int{"k5": 0, "k2": 7, "k8": 3}}
+	order, _ := Order(tracks, c)
+	for id, pos := range c.Pins {
+		if order[pos].ID != id {
+			t.Errorf("position %d = %s, want pinned %s (%v)", pos, order[pos].ID, id, ids(order))
+		}
+	}
+	seen := map[string]bool{}
+	for _, tr := range order {
+		if seen[tr.ID] {
+			t.Fatalf("track %s placed twice: %v", tr.ID, ids(order))
+		}
+		seen[tr.ID] = true
+	}
+	if len(seen) != len(tracks) {
+		t.Fatalf("order has %d tracks, want %d", len(seen), len(tracks))
+	}
+}
+
+func TestOrder_FollowsEnergyCurve(t *testing.T) {
+	var tracks []*Track
+	for i, e := range []int{9, 3, 5, 7, 4, 8, 6, 5, 3, 8, 6} {
+		tracks = append(tracks, &Track{ID: fmt.Sprintf("t%d", i), MusicalKey: "8A", BPM: 124, Energy: e})
+	}
+	order, cost := Order(tracks, Constraints{Curve: PeakCurve})
+	if cost >= Cost(tracks, PeakCurve) {
+		t.Errorf("proposal is no better than the original order")
+	}
+	peak := 0
+	for i, tr := range order {
+		if tr.Energy == 9 {
+			peak = i
+		}
+	}
+	if peak < 5 || peak > 8 {
+		t.Errorf("peak track at %d of %d, want around 70%% through", peak, len(order))
+	}
+	if order[0].Energy > 4 {
+		t.Errorf("opener energy = %d, want a warm-up track", order[0].Energy)
+	}
+}
+
+func TestOrder_Deterministic(t *testing.T) {
+	a, _ := Order(wheel(8), Constraints{Curve: PeakCurve})
+	b, _ := Order(wheel(8), Constraints{Curve: PeakCurve})
+	if fmt.Sprint(ids(a)) != fmt.Sprint(ids(b)) {
+		t.Errorf("orders differ: %v vs %v", ids(a), ids(b))
+	}
+}
@@ -0,0 +1,74 @@
+package sequence
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrCrateNotFound):
+		return http.StatusNotFound, "crate_not_found"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrEmptyCrate):
+		return http.StatusUnprocessableEntity, "empty_crate"
+	case errors.Is(err, ErrCrateChanged):
+		return http.StatusConflict, "crate_changed"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+// Propose suggests an order for the crate. The body is optional; without
+// one the order only minimizes key clashes and tempo jumps.
+func (h *Handlers) Propose(c *gin.Context) {
+	var req ProposeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+			return
+		}
+	}
+	proposal, err := h.manager.Propose(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sequence": proposal})
+}
+
+type applyRequest struct {
+	TrackIDs []string `json:"track_ids"`
+}
+
+func (h *Handlers) Apply(c *gin.Context) {
+	var req applyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if err := h.manager.Apply(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("user_role"), req.TrackIDs); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reordered": len(req.TrackIDs)})
+}
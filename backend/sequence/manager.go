@@ -0,0 +1,222 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrCrateNotFound  = errors.New("crate not found")
+	ErrEmptyCrate     = errors.New("crate has no tracks")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrCrateChanged   = errors.New("crate tracks changed since the order was proposed")
+)
+
+// Limits. The local search is quadratic in crate size per pass; at the cap
+// a proposal takes a few seconds on a Pi.
+const (
+	maxSequenceTracks = 500
+	maxCurvePoints    = 32
+	minCurveEnergy    = 1
+	maxCurveEnergy    = 10
+)
+
+// Energy curve presets.
+const PresetPeak = "peak"
+
+var presets = map[string][]EnergyPoint{
+	PresetPeak: PeakCurve,
+}
+
+// Pin fixes a track at a 0-based position in the set.
+type Pin struct {
+	TrackID  string `json:"track_id"`
+	Position int    `json:"position"`
+}
+
+// ProposeRequest constrains a proposed order. Every field is optional.
+type ProposeRequest struct {
+	FirstTrackID string        `json:"first_track_id"`
+	LastTrackID  string        `json:"last_track_id"`
+	Pins         []Pin         `json:"pins"`
+	EnergyCurve  []EnergyPoint `json:"energy_curve"`
+	EnergyPreset string        `json:"energy_preset"`
+}
+
+// ProposedTrack is one track of a proposal. TransitionCost is the cost of
+// mixing into it from the track before; nil for the opener.
+type ProposedTrack struct {
+	*Track
+	Position       int      `json:"position"`
+	Pinned         bool     `json:"pinned"`
+	TransitionCost *float64 `json:"transition_cost,omitempty"`
+}
+
+// Proposal is a suggested playing order. Lower costs are smoother;
+// OriginalCost scores the crate's current order the same way.
+type Proposal struct {
+	PlaylistID   string           `json:"playlist_id"`
+	Tracks       []*ProposedTrack `json:"tracks"`
+	Cost         float64          `json:"cost"`
+	OriginalCost float64          `json:"original_cost"`
+}
+
+// Manager proposes and applies playing orders for crates.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now}
+}
+
+// crate loads a crate the user may reorder: their own, or any for admins.
+func (m *Manager) crate(ctx context.Context, playlistID, userID, userRole string) (*Crate, error) {
+	crate, err := m.repo.GetCrate(ctx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	if userRole != "admin" && crate.OwnerUserID != userID {
+		return nil, ErrAccessDenied
+	}
+	return crate, nil
+}
+
+// constraints checks a request against the crate's tracks.
+func constraints(req *ProposeRequest, tracks []*Track) (Constraints, error) {
+	n := len(tracks)
+	inCrate := make(map[string]bool, n)
+	for _, t := range tracks {
+		inCrate[t.ID] = true
+	}
+
+	c := Constraints{Pins: make(map[string]int)}
+	byPos := make(map[int]string)
+	pin := func(id string, pos int) error {
+		if !inCrate[id] {
+			return fmt.Errorf("%w: track %s is not in the crate", ErrInvalidRequest, id)
+		}
+		if pos < 0 || pos >= n {
+			return fmt.Errorf("%w: pin positions must be from 0 to %d", ErrInvalidRequest, n-1)
+		}
+		if p, ok := c.Pins[id]; ok && p != pos {
+			return fmt.Errorf("%w: track %s is pinned to more than one position", ErrInvalidRequest, id)
+		}
+		if other, ok := byPos[pos]; ok && other != id {
+			return fmt.Errorf("%w: more than one track is pinned at position %d", ErrInvalidRequest, pos)
+		}
+		c.Pins[id], byPos[pos] = pos, id
+		return nil
+	}
+	if req.FirstTrackID != "" {
+		if err := pin(req.FirstTrackID, 0); err != nil {
+			return c, err
+		}
+	}
+	if req.LastTrackID != "" {
+		if err := pin(req.LastTrackID, n-1); err != nil {
+			return c, err
+		}
+	}
+	for _, p := range req.Pins {
+		if err := pin(p.TrackID, p.Position); err != nil {
+			return c, err
+		}
+	}
+
+	switch {
+	case req.EnergyPreset != "" && len(req.EnergyCurve) > 0:
+		return c, fmt.Errorf("%w: give energy_curve or energy_preset, not both", ErrInvalidRequest)
+	case req.EnergyPreset != "":
+		curve, ok := presets[req.EnergyPreset]
+		if !ok {
+			return c, fmt.Errorf("%w: unknown energy_preset %q", ErrInvalidRequest, req.EnergyPreset)
+		}
+		c.Curve = curve
+	case len(req.EnergyCurve) > 0:
+		if len(req.EnergyCurve) > maxCurvePoints {
+			return c, fmt.Errorf("%w: energy_curve has at most %d points", ErrInvalidRequest, maxCurvePoints)
+		}
+		for _, p := range req.EnergyCurve {
+			if p.At < 0 || p.At > 1 || p.Energy < minCurveEnergy || p.Energy > maxCurveEnergy {
+				return c, fmt.Errorf("%w: energy_curve points need 0 <= at <= 1 and %d <= energy <= %d",
+					ErrInvalidRequest, minCurveEnergy, maxCurveEnergy)
+			}
+		}
+		c.Curve = req.EnergyCurve
+	}
+	return c, nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Propose suggests a playing order for a crate. Nothing is written; see
+// Apply.
+func (m *Manager) Propose(ctx context.Context, playlistID, userID, userRole string, req *ProposeRequest) (*Proposal, error) {
+	crate, err := m.crate(ctx, playlistID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := m.repo.GetCrateTracks(ctx, crate.ID)
+	if err != nil {
+		return nil, fmt.Errorf("load crate tracks: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrEmptyCrate
+	}
+	if len(tracks) > maxSequenceTracks {
+		return nil, fmt.Errorf("%w: crates over %d tracks are too long to sequence", ErrInvalidRequest, maxSequenceTracks)
+	}
+	c, err := constraints(req, tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	order, cost := Order(tracks, c)
+	proposal := &Proposal{
+		PlaylistID:   crate.ID,
+		Tracks:       make([]*ProposedTrack, len(order)),
+		Cost:         round2(cost),
+		OriginalCost: round2(Cost(tracks, c.Curve)),
+	}
+	for pos, t := range order {
+		pt := &ProposedTrack{Track: t, Position: pos}
+		_, pt.Pinned = c.Pins[t.ID]
+		if pos > 0 {
+			tc := round2(TransitionCost(order[pos-1], t))
+			pt.TransitionCost = &tc
+		}
+		proposal.Tracks[pos] = pt
+	}
+	return proposal, nil
+}
+
+// Apply rewrites a crate's order to trackIDs, which must list every track in
+// the crate exactly once.
+func (m *Manager) Apply(ctx context.Context, playlistID, userID, userRole string, trackIDs []string) error {
+	if len(trackIDs) == 0 {
+		return fmt.Errorf("%w: track_ids is required", ErrInvalidRequest)
+	}
+	if len(trackIDs) > maxSequenceTracks {
+		return fmt.Errorf("%w: at most %d track_ids", ErrInvalidRequest, maxSequenceTracks)
+	}
+	seen := make(map[string]bool, len(trackIDs))
+	for _, id := range trackIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: track %s is listed twice", ErrInvalidRequest, id)
+		}
+		seen[id] = true
+	}
+	crate, err := m.crate(ctx, playlistID, userID, userRole)
+	if err != nil {
+		return err
+	}
+	return m.repo.ApplyOrder(ctx, crate.ID, trackIDs, m.now())
+}
@@ -0,0 +1,148 @@
+package sequence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) (*Manager, *sql.DB) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE playlists (id TEXT PRIMARY KEY, owner_user_id TEXT NOT NULL, updated_at DATETIME);
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, title TEXT, artist TEXT, original_filename TEXT NOT NULL DEFAULT '',
+			bpm REAL, musical_key TEXT, energy INTEGER, deleted_at DATETIME
+		);
+		CREATE TABLE playlist_tracks (
+			playlist_id TEXT NOT NULL, track_id TEXT NOT NULL, position INTEGER NOT NULL DEFAULT 0,
+			added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(playlist_id, track_id)
+		);
+		INSERT INTO playlists (id, owner_user_id) VALUES ('crate', 'dj'), ('empty', 'dj');
+		INSERT INTO tracks (id, title, bpm, musical_key, energy) VALUES
+			('a', 'A', 124, '3A', 4), ('b', 'B', 124, '1A', 3), ('c', 'C', 124, '2A', 6), ('d', 'D', 124, '4A', NULL);
+		INSERT INTO playlist_tracks (playlist_id, track_id, position) VALUES
+			('crate', 'a', 0), ('crate', 'b', 1), ('crate', 'c', 2), ('crate', 'd', 3);
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB})), sqlDB
+}
+
+func crateOrder(t *testing.T, sqlDB *sql.DB) []string {
+	t.Helper()
+	rows, err := sqlDB.Query(`SELECT track_id FROM playlist_tracks WHERE playlist_id = 'crate' ORDER BY position`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		out = append(out, id)
+	}
+	return out
+}
+
+func TestPropose(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	p, err := m.Propose(ctx, "crate", "dj", "user", &ProposeRequest{})
+	if err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+	got := ""
+	for _, tr := range p.Tracks {
+		got += tr.ID
+	}
+	if got != "bcad" && got != "dacb" {
+		t.Errorf("order = %s, want the keys in wheel order", got)
+	}
+	if p.Cost != 3 || p.OriginalCost <= p.Cost {
+		t.Errorf("cost = %v, original = %v; want 3 and higher", p.Cost, p.OriginalCost)
+	}
+	if p.Tracks[0].TransitionCost != nil || p.Tracks[1].TransitionCost == nil {
+		t.Errorf("transition costs should start at the second track")
+	}
+
+	p, err = m.Propose(ctx, "crate", "dj", "user", &ProposeRequest{FirstTrackID: "d", Pins: []Pin{{TrackID: "c", Position: 3}}})
+	if err != nil {
+		t.Fatalf("Propose with pins: %v", err)
+	}
+	if p.Tracks[0].ID != "d" || p.Tracks[3].ID != "c" || !p.Tracks[0].Pinned || p.Tracks[1].Pinned {
+		t.Errorf("pins not honoured: %+v", p.Tracks)
+	}
+}
+
+func TestPropose_Errors(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	cases := []struct {
+		name     string
+		playlist string
+		user     string
+		req      ProposeRequest
+		want     error
+	}{
+		{"missing crate", "nope", "dj", ProposeRequest{}, ErrCrateNotFound},
+		{"not owner", "crate", "other", ProposeRequest{}, ErrAccessDenied},
+		{"empty", "empty", "dj", ProposeRequest{}, ErrEmptyCrate},
+		{"unknown track", "crate", "dj", ProposeRequest{FirstTrackID: "zz"}, ErrInvalidRequest},
+		{"first and last clash", "crate", "dj", ProposeRequest{FirstTrackID: "a", Pins: []Pin{{TrackID: "b", Position: 0}}}, ErrInvalidRequest},
+		{"pin out of range", "crate", "dj", ProposeRequest{Pins: []Pin{{TrackID: "b", Position: 4}}}, ErrInvalidRequest},
+		{"pinned twice", "crate", "dj", ProposeRequest{FirstTrackID: "a", LastTrackID: "a"}, ErrInvalidRequest},
+		{"unknown preset", "crate", "dj", ProposeRequest{EnergyPreset: "sunrise"}, ErrInvalidRequest},
+		{"bad curve", "crate", "dj", ProposeRequest{EnergyCurve: []EnergyPoint{{At: 1.5, Energy: 5}}}, ErrInvalidRequest},
+	}
+	for _, tc := range cases {
+		_, err := m.Propose(ctx, tc.playlist, tc.user, "user", &tc.req)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if _, err := m.Propose(ctx, "crate", "other", "admin", &ProposeRequest{EnergyPreset: PresetPeak}); err != nil {
+		t.Errorf("admin Propose: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+
+	if err := m.Apply(ctx, "crate", "dj", "user", []string{"b", "c", "a", "d"}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := crateOrder(t, sqlDB); len(got) != 4 || got[0] != "b" || got[1] != "c" || got[2] != "a" || got[3] != "d" {
+		t.Errorf("order = %v, want [b c a d]", got)
+	}
+
+	for name, ids := range map[string][]string{
+		"missing track": {"a", "b", "c"},
+		"foreign track": {"a", "b", "c", "x"},
+	} {
+		if err := m.Apply(ctx, "crate", "dj", "user", ids); !errors.Is(err, ErrCrateChanged) {
+			t.Errorf("%s: error = %v, want ErrCrateChanged", name, err)
+		}
+	}
+	if err := m.Apply(ctx, "crate", "dj", "user", []string{"a", "a", "b", "c"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("duplicate: error = %v, want ErrInvalidRequest", err)
+	}
+	if err := m.Apply(ctx, "crate", "other", "user", []string{"a", "b", "c", "d"}); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("not owner: error = %v, want ErrAccessDenied", err)
+	}
+	if got := crateOrder(t, sqlDB); got[0] != "b" || got[3] != "d" {
+		t.Errorf("failed applies changed the order: %v", got)
+	}
+}
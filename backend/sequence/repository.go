@@ -0,0 +1,119 @@
+package sequence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Crate is the subset of a playlist row sequencing needs for access checks.
+type Crate struct {
+	ID          string
+	OwnerUserID string
+}
+
+func (r *Repository) GetCrate(ctx context.Context, playlistID string) (*Crate, error) {
+	var c Crate
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_user_id FROM playlists WHERE id = ?`, playlistID,
+	).Scan(&c.ID, &c.OwnerUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCrateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCrateTracks returns every track in a crate in crate order.
+func (r *Repository) GetCrateTracks(ctx context.Context, playlistID string) ([]*Track, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, COALESCE(t.title, t.original_filename), COALESCE(t.artist, ''),
+		       COALESCE(t.bpm, 0), COALESCE(t.musical_key, ''), COALESCE(t.energy, 0)
+		FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL
+		ORDER BY pt.position ASC, pt.added_at ASC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Track
+	for rows.Next() {
+		var t Track
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.BPM, &t.MusicalKey, &t.Energy); err != nil {
+			return nil, err
+		}
+		out = append(out, &t)
+	}
+	return out, rows.Err()
+}
+
+// ApplyOrder rewrites a crate's positions to trackIDs (0-based) in one
+// transaction. It fails with ErrCrateChanged, writing nothing, unless
+// trackIDs is exactly the crate's current set of tracks. Trashed tracks
+// keep their positions, to return to if restored.
+func (r *Repository) ApplyOrder(ctx context.Context, playlistID string, trackIDs []string, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT pt.track_id FROM playlist_tracks pt
+		INNER JOIN tracks t ON t.id = pt.track_id
+		WHERE pt.playlist_id = ? AND t.deleted_at IS NULL
+	`, playlistID)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(current) != len(trackIDs) {
+		return ErrCrateChanged
+	}
+	for _, id := range trackIDs {
+		if !current[id] {
+			return ErrCrateChanged
+		}
+	}
+
+	for pos, id := range trackIDs {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE playlist_tracks SET position = ? WHERE playlist_id = ? AND track_id = ?`,
+			pos, playlistID, id,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE playlists SET updated_at = ? WHERE id = ?`, now, playlistID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,15 @@
+package sequence
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/playlists/:id/sequence")
+		{
+			r.POST("", handlers.Propose)
+			r.POST("/apply", handlers.Apply)
+		}
+	}
+}
@@ -26,6 +26,12 @@ func getAllowedOrigins() map[string]bool {
     return origins
 }
 
+// IsAllowedOrigin reports whether a browser origin is in the CORS allowlist.
+// Used by WebSocket endpoints, which CORS does not protect.
+func IsAllowedOrigin(origin string) bool {
+    return getAllowedOrigins()[origin]
+}
+
 // NewRouter constructs a Gin engine with common middleware and returns
 // both the engine and the versioned API group.
 func NewRouter() (*gin.Engine, *gin.RouterGroup) {
@@ -0,0 +1,84 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Settings are a user's display preferences.
+type Settings struct {
+	KeyNotation analysis.KeyNotation `json:"key_notation"`
+}
+
+// Defaults are the settings of a user who has never changed any.
+func Defaults() *Settings {
+	return &Settings{KeyNotation: analysis.NotationCamelot}
+}
+
+// Update is a partial change to a user's settings; nil fields are kept.
+type Update struct {
+	KeyNotation *string `json:"key_notation"`
+}
+
+// Manager keeps per-user settings. Every JSON response consults them (see
+// KeyNotationMiddleware), so they are cached in memory once read.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]Settings
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now, cache: make(map[string]Settings)}
+}
+
+// Get returns the user's settings.
+func (m *Manager) Get(ctx context.Context, userID string) (*Settings, error) {
+	m.mu.Lock()
+	s, ok := m.cache[userID]
+	m.mu.Unlock()
+	if ok {
+		return &s, nil
+	}
+	loaded, err := m.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.cache[userID] = *loaded
+	m.mu.Unlock()
+	return loaded, nil
+}
+
+// Update applies a partial change and returns the new settings.
+func (m *Manager) Update(ctx context.Context, userID string, u *Update) (*Settings, error) {
+	s, err := m.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.KeyNotation != nil {
+		n, ok := analysis.ParseKeyNotation(*u.KeyNotation)
+		if !ok {
+			return nil, fmt.Errorf("%w: key_notation must be one of camelot, open_key, standard", ErrInvalidRequest)
+		}
+		s.KeyNotation = n
+	}
+	if err := m.repo.Save(ctx, userID, s, m.now()); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.cache[userID] = *s
+	m.mu.Unlock()
+	return s, nil
+}
@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE user_settings (
+			user_id TEXT PRIMARY KEY, key_notation TEXT NOT NULL DEFAULT 'camelot', updated_at DATETIME NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB}))
+}
+
+func TestManager_Update(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	s, err := m.Get(ctx, "dj")
+	if err != nil || s.KeyNotation != analysis.NotationCamelot {
+		t.Fatalf("default settings = %+v, %v", s, err)
+	}
+
+	openKey := "open_key"
+	if _, err := m.Update(ctx, "dj", &Update{KeyNotation: &openKey}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// A fresh manager reads the saved row rather than the cache.
+	fresh := NewManager(m.repo)
+	if s, err := fresh.Get(ctx, "dj"); err != nil || s.KeyNotation != analysis.NotationOpenKey {
+		t.Errorf("saved settings = %+v, %v", s, err)
+	}
+	if s, _ := m.Get(ctx, "other"); s.KeyNotation != analysis.NotationCamelot {
+		t.Errorf("other user's notation = %q", s.KeyNotation)
+	}
+
+	bogus := "solfege"
+	if _, err := m.Update(ctx, "dj", &Update{KeyNotation: &bogus}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("bogus notation: err = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestKeyNotationMiddleware(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+	standard := "standard"
+	if _, err := m.Update(ctx, "dj", &Update{KeyNotation: &standard}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", c.GetHeader("X-User")) })
+	r.Use(KeyNotationMiddleware(m))
+	r.GET("/track", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"track": gin.H{"musical_key": "8A", "title": "musical_key"},
+			"similar": []gin.H{{"musical_key": "9B"}, {"musical_key": "unknown"}}})
+	})
+	r.GET("/audio", func(c *gin.Context) {
+		c.Data(http.StatusOK, "audio/mpeg", []byte(`"musical_key":"8A"`))
+	})
+
+	get := func(path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/track", "dj")
+	want := `{"similar":[{"musical_key":"G"},{"musical_key":"unknown"}],"track":{"musical_key":"Am","title":"musical_key"}}`
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("standard notation: %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/track", "other"); !strings.Contains(w.Body.String(), `"musical_key":"8A"`) {
+		t.Errorf("camelot user got %s", w.Body.String())
+	}
+	if w := get("/audio", "dj"); w.Body.String() != `"musical_key":"8A"` || w.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("non-JSON body was rewritten: %s", w.Body.String())
+	}
+}
+
+func TestKeyNotationMiddleware_StreamsPassThrough(t *testing.T) {
+	m := newTestManager(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "dj") })
+	r.Use(KeyNotationMiddleware(m))
+
+	w := httptest.NewRecorder()
+	var streamed bool
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "audio/mpeg")
+		c.Header("Content-Range", "bytes 0-7/100")
+		c.Status(http.StatusPartialContent)
+		c.Writer.Write([]byte("ID3\x04"))
+		// The first chunk reaches the client before the handler returns.
+		streamed = w.Body.String() == "ID3\x04"
+		c.Writer.Write([]byte("\x00\x00\x00\x00"))
+	})
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+
+	if !streamed {
+		t.Error("stream was buffered")
+	}
+	if w.Code != http.StatusPartialContent || w.Body.Len() != 8 || w.Header().Get("Content-Range") != "bytes 0-7/100" {
+		t.Errorf("stream = %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if _, ok := m.cache["dj"]; ok {
+		t.Error("settings were looked up for a non-JSON response")
+	}
+}
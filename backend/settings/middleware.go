@@ -0,0 +1,73 @@
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/gin-gonic/gin"
+)
+
+// musicalKeyRE matches a musical_key field in encoding/json's compact
+// output. Keys are stored as Camelot, so the value never needs escaping.
+var musicalKeyRE = regexp.MustCompile(`"musical_key":"([^"\\]*)"`)
+
+// KeyNotationMiddleware writes every musical_key in a JSON response in the
+// user's preferred notation. Handlers and the database only ever see
+// Camelot; the conversion happens once the response is serialized, so no
+// endpoint has to know about notations. The user's notation is only looked
+// up once a JSON body is written, so responses that are not JSON, such as
+// audio streams, pass straight through without a lookup or buffering.
+func KeyNotationMiddleware(m *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &keyWriter{ResponseWriter: c.Writer, notation: func() analysis.KeyNotation {
+			userID := c.GetString("user_id")
+			s, err := m.Get(c.Request.Context(), userID)
+			if err != nil {
+				fmt.Printf("[Settings] Failed to load settings for %s: %v\n", userID, err)
+				return analysis.NotationCamelot
+			}
+			return s.KeyNotation
+		}}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.buf != nil {
+			w.ResponseWriter.Write(musicalKeyRE.ReplaceAllFunc(w.buf.Bytes(), func(field []byte) []byte {
+				key := string(musicalKeyRE.FindSubmatch(field)[1])
+				return []byte(`"musical_key":"` + analysis.FormatKey(key, w.target) + `"`)
+			}))
+		}
+	}
+}
+
+// keyWriter holds back a JSON body so its keys can be rewritten, when the
+// user's notation isn't Camelot.
+type keyWriter struct {
+	gin.ResponseWriter
+	notation func() analysis.KeyNotation
+	target   analysis.KeyNotation
+	buf      *bytes.Buffer // non-nil once a JSON body is being held back
+	decided  bool
+}
+
+func (w *keyWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			if w.target = w.notation(); w.target != analysis.NotationCamelot {
+				w.buf = &bytes.Buffer{}
+			}
+		}
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *keyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
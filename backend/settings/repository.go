@@ -0,0 +1,41 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Get returns the user's settings, or the defaults if they have never
+// saved any.
+func (r *Repository) Get(ctx context.Context, userID string) (*Settings, error) {
+	var s Settings
+	err := r.db.QueryRowContext(ctx, `SELECT key_notation FROM user_settings WHERE user_id = ?`, userID).
+		Scan(&s.KeyNotation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Defaults(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repository) Save(ctx context.Context, userID string, s *Settings, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_settings (user_id, key_notation, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET key_notation = excluded.key_notation, updated_at = excluded.updated_at
+	`, userID, s.KeyNotation, now)
+	return err
+}
@@ -0,0 +1,15 @@
+package settings
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/settings")
+		{
+			r.GET("", handlers.Get)
+			r.PATCH("", handlers.Update)
+		}
+	}
+}
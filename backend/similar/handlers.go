@@ -0,0 +1,129 @@
+package similar
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, ErrTrackNotFound):
+		return http.StatusNotFound, "track_not_found"
+	case errors.Is(err, ErrRadioNotFound):
+		return http.StatusNotFound, "radio_not_found"
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden, "access_denied"
+	case errors.Is(err, ErrNotIndexed):
+		return http.StatusUnprocessableEntity, "not_indexed"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+// intQuery reads an integer query parameter, falling back to def when it is
+// absent. Malformed values come back as 0, which the manager rejects.
+func intQuery(c *gin.Context, name string, def int) int {
+	s := c.Query(name)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func (h *Handlers) Similar(c *gin.Context) {
+	track, similar, err := h.manager.Similar(c.Request.Context(), c.Param("id"),
+		c.GetString("user_id"), c.GetString("user_role"), intQuery(c, "limit", DefaultSimilar))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"track": track, "similar": similar})
+}
+
+type startRadioRequest struct {
+	SeedTrackID string `json:"seed_track_id" binding:"required"`
+	Count       int    `json:"count"`
+}
+
+func (h *Handlers) StartRadio(c *gin.Context) {
+	var req startRadioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	if req.Count == 0 {
+		req.Count = DefaultBatch
+	}
+	radio, tracks, err := h.manager.StartRadio(c.Request.Context(), req.SeedTrackID,
+		c.GetString("user_id"), c.GetString("user_role"), req.Count)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"radio": radio, "tracks": tracks})
+}
+
+func (h *Handlers) GetRadio(c *gin.Context) {
+	radio, err := h.manager.GetRadio(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"radio": radio})
+}
+
+// NextRadio hands out the radio's next tracks. Clients call it as their
+// queue runs low; there is no end.
+func (h *Handlers) NextRadio(c *gin.Context) {
+	tracks, err := h.manager.NextRadio(c.Request.Context(), c.Param("id"),
+		c.GetString("user_id"), intQuery(c, "count", DefaultBatch))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tracks": tracks})
+}
+
+func (h *Handlers) StopRadio(c *gin.Context) {
+	if err := h.manager.StopRadio(c.Param("id"), c.GetString("user_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handlers) IndexStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"index": h.manager.Status()})
+}
+
+// RebuildIndex rebuilds the index now, even if nothing changed.
+func (h *Handlers) RebuildIndex(c *gin.Context) {
+	if _, err := h.manager.Refresh(c.Request.Context(), true); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"index": h.manager.Status()})
+}
@@ -0,0 +1,111 @@
+package similar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// feature builds a track whose timbre is a scaled copy of base.
+func feature(id, owner string, timbre float64, bpm float64, key string, energy int) *Features {
+	mfcc := make([]float64, 13)
+	for i := range mfcc {
+		mfcc[i] = timbre * float64(i%4-1)
+	}
+	return &Features{
+		TrackID: id, OwnerUserID: owner, MFCC: mfcc,
+		SpectralCentroid: 1000 + 200*timbre, SpectralRolloff: 3000 + 500*timbre, SpectralFlux: 0.05 + 0.01*timbre,
+		OnsetRate: 2 + timbre, Danceability: 0.5, DynamicComplexity: 3,
+		BPM: bpm, MusicalKey: key, Energy: energy,
+	}
+}
+
+func testIndex() *Index {
+	return BuildIndex([]*Features{
+		feature("house1", "dj", 1, 124, "8A", 6),
+		feature("house2", "dj", 1.1, 125, "9A", 6),
+		feature("house3", "dj", 1.2, 126, "8B", 7),
+		feature("ambient", "dj", 8, 80, "2B", 2),
+		feature("dnb", "dj", 5, 174, "4A", 9),
+		feature("theirs", "other", 1, 124, "8A", 6),
+	}, "sig", time.Unix(1700000000, 0))
+}
+
+func TestRawVector_TempoOctaveAndKeyWheel(t *testing.T) {
+	a := rawVector(&Features{BPM: 87})
+	b := rawVector(&Features{BPM: 174})
+	if d := a[dimTempo] - b[dimTempo]; d > 1e-9 || d < -1e-9 {
+		t.Errorf("half-time tempos should coincide: %v vs %v", a[dimTempo:dimKey], b[dimTempo:dimKey])
+	}
+	k12 := rawVector(&Features{MusicalKey: "12A"})
+	k1 := rawVector(&Features{MusicalKey: "1A"})
+	k6 := rawVector(&Features{MusicalKey: "6A"})
+	near := (k12[dimKey]-k1[dimKey])*(k12[dimKey]-k1[dimKey]) + (k12[dimKey+1]-k1[dimKey+1])*(k12[dimKey+1]-k1[dimKey+1])
+	far := (k12[dimKey]-k6[dimKey])*(k12[dimKey]-k6[dimKey]) + (k12[dimKey+1]-k6[dimKey+1])*(k12[dimKey+1]-k6[dimKey+1])
+	if near >= far {
+		t.Errorf("12A should sit next to 1A on the wheel: %v vs %v", near, far)
+	}
+}
+
+func TestIndex_Nearest(t *testing.T) {
+	ix := testIndex()
+	q, owner, ok := ix.Vector("house1")
+	if !ok || owner != "dj" {
+		t.Fatalf("Vector(house1) = %v, %q, %v", q, owner, ok)
+	}
+	got := ix.Nearest("dj", q, 3, func(id string) bool { return id == "house1" })
+	if len(got) != 3 || got[0].TrackID != "house2" || got[1].TrackID != "house3" {
+		t.Fatalf("Nearest = %+v, want house2, house3 first", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Distance < got[i-1].Distance {
+			t.Errorf("results out of order: %+v", got)
+		}
+	}
+	for _, n := range ix.Nearest("dj", q, 10, nil) {
+		if n.TrackID == "theirs" {
+			t.Error("search crossed into another user's library")
+		}
+	}
+	if Score(0) != 100 || Score(got[2].Distance) >= Score(got[0].Distance) {
+		t.Errorf("scores: %v, %v", Score(got[0].Distance), Score(got[2].Distance))
+	}
+}
+
+func TestIndex_FileRoundTrip(t *testing.T) {
+	ix := testIndex()
+	path := filepath.Join(t.TempDir(), "sub", "similarity.idx")
+	if err := ix.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	loaded, err := ReadIndexFile(path)
+	if err != nil {
+		t.Fatalf("ReadIndexFile: %v", err)
+	}
+	if loaded.Signature != "sig" || !loaded.BuiltAt.Equal(ix.BuiltAt) || loaded.Len() != ix.Len() {
+		t.Fatalf("loaded = %q %v %d", loaded.Signature, loaded.BuiltAt, loaded.Len())
+	}
+	for id := range ix.tracks {
+		a, oa, _ := ix.Vector(id)
+		b, ob, ok := loaded.Vector(id)
+		if !ok || oa != ob || distance(a, b) != 0 {
+			t.Errorf("track %s differs after reload", id)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadIndexFile(path); !errors.Is(err, errIndexFormat) {
+		t.Errorf("truncated: err = %v, want errIndexFormat", err)
+	}
+	if err := os.WriteFile(path, []byte("CDSIMIX0garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadIndexFile(path); !errors.Is(err, errIndexFormat) {
+		t.Errorf("old format: err = %v, want errIndexFormat", err)
+	}
+}
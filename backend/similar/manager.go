@@ -0,0 +1,194 @@
+package similar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrNotIndexed     = errors.New("track has no sonic features indexed yet")
+	ErrRadioNotFound  = errors.New("radio session not found")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Search and radio limits.
+const (
+	DefaultSimilar = 20
+	MaxSimilar     = 100
+	DefaultBatch   = 5
+	MaxBatch       = 25
+	// radioMemory is how many recent picks steer a radio alongside its
+	// seed, so it drifts with the music but never far from where it began.
+	radioMemory = 5
+	// radioChoices is how many of the nearest unplayed tracks a radio picks
+	// from at random, so two radios from one seed don't play the same list;
+	// only those within radioSpread times the nearest one's distance count.
+	radioChoices     = 3
+	radioSpread      = 1.5
+	radioIdleTimeout = 2 * time.Hour
+	maxUserRadios    = 4
+)
+
+// SimilarTrack is a search result. Score runs from 0 to 100.
+type SimilarTrack struct {
+	Track    *Track  `json:"track"`
+	Distance float64 `json:"distance"`
+	Score    float64 `json:"score"`
+}
+
+// IndexStatus describes the loaded index.
+type IndexStatus struct {
+	Tracks  int       `json:"tracks"`
+	BuiltAt time.Time `json:"built_at"`
+	Path    string    `json:"path"`
+}
+
+// Manager serves similarity searches from an in-memory index that it keeps
+// on disk at path and rebuilds as analysis lands, and runs "more like this"
+// radios over it.
+type Manager struct {
+	repo *Repository
+	path string
+	now  func() time.Time
+
+	index     atomic.Pointer[Index]
+	rebuildMu sync.Mutex
+	wake      chan struct{}
+
+	mu     sync.Mutex
+	radios map[string]*radioSession
+	rng    *rand.Rand
+}
+
+func NewManager(repo *Repository, path string) *Manager {
+	m := &Manager{
+		repo:   repo,
+		path:   path,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		radios: make(map[string]*radioSession),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	m.index.Store(newIndex("", time.Time{}))
+	return m
+}
+
+// Notify tells the index loop that analysis results changed. It never
+// blocks; bursts collapse into one rebuild.
+func (m *Manager) Notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Load reads the index file written by an earlier run, if there is one.
+func (m *Manager) Load() error {
+	ix, err := ReadIndexFile(m.path)
+	if err != nil {
+		return err
+	}
+	m.index.Store(ix)
+	return nil
+}
+
+// Refresh rebuilds the index if the analyzed library changed since it was
+// built, or unconditionally with force, and saves it. Returns whether it
+// rebuilt.
+func (m *Manager) Refresh(ctx context.Context, force bool) (bool, error) {
+	m.rebuildMu.Lock()
+	defer m.rebuildMu.Unlock()
+
+	signature, err := m.repo.Signature(ctx)
+	if err != nil {
+		return false, fmt.Errorf("read index signature: %w", err)
+	}
+	if !force && signature == m.index.Load().Signature {
+		return false, nil
+	}
+	features, err := m.repo.LoadFeatures(ctx)
+	if err != nil {
+		return false, fmt.Errorf("load features: %w", err)
+	}
+	ix := BuildIndex(features, signature, m.now())
+	m.index.Store(ix)
+	if err := ix.WriteFile(m.path); err != nil {
+		return true, fmt.Errorf("save index: %w", err)
+	}
+	return true, nil
+}
+
+// Status describes the loaded index.
+func (m *Manager) Status() IndexStatus {
+	ix := m.index.Load()
+	return IndexStatus{Tracks: ix.Len(), BuiltAt: ix.BuiltAt, Path: m.path}
+}
+
+// source loads a track the user may search from and its indexed vector.
+func (m *Manager) source(ctx context.Context, trackID, userID, userRole string) (*Track, []float32, error) {
+	track, err := m.repo.GetTrack(ctx, trackID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userRole != "admin" && track.OwnerUserID != userID {
+		return nil, nil, ErrAccessDenied
+	}
+	vec, _, ok := m.index.Load().Vector(track.ID)
+	if !ok {
+		return nil, nil, ErrNotIndexed
+	}
+	return track, vec, nil
+}
+
+// results loads the tracks behind neighbours, dropping any deleted since
+// the index was built.
+func (m *Manager) results(ctx context.Context, neighbors []Neighbor) ([]*SimilarTrack, error) {
+	ids := make([]string, len(neighbors))
+	for i, n := range neighbors {
+		ids[i] = n.TrackID
+	}
+	tracks, err := m.repo.GetTracks(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load tracks: %w", err)
+	}
+	out := []*SimilarTrack{}
+	for _, n := range neighbors {
+		if t, ok := tracks[n.TrackID]; ok {
+			out = append(out, &SimilarTrack{Track: t, Distance: round3(n.Distance), Score: Score(n.Distance)})
+		}
+	}
+	return out, nil
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// Similar returns the tracks in the owner's library that sound most like
+// trackID, nearest first.
+func (m *Manager) Similar(ctx context.Context, trackID, userID, userRole string, limit int) (*Track, []*SimilarTrack, error) {
+	if limit < 1 || limit > MaxSimilar {
+		return nil, nil, fmt.Errorf("%w: limit must be from 1 to %d", ErrInvalidRequest, MaxSimilar)
+	}
+	track, vec, err := m.source(ctx, trackID, userID, userRole)
+	if err != nil {
+		return nil, nil, err
+	}
+	neighbors := m.index.Load().Nearest(track.OwnerUserID, vec, limit, func(id string) bool { return id == track.ID })
+	similar, err := m.results(ctx, neighbors)
+	return track, similar, err
+}
+
+// IndexFilePath is where the index lives under the data directory.
+func IndexFilePath(dataDir string) string {
+	return filepath.Join(dataDir, "similarity.idx")
+}
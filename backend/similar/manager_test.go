@@ -0,0 +1,181 @@
+package similar
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) (*Manager, *sql.DB) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT NOT NULL, title TEXT, artist TEXT, album TEXT, genre TEXT,
+			duration_seconds REAL, bpm REAL, musical_key TEXT, energy INTEGER, mood TEXT,
+			danceability REAL, dynamic_complexity REAL, loudness_lufs REAL, spectral_centroid REAL, onset_rate REAL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, deleted_at DATETIME
+		);
+		CREATE TABLE track_features (
+			track_id TEXT PRIMARY KEY, mfcc BLOB NOT NULL, spectral_rolloff REAL, spectral_flux REAL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	for _, f := range []*Features{
+		feature("house1", "dj", 1, 124, "8A", 6),
+		feature("house2", "dj", 1.1, 125, "9A", 6),
+		feature("house3", "dj", 1.2, 126, "8B", 7),
+		feature("house4", "dj", 1.3, 124, "7A", 6),
+		feature("ambient", "dj", 8, 80, "2B", 2),
+		feature("theirs", "other", 1, 124, "8A", 6),
+	} {
+		seedFeatures(t, sqlDB, f)
+	}
+	// Analyzed without essentia: no features, so not indexed.
+	if _, err := sqlDB.Exec(`INSERT INTO tracks (id, owner_user_id, bpm) VALUES ('plain', 'dj', 124)`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "similarity.idx")
+	return NewManager(NewRepository(&db.DB{DB: sqlDB}), path), sqlDB
+}
+
+func seedFeatures(t *testing.T, sqlDB *sql.DB, f *Features) {
+	t.Helper()
+	_, err := sqlDB.Exec(`
+		INSERT INTO tracks (id, owner_user_id, title, bpm, musical_key, energy, danceability,
+			dynamic_complexity, spectral_centroid, onset_rate)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.TrackID, f.OwnerUserID, f.TrackID, f.BPM, f.MusicalKey, f.Energy, f.Danceability,
+		f.DynamicComplexity, f.SpectralCentroid, f.OnsetRate)
+	if err != nil {
+		t.Fatalf("seed track: %v", err)
+	}
+	_, err = sqlDB.Exec(`INSERT INTO track_features (track_id, mfcc, spectral_rolloff, spectral_flux) VALUES (?, ?, ?, ?)`,
+		f.TrackID, analysis.EncodeMFCC(f.MFCC), f.SpectralRolloff, f.SpectralFlux)
+	if err != nil {
+		t.Fatalf("seed features: %v", err)
+	}
+}
+
+func TestManager_RefreshAndSimilar(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+
+	if _, _, err := m.Similar(ctx, "house1", "dj", "user", 3); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("before build: err = %v, want ErrNotIndexed", err)
+	}
+	rebuilt, err := m.Refresh(ctx, false)
+	if err != nil || !rebuilt {
+		t.Fatalf("Refresh = %v, %v", rebuilt, err)
+	}
+	if rebuilt, _ := m.Refresh(ctx, false); rebuilt {
+		t.Error("Refresh rebuilt an unchanged library")
+	}
+
+	track, similar, err := m.Similar(ctx, "house1", "dj", "user", 3)
+	if err != nil {
+		t.Fatalf("Similar: %v", err)
+	}
+	if track.ID != "house1" || len(similar) != 3 || similar[0].Track.ID != "house2" {
+		t.Fatalf("Similar = %v, %+v", track.ID, similar)
+	}
+	for _, s := range similar {
+		if s.Track.ID == "ambient" || s.Track.ID == "theirs" {
+			t.Errorf("unexpected neighbour %s", s.Track.ID)
+		}
+	}
+
+	cases := []struct {
+		name, id, user, role string
+		want                 error
+	}{
+		{"missing", "nope", "dj", "user", ErrTrackNotFound},
+		{"someone else's", "theirs", "dj", "user", ErrAccessDenied},
+		{"no features", "plain", "dj", "user", ErrNotIndexed},
+	}
+	for _, tc := range cases {
+		if _, _, err := m.Similar(ctx, tc.id, tc.user, tc.role, 3); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if _, similar, err := m.Similar(ctx, "theirs", "admin-id", "admin", 3); err != nil || len(similar) != 0 {
+		t.Errorf("admin search of a one-track library = %+v, %v", similar, err)
+	}
+
+	// Deleting a track changes the signature, and a fresh manager picks the
+	// saved index up from disk.
+	if _, err := sqlDB.Exec(`DELETE FROM track_features WHERE track_id = 'house4'`); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt, err := m.Refresh(ctx, false); err != nil || !rebuilt {
+		t.Fatalf("Refresh after delete = %v, %v", rebuilt, err)
+	}
+	fresh := NewManager(m.repo, m.path)
+	if err := fresh.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if st := fresh.Status(); st.Tracks != 5 {
+		t.Errorf("loaded index has %d tracks, want 5", st.Tracks)
+	}
+}
+
+func TestManager_Radio(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	if _, err := m.Refresh(ctx, false); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	radio, first, err := m.StartRadio(ctx, "house1", "dj", "user", 3)
+	if err != nil {
+		t.Fatalf("StartRadio: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, s := range first {
+		if s.Track.ID == "house1" || seen[s.Track.ID] || s.Track.OwnerUserID != "dj" {
+			t.Errorf("bad pick %s in %v", s.Track.ID, first)
+		}
+		seen[s.Track.ID] = true
+		if s.Track.ID == "ambient" {
+			t.Error("the odd one out was picked before similar tracks")
+		}
+	}
+
+	// The library holds four other tracks; the radio plays the last one, then
+	// starts over without repeating its recent picks back to back.
+	next, err := m.NextRadio(ctx, radio.ID, "dj", 1)
+	if err != nil || len(next) != 1 || seen[next[0].Track.ID] {
+		t.Fatalf("NextRadio = %+v, %v", next, err)
+	}
+	more, err := m.NextRadio(ctx, radio.ID, "dj", 2)
+	if err != nil || len(more) == 0 {
+		t.Fatalf("radio ended: %+v, %v", more, err)
+	}
+
+	if _, err := m.NextRadio(ctx, radio.ID, "someone", 1); !errors.Is(err, ErrRadioNotFound) {
+		t.Errorf("other user: err = %v, want ErrRadioNotFound", err)
+	}
+	if _, err := m.NextRadio(ctx, radio.ID, "dj", MaxBatch+1); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("big batch: err = %v, want ErrInvalidRequest", err)
+	}
+	if err := m.StopRadio(radio.ID, "dj"); err != nil {
+		t.Fatalf("StopRadio: %v", err)
+	}
+	if _, err := m.GetRadio(radio.ID, "dj"); !errors.Is(err, ErrRadioNotFound) {
+		t.Errorf("after stop: err = %v, want ErrRadioNotFound", err)
+	}
+}
@@ -0,0 +1,189 @@
+package similar
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// radioSession is one listener's endless "more like this" radio. Sessions
+// live in memory only and lapse after radioIdleTimeout without a request.
+type radioSession struct {
+	id       string
+	userID   string
+	owner    string // library the radio draws from
+	seedID   string
+	seed     []float32
+	played   map[string]bool // since the library last started over; the seed counts
+	recent   []string        // last radioMemory picks, oldest first
+	count    int             // tracks handed out in all
+	lastUsed time.Time
+}
+
+// Radio is the JSON view of a radio session.
+type Radio struct {
+	ID          string `json:"id"`
+	SeedTrackID string `json:"seed_track_id"`
+	Played      int    `json:"played"`
+}
+
+func (s *radioSession) info() *Radio {
+	return &Radio{ID: s.id, SeedTrackID: s.seedID, Played: s.count}
+}
+
+// query steers the radio: halfway between the seed and the recent picks.
+func (s *radioSession) query(ix *Index) []float32 {
+	var recent [][]float32
+	for _, id := range s.recent {
+		if v, _, ok := ix.Vector(id); ok {
+			recent = append(recent, v)
+		}
+	}
+	if len(recent) == 0 {
+		return s.seed
+	}
+	return Centroid(s.seed, Centroid(recent...))
+}
+
+// pick chooses the next track: one of the few nearest unplayed tracks
+// that are nearly as close as the nearest. Once the whole library has
+// played, it starts over, only keeping the last pick from playing twice in
+// a row. Returns "" if the library has nothing else to play.
+func (m *Manager) pick(s *radioSession, ix *Index) string {
+	skip := func(id string) bool { return s.played[id] }
+	choices := ix.Nearest(s.owner, s.query(ix), radioChoices, skip)
+	if len(choices) == 0 && len(s.recent) > 0 {
+		s.played = map[string]bool{s.recent[len(s.recent)-1]: true}
+		choices = ix.Nearest(s.owner, s.query(ix), radioChoices, skip)
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	n := 1
+	for n < len(choices) && choices[n].Distance <= choices[0].Distance*radioSpread+1e-6 {
+		n++
+	}
+	id := choices[m.rng.Intn(n)].TrackID
+	s.played[id] = true
+	s.count++
+	s.recent = append(s.recent, id)
+	if len(s.recent) > radioMemory {
+		s.recent = s.recent[1:]
+	}
+	return id
+}
+
+// expireRadios drops idle sessions. The caller holds m.mu.
+func (m *Manager) expireRadios() {
+	cutoff := m.now().Add(-radioIdleTimeout)
+	for id, s := range m.radios {
+		if s.lastUsed.Before(cutoff) {
+			delete(m.radios, id)
+		}
+	}
+}
+
+// StartRadio starts a radio seeded by trackID and returns it with its first
+// count tracks. A user's oldest radio is dropped once they have
+// maxUserRadios.
+func (m *Manager) StartRadio(ctx context.Context, trackID, userID, userRole string, count int) (*Radio, []*SimilarTrack, error) {
+	if count < 1 || count > MaxBatch {
+		return nil, nil, fmt.Errorf("%w: count must be from 1 to %d", ErrInvalidRequest, MaxBatch)
+	}
+	track, vec, err := m.source(ctx, trackID, userID, userRole)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.Lock()
+	m.expireRadios()
+	var oldest *radioSession
+	n := 0
+	for _, s := range m.radios {
+		if s.userID == userID {
+			n++
+			if oldest == nil || s.lastUsed.Before(oldest.lastUsed) {
+				oldest = s
+			}
+		}
+	}
+	if n >= maxUserRadios {
+		delete(m.radios, oldest.id)
+	}
+	s := &radioSession{
+		id:       uuid.New().String(),
+		userID:   userID,
+		owner:    track.OwnerUserID,
+		seedID:   track.ID,
+		seed:     vec,
+		played:   map[string]bool{track.ID: true},
+		lastUsed: m.now(),
+	}
+	m.radios[s.id] = s
+	m.mu.Unlock()
+
+	tracks, err := m.NextRadio(ctx, s.id, userID, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return s.info(), tracks, nil
+}
+
+// NextRadio returns the radio's next count tracks and counts them as
+// played.
+func (m *Manager) NextRadio(ctx context.Context, radioID, userID string, count int) ([]*SimilarTrack, error) {
+	if count < 1 || count > MaxBatch {
+		return nil, fmt.Errorf("%w: count must be from 1 to %d", ErrInvalidRequest, MaxBatch)
+	}
+	ix := m.index.Load()
+
+	m.mu.Lock()
+	m.expireRadios()
+	s, ok := m.radios[radioID]
+	if !ok || s.userID != userID {
+		m.mu.Unlock()
+		return nil, ErrRadioNotFound
+	}
+	s.lastUsed = m.now()
+	query := s.seed
+	var neighbors []Neighbor
+	for i := 0; i < count; i++ {
+		id := m.pick(s, ix)
+		if id == "" {
+			break
+		}
+		v, _, _ := ix.Vector(id)
+		neighbors = append(neighbors, Neighbor{TrackID: id, Distance: distance(query, v)})
+	}
+	m.mu.Unlock()
+
+	return m.results(ctx, neighbors)
+}
+
+// GetRadio returns one of the user's radios.
+func (m *Manager) GetRadio(radioID, userID string) (*Radio, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireRadios()
+	s, ok := m.radios[radioID]
+	if !ok || s.userID != userID {
+		return nil, ErrRadioNotFound
+	}
+	return s.info(), nil
+}
+
+// StopRadio ends one of the user's radios.
+func (m *Manager) StopRadio(radioID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.radios[radioID]
+	if !ok || s.userID != userID {
+		return ErrRadioNotFound
+	}
+	delete(m.radios, radioID)
+	return nil
+}
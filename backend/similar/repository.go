@@ -0,0 +1,137 @@
+package similar
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Track is the subset of a track row similarity results show.
+type Track struct {
+	ID              string   `json:"id"`
+	OwnerUserID     string   `json:"owner_user_id"`
+	Title           *string  `json:"title,omitempty"`
+	Artist          *string  `json:"artist,omitempty"`
+	Album           *string  `json:"album,omitempty"`
+	Genre           *string  `json:"genre,omitempty"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	BPM             *float64 `json:"bpm,omitempty"`
+	MusicalKey      *string  `json:"musical_key,omitempty"`
+	Energy          *int     `json:"energy,omitempty"`
+	Mood            *string  `json:"mood,omitempty"`
+}
+
+const trackColumns = `id, owner_user_id, title, artist, album, genre, duration_seconds, bpm, musical_key, energy, mood`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTrack(row rowScanner) (*Track, error) {
+	var t Track
+	err := row.Scan(&t.ID, &t.OwnerUserID, &t.Title, &t.Artist, &t.Album, &t.Genre,
+		&t.DurationSeconds, &t.BPM, &t.MusicalKey, &t.Energy, &t.Mood)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Repository) GetTrack(ctx context.Context, id string) (*Track, error) {
+	t, err := scanTrack(r.db.QueryRowContext(ctx, `SELECT `+trackColumns+` FROM tracks WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTrackNotFound
+	}
+	return t, err
+}
+
+// GetTracks loads tracks by ID. Missing and trashed IDs are left out of
+// the map.
+func (r *Repository) GetTracks(ctx context.Context, ids []string) (map[string]*Track, error) {
+	out := make(map[string]*Track, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+trackColumns+` FROM tracks WHERE deleted_at IS NULL AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[t.ID] = t
+	}
+	return out, rows.Err()
+}
+
+// Signature summarizes the indexed rows: it changes whenever a track gains,
+// loses or changes features, or an indexed track is edited, trashed or
+// deleted.
+func (r *Repository) Signature(ctx context.Context) (string, error) {
+	var count int
+	var features, tracks string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(f.updated_at), ''), COALESCE(MAX(t.updated_at), '')
+		FROM track_features f
+		INNER JOIN tracks t ON t.id = f.track_id
+		WHERE t.deleted_at IS NULL
+	`).Scan(&count, &features, &tracks)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d|%s|%s", count, features, tracks), nil
+}
+
+// LoadFeatures returns the features of every track that has them.
+func (r *Repository) LoadFeatures(ctx context.Context) ([]*Features, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.owner_user_id, f.mfcc,
+		       COALESCE(t.spectral_centroid, 0), COALESCE(f.spectral_rolloff, 0), COALESCE(f.spectral_flux, 0),
+		       COALESCE(t.onset_rate, 0), COALESCE(t.danceability, 0), COALESCE(t.dynamic_complexity, 0),
+		       t.loudness_lufs, COALESCE(t.bpm, 0), COALESCE(t.musical_key, ''), COALESCE(t.energy, 0)
+		FROM track_features f
+		INNER JOIN tracks t ON t.id = f.track_id
+		WHERE t.deleted_at IS NULL
+		ORDER BY t.owner_user_id, t.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Features
+	for rows.Next() {
+		var f Features
+		var mfcc []byte
+		if err := rows.Scan(&f.TrackID, &f.OwnerUserID, &mfcc,
+			&f.SpectralCentroid, &f.SpectralRolloff, &f.SpectralFlux,
+			&f.OnsetRate, &f.Danceability, &f.DynamicComplexity,
+			&f.LoudnessLUFS, &f.BPM, &f.MusicalKey, &f.Energy); err != nil {
+			return nil, err
+		}
+		f.MFCC = analysis.DecodeMFCC(mfcc)
+		out = append(out, &f)
+	}
+	return out, rows.Err()
+}
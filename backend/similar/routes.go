@@ -0,0 +1,27 @@
+package similar
+
+import (
+	"github.com/faraz525/home-music-server/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		rg.GET("/tracks/:id/similar", handlers.Similar)
+
+		r := rg.Group("/similar")
+		{
+			r.POST("/radio", handlers.StartRadio)
+			r.GET("/radio/:id", handlers.GetRadio)
+			r.GET("/radio/:id/next", handlers.NextRadio)
+			r.DELETE("/radio/:id", handlers.StopRadio)
+
+			admin := r.Group("")
+			admin.Use(auth.AdminMiddleware())
+			admin.GET("/index", handlers.IndexStatus)
+			admin.POST("/index/rebuild", handlers.RebuildIndex)
+		}
+	}
+}
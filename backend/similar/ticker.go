@@ -0,0 +1,54 @@
+package similar
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// settleDelay lets a burst of analyses finish before rebuilding, so a
+// backfill rebuilds the index a few times rather than once per track.
+const settleDelay = 30 * time.Second
+
+// StartLoop keeps the index current. It loads the saved index, then
+// rebuilds whenever analysis lands (see Manager.Notify) and on interval as
+// a safety net for edits and deletions, each time only if the analyzed
+// library actually changed.
+func StartLoop(ctx context.Context, m *Manager, interval time.Duration) {
+	if err := m.Load(); err != nil {
+		fmt.Printf("[Similar] No usable index at %s (%v), building one\n", m.path, err)
+	} else {
+		fmt.Printf("[Similar] Loaded index of %d track(s) from %s\n", m.index.Load().Len(), m.path)
+	}
+	refresh := func() {
+		start := time.Now()
+		rebuilt, err := m.Refresh(ctx, false)
+		if err != nil {
+			fmt.Printf("[Similar] Index rebuild failed: %v\n", err)
+			return
+		}
+		if rebuilt {
+			fmt.Printf("[Similar] Rebuilt index: %d track(s) in %s\n", m.index.Load().Len(), time.Since(start).Round(time.Millisecond))
+		}
+	}
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("[Similar] Index loop stopped")
+			return
+		case <-m.wake:
+			select {
+			case <-ctx.Done():
+				fmt.Println("[Similar] Index loop stopped")
+				return
+			case <-time.After(settleDelay):
+			}
+		case <-ticker.C:
+		}
+		refresh()
+	}
+}
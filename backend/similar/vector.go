@@ -0,0 +1,162 @@
+package similar
+
+import (
+	"math"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+)
+
+// Features is everything the index knows about one analyzed track.
+type Features struct {
+	TrackID           string
+	OwnerUserID       string
+	MFCC              []float64 // analysis.MFCCBands means
+	SpectralCentroid  float64
+	SpectralRolloff   float64
+	SpectralFlux      float64
+	OnsetRate         float64
+	Danceability      float64
+	DynamicComplexity float64
+	LoudnessLUFS      *float64
+	BPM               float64 // 0 if unknown
+	MusicalKey        string  // Camelot; "" if unknown
+	Energy            int     // 0 if unknown
+}
+
+// Vector layout. MFCC 0 tracks overall level, which loudness already
+// covers, so timbre uses bands 1-12. Tempo and key sit on circles: tempo by
+// octave, so a track and its half-time twin coincide, and key round the
+// Camelot wheel, so 12A is next to 1A.
+const (
+	dimTimbre    = 0  // 12 MFCC means
+	dimSpectral  = 12 // log centroid, log rolloff, flux
+	dimRhythm    = 15 // onset rate, danceability, dynamic complexity, loudness
+	dimTempo     = 19 // cos, sin
+	dimKey       = 21 // cos, sin, mode
+	dimEnergy    = 24
+	Dims         = 25
+	keyModeScale = 0.5 // relative major/minor sits half a wheel step apart
+)
+
+// group weights balance the feature families so the twelve timbre bands
+// don't drown out tempo and key. Each dimension gets its group's weight
+// divided by the square root of the group's size.
+var groups = []struct {
+	from, to int
+	weight   float64
+	circular bool // already unit scale; not z-scored
+}{
+	{dimTimbre, dimSpectral, 1.0, false},
+	{dimSpectral, dimRhythm, 0.5, false},
+	{dimRhythm, dimTempo, 0.6, false},
+	{dimTempo, dimKey, 0.8, true},
+	{dimKey, dimEnergy, 0.5, true},
+	{dimEnergy, Dims, 0.6, false},
+}
+
+// rawVector lays out a track's features. Unknown values are NaN and end up
+// at the library average once normalized.
+func rawVector(f *Features) []float64 {
+	v := make([]float64, Dims)
+	for i := range v {
+		v[i] = math.NaN()
+	}
+	if len(f.MFCC) == analysis.MFCCBands {
+		copy(v[dimTimbre:dimSpectral], f.MFCC[1:])
+	}
+	if f.SpectralCentroid > 0 {
+		v[dimSpectral] = math.Log(f.SpectralCentroid)
+	}
+	if f.SpectralRolloff > 0 {
+		v[dimSpectral+1] = math.Log(f.SpectralRolloff)
+	}
+	v[dimSpectral+2] = f.SpectralFlux
+	v[dimRhythm] = f.OnsetRate
+	v[dimRhythm+1] = f.Danceability
+	v[dimRhythm+2] = f.DynamicComplexity
+	if f.LoudnessLUFS != nil {
+		v[dimRhythm+3] = *f.LoudnessLUFS
+	}
+	if f.BPM > 0 {
+		_, frac := math.Modf(math.Log2(f.BPM))
+		v[dimTempo], v[dimTempo+1] = math.Cos(2*math.Pi*frac), math.Sin(2*math.Pi*frac)
+	}
+	if num, letter, ok := analysis.ParseCamelot(f.MusicalKey); ok {
+		a := 2 * math.Pi * float64(num-1) / 12
+		v[dimKey], v[dimKey+1] = math.Cos(a), math.Sin(a)
+		v[dimKey+2] = -keyModeScale
+		if letter == 'B' {
+			v[dimKey+2] = keyModeScale
+		}
+	}
+	if f.Energy > 0 {
+		v[dimEnergy] = float64(f.Energy)
+	}
+	return v
+}
+
+// normalize turns raw vectors into weighted, comparable ones in place:
+// linear dimensions are z-scored across the whole set, unknowns become 0
+// (the average, or the circle's centre), and each group is weighted.
+func normalize(raw [][]float64) [][]float32 {
+	mean := make([]float64, Dims)
+	std := make([]float64, Dims)
+	for d := 0; d < Dims; d++ {
+		var sum, sq float64
+		n := 0
+		for _, v := range raw {
+			if !math.IsNaN(v[d]) {
+				sum += v[d]
+				sq += v[d] * v[d]
+				n++
+			}
+		}
+		if n > 0 {
+			mean[d] = sum / float64(n)
+			std[d] = math.Sqrt(math.Max(0, sq/float64(n)-mean[d]*mean[d]))
+		}
+	}
+
+	weight := make([]float64, Dims)
+	circular := make([]bool, Dims)
+	for _, g := range groups {
+		for d := g.from; d < g.to; d++ {
+			weight[d] = g.weight / math.Sqrt(float64(g.to-g.from))
+			circular[d] = g.circular
+		}
+	}
+
+	out := make([][]float32, len(raw))
+	for i, v := range raw {
+		vec := make([]float32, Dims)
+		for d, x := range v {
+			switch {
+			case math.IsNaN(x):
+				x = 0
+			case circular[d]:
+			case std[d] > 1e-9:
+				x = (x - mean[d]) / std[d]
+			default:
+				x = 0
+			}
+			vec[d] = float32(x * weight[d])
+		}
+		out[i] = vec
+	}
+	return out
+}
+
+// distance is the Euclidean distance between two normalized vectors.
+func distance(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(float64(sum))
+}
+
+// Score maps a distance to a 0-100 similarity, 100 being identical.
+func Score(dist float64) float64 {
+	return math.Round(1000/(1+dist)) / 10
+}
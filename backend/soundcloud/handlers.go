@@ -2,9 +2,12 @@ package soundcloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
 	"github.com/gin-gonic/gin"
 )
 
@@ -84,7 +87,13 @@ func (h *Handlers) TriggerSync(c *gin.Context) {
 }
 
 func (h *Handlers) GetHistory(c *gin.Context) {
-	history, err := h.manager.GetSyncHistory(c.Request.Context(), 20)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	history, next, err := h.manager.GetSyncHistory(c.Request.Context(), c.Query("cursor"), limit, offset)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_cursor", "message": err.Error()}})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": err.Error()}})
 		return
@@ -94,5 +103,9 @@ func (h *Handlers) GetHistory(c *gin.Context) {
 		history = []*SyncHistory{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"history": history})
+	resp := gin.H{"history": history, "has_next": next != ""}
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	c.JSON(http.StatusOK, resp)
 }
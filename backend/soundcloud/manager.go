@@ -81,11 +81,14 @@ func (m *Manager) SaveSyncConfig(ctx context.Context, token, ownerUserID string,
 	return m.repo.UpsertSyncConfig(ctx, cfg)
 }
 
-func (m *Manager) GetSyncHistory(ctx context.Context, limit int) ([]*SyncHistory, error) {
+func (m *Manager) GetSyncHistory(ctx context.Context, cursor string, limit, offset int) ([]*SyncHistory, string, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 10
 	}
-	return m.repo.GetSyncHistory(ctx, limit)
+	if offset < 0 {
+		offset = 0
+	}
+	return m.repo.GetSyncHistory(ctx, cursor, limit, offset)
 }
 
 func (m *Manager) SyncLikes(ctx context.Context) error {
@@ -295,6 +298,7 @@ func (m *Manager) importTrack(ctx context.Context, userID string, entry Manifest
 	if md.Bitrate != nil {
 		track.Bitrate = md.Bitrate
 	}
+	track.ISRC = md.ISRC
 
 	track, err = m.tracksRepo.CreateTrack(ctx, track)
 	if err != nil {
@@ -110,11 +110,14 @@ func (m *Manager) Sync(ctx context.Context) error {
 	return m.SyncLikes(ctx)
 }
 
-func (m *Manager) GetSyncHistory(ctx context.Context, limit int) ([]*SyncHistory, error) {
+func (m *Manager) GetSyncHistory(ctx context.Context, cursor string, limit, offset int) ([]*SyncHistory, string, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 10
 	}
-	return m.repo.GetSyncHistory(ctx, limit)
+	if offset < 0 {
+		offset = 0
+	}
+	return m.repo.GetSyncHistory(ctx, cursor, limit, offset)
 }
 
 // ExchangeCodeForToken exchanges an authorization code for access and refresh tokens using PKCE
@@ -655,6 +658,12 @@ func (m *Manager) downloadAndImportTrack(ctx context.Context, userID string, st
 		track.Bitrate = md.Bitrate
 	}
 
+	if isrc := strings.ToUpper(st.ExternalIDs.ISRC); isrc != "" {
+		track.ISRC = &isrc
+	} else {
+		track.ISRC = md.ISRC
+	}
+
 	track, err = m.tracksRepo.CreateTrack(ctx, track)
 	if err != nil {
 		m.storage.Delete(ctx, relPath)
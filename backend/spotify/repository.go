@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/faraz525/home-music-server/backend/internal/db"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
 	"github.com/faraz525/home-music-server/backend/utils"
 )
 
@@ -142,26 +143,36 @@ func (r *Repository) RecordSync(ctx context.Context, started time.Time, tracksAd
 	return err
 }
 
-func (r *Repository) GetSyncHistory(ctx context.Context, limit int) ([]*SyncHistory, error) {
+// historyKeys order sync runs newest first
+var historyKeys = []pagination.Key{{Expr: "sync_started_at", Desc: true}, {Expr: "id"}}
+
+// GetSyncHistory returns a page of sync runs, newest first, starting after
+// cursor when it is set, and the next page's cursor
+func (r *Repository) GetSyncHistory(ctx context.Context, cursor string, limit, offset int) ([]*SyncHistory, string, error) {
+	after, args, err := pagination.After(cursor, historyKeys)
+	if err != nil {
+		return nil, "", err
+	}
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, sync_started_at, sync_completed_at, tracks_added, tracks_skipped, error_message
-         FROM spotify_sync_history ORDER BY sync_started_at DESC LIMIT ?`,
-		limit,
+		`SELECT id, sync_started_at, sync_completed_at, tracks_added, tracks_skipped, error_message`+pagination.Columns(historyKeys)+`
+         FROM spotify_sync_history WHERE 1 = 1`+after+` ORDER BY `+pagination.OrderBy(historyKeys)+` LIMIT ? OFFSET ?`,
+		append(args, limit+1, offset)...,
 	)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
+	sc := pagination.NewScanner(rows, historyKeys)
 	var history []*SyncHistory
 	for rows.Next() {
 		var h SyncHistory
 		var completedAt sql.NullTime
 		var errMsg sql.NullString
 
-		err := rows.Scan(&h.ID, &h.StartedAt, &completedAt, &h.TracksAdded, &h.TracksSkipped, &errMsg)
+		err := sc.Scan(&h.ID, &h.StartedAt, &completedAt, &h.TracksAdded, &h.TracksSkipped, &errMsg)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if completedAt.Valid {
@@ -173,8 +184,15 @@ func (r *Repository) GetSyncHistory(ctx context.Context, limit int) ([]*SyncHist
 
 		history = append(history, &h)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-	return history, rows.Err()
+	next := sc.Next(limit)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, next, nil
 }
 
 type SyncHistory struct {
@@ -0,0 +1,265 @@
+package suggest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/faraz525/home-music-server/backend/library"
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Suggestions per kind, and how many close matches are scored for each
+// kind when nothing matches exactly.
+const (
+	defaultLimit  = 5
+	maxLimit      = 10
+	maxCandidates = 100
+	// coverSize is the thumbnail size track suggestions' cover URLs ask for.
+	coverSize = 64
+)
+
+// Suggestions answers one autocomplete request. Highlights are the
+// matching text HTML-escaped, with the matches in <mark></mark>.
+type Suggestions struct {
+	Query   string              `json:"query"`
+	Tracks  []*TrackSuggestion  `json:"tracks"`
+	Artists []*ArtistSuggestion `json:"artists"`
+	Crates  []*CrateSuggestion  `json:"crates"`
+}
+
+// TrackSuggestion is a track from the user's library. Title falls back to
+// the filename for untagged tracks.
+type TrackSuggestion struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Artist    string          `json:"artist,omitempty"`
+	CoverURL  string          `json:"cover_url,omitempty"`
+	Highlight *TrackHighlight `json:"highlight"`
+	// Fuzzy is set on close matches, shown when nothing matched exactly.
+	Fuzzy bool `json:"fuzzy,omitempty"`
+}
+
+type TrackHighlight struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist,omitempty"`
+}
+
+// ArtistSuggestion is an artist in the user's library; ID is their
+// /api/library/artists ID.
+type ArtistSuggestion struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	TrackCount int    `json:"track_count"`
+	Highlight  string `json:"highlight"`
+	Fuzzy      bool   `json:"fuzzy,omitempty"`
+}
+
+// CrateSuggestion is one of the user's crates or another user's public one.
+type CrateSuggestion struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsSmart   bool   `json:"is_smart"`
+	IsOwner   bool   `json:"is_owner"`
+	Highlight string `json:"highlight"`
+	Fuzzy     bool   `json:"fuzzy,omitempty"`
+}
+
+// Manager suggests tracks, artists and crates as the user types. Every
+// word typed must start a word of the suggestion, ignoring case and
+// accents; when nothing does, close matches are suggested instead.
+type Manager struct {
+	repo *Repository
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo}
+}
+
+// prefixMatch renders words as a tracks_fts query matching every word as a
+// prefix, in column or all columns if column is "".
+func prefixMatch(column string, words []string) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"*`
+	}
+	match := strings.Join(parts, " AND ")
+	if column != "" {
+		match = column + " : (" + match + ")"
+	}
+	return match
+}
+
+// Suggest returns up to limit suggestions of each kind for q, plain text
+// as typed so far.
+func (m *Manager) Suggest(ctx context.Context, userID, q string, limit int) (*Suggestions, error) {
+	words := search.Words(q)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%w: q needs a letter or digit", ErrInvalidRequest)
+	}
+	if limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+
+	tracks, err := m.tracks(ctx, userID, words, limit)
+	if err != nil {
+		return nil, fmt.Errorf("suggest tracks: %w", err)
+	}
+	artists, err := m.artists(ctx, userID, words, limit)
+	if err != nil {
+		return nil, fmt.Errorf("suggest artists: %w", err)
+	}
+	crates, err := m.crates(ctx, userID, words, limit)
+	if err != nil {
+		return nil, fmt.Errorf("suggest crates: %w", err)
+	}
+	return &Suggestions{Query: q, Tracks: tracks, Artists: artists, Crates: crates}, nil
+}
+
+// byScore sorts items closest first, keeping the order of equally close
+// ones, and returns at most limit of them.
+func byScore[T any](items []T, scores map[int]float64, limit int) []T {
+	idx := make([]int, 0, len(scores))
+	for i := range scores {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if scores[idx[a]] != scores[idx[b]] {
+			return scores[idx[a]] > scores[idx[b]]
+		}
+		return idx[a] < idx[b]
+	})
+	out := []T{}
+	for _, i := range idx {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, items[i])
+	}
+	return out
+}
+
+func (m *Manager) tracks(ctx context.Context, userID string, words []string, limit int) ([]*TrackSuggestion, error) {
+	rows, err := m.repo.Tracks(ctx, userID, prefixMatch("", words), limit)
+	if err != nil {
+		return nil, err
+	}
+	fuzzy := false
+	if len(rows) == 0 {
+		match := search.TrigramMatch("", words)
+		if match == "" {
+			return []*TrackSuggestion{}, nil
+		}
+		candidates, err := m.repo.TrigramTracks(ctx, userID, match, maxCandidates)
+		if err != nil {
+			return nil, err
+		}
+		scores := map[int]float64{}
+		for i, t := range candidates {
+			if score, ok := search.FuzzyScore(words, t.title, t.artist, t.album, t.filename); ok {
+				scores[i] = score
+			}
+		}
+		rows, fuzzy = byScore(candidates, scores, limit), true
+	}
+
+	tracks := make([]*TrackSuggestion, len(rows))
+	for i, t := range rows {
+		s := &TrackSuggestion{ID: t.id, Title: t.title, Artist: t.artist, Fuzzy: fuzzy}
+		if s.Title == "" {
+			s.Title = t.filename
+		}
+		if t.hasCover {
+			s.CoverURL = "/api/tracks/" + t.id + "/cover?size=" + strconv.Itoa(coverSize)
+		}
+		s.Highlight = &TrackHighlight{Title: search.Highlight(s.Title, words)}
+		if s.Artist != "" {
+			s.Highlight.Artist = search.Highlight(s.Artist, words)
+		}
+		tracks[i] = s
+	}
+	return tracks, nil
+}
+
+func (m *Manager) artists(ctx context.Context, userID string, words []string, limit int) ([]*ArtistSuggestion, error) {
+	rows, err := m.repo.Artists(ctx, userID, "tracks_fts", prefixMatch("artist", words), limit)
+	if err != nil {
+		return nil, err
+	}
+	fuzzy := false
+	if len(rows) == 0 {
+		match := search.TrigramMatch("artist", words)
+		if match == "" {
+			return []*ArtistSuggestion{}, nil
+		}
+		candidates, err := m.repo.Artists(ctx, userID, "tracks_trigram", match, maxCandidates)
+		if err != nil {
+			return nil, err
+		}
+		scores := map[int]float64{}
+		for i, a := range candidates {
+			if score, ok := search.FuzzyScore(words, a.name); ok {
+				scores[i] = score
+			}
+		}
+		rows, fuzzy = byScore(candidates, scores, limit), true
+	}
+
+	artists := make([]*ArtistSuggestion, len(rows))
+	for i, a := range rows {
+		artists[i] = &ArtistSuggestion{
+			ID:         library.GroupID(a.name),
+			Name:       a.name,
+			TrackCount: a.trackCount,
+			Highlight:  search.Highlight(a.name, words),
+			Fuzzy:      fuzzy,
+		}
+	}
+	return artists, nil
+}
+
+// crates matches crate names in Go: a user sees few enough crates that
+// reading them all beats keeping another index.
+func (m *Manager) crates(ctx context.Context, userID string, words []string, limit int) ([]*CrateSuggestion, error) {
+	all, err := m.repo.Crates(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*crateRow
+	for _, c := range all {
+		if len(rows) == limit {
+			break
+		}
+		if search.Matches(words, c.name) {
+			rows = append(rows, c)
+		}
+	}
+	fuzzy := false
+	if len(rows) == 0 {
+		scores := map[int]float64{}
+		for i, c := range all {
+			if score, ok := search.FuzzyScore(words, c.name); ok {
+				scores[i] = score
+			}
+		}
+		rows, fuzzy = byScore(all, scores, limit), true
+	}
+
+	crates := make([]*CrateSuggestion, len(rows))
+	for i, c := range rows {
+		crates[i] = &CrateSuggestion{
+			ID:        c.id,
+			Name:      c.name,
+			IsSmart:   c.isSmart,
+			IsOwner:   c.isOwner,
+			Highlight: search.Highlight(c.name, words),
+			Fuzzy:     fuzzy,
+		}
+	}
+	return crates, nil
+}
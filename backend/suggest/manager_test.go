@@ -0,0 +1,128 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) (*Manager, *sql.DB) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE playlists (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, name TEXT,
+			is_default BOOLEAN DEFAULT FALSE, is_public BOOLEAN DEFAULT FALSE, rules TEXT
+		);
+		INSERT INTO playlists (id, owner_user_id, name, is_default, is_public, rules) VALUES
+			('p1', 'dj', 'Warm-up Séance', FALSE, FALSE, NULL),
+			('p2', 'dj', 'Peak time', FALSE, FALSE, '{}'),
+			('p3', 'other', 'Peak hour', FALSE, TRUE, NULL),
+			('p4', 'other', 'Peak secrets', FALSE, FALSE, NULL),
+			('p5', 'other', 'Unsorted peaks', TRUE, TRUE, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB})), sqlDB
+}
+
+func TestCrates(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	crates, err := m.crates(ctx, "dj", []string{"peak"}, 5)
+	if err != nil {
+		t.Fatalf("crates: %v", err)
+	}
+	var ids []string
+	for _, c := range crates {
+		ids = append(ids, c.ID)
+	}
+	// Own crates first; others' private and default crates are left out.
+	if strings.Join(ids, ",") != "p2,p3" {
+		t.Errorf("crates = %v, want p2,p3", ids)
+	}
+	if !crates[0].IsSmart || !crates[0].IsOwner || crates[1].IsOwner || crates[0].Fuzzy {
+		t.Errorf("flags = %+v %+v", crates[0], crates[1])
+	}
+	if crates[0].Highlight != "<mark>Peak</mark> time" {
+		t.Errorf("highlight = %q", crates[0].Highlight)
+	}
+
+	crates, err = m.crates(ctx, "dj", []string{"seanse"}, 5)
+	if err != nil {
+		t.Fatalf("crates: %v", err)
+	}
+	if len(crates) != 1 || crates[0].ID != "p1" || !crates[0].Fuzzy {
+		t.Errorf("typo crates = %+v", crates)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	_, err := sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, title TEXT, artist TEXT, album TEXT,
+			genre TEXT, original_filename TEXT, cover_path TEXT, created_at DATETIME, deleted_at DATETIME
+		);
+		CREATE VIRTUAL TABLE tracks_fts USING fts5(track_id UNINDEXED, title, artist, album, genre, original_filename, tags);
+		CREATE VIRTUAL TABLE tracks_trigram USING fts5(
+			track_id UNINDEXED, title, artist, album, genre, original_filename,
+			tokenize = 'trigram remove_diacritics 1'
+		);
+		INSERT INTO tracks (id, owner_user_id, title, artist, album, genre, original_filename, cover_path, created_at) VALUES
+			('t1', 'dj', 'Strobe', 'deadmau5', 'For Lack', 'House', 'strobe.mp3', '/c.jpg', '2024-01-01'),
+			('t2', 'dj', NULL, 'Beyoncé', NULL, NULL, 'halo.mp3', NULL, '2024-01-02'),
+			('t3', 'other', 'Strobe', 'deadmau5', NULL, NULL, 'x.mp3', NULL, '2024-01-03');
+		INSERT INTO tracks_fts SELECT id, title, artist, album, genre, original_filename, NULL FROM tracks;
+		INSERT INTO tracks_trigram SELECT id, title, artist, album, genre, original_filename FROM tracks;
+	`)
+	if err != nil && strings.Contains(err.Error(), "fts5") {
+		t.Skip("sqlite3 built without FTS5; run with -tags sqlite_fts5")
+	}
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	ctx := context.Background()
+
+	s, err := m.Suggest(ctx, "dj", "dead", 0)
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if len(s.Tracks) != 1 || s.Tracks[0].ID != "t1" || s.Tracks[0].CoverURL == "" || s.Tracks[0].Fuzzy {
+		t.Errorf("tracks = %+v", s.Tracks)
+	}
+	if len(s.Artists) != 1 || s.Artists[0].TrackCount != 1 || s.Artists[0].Highlight != "<mark>dead</mark>mau5" {
+		t.Errorf("artists = %+v", s.Artists)
+	}
+
+	s, err = m.Suggest(ctx, "dj", "deadmou5", 0)
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if len(s.Tracks) != 1 || !s.Tracks[0].Fuzzy || len(s.Artists) != 1 || !s.Artists[0].Fuzzy {
+		t.Errorf("typo = %+v %+v", s.Tracks, s.Artists)
+	}
+
+	s, err = m.Suggest(ctx, "dj", "beyonce", 0)
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if len(s.Tracks) != 1 || s.Tracks[0].Title != "halo.mp3" || s.Tracks[0].Highlight.Artist != "<mark>Beyoncé</mark>" {
+		t.Errorf("accents = %+v", s.Tracks)
+	}
+
+	if _, err := m.Suggest(ctx, "dj", " -- ", 0); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("no words: err = %v, want ErrInvalidRequest", err)
+	}
+}
@@ -0,0 +1,135 @@
+package suggest
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// trackRow is the part of a track a suggestion shows.
+type trackRow struct {
+	id, title, artist, album, filename string
+	hasCover                           bool
+}
+
+func (r *Repository) queryTracks(ctx context.Context, query string, args ...any) ([]*trackRow, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []*trackRow
+	for rows.Next() {
+		var t trackRow
+		var title, artist, album sql.NullString
+		if err := rows.Scan(&t.id, &title, &artist, &album, &t.filename, &t.hasCover); err != nil {
+			return nil, err
+		}
+		t.title, t.artist, t.album = title.String, artist.String, album.String
+		tracks = append(tracks, &t)
+	}
+	return tracks, rows.Err()
+}
+
+// Tracks returns up to limit of a user's tracks matching the tracks_fts
+// query match, best bm25 first.
+func (r *Repository) Tracks(ctx context.Context, userID, match string, limit int) ([]*trackRow, error) {
+	return r.queryTracks(ctx, `
+		SELECT t.id, t.title, t.artist, t.album, t.original_filename, t.cover_path IS NOT NULL
+		FROM tracks t
+		INNER JOIN tracks_fts fts ON t.id = fts.track_id
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NULL AND tracks_fts MATCH ?
+		ORDER BY `+search.Rank+`, t.created_at DESC
+		LIMIT ?
+	`, userID, match, limit)
+}
+
+// TrigramTracks returns up to limit of a user's tracks matching the
+// tracks_trigram query match, best bm25 first.
+func (r *Repository) TrigramTracks(ctx context.Context, userID, match string, limit int) ([]*trackRow, error) {
+	return r.queryTracks(ctx, `
+		SELECT t.id, t.title, t.artist, t.album, t.original_filename, t.cover_path IS NOT NULL
+		FROM tracks t
+		INNER JOIN tracks_trigram tg ON t.id = tg.track_id
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NULL AND tracks_trigram MATCH ?
+		ORDER BY bm25(tracks_trigram), t.created_at DESC
+		LIMIT ?
+	`, userID, match, limit)
+}
+
+// artistRow is an artist in a user's library and how many tracks they have.
+type artistRow struct {
+	name       string
+	trackCount int
+}
+
+// Artists returns up to limit of the artists of a user's tracks matching
+// the query match on index, which is tracks_fts or tracks_trigram, most
+// tracks first.
+func (r *Repository) Artists(ctx context.Context, userID, index, match string, limit int) ([]*artistRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT MIN(t.artist), COUNT(*) FROM tracks t
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NULL AND t.artist IS NOT NULL AND t.artist != ''
+		AND t.id IN (SELECT track_id FROM `+index+` WHERE `+index+` MATCH ?)
+		GROUP BY t.artist COLLATE NOCASE
+		ORDER BY COUNT(*) DESC, MIN(t.artist) COLLATE NOCASE
+		LIMIT ?
+	`, userID, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artists []*artistRow
+	for rows.Next() {
+		var a artistRow
+		if err := rows.Scan(&a.name, &a.trackCount); err != nil {
+			return nil, err
+		}
+		artists = append(artists, &a)
+	}
+	return artists, rows.Err()
+}
+
+// crateRow is a crate a user can open.
+type crateRow struct {
+	id, name string
+	isSmart  bool
+	isOwner  bool
+}
+
+// Crates returns the crates a user can open: their own, then other users'
+// public crates, each by name.
+func (r *Repository) Crates(ctx context.Context, userID string) ([]*crateRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.rules IS NOT NULL, p.owner_user_id = ?
+		FROM playlists p
+		WHERE p.owner_user_id = ? OR (p.is_public = TRUE AND p.is_default = FALSE)
+		ORDER BY p.owner_user_id = ? DESC, p.name COLLATE NOCASE
+	`, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crates []*crateRow
+	for rows.Next() {
+		var c crateRow
+		if err := rows.Scan(&c.id, &c.name, &c.isSmart, &c.isOwner); err != nil {
+			return nil, err
+		}
+		crates = append(crates, &c)
+	}
+	return crates, rows.Err()
+}
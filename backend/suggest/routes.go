@@ -0,0 +1,11 @@
+package suggest
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		rg.GET("/search/suggest", handlers.Suggest)
+	}
+}
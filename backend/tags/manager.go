@@ -0,0 +1,322 @@
+package tags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTagNotFound    = errors.New("tag not found")
+	ErrTrackNotFound  = errors.New("track not found")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrTagExists      = errors.New("tag already exists")
+)
+
+// Limits. Bulk requests match the size of a page of tracks in the client.
+const (
+	maxNameLength   = 50
+	maxTagsPerUser  = 500
+	maxBulkTracks   = 500
+	maxBulkTagNames = 20
+)
+
+var colorRE = regexp.MustCompile(`^#[0-9A-F]{6}$`)
+
+// Manager stores per-user tags and which of the user's tracks carry them.
+// Tags can only go on tracks the user owns, so a track's tags are always
+// its owner's; the search index keeps their names in tracks_fts.tags.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now}
+}
+
+type CreateRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+// UpdateRequest renames or recolours a tag; omitted fields are left as they
+// are. An empty color clears it.
+type UpdateRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// BulkRequest tags or untags tracks by tag name. Tagging creates any tags
+// the user doesn't have yet.
+type BulkRequest struct {
+	TrackIDs []string `json:"track_ids" binding:"required"`
+	Tags     []string `json:"tags" binding:"required"`
+}
+
+// normalize tidies user input before validation: trimmed names and
+// upper-case colours with a leading '#'.
+func normalize(t *Tag) {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Color = strings.ToUpper(strings.TrimSpace(t.Color))
+	if t.Color != "" && !strings.HasPrefix(t.Color, "#") {
+		t.Color = "#" + t.Color
+	}
+}
+
+// validate checks a tag's name and colour. Names can't contain commas, as
+// the tag= filter and tag: search take comma-separated lists.
+func validate(t *Tag) error {
+	if t.Name == "" {
+		return fmt.Errorf("%w: tags need a name", ErrInvalidRequest)
+	}
+	if utf8.RuneCountInString(t.Name) > maxNameLength {
+		return fmt.Errorf("%w: tag names are limited to %d characters", ErrInvalidRequest, maxNameLength)
+	}
+	if strings.Contains(t.Name, ",") {
+		return fmt.Errorf("%w: tag names can't contain commas", ErrInvalidRequest)
+	}
+	if t.Color != "" && !colorRE.MatchString(t.Color) {
+		return fmt.Errorf("%w: color must be a hex colour like #FF8800", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// List returns the user's tags with their track counts.
+func (m *Manager) List(ctx context.Context, userID string) ([]*Tag, error) {
+	return m.repo.ListTags(ctx, userID)
+}
+
+// checkName reports ErrTagExists if the user has another tag called name.
+func (m *Manager) checkName(ctx context.Context, userID, name, exceptID string) error {
+	existing, err := m.repo.TagsByName(ctx, userID, []string{name})
+	if err != nil {
+		return err
+	}
+	for _, t := range existing {
+		if t.ID != exceptID {
+			return fmt.Errorf("%w: you already have a tag called %q", ErrTagExists, t.Name)
+		}
+	}
+	return nil
+}
+
+// checkQuota reports ErrInvalidRequest if the user can't have n more tags.
+func (m *Manager) checkQuota(ctx context.Context, userID string, n int) error {
+	if n == 0 {
+		return nil
+	}
+	have, err := m.repo.CountTags(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if have+n > maxTagsPerUser {
+		return fmt.Errorf("%w: you can have at most %d tags", ErrInvalidRequest, maxTagsPerUser)
+	}
+	return nil
+}
+
+// Create adds a tag for the user.
+func (m *Manager) Create(ctx context.Context, userID string, req *CreateRequest) (*Tag, error) {
+	now := m.now()
+	t := &Tag{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Color:     req.Color,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	normalize(t)
+	if err := validate(t); err != nil {
+		return nil, err
+	}
+	if err := m.checkName(ctx, userID, t.Name, ""); err != nil {
+		return nil, err
+	}
+	if err := m.checkQuota(ctx, userID, 1); err != nil {
+		return nil, err
+	}
+	if err := m.repo.CreateTag(ctx, t); err != nil {
+		return nil, fmt.Errorf("create tag: %w", err)
+	}
+	return t, nil
+}
+
+// get loads one of the user's tags; other users' tags are reported as not
+// found.
+func (m *Manager) get(ctx context.Context, tagID, userID string) (*Tag, error) {
+	t, err := m.repo.GetTag(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+	if t.UserID != userID {
+		return nil, ErrTagNotFound
+	}
+	return t, nil
+}
+
+// Update applies req to one of the user's tags. A rename shows up in
+// search straight away.
+func (m *Manager) Update(ctx context.Context, tagID, userID string, req *UpdateRequest) (*Tag, error) {
+	t, err := m.get(ctx, tagID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		t.Name = *req.Name
+	}
+	if req.Color != nil {
+		t.Color = *req.Color
+	}
+	normalize(t)
+	if err := validate(t); err != nil {
+		return nil, err
+	}
+	if err := m.checkName(ctx, userID, t.Name, t.ID); err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = m.now()
+	if err := m.repo.UpdateTag(ctx, t); err != nil {
+		return nil, fmt.Errorf("update tag: %w", err)
+	}
+	return t, nil
+}
+
+// Delete removes one of the user's tags from all their tracks and deletes it.
+func (m *Manager) Delete(ctx context.Context, tagID, userID string) error {
+	if _, err := m.get(ctx, tagID, userID); err != nil {
+		return err
+	}
+	return m.repo.DeleteTag(ctx, tagID)
+}
+
+// TrackTags returns the user's tags on one of their tracks.
+func (m *Manager) TrackTags(ctx context.Context, trackID, userID string) ([]*Tag, error) {
+	owned, err := m.repo.OwnedTracks(ctx, userID, []string{trackID})
+	if err != nil {
+		return nil, err
+	}
+	if !owned[trackID] {
+		return nil, ErrTrackNotFound
+	}
+	return m.repo.TrackTags(ctx, trackID, userID)
+}
+
+// bulk checks a bulk request: the tracks must all be the user's, and the
+// tag names valid. It returns the de-duplicated track IDs and tag names.
+func (m *Manager) bulk(ctx context.Context, userID string, req *BulkRequest) ([]string, []string, error) {
+	var trackIDs []string
+	seen := map[string]bool{}
+	for _, id := range req.TrackIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			trackIDs = append(trackIDs, id)
+		}
+	}
+	if len(trackIDs) == 0 {
+		return nil, nil, fmt.Errorf("%w: no track IDs provided", ErrInvalidRequest)
+	}
+	if len(trackIDs) > maxBulkTracks {
+		return nil, nil, fmt.Errorf("%w: at most %d tracks at once", ErrInvalidRequest, maxBulkTracks)
+	}
+
+	var names []string
+	seen = map[string]bool{}
+	for _, name := range req.Tags {
+		t := &Tag{Name: name}
+		normalize(t)
+		if err := validate(t); err != nil {
+			return nil, nil, err
+		}
+		if key := strings.ToLower(t.Name); !seen[key] {
+			seen[key] = true
+			names = append(names, t.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("%w: no tags provided", ErrInvalidRequest)
+	}
+	if len(names) > maxBulkTagNames {
+		return nil, nil, fmt.Errorf("%w: at most %d tags at once", ErrInvalidRequest, maxBulkTagNames)
+	}
+
+	owned, err := m.repo.OwnedTracks(ctx, userID, trackIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range trackIDs {
+		if !owned[id] {
+			return nil, nil, fmt.Errorf("%w: %s", ErrTrackNotFound, id)
+		}
+	}
+	return trackIDs, names, nil
+}
+
+// Tag puts the named tags on the user's tracks, creating tags they don't
+// have yet, and returns how many track-tag links were added along with the
+// tags as they now stand.
+func (m *Manager) Tag(ctx context.Context, userID string, req *BulkRequest) (int, []*Tag, error) {
+	trackIDs, names, err := m.bulk(ctx, userID, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	existing, err := m.repo.TagsByName(ctx, userID, names)
+	if err != nil {
+		return 0, nil, err
+	}
+	have := map[string]bool{}
+	var tagIDs []string
+	for _, t := range existing {
+		have[strings.ToLower(t.Name)] = true
+		tagIDs = append(tagIDs, t.ID)
+	}
+	now := m.now()
+	var create []*Tag
+	for _, name := range names {
+		if !have[strings.ToLower(name)] {
+			create = append(create, &Tag{ID: uuid.New().String(), UserID: userID, Name: name, CreatedAt: now, UpdatedAt: now})
+		}
+	}
+	if err := m.checkQuota(ctx, userID, len(create)); err != nil {
+		return 0, nil, err
+	}
+	added, err := m.repo.Apply(ctx, create, tagIDs, trackIDs, now)
+	if err != nil {
+		return 0, nil, fmt.Errorf("tag tracks: %w", err)
+	}
+	tags, err := m.repo.TagsByName(ctx, userID, names)
+	if err != nil {
+		return 0, nil, err
+	}
+	return added, tags, nil
+}
+
+// Untag takes the named tags off the user's tracks and returns how many
+// track-tag links were removed. Names the user has no tag for are ignored;
+// the tags themselves are kept, even when no track carries them any more.
+func (m *Manager) Untag(ctx context.Context, userID string, req *BulkRequest) (int, error) {
+	trackIDs, names, err := m.bulk(ctx, userID, req)
+	if err != nil {
+		return 0, err
+	}
+	existing, err := m.repo.TagsByName(ctx, userID, names)
+	if err != nil {
+		return 0, err
+	}
+	tagIDs := make([]string, len(existing))
+	for i, t := range existing {
+		tagIDs[i] = t.ID
+	}
+	removed, err := m.repo.Remove(ctx, tagIDs, trackIDs)
+	if err != nil {
+		return 0, fmt.Errorf("untag tracks: %w", err)
+	}
+	return removed, nil
+}
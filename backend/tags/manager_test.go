@@ -0,0 +1,164 @@
+package tags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (id TEXT PRIMARY KEY, owner_user_id TEXT, deleted_at DATETIME);
+		CREATE TABLE tags (
+			id TEXT PRIMARY KEY, user_id TEXT NOT NULL, name TEXT NOT NULL, color TEXT,
+			created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL
+		);
+		CREATE UNIQUE INDEX idx_tags_user_name ON tags(user_id, name COLLATE NOCASE);
+		CREATE TABLE track_tags (
+			track_id TEXT NOT NULL, tag_id TEXT NOT NULL, created_at DATETIME NOT NULL,
+			PRIMARY KEY (track_id, tag_id)
+		);
+		INSERT INTO tracks (id, owner_user_id, deleted_at) VALUES
+			('t1', 'dj', NULL), ('t2', 'dj', NULL), ('t3', 'other', NULL), ('trashed', 'dj', CURRENT_TIMESTAMP);
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB}))
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestCreateValidatesAndRejectsDuplicates(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	tag, err := m.Create(ctx, "dj", &CreateRequest{Name: "  Vocal ", Color: "ff8800"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if tag.Name != "Vocal" || tag.Color != "#FF8800" {
+		t.Errorf("tag = %q %q, want Vocal #FF8800", tag.Name, tag.Color)
+	}
+
+	if _, err := m.Create(ctx, "dj", &CreateRequest{Name: "vocal"}); !errors.Is(err, ErrTagExists) {
+		t.Errorf("duplicate: error = %v, want ErrTagExists", err)
+	}
+	if _, err := m.Create(ctx, "other", &CreateRequest{Name: "vocal"}); err != nil {
+		t.Errorf("another user's tag of the same name: %v", err)
+	}
+	for _, req := range []CreateRequest{{Name: " "}, {Name: "a,b"}, {Name: "x", Color: "orange"}} {
+		if _, err := m.Create(ctx, "dj", &req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("create %+v: error = %v, want ErrInvalidRequest", req, err)
+		}
+	}
+}
+
+func TestTagCreatesMissingTagsAndCounts(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.Create(ctx, "dj", &CreateRequest{Name: "Warmup"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	added, tags, err := m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1", "t2", "t1"}, Tags: []string{"warmup", "B2B with Sam", "WARMUP"}})
+	if err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	if added != 4 {
+		t.Errorf("added = %d, want 4", added)
+	}
+	if len(tags) != 2 || tags[0].Name != "B2B with Sam" || tags[1].Name != "Warmup" {
+		t.Fatalf("tags = %+v", tags)
+	}
+	for _, tag := range tags {
+		if tag.TrackCount != 2 {
+			t.Errorf("%s track count = %d, want 2", tag.Name, tag.TrackCount)
+		}
+	}
+
+	// Tagging again adds nothing
+	added, _, err = m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1"}, Tags: []string{"warmup"}})
+	if err != nil || added != 0 {
+		t.Errorf("re-tag: added = %d, err = %v", added, err)
+	}
+
+	removed, err := m.Untag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1"}, Tags: []string{"Warmup", "unknown"}})
+	if err != nil || removed != 1 {
+		t.Errorf("untag: removed = %d, err = %v", removed, err)
+	}
+	onT1, err := m.TrackTags(ctx, "t1", "dj")
+	if err != nil {
+		t.Fatalf("track tags: %v", err)
+	}
+	if len(onT1) != 1 || onT1[0].Name != "B2B with Sam" {
+		t.Errorf("t1 tags = %+v", onT1)
+	}
+}
+
+func TestBulkRejectsOtherUsersTracks(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if _, _, err := m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1", "t3"}, Tags: []string{"vocal"}}); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("tag: error = %v, want ErrTrackNotFound", err)
+	}
+	if tags, _ := m.List(ctx, "dj"); len(tags) != 0 {
+		t.Errorf("a failed request created tags: %+v", tags)
+	}
+	if _, err := m.TrackTags(ctx, "t3", "dj"); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("track tags: error = %v, want ErrTrackNotFound", err)
+	}
+	if _, _, err := m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"trashed"}, Tags: []string{"vocal"}}); !errors.Is(err, ErrTrackNotFound) {
+		t.Errorf("track in the trash: error = %v, want ErrTrackNotFound", err)
+	}
+	if _, _, err := m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1"}}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("no tags: error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	_, tags, err := m.Tag(ctx, "dj", &BulkRequest{TrackIDs: []string{"t1"}, Tags: []string{"vocal", "dub"}})
+	if err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	dub, vocal := tags[0], tags[1]
+
+	if _, err := m.Update(ctx, dub.ID, "dj", &UpdateRequest{Name: ptr("VOCAL")}); !errors.Is(err, ErrTagExists) {
+		t.Errorf("rename onto another tag: error = %v, want ErrTagExists", err)
+	}
+	updated, err := m.Update(ctx, vocal.ID, "dj", &UpdateRequest{Name: ptr("Vocals"), Color: ptr("#00ff00")})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Name != "Vocals" || updated.Color != "#00FF00" || updated.TrackCount != 1 {
+		t.Errorf("updated = %+v", updated)
+	}
+	if _, err := m.Update(ctx, vocal.ID, "other", &UpdateRequest{Name: ptr("x")}); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("other user's update: error = %v, want ErrTagNotFound", err)
+	}
+
+	if err := m.Delete(ctx, vocal.ID, "dj"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	onT1, _ := m.TrackTags(ctx, "t1", "dj")
+	if len(onT1) != 1 || onT1[0].ID != dub.ID {
+		t.Errorf("t1 tags after delete = %+v", onT1)
+	}
+	if err := m.Delete(ctx, vocal.ID, "dj"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("second delete: error = %v, want ErrTagNotFound", err)
+	}
+}
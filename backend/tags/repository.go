@@ -0,0 +1,243 @@
+package tags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Tag is one of a user's tags, with the number of tracks carrying it.
+type Tag struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Color      string    `json:"color,omitempty"`
+	TrackCount int       `json:"track_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	UserID string `json:"-"`
+}
+
+const tagColumns = `g.id, g.user_id, g.name, g.color, g.created_at, g.updated_at,
+	(SELECT COUNT(*) FROM track_tags tt WHERE tt.tag_id = g.id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTag(row rowScanner) (*Tag, error) {
+	var t Tag
+	var color sql.NullString
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &color, &t.CreatedAt, &t.UpdatedAt, &t.TrackCount); err != nil {
+		return nil, err
+	}
+	t.Color = color.String
+	return &t, nil
+}
+
+func (r *Repository) queryTags(ctx context.Context, query string, args ...any) ([]*Tag, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns a user's tags by name.
+func (r *Repository) ListTags(ctx context.Context, userID string) ([]*Tag, error) {
+	return r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags g WHERE g.user_id = ? ORDER BY g.name COLLATE NOCASE`, userID)
+}
+
+func (r *Repository) GetTag(ctx context.Context, id string) (*Tag, error) {
+	t, err := scanTag(r.db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags g WHERE g.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
+	return t, err
+}
+
+// TagsByName returns those of a user's tags named in names, ignoring case.
+func (r *Repository) TagsByName(ctx context.Context, userID string, names []string) ([]*Tag, error) {
+	if len(names) == 0 {
+		return []*Tag{}, nil
+	}
+	args := []any{userID}
+	for _, name := range names {
+		args = append(args, name)
+	}
+	return r.queryTags(ctx, `
+		SELECT `+tagColumns+` FROM tags g
+		WHERE g.user_id = ? AND g.name COLLATE NOCASE IN (?`+strings.Repeat(", ?", len(names)-1)+`)
+		ORDER BY g.name COLLATE NOCASE
+	`, args...)
+}
+
+// TrackTags returns the user's tags on a track.
+func (r *Repository) TrackTags(ctx context.Context, trackID, userID string) ([]*Tag, error) {
+	return r.queryTags(ctx, `
+		SELECT `+tagColumns+` FROM tags g
+		JOIN track_tags x ON x.tag_id = g.id
+		WHERE x.track_id = ? AND g.user_id = ?
+		ORDER BY g.name COLLATE NOCASE
+	`, trackID, userID)
+}
+
+// CountTags returns how many tags a user has.
+func (r *Repository) CountTags(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tags WHERE user_id = ?`, userID).Scan(&n)
+	return n, err
+}
+
+func nullColor(color string) any {
+	if color == "" {
+		return nil
+	}
+	return color
+}
+
+func (r *Repository) CreateTag(ctx context.Context, t *Tag) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO tags (id, user_id, name, color, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.ID, t.UserID, t.Name, nullColor(t.Color), t.CreatedAt, t.UpdatedAt)
+	return err
+}
+
+func (r *Repository) UpdateTag(ctx context.Context, t *Tag) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE tags SET name = ?, color = ?, updated_at = ? WHERE id = ?
+	`, t.Name, nullColor(t.Color), t.UpdatedAt, t.ID)
+	return err
+}
+
+// DeleteTag removes a tag from every track, then deletes it. The links go
+// first so the search index triggers see each untagging.
+func (r *Repository) DeleteTag(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM track_tags WHERE tag_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// OwnedTracks returns which of trackIDs exist, belong to userID and are
+// not in the trash.
+func (r *Repository) OwnedTracks(ctx context.Context, userID string, trackIDs []string) (map[string]bool, error) {
+	owned := make(map[string]bool, len(trackIDs))
+	if len(trackIDs) == 0 {
+		return owned, nil
+	}
+	args := []any{userID}
+	for _, id := range trackIDs {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id FROM tracks WHERE owner_user_id = ? AND deleted_at IS NULL AND id IN (?`+strings.Repeat(", ?", len(trackIDs)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owned[id] = true
+	}
+	return owned, rows.Err()
+}
+
+// Apply creates the tags in create, then links every tag in tagIDs (and
+// the new ones) to every track, in one transaction. It returns how many
+// links were added; existing ones are left alone.
+func (r *Repository) Apply(ctx context.Context, create []*Tag, tagIDs, trackIDs []string, now time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, t := range create {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO tags (id, user_id, name, color, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, t.ID, t.UserID, t.Name, nullColor(t.Color), t.CreatedAt, t.UpdatedAt); err != nil {
+			return 0, err
+		}
+		tagIDs = append(tagIDs, t.ID)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO track_tags (track_id, tag_id, created_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	added := 0
+	for _, trackID := range trackIDs {
+		for _, tagID := range tagIDs {
+			res, err := stmt.ExecContext(ctx, trackID, tagID, now)
+			if err != nil {
+				return 0, err
+			}
+			n, _ := res.RowsAffected()
+			added += int(n)
+		}
+	}
+	return added, tx.Commit()
+}
+
+// Remove unlinks every tag in tagIDs from every track, returning how many
+// links were removed.
+func (r *Repository) Remove(ctx context.Context, tagIDs, trackIDs []string) (int, error) {
+	if len(tagIDs) == 0 || len(trackIDs) == 0 {
+		return 0, nil
+	}
+	args := make([]any, 0, len(tagIDs)+len(trackIDs))
+	for _, id := range tagIDs {
+		args = append(args, id)
+	}
+	for _, id := range trackIDs {
+		args = append(args, id)
+	}
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM track_tags
+		WHERE tag_id IN (?`+strings.Repeat(", ?", len(tagIDs)-1)+`)
+		AND track_id IN (?`+strings.Repeat(", ?", len(trackIDs)-1)+`)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
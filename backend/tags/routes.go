@@ -0,0 +1,20 @@
+package tags
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/tags")
+		{
+			r.GET("", handlers.ListTags)
+			r.POST("", handlers.CreateTag)
+			r.PATCH("/:id", handlers.UpdateTag)
+			r.DELETE("/:id", handlers.DeleteTag)
+			r.POST("/tag", handlers.TagTracks)
+			r.POST("/untag", handlers.UntagTracks)
+		}
+		rg.GET("/tracks/:id/tags", handlers.ListTrackTags)
+	}
+}
@@ -0,0 +1,99 @@
+package tempo
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/gin-gonic/gin"
+)
+
+type Handlers struct {
+	manager *Manager
+}
+
+func NewHandlers(manager *Manager) *Handlers {
+	return &Handlers{manager: manager}
+}
+
+// errorStatus maps manager errors to an HTTP status and error code.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "invalid_request"
+	default:
+		return http.StatusInternalServerError, "internal_error"
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, gin.H{"error": gin.H{"code": code, "message": err.Error()}})
+}
+
+func (h *Handlers) GetRanges(c *gin.Context) {
+	ranges, err := h.manager.Ranges(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ranges": ranges, "defaults": analysis.DefaultTempoRanges})
+}
+
+type setRangesRequest struct {
+	Ranges []analysis.TempoRange `json:"ranges"`
+}
+
+func (h *Handlers) SetRanges(c *gin.Context) {
+	var req setRangesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+		return
+	}
+	ranges, flagged, err := h.manager.SetRanges(c.Request.Context(), c.GetString("user_id"), req.Ranges)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ranges": ranges, "flagged": flagged})
+}
+
+func (h *Handlers) ListFlagged(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	tracks, total, err := h.manager.Flagged(c.Request.Context(), c.GetString("user_id"), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tracks": tracks, "total": total, "limit": limit, "offset": offset})
+}
+
+type fixRequest struct {
+	TrackIDs []string `json:"track_ids"`
+}
+
+// Fix applies suggested tempos. The body is optional; without track_ids
+// every flagged track is fixed.
+func (h *Handlers) Fix(c *gin.Context) {
+	var req fixRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+			return
+		}
+	}
+	n, err := h.manager.Fix(c.Request.Context(), c.GetString("user_id"), req.TrackIDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fixed": n})
+}
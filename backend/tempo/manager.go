@@ -0,0 +1,138 @@
+package tempo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Range limits. The bounds are wider than a BPM edit allows so a range can
+// sit right at the edge of what DJs play.
+const (
+	minRangeBPM = 40
+	maxRangeBPM = 300
+	maxRanges   = 64
+	maxFixIDs   = 1000
+)
+
+// Manager keeps each user's preferred tempo ranges and the octave-error
+// suggestions they produce. The analysis worker applies the ranges as it
+// analyzes (see analysis.NormalizeTempo); changing them re-checks the
+// library but only suggests corrections, which the user applies in bulk.
+type Manager struct {
+	repo *Repository
+	now  func() time.Time
+}
+
+func NewManager(repo *Repository) *Manager {
+	return &Manager{repo: repo, now: time.Now}
+}
+
+// Ranges returns the user's tempo ranges.
+func (m *Manager) Ranges(ctx context.Context, userID string) ([]analysis.TempoRange, error) {
+	return m.repo.ListRanges(ctx, userID)
+}
+
+// validateRanges normalizes genres in place and checks each range.
+func validateRanges(ranges []analysis.TempoRange) error {
+	if len(ranges) > maxRanges {
+		return fmt.Errorf("%w: at most %d ranges", ErrInvalidRequest, maxRanges)
+	}
+	seen := make(map[string]bool, len(ranges))
+	for i := range ranges {
+		r := &ranges[i]
+		r.Genre = analysis.NormalizeGenre(r.Genre)
+		if r.MinBPM < minRangeBPM || r.MaxBPM > maxRangeBPM || r.MinBPM >= r.MaxBPM {
+			return fmt.Errorf("%w: ranges need %d <= min_bpm < max_bpm <= %d", ErrInvalidRequest, minRangeBPM, maxRangeBPM)
+		}
+		if seen[r.Genre] {
+			if r.Genre == "" {
+				return fmt.Errorf("%w: only one range may have no genre", ErrInvalidRequest)
+			}
+			return fmt.Errorf("%w: genre %q has more than one range", ErrInvalidRequest, r.Genre)
+		}
+		seen[r.Genre] = true
+	}
+	return nil
+}
+
+// SetRanges replaces the user's tempo ranges and re-checks their library
+// against them, returning how many tracks are now flagged.
+func (m *Manager) SetRanges(ctx context.Context, userID string, ranges []analysis.TempoRange) ([]analysis.TempoRange, int, error) {
+	if err := validateRanges(ranges); err != nil {
+		return nil, 0, err
+	}
+	if err := m.repo.ReplaceRanges(ctx, userID, ranges, m.now()); err != nil {
+		return nil, 0, fmt.Errorf("save tempo ranges: %w", err)
+	}
+	flagged, err := m.Reflag(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	saved, err := m.repo.ListRanges(ctx, userID)
+	return saved, flagged, err
+}
+
+// Reflag recomputes tempo suggestions for the user's analyzed tracks from
+// the tempo the analyzer reported. A track is flagged when its ranges put it
+// somewhere other than its current BPM, which includes undoing an earlier
+// correction the new ranges no longer call for.
+func (m *Manager) Reflag(ctx context.Context, userID string) (int, error) {
+	ranges, err := m.repo.ListRanges(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	tracks, err := m.repo.candidates(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	changes := make(map[string]float64)
+	flagged := 0
+	for _, t := range tracks {
+		target := t.raw
+		factor, suggested := analysis.NormalizeTempo(t.raw, t.confidence, t.genre, ranges)
+		if suggested > 0 {
+			target = suggested
+		} else if factor != 1 {
+			target = math.Round(t.raw*factor*1000) / 1000
+		}
+		next := 0.0
+		if math.Abs(target-t.bpm) > 0.01 {
+			next = target
+			flagged++
+		}
+		if t.suggested == nil && next == 0 || t.suggested != nil && *t.suggested == next {
+			continue
+		}
+		changes[t.id] = next
+	}
+	if len(changes) > 0 {
+		if err := m.repo.SetSuggestions(ctx, changes); err != nil {
+			return 0, fmt.Errorf("save tempo suggestions: %w", err)
+		}
+	}
+	return flagged, nil
+}
+
+// Flagged lists the user's tracks with a suggested tempo correction.
+func (m *Manager) Flagged(ctx context.Context, userID string, limit, offset int) ([]*FlaggedTrack, int, error) {
+	return m.repo.ListFlagged(ctx, userID, limit, offset)
+}
+
+// Fix applies suggested corrections to the user's flagged tracks: those in
+// trackIDs, or all of them when it is empty.
+func (m *Manager) Fix(ctx context.Context, userID string, trackIDs []string) (int64, error) {
+	if len(trackIDs) > maxFixIDs {
+		return 0, fmt.Errorf("%w: at most %d track_ids per request", ErrInvalidRequest, maxFixIDs)
+	}
+	return m.repo.ApplySuggestions(ctx, userID, trackIDs)
+}
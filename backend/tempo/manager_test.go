@@ -0,0 +1,135 @@
+package tempo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestManager(t *testing.T) (*Manager, *sql.DB) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, title TEXT, artist TEXT, genre TEXT,
+			bpm REAL, bpm_confidence REAL, bpm_raw REAL, bpm_suggested REAL, bpm_backend TEXT,
+			analysis_status TEXT NOT NULL DEFAULT 'analyzed', updated_at DATETIME, deleted_at DATETIME
+		);
+		CREATE TABLE user_tempo_ranges (
+			user_id TEXT NOT NULL, genre TEXT NOT NULL DEFAULT '', min_bpm REAL NOT NULL, max_bpm REAL NOT NULL,
+			updated_at DATETIME NOT NULL, PRIMARY KEY (user_id, genre)
+		);
+		INSERT INTO tracks (id, owner_user_id, genre, bpm, bpm_confidence, bpm_raw, analysis_status) VALUES
+			('slow', 'dj', NULL, 64, 0.9, 64, 'analyzed'),
+			('fine', 'dj', NULL, 126, 0.9, 126, 'analyzed'),
+			('dnb', 'dj', 'Drum & Bass', 174, 0.9, 87, 'analyzed'),
+			('edited', 'dj', NULL, 64, 0.9, 64, 'user_edited'),
+			('theirs', 'other', NULL, 64, 0.9, 64, 'analyzed');
+		INSERT INTO tracks (id, owner_user_id, bpm, bpm_confidence, bpm_raw, deleted_at) VALUES
+			('trashed', 'dj', 64, 0.9, 64, '2024-01-01');
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return NewManager(NewRepository(&db.DB{DB: sqlDB})), sqlDB
+}
+
+func TestValidateRanges(t *testing.T) {
+	cases := []struct {
+		name   string
+		ranges []analysis.TempoRange
+		ok     bool
+	}{
+		{"catch-all and genre", []analysis.TempoRange{{MinBPM: 90, MaxBPM: 150}, {Genre: "Drum & Bass", MinBPM: 165, MaxBPM: 180}}, true},
+		{"empty", nil, true},
+		{"backwards", []analysis.TempoRange{{MinBPM: 150, MaxBPM: 90}}, false},
+		{"too slow", []analysis.TempoRange{{MinBPM: 20, MaxBPM: 90}}, false},
+		{"two catch-alls", []analysis.TempoRange{{MinBPM: 90, MaxBPM: 150}, {Genre: " ", MinBPM: 80, MaxBPM: 140}}, false},
+		{"same genre twice", []analysis.TempoRange{{Genre: "dnb", MinBPM: 160, MaxBPM: 180}, {Genre: "DnB", MinBPM: 165, MaxBPM: 180}}, false},
+	}
+	for _, tc := range cases {
+		err := validateRanges(tc.ranges)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: error = %v, want ErrInvalidRequest", tc.name, err)
+		}
+	}
+}
+
+func TestSetRangesFlagsAndFix(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+
+	ranges, flagged, err := m.SetRanges(ctx, "dj", []analysis.TempoRange{{MinBPM: 100, MaxBPM: 150}})
+	if err != nil {
+		t.Fatalf("SetRanges: %v", err)
+	}
+	if len(ranges) != 1 || flagged != 1 {
+		t.Fatalf("ranges = %v, flagged = %d; want 1 range, 1 flagged", ranges, flagged)
+	}
+	tracks, total, err := m.Flagged(ctx, "dj", 10, 0)
+	if err != nil {
+		t.Fatalf("Flagged: %v", err)
+	}
+	if total != 1 || tracks[0].ID != "slow" || tracks[0].BPMSuggested != 128 {
+		t.Fatalf("flagged = %+v (total %d)", tracks, total)
+	}
+
+	// A user range for the genre that disagrees with the earlier correction
+	// flags it for undoing; the catch-all going away clears the other flag.
+	_, flagged, err = m.SetRanges(ctx, "dj", []analysis.TempoRange{{Genre: "drum and bass", MinBPM: 80, MaxBPM: 95}})
+	if err != nil {
+		t.Fatalf("SetRanges: %v", err)
+	}
+	if flagged != 1 {
+		t.Errorf("flagged = %d, want 1", flagged)
+	}
+	tracks, _, _ = m.Flagged(ctx, "dj", 10, 0)
+	if len(tracks) != 1 || tracks[0].ID != "dnb" || tracks[0].BPMSuggested != 87 {
+		t.Fatalf("flagged = %+v", tracks)
+	}
+
+	n, err := m.Fix(ctx, "dj", nil)
+	if err != nil || n != 1 {
+		t.Fatalf("Fix = %d, %v; want 1", n, err)
+	}
+	var bpm float64
+	var suggested sql.NullFloat64
+	var status string
+	_ = sqlDB.QueryRow(`SELECT bpm, bpm_suggested, analysis_status FROM tracks WHERE id = 'dnb'`).Scan(&bpm, &suggested, &status)
+	if bpm != 87 || suggested.Valid || status != "user_edited" {
+		t.Errorf("dnb after fix: bpm = %v, suggested = %v, status = %q", bpm, suggested, status)
+	}
+
+	// Other users' tracks and the trash are never touched.
+	for _, id := range []string{"theirs", "trashed"} {
+		_ = sqlDB.QueryRow(`SELECT bpm_suggested FROM tracks WHERE id = ?`, id).Scan(&suggested)
+		if suggested.Valid {
+			t.Errorf("%s was flagged: %v", id, suggested)
+		}
+	}
+}
+
+func TestFixOnlyListedTracks(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+	_, _ = sqlDB.Exec(`UPDATE tracks SET bpm_suggested = 128 WHERE id IN ('slow', 'theirs')`)
+
+	if n, err := m.Fix(ctx, "dj", []string{"fine", "theirs"}); err != nil || n != 0 {
+		t.Errorf("Fix of unflagged and foreign tracks = %d, %v; want 0", n, err)
+	}
+	if n, err := m.Fix(ctx, "dj", []string{"slow"}); err != nil || n != 1 {
+		t.Errorf("Fix = %d, %v; want 1", n, err)
+	}
+}
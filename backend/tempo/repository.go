@@ -0,0 +1,189 @@
+package tempo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FlaggedTrack is a track whose detected tempo is a probable octave error.
+type FlaggedTrack struct {
+	ID            string   `json:"id"`
+	Title         *string  `json:"title,omitempty"`
+	Artist        *string  `json:"artist,omitempty"`
+	Genre         *string  `json:"genre,omitempty"`
+	BPM           float64  `json:"bpm"`
+	BPMRaw        *float64 `json:"bpm_raw,omitempty"`
+	BPMSuggested  float64  `json:"bpm_suggested"`
+	BPMConfidence *float64 `json:"bpm_confidence,omitempty"`
+}
+
+// candidate is an analyzed track re-checked when the user's ranges change.
+type candidate struct {
+	id         string
+	bpm        float64
+	raw        float64
+	confidence float64
+	genre      string
+	suggested  *float64
+}
+
+func (r *Repository) ListRanges(ctx context.Context, userID string) ([]analysis.TempoRange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT genre, min_bpm, max_bpm FROM user_tempo_ranges
+		WHERE user_id = ?
+		ORDER BY genre
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranges := []analysis.TempoRange{}
+	for rows.Next() {
+		var tr analysis.TempoRange
+		if err := rows.Scan(&tr.Genre, &tr.MinBPM, &tr.MaxBPM); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, tr)
+	}
+	return ranges, rows.Err()
+}
+
+// ReplaceRanges swaps the user's ranges for a new set.
+func (r *Repository) ReplaceRanges(ctx context.Context, userID string, ranges []analysis.TempoRange, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_tempo_ranges WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, tr := range ranges {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_tempo_ranges (user_id, genre, min_bpm, max_bpm, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, userID, tr.Genre, tr.MinBPM, tr.MaxBPM, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// candidates returns the user's analyzed tracks with a detected tempo.
+// User-edited tracks are left out: their BPM is the user's call. So are
+// tracks in the trash.
+func (r *Repository) candidates(ctx context.Context, userID string) ([]candidate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, bpm, bpm_raw, COALESCE(bpm_confidence, 0), COALESCE(genre, ''), bpm_suggested
+		FROM tracks
+		WHERE owner_user_id = ? AND analysis_status = 'analyzed' AND deleted_at IS NULL
+		  AND bpm IS NOT NULL AND bpm_raw IS NOT NULL
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.bpm, &c.raw, &c.confidence, &c.genre, &c.suggested); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// SetSuggestions overwrites bpm_suggested for the given tracks; a 0 value
+// clears it.
+func (r *Repository) SetSuggestions(ctx context.Context, suggestions map[string]float64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, bpm := range suggestions {
+		var v any
+		if bpm > 0 {
+			v = bpm
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tracks SET bpm_suggested = ? WHERE id = ?`, v, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListFlagged returns the user's tracks with a suggested tempo correction.
+func (r *Repository) ListFlagged(ctx context.Context, userID string, limit, offset int) ([]*FlaggedTrack, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM tracks WHERE owner_user_id = ? AND deleted_at IS NULL AND bpm_suggested IS NOT NULL`, userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, title, artist, genre, bpm, bpm_raw, bpm_suggested, bpm_confidence
+		FROM tracks
+		WHERE owner_user_id = ? AND deleted_at IS NULL AND bpm_suggested IS NOT NULL
+		ORDER BY artist, title, id
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tracks := []*FlaggedTrack{}
+	for rows.Next() {
+		var t FlaggedTrack
+		var bpm sql.NullFloat64
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.Genre, &bpm, &t.BPMRaw, &t.BPMSuggested, &t.BPMConfidence); err != nil {
+			return nil, 0, err
+		}
+		t.BPM = bpm.Float64
+		tracks = append(tracks, &t)
+	}
+	return tracks, total, rows.Err()
+}
+
+// ApplySuggestions moves the suggested tempo into bpm for the user's flagged
+// tracks, limited to ids when given. Like a manual BPM edit, the result is
+// marked user-edited so reanalysis won't revert it.
+func (r *Repository) ApplySuggestions(ctx context.Context, userID string, ids []string) (int64, error) {
+	query := `
+		UPDATE tracks
+		SET bpm = bpm_suggested, bpm_suggested = NULL, bpm_backend = NULL,
+		    analysis_status = 'user_edited', updated_at = CURRENT_TIMESTAMP
+		WHERE owner_user_id = ? AND deleted_at IS NULL AND bpm_suggested IS NOT NULL`
+	args := []any{userID}
+	if len(ids) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,17 @@
+package tempo
+
+import "github.com/gin-gonic/gin"
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/tempo")
+		{
+			r.GET("/ranges", handlers.GetRanges)
+			r.PUT("/ranges", handlers.SetRanges)
+			r.GET("/flagged", handlers.ListFlagged)
+			r.POST("/fix", handlers.Fix)
+		}
+	}
+}
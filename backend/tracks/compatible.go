@@ -0,0 +1,215 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/faraz525/home-music-server/backend/analysis"
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/playlists"
+	"github.com/gin-gonic/gin"
+)
+
+// Compatible-track defaults. A 6% tempo difference is about what a
+// turntable's ±8% pitch fader covers with room to spare.
+const (
+	defaultBPMTolerance = 6.0
+	maxBPMTolerance     = 20.0
+	defaultCompatible   = 20
+	maxCompatible       = 100
+)
+
+// Transition score weights: how well the keys mix matters most, then how
+// far the tempo has to move, then whether energy holds or lifts.
+const (
+	keyWeight    = 0.5
+	tempoWeight  = 0.35
+	energyWeight = 0.15
+	// halfDoubleFactor discounts tempo matches that need half or double time.
+	halfDoubleFactor = 0.9
+	// unknownEnergy scores a transition where either track lacks an energy
+	// rating: neither rewarded nor ruled out.
+	unknownEnergy = 0.7
+)
+
+// CompatibleOptions narrows a compatible-track search.
+type CompatibleOptions struct {
+	PlaylistID   string  // search this crate instead of the owner's library
+	BPMTolerance float64 // percent
+	EnergyBoost  bool    // include +2 and +7 energy-boost moves
+	Limit        int
+}
+
+// CompatibleTrack is a candidate next track and how well it follows on.
+type CompatibleTrack struct {
+	Track *imodels.Track `json:"track"`
+	Move  string         `json:"move"`
+	// TempoRatio is 1, or 2 / 0.5 when the track mixes at double / half time.
+	TempoRatio     float64 `json:"tempo_ratio"`
+	BPMDiffPercent float64 `json:"bpm_diff_percent"`
+	EnergyDelta    *int    `json:"energy_delta,omitempty"`
+	Score          float64 `json:"score"`
+}
+
+// energyScore rates an energy change: holding or lifting by one is ideal,
+// bigger jumps and drops less so.
+func energyScore(from, to *int) (float64, *int) {
+	if from == nil || to == nil {
+		return unknownEnergy, nil
+	}
+	d := *to - *from
+	switch {
+	case d == 0 || d == 1:
+		return 1, &d
+	case d == 2 || d == -1:
+		return 0.8, &d
+	}
+	return math.Max(0, 1-0.2*math.Abs(float64(d))), &d
+}
+
+// rankCompatible scores candidates as transitions out of source and returns
+// those within the tempo tolerance, best first.
+func rankCompatible(source *imodels.Track, candidates []*imodels.Track, opts CompatibleOptions) []*CompatibleTrack {
+	keys := analysis.CompatibleKeys(*source.MusicalKey, opts.EnergyBoost)
+	tolerance := opts.BPMTolerance / 100
+
+	out := []*CompatibleTrack{}
+	for _, t := range candidates {
+		if t.BPM == nil || t.MusicalKey == nil {
+			continue
+		}
+		move, ok := keys[*t.MusicalKey]
+		if !ok {
+			continue
+		}
+		ratio, diff, ok := analysis.TempoMatch(*source.BPM, *t.BPM, tolerance)
+		if !ok {
+			continue
+		}
+		tempo := 1.0
+		if tolerance > 0 {
+			tempo = 1 - diff/tolerance
+		}
+		if ratio != 1 {
+			tempo *= halfDoubleFactor
+		}
+		energy, delta := energyScore(source.Energy, t.Energy)
+		score := keyWeight*analysis.MoveWeight(move) + tempoWeight*tempo + energyWeight*energy
+		out = append(out, &CompatibleTrack{
+			Track:          t,
+			Move:           move,
+			TempoRatio:     ratio,
+			BPMDiffPercent: math.Round(diff*1000) / 10,
+			EnergyDelta:    delta,
+			Score:          math.Round(score*1000) / 10,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].BPMDiffPercent != out[j].BPMDiffPercent {
+			return out[i].BPMDiffPercent < out[j].BPMDiffPercent
+		}
+		return out[i].Track.ID < out[j].Track.ID
+	})
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+	return out
+}
+
+// CompatibleTracks finds tracks that mix harmonically out of source, from
+// its owner's library or the crate in opts.
+func (m *Manager) CompatibleTracks(ctx context.Context, source *imodels.Track, opts CompatibleOptions) ([]*CompatibleTrack, error) {
+	keys := analysis.CompatibleKeys(*source.MusicalKey, opts.EnergyBoost)
+	list := make([]string, 0, len(keys))
+	for k := range keys {
+		list = append(list, k)
+	}
+	candidates, err := m.repo.GetCompatibleCandidates(ctx, source.OwnerUserID, opts.PlaylistID, list, source.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch candidates: %w", err)
+	}
+	return rankCompatible(source, candidates, opts), nil
+}
+
+// parseCompatibleOptions reads bpm_tolerance, energy_boost, limit and
+// playlist_id from the query string.
+func parseCompatibleOptions(c *gin.Context) (CompatibleOptions, error) {
+	opts := CompatibleOptions{
+		PlaylistID:   c.Query("playlist_id"),
+		BPMTolerance: defaultBPMTolerance,
+		Limit:        defaultCompatible,
+	}
+	if s := c.Query("bpm_tolerance"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > maxBPMTolerance {
+			return opts, fmt.Errorf("bpm_tolerance must be a percentage from 0 to %g", maxBPMTolerance)
+		}
+		opts.BPMTolerance = v
+	}
+	if s := c.Query("energy_boost"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return opts, errors.New("energy_boost must be true or false")
+		}
+		opts.EnergyBoost = v
+	}
+	if s := c.Query("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > maxCompatible {
+			return opts, fmt.Errorf("limit must be from 1 to %d", maxCompatible)
+		}
+		opts.Limit = v
+	}
+	return opts, nil
+}
+
+// CompatibleHandler handles GET /api/tracks/:id/compatible: tracks from the
+// owner's library (or the crate in playlist_id) that mix harmonically out of
+// this one, ranked by transition score.
+func CompatibleHandler(mgr *Manager, pm *playlists.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		userRole := c.GetString("user_role")
+
+		opts, err := parseCompatibleOptions(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_request", "message": err.Error()}})
+			return
+		}
+
+		track, err := mgr.GetTrack(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "track_not_found", "message": "Track not found"}})
+			return
+		}
+		if track.OwnerUserID != userID && userRole != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "access_denied", "message": "Access denied"}})
+			return
+		}
+		if track.BPM == nil || track.MusicalKey == nil || analysis.CompatibleKeys(*track.MusicalKey, false) == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": gin.H{"code": "not_analyzed", "message": "track has no BPM or key yet"}})
+			return
+		}
+		if opts.PlaylistID != "" {
+			if err := pm.CanAccessPlaylist(opts.PlaylistID, userID); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "playlist_not_found", "message": "Crate not found"}})
+				return
+			}
+		}
+
+		compatible, err := mgr.CompatibleTracks(c.Request.Context(), track, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "failed to find compatible tracks"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"track": track, "compatible": compatible})
+	}
+}
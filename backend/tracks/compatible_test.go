@@ -0,0 +1,83 @@
+package tracks
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func track(id, key string, bpm float64, energy *int) *imodels.Track {
+	return &imodels.Track{ID: id, MusicalKey: &key, BPM: &bpm, Energy: energy}
+}
+
+func TestRankCompatible(t *testing.T) {
+	source := track("src", "8A", 124, ptr(6))
+	candidates := []*imodels.Track{
+		track("same", "8A", 124, ptr(6)),
+		track("up", "9A", 125, ptr(7)),
+		track("relative", "8B", 123, ptr(6)),
+		track("half", "7A", 62.5, ptr(6)),
+		track("boost", "10A", 124, ptr(7)),
+		track("clash", "3B", 124, ptr(6)),
+		track("fast", "8A", 140, ptr(6)),
+	}
+
+	got := rankCompatible(source, candidates, CompatibleOptions{BPMTolerance: 6})
+	var ids []string
+	for _, c := range got {
+		ids = append(ids, c.Track.ID)
+	}
+	want := []string{"same", "up", "relative", "half"}
+	if len(ids) != len(want) {
+		t.Fatalf("ranked = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ranked = %v, want %v", ids, want)
+		}
+	}
+	if got[3].TempoRatio != 2 || got[3].Move != "-1" {
+		t.Errorf("half-time match = %+v", got[3])
+	}
+	if got[0].Score != 100 {
+		t.Errorf("same key, same tempo, same energy score = %v, want 100", got[0].Score)
+	}
+
+	boosted := rankCompatible(source, candidates, CompatibleOptions{BPMTolerance: 6, EnergyBoost: true, Limit: 10})
+	found := false
+	for _, c := range boosted {
+		if c.Track.ID == "boost" && c.Move == "+2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("energy boost move missing with energy_boost")
+	}
+}
+
+func TestParseCompatibleOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(query string) (CompatibleOptions, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/tracks/x/compatible?"+query, nil)
+		return parseCompatibleOptions(c)
+	}
+
+	opts, err := parse("")
+	if err != nil || opts.BPMTolerance != defaultBPMTolerance || opts.Limit != defaultCompatible || opts.EnergyBoost {
+		t.Errorf("defaults = %+v, %v", opts, err)
+	}
+	opts, err = parse("bpm_tolerance=3.5&energy_boost=true&limit=5&playlist_id=p1")
+	if err != nil || opts.BPMTolerance != 3.5 || !opts.EnergyBoost || opts.Limit != 5 || opts.PlaylistID != "p1" {
+		t.Errorf("parsed = %+v, %v", opts, err)
+	}
+	for _, q := range []string{"bpm_tolerance=50", "energy_boost=maybe", "limit=0"} {
+		if _, err := parse(q); err == nil {
+			t.Errorf("parse(%q) accepted invalid input", q)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tracks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
+)
+
+// maxGenreFacets caps the genre facet at the most common genres.
+const maxGenreFacets = 50
+
+// Facets counts the tracks matching scope, q (if not nil) and f by each of
+// the named facets (see search.FacetNames). scope is a condition on tracks
+// aliased t that picks the list: a user's library, a crate or unsorted.
+// Trashed tracks are never counted.
+func (m *Manager) Facets(ctx context.Context, scope string, scopeArgs []any, q *search.Query, userID string, f *search.ListFilter, names []string) (*imodels.Facets, error) {
+	from := "tracks t"
+	where := scope + " AND t.deleted_at IS NULL"
+	args := append([]any{}, scopeArgs...)
+	if q != nil {
+		compiled := q.Compile(userID)
+		qcond, qargs := compiled.Where()
+		from += compiled.Join()
+		where += qcond
+		args = append(args, qargs...)
+	}
+	cond, fargs := f.Where("t.")
+	where += cond
+	args = append(args, fargs...)
+
+	facets, err := m.repo.Facets(ctx, from, where, args, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count facets: %w", err)
+	}
+	return facets, nil
+}
+
+// LibraryScope is the Facets scope for userID's whole library.
+func LibraryScope(userID string) (string, []any) {
+	return "t.owner_user_id = ?", []any{userID}
+}
+
+// Facets runs one grouped count per named facet over the tracks in from
+// matching where.
+func (r *Repository) Facets(ctx context.Context, from, where string, args []any, names []string) (*imodels.Facets, error) {
+	facets := &imodels.Facets{}
+	if slices.Contains(names, "genre") {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT MIN(t.genre), COUNT(*) FROM `+from+`
+			WHERE `+where+` AND t.genre IS NOT NULL AND t.genre != ''
+			GROUP BY t.genre COLLATE NOCASE
+			ORDER BY COUNT(*) DESC, MIN(t.genre) COLLATE NOCASE
+			LIMIT ?
+		`, append(args, maxGenreFacets)...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		facets.Genre = []imodels.GenreFacet{}
+		for rows.Next() {
+			var g imodels.GenreFacet
+			if err := rows.Scan(&g.Genre, &g.Count); err != nil {
+				return nil, err
+			}
+			facets.Genre = append(facets.Genre, g)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(names, "key") {
+		// Camelot keys in wheel order, 1A, 1B, 2A …
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT t.musical_key, COUNT(*) FROM `+from+`
+			WHERE `+where+` AND t.musical_key IS NOT NULL
+			GROUP BY t.musical_key
+			ORDER BY CAST(t.musical_key AS INTEGER), t.musical_key
+		`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		facets.Key = []imodels.KeyFacet{}
+		for rows.Next() {
+			var k imodels.KeyFacet
+			if err := rows.Scan(&k.MusicalKey, &k.Count); err != nil {
+				return nil, err
+			}
+			facets.Key = append(facets.Key, k)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(names, "bpm") {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT CAST(t.bpm / ? AS INTEGER) AS bucket, COUNT(*) FROM `+from+`
+			WHERE `+where+` AND t.bpm IS NOT NULL
+			GROUP BY bucket
+			ORDER BY bucket
+		`, append([]any{search.BPMBucketWidth}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		facets.BPM = []imodels.BPMFacet{}
+		for rows.Next() {
+			var bucket, count int
+			if err := rows.Scan(&bucket, &count); err != nil {
+				return nil, err
+			}
+			facets.BPM = append(facets.BPM, imodels.BPMFacet{
+				Min:   float64(bucket * search.BPMBucketWidth),
+				Max:   float64((bucket + 1) * search.BPMBucketWidth),
+				Count: count,
+			})
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return facets, nil
+}
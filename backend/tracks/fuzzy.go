@@ -0,0 +1,146 @@
+package tracks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
+	"github.com/faraz525/home-music-server/backend/utils"
+)
+
+// maxFuzzyCandidates caps how many trigram matches fuzzy search scores,
+// best bm25 first, and so how many close matches it can return.
+const maxFuzzyCandidates = 500
+
+// FuzzySearch finds tracks whose title, artist, album, genre or filename
+// come close to the free text in q, so typos like "deadmou5" still find
+// "deadmau5". The rest of q and f must match as usual. Tracks come
+// closest first unless f sorts them; the list is marked Fuzzy.
+func (m *Manager) FuzzySearch(ctx context.Context, q *search.Query, userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	words, rest := q.Fuzzy()
+	match := search.TrigramMatch("", words)
+	if match == "" {
+		return utils.NewTrackList(nil, 0, limit, offset), nil
+	}
+
+	candidates, err := m.repo.FuzzyCandidates(ctx, userID, match, rest, f, maxFuzzyCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find close matches: %w", err)
+	}
+	type scored struct {
+		id    string
+		score float64
+	}
+	var matches []scored
+	for _, c := range candidates {
+		if score, ok := search.FuzzyScore(words, c.texts...); ok {
+			matches = append(matches, scored{c.id, score})
+		}
+	}
+	// Stable, so equally close tracks keep their bm25 order
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.id
+	}
+
+	tracks, err := m.repo.TracksByIDs(ctx, ids, f, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch close matches: %w", err)
+	}
+	list := utils.NewTrackList(tracks, len(ids), limit, offset)
+	list.Fuzzy = true
+	return list, nil
+}
+
+// fuzzyCandidate is a track's ID and the text fuzzy search scores it on.
+type fuzzyCandidate struct {
+	id    string
+	texts []string
+}
+
+// FuzzyCandidates returns up to max of userID's tracks matching the
+// tracks_trigram query match, rest (if not nil) and f, best bm25 first.
+func (r *Repository) FuzzyCandidates(ctx context.Context, userID, match string, rest *search.Query, f *search.ListFilter, max int) ([]fuzzyCandidate, error) {
+	compiled := &search.Compiled{}
+	if rest != nil {
+		compiled = rest.Compile(userID)
+	}
+	qcond, qargs := compiled.Where()
+	cond, fargs := f.Where("t.")
+	args := append(append([]any{userID, match}, qargs...), fargs...)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.title, t.artist, t.album, t.genre, t.original_filename
+		FROM tracks t
+		INNER JOIN tracks_trigram tg ON t.id = tg.track_id`+compiled.Join()+`
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NULL AND tracks_trigram MATCH ?`+qcond+cond+`
+		ORDER BY bm25(tracks_trigram, 0, 10, 8, 4, 2, 1)
+		LIMIT ?
+	`, append(args, max)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []fuzzyCandidate
+	for rows.Next() {
+		var id string
+		var title, artist, album, genre sql.NullString
+		var filename string
+		if err := rows.Scan(&id, &title, &artist, &album, &genre, &filename); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, fuzzyCandidate{id, []string{title.String, artist.String, album.String, genre.String, filename}})
+	}
+	return candidates, rows.Err()
+}
+
+// TracksByIDs returns a page of the tracks with the given IDs, ordered by
+// f or else in the order given.
+func (r *Repository) TracksByIDs(ctx context.Context, ids []string, f *search.ListFilter, limit, offset int) ([]*imodels.Track, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, 0, len(ids)+3)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	// The given order is the fallback, for when f doesn't sort.
+	order, orderArgs := f.OrderBy("t.", "")
+	if order == "" {
+		order, orderArgs = "instr(?, ',' || t.id || ',')", []any{"," + strings.Join(ids, ",") + ","}
+	}
+	args = append(args, orderArgs...)
+	args = append(args, limit, offset)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
+			t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
+			t.sample_rate, t.bitrate,
+			t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+			t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+			t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+			t.file_path, t.cover_path, t.created_at, t.updated_at
+		FROM tracks t
+		WHERE t.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []*imodels.Track
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
@@ -0,0 +1,39 @@
+package tracks
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GridHandler handles GET /api/tracks/:id/grid: the beatgrid, first downbeat
+// and auto-generated cues. Tracks get a grid when analysis finds a tempo;
+// until then (or if detection failed) the response is 404 grid_not_found.
+func GridHandler(mgr *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trackID := c.Param("id")
+		userID := c.GetString("user_id")
+		userRole := c.GetString("user_role")
+
+		track, err := mgr.GetTrack(c.Request.Context(), trackID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "track_not_found", "message": "Track not found"}})
+			return
+		}
+		if track.OwnerUserID != userID && userRole != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "access_denied", "message": "Access denied"}})
+			return
+		}
+
+		grid, err := mgr.GetGrid(c.Request.Context(), trackID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "failed to load beatgrid"}})
+			return
+		}
+		if grid == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "grid_not_found", "message": "track has no beatgrid yet"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"grid": grid})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
-	"strings"
 
+	"github.com/faraz525/home-music-server/backend/analysis"
 	"github.com/gin-gonic/gin"
 )
 
@@ -50,13 +50,14 @@ func PatchHandler(mgr *Manager) gin.HandlerFunc {
 			return
 		}
 		if req.MusicalKey != nil {
-			// Normalize to uppercase (DJs routinely type "8a"); validate after.
-			upper := strings.ToUpper(*req.MusicalKey)
-			req.MusicalKey = &upper
-			if !isValidCamelot(*req.MusicalKey) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_key", "message": "musical_key must be Camelot notation like 8A or 12B"}})
+			// Accept whatever notation the user reads keys in ("8a", "1m",
+			// "Am"); storage is always Camelot.
+			key, ok := analysis.ParseKey(*req.MusicalKey)
+			if !ok || !isValidCamelot(key) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_key", "message": "musical_key must be a key like 8A (Camelot), 1m (Open Key) or Am"}})
 				return
 			}
+			req.MusicalKey = &key
 		}
 
 		trackID := c.Param("id")
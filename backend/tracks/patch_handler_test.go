@@ -67,3 +67,19 @@ func TestPatchHandler_RejectsEmptyBody(t *testing.T) {
 		t.Errorf("status = %d, want 400 (empty body)", w.Code)
 	}
 }
+
+func TestPatchHandler_KeyNotations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PATCH("/api/tracks/:id", PatchHandler(nil))
+
+	for key, want := range map[string]int{"8a": 204, "1m": 204, "F#m": 204, "A minor": 204, "13A": 400, "H": 400} {
+		req := httptest.NewRequest("PATCH", "/api/tracks/abc", strings.NewReader(`{"musical_key": "`+key+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("musical_key %q: status = %d, want %d", key, w.Code, want)
+		}
+	}
+}
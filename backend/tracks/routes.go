@@ -15,6 +15,8 @@ func Routes(m *Manager, pm *playlists.Manager) func(*gin.RouterGroup) {
 		g.GET("/:id/stream", StreamHandler(m))
 		g.GET("/:id/cover", CoverHandler(m))
 		g.GET("/:id/download", DownloadHandler(m))
+		g.GET("/:id/grid", GridHandler(m))
+		g.GET("/:id/compatible", CompatibleHandler(m, pm))
 		g.DELETE("/:id", DeleteHandler(m))
 		g.GET("/:id", GetHandler(m))
 		g.PATCH("/:id", PatchHandler(m))
@@ -23,10 +25,7 @@ func Routes(m *Manager, pm *playlists.Manager) func(*gin.RouterGroup) {
 	admin := g.Group("/admin")
 	admin.Use(auth.AdminMiddleware())
 	{
-		admin.GET("", func(c *gin.Context) {
-			// TODO: Admin dashboard stats
-			c.JSON(200, gin.H{"message": "Admin access granted"})
-		})
+		admin.GET("", AdminStatsHandler(m))
 		admin.POST("/sanitize", SanitizeAllHandler(m))
 	}
 }
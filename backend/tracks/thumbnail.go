@@ -0,0 +1,121 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+)
+
+// ThumbnailSizes are the sizes the cover endpoint's size= takes: the
+// longest side of the thumbnail, in pixels.
+var ThumbnailSizes = []int{64, 128, 256}
+
+// thumbnailPath is where a cover's thumbnail of the given size is kept:
+// next to the cover, in the track's directory.
+func thumbnailPath(coverPath string, size int) string {
+	return filepath.Join(filepath.Dir(coverPath), fmt.Sprintf("cover_%d.jpg", size))
+}
+
+// CoverThumbnail returns the path of a thumbnail of a cover, no more than
+// size pixels either way. It is made on first request and again whenever
+// the cover is newer. Covers already that small, or in a format Go can't
+// decode (WebP), come back as they are.
+func (m *Manager) CoverThumbnail(ctx context.Context, coverPath string, size int) (string, error) {
+	coverFull, ok := m.storage.ResolveFullPath(coverPath)
+	if !ok {
+		return "", fmt.Errorf("failed to resolve cover path")
+	}
+	thumbPath := thumbnailPath(coverPath, size)
+	thumbFull, ok := m.storage.ResolveFullPath(thumbPath)
+	if !ok {
+		return "", fmt.Errorf("failed to resolve thumbnail path")
+	}
+	coverInfo, err := os.Stat(coverFull)
+	if err != nil {
+		return "", err
+	}
+	if thumbInfo, err := os.Stat(thumbFull); err == nil && !thumbInfo.ModTime().Before(coverInfo.ModTime()) {
+		return thumbPath, nil
+	}
+
+	f, err := os.Open(coverFull)
+	if err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if errors.Is(err, image.ErrFormat) {
+		return coverPath, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decode cover: %w", err)
+	}
+	if b := src.Bounds(); b.Dx() <= size && b.Dy() <= size {
+		return coverPath, nil
+	}
+
+	tmp := thumbFull + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	if err := jpeg.Encode(out, shrink(src, size), &jpeg.Options{Quality: 85}); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to close thumbnail: %w", err)
+	}
+	if err := os.Rename(tmp, thumbFull); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to rename thumbnail: %w", err)
+	}
+	return thumbPath, nil
+}
+
+// removeThumbnails deletes a cover's cached thumbnails (best-effort).
+func (m *Manager) removeThumbnails(ctx context.Context, coverPath string) {
+	for _, size := range ThumbnailSizes {
+		_ = m.storage.Delete(ctx, thumbnailPath(coverPath, size))
+	}
+}
+
+// shrink scales src down to fit in a size×size square, keeping its aspect
+// ratio. Each output pixel averages the block of source pixels it covers,
+// which is plenty for cover art.
+func shrink(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
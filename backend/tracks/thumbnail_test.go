@@ -0,0 +1,31 @@
+package tracks
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestShrink(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 600; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x < 300 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	dst := shrink(src, 64)
+	if b := dst.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Fatalf("shrunk to %dx%d, want 64x32", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := dst.At(0, 0).RGBA(); r>>8 != 255 {
+		t.Errorf("left pixel red = %d, want 255", r>>8)
+	}
+	if r, _, _, _ := dst.At(63, 31).RGBA(); r>>8 != 0 {
+		t.Errorf("right pixel red = %d, want 0", r>>8)
+	}
+}
@@ -3,10 +3,15 @@ package tracks
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"strings"
 
+	"github.com/faraz525/home-music-server/backend/analysis"
 	"github.com/faraz525/home-music-server/backend/internal/db"
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
+	"github.com/faraz525/home-music-server/backend/search"
 	"github.com/faraz525/home-music-server/backend/utils"
 )
 
@@ -25,14 +30,16 @@ func NewRepository(db *db.DB) *Repository {
 func scanTrack(row interface{ Scan(dest ...any) error }) (*imodels.Track, error) {
 	var t imodels.Track
 	var duration, bpm, bpmConf, keyConf sql.NullFloat64
-	var title, artist, album, genre, key, coverPath sql.NullString
+	var title, artist, album, genre, key, bpmBackend, keyBackend, coverPath sql.NullString
 	var year, sampleRate, bitrate sql.NullInt64
 	var analyzedAt sql.NullTime
 
 	err := row.Scan(
 		&t.ID, &t.OwnerUserID, &t.OriginalFilename, &t.ContentType, &t.SizeBytes,
 		&duration, &title, &artist, &album, &genre, &year, &sampleRate, &bitrate,
-		&bpm, &bpmConf, &key, &keyConf, &analyzedAt, &t.AnalysisStatus,
+		&bpm, &bpmConf, &key, &keyConf, &analyzedAt, &t.AnalysisStatus, &bpmBackend, &keyBackend,
+		&t.Energy, &t.Danceability, &t.DynamicComplexity, &t.LoudnessLUFS, &t.SpectralCentroid, &t.OnsetRate, &t.Mood, &t.BPMRaw, &t.BPMSuggested,
+		&t.QualityVerdict, &t.QualityConfidence, &t.SpectralCutoffHz,
 		&t.FilePath, &coverPath, &t.CreatedAt, &t.UpdatedAt,
 	)
 	if err != nil {
@@ -85,6 +92,14 @@ func scanTrack(row interface{ Scan(dest ...any) error }) (*imodels.Track, error)
 	if analyzedAt.Valid {
 		t.AnalyzedAt = &analyzedAt.Time
 	}
+	if bpmBackend.Valid {
+		v := bpmBackend.String
+		t.BPMBackend = &v
+	}
+	if keyBackend.Valid {
+		v := keyBackend.String
+		t.KeyBackend = &v
+	}
 	if coverPath.Valid {
 		v := coverPath.String
 		t.CoverPath = &v
@@ -98,11 +113,11 @@ func (r *Repository) CreateTrack(ctx context.Context, track *imodels.Track) (*im
 
 	_, err := r.db.ExecContext(ctx,
 		`INSERT INTO tracks (id, owner_user_id, original_filename, content_type, size_bytes,
-			duration_seconds, title, artist, album, genre, year, sample_rate, bitrate, file_path, cover_path, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			duration_seconds, title, artist, album, genre, year, sample_rate, bitrate, isrc, file_path, cover_path, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, track.OwnerUserID, track.OriginalFilename, track.ContentType, track.SizeBytes,
 		track.DurationSeconds, track.Title, track.Artist, track.Album, track.Genre, track.Year,
-		track.SampleRate, track.Bitrate, track.FilePath, track.CoverPath, track.CreatedAt, track.UpdatedAt,
+		track.SampleRate, track.Bitrate, track.ISRC, track.FilePath, track.CoverPath, track.CreatedAt, track.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -112,30 +127,53 @@ func (r *Repository) CreateTrack(ctx context.Context, track *imodels.Track) (*im
 	return track, nil
 }
 
-// GetTracks retrieves tracks for a user with pagination
-func (r *Repository) GetTracks(ctx context.Context, userID string, limit, offset int) ([]*imodels.Track, error) {
+// GetTracks retrieves tracks for a user with pagination, narrowed and
+// ordered by f
+func (r *Repository) GetTracks(ctx context.Context, userID string, f *search.ListFilter, limit, offset int) ([]*imodels.Track, string, error) {
+	keys := f.PageKeys("", pagination.Key{Expr: "created_at", Desc: true})
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, "", err
+	}
+	cond, args := f.Where("")
 	query := `SELECT id, owner_user_id, original_filename, content_type, size_bytes,
 		duration_seconds, title, artist, album, genre, year, sample_rate, bitrate,
-		bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status,
-		file_path, cover_path, created_at, updated_at
-		FROM tracks WHERE owner_user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
-
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+		bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status, bpm_backend, key_backend,
+		energy, danceability, dynamic_complexity, loudness_lufs, spectral_centroid, onset_rate, mood, bpm_raw, bpm_suggested,
+		quality_verdict, quality_confidence, spectral_cutoff_hz,
+		file_path, cover_path, created_at, updated_at` + pagination.Columns(keys) + `
+		FROM tracks WHERE owner_user_id = ? AND deleted_at IS NULL` + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + ` LIMIT ? OFFSET ?`
+
+	args = append(append([]any{userID}, args...), afterArgs...)
+	rows, err := r.db.QueryContext(ctx, query, pagination.QueryArgs(keys, args, limit+1, offset)...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
+	return scanTrackPage(rows, keys, limit)
+}
 
+// scanTrackPage reads a page of tracks selected with pagination.Columns(keys)
+// and limit+1 rows, returning the first limit and the next page's cursor.
+func scanTrackPage(rows *sql.Rows, keys []pagination.Key, limit int) ([]*imodels.Track, string, error) {
+	sc := pagination.NewScanner(rows, keys)
 	var tracks []*imodels.Track
 	for rows.Next() {
-		track, err := scanTrack(rows)
+		track, err := scanTrack(sc)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		tracks = append(tracks, track)
 	}
-
-	return tracks, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	next := sc.Next(limit)
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, next, nil
 }
 
 // GetAllTracks retrieves all tracks with optional FTS5 search (admin only)
@@ -149,12 +187,14 @@ func (r *Repository) GetAllTracks(ctx context.Context, limit, offset int, search
 			SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 				t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 				t.sample_rate, t.bitrate,
-				t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
+				t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+				t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+				t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
 				t.file_path, t.cover_path, t.created_at, t.updated_at
 			FROM tracks t
 			INNER JOIN tracks_fts fts ON t.id = fts.track_id
-			WHERE tracks_fts MATCH ?
-			ORDER BY fts.rank, t.created_at DESC
+			WHERE tracks_fts MATCH ? AND t.deleted_at IS NULL
+			ORDER BY `+search.Rank+`, t.created_at DESC
 			LIMIT ? OFFSET ?
 		`
 		ftsQuery := prepareFTS5Query(searchQuery)
@@ -164,9 +204,12 @@ func (r *Repository) GetAllTracks(ctx context.Context, limit, offset int, search
 		query = `
 			SELECT id, owner_user_id, original_filename, content_type, size_bytes,
 				duration_seconds, title, artist, album, genre, year, sample_rate, bitrate,
-				bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status,
+				bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status, bpm_backend, key_backend,
+				energy, danceability, dynamic_complexity, loudness_lufs, spectral_centroid, onset_rate, mood, bpm_raw, bpm_suggested,
+				quality_verdict, quality_confidence, spectral_cutoff_hz,
 				file_path, cover_path, created_at, updated_at
 			FROM tracks
+			WHERE deleted_at IS NULL
 			ORDER BY created_at DESC
 			LIMIT ? OFFSET ?
 		`
@@ -191,86 +234,108 @@ func (r *Repository) GetAllTracks(ctx context.Context, limit, offset int, search
 	return tracks, rows.Err()
 }
 
-// GetTrackByID retrieves a single track by ID
+// GetTrackByID retrieves a single track by ID, unless it is in the trash
 func (r *Repository) GetTrackByID(ctx context.Context, trackID string) (*imodels.Track, error) {
+	return r.getTrack(ctx, trackID, " AND deleted_at IS NULL")
+}
+
+// GetTrackByIDWithTrashed retrieves a single track by ID, in the trash or not
+func (r *Repository) GetTrackByIDWithTrashed(ctx context.Context, trackID string) (*imodels.Track, error) {
+	return r.getTrack(ctx, trackID, "")
+}
+
+// getTrack retrieves a single track by ID; trash narrows the WHERE clause
+func (r *Repository) getTrack(ctx context.Context, trackID, trash string) (*imodels.Track, error) {
 	row := r.db.QueryRowContext(ctx,
 		`SELECT id, owner_user_id, original_filename, content_type, size_bytes,
 		duration_seconds, title, artist, album, genre, year, sample_rate, bitrate,
-		bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status,
+		bpm, bpm_confidence, musical_key, key_confidence, analyzed_at, analysis_status, bpm_backend, key_backend,
+		energy, danceability, dynamic_complexity, loudness_lufs, spectral_centroid, onset_rate, mood, bpm_raw, bpm_suggested,
+		quality_verdict, quality_confidence, spectral_cutoff_hz,
 		file_path, cover_path, created_at, updated_at
-		FROM tracks WHERE id = ?`,
+		FROM tracks WHERE id = ?`+trash,
 		trackID,
 	)
 	return scanTrack(row)
 }
 
+// TrashTrack moves a track to its owner's trash. It reports whether the
+// track was found outside the trash.
+func (r *Repository) TrashTrack(ctx context.Context, trackID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE tracks SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", trackID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 // DeleteTrack deletes a track by ID
 func (r *Repository) DeleteTrack(ctx context.Context, trackID string) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM tracks WHERE id = ?", trackID)
 	return err
 }
 
-// GetTracksCount returns the total count of tracks for a user
-func (r *Repository) GetTracksCount(ctx context.Context, userID string) (int, error) {
+// GetTracksCount returns the total count of a user's tracks matching f
+func (r *Repository) GetTracksCount(ctx context.Context, userID string, f *search.ListFilter) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracks WHERE owner_user_id = ?", userID).Scan(&count)
+	cond, args := f.Where("")
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracks WHERE owner_user_id = ? AND deleted_at IS NULL"+cond,
+		append([]any{userID}, args...)...).Scan(&count)
 	return count, err
 }
 
 // GetAllTracksCount returns the total count of all tracks
 func (r *Repository) GetAllTracksCount(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracks").Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracks WHERE deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
-// SearchTracks searches tracks by title, artist, album, genre, or filename using FTS5
-// FTS5 provides much faster full-text search than LIKE, especially on Raspberry Pi
-func (r *Repository) SearchTracks(ctx context.Context, query string, userID string, limit, offset int) ([]*imodels.Track, error) {
-	// FTS5 query - searches across all indexed fields
-	// Use double quotes for phrase matching or just the term for any word matching
+// SearchTracks searches a user's tracks with a parsed query (see package
+// search), narrowed and ordered by f. Text terms use FTS5, which is much
+// faster than LIKE, especially on Raspberry Pi
+func (r *Repository) SearchTracks(ctx context.Context, q *search.Query, userID string, f *search.ListFilter, limit, offset int) ([]*imodels.Track, string, error) {
+	compiled := q.Compile(userID)
+	keys := f.PageKeys("t.", compiled.PageKeys(pagination.Key{Expr: "t.created_at", Desc: true})...)
+	after, afterArgs, err := f.After(keys)
+	if err != nil {
+		return nil, "", err
+	}
+	qcond, qargs := compiled.Where()
+	cond, args := f.Where("t.")
 	searchQuery := `
 		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
 			t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
 			t.sample_rate, t.bitrate,
-			t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status,
-			t.file_path, t.cover_path, t.created_at, t.updated_at
-		FROM tracks t
-		INNER JOIN tracks_fts fts ON t.id = fts.track_id
-		WHERE t.owner_user_id = ?
-		AND tracks_fts MATCH ?
-		ORDER BY fts.rank, t.created_at DESC
+			t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+			t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+			t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+			t.file_path, t.cover_path, t.created_at, t.updated_at` + pagination.Columns(keys) + `
+		FROM tracks t` + compiled.Join() + `
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NULL` + qcond + cond + after + `
+		ORDER BY ` + pagination.OrderBy(keys) + `
 		LIMIT ? OFFSET ?
 	`
 
-	// Escape special FTS5 characters and prepare the query
-	// FTS5 uses OR by default for multiple terms
-	ftsQuery := prepareFTS5Query(query)
-
-	// Debug logging
-	println("[SearchTracks] Original query:", query)
-	println("[SearchTracks] FTS5 query:", ftsQuery)
-	println("[SearchTracks] UserID:", userID)
-
-	rows, err := r.db.QueryContext(ctx, searchQuery, userID, ftsQuery, limit, offset)
-	if err != nil {
-		println("[SearchTracks] ERROR:", err.Error())
-	}
+	args = append(append(append([]any{userID}, qargs...), args...), afterArgs...)
+	rows, err := r.db.QueryContext(ctx, searchQuery, pagination.QueryArgs(keys, args, limit+1, offset)...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
+	return scanTrackPage(rows, keys, limit)
+}
 
-	var tracks []*imodels.Track
-	for rows.Next() {
-		track, err := scanTrack(rows)
-		if err != nil {
-			return nil, err
-		}
-		tracks = append(tracks, track)
-	}
-
-	return tracks, rows.Err()
+// SearchTracksCount returns the number of a user's tracks matching q and f
+func (r *Repository) SearchTracksCount(ctx context.Context, q *search.Query, userID string, f *search.ListFilter) (int, error) {
+	compiled := q.Compile(userID)
+	qcond, qargs := compiled.Where()
+	cond, args := f.Where("t.")
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tracks t"+compiled.Join()+" WHERE t.owner_user_id = ? AND t.deleted_at IS NULL"+qcond+cond,
+		append(append([]any{userID}, qargs...), args...)...).Scan(&count)
+	return count, err
 }
 
 // prepareFTS5Query escapes and formats a user query for FTS5
@@ -332,12 +397,13 @@ func (r *Repository) UpdateAnalysisOverride(ctx context.Context, trackID string,
 	// Build a dynamic SET clause so we only update provided fields.
 	sets := []string{"analysis_status = 'user_edited'", "analysis_error = NULL", "next_retry_at = NULL", "updated_at = CURRENT_TIMESTAMP"}
 	args := []any{}
+	// An overridden field no longer came from an analysis backend.
 	if bpm != nil {
-		sets = append(sets, "bpm = ?")
+		sets = append(sets, "bpm = ?", "bpm_backend = NULL", "bpm_suggested = NULL")
 		args = append(args, *bpm)
 	}
 	if musicalKey != nil {
-		sets = append(sets, "musical_key = ?")
+		sets = append(sets, "musical_key = ?", "key_backend = NULL")
 		args = append(args, *musicalKey)
 	}
 	args = append(args, trackID)
@@ -345,3 +411,149 @@ func (r *Repository) UpdateAnalysisOverride(ctx context.Context, trackID string,
 	_, err := r.db.ExecContext(ctx, query, args...)
 	return err
 }
+
+// LibraryStats summarizes the whole library for the admin dashboard.
+type LibraryStats struct {
+	Tracks          int            `json:"tracks"`
+	Owners          int            `json:"owners"`
+	SizeBytes       int64          `json:"size_bytes"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Analysis        map[string]int `json:"analysis"`
+}
+
+// GetLibraryStats returns track, owner, size and duration totals plus track
+// counts by analysis status.
+func (r *Repository) GetLibraryStats(ctx context.Context) (*LibraryStats, error) {
+	stats := &LibraryStats{Analysis: make(map[string]int)}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT owner_user_id),
+		       COALESCE(SUM(size_bytes), 0), COALESCE(SUM(duration_seconds), 0)
+		FROM tracks
+	`).Scan(&stats.Tracks, &stats.Owners, &stats.SizeBytes, &stats.DurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT analysis_status, COUNT(*) FROM tracks GROUP BY analysis_status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		stats.Analysis[status] = n
+	}
+	return stats, rows.Err()
+}
+
+// GetGrid returns the stored beatgrid and cues for a track, or nil if it has
+// none yet. Beats are unpacked from the grid, or expanded from it when none
+// were detected.
+func (r *Repository) GetGrid(ctx context.Context, trackID string) (*analysis.Grid, error) {
+	g := &analysis.Grid{}
+	var beats []byte
+	var cues string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT bpm, beat_interval, first_beat, first_downbeat, beat_count, beats, cues
+		FROM track_grids WHERE track_id = ?
+	`, trackID).Scan(&g.BPM, &g.Interval, &g.FirstBeat, &g.FirstDownbeat, &g.BeatCount, &beats, &cues)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if beats != nil {
+		if g.Beats, err = analysis.DecodeBeats(beats, g.FirstBeat, g.Interval); err != nil {
+			return nil, err
+		}
+		g.BeatsDetected = true
+	} else {
+		g.Beats = analysis.GridBeats(g.FirstBeat, g.Interval, g.BeatCount)
+	}
+	if err := json.Unmarshal([]byte(cues), &g.Cues); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Cue is one of a user's cues or loops on a track, carried into downloads.
+type Cue struct {
+	Kind       string
+	Slot       int // hot cues only
+	Label      string
+	Seconds    float64
+	EndSeconds float64 // loops only
+}
+
+// GetCues returns userID's cues on a track in position order.
+func (r *Repository) GetCues(ctx context.Context, trackID, userID string) ([]Cue, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT kind, COALESCE(slot, 0), label, position_seconds, COALESCE(end_seconds, 0)
+		FROM track_cues
+		WHERE track_id = ? AND user_id = ?
+		ORDER BY position_seconds
+	`, trackID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Cue
+	for rows.Next() {
+		var c Cue
+		if err := rows.Scan(&c.Kind, &c.Slot, &c.Label, &c.Seconds, &c.EndSeconds); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetCompatibleCandidates returns analyzed tracks in one of keys, other
+// than excludeID, from the user's library or, when playlistID is set, from
+// that crate.
+func (r *Repository) GetCompatibleCandidates(ctx context.Context, userID, playlistID string, keys []string, excludeID string) ([]*imodels.Track, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	scope := "t.owner_user_id = ?"
+	args := []any{userID}
+	if playlistID != "" {
+		scope = "t.id IN (SELECT track_id FROM playlist_tracks WHERE playlist_id = ?)"
+		args = []any{playlistID}
+	}
+	args = append(args, excludeID)
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.owner_user_id, t.original_filename, t.content_type, t.size_bytes,
+			t.duration_seconds, t.title, t.artist, t.album, t.genre, t.year,
+			t.sample_rate, t.bitrate,
+			t.bpm, t.bpm_confidence, t.musical_key, t.key_confidence, t.analyzed_at, t.analysis_status, t.bpm_backend, t.key_backend,
+			t.energy, t.danceability, t.dynamic_complexity, t.loudness_lufs, t.spectral_centroid, t.onset_rate, t.mood, t.bpm_raw, t.bpm_suggested,
+			t.quality_verdict, t.quality_confidence, t.spectral_cutoff_hz,
+			t.file_path, t.cover_path, t.created_at, t.updated_at
+		FROM tracks t
+		WHERE `+scope+` AND t.id != ? AND t.deleted_at IS NULL AND t.bpm IS NOT NULL
+		  AND t.musical_key IN (?`+strings.Repeat(", ?", len(keys)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []*imodels.Track
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
@@ -2,17 +2,22 @@ package tracks
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime"
 	"net/http"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/faraz525/home-music-server/backend/internal/pagination"
 	"github.com/faraz525/home-music-server/backend/playlists"
+	"github.com/faraz525/home-music-server/backend/search"
 	"github.com/gin-gonic/gin"
 
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
@@ -36,6 +41,19 @@ func SanitizeAllHandler(manager *Manager) gin.HandlerFunc {
 	}
 }
 
+// AdminStatsHandler returns library-wide totals and analysis status counts.
+// Per-track analysis detail lives under /api/analysis.
+func AdminStatsHandler(manager *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := manager.LibraryStats(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "server_error", "message": "Failed to load library stats"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"stats": stats})
+	}
+}
+
 type UploadRequest struct {
 	Title  string `form:"title"`
 	Artist string `form:"artist"`
@@ -127,54 +145,100 @@ func ListHandler(manager *Manager, playlistsManager *playlists.Manager) gin.Hand
 			offset = 0
 		}
 
+		filter, err := search.ParseListFilter(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_filter", "message": err.Error()}})
+			return
+		}
+		filter.UserID = c.GetString("user_id")
+
+		facets, err := search.ParseFacets(c.Query("facets"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_filter", "message": err.Error()}})
+			return
+		}
+
+		var query *search.Query
+		if strings.TrimSpace(q) != "" {
+			query, err = search.Parse(q)
+			if typing, _ := strconv.ParseBool(c.Query("typing")); err != nil && typing {
+				// Search-as-you-type: half a query is still worth searching.
+				query, err = search.Text(q), nil
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_query", "message": err.Error()}})
+				return
+			}
+		}
+
 		var trackList *imodels.TrackList
 
 		// Handle search queries (prioritize search if present)
-		if q != "" {
+		if query != nil {
 			// If searching with a specific playlist/crate, filter results
 			if playlistID != "" {
 				if playlistID == "unsorted" {
 					// Search within unsorted tracks only
-					trackList, err = playlistsManager.SearchUnsortedTracks(userID.(string), q, limit, offset)
+					trackList, err = playlistsManager.SearchUnsortedTracks(userID.(string), query, filter, limit, offset)
 				} else {
 					// Search within specific playlist
-					trackList, err = playlistsManager.SearchPlaylistTracks(playlistID, userID.(string), q, limit, offset)
+					trackList, err = playlistsManager.SearchPlaylistTracks(playlistID, userID.(string), query, filter, limit, offset)
 				}
 			} else {
 				// Search all user's tracks
-				trackList, err = manager.SearchTracks(c.Request.Context(), q, userID.(string), limit, offset)
+				trackList, err = manager.SearchTracks(c.Request.Context(), query, userID.(string), filter, limit, offset)
 			}
 		} else if playlistID != "" {
 			// No search, just list tracks from playlist/crate
 			if playlistID == "unsorted" {
 				// Get tracks not in any playlist
-				trackList, err = playlistsManager.GetUnsortedTracks(userID.(string), limit, offset)
+				trackList, err = playlistsManager.GetUnsortedTracks(userID.(string), filter, limit, offset)
 			} else {
 				// Get tracks from specific playlist
-				playlistWithTracks, playlistErr := playlistsManager.GetPlaylistTracks(playlistID, userID.(string), limit, offset)
+				playlistWithTracks, playlistErr := playlistsManager.GetPlaylistTracks(playlistID, userID.(string), filter, limit, offset)
 				if playlistErr != nil {
 					err = playlistErr
 				} else {
 					// Convert to TrackList format
 					trackList = &imodels.TrackList{
-						Tracks:  playlistWithTracks.Tracks,
-						Total:   playlistWithTracks.Total,
-						Limit:   playlistWithTracks.Limit,
-						Offset:  playlistWithTracks.Offset,
-						HasNext: playlistWithTracks.HasNext,
+						Tracks:     playlistWithTracks.Tracks,
+						Total:      playlistWithTracks.Total,
+						Limit:      playlistWithTracks.Limit,
+						Offset:     playlistWithTracks.Offset,
+						HasNext:    playlistWithTracks.HasNext,
+						NextCursor: playlistWithTracks.NextCursor,
 					}
 				}
 			}
 		} else {
 			// No search, no playlist - show all user's tracks
-			trackList, err = manager.GetTracks(c.Request.Context(), userID.(string), limit, offset)
+			trackList, err = manager.GetTracks(c.Request.Context(), userID.(string), filter, limit, offset)
 		}
 
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_cursor", "message": err.Error()}})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "server_error", "message": "Failed to fetch tracks"}})
 			return
 		}
 
+		// Facet counts cover the whole list, not just this page
+		if len(facets) > 0 {
+			scope, scopeArgs := LibraryScope(userID.(string))
+			if playlistID != "" {
+				scope, scopeArgs, err = playlistsManager.TrackScope(playlistID, userID.(string))
+			}
+			if err == nil {
+				trackList.Facets, err = manager.Facets(c.Request.Context(), scope, scopeArgs, query, userID.(string), filter, facets)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "server_error", "message": "Failed to count facets"}})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, trackList)
 	}
 }
@@ -233,6 +297,7 @@ func StreamHandler(manager *Manager) gin.HandlerFunc {
 			return
 		}
 		defer file.Close()
+		defer manager.beginStream()()
 
 		// Handle range requests for seeking
 		rangeHeader := c.GetHeader("Range")
@@ -265,6 +330,7 @@ func StreamHandler(manager *Manager) gin.HandlerFunc {
 
 // CoverHandler serves the cover-art sidecar image for a track.
 // Mirrors the auth pattern in StreamHandler. Returns 404 when the track has no cover.
+// With size= (one of ThumbnailSizes) it serves a cached thumbnail instead.
 func CoverHandler(manager *Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		trackID := c.Param("id")
@@ -287,14 +353,30 @@ func CoverHandler(manager *Manager) gin.HandlerFunc {
 			return
 		}
 
-		file, info, err := manager.OpenFile(c.Request.Context(), *track.CoverPath)
+		// size= serves a cached thumbnail instead of the full cover
+		coverPath := *track.CoverPath
+		if sizeStr := c.Query("size"); sizeStr != "" {
+			size, err := strconv.Atoi(sizeStr)
+			if err != nil || !slices.Contains(ThumbnailSizes, size) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_size", "message": fmt.Sprintf("size must be one of %v", ThumbnailSizes)}})
+				return
+			}
+			thumbPath, err := manager.CoverThumbnail(c.Request.Context(), coverPath, size)
+			if err != nil {
+				fmt.Printf("[CrateDrop] Warning: failed to make %dpx thumbnail for track %s: %v\n", size, trackID, err)
+			} else {
+				coverPath = thumbPath
+			}
+		}
+
+		file, info, err := manager.OpenFile(c.Request.Context(), coverPath)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "cover_not_found", "message": "Cover file missing"}})
 			return
 		}
 		defer file.Close()
 
-		ctype := mime.TypeByExtension(filepath.Ext(*track.CoverPath))
+		ctype := mime.TypeByExtension(filepath.Ext(coverPath))
 		if ctype == "" {
 			ctype = "image/jpeg"
 		}
@@ -328,16 +410,23 @@ func DownloadHandler(manager *Manager) gin.HandlerFunc {
 		// Generate download filename from metadata
 		downloadFilename := generateDownloadFilename(track)
 
-		// For MP3s, re-inject metadata that was stripped during sanitization
-		if isMP3ContentType(track.ContentType) && hasMetadata(track) {
-			if err := streamWithMetadata(c, manager, track, downloadFilename); err != nil {
-				fmt.Printf("[CrateDrop] Failed to stream with metadata: %v, falling back to direct download\n", err)
-				streamDirectDownload(c, manager, track, downloadFilename)
+		// For MP3s, re-inject metadata that was stripped during sanitization,
+		// along with the user's cues as chapters
+		if isMP3ContentType(track.ContentType) {
+			cues, err := manager.GetCues(c.Request.Context(), trackID, userID.(string))
+			if err != nil {
+				fmt.Printf("[CrateDrop] Failed to load cues for download: %v\n", err)
+			}
+			if hasMetadata(track) || len(cues) > 0 {
+				if err := streamWithMetadata(c, manager, track, cues, downloadFilename); err != nil {
+					fmt.Printf("[CrateDrop] Failed to stream with metadata: %v, falling back to direct download\n", err)
+					streamDirectDownload(c, manager, track, downloadFilename)
+				}
+				return
 			}
-			return
 		}
 
-		// Non-MP3 or no metadata: serve directly
+		// Non-MP3 or nothing to embed: serve directly
 		streamDirectDownload(c, manager, track, downloadFilename)
 	}
 }
@@ -360,13 +449,17 @@ func DeleteHandler(manager *Manager) gin.HandlerFunc {
 			return
 		}
 
-		// Delete track (manager handles both file and database deletion)
-		if err := manager.DeleteTrack(c.Request.Context(), trackID); err != nil {
+		// Move to the owner's trash; the file goes when the trash is purged
+		if err := manager.TrashTrack(c.Request.Context(), trackID); err != nil {
+			if errors.Is(err, ErrTrackNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "track_not_found", "message": "Track not found"}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "server_error", "message": err.Error()}})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Track deleted successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Track moved to trash"})
 	}
 }
 
@@ -464,18 +557,68 @@ func streamDirectDownload(c *gin.Context, manager *Manager, track *imodels.Track
 	io.Copy(c.Writer, file)
 }
 
-// streamWithMetadata uses ffmpeg to inject ID3 tags into the download stream
-func streamWithMetadata(c *gin.Context, manager *Manager, track *imodels.Track, filename string) error {
+// cueChapters renders a user's cues in ffmpeg's FFMETADATA1 format, which
+// the MP3 muxer writes as ID3v2 CHAP frames. A cue's chapter runs to the
+// next cue or the end of the track; a loop's chapter is the loop.
+func cueChapters(cues []Cue, duration float64) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for i, cue := range cues {
+		end := duration
+		if cue.Kind == "loop" && cue.EndSeconds > cue.Seconds {
+			end = cue.EndSeconds
+		} else if i < len(cues)-1 {
+			end = cues[i+1].Seconds
+		}
+		end = math.Max(end, cue.Seconds)
+		b.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\nEND=%d\n", int64(math.Round(cue.Seconds*1000)), int64(math.Round(end*1000)))
+		fmt.Fprintf(&b, "title=%s\n", escapeFFMetadata(cueLabel(cue)))
+	}
+	return b.String()
+}
+
+// cueLabel names a cue: its own label, or "Hot cue 3", "Memory cue",
+// "Loop" when it has none.
+func cueLabel(c Cue) string {
+	switch {
+	case c.Label != "":
+		return c.Label
+	case c.Kind == "hot":
+		return fmt.Sprintf("Hot cue %d", c.Slot)
+	case c.Kind == "loop":
+		return "Loop"
+	default:
+		return "Memory cue"
+	}
+}
+
+func escapeFFMetadata(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n")
+	return r.Replace(s)
+}
+
+// streamWithMetadata uses ffmpeg to inject ID3 tags, and the user's cues as
+// chapters, into the download stream
+func streamWithMetadata(c *gin.Context, manager *Manager, track *imodels.Track, cues []Cue, filename string) error {
 	fullPath, ok := manager.ResolveFullPath(track.FilePath)
 	if !ok {
 		return fmt.Errorf("failed to resolve file path")
 	}
 
 	// Build ffmpeg command to inject metadata
-	args := []string{
-		"-i", fullPath,
-		"-c:a", "copy", // Copy audio without re-encoding
+	args := []string{"-i", fullPath}
+	var chapters string
+	if len(cues) > 0 {
+		// Chapters come in on stdin as a second input
+		var duration float64
+		if track.DurationSeconds != nil {
+			duration = *track.DurationSeconds
+		}
+		chapters = cueChapters(cues, duration)
+		args = append(args, "-f", "ffmetadata", "-i", "pipe:0", "-map_chapters", "1")
 	}
+	args = append(args, "-c:a", "copy") // Copy audio without re-encoding
 
 	// Add metadata tags
 	if track.Title != nil && *track.Title != "" {
@@ -498,6 +641,7 @@ func streamWithMetadata(c *gin.Context, manager *Manager, track *imodels.Track,
 	args = append(args, "-f", "mp3", "pipe:1")
 
 	cmd := exec.CommandContext(c.Request.Context(), "ffmpeg", args...)
+	cmd.Stdin = strings.NewReader(chapters)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
@@ -0,0 +1,23 @@
+package tracks
+
+import "testing"
+
+func TestCueChapters(t *testing.T) {
+	cues := []Cue{
+		{Kind: "hot", Slot: 1, Seconds: 0.5},
+		{Kind: "loop", Label: "Break=8 bars", Seconds: 32, EndSeconds: 40.25},
+		{Kind: "memory", Seconds: 60},
+	}
+	want := ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=500\nEND=32000\ntitle=Hot cue 1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=32000\nEND=40250\ntitle=Break\\=8 bars\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=60000\nEND=180000\ntitle=Memory cue\n"
+	if got := cueChapters(cues, 180); got != want {
+		t.Errorf("cueChapters =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without a known duration the last chapter is just its start.
+	if got := cueChapters(cues[2:], 0); got != ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=60000\nEND=60000\ntitle=Memory cue\n" {
+		t.Errorf("cueChapters without duration =\n%s", got)
+	}
+}
@@ -2,6 +2,7 @@ package tracks
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -9,18 +10,32 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/faraz525/home-music-server/backend/analysis"
 	"github.com/faraz525/home-music-server/backend/internal/media/metadata"
 	imodels "github.com/faraz525/home-music-server/backend/internal/models"
 	"github.com/faraz525/home-music-server/backend/internal/storage"
+	"github.com/faraz525/home-music-server/backend/search"
 	"github.com/faraz525/home-music-server/backend/utils"
 )
 
+// ErrTrackNotFound is returned when a track doesn't exist or is already in
+// the trash
+var ErrTrackNotFound = errors.New("track not found")
+
 // Manager handles track business logic and API management
 type Manager struct {
 	repo      *Repository
 	storage   storage.Storage
 	extractor metadata.Extractor
+
+	// Stream activity, so background work can back off during playback.
+	// Players fetch in short range requests, so "streaming" means a request
+	// is in flight or one finished recently.
+	activeStreams atomic.Int32
+	lastStreamAt  atomic.Int64 // unix nanoseconds
 }
 
 // NewManager creates a new tracks manager
@@ -114,6 +129,7 @@ func (m *Manager) UploadTrack(ctx context.Context, userID string, fileHeader *mu
 	if v := firstNonZero(req.Bitrate, derefInt(md.Bitrate)); v > 0 {
 		track.Bitrate = &v
 	}
+	track.ISRC = md.ISRC
 
 	// Persist
 	fmt.Printf("[CrateDrop] Inserting track into database...\n")
@@ -390,24 +406,50 @@ func (m *Manager) OpenFile(ctx context.Context, relativePath string) (storage.Re
 	return m.storage.Open(ctx, relativePath)
 }
 
+// beginStream records a stream request; call the returned func when it ends.
+func (m *Manager) beginStream() func() {
+	m.activeStreams.Add(1)
+	m.lastStreamAt.Store(time.Now().UnixNano())
+	return func() {
+		m.lastStreamAt.Store(time.Now().UnixNano())
+		m.activeStreams.Add(-1)
+	}
+}
+
+// StreamingWithin reports whether a track is being streamed or finished
+// streaming within the window.
+func (m *Manager) StreamingWithin(window time.Duration) bool {
+	if m.activeStreams.Load() > 0 {
+		return true
+	}
+	last := m.lastStreamAt.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < window
+}
+
+// LibraryStats returns library-wide totals for the admin dashboard.
+func (m *Manager) LibraryStats(ctx context.Context) (*LibraryStats, error) {
+	return m.repo.GetLibraryStats(ctx)
+}
+
 // ResolveFullPath exposes storage path resolution for download with metadata
 func (m *Manager) ResolveFullPath(relativePath string) (string, bool) {
 	return m.storage.ResolveFullPath(relativePath)
 }
 
-// GetTracks retrieves tracks for a user with pagination
-func (m *Manager) GetTracks(ctx context.Context, userID string, limit, offset int) (*imodels.TrackList, error) {
-	tracks, err := m.repo.GetTracks(ctx, userID, limit, offset)
+// GetTracks retrieves tracks for a user with pagination, narrowed and
+// ordered by f (nil for all tracks, newest first)
+func (m *Manager) GetTracks(ctx context.Context, userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	tracks, next, err := m.repo.GetTracks(ctx, userID, f, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tracks: %w", err)
 	}
 
-	total, err := m.repo.GetTracksCount(ctx, userID)
+	total, err := m.repo.GetTracksCount(ctx, userID, f)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count tracks: %w", err)
 	}
 
-	return utils.NewTrackList(tracks, total, limit, offset), nil
+	return newTrackPage(tracks, total, limit, offset, next), nil
 }
 
 // GetAllTracks retrieves all tracks with search (admin only)
@@ -434,10 +476,25 @@ func (m *Manager) GetTrack(ctx context.Context, trackID string) (*imodels.Track,
 	return track, nil
 }
 
-// DeleteTrack deletes a track and its file
+// TrashTrack moves a track to its owner's trash. It drops out of lists and
+// search but keeps its file, crate memberships and analysis until it is
+// restored or purged.
+func (m *Manager) TrashTrack(ctx context.Context, trackID string) error {
+	found, err := m.repo.TrashTrack(ctx, trackID)
+	if err != nil {
+		return fmt.Errorf("failed to trash track: %w", err)
+	}
+	if !found {
+		return ErrTrackNotFound
+	}
+	return nil
+}
+
+// DeleteTrack deletes a track and its file for good, whether or not it is
+// in the trash
 func (m *Manager) DeleteTrack(ctx context.Context, trackID string) error {
 	// Get track info first
-	track, err := m.repo.GetTrackByID(ctx, trackID)
+	track, err := m.repo.GetTrackByIDWithTrashed(ctx, trackID)
 	if err != nil {
 		return fmt.Errorf("track not found: %w", err)
 	}
@@ -452,6 +509,7 @@ func (m *Manager) DeleteTrack(ctx context.Context, trackID string) error {
 		if err := m.storage.Delete(ctx, *track.CoverPath); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("[CrateDrop] Warning: failed to delete cover for track %s: %v\n", trackID, err)
 		}
+		m.removeThumbnails(ctx, *track.CoverPath)
 	}
 
 	// Delete from database
@@ -462,18 +520,35 @@ func (m *Manager) DeleteTrack(ctx context.Context, trackID string) error {
 	return nil
 }
 
-// SearchTracks searches tracks for a user
-func (m *Manager) SearchTracks(ctx context.Context, query, userID string, limit, offset int) (*imodels.TrackList, error) {
-	tracks, err := m.repo.SearchTracks(ctx, query, userID, limit, offset)
+// SearchTracks searches tracks for a user with a parsed query, narrowed and
+// ordered by f. When nothing matches exactly it falls back to close
+// matches for the query's free text (see FuzzySearch).
+func (m *Manager) SearchTracks(ctx context.Context, q *search.Query, userID string, f *search.ListFilter, limit, offset int) (*imodels.TrackList, error) {
+	tracks, next, err := m.repo.SearchTracks(ctx, q, userID, f, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search tracks: %w", err)
 	}
 
-	// For search results, we don't provide total count for simplicity
-	// In production, you might want to implement this
-	total := len(tracks) // This is not accurate for pagination
+	total, err := m.repo.SearchTracksCount(ctx, q, userID, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tracks: %w", err)
+	}
+	if total == 0 {
+		return m.FuzzySearch(ctx, q, userID, f, limit, offset)
+	}
+
+	return newTrackPage(tracks, total, limit, offset, next), nil
+}
 
-	return utils.NewTrackList(tracks, total, limit, offset), nil
+// newTrackPage is a page of a track list fetched with a cursor or an
+// offset. next, the cursor for the page after, also says whether there is
+// one, which offset+limit < total can't tell once the page started at a
+// cursor.
+func newTrackPage(tracks []*imodels.Track, total, limit, offset int, next string) *imodels.TrackList {
+	list := utils.NewTrackList(tracks, total, limit, offset)
+	list.HasNext = next != ""
+	list.NextCursor = next
+	return list
 }
 
 // GetStreamInfo returns information needed for streaming
@@ -525,3 +600,14 @@ func derefInt(p *int) int {
 func (m *Manager) UpdateAnalysisOverride(ctx context.Context, trackID string, bpm *float64, musicalKey *string) error {
 	return m.repo.UpdateAnalysisOverride(ctx, trackID, bpm, musicalKey)
 }
+
+// GetCues returns a user's cues and loops on a track, in position order.
+func (m *Manager) GetCues(ctx context.Context, trackID, userID string) ([]Cue, error) {
+	return m.repo.GetCues(ctx, trackID, userID)
+}
+
+// GetGrid returns a track's beatgrid and cues, or nil if it hasn't been
+// gridded yet.
+func (m *Manager) GetGrid(ctx context.Context, trackID string) (*analysis.Grid, error) {
+	return m.repo.GetGrid(ctx, trackID)
+}
@@ -0,0 +1,209 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Limits.
+const (
+	// DefaultRetentionDays is how long trashed tracks are kept until an
+	// admin sets otherwise.
+	DefaultRetentionDays = 30
+	maxRetentionDays     = 365
+
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// Item is a track in a user's trash.
+type Item struct {
+	ID               string    `json:"id"`
+	Title            *string   `json:"title,omitempty"`
+	Artist           *string   `json:"artist,omitempty"`
+	Album            *string   `json:"album,omitempty"`
+	DurationSeconds  *float64  `json:"duration_seconds,omitempty"`
+	OriginalFilename string    `json:"original_filename"`
+	ContentType      string    `json:"content_type"`
+	SizeBytes        int64     `json:"size_bytes"`
+	DeletedAt        time.Time `json:"deleted_at"`
+	// ExpiresAt is when the purge deletes the track and its file for good.
+	ExpiresAt time.Time `json:"expires_at"`
+	// CrateCount is how many crates the track returns to if restored.
+	CrateCount int `json:"crate_count"`
+}
+
+// ItemList is a page of a user's trash.
+type ItemList struct {
+	Items         []*Item `json:"items"`
+	Total         int     `json:"total"`
+	Limit         int     `json:"limit"`
+	Offset        int     `json:"offset"`
+	HasNext       bool    `json:"has_next"`
+	RetentionDays int     `json:"retention_days"`
+}
+
+// Settings are the server-wide trash settings.
+type Settings struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+// EmptyResult is what emptying trash deleted.
+type EmptyResult struct {
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed,omitempty"`
+}
+
+// TrackDeleter deletes a track, trashed or not, with its file, cover and
+// everything that hangs off it; tracks.Manager.
+type TrackDeleter interface {
+	DeleteTrack(ctx context.Context, trackID string) error
+}
+
+// Manager keeps each user's trash: tracks they deleted, hidden from the
+// library but kept, with their crate memberships and analysis, for the
+// retention period so they can be restored. Tracks go to the trash
+// through tracks.Manager.TrashTrack.
+type Manager struct {
+	repo             *Repository
+	tracks           TrackDeleter
+	defaultRetention int
+	now              func() time.Time
+}
+
+// NewManager returns a Manager keeping trash for defaultRetention days
+// until an admin changes it; DefaultRetentionDays when it is out of range.
+func NewManager(repo *Repository, tracks TrackDeleter, defaultRetention int) *Manager {
+	if defaultRetention < 1 || defaultRetention > maxRetentionDays {
+		defaultRetention = DefaultRetentionDays
+	}
+	return &Manager{repo: repo, tracks: tracks, defaultRetention: defaultRetention, now: time.Now}
+}
+
+// Settings returns the trash settings in force.
+func (m *Manager) Settings(ctx context.Context) (*Settings, error) {
+	days, ok, err := m.repo.RetentionDays(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load trash settings: %w", err)
+	}
+	if !ok {
+		days = m.defaultRetention
+	}
+	return &Settings{RetentionDays: days}, nil
+}
+
+// SetRetention changes how many days trashed tracks are kept. It applies
+// to tracks already in the trash too, so shortening it lets the next purge
+// delete more.
+func (m *Manager) SetRetention(ctx context.Context, days int) (*Settings, error) {
+	if days < 1 || days > maxRetentionDays {
+		return nil, fmt.Errorf("%w: retention_days must be between 1 and %d", ErrInvalidRequest, maxRetentionDays)
+	}
+	if err := m.repo.SetRetentionDays(ctx, days); err != nil {
+		return nil, fmt.Errorf("save trash settings: %w", err)
+	}
+	return &Settings{RetentionDays: days}, nil
+}
+
+// List returns a page of the user's trash.
+func (m *Manager) List(ctx context.Context, userID string, limit, offset int) (*ItemList, error) {
+	if limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	settings, err := m.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, total, err := m.repo.List(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	for _, it := range items {
+		it.ExpiresAt = it.DeletedAt.AddDate(0, 0, settings.RetentionDays)
+	}
+	return &ItemList{
+		Items:         items,
+		Total:         total,
+		Limit:         limit,
+		Offset:        offset,
+		HasNext:       offset+limit < total,
+		RetentionDays: settings.RetentionDays,
+	}, nil
+}
+
+// Restore puts a track from the user's trash back in their library and
+// crates.
+func (m *Manager) Restore(ctx context.Context, userID, trackID string) error {
+	restored, err := m.repo.Restore(ctx, userID, trackID)
+	if err != nil {
+		return fmt.Errorf("restore track: %w", err)
+	}
+	if !restored {
+		return fmt.Errorf("%w: track %s is not in your trash", ErrNotFound, trackID)
+	}
+	return nil
+}
+
+// Delete deletes a track in the user's trash for good, without waiting for
+// the purge.
+func (m *Manager) Delete(ctx context.Context, userID, trackID string) error {
+	trashed, err := m.repo.InTrash(ctx, userID, trackID)
+	if err != nil {
+		return fmt.Errorf("find track: %w", err)
+	}
+	if !trashed {
+		return fmt.Errorf("%w: track %s is not in your trash", ErrNotFound, trackID)
+	}
+	if err := m.tracks.DeleteTrack(ctx, trackID); err != nil {
+		return fmt.Errorf("delete track: %w", err)
+	}
+	return nil
+}
+
+// Empty deletes everything in userID's trash for good, or in everyone's
+// when userID is empty.
+func (m *Manager) Empty(ctx context.Context, userID string) (*EmptyResult, error) {
+	return m.deleteBefore(ctx, userID, m.now())
+}
+
+// Purge deletes the tracks that have been in the trash longer than the
+// retention period, with their files.
+func (m *Manager) Purge(ctx context.Context) (*EmptyResult, error) {
+	settings, err := m.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.deleteBefore(ctx, "", m.now().AddDate(0, 0, -settings.RetentionDays))
+}
+
+// deleteBefore deletes trashed tracks deleted at or before cutoff. A track
+// that fails to delete stays in the trash for the next try.
+func (m *Manager) deleteBefore(ctx context.Context, userID string, cutoff time.Time) (*EmptyResult, error) {
+	ids, err := m.repo.TrashedIDs(ctx, userID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	result := &EmptyResult{}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if err := m.tracks.DeleteTrack(ctx, id); err != nil {
+			fmt.Printf("[Trash] Failed to delete track %s: %v\n", id, err)
+			result.Failed++
+			continue
+		}
+		result.Deleted++
+	}
+	return result, nil
+}
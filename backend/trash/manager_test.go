@@ -0,0 +1,197 @@
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fakeTracks deletes track rows as tracks.Manager would, files aside.
+type fakeTracks struct {
+	db *sql.DB
+}
+
+func (f *fakeTracks) DeleteTrack(ctx context.Context, trackID string) error {
+	if _, err := f.db.ExecContext(ctx, `DELETE FROM playlist_tracks WHERE track_id = ?`, trackID); err != nil {
+		return err
+	}
+	_, err := f.db.ExecContext(ctx, `DELETE FROM tracks WHERE id = ?`, trackID)
+	return err
+}
+
+func newTestManager(t *testing.T) (*Manager, *sql.DB) {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = sqlDB.Exec(`
+		CREATE TABLE tracks (
+			id TEXT PRIMARY KEY, owner_user_id TEXT, title TEXT, artist TEXT, album TEXT, duration_seconds REAL,
+			original_filename TEXT NOT NULL, content_type TEXT NOT NULL, size_bytes INTEGER NOT NULL,
+			deleted_at DATETIME
+		);
+		CREATE TABLE playlist_tracks (
+			id TEXT PRIMARY KEY, playlist_id TEXT NOT NULL, track_id TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0, UNIQUE(playlist_id, track_id)
+		);
+		CREATE TABLE trash_settings (
+			id INTEGER PRIMARY KEY CHECK (id = 1), retention_days INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO tracks (id, owner_user_id, title, original_filename, content_type, size_bytes, deleted_at) VALUES
+			('live', 'u1', 'Live', 'live.mp3', 'audio/mpeg', 1, NULL),
+			('old', 'u1', 'Old', 'old.mp3', 'audio/mpeg', 1, '2026-01-01 10:00:00'),
+			('new', 'u1', 'New', 'new.mp3', 'audio/mpeg', 1, '2026-01-25 10:00:00'),
+			('other', 'u2', 'Other', 'other.mp3', 'audio/mpeg', 1, '2026-01-01 10:00:00');
+		INSERT INTO playlist_tracks (id, playlist_id, track_id, position) VALUES
+			('pt1', 'c1', 'live', 0), ('pt2', 'c1', 'old', 1), ('pt3', 'c2', 'old', 4);
+	`)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	m := NewManager(NewRepository(&db.DB{DB: sqlDB}), &fakeTracks{db: sqlDB}, 30)
+	m.now = func() time.Time { return time.Date(2026, 2, 5, 12, 0, 0, 0, time.UTC) }
+	return m, sqlDB
+}
+
+func trackIDs(t *testing.T, sqlDB *sql.DB) map[string]bool {
+	t.Helper()
+	rows, err := sqlDB.Query(`SELECT id FROM tracks`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids[id] = true
+	}
+	return ids
+}
+
+func TestList(t *testing.T) {
+	m, _ := newTestManager(t)
+	list, err := m.List(context.Background(), "u1", 0, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if list.Total != 2 || len(list.Items) != 2 || list.RetentionDays != 30 {
+		t.Fatalf("list = %+v, want the two trashed u1 tracks", list)
+	}
+	if list.Items[0].ID != "new" || list.Items[1].ID != "old" {
+		t.Errorf("order = %s, %s; want most recently deleted first", list.Items[0].ID, list.Items[1].ID)
+	}
+	old := list.Items[1]
+	if want := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC); !old.ExpiresAt.Equal(want) {
+		t.Errorf("expires_at = %v, want %v", old.ExpiresAt, want)
+	}
+	if old.CrateCount != 2 {
+		t.Errorf("crate_count = %d, want 2", old.CrateCount)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+	if err := m.Restore(ctx, "u2", "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restore another user's track: err = %v, want ErrNotFound", err)
+	}
+	if err := m.Restore(ctx, "u1", "live"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restore a track not in the trash: err = %v, want ErrNotFound", err)
+	}
+	if err := m.Restore(ctx, "u1", "old"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var deletedAt sql.NullString
+	var position int
+	sqlDB.QueryRow(`SELECT deleted_at FROM tracks WHERE id = 'old'`).Scan(&deletedAt)
+	sqlDB.QueryRow(`SELECT position FROM playlist_tracks WHERE playlist_id = 'c2' AND track_id = 'old'`).Scan(&position)
+	if deletedAt.Valid || position != 4 {
+		t.Errorf("deleted_at = %v, position = %d; want restored at position 4", deletedAt, position)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+	if err := m.Delete(ctx, "u1", "live"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete a track not in the trash: err = %v, want ErrNotFound", err)
+	}
+	if err := m.Delete(ctx, "u1", "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete another user's track: err = %v, want ErrNotFound", err)
+	}
+	if err := m.Delete(ctx, "u1", "new"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ids := trackIDs(t, sqlDB); ids["new"] || !ids["live"] {
+		t.Errorf("tracks = %v, want only new deleted", ids)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+	result, err := m.Purge(ctx)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if result.Deleted != 2 {
+		t.Errorf("deleted = %d, want 2", result.Deleted)
+	}
+	if ids := trackIDs(t, sqlDB); ids["old"] || ids["other"] || !ids["new"] || !ids["live"] {
+		t.Errorf("tracks = %v, want the expired ones gone", ids)
+	}
+
+	if _, err := m.SetRetention(ctx, 7); err != nil {
+		t.Fatalf("SetRetention: %v", err)
+	}
+	if _, err := m.Purge(ctx); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if ids := trackIDs(t, sqlDB); ids["new"] || !ids["live"] {
+		t.Errorf("tracks = %v, want new purged under the shorter retention", ids)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	m, sqlDB := newTestManager(t)
+	ctx := context.Background()
+	result, err := m.Empty(ctx, "u2")
+	if err != nil {
+		t.Fatalf("Empty: %v", err)
+	}
+	if ids := trackIDs(t, sqlDB); result.Deleted != 1 || ids["other"] || !ids["old"] {
+		t.Errorf("deleted = %d, tracks = %v; want only u2's trash emptied", result.Deleted, ids)
+	}
+	if result, err = m.Empty(ctx, ""); err != nil {
+		t.Fatalf("Empty: %v", err)
+	}
+	if ids := trackIDs(t, sqlDB); result.Deleted != 2 || len(ids) != 1 || !ids["live"] {
+		t.Errorf("deleted = %d, tracks = %v; want every trash emptied", result.Deleted, ids)
+	}
+}
+
+func TestSetRetention(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	for _, days := range []int{0, -1, maxRetentionDays + 1} {
+		if _, err := m.SetRetention(ctx, days); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("SetRetention(%d): err = %v, want ErrInvalidRequest", days, err)
+		}
+	}
+	if _, err := m.SetRetention(ctx, 90); err != nil {
+		t.Fatalf("SetRetention: %v", err)
+	}
+	if s, _ := m.Settings(ctx); s.RetentionDays != 90 {
+		t.Errorf("retention = %d, want 90", s.RetentionDays)
+	}
+}
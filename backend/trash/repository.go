@@ -0,0 +1,130 @@
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/faraz525/home-music-server/backend/internal/db"
+)
+
+type Repository struct {
+	db *db.DB
+}
+
+func NewRepository(db *db.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// sqlTime formats t as SQLite's CURRENT_TIMESTAMP does, so it compares
+// with deleted_at as text.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// List returns a page of a user's trash, most recently deleted first, and
+// how many tracks it holds in all.
+func (r *Repository) List(ctx context.Context, userID string, limit, offset int) ([]*Item, int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM tracks WHERE owner_user_id = ? AND deleted_at IS NOT NULL`, userID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.title, t.artist, t.album, t.duration_seconds, t.original_filename, t.content_type,
+		       t.size_bytes, t.deleted_at,
+		       (SELECT COUNT(*) FROM playlist_tracks pt WHERE pt.track_id = t.id)
+		FROM tracks t
+		WHERE t.owner_user_id = ? AND t.deleted_at IS NOT NULL
+		ORDER BY t.deleted_at DESC, t.id
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []*Item{}
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ID, &it.Title, &it.Artist, &it.Album, &it.DurationSeconds, &it.OriginalFilename, &it.ContentType,
+			&it.SizeBytes, &it.DeletedAt, &it.CrateCount); err != nil {
+			return nil, 0, err
+		}
+		items = append(items, &it)
+	}
+	return items, total, rows.Err()
+}
+
+// Restore takes a track out of its owner's trash, reporting whether it was
+// there. Its crate memberships never left, so it is back where it was.
+func (r *Repository) Restore(ctx context.Context, userID, trackID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE tracks SET deleted_at = NULL WHERE id = ? AND owner_user_id = ? AND deleted_at IS NOT NULL`,
+		trackID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// InTrash reports whether a track is in the user's trash.
+func (r *Repository) InTrash(ctx context.Context, userID, trackID string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM tracks WHERE id = ? AND owner_user_id = ? AND deleted_at IS NOT NULL`,
+		trackID, userID,
+	).Scan(&n)
+	return n > 0, err
+}
+
+// TrashedIDs returns the IDs of trashed tracks deleted at or before
+// before, oldest first, limited to userID's when it isn't empty.
+func (r *Repository) TrashedIDs(ctx context.Context, userID string, before time.Time) ([]string, error) {
+	query := `SELECT id FROM tracks WHERE deleted_at IS NOT NULL AND deleted_at <= ?`
+	args := []any{sqlTime(before)}
+	if userID != "" {
+		query += ` AND owner_user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY deleted_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RetentionDays returns the retention an admin set, or ok false when none
+// has been.
+func (r *Repository) RetentionDays(ctx context.Context) (days int, ok bool, err error) {
+	err = r.db.QueryRowContext(ctx, `SELECT retention_days FROM trash_settings WHERE id = 1`).Scan(&days)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return days, err == nil, err
+}
+
+// SetRetentionDays stores the retention for every user's trash.
+func (r *Repository) SetRetentionDays(ctx context.Context, days int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO trash_settings (id, retention_days, updated_at) VALUES (1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (id) DO UPDATE SET retention_days = excluded.retention_days, updated_at = excluded.updated_at
+	`, days)
+	return err
+}
@@ -0,0 +1,25 @@
+package trash
+
+import (
+	"github.com/faraz525/home-music-server/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func Routes(manager *Manager) func(*gin.RouterGroup) {
+	return func(rg *gin.RouterGroup) {
+		handlers := NewHandlers(manager)
+
+		r := rg.Group("/trash")
+		{
+			r.GET("", handlers.List)
+			r.POST("/:id/restore", handlers.Restore)
+			r.DELETE("/:id", handlers.Delete)
+
+			admin := r.Group("/admin")
+			admin.Use(auth.AdminMiddleware())
+			admin.GET("/settings", handlers.GetSettings)
+			admin.PUT("/settings", handlers.UpdateSettings)
+			admin.POST("/empty", handlers.Empty)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package trash
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StartLoop purges expired trash at startup and then on interval.
+func StartLoop(ctx context.Context, m *Manager, interval time.Duration) {
+	fmt.Printf("[Trash] Starting purge loop (interval=%s)\n", interval)
+	purge := func() {
+		result, err := m.Purge(ctx)
+		if err != nil {
+			fmt.Printf("[Trash] Purge failed: %v\n", err)
+			return
+		}
+		if result.Deleted > 0 || result.Failed > 0 {
+			fmt.Printf("[Trash] Purged %d expired track(s), %d failed\n", result.Deleted, result.Failed)
+		}
+	}
+	purge()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("[Trash] Purge loop stopped")
+			return
+		case <-ticker.C:
+			purge()
+		}
+	}
+}
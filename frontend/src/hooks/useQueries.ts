@@ -37,7 +37,9 @@ export function useTracks(params: { q?: string; selectedCrate?: string }) {
     initialPageParam: 0,
     queryFn: async ({ pageParam }) => {
       const offset = pageParam as number
-      const pageParams = { q: q || undefined, limit: TRACKS_PAGE_SIZE, offset }
+      // typing: the search box sends each pause, so a half-typed query
+      // searches as plain words instead of failing.
+      const pageParams = { q: q || undefined, typing: q ? true : undefined, limit: TRACKS_PAGE_SIZE, offset }
 
       let response
       if (selectedCrate === 'unsorted') {
@@ -106,7 +106,7 @@ export const communityApi = {
 export const playlistsApi = cratesApi
 
 // Unsorted tracks
-export type UnsortedParams = { limit?: number; offset?: number; q?: string }
+export type UnsortedParams = { limit?: number; offset?: number; q?: string; typing?: boolean }
 export const tracksApi = {
   getUnsorted: (params?: UnsortedParams) =>
     api.get('/api/tracks', { params: { ...(params || {}), playlist_id: 'unsorted' } }),
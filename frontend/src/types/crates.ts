@@ -71,7 +71,7 @@ export type Track = {
   musical_key?: string
   key_confidence?: number
   analyzed_at?: string
-  analysis_status?: 'pending' | 'analyzed' | 'failed' | 'user_edited'
+  analysis_status?: 'pending' | 'analyzing' | 'analyzed' | 'failed' | 'user_edited'
   file_path?: string
   cover_path?: string
   created_at?: string